@@ -53,6 +53,15 @@ include $(dir)/Rules.mk
 dir := filestore/pb
 include $(dir)/Rules.mk
 
+dir := relay/pb
+include $(dir)/Rules.mk
+
+dir := autonat/pb
+include $(dir)/Rules.mk
+
+dir := holepunch/pb
+include $(dir)/Rules.mk
+
 
 # -------------------- #
 #   universal rules    #
@@ -0,0 +1,255 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	mdtest "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag/test"
+)
+
+type testEntry struct {
+	name     string
+	typ      byte
+	body     string
+	linkname string
+}
+
+func writeTestTar(t *testing.T, entries []testEntry) []byte {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		h := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typ,
+			Mode:     0644,
+			Size:     int64(len(e.body)),
+			Linkname: e.linkname,
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readTestTar(t *testing.T, data []byte) map[string]testEntry {
+	out := make(map[string]testEntry)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[h.Name] = testEntry{name: h.Name, typ: h.Typeflag, body: string(body), linkname: h.Linkname}
+	}
+}
+
+func TestTarRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	in := writeTestTar(t, []testEntry{
+		{name: "dir/", typ: tar.TypeDir},
+		{name: "dir/a.txt", typ: tar.TypeReg, body: "aaa"},
+		{name: "./dir/sub/b.txt", typ: tar.TypeReg, body: "bbb"},
+		{name: "../escaped.txt", typ: tar.TypeReg, body: "confined"},
+		{name: "dir/link", typ: tar.TypeSymlink, linkname: "a.txt"},
+		{name: "dir/hard", typ: tar.TypeLink, linkname: "dir/a.txt"},
+		{name: "dir/a.txt", typ: tar.TypeReg, body: "replaced"},
+		{name: "fifo", typ: tar.TypeFifo},
+	})
+
+	res, err := Import(ctx, bytes.NewReader(in), ds, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Entries != 7 || res.Skipped != 1 {
+		t.Fatalf("expected 7 entries and 1 skipped, got %d and %d", res.Entries, res.Skipped)
+	}
+
+	out := new(bytes.Buffer)
+	if err := Export(ctx, out, res.Root, ds, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	entries := readTestTar(t, out.Bytes())
+
+	expected := map[string]testEntry{
+		"dir/":          {typ: tar.TypeDir},
+		"dir/a.txt":     {typ: tar.TypeReg, body: "replaced"},
+		"dir/hard":      {typ: tar.TypeReg, body: "aaa"},
+		"dir/link":      {typ: tar.TypeSymlink, linkname: "a.txt"},
+		"dir/sub/":      {typ: tar.TypeDir},
+		"dir/sub/b.txt": {typ: tar.TypeReg, body: "bbb"},
+		"escaped.txt":   {typ: tar.TypeReg, body: "confined"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d: %v", len(expected), len(entries), entries)
+	}
+	for name, exp := range expected {
+		e, ok := entries[name]
+		if !ok {
+			t.Fatalf("missing entry %s", name)
+		}
+		if e.typ != exp.typ || e.body != exp.body || e.linkname != exp.linkname {
+			t.Fatalf("unexpected entry %s: %+v", name, e)
+		}
+	}
+
+	again := new(bytes.Buffer)
+	if err := Export(ctx, again, res.Root, ds, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), again.Bytes()) {
+		t.Fatal("the export is not deterministic")
+	}
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, body := range map[string]string{"a/one.txt": "one", "a/b/two.txt": "two"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Import(ctx, buf, ds, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	if err := Export(ctx, out, res.Root, ds, ExportOptions{Format: Zip}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "a/b/two.txt" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != "two" {
+				t.Fatalf("unexpected content %q", body)
+			}
+		}
+	}
+	expected := []string{"a/", "a/b/", "a/b/two.txt", "a/one.txt"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected entries %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected entries %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestImportUnknownFormat(t *testing.T) {
+	_, err := Import(context.Background(), bytes.NewReader([]byte("not an archive")), mdtest.Mock(), ImportOptions{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestExportSkip(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	in := writeTestTar(t, []testEntry{
+		{name: "a.txt", typ: tar.TypeReg, body: "aaa"},
+		{name: "b.txt", typ: tar.TypeReg, body: "bbb"},
+	})
+	res, err := Import(ctx, bytes.NewReader(in), ds, ImportOptions{PreserveMode: true, PreserveMtime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the CID of the content, without the attributes
+	content, err := coreunix.BuildFile(bytes.NewReader([]byte("aaa")), ds, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	err = Export(ctx, out, res.Root, ds, ExportOptions{
+		Name: "root",
+		Skip: func(p string, size int64, c cid.Cid) bool {
+			return p == "a.txt" && size == 3 && c.Equals(content.Cid())
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(out)
+	skipped := make(map[string]string)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := h.PAXRecords[SkippedPAXRecord]; ok {
+			if h.Size != 0 {
+				t.Fatalf("expected the skipped entry %s to be empty", h.Name)
+			}
+			skipped[h.Name] = s
+		}
+	}
+	if len(skipped) != 1 || skipped["root/a.txt"] != "3" {
+		t.Fatalf("expected root/a.txt to be skipped, got %v", skipped)
+	}
+
+	err = Export(ctx, ioutil.Discard, res.Root, ds, ExportOptions{
+		Format: Zip,
+		Skip:   func(string, int64, cid.Cid) bool { return true },
+	})
+	if err == nil {
+		t.Fatal("expected an error skipping files in a zip archive")
+	}
+}
@@ -0,0 +1,323 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// The times of the entries without a stored modification time. The zip
+// format can't represent times before 1980.
+var (
+	tarEpoch = time.Unix(0, 0).UTC()
+	zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// The modes of the entries without stored permissions.
+const (
+	defaultFileMode = 0644
+	defaultDirMode  = 0755
+)
+
+// SkippedPAXRecord is the PAX record of the entries of the files skipped
+// by ExportOptions.Skip, holding their size. The entries are empty.
+const SkippedPAXRecord = "IPFS.skipped"
+
+// ExportOptions are the options of Export.
+type ExportOptions struct {
+	// Format is the format of the archive, tar when empty.
+	Format Format
+
+	// Name is the name of the entry of the root, which a root file needs.
+	// The entries of a root directory without a name are written relative
+	// to it.
+	Name string
+
+	// Skip, when set, is called with the path of every file, relative to
+	// the root, its size and the CID of its content: the CID of its node
+	// without the attributes. The content of the files it returns true for
+	// is neither fetched nor written: they are written as empty entries
+	// with the SkippedPAXRecord record. Only tar archives support it.
+	Skip func(p string, size int64, c cid.Cid) bool
+}
+
+// Export writes the UnixFS tree under root to w as an archive. The files
+// are read as they are written, so the archive is never held in memory.
+//
+// The output is deterministic: the entries are written in the order of
+// their paths, without owners, with the permissions and the modification
+// times stored in the nodes or fixed ones, so exporting a tree always writes
+// the same bytes.
+func Export(ctx context.Context, w io.Writer, root ipld.Node, ds ipld.DAGService, opts ExportOptions) error {
+	var ew entryWriter
+	switch opts.Format {
+	case "", Tar:
+		ew = &tarEntryWriter{tw: tar.NewWriter(w)}
+	case Zip:
+		ew = &zipEntryWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unknown archive format %q", opts.Format)
+	}
+
+	if opts.Skip != nil && opts.Format == Zip {
+		return fmt.Errorf("files can only be skipped in tar archives")
+	}
+
+	ex := &exporter{ctx: ctx, ds: ds, w: ew, name: opts.Name, skip: opts.Skip}
+	if err := ex.walk(opts.Name, root); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// entryWriter writes the entries of an archive.
+type entryWriter interface {
+	dir(p string, attrs coreunix.FileAttrs) error
+	file(p string, size int64, attrs coreunix.FileAttrs, r io.Reader) error
+	symlink(p, target string, attrs coreunix.FileAttrs) error
+	skipped(p string, size int64, attrs coreunix.FileAttrs) error
+	Close() error
+}
+
+type exporter struct {
+	ctx  context.Context
+	ds   ipld.DAGService
+	w    entryWriter
+	name string
+	skip func(p string, size int64, c cid.Cid) bool
+}
+
+// skipped returns whether the file nd at p is skipped.
+func (ex *exporter) skipped(p string, size int64, nd ipld.Node, attrs coreunix.FileAttrs) (bool, error) {
+	if ex.skip == nil {
+		return false, nil
+	}
+	c := nd.Cid()
+	if !attrs.IsZero() {
+		pn, err := coreunix.SetFileAttrs(nd, coreunix.FileAttrs{}, nil)
+		if err != nil {
+			return false, err
+		}
+		c = pn.Cid()
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(p, ex.name), "/")
+	return ex.skip(rel, size, c), nil
+}
+
+// walk writes the entries of nd at p, an empty p being the root.
+func (ex *exporter) walk(p string, nd ipld.Node) error {
+	if err := ex.ctx.Err(); err != nil {
+		return err
+	}
+
+	attrs, err := coreunix.GetFileAttrs(nd)
+	if err != nil {
+		return err
+	}
+
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		if p == "" {
+			return fmt.Errorf("the entry of a root file needs a name")
+		}
+		size := int64(len(nd.RawData()))
+		skip, err := ex.skipped(p, size, nd, attrs)
+		if err != nil {
+			return err
+		}
+		if skip {
+			return ex.w.skipped(p, size, attrs)
+		}
+		return ex.w.file(p, size, attrs, bytes.NewReader(nd.RawData()))
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return err
+		}
+
+		switch fsn.Type() {
+		case ft.TDirectory, ft.THAMTShard:
+			return ex.walkDir(p, nd, attrs)
+		case ft.TFile, ft.TRaw:
+			if p == "" {
+				return fmt.Errorf("the entry of a root file needs a name")
+			}
+			size := int64(fsn.FileSize())
+			skip, err := ex.skipped(p, size, nd, attrs)
+			if err != nil {
+				return err
+			}
+			if skip {
+				return ex.w.skipped(p, size, attrs)
+			}
+			dr, err := uio.NewDagReader(ex.ctx, nd, ex.ds)
+			if err != nil {
+				return err
+			}
+			defer dr.Close()
+			return ex.w.file(p, int64(dr.Size()), attrs, dr)
+		case ft.TSymlink:
+			if p == "" {
+				return fmt.Errorf("the entry of a root symlink needs a name")
+			}
+			return ex.w.symlink(p, string(fsn.Data()), attrs)
+		default:
+			return fmt.Errorf("%s: unsupported unixfs type %s", p, fsn.Type())
+		}
+	default:
+		return fmt.Errorf("%s: not a unixfs node", p)
+	}
+}
+
+func (ex *exporter) walkDir(p string, nd ipld.Node, attrs coreunix.FileAttrs) error {
+	if p != "" {
+		if err := ex.w.dir(p, attrs); err != nil {
+			return err
+		}
+	}
+
+	dir, err := uio.NewDirectoryFromNode(ex.ds, nd)
+	if err != nil {
+		return err
+	}
+	links, err := dir.Links(ex.ctx)
+	if err != nil {
+		return err
+	}
+	// the links of sharded directories are in the order of their hashes
+	sort.Slice(links, func(i, j int) bool { return links[i].Name < links[j].Name })
+
+	for _, l := range links {
+		child, err := l.GetNode(ex.ctx, ex.ds)
+		if err != nil {
+			return err
+		}
+		if err := ex.walk(gopath.Join(p, l.Name), child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func entryMode(attrs coreunix.FileAttrs, def os.FileMode) os.FileMode {
+	if attrs.HasMode {
+		return attrs.Mode
+	}
+	return def
+}
+
+func entryTime(attrs coreunix.FileAttrs, def time.Time) time.Time {
+	if attrs.Mtime.IsZero() {
+		return def
+	}
+	// the zip times are written in the location of the time
+	return attrs.Mtime.UTC()
+}
+
+type tarEntryWriter struct {
+	tw *tar.Writer
+}
+
+func (w *tarEntryWriter) header(name string, typ byte, attrs coreunix.FileAttrs, def os.FileMode) *tar.Header {
+	return &tar.Header{
+		Name:     name,
+		Typeflag: typ,
+		Mode:     coreunix.FileAttrs{Mode: entryMode(attrs, def)}.UnixMode(),
+		ModTime:  entryTime(attrs, tarEpoch),
+	}
+}
+
+func (w *tarEntryWriter) dir(p string, attrs coreunix.FileAttrs) error {
+	return w.tw.WriteHeader(w.header(p+"/", tar.TypeDir, attrs, defaultDirMode))
+}
+
+func (w *tarEntryWriter) file(p string, size int64, attrs coreunix.FileAttrs, r io.Reader) error {
+	h := w.header(p, tar.TypeReg, attrs, defaultFileMode)
+	h.Size = size
+	if err := w.tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func (w *tarEntryWriter) symlink(p, target string, attrs coreunix.FileAttrs) error {
+	h := w.header(p, tar.TypeSymlink, attrs, os.ModePerm)
+	h.Linkname = target
+	return w.tw.WriteHeader(h)
+}
+
+func (w *tarEntryWriter) skipped(p string, size int64, attrs coreunix.FileAttrs) error {
+	h := w.header(p, tar.TypeReg, attrs, defaultFileMode)
+	h.Format = tar.FormatPAX
+	h.PAXRecords = map[string]string{SkippedPAXRecord: strconv.FormatInt(size, 10)}
+	return w.tw.WriteHeader(h)
+}
+
+func (w *tarEntryWriter) Close() error {
+	return w.tw.Close()
+}
+
+type zipEntryWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipEntryWriter) header(name string, method uint16, mode os.FileMode, attrs coreunix.FileAttrs) *zip.FileHeader {
+	fh := &zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: entryTime(attrs, zipEpoch),
+	}
+	fh.SetMode(mode)
+	return fh
+}
+
+func (w *zipEntryWriter) dir(p string, attrs coreunix.FileAttrs) error {
+	mode := entryMode(attrs, defaultDirMode) | os.ModeDir
+	_, err := w.zw.CreateHeader(w.header(p+"/", zip.Store, mode, attrs))
+	return err
+}
+
+func (w *zipEntryWriter) file(p string, size int64, attrs coreunix.FileAttrs, r io.Reader) error {
+	fw, err := w.zw.CreateHeader(w.header(p, zip.Deflate, entryMode(attrs, defaultFileMode), attrs))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+func (w *zipEntryWriter) symlink(p, target string, attrs coreunix.FileAttrs) error {
+	mode := entryMode(attrs, os.ModePerm) | os.ModeSymlink
+	fw, err := w.zw.CreateHeader(w.header(p, zip.Store, mode, attrs))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(fw, target)
+	return err
+}
+
+func (w *zipEntryWriter) skipped(p string, size int64, attrs coreunix.FileAttrs) error {
+	return fmt.Errorf("files can only be skipped in tar archives")
+}
+
+func (w *zipEntryWriter) Close() error {
+	return w.zw.Close()
+}
@@ -0,0 +1,389 @@
+// Package archive imports tar and zip archives as UnixFS trees and exports
+// UnixFS trees as archives, streaming the content of the files.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"strings"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	balanced "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/importer/balanced"
+	ihelper "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/importer/helpers"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	mfs "gx/ipfs/QmRkrpnhZqDxTxwGCsDbuZMr7uCFZHH6SGfrcjgEQwxF3t/go-mfs"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	chunker "gx/ipfs/QmdSeG9s4EQ9TGruJJS9Us38TQDZtMmFGwzTYUDVqNTURm/go-ipfs-chunker"
+)
+
+var log = logging.Logger("archive")
+
+// Format is an archive format.
+type Format string
+
+const (
+	// Tar is the tar format. Gzip compressed tar archives are imported too.
+	Tar Format = "tar"
+	// Zip is the zip format.
+	Zip Format = "zip"
+)
+
+// maxSymlinkSize bounds the targets of the symlinks read from zip archives,
+// where they are stored as the content of the entry.
+const maxSymlinkSize = 4096
+
+// ParseFormat returns the format named s. The empty string returns the
+// empty format, detected from the archive.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "", Tar, Zip:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown archive format %q, must be %q or %q", s, Tar, Zip)
+	}
+}
+
+// ImportOptions are the options of Import.
+type ImportOptions struct {
+	// Format is the format of the archive, detected from its first bytes
+	// when empty.
+	Format Format
+
+	// PreserveMode and PreserveMtime store the permissions and the
+	// modification times of the files of the archive in their nodes, as
+	// 'ipfs add --preserve-mode --preserve-mtime' does.
+	PreserveMode  bool
+	PreserveMtime bool
+
+	// RawLeaves stores the data of the files in raw leaves.
+	RawLeaves bool
+
+	// CidBuilder builds the CIDs of the nodes, CIDv0 when nil.
+	CidBuilder cid.Builder
+}
+
+// ImportResult is the outcome of Import.
+type ImportResult struct {
+	// Root is the directory holding the entries of the archive.
+	Root ipld.Node
+	// Entries is the number of entries imported.
+	Entries int
+	// Skipped is the number of entries of types UnixFS can't represent,
+	// like devices and fifos, which were skipped.
+	Skipped int
+}
+
+// Import reads an archive from r and adds its entries to ds as a UnixFS
+// tree, keeping their paths. The files are chunked as they are read, so
+// that a tar archive is never held in memory. A zip archive, which has its
+// index at its end, is first copied to a temporary file.
+//
+// The paths are confined to the root: the leading '/' and the '..' escaping
+// it are dropped. A later entry replaces an earlier one of the same path, as
+// when the archive is extracted.
+func Import(ctx context.Context, r io.Reader, ds ipld.DAGService, opts ImportOptions) (*ImportResult, error) {
+	br := bufio.NewReader(r)
+	format, compressed, err := detectFormat(br)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Format != "" {
+		format = opts.Format
+	}
+	if format == "" {
+		return nil, fmt.Errorf("unknown archive format, set it with --format")
+	}
+
+	rootNd := ft.EmptyDirNode()
+	rootNd.SetCidBuilder(opts.CidBuilder)
+	if err := ds.Add(ctx, rootNd); err != nil {
+		return nil, err
+	}
+	root, err := mfs.NewRoot(ctx, ds, rootNd, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	im := &importer{ctx: ctx, ds: ds, opts: opts, root: root}
+	switch format {
+	case Tar:
+		var tr io.Reader = br
+		if compressed {
+			gz, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			defer gz.Close()
+			tr = gz
+		}
+		err = im.importTar(tar.NewReader(tr))
+	case Zip:
+		err = im.importZip(br)
+	default:
+		err = fmt.Errorf("unknown archive format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := root.Flush(); err != nil {
+		return nil, err
+	}
+	nd, err := root.GetDirectory().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	im.res.Root = nd
+	return &im.res, nil
+}
+
+// detectFormat returns the format of the archive read by br from its first
+// bytes, and whether it's compressed with gzip. The format is empty when
+// unknown.
+func detectFormat(br *bufio.Reader) (Format, bool, error) {
+	head, err := br.Peek(262)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", false, err
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return Zip, false, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return Tar, true, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return Tar, false, nil
+	}
+	return "", false, nil
+}
+
+type importer struct {
+	ctx  context.Context
+	ds   ipld.DAGService
+	opts ImportOptions
+	root *mfs.Root
+	res  ImportResult
+}
+
+func (im *importer) importTar(tr *tar.Reader) error {
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		p := cleanPath(h.Name)
+		if p == "" {
+			continue
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			_, err = im.dir(p)
+		case tar.TypeReg, tar.TypeRegA:
+			err = im.addFile(p, tr, im.attrs(h.FileInfo()))
+		case tar.TypeSymlink:
+			err = im.addSymlink(p, h.Linkname)
+		case tar.TypeLink:
+			err = im.addHardlink(p, cleanPath(h.Linkname))
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			log.Warningf("skipping %s, of unsupported tar type %q", h.Name, h.Typeflag)
+			im.res.Skipped++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", h.Name, err)
+		}
+		im.res.Entries++
+	}
+}
+
+func (im *importer) importZip(r io.Reader) error {
+	tmp, err := ioutil.TempFile("", "ipfs-archive-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		p := cleanPath(f.Name)
+		if p == "" {
+			continue
+		}
+
+		fi := f.FileInfo()
+		switch {
+		case fi.IsDir():
+			_, err = im.dir(p)
+		case fi.Mode()&os.ModeSymlink != 0:
+			err = im.addZipSymlink(p, f)
+		case fi.Mode().IsRegular():
+			err = im.addZipFile(p, f)
+		default:
+			log.Warningf("skipping %s, of unsupported mode %s", f.Name, fi.Mode())
+			im.res.Skipped++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", f.Name, err)
+		}
+		im.res.Entries++
+	}
+	return nil
+}
+
+func (im *importer) addZipFile(p string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return im.addFile(p, rc, im.attrs(f.FileInfo()))
+}
+
+func (im *importer) addZipSymlink(p string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	target, err := ioutil.ReadAll(io.LimitReader(rc, maxSymlinkSize+1))
+	if err != nil {
+		return err
+	}
+	if len(target) > maxSymlinkSize {
+		return fmt.Errorf("symlink target longer than %d bytes", maxSymlinkSize)
+	}
+	return im.addSymlink(p, string(target))
+}
+
+// attrs returns the attributes of the entry to preserve.
+func (im *importer) attrs(fi os.FileInfo) coreunix.FileAttrs {
+	return coreunix.FileAttrsFromInfo(fi, im.opts.PreserveMode, im.opts.PreserveMtime)
+}
+
+func (im *importer) addFile(p string, r io.Reader, attrs coreunix.FileAttrs) error {
+	params := ihelper.DagBuilderParams{
+		Dagserv:    im.ds,
+		RawLeaves:  im.opts.RawLeaves,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: im.opts.CidBuilder,
+	}
+	nd, err := balanced.Layout(params.New(chunker.DefaultSplitter(r)))
+	if err != nil {
+		return err
+	}
+
+	if !attrs.IsZero() {
+		pn, err := coreunix.SetFileAttrs(nd, attrs, im.opts.CidBuilder)
+		if err != nil {
+			return err
+		}
+		if err := im.ds.Add(im.ctx, pn); err != nil {
+			return err
+		}
+		nd = pn
+	}
+	return im.put(p, nd)
+}
+
+func (im *importer) addSymlink(p, target string) error {
+	data, err := ft.SymlinkData(target)
+	if err != nil {
+		return err
+	}
+	nd := dag.NodeWithData(data)
+	nd.SetCidBuilder(im.opts.CidBuilder)
+	if err := im.ds.Add(im.ctx, nd); err != nil {
+		return err
+	}
+	return im.put(p, nd)
+}
+
+func (im *importer) addHardlink(p, target string) error {
+	fsn, err := mfs.Lookup(im.root, "/"+target)
+	if err != nil {
+		return fmt.Errorf("hard link to %s: %s", target, err)
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return err
+	}
+	return im.put(p, nd)
+}
+
+// put links nd at p, replacing the node there.
+func (im *importer) put(p string, nd ipld.Node) error {
+	dir, name := gopath.Split(p)
+	parent, err := im.dir(dir)
+	if err != nil {
+		return err
+	}
+	if _, err := parent.Child(name); err == nil {
+		if err := parent.Unlink(name); err != nil {
+			return err
+		}
+	}
+	return parent.AddChild(name, nd)
+}
+
+// dir returns the directory at p, creating it and its parents as needed.
+func (im *importer) dir(p string) (*mfs.Directory, error) {
+	cur := im.root.GetDirectory()
+	for _, name := range strings.Split(p, "/") {
+		if name == "" {
+			continue
+		}
+		child, err := cur.Child(name)
+		if err == os.ErrNotExist {
+			if cur, err = cur.Mkdir(name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d, ok := child.(*mfs.Directory)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a directory", name)
+		}
+		cur = d
+	}
+	return cur, nil
+}
+
+// cleanPath returns the path of an entry relative to the root of the
+// archive, empty for the root itself.
+func cleanPath(name string) string {
+	return strings.TrimPrefix(gopath.Clean("/"+name), "/")
+}
@@ -0,0 +1,306 @@
+// Package autonat determines whether the node is reachable from the internet
+// by asking other peers to dial it back, and answers the same requests for
+// other peers.
+//
+// It speaks libp2p's AutoNAT protocol: the client sends its addresses in a
+// DIAL message and the server replies with a DIAL_RESPONSE telling whether
+// it could connect to one of them.
+package autonat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/autonat/pb"
+	p2putil "github.com/ipfs/go-ipfs/internal/p2putil"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+var log = logging.Logger("autonat")
+
+// ProtocolID is the AutoNAT protocol.
+const ProtocolID = protocol.ID("/libp2p/autonat/1.0.0")
+
+// Reachability is the NAT status of the node.
+type Reachability string
+
+const (
+	ReachabilityUnknown Reachability = "unknown"
+	ReachabilityPublic  Reachability = "public"
+	ReachabilityPrivate Reachability = "private"
+)
+
+// Probe parameters. Variables so tests can speed them up.
+var (
+	// initialDelay leaves time to connect to peers before the first probe.
+	initialDelay = 15 * time.Second
+	// retryInterval is the delay between probes while the status is
+	// uncertain.
+	retryInterval = time.Minute
+	// refreshInterval is the delay between probes once the status is
+	// confirmed.
+	refreshInterval = 15 * time.Minute
+	// requestTimeout bounds a single request, including the dial back.
+	requestTimeout = 30 * time.Second
+)
+
+const (
+	// maxConfidence is the number of concurring answers after which the
+	// status is considered confirmed.
+	maxConfidence = 3
+	// peersPerProbe is the number of peers asked in a single probe.
+	peersPerProbe = 3
+)
+
+// ProbeStats counts the answers to the reachability probes of the node.
+type ProbeStats struct {
+	// Requests is the number of peers asked to dial back.
+	Requests uint64
+	// Successes is the number of successful dial backs.
+	Successes uint64
+	// DialErrors is the number of peers that couldn't dial back.
+	DialErrors uint64
+	// Errors is the number of requests that were refused or failed.
+	Errors uint64
+}
+
+// Status is the reachability of the node.
+type Status struct {
+	Reachability Reachability
+	// PublicAddr is the address the last successful dial back reached.
+	PublicAddr ma.Multiaddr
+	// LastProbe is the time of the last probe, zero before the first one.
+	LastProbe time.Time
+	// Confidence is the number of concurring answers, up to 3.
+	Confidence int
+	Stats      ProbeStats
+}
+
+// AutoNAT periodically asks peers to dial the node back to determine its
+// reachability.
+type AutoNAT struct {
+	host   host.Host
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lk         sync.Mutex
+	status     Reachability
+	publicAddr ma.Multiaddr
+	confidence int
+	lastProbe  time.Time
+
+	requests   uint64
+	successes  uint64
+	dialErrors uint64
+	reqErrors  uint64
+}
+
+// New starts probing the reachability of h.
+func New(h host.Host) *AutoNAT {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &AutoNAT{
+		host:   h,
+		ctx:    ctx,
+		cancel: cancel,
+		status: ReachabilityUnknown,
+	}
+	go a.run()
+	return a
+}
+
+// Close stops probing.
+func (a *AutoNAT) Close() error {
+	a.cancel()
+	return nil
+}
+
+// Status returns the current reachability.
+func (a *AutoNAT) Status() Status {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	return Status{
+		Reachability: a.status,
+		PublicAddr:   a.publicAddr,
+		LastProbe:    a.lastProbe,
+		Confidence:   a.confidence,
+		Stats: ProbeStats{
+			Requests:   atomic.LoadUint64(&a.requests),
+			Successes:  atomic.LoadUint64(&a.successes),
+			DialErrors: atomic.LoadUint64(&a.dialErrors),
+			Errors:     atomic.LoadUint64(&a.reqErrors),
+		},
+	}
+}
+
+func (a *AutoNAT) run() {
+	delay := initialDelay
+	for {
+		select {
+		case <-time.After(delay):
+		case <-a.ctx.Done():
+			return
+		}
+
+		a.probe()
+
+		a.lk.Lock()
+		if a.confidence >= maxConfidence {
+			delay = refreshInterval
+		} else {
+			delay = retryInterval
+		}
+		a.lk.Unlock()
+	}
+}
+
+// probe asks connected AutoNAT servers to dial back until one gives a
+// definite answer.
+func (a *AutoNAT) probe() {
+	addrs := a.dialableAddrs()
+	if len(addrs) == 0 {
+		return
+	}
+
+	peers := a.servers()
+	if len(peers) > peersPerProbe {
+		peers = peers[:peersPerProbe]
+	}
+
+	for _, p := range peers {
+		atomic.AddUint64(&a.requests, 1)
+		addr, err := a.request(p, addrs)
+		switch err {
+		case nil:
+			atomic.AddUint64(&a.successes, 1)
+			a.recordPublic(addr)
+			return
+		case errDialBack:
+			atomic.AddUint64(&a.dialErrors, 1)
+			a.recordPrivate()
+			return
+		default:
+			log.Debugf("autonat request to %s failed: %s", p.Pretty(), err)
+			atomic.AddUint64(&a.reqErrors, 1)
+		}
+	}
+}
+
+func (a *AutoNAT) recordPublic(addr ma.Multiaddr) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	a.lastProbe = time.Now()
+	a.publicAddr = addr
+	if a.status != ReachabilityPublic {
+		log.Infof("node is publicly reachable at %s", addr)
+		a.status = ReachabilityPublic
+		a.confidence = 0
+	}
+	if a.confidence < maxConfidence {
+		a.confidence++
+	}
+}
+
+func (a *AutoNAT) recordPrivate() {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	a.lastProbe = time.Now()
+	// a single failure doesn't outweigh confirmed reachability.
+	if a.status == ReachabilityPublic && a.confidence > 1 {
+		a.confidence--
+		return
+	}
+	if a.status != ReachabilityPrivate {
+		log.Info("node is not publicly reachable")
+		a.status = ReachabilityPrivate
+		a.publicAddr = nil
+		a.confidence = 0
+	}
+	if a.confidence < maxConfidence {
+		a.confidence++
+	}
+}
+
+// servers returns the connected peers speaking AutoNAT, in random order.
+func (a *AutoNAT) servers() []peer.ID {
+	var out []peer.ID
+	for _, p := range a.host.Network().Peers() {
+		protos, err := a.host.Peerstore().SupportsProtocols(p, string(ProtocolID))
+		if err == nil && len(protos) > 0 {
+			out = append(out, p)
+		}
+	}
+	rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// dialableAddrs returns the addresses worth asking a dial back for.
+func (a *AutoNAT) dialableAddrs() []ma.Multiaddr {
+	var out []ma.Multiaddr
+	for _, addr := range a.host.Addrs() {
+		if !p2putil.IsRelayAddr(addr) && !manet.IsIPLoopback(addr) {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+var errDialBack = errors.New("dial back failed")
+
+// request asks p to dial back one of addrs, returning the address it reached.
+func (a *AutoNAT) request(p peer.ID, addrs []ma.Multiaddr) (ma.Multiaddr, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, requestTimeout)
+	defer cancel()
+
+	s, err := a.host.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(requestTimeout))
+
+	info := &pb.Message_PeerInfo{Id: []byte(a.host.ID())}
+	for _, addr := range addrs {
+		info.Addrs = append(info.Addrs, addr.Bytes())
+	}
+	err = p2putil.WriteMsg(s, &pb.Message{
+		Type: pb.Message_DIAL.Enum(),
+		Dial: &pb.Message_Dial{Peer: info},
+	})
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	var resp pb.Message
+	if err := p2putil.ReadMsg(bufio.NewReader(s), &resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if resp.GetType() != pb.Message_DIAL_RESPONSE || resp.DialResponse == nil {
+		return nil, errors.New("unexpected message")
+	}
+
+	dr := resp.DialResponse
+	switch dr.GetStatus() {
+	case pb.Message_OK:
+		return ma.NewMultiaddrBytes(dr.Addr)
+	case pb.Message_E_DIAL_ERROR:
+		return nil, errDialBack
+	default:
+		return nil, errors.New(dr.GetStatus().String() + ": " + dr.GetStatusText())
+	}
+}
@@ -0,0 +1,58 @@
+package autonat
+
+import (
+	"testing"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+func TestReachabilityConfidence(t *testing.T) {
+	a := &AutoNAT{status: ReachabilityUnknown}
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+
+	a.recordPrivate()
+	if st := a.Status(); st.Reachability != ReachabilityPrivate || st.Confidence != 1 {
+		t.Fatalf("expected private with confidence 1, got %+v", st)
+	}
+
+	for i := 0; i < 5; i++ {
+		a.recordPublic(addr)
+	}
+	if st := a.Status(); st.Reachability != ReachabilityPublic || st.Confidence != maxConfidence {
+		t.Fatalf("expected confirmed public, got %+v", st)
+	}
+
+	// a confirmed status survives isolated failures
+	a.recordPrivate()
+	a.recordPrivate()
+	if st := a.Status(); st.Reachability != ReachabilityPublic {
+		t.Fatalf("expected public, got %+v", st)
+	}
+	a.recordPrivate()
+	if st := a.Status(); st.Reachability != ReachabilityPrivate || st.PublicAddr != nil {
+		t.Fatalf("expected private, got %+v", st)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	s := &Service{
+		globalLimit: 3,
+		peerLimit:   2,
+		reqs:        make(map[peer.ID]int),
+	}
+
+	p1, p2 := peer.ID("peer1"), peer.ID("peer2")
+	if !s.allow(p1) || !s.allow(p1) {
+		t.Fatal("expected the first requests to be allowed")
+	}
+	if s.allow(p1) {
+		t.Fatal("expected the peer limit to apply")
+	}
+	if !s.allow(p2) {
+		t.Fatal("expected another peer to be allowed")
+	}
+	if s.allow(p2) {
+		t.Fatal("expected the global limit to apply")
+	}
+}
@@ -0,0 +1,1199 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: autonat/pb/autonat.proto
+
+package pb
+
+import (
+	fmt "fmt"
+	proto "gx/ipfs/QmdxUuburamoF6zF9qjeQC4WYcWGbWuRmdLacMEsW8ioD8/gogo-protobuf/proto"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
+
+type Message_MessageType int32
+
+const (
+	Message_DIAL          Message_MessageType = 0
+	Message_DIAL_RESPONSE Message_MessageType = 1
+)
+
+var Message_MessageType_name = map[int32]string{
+	0: "DIAL",
+	1: "DIAL_RESPONSE",
+}
+
+var Message_MessageType_value = map[string]int32{
+	"DIAL":          0,
+	"DIAL_RESPONSE": 1,
+}
+
+func (x Message_MessageType) Enum() *Message_MessageType {
+	p := new(Message_MessageType)
+	*p = x
+	return p
+}
+
+func (x Message_MessageType) String() string {
+	return proto.EnumName(Message_MessageType_name, int32(x))
+}
+
+func (x *Message_MessageType) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Message_MessageType_value, data, "Message_MessageType")
+	if err != nil {
+		return err
+	}
+	*x = Message_MessageType(value)
+	return nil
+}
+
+func (Message_MessageType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_d124dd864ecde1b5, []int{0, 0}
+}
+
+type Message_ResponseStatus int32
+
+const (
+	Message_OK               Message_ResponseStatus = 0
+	Message_E_DIAL_ERROR     Message_ResponseStatus = 100
+	Message_E_DIAL_REFUSED   Message_ResponseStatus = 101
+	Message_E_BAD_REQUEST    Message_ResponseStatus = 200
+	Message_E_INTERNAL_ERROR Message_ResponseStatus = 300
+)
+
+var Message_ResponseStatus_name = map[int32]string{
+	0:   "OK",
+	100: "E_DIAL_ERROR",
+	101: "E_DIAL_REFUSED",
+	200: "E_BAD_REQUEST",
+	300: "E_INTERNAL_ERROR",
+}
+
+var Message_ResponseStatus_value = map[string]int32{
+	"OK":               0,
+	"E_DIAL_ERROR":     100,
+	"E_DIAL_REFUSED":   101,
+	"E_BAD_REQUEST":    200,
+	"E_INTERNAL_ERROR": 300,
+}
+
+func (x Message_ResponseStatus) Enum() *Message_ResponseStatus {
+	p := new(Message_ResponseStatus)
+	*p = x
+	return p
+}
+
+func (x Message_ResponseStatus) String() string {
+	return proto.EnumName(Message_ResponseStatus_name, int32(x))
+}
+
+func (x *Message_ResponseStatus) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Message_ResponseStatus_value, data, "Message_ResponseStatus")
+	if err != nil {
+		return err
+	}
+	*x = Message_ResponseStatus(value)
+	return nil
+}
+
+func (Message_ResponseStatus) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_d124dd864ecde1b5, []int{0, 1}
+}
+
+type Message struct {
+	Type                 *Message_MessageType  `protobuf:"varint,1,opt,name=type,enum=autonat.pb.Message_MessageType" json:"type,omitempty"`
+	Dial                 *Message_Dial         `protobuf:"bytes,2,opt,name=dial" json:"dial,omitempty"`
+	DialResponse         *Message_DialResponse `protobuf:"bytes,3,opt,name=dialResponse" json:"dialResponse,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+func (*Message) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d124dd864ecde1b5, []int{0}
+}
+func (m *Message) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Message) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Message.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Message) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message.Merge(m, src)
+}
+func (m *Message) XXX_Size() int {
+	return m.Size()
+}
+func (m *Message) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message proto.InternalMessageInfo
+
+func (m *Message) GetType() Message_MessageType {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Message_DIAL
+}
+
+func (m *Message) GetDial() *Message_Dial {
+	if m != nil {
+		return m.Dial
+	}
+	return nil
+}
+
+func (m *Message) GetDialResponse() *Message_DialResponse {
+	if m != nil {
+		return m.DialResponse
+	}
+	return nil
+}
+
+type Message_PeerInfo struct {
+	Id                   []byte   `protobuf:"bytes,1,opt,name=id" json:"id"`
+	Addrs                [][]byte `protobuf:"bytes,2,rep,name=addrs" json:"addrs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Message_PeerInfo) Reset()         { *m = Message_PeerInfo{} }
+func (m *Message_PeerInfo) String() string { return proto.CompactTextString(m) }
+func (*Message_PeerInfo) ProtoMessage()    {}
+func (*Message_PeerInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d124dd864ecde1b5, []int{0, 0}
+}
+func (m *Message_PeerInfo) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Message_PeerInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Message_PeerInfo.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Message_PeerInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_PeerInfo.Merge(m, src)
+}
+func (m *Message_PeerInfo) XXX_Size() int {
+	return m.Size()
+}
+func (m *Message_PeerInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_PeerInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_PeerInfo proto.InternalMessageInfo
+
+func (m *Message_PeerInfo) GetId() []byte {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *Message_PeerInfo) GetAddrs() [][]byte {
+	if m != nil {
+		return m.Addrs
+	}
+	return nil
+}
+
+type Message_Dial struct {
+	Peer                 *Message_PeerInfo `protobuf:"bytes,1,opt,name=peer" json:"peer,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *Message_Dial) Reset()         { *m = Message_Dial{} }
+func (m *Message_Dial) String() string { return proto.CompactTextString(m) }
+func (*Message_Dial) ProtoMessage()    {}
+func (*Message_Dial) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d124dd864ecde1b5, []int{0, 1}
+}
+func (m *Message_Dial) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Message_Dial) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Message_Dial.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Message_Dial) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_Dial.Merge(m, src)
+}
+func (m *Message_Dial) XXX_Size() int {
+	return m.Size()
+}
+func (m *Message_Dial) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_Dial.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_Dial proto.InternalMessageInfo
+
+func (m *Message_Dial) GetPeer() *Message_PeerInfo {
+	if m != nil {
+		return m.Peer
+	}
+	return nil
+}
+
+type Message_DialResponse struct {
+	Status               *Message_ResponseStatus `protobuf:"varint,1,opt,name=status,enum=autonat.pb.Message_ResponseStatus" json:"status,omitempty"`
+	StatusText           *string                 `protobuf:"bytes,2,opt,name=statusText" json:"statusText,omitempty"`
+	Addr                 []byte                  `protobuf:"bytes,3,opt,name=addr" json:"addr"`
+	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
+	XXX_sizecache        int32                   `json:"-"`
+}
+
+func (m *Message_DialResponse) Reset()         { *m = Message_DialResponse{} }
+func (m *Message_DialResponse) String() string { return proto.CompactTextString(m) }
+func (*Message_DialResponse) ProtoMessage()    {}
+func (*Message_DialResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d124dd864ecde1b5, []int{0, 2}
+}
+func (m *Message_DialResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Message_DialResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Message_DialResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Message_DialResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_DialResponse.Merge(m, src)
+}
+func (m *Message_DialResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *Message_DialResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_DialResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_DialResponse proto.InternalMessageInfo
+
+func (m *Message_DialResponse) GetStatus() Message_ResponseStatus {
+	if m != nil && m.Status != nil {
+		return *m.Status
+	}
+	return Message_OK
+}
+
+func (m *Message_DialResponse) GetStatusText() string {
+	if m != nil && m.StatusText != nil {
+		return *m.StatusText
+	}
+	return ""
+}
+
+func (m *Message_DialResponse) GetAddr() []byte {
+	if m != nil {
+		return m.Addr
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("autonat.pb.Message_MessageType", Message_MessageType_name, Message_MessageType_value)
+	proto.RegisterEnum("autonat.pb.Message_ResponseStatus", Message_ResponseStatus_name, Message_ResponseStatus_value)
+	proto.RegisterType((*Message)(nil), "autonat.pb.Message")
+	proto.RegisterType((*Message_PeerInfo)(nil), "autonat.pb.Message.PeerInfo")
+	proto.RegisterType((*Message_Dial)(nil), "autonat.pb.Message.Dial")
+	proto.RegisterType((*Message_DialResponse)(nil), "autonat.pb.Message.DialResponse")
+}
+
+func init() { proto.RegisterFile("autonat/pb/autonat.proto", fileDescriptor_d124dd864ecde1b5) }
+
+var fileDescriptor_d124dd864ecde1b5 = []byte{
+	// 400 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x91, 0x41, 0x8b, 0xd3, 0x40,
+	0x14, 0xc7, 0x33, 0xe9, 0xb8, 0xae, 0xaf, 0xd9, 0x32, 0x3e, 0x2a, 0x0c, 0x45, 0xb2, 0x21, 0x78,
+	0x28, 0x22, 0x5d, 0xe9, 0x41, 0xf4, 0xe6, 0x96, 0x8c, 0x50, 0xd4, 0x76, 0x9d, 0x64, 0x2f, 0x5e,
+	0x42, 0x4a, 0x46, 0x09, 0x2c, 0x4d, 0x48, 0xb2, 0x60, 0xbf, 0x45, 0x3f, 0x88, 0x1f, 0xa4, 0x47,
+	0x0f, 0x9e, 0x45, 0xea, 0x17, 0x91, 0x4c, 0xa7, 0x35, 0x85, 0x7a, 0x9a, 0x37, 0x8f, 0xdf, 0xef,
+	0xe5, 0x9f, 0x37, 0xc0, 0x93, 0xfb, 0x3a, 0x5f, 0x26, 0xf5, 0x55, 0xb1, 0xb8, 0x32, 0xe5, 0xa8,
+	0x28, 0xf3, 0x3a, 0x47, 0x38, 0x5c, 0x17, 0xfe, 0x4f, 0x0a, 0x0f, 0x3f, 0xaa, 0xaa, 0x4a, 0xbe,
+	0x2a, 0x7c, 0x03, 0xb4, 0x5e, 0x15, 0x8a, 0x13, 0x8f, 0x0c, 0x7b, 0xe3, 0xcb, 0xd1, 0x3f, 0x6c,
+	0x64, 0x90, 0xfd, 0x19, 0xad, 0x0a, 0x35, 0xa1, 0x9b, 0x5f, 0x97, 0x44, 0x6a, 0x05, 0x5f, 0x00,
+	0x4d, 0xb3, 0xe4, 0x8e, 0xdb, 0x1e, 0x19, 0x76, 0xc7, 0xfc, 0x94, 0x1a, 0x64, 0xc9, 0x9d, 0xd4,
+	0x14, 0x06, 0xe0, 0x34, 0xa7, 0x54, 0x55, 0x91, 0x2f, 0x2b, 0xc5, 0x3b, 0xda, 0xf2, 0xfe, 0x6b,
+	0x19, 0x4e, 0x1e, 0x59, 0x83, 0x57, 0x70, 0x7e, 0xa3, 0x54, 0x39, 0x5d, 0x7e, 0xc9, 0xb1, 0x0f,
+	0x76, 0x96, 0xea, 0xe0, 0x8e, 0xce, 0x65, 0x49, 0x3b, 0x4b, 0xb1, 0x0f, 0x0f, 0x92, 0x34, 0x2d,
+	0x2b, 0x6e, 0x7b, 0x9d, 0xa1, 0x23, 0x77, 0x97, 0xc1, 0x6b, 0xa0, 0xcd, 0x54, 0x7c, 0x09, 0xb4,
+	0x50, 0xaa, 0xd4, 0x56, 0x77, 0xfc, 0xf4, 0xd4, 0xd7, 0xf7, 0xf3, 0xa5, 0x26, 0x07, 0x6b, 0x02,
+	0x4e, 0x3b, 0x10, 0xbe, 0x85, 0xb3, 0xaa, 0x4e, 0xea, 0xfb, 0xca, 0xec, 0xcc, 0x3f, 0x35, 0x64,
+	0x4f, 0x87, 0x9a, 0x34, 0x6b, 0x33, 0x1e, 0x3e, 0x03, 0xd8, 0x55, 0x91, 0xfa, 0x56, 0xeb, 0xf5,
+	0x3d, 0x32, 0x44, 0xab, 0x8f, 0x1c, 0x68, 0x93, 0x9d, 0x77, 0x5a, 0x3f, 0xa8, 0x3b, 0xfe, 0x73,
+	0xe8, 0xb6, 0xde, 0x04, 0xcf, 0x81, 0x06, 0xd3, 0xeb, 0x0f, 0xcc, 0xc2, 0xc7, 0x70, 0xd1, 0x54,
+	0xb1, 0x14, 0xe1, 0xcd, 0x7c, 0x16, 0x0a, 0x46, 0xfc, 0x0c, 0x7a, 0xc7, 0x59, 0xf0, 0x0c, 0xec,
+	0xf9, 0x7b, 0x66, 0x21, 0x03, 0x47, 0xc4, 0x1a, 0x17, 0x52, 0xce, 0x25, 0x4b, 0x11, 0xa1, 0x67,
+	0x3a, 0x52, 0xbc, 0xbb, 0x0d, 0x45, 0xc0, 0x14, 0x22, 0x5c, 0x88, 0x78, 0x72, 0x1d, 0xc4, 0x52,
+	0x7c, 0xba, 0x15, 0x61, 0xc4, 0x36, 0x04, 0x9f, 0x00, 0x13, 0xf1, 0x74, 0x16, 0x09, 0x39, 0x3b,
+	0xd8, 0xdf, 0xed, 0x49, 0x7f, 0xb3, 0x75, 0xc9, 0x8f, 0xad, 0x4b, 0x7e, 0x6f, 0x5d, 0xb2, 0xfe,
+	0xe3, 0x5a, 0x9f, 0xed, 0x62, 0xf1, 0x77, 0x00, 0xa7, 0xbe, 0x10, 0xca, 0x93, 0x02, 0x00, 0x00,
+}
+
+func (m *Message) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Message) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.DialResponse != nil {
+		{
+			size, err := m.DialResponse.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintAutonat(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Dial != nil {
+		{
+			size, err := m.Dial.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintAutonat(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Type != nil {
+		i = encodeVarintAutonat(dAtA, i, uint64(*m.Type))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Message_PeerInfo) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Message_PeerInfo) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Message_PeerInfo) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Addrs) > 0 {
+		for iNdEx := len(m.Addrs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Addrs[iNdEx])
+			copy(dAtA[i:], m.Addrs[iNdEx])
+			i = encodeVarintAutonat(dAtA, i, uint64(len(m.Addrs[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if m.Id != nil {
+		i -= len(m.Id)
+		copy(dAtA[i:], m.Id)
+		i = encodeVarintAutonat(dAtA, i, uint64(len(m.Id)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Message_Dial) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Message_Dial) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Message_Dial) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Peer != nil {
+		{
+			size, err := m.Peer.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintAutonat(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Message_DialResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Message_DialResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Message_DialResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Addr != nil {
+		i -= len(m.Addr)
+		copy(dAtA[i:], m.Addr)
+		i = encodeVarintAutonat(dAtA, i, uint64(len(m.Addr)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.StatusText != nil {
+		i -= len(*m.StatusText)
+		copy(dAtA[i:], *m.StatusText)
+		i = encodeVarintAutonat(dAtA, i, uint64(len(*m.StatusText)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Status != nil {
+		i = encodeVarintAutonat(dAtA, i, uint64(*m.Status))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintAutonat(dAtA []byte, offset int, v uint64) int {
+	offset -= sovAutonat(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *Message) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Type != nil {
+		n += 1 + sovAutonat(uint64(*m.Type))
+	}
+	if m.Dial != nil {
+		l = m.Dial.Size()
+		n += 1 + l + sovAutonat(uint64(l))
+	}
+	if m.DialResponse != nil {
+		l = m.DialResponse.Size()
+		n += 1 + l + sovAutonat(uint64(l))
+	}
+	return n
+}
+
+func (m *Message_PeerInfo) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Id != nil {
+		l = len(m.Id)
+		n += 1 + l + sovAutonat(uint64(l))
+	}
+	if len(m.Addrs) > 0 {
+		for _, b := range m.Addrs {
+			l = len(b)
+			n += 1 + l + sovAutonat(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *Message_Dial) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Peer != nil {
+		l = m.Peer.Size()
+		n += 1 + l + sovAutonat(uint64(l))
+	}
+	return n
+}
+
+func (m *Message_DialResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Status != nil {
+		n += 1 + sovAutonat(uint64(*m.Status))
+	}
+	if m.StatusText != nil {
+		l = len(*m.StatusText)
+		n += 1 + l + sovAutonat(uint64(l))
+	}
+	if m.Addr != nil {
+		l = len(m.Addr)
+		n += 1 + l + sovAutonat(uint64(l))
+	}
+	return n
+}
+
+func sovAutonat(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozAutonat(x uint64) (n int) {
+	return sovAutonat(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *Message) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAutonat
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Message: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Message: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			var v Message_MessageType
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= Message_MessageType(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Type = &v
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Dial", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Dial == nil {
+				m.Dial = &Message_Dial{}
+			}
+			if err := m.Dial.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DialResponse", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.DialResponse == nil {
+				m.DialResponse = &Message_DialResponse{}
+			}
+			if err := m.DialResponse.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAutonat(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Message_PeerInfo) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAutonat
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PeerInfo: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PeerInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Id = append(m.Id[:0], dAtA[iNdEx:postIndex]...)
+			if m.Id == nil {
+				m.Id = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Addrs", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Addrs = append(m.Addrs, make([]byte, postIndex-iNdEx))
+			copy(m.Addrs[len(m.Addrs)-1], dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAutonat(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Message_Dial) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAutonat
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Dial: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Dial: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Peer", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Peer == nil {
+				m.Peer = &Message_PeerInfo{}
+			}
+			if err := m.Peer.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAutonat(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Message_DialResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAutonat
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DialResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DialResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			var v Message_ResponseStatus
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= Message_ResponseStatus(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Status = &v
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StatusText", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			s := string(dAtA[iNdEx:postIndex])
+			m.StatusText = &s
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Addr", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Addr = append(m.Addr[:0], dAtA[iNdEx:postIndex]...)
+			if m.Addr == nil {
+				m.Addr = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAutonat(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAutonat
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipAutonat(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	depth := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowAutonat
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+		case 1:
+			iNdEx += 8
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowAutonat
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthAutonat
+			}
+			iNdEx += length
+		case 3:
+			depth++
+		case 4:
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupAutonat
+			}
+			depth--
+		case 5:
+			iNdEx += 4
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthAutonat
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+var (
+	ErrInvalidLengthAutonat        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowAutonat          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupAutonat = fmt.Errorf("proto: unexpected end of group")
+)
@@ -0,0 +1,243 @@
+package autonat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/autonat/pb"
+	p2putil "github.com/ipfs/go-ipfs/internal/p2putil"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+// Defaults of the throttle settings left unset in the config.
+const (
+	DefaultGlobalLimit = 30
+	DefaultPeerLimit   = 3
+	DefaultInterval    = time.Minute
+)
+
+// dialTimeout bounds a dial back.
+var dialTimeout = 15 * time.Second
+
+// ServiceStats counts the requests answered for other peers.
+type ServiceStats struct {
+	// Successes is the number of successful dial backs.
+	Successes uint64
+	// DialErrors is the number of failed dial backs.
+	DialErrors uint64
+	// Throttled is the number of requests refused by the throttle.
+	Throttled uint64
+	// Refused is the number of invalid requests.
+	Refused uint64
+}
+
+// Service answers the AutoNAT requests of other peers.
+//
+// Dial backs are made from a separate host with its own identity, so they
+// can't reuse the connection the request came in on.
+type Service struct {
+	host   host.Host
+	dialer host.Host
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	globalLimit int
+	peerLimit   int
+
+	lk     sync.Mutex
+	global int
+	reqs   map[peer.ID]int
+
+	successes  uint64
+	dialErrors uint64
+	throttled  uint64
+	refused    uint64
+}
+
+// NewService answers AutoNAT requests made to h, dialing back from dialer.
+// The service owns dialer and closes it with Close.
+func NewService(h, dialer host.Host, cfg extconfig.AutoNATThrottle) (*Service, error) {
+	interval := DefaultInterval
+	if cfg.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("parsing AutoNAT.Throttle.Interval: %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		host:        h,
+		dialer:      dialer,
+		ctx:         ctx,
+		cancel:      cancel,
+		globalLimit: DefaultGlobalLimit,
+		peerLimit:   DefaultPeerLimit,
+		reqs:        make(map[peer.ID]int),
+	}
+	if cfg.GlobalLimit > 0 {
+		s.globalLimit = cfg.GlobalLimit
+	}
+	if cfg.PeerLimit > 0 {
+		s.peerLimit = cfg.PeerLimit
+	}
+
+	h.SetStreamHandler(ProtocolID, s.handleStream)
+	go s.resetThrottle(interval)
+	return s, nil
+}
+
+// Close stops answering requests.
+func (s *Service) Close() error {
+	s.host.RemoveStreamHandler(ProtocolID)
+	s.cancel()
+	return s.dialer.Close()
+}
+
+// Stats returns the request counters.
+func (s *Service) Stats() ServiceStats {
+	return ServiceStats{
+		Successes:  atomic.LoadUint64(&s.successes),
+		DialErrors: atomic.LoadUint64(&s.dialErrors),
+		Throttled:  atomic.LoadUint64(&s.throttled),
+		Refused:    atomic.LoadUint64(&s.refused),
+	}
+}
+
+func (s *Service) resetThrottle(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			s.lk.Lock()
+			s.global = 0
+			s.reqs = make(map[peer.ID]int)
+			s.lk.Unlock()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// allow reserves a request for p against the limits.
+func (s *Service) allow(p peer.ID) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.global >= s.globalLimit || s.reqs[p] >= s.peerLimit {
+		return false
+	}
+	s.global++
+	s.reqs[p]++
+	return true
+}
+
+func (s *Service) handleStream(str inet.Stream) {
+	defer str.Close()
+	str.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req pb.Message
+	if err := p2putil.ReadMsg(bufio.NewReader(str), &req); err != nil {
+		str.Reset()
+		return
+	}
+
+	status, text, addr := s.handleDial(str.Conn(), &req)
+	resp := &pb.Message_DialResponse{Status: status.Enum()}
+	if text != "" {
+		resp.StatusText = &text
+	}
+	if addr != nil {
+		resp.Addr = addr.Bytes()
+	}
+
+	err := p2putil.WriteMsg(str, &pb.Message{
+		Type:         pb.Message_DIAL_RESPONSE.Enum(),
+		DialResponse: resp,
+	})
+	if err != nil {
+		str.Reset()
+	}
+}
+
+func (s *Service) handleDial(c inet.Conn, req *pb.Message) (pb.Message_ResponseStatus, string, ma.Multiaddr) {
+	p := c.RemotePeer()
+
+	if req.GetType() != pb.Message_DIAL || req.Dial == nil || req.Dial.Peer == nil {
+		atomic.AddUint64(&s.refused, 1)
+		return pb.Message_E_BAD_REQUEST, "expected a dial message", nil
+	}
+	id, err := peer.IDFromBytes(req.Dial.Peer.Id)
+	if err != nil || id != p {
+		atomic.AddUint64(&s.refused, 1)
+		return pb.Message_E_BAD_REQUEST, "peer id mismatch", nil
+	}
+
+	// only dial the IP the request came from, so the service can't be
+	// used to make the node dial arbitrary hosts.
+	obsIP := p2putil.IPOf(c.RemoteMultiaddr())
+	if obsIP == "" || p2putil.IsRelayAddr(c.RemoteMultiaddr()) {
+		atomic.AddUint64(&s.refused, 1)
+		return pb.Message_E_DIAL_REFUSED, "request must come from a direct IP connection", nil
+	}
+
+	var addrs []ma.Multiaddr
+	for _, b := range req.Dial.Peer.Addrs {
+		a, err := ma.NewMultiaddrBytes(b)
+		if err != nil || p2putil.IsRelayAddr(a) || manet.IsIPLoopback(a) {
+			continue
+		}
+		if p2putil.IPOf(a) == obsIP {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		atomic.AddUint64(&s.refused, 1)
+		return pb.Message_E_DIAL_REFUSED, "no dialable addresses", nil
+	}
+
+	if !s.allow(p) {
+		atomic.AddUint64(&s.throttled, 1)
+		return pb.Message_E_DIAL_REFUSED, "too many requests", nil
+	}
+
+	addr, err := s.dialBack(p, addrs)
+	if err != nil {
+		atomic.AddUint64(&s.dialErrors, 1)
+		return pb.Message_E_DIAL_ERROR, err.Error(), nil
+	}
+	atomic.AddUint64(&s.successes, 1)
+	return pb.Message_OK, "", addr
+}
+
+// dialBack connects to p on one of addrs and returns the address reached.
+func (s *Service) dialBack(p peer.ID, addrs []ma.Multiaddr) (ma.Multiaddr, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, dialTimeout)
+	defer cancel()
+
+	defer s.dialer.Peerstore().ClearAddrs(p)
+	defer s.dialer.Network().ClosePeer(p)
+
+	if err := s.dialer.Connect(ctx, pstore.PeerInfo{ID: p, Addrs: addrs}); err != nil {
+		return nil, err
+	}
+	conns := s.dialer.Network().ConnsToPeer(p)
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("connection to %s closed", p.Pretty())
+	}
+	return conns[0].RemoteMultiaddr(), nil
+}
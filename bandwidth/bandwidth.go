@@ -0,0 +1,386 @@
+// Package bandwidth keeps the history of the bandwidth used by the node, for
+// the whole node, for each protocol and for each peer.
+//
+// The Reporter wraps the bandwidth counter of libp2p: it remembers the
+// protocols and peers it saw, samples their bandwidth periodically into ring
+// buffers, and persists the total number of bytes transferred in the
+// datastore, so that the lifetime totals survive restarts.
+package bandwidth
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	metrics "gx/ipfs/QmdhwKw53CTV8EJSAsR1bpmMT5kXiWBgeAyv1EXeeDiXqR/go-libp2p-metrics"
+)
+
+var log = logging.Logger("bandwidth")
+
+// Defaults of the settings left unset in the config. The default history
+// covers the last hour.
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultLength   = 360
+)
+
+// flushInterval is the interval at which the lifetime totals are written to
+// the datastore.
+const flushInterval = time.Minute
+
+// totalsKey is the datastore key of the lifetime totals.
+var totalsKey = ds.NewKey("/local/bandwidth")
+
+// Sample is the bandwidth of the node, a protocol or a peer at a time.
+type Sample struct {
+	Time time.Time
+	metrics.Stats
+}
+
+// Totals are numbers of bytes transferred.
+type Totals struct {
+	In  int64
+	Out int64
+}
+
+func (t Totals) add(st metrics.Stats) Totals {
+	return Totals{In: t.In + st.TotalIn, Out: t.Out + st.TotalOut}
+}
+
+// lifetime is what is persisted of the totals.
+type lifetime struct {
+	Since     time.Time
+	Total     Totals
+	Protocols map[protocol.ID]Totals `json:",omitempty"`
+}
+
+// series is a ring buffer of samples.
+type series struct {
+	samples []Sample
+	next    int
+	full    bool
+
+	// lastActive is the time of the last sample with a non zero rate.
+	lastActive time.Time
+}
+
+func newSeries(length int) *series {
+	return &series{samples: make([]Sample, length)}
+}
+
+func (s *series) add(smp Sample) {
+	s.samples[s.next] = smp
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+	if smp.RateIn > 0 || smp.RateOut > 0 {
+		s.lastActive = smp.Time
+	}
+}
+
+// list returns the samples taken after since, the oldest first.
+func (s *series) list(since time.Time) []Sample {
+	var ordered []Sample
+	if s.full {
+		ordered = append(ordered, s.samples[s.next:]...)
+	}
+	ordered = append(ordered, s.samples[:s.next]...)
+
+	out := make([]Sample, 0, len(ordered))
+	for _, smp := range ordered {
+		if smp.Time.After(since) {
+			out = append(out, smp)
+		}
+	}
+	return out
+}
+
+// Reporter is a metrics.Reporter keeping the history of the bandwidth.
+type Reporter struct {
+	metrics.Reporter
+
+	// the protocols and peers seen, written on every message so kept
+	// apart from lk
+	protocols sync.Map // protocol.ID -> struct{}
+	peers     sync.Map // peer.ID -> struct{}
+
+	ds       ds.Datastore
+	interval time.Duration
+	length   int
+
+	lk        sync.Mutex
+	base      lifetime
+	total     *series
+	protoHist map[protocol.ID]*series
+	peerHist  map[peer.ID]*series
+
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// NewReporter returns a Reporter counting the bandwidth with r, and adding
+// the totals persisted in d to its lifetime totals.
+func NewReporter(r metrics.Reporter, d ds.Datastore, cfg *extconfig.BandwidthHistory) (*Reporter, error) {
+	rep := &Reporter{
+		Reporter:  r,
+		ds:        d,
+		interval:  DefaultInterval,
+		length:    DefaultLength,
+		protoHist: make(map[protocol.ID]*series),
+		peerHist:  make(map[peer.ID]*series),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if cfg.Interval != "" {
+		var err error
+		rep.interval, err = time.ParseDuration(cfg.Interval)
+		if err != nil || rep.interval <= 0 {
+			return nil, fmt.Errorf("invalid Swarm.BandwidthHistory.Interval %q", cfg.Interval)
+		}
+	}
+	if cfg.Length > 0 {
+		rep.length = cfg.Length
+	}
+	rep.total = newSeries(rep.length)
+
+	if err := rep.load(); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+func (r *Reporter) load() error {
+	r.base = lifetime{Since: time.Now(), Protocols: make(map[protocol.ID]Totals)}
+
+	data, err := r.ds.Get(totalsKey)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &r.base); err != nil {
+		return fmt.Errorf("reading the bandwidth totals: %s", err)
+	}
+	if r.base.Protocols == nil {
+		r.base.Protocols = make(map[protocol.ID]Totals)
+	}
+	return nil
+}
+
+// LogSentMessageStream implements metrics.Reporter.
+func (r *Reporter) LogSentMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	r.seen(proto, p)
+	r.Reporter.LogSentMessageStream(size, proto, p)
+}
+
+// LogRecvMessageStream implements metrics.Reporter.
+func (r *Reporter) LogRecvMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	r.seen(proto, p)
+	r.Reporter.LogRecvMessageStream(size, proto, p)
+}
+
+func (r *Reporter) seen(proto protocol.ID, p peer.ID) {
+	if _, ok := r.protocols.Load(proto); !ok {
+		r.protocols.Store(proto, struct{}{})
+	}
+	if _, ok := r.peers.Load(p); !ok {
+		r.peers.Store(p, struct{}{})
+	}
+}
+
+// Protocols returns the protocols seen, sorted.
+func (r *Reporter) Protocols() []protocol.ID {
+	var out []protocol.ID
+	r.protocols.Range(func(k, _ interface{}) bool {
+		out = append(out, k.(protocol.ID))
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// MatchProtocols returns the protocols seen matching pattern, where *
+// matches any sequence of characters: /ipfs/bitswap* matches all the bitswap
+// versions.
+func (r *Reporter) MatchProtocols(pattern string) []protocol.ID {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	re := regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+
+	var out []protocol.ID
+	for _, proto := range r.Protocols() {
+		if re.MatchString(string(proto)) {
+			out = append(out, proto)
+		}
+	}
+	return out
+}
+
+// GetBandwidthForProtocols returns the sum of the bandwidth of protos.
+func (r *Reporter) GetBandwidthForProtocols(protos []protocol.ID) metrics.Stats {
+	var sum metrics.Stats
+	for _, proto := range protos {
+		st := r.GetBandwidthForProtocol(proto)
+		sum.TotalIn += st.TotalIn
+		sum.TotalOut += st.TotalOut
+		sum.RateIn += st.RateIn
+		sum.RateOut += st.RateOut
+	}
+	return sum
+}
+
+// Lifetime returns the bytes transferred since the time returned, including
+// the previous runs of the node.
+func (r *Reporter) Lifetime() (time.Time, Totals) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.base.Since, r.base.Total.add(r.GetBandwidthTotals())
+}
+
+// ProtocolLifetime returns the bytes transferred over proto since the time
+// returned by Lifetime.
+func (r *Reporter) ProtocolLifetime(proto protocol.ID) Totals {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.base.Protocols[proto].add(r.GetBandwidthForProtocol(proto))
+}
+
+// History returns the samples of the whole node taken after since, the
+// oldest first.
+func (r *Reporter) History(since time.Time) []Sample {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.total.list(since)
+}
+
+// ProtocolHistory returns the samples of proto taken after since.
+func (r *Reporter) ProtocolHistory(proto protocol.ID, since time.Time) []Sample {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	s, ok := r.protoHist[proto]
+	if !ok {
+		return nil
+	}
+	return s.list(since)
+}
+
+// PeerHistory returns the samples of p taken after since. Only the history
+// of the peers active during the period covered by the history is kept.
+func (r *Reporter) PeerHistory(p peer.ID, since time.Time) []Sample {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	s, ok := r.peerHist[p]
+	if !ok {
+		return nil
+	}
+	return s.list(since)
+}
+
+// sample records the current bandwidth in the history.
+func (r *Reporter) sample(now time.Time) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.total.add(Sample{Time: now, Stats: r.GetBandwidthTotals()})
+
+	r.protocols.Range(func(k, _ interface{}) bool {
+		proto := k.(protocol.ID)
+		s, ok := r.protoHist[proto]
+		if !ok {
+			s = newSeries(r.length)
+			r.protoHist[proto] = s
+		}
+		s.add(Sample{Time: now, Stats: r.GetBandwidthForProtocol(proto)})
+		return true
+	})
+
+	// the peers come and go: forget the ones idle for the whole history
+	window := r.interval * time.Duration(r.length)
+	r.peers.Range(func(k, _ interface{}) bool {
+		p := k.(peer.ID)
+		s, ok := r.peerHist[p]
+		if !ok {
+			s = newSeries(r.length)
+			s.lastActive = now
+			r.peerHist[p] = s
+		}
+		s.add(Sample{Time: now, Stats: r.GetBandwidthForPeer(p)})
+		if now.Sub(s.lastActive) > window {
+			delete(r.peerHist, p)
+			r.peers.Delete(p)
+		}
+		return true
+	})
+}
+
+// Flush writes the lifetime totals to the datastore.
+func (r *Reporter) Flush() error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	lt := lifetime{
+		Since:     r.base.Since,
+		Total:     r.base.Total.add(r.GetBandwidthTotals()),
+		Protocols: make(map[protocol.ID]Totals, len(r.base.Protocols)),
+	}
+	for proto, t := range r.base.Protocols {
+		lt.Protocols[proto] = t
+	}
+	r.protocols.Range(func(k, _ interface{}) bool {
+		proto := k.(protocol.ID)
+		lt.Protocols[proto] = r.base.Protocols[proto].add(r.GetBandwidthForProtocol(proto))
+		return true
+	})
+
+	data, err := json.Marshal(&lt)
+	if err != nil {
+		return err
+	}
+	return r.ds.Put(totalsKey, data)
+}
+
+// Start starts sampling the bandwidth and persisting the totals.
+func (r *Reporter) Start() {
+	go func() {
+		defer close(r.done)
+		sampler := time.NewTicker(r.interval)
+		defer sampler.Stop()
+		flusher := time.NewTicker(flushInterval)
+		defer flusher.Stop()
+		for {
+			select {
+			case now := <-sampler.C:
+				r.sample(now)
+			case <-flusher.C:
+				if err := r.Flush(); err != nil {
+					log.Errorf("writing the bandwidth totals: %s", err)
+				}
+			case <-r.closing:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the sampling and writes the totals one last time. It must
+// only be called after Start.
+func (r *Reporter) Close() error {
+	close(r.closing)
+	<-r.done
+	return r.Flush()
+}
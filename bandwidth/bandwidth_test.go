@@ -0,0 +1,128 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	metrics "gx/ipfs/QmdhwKw53CTV8EJSAsR1bpmMT5kXiWBgeAyv1EXeeDiXqR/go-libp2p-metrics"
+)
+
+// counter is a synchronous metrics.Reporter, the bandwidth counter of
+// libp2p updates its totals in the background.
+type counter struct {
+	metrics.Reporter
+	total  metrics.Stats
+	protos map[protocol.ID]metrics.Stats
+	peers  map[peer.ID]metrics.Stats
+}
+
+func newCounter() *counter {
+	return &counter{
+		Reporter: metrics.NewBandwidthCounter(),
+		protos:   make(map[protocol.ID]metrics.Stats),
+		peers:    make(map[peer.ID]metrics.Stats),
+	}
+}
+
+func (c *counter) LogSentMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	c.total.TotalOut += size
+	c.total.RateOut = float64(size)
+	st := c.protos[proto]
+	st.TotalOut += size
+	st.RateOut = float64(size)
+	c.protos[proto] = st
+	st = c.peers[p]
+	st.TotalOut += size
+	st.RateOut = float64(size)
+	c.peers[p] = st
+}
+
+func (c *counter) GetBandwidthTotals() metrics.Stats                   { return c.total }
+func (c *counter) GetBandwidthForProtocol(p protocol.ID) metrics.Stats { return c.protos[p] }
+func (c *counter) GetBandwidthForPeer(p peer.ID) metrics.Stats         { return c.peers[p] }
+
+func TestHistoryAndLifetime(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	cfg := &extconfig.BandwidthHistory{Interval: "1s", Length: 3}
+	r, err := NewReporter(newCounter(), d, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := peer.ID("peer")
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		r.LogSentMessageStream(100, "/ipfs/bitswap/1.1.0", p)
+		r.LogSentMessageStream(10, "/ipfs/kad/1.0.0", p)
+		r.sample(start.Add(time.Duration(i) * time.Second))
+	}
+
+	hist := r.History(time.Time{})
+	if len(hist) != 3 {
+		t.Fatalf("expected the ring buffer to keep 3 samples, got %d", len(hist))
+	}
+	if hist[0].TotalOut != 330 || hist[2].TotalOut != 550 {
+		t.Fatalf("unexpected samples %v", hist)
+	}
+	if hist := r.ProtocolHistory("/ipfs/kad/1.0.0", start.Add(4*time.Second)); len(hist) != 1 || hist[0].TotalOut != 50 {
+		t.Fatalf("unexpected protocol samples %v", hist)
+	}
+	if hist := r.PeerHistory(p, time.Time{}); len(hist) != 3 {
+		t.Fatalf("expected 3 samples for the peer, got %d", len(hist))
+	}
+
+	protos := r.MatchProtocols("/ipfs/bitswap*")
+	if len(protos) != 1 || r.GetBandwidthForProtocols(protos).TotalOut != 500 {
+		t.Fatalf("unexpected protocols %v", protos)
+	}
+
+	// the lifetime totals add up across restarts
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r2, err := NewReporter(newCounter(), d, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2.LogSentMessageStream(100, "/ipfs/bitswap/1.1.0", p)
+	since, total := r2.Lifetime()
+	if total.Out != 650 {
+		t.Fatalf("expected 650 bytes sent in total, got %d", total.Out)
+	}
+	if !since.Equal(r.base.Since) {
+		t.Fatalf("expected the lifetime to start at the first run")
+	}
+	if got := r2.ProtocolLifetime("/ipfs/bitswap/1.1.0").Out; got != 600 {
+		t.Fatalf("expected 600 bytes sent over bitswap, got %d", got)
+	}
+}
+
+func TestIdlePeersForgotten(t *testing.T) {
+	c := newCounter()
+	r, err := NewReporter(c, dssync.MutexWrap(ds.NewMapDatastore()), &extconfig.BandwidthHistory{Interval: "1s", Length: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := peer.ID("peer")
+	start := time.Now()
+	r.LogSentMessageStream(100, "/ipfs/bitswap/1.1.0", p)
+	r.sample(start)
+
+	// the peer stops transferring
+	st := c.peers[p]
+	st.RateOut = 0
+	c.peers[p] = st
+	for i := 1; i <= 3; i++ {
+		r.sample(start.Add(time.Duration(i) * time.Second))
+	}
+	if hist := r.PeerHistory(p, time.Time{}); hist != nil {
+		t.Fatalf("expected the idle peer to be forgotten, got %v", hist)
+	}
+}
@@ -0,0 +1,194 @@
+package bandwidth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	smux "gx/ipfs/QmY9JXR3FupnYAYJWK9aMr9bCpqWKcToQ1tz8DVGTrHpHw/go-stream-muxer"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+const (
+	// maxWrite is the largest write of a shaped stream, the larger ones
+	// are split so that they are spread over time.
+	maxWrite = minBurst
+
+	// multistreamHeader starts the protocol negotiation of the streams.
+	multistreamHeader = "/multistream/1.0.0"
+	// maxSniffed is the number of bytes of each direction of a stream
+	// read to find its protocol.
+	maxSniffed = 4096
+)
+
+// Muxer returns tpt with the streams of its connections shaped by s.
+func (s *Shaper) Muxer(tpt smux.Transport) smux.Transport {
+	return &shapedTransport{Transport: tpt, shaper: s}
+}
+
+type shapedTransport struct {
+	smux.Transport
+	shaper *Shaper
+}
+
+func (t *shapedTransport) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	mc, err := t.Transport.NewConn(c, isServer)
+	if err != nil {
+		return nil, err
+	}
+
+	// the muxers run over the secure connections, which know the peer
+	var p peer.ID
+	if pc, ok := c.(interface{ RemotePeer() peer.ID }); ok {
+		p = pc.RemotePeer()
+	}
+	return &shapedConn{Conn: mc, shaper: t.shaper, peer: p}, nil
+}
+
+type shapedConn struct {
+	smux.Conn
+	shaper *Shaper
+	peer   peer.ID
+}
+
+func (c *shapedConn) OpenStream() (smux.Stream, error) {
+	s, err := c.Conn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return c.wrap(s), nil
+}
+
+func (c *shapedConn) AcceptStream() (smux.Stream, error) {
+	s, err := c.Conn.AcceptStream()
+	if err != nil {
+		return nil, err
+	}
+	return c.wrap(s), nil
+}
+
+func (c *shapedConn) wrap(s smux.Stream) smux.Stream {
+	return &shapedStream{Stream: s, shaper: c.shaper, peer: c.peer}
+}
+
+// shapedStream waits for the limits before writing and after reading.
+type shapedStream struct {
+	smux.Stream
+	shaper *Shaper
+	peer   peer.ID
+	sniff  protoSniffer
+}
+
+func (s *shapedStream) Read(b []byte) (int, error) {
+	n, err := s.Stream.Read(b)
+	if n > 0 {
+		s.sniff.feed(b[:n], true)
+		s.shaper.shape(int64(n), s.sniff.protocol(), s.peer, true)
+	}
+	return n, err
+}
+
+func (s *shapedStream) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > maxWrite {
+			chunk = chunk[:maxWrite]
+		}
+		s.shaper.shape(int64(len(chunk)), s.sniff.protocol(), s.peer, false)
+
+		n, err := s.Stream.Write(chunk)
+		written += n
+		s.sniff.feed(chunk[:n], false)
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// protoSniffer finds the protocol of a stream in its multistream-select
+// negotiation, the varint delimited lines starting the stream: one side
+// proposes protocols, the other echoes the one it accepts. The protocol is
+// the first line, other than the header, seen in both directions.
+type protoSniffer struct {
+	lk    sync.Mutex
+	done  bool
+	proto protocol.ID
+
+	// the bytes not parsed yet, the lines and the number of bytes seen,
+	// of each direction, the received one first
+	buf   [2][]byte
+	lines [2]map[string]bool
+	seen  [2]int
+}
+
+func (ps *protoSniffer) protocol() protocol.ID {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	return ps.proto
+}
+
+func (ps *protoSniffer) feed(b []byte, in bool) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+	if ps.done {
+		return
+	}
+
+	d, other := 1, 0
+	if in {
+		d, other = 0, 1
+	}
+	ps.seen[d] += len(b)
+	if ps.seen[d] > maxSniffed {
+		ps.stop()
+		return
+	}
+	ps.buf[d] = append(ps.buf[d], b...)
+
+	for {
+		l, n := binary.Uvarint(ps.buf[d])
+		if n == 0 {
+			// incomplete length
+			return
+		}
+		if n < 0 || l > maxSniffed {
+			ps.stop()
+			return
+		}
+		if uint64(len(ps.buf[d])-n) < l {
+			return
+		}
+		msg := ps.buf[d][n : n+int(l)]
+		ps.buf[d] = ps.buf[d][n+int(l):]
+
+		if !bytes.HasSuffix(msg, []byte("\n")) {
+			// not a negotiation, or past it
+			ps.stop()
+			return
+		}
+		line := string(msg[:len(msg)-1])
+		if line == multistreamHeader {
+			continue
+		}
+		if ps.lines[other][line] {
+			ps.proto = protocol.ID(line)
+			ps.stop()
+			return
+		}
+		if ps.lines[d] == nil {
+			ps.lines[d] = make(map[string]bool)
+		}
+		ps.lines[d][line] = true
+	}
+}
+
+func (ps *protoSniffer) stop() {
+	ps.done = true
+	ps.buf = [2][]byte{}
+	ps.lines = [2]map[string]bool{}
+}
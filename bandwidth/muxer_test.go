@@ -0,0 +1,92 @@
+package bandwidth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+// msLine encodes a line of the multistream-select negotiation.
+func msLine(s string) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(b, uint64(len(s)+1))
+	return append(b[:n], s+"\n"...)
+}
+
+func TestProtoSniffer(t *testing.T) {
+	var ps protoSniffer
+	out := append(msLine(multistreamHeader), msLine("/ipfs/kad/1.0.0")...)
+	out = append(out, msLine("/ipfs/bitswap/1.1.0")...)
+	ps.feed(out[:5], false)
+	ps.feed(out[5:], false)
+	ps.feed(msLine(multistreamHeader), true)
+	ps.feed(msLine("na"), true)
+	if ps.protocol() != "" {
+		t.Fatalf("expected no protocol before it's accepted, got %s", ps.protocol())
+	}
+	ps.feed(append(msLine("/ipfs/bitswap/1.1.0"), "data"...), true)
+	if ps.protocol() != "/ipfs/bitswap/1.1.0" {
+		t.Fatalf("expected the accepted protocol, got %q", ps.protocol())
+	}
+
+	// not a negotiation
+	var raw protoSniffer
+	raw.feed(bytes.Repeat([]byte{0xff}, 100), true)
+	raw.feed(msLine("/ipfs/kad/1.0.0"), false)
+	raw.feed(msLine("/ipfs/kad/1.0.0"), true)
+	if raw.protocol() != "" {
+		t.Fatalf("expected no protocol, got %s", raw.protocol())
+	}
+}
+
+// sinkStream is a stream writing to a buffer.
+type sinkStream struct {
+	bytes.Buffer
+}
+
+func (s *sinkStream) Close() error                     { return nil }
+func (s *sinkStream) Reset() error                     { return nil }
+func (s *sinkStream) SetDeadline(time.Time) error      { return nil }
+func (s *sinkStream) SetReadDeadline(time.Time) error  { return nil }
+func (s *sinkStream) SetWriteDeadline(time.Time) error { return nil }
+
+func TestShapedStream(t *testing.T) {
+	s, clock := newTestShaper(t, &extconfig.BandwidthLimits{
+		Classes: map[string]extconfig.BandwidthClass{
+			"bitswap": {
+				BandwidthRates: extconfig.BandwidthRates{Out: "100KB/s"},
+				Protocols:      []string{"/ipfs/bitswap"},
+			},
+		},
+	})
+
+	sink := &sinkStream{}
+	str := &shapedStream{Stream: sink, shaper: s, peer: peer.ID("peer-a")}
+
+	// negotiate bitswap, the remote peer echoing it
+	proto := protocol.ID("/ipfs/bitswap/1.1.0")
+	if _, err := str.Write(append(msLine(multistreamHeader), msLine(string(proto))...)); err != nil {
+		t.Fatal(err)
+	}
+	str.sniff.feed(append(msLine(multistreamHeader), msLine(string(proto))...), true)
+	if str.sniff.protocol() != proto {
+		t.Fatalf("expected %s, got %q", proto, str.sniff.protocol())
+	}
+
+	// 500KB at 100KB/s, past the burst, take about 5s, written in chunks
+	if _, err := str.Write(make([]byte, 500000)); err != nil {
+		t.Fatal(err)
+	}
+	if clock.slept < 4500*time.Millisecond || clock.slept > 5*time.Second {
+		t.Fatalf("expected to wait about 5s, waited %s", clock.slept)
+	}
+	if sink.Len() < 500000 {
+		t.Fatalf("expected the data to be written, got %d bytes", sink.Len())
+	}
+}
@@ -0,0 +1,366 @@
+package bandwidth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+const (
+	// burstTime is the time of transfer at the full rate a bucket holds,
+	// the burst a stream can send after being idle.
+	burstTime = 100 * time.Millisecond
+	// minBurst is the smallest burst of a bucket, so that the low rates
+	// don't split the messages of the streams.
+	minBurst = 32 << 10
+	// sweepInterval is the interval at which the buckets of the idle peers
+	// are dropped. A bucket idle for longer is full, dropping it doesn't
+	// change the limits.
+	sweepInterval = time.Minute
+)
+
+// ParseRate parses a rate in bytes per second, such as "10MB/s" or
+// "512KiB/s", or in bits per second, such as "50Mbps" or "1Gbit/s". The
+// empty rate is 0, unlimited.
+func ParseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	lower := strings.ToLower(s)
+	for _, suffix := range []string{"bps", "bit/s"} {
+		if !strings.HasSuffix(lower, suffix) {
+			continue
+		}
+		num := strings.TrimSpace(strings.TrimSuffix(lower, suffix))
+		mult := 1.0
+		if n := len(num); n > 0 {
+			switch num[n-1] {
+			case 'k':
+				mult = 1e3
+			case 'm':
+				mult = 1e6
+			case 'g':
+				mult = 1e9
+			case 't':
+				mult = 1e12
+			}
+			if mult > 1 {
+				num = strings.TrimSpace(num[:n-1])
+			}
+		}
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid rate %q", s)
+		}
+		return v * mult / 8, nil
+	}
+
+	if strings.HasSuffix(lower, "/s") {
+		v, err := humanize.ParseBytes(strings.TrimSpace(s[:len(s)-2]))
+		if err != nil {
+			return 0, fmt.Errorf("invalid rate %q: %s", s, err)
+		}
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("invalid rate %q, expected bytes per second such as \"10MB/s\" or bits per second such as \"50Mbps\"", s)
+}
+
+// bucket is a token bucket of the bytes of a direction of a limit. The
+// streams take the tokens of the bytes they transferred, and wait while the
+// bucket is in debt.
+type bucket struct {
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, now time.Time) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+	burst := rate * burstTime.Seconds()
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return &bucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// take removes n tokens from the bucket, and returns how long to wait for
+// its debt to be repaid. The nil bucket is unlimited.
+func (b *bucket) take(n int64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rates are the parsed rates of a limit, in bytes per second.
+type rates struct {
+	in, out float64
+}
+
+func parseRates(r extconfig.BandwidthRates) (rates, error) {
+	in, err := ParseRate(r.In)
+	if err != nil {
+		return rates{}, err
+	}
+	out, err := ParseRate(r.Out)
+	if err != nil {
+		return rates{}, err
+	}
+	return rates{in: in, out: out}, nil
+}
+
+func (r rates) limited() bool {
+	return r.in > 0 || r.out > 0
+}
+
+// buckets are the buckets of the two directions of a limit.
+type buckets struct {
+	in, out *bucket
+}
+
+func newBuckets(r rates, now time.Time) *buckets {
+	return &buckets{in: newBucket(r.in, now), out: newBucket(r.out, now)}
+}
+
+func (b *buckets) take(n int64, in bool, now time.Time) time.Duration {
+	if in {
+		return b.in.take(n, now)
+	}
+	return b.out.take(n, now)
+}
+
+// idle tells whether the buckets are full since before t.
+func (b *buckets) idle(t time.Time) bool {
+	return (b.in == nil || b.in.last.Before(t)) && (b.out == nil || b.out.last.Before(t))
+}
+
+// class is a parsed BandwidthClass.
+type class struct {
+	rates     rates
+	protocols []string
+	peers     map[peer.ID]bool
+	perPeer   bool
+
+	shared *buckets
+	byPeer map[peer.ID]*buckets
+}
+
+func (c *class) match(proto protocol.ID, p peer.ID) bool {
+	if len(c.peers) > 0 && !c.peers[p] {
+		return false
+	}
+	if len(c.protocols) == 0 {
+		return true
+	}
+	for _, prefix := range c.protocols {
+		if strings.HasPrefix(string(proto), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Shaper enforces the bandwidth limits of the config on the streams of the
+// muxers wrapped by Muxer. The streams wait before writing, and after
+// reading, once a limit is exceeded, which slows them down to the rates of
+// the limits, the muxers applying the backpressure to the remote peers.
+type Shaper struct {
+	// active is 1 when a limit is set, so that the streams don't take
+	// the lock without limits
+	active int32
+
+	lk        sync.Mutex
+	cfg       extconfig.BandwidthLimits
+	total     *buckets
+	peerRates rates
+	peers     map[peer.ID]*buckets
+	classes   []*class
+	lastSweep time.Time
+
+	// now and sleep are replaced by the tests
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewShaper returns a Shaper enforcing the limits of cfg.
+func NewShaper(cfg *extconfig.BandwidthLimits) (*Shaper, error) {
+	s := &Shaper{
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+	if err := s.SetLimits(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetLimits replaces the limits, the current limits are kept when cfg is
+// invalid. The streams transferring start over with full buckets.
+func (s *Shaper) SetLimits(cfg *extconfig.BandwidthLimits) error {
+	total, err := parseRates(cfg.Total)
+	if err != nil {
+		return fmt.Errorf("Swarm.BandwidthLimits.Total: %s", err)
+	}
+	peerRates, err := parseRates(cfg.Peer)
+	if err != nil {
+		return fmt.Errorf("Swarm.BandwidthLimits.Peer: %s", err)
+	}
+
+	now := s.now()
+	active := total.limited() || peerRates.limited()
+	var classes []*class
+	for name, cc := range cfg.Classes {
+		r, err := parseRates(cc.BandwidthRates)
+		if err != nil {
+			return fmt.Errorf("Swarm.BandwidthLimits.Classes.%s: %s", name, err)
+		}
+		c := &class{
+			rates:     r,
+			protocols: cc.Protocols,
+			perPeer:   cc.PerPeer,
+			shared:    newBuckets(r, now),
+			byPeer:    make(map[peer.ID]*buckets),
+		}
+		if len(cc.Peers) > 0 {
+			c.peers = make(map[peer.ID]bool, len(cc.Peers))
+			for _, id := range cc.Peers {
+				p, err := peer.IDB58Decode(id)
+				if err != nil {
+					return fmt.Errorf("Swarm.BandwidthLimits.Classes.%s: invalid peer ID %q: %s", name, id, err)
+				}
+				c.peers[p] = true
+			}
+		}
+		if r.limited() {
+			classes = append(classes, c)
+			active = true
+		}
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.cfg = copyLimits(cfg)
+	s.total = newBuckets(total, now)
+	s.peerRates = peerRates
+	s.peers = make(map[peer.ID]*buckets)
+	s.classes = classes
+	s.lastSweep = now
+	if active {
+		atomic.StoreInt32(&s.active, 1)
+	} else {
+		atomic.StoreInt32(&s.active, 0)
+	}
+	return nil
+}
+
+// Limits returns the limits in effect.
+func (s *Shaper) Limits() extconfig.BandwidthLimits {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return copyLimits(&s.cfg)
+}
+
+func copyLimits(cfg *extconfig.BandwidthLimits) extconfig.BandwidthLimits {
+	out := extconfig.BandwidthLimits{Total: cfg.Total, Peer: cfg.Peer}
+	if len(cfg.Classes) > 0 {
+		out.Classes = make(map[string]extconfig.BandwidthClass, len(cfg.Classes))
+		for name, c := range cfg.Classes {
+			out.Classes[name] = c
+		}
+	}
+	return out
+}
+
+// shape takes the bytes transferred from the buckets of the limits of the
+// stream, and waits for the longest of their debts.
+func (s *Shaper) shape(size int64, proto protocol.ID, p peer.ID, in bool) {
+	if atomic.LoadInt32(&s.active) == 0 {
+		return
+	}
+	if wait := s.reserve(size, proto, p, in); wait > 0 {
+		s.sleep(wait)
+	}
+}
+
+func (s *Shaper) reserve(size int64, proto protocol.ID, p peer.ID, in bool) time.Duration {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	wait := s.total.take(size, in, now)
+	max := func(d time.Duration) {
+		if d > wait {
+			wait = d
+		}
+	}
+
+	if s.peerRates.limited() {
+		max(peerBuckets(s.peers, p, s.peerRates, now).take(size, in, now))
+	}
+	for _, c := range s.classes {
+		if !c.match(proto, p) {
+			continue
+		}
+		if c.perPeer {
+			max(peerBuckets(c.byPeer, p, c.rates, now).take(size, in, now))
+		} else {
+			max(c.shared.take(size, in, now))
+		}
+	}
+	return wait
+}
+
+func peerBuckets(m map[peer.ID]*buckets, p peer.ID, r rates, now time.Time) *buckets {
+	b, ok := m[p]
+	if !ok {
+		b = newBuckets(r, now)
+		m[p] = b
+	}
+	return b
+}
+
+// sweep drops the buckets of the peers idle since the last sweep.
+func (s *Shaper) sweep(now time.Time) {
+	for p, b := range s.peers {
+		if b.idle(s.lastSweep) {
+			delete(s.peers, p)
+		}
+	}
+	for _, c := range s.classes {
+		for p, b := range c.byPeer {
+			if b.idle(s.lastSweep) {
+				delete(c.byPeer, p)
+			}
+		}
+	}
+	s.lastSweep = now
+}
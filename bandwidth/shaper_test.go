@@ -0,0 +1,126 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+)
+
+func TestParseRate(t *testing.T) {
+	for s, expected := range map[string]float64{
+		"":          0,
+		"50Mbps":    50e6 / 8,
+		"8 kbps":    1000,
+		"1Gbit/s":   1e9 / 8,
+		"10MB/s":    10e6,
+		"512KiB/s":  512 << 10,
+		"1024 B/s":  1024,
+		"1.5 MiB/s": 1.5 * (1 << 20),
+	} {
+		rate, err := ParseRate(s)
+		if err != nil {
+			t.Errorf("parsing %q: %s", s, err)
+		} else if rate != expected {
+			t.Errorf("expected %q to be %f bytes/s, got %f", s, expected, rate)
+		}
+	}
+	for _, s := range []string{"10", "10MB", "fastbps", "-1Mbps", "x/s"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+}
+
+// fakeClock is the time of a Shaper, only moving when it sleeps.
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func newTestShaper(t *testing.T, cfg *extconfig.BandwidthLimits) (*Shaper, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	s, err := NewShaper(&extconfig.BandwidthLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return clock.now }
+	s.sleep = func(d time.Duration) {
+		clock.now = clock.now.Add(d)
+		clock.slept += d
+	}
+	if err := s.SetLimits(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return s, clock
+}
+
+func TestShaperRates(t *testing.T) {
+	pa, pb := peer.ID("peer-a"), peer.ID("peer-b")
+	s, clock := newTestShaper(t, &extconfig.BandwidthLimits{
+		Total: extconfig.BandwidthRates{Out: "1MB/s"},
+		Classes: map[string]extconfig.BandwidthClass{
+			"bitswap": {
+				BandwidthRates: extconfig.BandwidthRates{Out: "100KB/s"},
+				Protocols:      []string{"/ipfs/bitswap"},
+				PerPeer:        true,
+			},
+		},
+	})
+
+	// 2MB sent at 1MB/s, past the burst, take about 2s
+	for i := 0; i < 200; i++ {
+		s.shape(10000, "/ipfs/kad/1.0.0", pa, false)
+	}
+	if clock.slept < 1800*time.Millisecond || clock.slept > 2*time.Second {
+		t.Fatalf("expected to wait about 2s, waited %s", clock.slept)
+	}
+
+	// the received bytes aren't limited
+	clock.slept = 0
+	s.shape(10<<20, "/ipfs/kad/1.0.0", pa, true)
+	if clock.slept != 0 {
+		t.Fatalf("expected the received bytes not to wait, waited %s", clock.slept)
+	}
+
+	// each peer has its own bitswap limit
+	for _, p := range []peer.ID{pa, pb} {
+		clock.now = clock.now.Add(time.Minute)
+		clock.slept = 0
+		for i := 0; i < 50; i++ {
+			s.shape(10000, "/ipfs/bitswap/1.1.0", p, false)
+		}
+		if clock.slept < 4500*time.Millisecond || clock.slept > 5*time.Second {
+			t.Fatalf("expected %s to wait about 5s, waited %s", p, clock.slept)
+		}
+	}
+
+	// removing the limits stops the waits
+	if err := s.SetLimits(&extconfig.BandwidthLimits{}); err != nil {
+		t.Fatal(err)
+	}
+	clock.slept = 0
+	s.shape(100<<20, "/ipfs/bitswap/1.1.0", pa, false)
+	if clock.slept != 0 {
+		t.Fatalf("expected no wait without limits, waited %s", clock.slept)
+	}
+}
+
+func TestShaperInvalidLimits(t *testing.T) {
+	s, _ := newTestShaper(t, &extconfig.BandwidthLimits{Peer: extconfig.BandwidthRates{In: "1MB/s"}})
+	for _, cfg := range []*extconfig.BandwidthLimits{
+		{Total: extconfig.BandwidthRates{In: "fast"}},
+		{Classes: map[string]extconfig.BandwidthClass{
+			"bad": {BandwidthRates: extconfig.BandwidthRates{In: "1MB/s"}, Peers: []string{"not a peer"}},
+		}},
+	} {
+		if err := s.SetLimits(cfg); err == nil {
+			t.Fatalf("expected %v to be invalid", cfg)
+		}
+	}
+	if l := s.Limits(); l.Peer.In != "1MB/s" {
+		t.Fatalf("expected the limits to be kept, got %v", l)
+	}
+}
@@ -0,0 +1,226 @@
+// Package blockcache holds the bloom filter and the ARC cache in front of the
+// blockstore of the node, so that they can be resized while the node runs
+// and that operators can tell how often they answer the reads.
+//
+// The caches are the ones of go-ipfs-blockstore. A resize builds new caches
+// in place of the old ones: the ARC cache starts empty, and the bloom filter
+// is rebuilt from the keys of the blockstore in the background.
+//
+// A read is a miss when it reaches the blockstore behind the caches, and a
+// hit when the caches answer it, negatively included. The counts are
+// exported to Prometheus too.
+package blockcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	prometheus "gx/ipfs/QmYYv3QFnfQbiwmi1tpkgKF8o4xFnZoBrvpupTiGJwL9nH/client_golang/prometheus"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+var (
+	readsMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "blockstore_cache",
+		Name:      "reads_total",
+		Help:      "Reads of the blockstore going through its caches.",
+	})
+
+	missesMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "blockstore_cache",
+		Name:      "misses_total",
+		Help:      "Reads of the blockstore that its caches didn't answer.",
+	})
+
+	sizeMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ipfs",
+		Subsystem: "blockstore_cache",
+		Name:      "size",
+		Help:      "Size of the caches of the blockstore: blocks for the ARC cache, bytes for the bloom filter.",
+	}, []string{"cache"})
+)
+
+func init() {
+	prometheus.MustRegister(readsMetric, missesMetric, sizeMetric)
+}
+
+// Stats are the sizes of the caches and the reads they answered.
+type Stats struct {
+	ARCCacheSize      int
+	BloomFilterSize   int
+	BloomFilterHashes int
+
+	// Reads is the number of reads going through the caches, Misses the
+	// number of the ones reaching the blockstore behind them.
+	Reads  uint64
+	Misses uint64
+}
+
+// Hits returns the number of reads the caches answered.
+func (s Stats) Hits() uint64 {
+	if s.Misses > s.Reads {
+		return 0
+	}
+	return s.Reads - s.Misses
+}
+
+// Cache is the caches in front of a blockstore.
+type Cache struct {
+	ctx  context.Context
+	base bstore.Blockstore
+
+	lk     sync.RWMutex
+	opts   bstore.CacheOpts
+	cached bstore.Blockstore
+	cancel context.CancelFunc
+
+	reads  uint64
+	misses uint64
+}
+
+// New returns the caches of opts in front of bs.
+func New(ctx context.Context, bs bstore.Blockstore, opts bstore.CacheOpts) (*Cache, error) {
+	c := &Cache{ctx: ctx}
+	c.base = &missCounter{Blockstore: bs, c: c}
+	if err := c.Resize(opts); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Resize replaces the caches by the ones of opts.
+func (c *Cache) Resize(opts bstore.CacheOpts) error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	// the new bloom filter is built from the keys of the blockstore, the
+	// puts must wait for it to be in place not to be missed
+	ctx, cancel := context.WithCancel(c.ctx)
+	cached, err := bstore.CachedBlockstore(ctx, c.base, opts)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.opts, c.cached, c.cancel = opts, cached, cancel
+	sizeMetric.WithLabelValues("arc").Set(float64(opts.HasARCCacheSize))
+	sizeMetric.WithLabelValues("bloom").Set(float64(opts.HasBloomFilterSize))
+	return nil
+}
+
+// Options returns the options of the caches.
+func (c *Cache) Options() bstore.CacheOpts {
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+	return c.opts
+}
+
+// Stats returns the sizes of the caches and the reads counted so far.
+func (c *Cache) Stats() Stats {
+	opts := c.Options()
+	return Stats{
+		ARCCacheSize:      opts.HasARCCacheSize,
+		BloomFilterSize:   opts.HasBloomFilterSize,
+		BloomFilterHashes: opts.HasBloomFilterHashes,
+		Reads:             atomic.LoadUint64(&c.reads),
+		Misses:            atomic.LoadUint64(&c.misses),
+	}
+}
+
+// Blockstore returns the blockstore reading through the caches.
+func (c *Cache) Blockstore() bstore.Blockstore {
+	return (*blockstore)(c)
+}
+
+func (c *Cache) current() bstore.Blockstore {
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+	return c.cached
+}
+
+func (c *Cache) read() {
+	atomic.AddUint64(&c.reads, 1)
+	readsMetric.Inc()
+}
+
+func (c *Cache) miss() {
+	atomic.AddUint64(&c.misses, 1)
+	missesMetric.Inc()
+}
+
+// blockstore uses the current caches of the Cache.
+type blockstore Cache
+
+func (bs *blockstore) cache() *Cache {
+	return (*Cache)(bs)
+}
+
+func (bs *blockstore) DeleteBlock(k cid.Cid) error {
+	return bs.cache().current().DeleteBlock(k)
+}
+
+func (bs *blockstore) Has(k cid.Cid) (bool, error) {
+	bs.cache().read()
+	return bs.cache().current().Has(k)
+}
+
+func (bs *blockstore) Get(k cid.Cid) (blocks.Block, error) {
+	bs.cache().read()
+	return bs.cache().current().Get(k)
+}
+
+func (bs *blockstore) GetSize(k cid.Cid) (int, error) {
+	bs.cache().read()
+	return bs.cache().current().GetSize(k)
+}
+
+func (bs *blockstore) Put(b blocks.Block) error {
+	c := bs.cache()
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+	return c.cached.Put(b)
+}
+
+func (bs *blockstore) PutMany(bls []blocks.Block) error {
+	c := bs.cache()
+	c.lk.RLock()
+	defer c.lk.RUnlock()
+	return c.cached.PutMany(bls)
+}
+
+func (bs *blockstore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	return bs.cache().current().AllKeysChan(ctx)
+}
+
+func (bs *blockstore) HashOnRead(enabled bool) {
+	bs.cache().current().HashOnRead(enabled)
+}
+
+// missCounter counts the reads reaching the blockstore behind the caches.
+type missCounter struct {
+	bstore.Blockstore
+	c *Cache
+}
+
+func (bs *missCounter) Has(k cid.Cid) (bool, error) {
+	bs.c.miss()
+	return bs.Blockstore.Has(k)
+}
+
+func (bs *missCounter) Get(k cid.Cid) (blocks.Block, error) {
+	bs.c.miss()
+	return bs.Blockstore.Get(k)
+}
+
+func (bs *missCounter) GetSize(k cid.Cid) (int, error) {
+	bs.c.miss()
+	return bs.Blockstore.GetSize(k)
+}
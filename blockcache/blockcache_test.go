@@ -0,0 +1,61 @@
+package blockcache
+
+import (
+	"context"
+	"testing"
+
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+func TestCacheStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	base := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	c, err := New(ctx, base, bstore.CacheOpts{HasARCCacheSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := c.Blockstore()
+
+	b := blocks.NewBlock([]byte("hello cache"))
+	other := blocks.NewBlock([]byte("not there"))
+	if err := bs.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// the put is cached, the absent block once read
+	for _, k := range []blocks.Block{b, other, other} {
+		if _, err := bs.Has(k.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := c.Stats()
+	expected := Stats{ARCCacheSize: 16, Reads: 3, Misses: 1}
+	if st != expected {
+		t.Fatalf("expected %+v, got %+v", expected, st)
+	}
+	if st.Hits() != 2 {
+		t.Fatalf("expected 2 hits, got %d", st.Hits())
+	}
+
+	// without caches, every read is a miss
+	if err := c.Resize(bstore.CacheOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	has, err := bs.Has(b.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("expected the block to be kept by the resize")
+	}
+	st = c.Stats()
+	expected = Stats{Reads: 4, Misses: 2}
+	if st != expected {
+		t.Fatalf("expected %+v, got %+v", expected, st)
+	}
+}
@@ -0,0 +1,106 @@
+// Package blockpolicy rejects the blocks larger than the maximum block size,
+// which the other implementations can't exchange. The blocks whose CID uses
+// an insecure or too short hash function are already rejected by the block
+// service.
+//
+// The policy wraps the blockstore, so that it applies to every block written,
+// whether added through the API, fetched by bitswap or a DAG transfer, or
+// imported.
+package blockpolicy
+
+import (
+	"fmt"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+var log = logging.Logger("blockpolicy")
+
+// DefaultMaxBlockSize is the size of the largest block accepted when
+// Datastore.MaxBlockSize is unset, twice the 1MiB every implementation is
+// expected to exchange.
+const DefaultMaxBlockSize = 2 << 20
+
+// TooLargeError is the error of a block larger than the maximum block size.
+type TooLargeError struct {
+	Cid  cid.Cid
+	Size int
+	Max  int
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("block %s is %s, larger than the maximum block size of %s (Datastore.MaxBlockSize)",
+		e.Cid, humanize.IBytes(uint64(e.Size)), humanize.IBytes(uint64(e.Max)))
+}
+
+// Policy decides which blocks are accepted.
+type Policy struct {
+	// MaxSize is the size of the largest block accepted, zero for no limit.
+	MaxSize int
+}
+
+// New returns the policy of cfg.
+func New(cfg *extconfig.BlockPolicy) (*Policy, error) {
+	p := &Policy{MaxSize: DefaultMaxBlockSize}
+	if cfg.MaxBlockSize != "" {
+		size, err := humanize.ParseBytes(cfg.MaxBlockSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Datastore.MaxBlockSize %q: %s", cfg.MaxBlockSize, err)
+		}
+		p.MaxSize = int(size)
+	}
+	return p, nil
+}
+
+// Check returns the reason b is rejected, nil when it is accepted.
+func (p *Policy) Check(b blocks.Block) error {
+	if size := len(b.RawData()); p.MaxSize > 0 && size > p.MaxSize {
+		return &TooLargeError{Cid: b.Cid(), Size: size, Max: p.MaxSize}
+	}
+	return nil
+}
+
+type blockstore struct {
+	bstore.Blockstore
+	p *Policy
+}
+
+// Blockstore wraps bs so that it refuses to write the blocks p rejects.
+func Blockstore(bs bstore.Blockstore, p *Policy) bstore.Blockstore {
+	return &blockstore{Blockstore: bs, p: p}
+}
+
+func (bs *blockstore) Put(b blocks.Block) error {
+	if err := bs.p.Check(b); err != nil {
+		log.Debug(err)
+		return err
+	}
+	return bs.Blockstore.Put(b)
+}
+
+// PutMany writes none of the blocks when one of them is rejected.
+func (bs *blockstore) PutMany(bls []blocks.Block) error {
+	for _, b := range bls {
+		if err := bs.p.Check(b); err != nil {
+			log.Debug(err)
+			return err
+		}
+	}
+	return bs.Blockstore.PutMany(bls)
+}
+
+type gcBlockstore struct {
+	bstore.Blockstore
+	bstore.GCLocker
+}
+
+// GCBlockstore wraps bs so that it refuses to write the blocks p rejects.
+func GCBlockstore(bs bstore.GCBlockstore, p *Policy) bstore.GCBlockstore {
+	return &gcBlockstore{Blockstore: Blockstore(bs, p), GCLocker: bs}
+}
@@ -0,0 +1,64 @@
+package blockpolicy
+
+import (
+	"bytes"
+	"testing"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+func TestBlockstore(t *testing.T) {
+	p, err := New(&extconfig.BlockPolicy{MaxBlockSize: "1KiB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bs := Blockstore(base, p)
+
+	small := blocks.NewBlock(bytes.Repeat([]byte("a"), 1024))
+	if err := bs.Put(small); err != nil {
+		t.Fatalf("expected a block of the maximum size to be accepted: %s", err)
+	}
+
+	large := blocks.NewBlock(bytes.Repeat([]byte("b"), 1025))
+	if _, ok := bs.Put(large).(*TooLargeError); !ok {
+		t.Fatal("expected the block larger than the maximum size to be rejected")
+	}
+
+	other := blocks.NewBlock([]byte("other"))
+	if _, ok := bs.PutMany([]blocks.Block{other, large}).(*TooLargeError); !ok {
+		t.Fatal("expected the batch with a large block to be rejected")
+	}
+
+	for _, b := range []blocks.Block{large, other} {
+		if has, _ := base.Has(b.Cid()); has {
+			t.Errorf("expected %s not to be written", b.Cid())
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for s, max := range map[string]int{
+		"":     DefaultMaxBlockSize,
+		"0":    0,
+		"4MiB": 4 << 20,
+		"1MB":  1000000,
+	} {
+		p, err := New(&extconfig.BlockPolicy{MaxBlockSize: s})
+		if err != nil {
+			t.Fatalf("%q: %s", s, err)
+		}
+		if p.MaxSize != max {
+			t.Errorf("%q: expected a maximum of %d, got %d", s, max, p.MaxSize)
+		}
+	}
+
+	if _, err := New(&extconfig.BlockPolicy{MaxBlockSize: "big"}); err == nil {
+		t.Fatal("expected an invalid size to fail")
+	}
+}
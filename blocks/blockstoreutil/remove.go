@@ -28,6 +28,10 @@ type RmBlocksOpts struct {
 	Prefix string
 	Quiet  bool
 	Force  bool
+
+	// Unpin removes the direct pins of the blocks before removing them.
+	// Blocks pinned recursively or indirectly are still skipped.
+	Unpin bool
 }
 
 // RmBlocks removes the blocks provided in the cids slice.
@@ -44,6 +48,13 @@ func RmBlocks(blocks bs.GCBlockstore, pins pin.Pinner, cids []cid.Cid, opts RmBl
 		unlocker := blocks.GCLock()
 		defer unlocker.Unlock()
 
+		if opts.Unpin {
+			if err := unpinDirect(pins, cids); err != nil {
+				out <- &RemovedBlock{Error: fmt.Sprintf("unpin failed: %s", err)}
+				return
+			}
+		}
+
 		stillOkay := FilterPinned(pins, out, cids)
 
 		for _, c := range stillOkay {
@@ -60,6 +71,25 @@ func RmBlocks(blocks bs.GCBlockstore, pins pin.Pinner, cids []cid.Cid, opts RmBl
 	return out, nil
 }
 
+// unpinDirect removes the direct pins of the given Cids.
+func unpinDirect(pins pin.Pinner, cids []cid.Cid) error {
+	res, err := pins.CheckIfPinned(cids...)
+	if err != nil {
+		return err
+	}
+	unpinned := false
+	for _, r := range res {
+		if r.Mode == pin.Direct {
+			pins.RemovePinWithMode(r.Key, pin.Direct)
+			unpinned = true
+		}
+	}
+	if !unpinned {
+		return nil
+	}
+	return pins.Flush()
+}
+
 // FilterPinned takes a slice of Cids and returns it with the pinned Cids
 // removed. If a Cid is pinned, it will place RemovedBlock objects in the given
 // out channel, with an error which indicates that the Cid is pinned.
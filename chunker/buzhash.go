@@ -0,0 +1,100 @@
+package chunker
+
+import (
+	"io"
+	"math/bits"
+)
+
+// The sizes of the buzhash chunks: a boundary is found on average every
+// buzMask+1 bytes after buzMin.
+const (
+	buzMin    = 128 << 10
+	buzMax    = 512 << 10
+	buzMask   = 1<<17 - 1
+	buzWindow = 32
+)
+
+// buzTable maps the bytes to the random values summed by the rolling hash.
+var buzTable [256]uint32
+
+func init() {
+	// the values only have to be random and never change: they are
+	// drawn from a fixed seed with splitmix64
+	x := uint64(0x62757a68617368) // "buzhash"
+	for i := range buzTable {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		buzTable[i] = uint32(z ^ (z >> 31))
+	}
+}
+
+// Buzhash splits a reader into content defined chunks of 128KiB to 512KiB,
+// cut where the buzhash of the last 32 bytes has its 17 low bits unset.
+type Buzhash struct {
+	r   io.Reader
+	buf []byte
+	n   int
+	eof bool
+}
+
+// NewBuzhash returns a buzhash splitter of r.
+func NewBuzhash(r io.Reader) *Buzhash {
+	return &Buzhash{
+		r:   r,
+		buf: make([]byte, buzMax),
+	}
+}
+
+// Reader returns the reader being split.
+func (b *Buzhash) Reader() io.Reader {
+	return b.r
+}
+
+// NextBytes returns the next chunk, or io.EOF after the last one.
+func (b *Buzhash) NextBytes() ([]byte, error) {
+	if !b.eof {
+		n, err := io.ReadFull(b.r, b.buf[b.n:])
+		b.n += n
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			b.eof = true
+		default:
+			return nil, err
+		}
+	}
+	if b.n == 0 {
+		return nil, io.EOF
+	}
+
+	cut := buzCut(b.buf[:b.n])
+	chunk := make([]byte, cut)
+	copy(chunk, b.buf)
+	b.n = copy(b.buf, b.buf[cut:b.n])
+	return chunk, nil
+}
+
+// buzCut returns the length of the chunk starting data, which holds the
+// maximum size of a chunk unless it is the end of the input.
+func buzCut(data []byte) int {
+	if len(data) <= buzMin {
+		return len(data)
+	}
+
+	var state uint32
+	i := buzMin - buzWindow
+	for ; i < buzMin; i++ {
+		state = bits.RotateLeft32(state, 1) ^ buzTable[data[i]]
+	}
+	for ; i < len(data); i++ {
+		if state&buzMask == 0 {
+			return i
+		}
+		// the byte leaving the window was rotated 32 times: it is
+		// removed by xoring its value again
+		state = bits.RotateLeft32(state, 1) ^ buzTable[data[i-buzWindow]] ^ buzTable[data[i]]
+	}
+	return len(data)
+}
@@ -0,0 +1,157 @@
+// Package chunker parses the chunkers of the importer, the strategies
+// splitting the added files into blocks, and validates their parameters.
+//
+// Besides the fixed size and rabin chunkers of go-ipfs-chunker, it provides
+// buzhash, a content defined chunker faster than rabin: the boundaries of
+// the chunks depend on the content around them, so that the chunks after an
+// insertion in a file are deduplicated with the ones of the original file.
+package chunker
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	chunk "gx/ipfs/QmdSeG9s4EQ9TGruJJS9Us38TQDZtMmFGwzTYUDVqNTURm/go-ipfs-chunker"
+)
+
+// DefaultChunker is the chunker used when none is given.
+const DefaultChunker = "size-262144"
+
+// SizeLimit is the maximum size of a chunk. Larger blocks aren't transferred
+// by bitswap.
+const SizeLimit = 1 << 20
+
+// rabinMinSize is the minimum size of a rabin chunk, the size of the window
+// of its rolling hash.
+const rabinMinSize = 16
+
+// The kinds of chunkers.
+const (
+	kindSize    = "size"
+	kindRabin   = "rabin"
+	kindBuzhash = "buzhash"
+)
+
+// spec is a parsed chunker.
+type spec struct {
+	kind          string
+	size          uint64
+	min, avg, max uint64
+}
+
+// FromString returns the splitter of r described by s:
+//
+//	size-[bytes]               chunks of a fixed size
+//	rabin                      rabin chunks of 256KiB on average
+//	rabin-[avg]                rabin chunks of avg bytes on average
+//	rabin-[min]-[avg]-[max]    rabin chunks of min to max bytes
+//	buzhash                    buzhash chunks of 128KiB to 512KiB
+//
+// The default chunker is used when s is empty or "default".
+func FromString(r io.Reader, s string) (chunk.Splitter, error) {
+	sp, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch sp.kind {
+	case kindRabin:
+		return chunk.NewRabinMinMax(r, sp.min, sp.avg, sp.max), nil
+	case kindBuzhash:
+		return NewBuzhash(r), nil
+	default:
+		return chunk.NewSizeSplitter(r, int64(sp.size)), nil
+	}
+}
+
+// Validate checks the chunker s, so that a command can fail before reading
+// its input.
+func Validate(s string) error {
+	_, err := parse(s)
+	return err
+}
+
+func parse(s string) (*spec, error) {
+	if s == "" || s == "default" {
+		s = DefaultChunker
+	}
+
+	parts := strings.Split(s, "-")
+	switch parts[0] {
+	case kindSize:
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid chunker %q, expected size-[bytes]", s)
+		}
+		size, err := parseSize(s, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return &spec{kind: kindSize, size: size}, nil
+
+	case kindRabin:
+		sp := &spec{kind: kindRabin, avg: uint64(chunk.DefaultBlockSize)}
+		switch len(parts) {
+		case 1:
+		case 2:
+			avg, err := parseSize(s, parts[1])
+			if err != nil {
+				return nil, err
+			}
+			sp.avg = avg
+		case 4:
+			var err error
+			if sp.min, err = parseSize(s, parts[1]); err != nil {
+				return nil, err
+			}
+			if sp.avg, err = parseSize(s, parts[2]); err != nil {
+				return nil, err
+			}
+			if sp.max, err = parseSize(s, parts[3]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid chunker %q, expected rabin, rabin-[avg] or rabin-[min]-[avg]-[max]", s)
+		}
+		if len(parts) < 4 {
+			// the sizes of chunk.NewRabin
+			sp.min, sp.max = sp.avg/3, sp.avg+sp.avg/2
+		}
+
+		switch {
+		case sp.min < rabinMinSize:
+			return nil, fmt.Errorf("invalid chunker %q: the minimum size must be at least %d bytes", s, rabinMinSize)
+		case sp.min >= sp.avg:
+			return nil, fmt.Errorf("invalid chunker %q: the minimum size must be smaller than the average size", s)
+		case sp.avg >= sp.max:
+			return nil, fmt.Errorf("invalid chunker %q: the average size must be smaller than the maximum size", s)
+		case sp.max > SizeLimit:
+			return nil, fmt.Errorf("invalid chunker %q: the maximum size must be at most %d bytes", s, SizeLimit)
+		}
+		return sp, nil
+
+	case kindBuzhash:
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("invalid chunker %q, buzhash takes no parameters", s)
+		}
+		return &spec{kind: kindBuzhash}, nil
+
+	default:
+		return nil, fmt.Errorf("unrecognized chunker %q, expected size-[bytes], rabin-[min]-[avg]-[max] or buzhash", s)
+	}
+}
+
+func parseSize(s, size string) (uint64, error) {
+	n, err := strconv.ParseUint(size, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chunker %q: %q isn't a number of bytes", s, size)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("invalid chunker %q: the sizes must be positive", s)
+	}
+	if n > SizeLimit {
+		return 0, fmt.Errorf("invalid chunker %q: the sizes must be at most %d bytes", s, SizeLimit)
+	}
+	return n, nil
+}
@@ -0,0 +1,97 @@
+package chunker
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(42)).Read(data)
+	return data
+}
+
+func split(t testing.TB, chunker string, data []byte) [][]byte {
+	spl, err := FromString(bytes.NewReader(data), chunker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks [][]byte
+	for {
+		c, err := spl.NextBytes()
+		if err == io.EOF {
+			return chunks
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, c)
+	}
+}
+
+func TestBuzhashShift(t *testing.T) {
+	data := randomData(8 << 20)
+	chunks := split(t, "buzhash", data)
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatal("the chunks don't make the data")
+	}
+	for _, c := range chunks[:len(chunks)-1] {
+		if len(c) < buzMin || len(c) > buzMax {
+			t.Fatalf("chunk of %d bytes out of the bounds", len(c))
+		}
+	}
+
+	// the chunks after an insertion are the same
+	seen := make(map[string]bool)
+	for _, c := range chunks {
+		seen[string(c)] = true
+	}
+	var shared int
+	for _, c := range split(t, "buzhash", append([]byte("inserted"), data...)) {
+		if seen[string(c)] {
+			shared++
+		}
+	}
+	if shared < len(chunks)-2 {
+		t.Fatalf("expected the chunks after the insertion to be shared, %d of %d are", shared, len(chunks))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, s := range []string{"", "default", "size-1024", "rabin", "rabin-65536", "rabin-16384-65536-131072", "buzhash"} {
+		if err := Validate(s); err != nil {
+			t.Errorf("expected %q to be valid, got %s", s, err)
+		}
+	}
+	for _, s := range []string{"size", "size-0", "size-2097152", "size-a", "rabin-1-2", "rabin-8-65536-131072",
+		"rabin-65536-16384-131072", "rabin-16384-131072-65536", "rabin-16384-65536-2097152", "buzhash-1", "fastcdc"} {
+		if err := Validate(s); err == nil {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+}
+
+// The benchmarks compare the throughput of the chunkers, to choose the
+// defaults.
+
+func benchmarkChunker(b *testing.B, chunker string) {
+	data := randomData(16 << 20)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		split(b, chunker, data)
+	}
+}
+
+func BenchmarkSize(b *testing.B) {
+	benchmarkChunker(b, "size-262144")
+}
+
+func BenchmarkRabin(b *testing.B) {
+	benchmarkChunker(b, "rabin")
+}
+
+func BenchmarkBuzhash(b *testing.B) {
+	benchmarkChunker(b, "buzhash")
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 
 	version "github.com/ipfs/go-ipfs"
@@ -18,34 +19,45 @@ import (
 	"github.com/ipfs/go-ipfs/core"
 	commands "github.com/ipfs/go-ipfs/core/commands"
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
+	corelog "github.com/ipfs/go-ipfs/core/corelog"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	migrate "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+	_ "github.com/ipfs/go-ipfs/repo/fsrepo/migrations/mg6"
+	tracing "github.com/ipfs/go-ipfs/tracing"
 
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 	mprome "gx/ipfs/QmUHHsirrDtP6WEHhE8SZeG672CLqDJn6XGzAHnvBHUiA3/go-metrics-prometheus"
 	"gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
 	"gx/ipfs/QmYYv3QFnfQbiwmi1tpkgKF8o4xFnZoBrvpupTiGJwL9nH/client_golang/prometheus"
 	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
 )
 
 const (
 	adjustFDLimitKwd          = "manage-fdlimit"
+	configOverrideKwd         = "config-override"
 	enableGCKwd               = "enable-gc"
 	initOptionKwd             = "init"
 	initProfileOptionKwd      = "init-profile"
 	ipfsMountKwd              = "mount-ipfs"
 	ipnsMountKwd              = "mount-ipns"
+	mfsMountKwd               = "mount-mfs"
 	migrateKwd                = "migrate"
 	mountKwd                  = "mount"
 	offlineKwd                = "offline"
+	offlineWebUIKwd           = "offline-webui"
 	routingOptionKwd          = "routing"
 	routingOptionSupernodeKwd = "supernode"
 	routingOptionDHTClientKwd = "dhtclient"
+	routingOptionDHTServerKwd = "dhtserver"
+	routingOptionDHTAutoKwd   = "auto"
 	routingOptionDHTKwd       = "dht"
 	routingOptionNoneKwd      = "none"
+	routingOptionCustomKwd    = "custom"
 	routingOptionDefaultKwd   = "default"
 	unencryptTransportKwd     = "disable-transport-encryption"
 	unrestrictedApiAccessKwd  = "unrestricted-api"
@@ -118,6 +130,23 @@ or send a SIGTERM signal to it (e.g. with 'kill'). It may take a while for the
 daemon to shutdown gracefully, but it can be killed forcibly by sending a
 second signal.
 
+Config overrides
+
+Config keys can be overridden when the daemon starts, without changing the
+config file, with environment variables named after the key, its dots
+replaced by underscores:
+
+  IPFS_CONFIG_Swarm_ConnMgr_HighWater=200 ipfs daemon
+
+or with --config-override, which takes precedence and accepts several
+Key=Value pairs separated by semicolons:
+
+  ipfs daemon --config-override 'Swarm.ConnMgr.HighWater=200;Routing.Type=dhtclient'
+
+Values are parsed as JSON, and taken as strings when they aren't valid JSON.
+The commands sent to the daemon see the overridden values, and never write
+them to the config file.
+
 IPFS_PATH environment variable
 
 ipfs uses a repository in the local file system. By default, the repo is
@@ -128,14 +157,58 @@ environment variable:
 
 Routing
 
-IPFS by default will use a DHT for content routing. There is a highly
-experimental alternative that operates the DHT in a 'client only' mode that
-can be enabled by running the daemon as:
+IPFS by default will use a DHT for content routing, as two separate DHTs: the
+WAN DHT with the peers connected over public addresses and the LAN DHT with
+the peers connected over private and loopback ones, so that the records of
+the local network never reach the public DHT. By default, the node answers
+the queries of the other peers as a DHT server. It can instead only query
+them as a client, for instance on a node behind a NAT:
 
   ipfs daemon --routing=dhtclient
 
-This will later be transitioned into a config option once it gets out of the
-'experimental' stage.
+or act as a server of the WAN DHT only while AutoNAT finds it publicly
+reachable, staying a server of the LAN DHT:
+
+  ipfs daemon --routing=auto
+
+The Routing.Type config sets the same values, "dhtserver" being the same as
+the default "dht". 'ipfs stats dht' shows the routing tables of both DHTs.
+
+With --routing=custom, or the Routing.Type config set to "custom", the daemon
+uses the router of a plugin, named by the Routing.Custom config.
+
+Offline mode
+
+With the global --offline option, the daemon runs without connecting to the
+rest of the network and only provides the local API:
+
+  ipfs daemon --offline
+
+Single commands can run offline against an online daemon the same way, e.g.
+'ipfs cat --offline <ref>' fails if a block of <ref> isn't stored locally.
+
+Migrations
+
+When the repo has an older version, 'ipfs daemon --migrate' upgrades it
+before starting. The migrations built into ipfs, such as the 6-to-7 one, run
+in process; the others are downloaded from the dist and run one version at a
+time. If a migration fails, the ones already run are reverted.
+
+The dist is fetched block by block from a gateway, and each block is verified
+against its CID, so that the migrations run are those of the dist CID built
+into ipfs. IPFS_DIST_PATH sets another dist, as an /ipfs/ path or the URL of
+one on a gateway, or a local directory holding a copy of the dist for the
+nodes without internet access; the local copy is trusted.
+
+WebUI
+
+The API serves the WebUI at /webui. The WebUI.Path config selects the version
+served, and WebUI.Bundle a CAR file holding a WebUI, imported and pinned when
+the daemon starts. With --offline-webui, or the WebUI.Offline config, the WebUI
+is only served from the local repo, for the nodes without network access:
+
+  ipfs webui pin /ipfs/<webui-hash>      # on a connected node
+  ipfs daemon --offline-webui
 
 DEPRECATION NOTICE
 
@@ -157,15 +230,17 @@ Headers.
 		cmdkit.BoolOption(writableKwd, "Enable writing objects (with POST, PUT and DELETE)"),
 		cmdkit.StringOption(ipfsMountKwd, "Path to the mountpoint for IPFS (if using --mount). Defaults to config setting."),
 		cmdkit.StringOption(ipnsMountKwd, "Path to the mountpoint for IPNS (if using --mount). Defaults to config setting."),
+		cmdkit.StringOption(mfsMountKwd, "Path to the read-write mountpoint for MFS (if using --mount). Defaults to config setting."),
 		cmdkit.BoolOption(unrestrictedApiAccessKwd, "Allow API access to unlisted hashes"),
+		cmdkit.BoolOption(offlineWebUIKwd, "Serve the WebUI from the local repo only, never fetching it from the network"),
 		cmdkit.BoolOption(unencryptTransportKwd, "Disable transport encryption (for debugging protocols)"),
 		cmdkit.BoolOption(enableGCKwd, "Enable automatic periodic repo garbage collection"),
 		cmdkit.BoolOption(adjustFDLimitKwd, "Check and raise file descriptor limits if needed").WithDefault(true),
-		cmdkit.BoolOption(offlineKwd, "Run offline. Do not connect to the rest of the network but provide local API."),
 		cmdkit.BoolOption(migrateKwd, "If true, assume yes at the migrate prompt. If false, assume no."),
 		cmdkit.BoolOption(enableFloodSubKwd, "Instantiate the ipfs daemon with the experimental pubsub feature enabled."),
 		cmdkit.BoolOption(enableIPNSPubSubKwd, "Enable IPNS record distribution through pubsub; enables pubsub."),
 		cmdkit.BoolOption(enableMultiplexKwd, "Add the experimental 'go-multiplex' stream muxer to libp2p on construction.").WithDefault(true),
+		cmdkit.StringOption(configOverrideKwd, "Override config keys without changing the config file, as 'Key=Value' pairs separated by semicolons."),
 
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmdkit.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -175,6 +250,36 @@ Headers.
 	Run:         daemonFunc,
 }
 
+// setConfigOverrides overrides the config keys of the IPFS_CONFIG_
+// environment variables and of the --config-override pairs.
+func setConfigOverrides(r *fsrepo.FSRepo, pairs string) error {
+	var kvs []string
+	for _, kv := range strings.Split(pairs, ";") {
+		if kv = strings.TrimSpace(kv); kv != "" {
+			kvs = append(kvs, kv)
+		}
+	}
+
+	overrides, err := fsrepo.ParseConfigOverrides(os.Environ(), kvs)
+	if err != nil {
+		return err
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	if err := r.SetConfigOverrides(overrides); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Printf("Overriding config keys: %s\n", strings.Join(keys, ", "))
+	return nil
+}
+
 // defaultMux tells mux to serve path using the default muxer. This is
 // mostly useful to hook up things that register in the default muxer,
 // and don't provide a convenient http.Handler entry point, such as
@@ -257,7 +362,12 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 			return fmt.Errorf("fs-repo requires migration")
 		}
 
-		err = migrate.RunMigration(fsrepo.RepoVersion)
+		rlk, err := fsrepo.LockReaders(cctx.ConfigRoot)
+		if err != nil {
+			return err
+		}
+		err = migrate.RunMigrations(cctx.ConfigRoot, fsrepo.RepoVersion, os.Stdout)
+		rlk.Close()
 		if err != nil {
 			fmt.Println("The migrations of fs-repo failed:")
 			fmt.Printf("  %s\n", err)
@@ -274,10 +384,45 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		break
 	}
 
-	cfg, err := cctx.GetConfig()
+	pairs, _ := req.Options[configOverrideKwd].(string)
+	if err := setConfigOverrides(repo.(*fsrepo.FSRepo), pairs); err != nil {
+		return err
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	// the API, the gateway and the mounts read the overridden config
+	cctx.SetConfig(cfg)
+
+	lcfg, err := extconfig.LoadLogging(repo)
+	if err != nil {
+		return err
+	}
+	logs, err := corelog.Configure(lcfg, cctx.ConfigRoot)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	if err := corelog.SetLevels(lcfg.Levels); err != nil {
+		return err
+	}
+
+	// export traces before constructing the node so its startup is traced
+	tcfg, err := extconfig.LoadTracing(repo)
+	if err != nil {
+		return err
+	}
+	tracer, err := tracing.New(tcfg)
 	if err != nil {
 		return err
 	}
+	if tracer != nil {
+		opentracing.SetGlobalTracer(tracer)
+		defer tracer.Close()
+		fmt.Printf("Exporting traces to %s\n", tracer.Endpoint())
+	}
 
 	offline, _ := req.Options[offlineKwd].(bool)
 	ipnsps, _ := req.Options[enableIPNSPubSubKwd].(bool)
@@ -315,10 +460,21 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return errors.New("supernode routing was never fully implemented and has been removed")
 	case routingOptionDHTClientKwd:
 		ncfg.Routing = core.DHTClientOption
-	case routingOptionDHTKwd:
+	case routingOptionDHTKwd, routingOptionDHTServerKwd:
 		ncfg.Routing = core.DHTOption
+	case routingOptionDHTAutoKwd:
+		ncfg.Routing = core.DHTAutoOption
 	case routingOptionNoneKwd:
 		ncfg.Routing = core.NilRouterOption
+	case routingOptionCustomKwd:
+		name, err := extconfig.LoadRoutingCustom(repo)
+		if err != nil {
+			return err
+		}
+		ncfg.Routing, err = core.CustomRoutingOptions.Get(name)
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unrecognized routing option: %s", routingOption)
 	}
@@ -329,6 +485,21 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return err
 	}
 	node.SetLocal(false)
+	node.OnConfigReload("Logging.Levels", func() error {
+		lcfg, err := extconfig.LoadLogging(node.Repo)
+		if err != nil {
+			return err
+		}
+		return corelog.SetLevels(lcfg.Levels)
+	})
+
+	// SIGTERM drains the daemon like 'ipfs shutdown --drain'
+	onTerminate.Store(func() {
+		fmt.Println("Received SIGTERM, draining before shutting down...")
+		if err := node.Drain(core.DefaultDrainTimeout); err != nil {
+			log.Error("error while draining ipfs daemon:", err)
+		}
+	})
 
 	if node.PNetFingerprint != nil {
 		fmt.Println("Swarm is limited to private network of peers with the swarm key")
@@ -417,29 +588,57 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		return nil, fmt.Errorf("serveHTTPApi: invalid API address: %q (err: %s)", apiAddr, err)
 	}
 
-	apiLis, err := manet.Listen(apiMaddr)
-	if err != nil {
-		return nil, fmt.Errorf("serveHTTPApi: manet.Listen(%s) failed: %s", apiMaddr, err)
+	var apiLis net.Listener
+	if sockPath, ok := corehttp.UnixSocketPath(apiMaddr); ok {
+		apiLis, err = listenAPIUnix(cctx, sockPath)
+		if err != nil {
+			return nil, fmt.Errorf("serveHTTPApi: listening on %s failed: %s", apiMaddr, err)
+		}
+	} else {
+		mlis, err := manet.Listen(apiMaddr)
+		if err != nil {
+			return nil, fmt.Errorf("serveHTTPApi: manet.Listen(%s) failed: %s", apiMaddr, err)
+		}
+		// we might have listened to /tcp/0 - lets see what we are listing on
+		apiMaddr = mlis.Multiaddr()
+		apiLis = manet.NetListener(mlis)
 	}
-	// we might have listened to /tcp/0 - lets see what we are listing on
-	apiMaddr = apiLis.Multiaddr()
 	fmt.Printf("API server listening on %s\n", apiMaddr)
 
+	node, err := cctx.ConstructNode()
+	if err != nil {
+		return nil, fmt.Errorf("serveHTTPApi: ConstructNode() failed: %s", err)
+	}
+
+	webuiCfg, err := extconfig.LoadWebUI(node.Repo)
+	if err != nil {
+		return nil, fmt.Errorf("serveHTTPApi: %s", err)
+	}
+	webuiPath, err := setupWebUI(req.Context, node, webuiCfg)
+	if err != nil {
+		return nil, fmt.Errorf("serveHTTPApi: %s", err)
+	}
+	offlineWebUI, _ := req.Options[offlineWebUIKwd].(bool)
+	offlineWebUI = offlineWebUI || webuiCfg.Offline.WithDefault(false)
+
 	// by default, we don't let you load arbitrary ipfs objects through the api,
 	// because this would open up the api to scripting vulnerabilities.
 	// only the webui objects are allowed.
 	// if you know what you're doing, go ahead and pass --unrestricted-api.
 	unrestricted, _ := req.Options[unrestrictedApiAccessKwd].(bool)
-	gatewayOpt := corehttp.GatewayOption(false, corehttp.WebUIPaths...)
+	gatewayOpt := corehttp.WebUIGatewayOption(offlineWebUI, webuiPaths(webuiPath)...)
 	if unrestricted {
 		gatewayOpt = corehttp.GatewayOption(true, "/ipfs", "/ipns")
 	}
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("api"),
+		corehttp.TracingOption("api"),
+		corehttp.DrainOption(),
+		corehttp.APIAuthOption(),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsOption(*cctx),
-		corehttp.WebUIOption,
+		corehttp.RedirectOption("webui", webuiPath),
 		gatewayOpt,
 		corehttp.VersionOption(),
 		defaultMux("/debug/vars"),
@@ -452,23 +651,36 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
 
-	node, err := cctx.ConstructNode()
-	if err != nil {
-		return nil, fmt.Errorf("serveHTTPApi: ConstructNode() failed: %s", err)
-	}
-
 	if err := node.Repo.SetAPIAddr(apiMaddr); err != nil {
 		return nil, fmt.Errorf("serveHTTPApi: SetAPIAddr() failed: %s", err)
 	}
 
 	errc := make(chan error)
 	go func() {
-		errc <- corehttp.Serve(node, manet.NetListener(apiLis), opts...)
+		errc <- corehttp.Serve(node, apiLis, opts...)
 		close(errc)
 	}()
 	return errc, nil
 }
 
+// listenAPIUnix creates the unix domain socket of the API, configured by
+// API.UnixSocket.
+func listenAPIUnix(cctx *oldcmds.Context, path string) (net.Listener, error) {
+	node, err := cctx.ConstructNode()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := extconfig.LoadAPIUnixSocket(node.Repo)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := cfg.FileMode()
+	if err != nil {
+		return nil, err
+	}
+	return corehttp.ListenUnix(path, mode, cfg.AllowedUIDs)
+}
+
 // printSwarmAddrs prints the addresses of the host
 func printSwarmAddrs(node *core.IpfsNode) {
 	if !node.OnlineMode() {
@@ -532,6 +744,8 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("gateway"),
+		corehttp.TracingOption("gateway"),
+		corehttp.DrainOption(),
 		corehttp.IPNSHostnameOption(),
 		corehttp.GatewayOption(writable, "/ipfs", "/ipns"),
 		corehttp.VersionOption(),
@@ -578,12 +792,23 @@ func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 		return fmt.Errorf("mountFuse: ConstructNode() failed: %s", err)
 	}
 
-	err = nodeMount.Mount(node, fsdir, nsdir)
+	mfsdir, found := req.Options[mfsMountKwd].(string)
+	if !found {
+		mfsdir, err = extconfig.LoadMountsMFS(node.Repo)
+		if err != nil {
+			return fmt.Errorf("mountFuse: LoadMountsMFS() failed: %s", err)
+		}
+	}
+
+	err = nodeMount.Mount(node, fsdir, nsdir, mfsdir)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("IPFS mounted at: %s\n", fsdir)
 	fmt.Printf("IPNS mounted at: %s\n", nsdir)
+	if mfsdir != "" {
+		fmt.Printf("MFS mounted at: %s\n", mfsdir)
+	}
 	return nil
 }
 
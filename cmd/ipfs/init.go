@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
@@ -13,7 +14,9 @@ import (
 	assets "github.com/ipfs/go-ipfs/assets"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	namesys "github.com/ipfs/go-ipfs/namesys"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	"gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
@@ -25,12 +28,36 @@ const (
 	nBitsForKeypairDefault = 2048
 )
 
+// defaultQUICAddrs are added to the swarm addresses of newly generated
+// configs, next to the TCP addresses set by config.Init.
+var defaultQUICAddrs = []string{
+	"/ip4/0.0.0.0/udp/4001/quic",
+	"/ip6/::/udp/4001/quic",
+}
+
 var initCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Initializes ipfs config file.",
 		ShortDescription: `
 Initializes ipfs configuration files and generates a new keypair.
 
+The keypair is an ed25519 one by default; --algorithm selects a secp256k1 or
+an RSA keypair instead, of --bits bits. To initialize a repo with an existing
+identity, as when provisioning a fleet of nodes, --import-identity reads the
+private key from a file, either as written by 'ipfs key' in the keystore or
+encoded in base64 like Identity.PrivKey in the config:
+
+    ipfs init --import-identity=node.key --profile=server
+
+Unless --empty-repo is set, the getting-started docs are added to the repo.
+The 'emptyrepo' profile skips them too, and the Init section of the config
+given to init can replace them with the content of a CAR file:
+
+    "Init": {
+      "EmptyRepo": false,
+      "SeedCAR": "/usr/share/product/welcome.car"
+    }
+
 If you are going to run IPFS in server environment, you may want to
 initialize it using 'server' profile.
 
@@ -47,7 +74,9 @@ environment variable:
 		cmdkit.FileArg("default-config", false, false, "Initialize with the given configuration.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
-		cmdkit.IntOption("bits", "b", "Number of bits to use in the generated RSA private key.").WithDefault(nBitsForKeypairDefault),
+		cmdkit.StringOption("algorithm", "a", "Key algorithm of the identity: ed25519, secp256k1 or rsa. Defaults to ed25519, or rsa with --bits."),
+		cmdkit.IntOption("bits", "b", "Number of bits to use in the generated RSA private key. Defaults to 2048."),
+		cmdkit.StringOption("import-identity", "Initialize with the private key in the given file instead of generating one."),
 		cmdkit.BoolOption("empty-repo", "e", "Don't add and pin help files to the local storage."),
 		cmdkit.StringOption("profile", "p", "Apply profile settings to config. Multiple profiles can be separated by ','"),
 
@@ -79,9 +108,15 @@ environment variable:
 		}
 
 		empty, _ := req.Options["empty-repo"].(bool)
-		nBitsForKeypair, _ := req.Options["bits"].(int)
+		idOpts, err := initIdentityOptions(req)
+		if err != nil {
+			return err
+		}
 
-		var conf *config.Config
+		var (
+			conf     *config.Config
+			confInit extconfig.Init
+		)
 
 		f := req.Files
 		if f != nil {
@@ -89,11 +124,21 @@ environment variable:
 			if err != nil {
 				return err
 			}
+			data, err := ioutil.ReadAll(confFile)
+			if err != nil {
+				return err
+			}
 
 			conf = &config.Config{}
-			if err := json.NewDecoder(confFile).Decode(conf); err != nil {
+			if err := json.Unmarshal(data, conf); err != nil {
 				return err
 			}
+			// the Init section isn't part of config.Config
+			var ext struct{ Init extconfig.Init }
+			if err := json.Unmarshal(data, &ext); err != nil {
+				return err
+			}
+			confInit = ext.Init
 		}
 
 		profile, _ := req.Options["profile"].(string)
@@ -103,10 +148,37 @@ environment variable:
 			profiles = strings.Split(profile, ",")
 		}
 
-		return doInit(os.Stdout, cctx.ConfigRoot, empty, nBitsForKeypair, profiles, conf)
+		return doInit(os.Stdout, cctx.ConfigRoot, empty, idOpts, profiles, conf, confInit)
 	},
 }
 
+func initIdentityOptions(req *cmds.Request) (identityOptions, error) {
+	algorithm, _ := req.Options["algorithm"].(string)
+	bits, bitsSet := req.Options["bits"].(int)
+	importFile, _ := req.Options["import-identity"].(string)
+
+	if importFile != "" {
+		if algorithm != "" || bitsSet {
+			return identityOptions{}, cmds.ClientError("--import-identity can't be used with --algorithm or --bits")
+		}
+		return identityOptions{importFile: importFile}, nil
+	}
+
+	if algorithm == "" {
+		algorithm = algorithmDefault
+		if bitsSet {
+			algorithm = algorithmRSA
+		}
+	}
+	if algorithm != algorithmRSA && bitsSet {
+		return identityOptions{}, cmds.ClientError("--bits only applies to rsa keys")
+	}
+	if !bitsSet {
+		bits = nBitsForKeypairDefault
+	}
+	return identityOptions{algorithm: algorithm, bits: bits}, nil
+}
+
 var errRepoExists = errors.New(`ipfs configuration file already exists!
 Reinitializing would overwrite your keys.
 `)
@@ -117,10 +189,10 @@ func initWithDefaults(out io.Writer, repoRoot string, profile string) error {
 		profiles = strings.Split(profile, ",")
 	}
 
-	return doInit(out, repoRoot, false, nBitsForKeypairDefault, profiles, nil)
+	return doInit(out, repoRoot, false, identityOptions{algorithm: algorithmDefault}, profiles, nil, extconfig.Init{})
 }
 
-func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, confProfiles []string, conf *config.Config) error {
+func doInit(out io.Writer, repoRoot string, empty bool, idOpts identityOptions, confProfiles []string, conf *config.Config, confInit extconfig.Init) error {
 	if _, err := fmt.Fprintf(out, "initializing IPFS node at %s\n", repoRoot); err != nil {
 		return err
 	}
@@ -135,14 +207,24 @@ func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, con
 
 	if conf == nil {
 		var err error
-		conf, err = config.Init(out, nBitsForKeypair)
+		conf, err = newConfig(out, idOpts)
+		if err != nil {
+			return err
+		}
+		conf.Addresses.Swarm = append(conf.Addresses.Swarm, defaultQUICAddrs...)
+	} else if idOpts.importFile != "" {
+		ident, err := identityConfig(out, idOpts)
 		if err != nil {
 			return err
 		}
+		conf.Identity = ident
 	}
 
+	// the profiles set the defaults of the Init section of the config,
+	// and --empty-repo overrides both
+	var initCfg extconfig.Init
 	for _, profile := range confProfiles {
-		transformer, ok := config.Profiles[profile]
+		transformer, ok := extconfig.Profiles[profile]
 		if !ok {
 			return fmt.Errorf("invalid configuration profile: %s", profile)
 		}
@@ -150,13 +232,24 @@ func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, con
 		if err := transformer.Transform(conf); err != nil {
 			return err
 		}
+		initCfg.Merge(extconfig.InitProfiles[profile])
+	}
+	initCfg.Merge(confInit)
+	if empty {
+		initCfg.EmptyRepo = extconfig.True
 	}
 
 	if err := fsrepo.Init(repoRoot, conf); err != nil {
 		return err
 	}
 
-	if !empty {
+	switch {
+	case initCfg.EmptyRepo.WithDefault(false):
+	case initCfg.SeedCAR != "":
+		if err := seedRepo(out, repoRoot, initCfg.SeedCAR); err != nil {
+			return err
+		}
+	default:
 		if err := addDefaultAssets(out, repoRoot); err != nil {
 			return err
 		}
@@ -222,6 +315,53 @@ func addDefaultAssets(out io.Writer, repoRoot string) error {
 	return err
 }
 
+// seedRepo adds the blocks of the CAR file carPath to the repo, and pins its
+// roots.
+func seedRepo(out io.Writer, repoRoot, carPath string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f, err := os.Open(carPath)
+	if err != nil {
+		return fmt.Errorf("init: seeding the repo failed: %s", err)
+	}
+	defer f.Close()
+
+	r, err := fsrepo.Open(repoRoot)
+	if err != nil { // NB: repo is owned by the node
+		return err
+	}
+
+	nd, err := core.NewNode(ctx, &core.BuildCfg{Repo: r})
+	if err != nil {
+		return err
+	}
+	defer nd.Close()
+
+	roots, _, err := corerepo.ImportCar(ctx, nd.Blockstore, f)
+	if err != nil {
+		return fmt.Errorf("init: seeding the repo from %s failed: %s", carPath, err)
+	}
+	if len(roots) == 0 {
+		return fmt.Errorf("init: %s has no roots to seed the repo with", carPath)
+	}
+
+	for _, c := range roots {
+		root, err := nd.DAG.Get(ctx, c)
+		if err != nil {
+			return fmt.Errorf("init: seeding the repo from %s failed: %s", carPath, err)
+		}
+		// the node is offline: the DAG of the root must be in the file
+		if err := nd.Pinning.Pin(ctx, root, true); err != nil {
+			return fmt.Errorf("init: pinning %s from %s failed: %s", c, carPath, err)
+		}
+		if _, err := fmt.Fprintf(out, "seeded the repo with %s\n", c); err != nil {
+			return err
+		}
+	}
+	return nd.Pinning.Flush()
+}
+
 func initializeIpnsKeyspace(repoRoot string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
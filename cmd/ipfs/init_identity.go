@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	ci "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	"gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+)
+
+// The algorithms of the identities generated by init.
+const (
+	algorithmRSA       = "rsa"
+	algorithmEd25519   = "ed25519"
+	algorithmSecp256k1 = "secp256k1"
+
+	algorithmDefault = algorithmEd25519
+)
+
+// identityOptions selects the identity of a new repo: a key generated with
+// algorithm, or the key read from importFile.
+type identityOptions struct {
+	algorithm  string
+	bits       int
+	importFile string
+}
+
+// newConfig returns the default config with the identity selected by opts.
+func newConfig(out io.Writer, opts identityOptions) (*config.Config, error) {
+	if opts.importFile == "" && opts.algorithm == algorithmRSA {
+		return config.Init(out, opts.bits)
+	}
+
+	// config.Init only generates RSA identities: the smallest one it allows
+	// is generated and replaced.
+	conf, err := config.Init(ioutil.Discard, 1024)
+	if err != nil {
+		return nil, err
+	}
+	ident, err := identityConfig(out, opts)
+	if err != nil {
+		return nil, err
+	}
+	conf.Identity = ident
+	return conf, nil
+}
+
+// identityConfig generates or imports the identity selected by opts.
+func identityConfig(out io.Writer, opts identityOptions) (config.Identity, error) {
+	var sk ci.PrivKey
+	if opts.importFile != "" {
+		fmt.Fprintf(out, "importing identity from %s...", opts.importFile)
+		k, err := readIdentityKey(opts.importFile)
+		if err != nil {
+			return config.Identity{}, err
+		}
+		sk = k
+	} else {
+		var typ int
+		switch opts.algorithm {
+		case algorithmRSA:
+			typ = ci.RSA
+			fmt.Fprintf(out, "generating %d-bit RSA keypair...", opts.bits)
+		case algorithmEd25519:
+			typ = ci.Ed25519
+			fmt.Fprintf(out, "generating ed25519 keypair...")
+		case algorithmSecp256k1:
+			typ = ci.Secp256k1
+			fmt.Fprintf(out, "generating secp256k1 keypair...")
+		default:
+			return config.Identity{}, fmt.Errorf("unknown key algorithm %q, must be %q, %q or %q",
+				opts.algorithm, algorithmEd25519, algorithmSecp256k1, algorithmRSA)
+		}
+		k, _, err := ci.GenerateKeyPairWithReader(typ, opts.bits, rand.Reader)
+		if err != nil {
+			return config.Identity{}, err
+		}
+		sk = k
+	}
+	fmt.Fprintf(out, "done\n")
+
+	skbytes, err := sk.Bytes()
+	if err != nil {
+		return config.Identity{}, err
+	}
+	id, err := peer.IDFromPublicKey(sk.GetPublic())
+	if err != nil {
+		return config.Identity{}, err
+	}
+	fmt.Fprintf(out, "peer identity: %s\n", id.Pretty())
+
+	return config.Identity{
+		PeerID:  id.Pretty(),
+		PrivKey: base64.StdEncoding.EncodeToString(skbytes),
+	}, nil
+}
+
+// readIdentityKey reads a private key from fname, either marshalled like the
+// keys of the keystore, or encoded in base64 like Identity.PrivKey in the
+// config.
+func readIdentityKey(fname string) (ci.PrivKey, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data))); err == nil {
+		if sk, err := ci.UnmarshalPrivateKey(b); err == nil {
+			return sk, nil
+		}
+	}
+	sk, err := ci.UnmarshalPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a private key: %s", fname, err)
+	}
+	return sk, nil
+}
@@ -57,6 +57,10 @@ type cmdDetails struct {
 	// preemptsAutoUpdate describes commands that must be executed without the
 	// auto-update pre-command hook
 	preemptsAutoUpdate bool
+
+	// onlyReadsRepo describes commands that don't modify the repo. Run with
+	// --local while the daemon owns the repo, they open it read-only.
+	onlyReadsRepo bool
 }
 
 func (d *cmdDetails) String() string {
@@ -71,6 +75,7 @@ func (d *cmdDetails) Loggable() map[string]interface{} {
 		"preemptsAutoUpdate": d.preemptsAutoUpdate,
 		"usesConfigAsInput":  d.usesConfigAsInput(),
 		"usesRepo":           d.usesRepo(),
+		"onlyReadsRepo":      d.onlyReadsRepo,
 	}
 }
 
@@ -84,12 +89,25 @@ func (d *cmdDetails) usesRepo() bool          { return !d.doesNotUseRepo }
 // properties so that other code can make decisions about whether to invoke a
 // command or return an error to the user.
 var cmdDetailsMap = map[string]cmdDetails{
-	"init":        {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
-	"daemon":      {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
-	"commands":    {doesNotUseRepo: true},
-	"version":     {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
-	"log":         {cannotRunOnClient: true},
-	"diag/cmds":   {cannotRunOnClient: true},
-	"repo/fsck":   {cannotRunOnDaemon: true},
-	"config/edit": {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"init":                   {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"daemon":                 {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
+	"commands":               {doesNotUseRepo: true},
+	"version":                {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
+	"version/check":          {},
+	"log":                    {cannotRunOnClient: true},
+	"diag/cmds":              {cannotRunOnClient: true},
+	"diag/profile":           {cannotRunOnClient: true},
+	"repo/fsck":              {cannotRunOnDaemon: true},
+	"repo/migrate-datastore": {cannotRunOnDaemon: true},
+	"repo/restore":           {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"config/edit":            {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"refs/local":             {onlyReadsRepo: true},
+	"files/ls":               {onlyReadsRepo: true},
+	"files/stat":             {onlyReadsRepo: true},
+	"files/read":             {onlyReadsRepo: true},
+	"pin/ls":                 {onlyReadsRepo: true},
+	"block/get":              {onlyReadsRepo: true},
+	"block/stat":             {onlyReadsRepo: true},
+	"repo/stat":              {onlyReadsRepo: true},
+	"repo/dedupe-stats":      {onlyReadsRepo: true},
 }
@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
+	gohttp "net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime/pprof"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -49,6 +52,10 @@ const (
 	EnvEnableProfiling = "IPFS_PROF"
 	cpuProfile         = "ipfs.cpuprof"
 	heapProfile        = "ipfs.memprof"
+
+	// EnvAPIToken holds the bearer token sent to the API, for the daemons
+	// configured with API.Authorizations.
+	EnvAPIToken = "IPFS_API_TOKEN"
 )
 
 // main roadmap:
@@ -95,6 +102,10 @@ func mainRet() int {
 	// so we need to make sure it's stable
 	os.Args[0] = "ipfs"
 
+	// plugins are loaded before parsing the command line, which may use
+	// their commands
+	loadPlugins(os.Args[1:])
+
 	buildEnv := func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
 		checkDebug(req)
 		repoPath, err := getRepoPath(req)
@@ -114,7 +125,7 @@ func mainRet() int {
 					return nil, errors.New("constructing node without a request")
 				}
 
-				r, err := fsrepo.Open(repoPath)
+				r, err := openRepo(req, repoPath)
 				if err != nil { // repo is owned by the node
 					return nil, err
 				}
@@ -166,24 +177,44 @@ func makeExecutor(req *cmds.Request, env interface{}) (cmds.Executor, error) {
 	if client != nil && !req.Command.External {
 		exctr = client.(cmds.Executor)
 	} else {
-		cctx := env.(*oldcmds.Context)
-		pluginpath := filepath.Join(cctx.ConfigRoot, "plugins")
+		exctr = cmds.NewExecutor(req.Root)
+	}
 
-		// check if repo is accessible before loading plugins
-		ok, err := checkPermissions(cctx.ConfigRoot)
-		if err != nil {
-			return nil, err
+	return exctr, nil
+}
+
+// loadPlugins loads the plugins of the repo given by the --config option of
+// args, or of the default repo. The plugins aren't loaded when the repo
+// can't be read, the commands using it report the error.
+func loadPlugins(args []string) {
+	repoPath := ""
+	for i, arg := range args {
+		if arg == "--" {
+			break
 		}
-		if ok {
-			if _, err := loader.LoadPlugins(pluginpath); err != nil {
-				log.Error("error loading plugins: ", err)
+		for _, opt := range []string{"-c", "--config"} {
+			if arg == opt && i+1 < len(args) {
+				repoPath = args[i+1]
+			} else if strings.HasPrefix(arg, opt+"=") {
+				repoPath = strings.TrimPrefix(arg, opt+"=")
 			}
 		}
-
-		exctr = cmds.NewExecutor(req.Root)
+	}
+	if repoPath == "" {
+		var err error
+		repoPath, err = fsrepo.BestKnownPath()
+		if err != nil {
+			return
+		}
 	}
 
-	return exctr, nil
+	// check if repo is accessible before loading plugins
+	if ok, _ := checkPermissions(repoPath); !ok {
+		return
+	}
+	if _, err := loader.LoadPlugins(filepath.Join(repoPath, "plugins")); err != nil {
+		log.Error("error loading plugins: ", err)
+	}
 }
 
 func checkPermissions(path string) (bool, error) {
@@ -233,6 +264,11 @@ func commandShouldRunOnDaemon(details cmdDetails, req *cmds.Request, cctx *oldcm
 		return nil, nil
 	}
 
+	// the repo is opened read-only if the daemon owns it
+	if local, _ := req.Options["local"].(bool); local && details.onlyReadsRepo {
+		return nil, nil
+	}
+
 	// at this point need to know whether api is running. we defer
 	// to this point so that we don't check unnecessarily
 
@@ -272,6 +308,18 @@ func commandShouldRunOnDaemon(details cmdDetails, req *cmds.Request, cctx *oldcm
 	return nil, nil
 }
 
+// openRepo opens the repo at repoPath for req, read-only when the command
+// only reads it and another process, such as the daemon, owns it.
+func openRepo(req *cmds.Request, repoPath string) (repo.Repo, error) {
+	if commandDetails(req.Path).onlyReadsRepo {
+		if locked, _ := fsrepo.LockedByOtherProcess(repoPath); locked {
+			log.Debugf("repo locked by another process, opening it read-only")
+			return fsrepo.OpenReadOnly(repoPath)
+		}
+	}
+	return fsrepo.Open(repoPath)
+}
+
 func getRepoPath(req *cmds.Request) (string, error) {
 	repoOpt, found := req.Options["config"].(string)
 	if found && repoOpt != "" {
@@ -344,36 +392,45 @@ func (ih *IntrHandler) Close() error {
 
 // Handle starts handling the given signals, and will call the handler
 // callback function each time a signal is catched. The function is passed
-// the number of times the handler has been triggered in total, as
-// well as the handler itself, so that the handling logic can use the
+// the number of times the handler has been triggered in total, the signal,
+// as well as the handler itself, so that the handling logic can use the
 // handler's wait group to ensure clean shutdown when Close() is called.
-func (ih *IntrHandler) Handle(handler func(count int, ih *IntrHandler), sigs ...os.Signal) {
+func (ih *IntrHandler) Handle(handler func(count int, sig os.Signal, ih *IntrHandler), sigs ...os.Signal) {
 	signal.Notify(ih.sig, sigs...)
 	ih.wg.Add(1)
 	go func() {
 		defer ih.wg.Done()
 		count := 0
-		for range ih.sig {
+		for sig := range ih.sig {
 			count++
-			handler(count, ih)
+			handler(count, sig, ih)
 		}
 		signal.Stop(ih.sig)
 	}()
 }
 
+// onTerminate, when set, handles the first SIGTERM instead of canceling the
+// context of the command. The daemon sets it to drain before shutting down.
+var onTerminate atomic.Value
+
 func setupInterruptHandler(ctx context.Context) (io.Closer, context.Context) {
 	intrh := NewIntrHandler()
 	ctx, cancelFunc := context.WithCancel(ctx)
 
-	handlerFunc := func(count int, ih *IntrHandler) {
+	handlerFunc := func(count int, sig os.Signal, ih *IntrHandler) {
 		switch count {
 		case 1:
 			fmt.Println() // Prevent un-terminated ^C character in terminal
 
+			stop := cancelFunc
+			if f, ok := onTerminate.Load().(func()); ok && sig == syscall.SIGTERM {
+				stop = f
+			}
+
 			ih.wg.Add(1)
 			go func() {
 				defer ih.wg.Done()
-				cancelFunc()
+				stop()
 			}()
 
 		default:
@@ -448,17 +505,58 @@ func getAPIClient(ctx context.Context, repoPath, apiAddrStr string) (http.Client
 }
 
 func apiClientForAddr(ctx context.Context, addr ma.Multiaddr) (http.Client, error) {
-	addr, err := resolveAddr(ctx, addr)
-	if err != nil {
-		return nil, err
+	var host string
+	var rt gohttp.RoundTripper
+	if sockPath, ok := corehttp.UnixSocketPath(addr); ok {
+		// the host of the requests is unused, they all go to the socket
+		host = "unix"
+		rt = &gohttp.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sockPath)
+			},
+		}
+	} else {
+		addr, err := resolveAddr(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		_, host, err = manet.DialArgs(addr)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	_, host, err := manet.DialArgs(addr)
-	if err != nil {
-		return nil, err
+	if token := os.Getenv(EnvAPIToken); token != "" {
+		if rt == nil {
+			rt = gohttp.DefaultTransport
+		}
+		rt = &tokenTransport{token: token, rt: rt}
 	}
 
-	return http.NewClient(host, http.ClientWithAPIPrefix(corehttp.APIPath)), nil
+	opts := []http.ClientOpt{http.ClientWithAPIPrefix(corehttp.APIPath)}
+	if rt != nil {
+		opts = append(opts, http.ClientWithHTTPClient(&gohttp.Client{Transport: rt}))
+	}
+	return http.NewClient(host, opts...), nil
+}
+
+// tokenTransport adds the API token to the requests.
+type tokenTransport struct {
+	token string
+	rt    gohttp.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(r *gohttp.Request) (*gohttp.Response, error) {
+	r2 := new(gohttp.Request)
+	*r2 = *r
+	r2.Header = make(gohttp.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		r2.Header[k] = v
+	}
+	r2.Header.Set("Authorization", "Bearer "+t.token)
+	return t.rt.RoundTrip(r2)
 }
 
 func resolveAddr(ctx context.Context, addr ma.Multiaddr) (ma.Multiaddr, error) {
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	core "github.com/ipfs/go-ipfs/core"
+	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
+)
+
+// setupWebUI returns the path of the WebUI the API serves, after importing
+// and pinning the bundle of the config.
+func setupWebUI(ctx context.Context, node *core.IpfsNode, cfg *extconfig.WebUI) (string, error) {
+	p := cfg.Path
+	if p != "" {
+		if _, err := path.ParsePath(p); err != nil {
+			return "", fmt.Errorf("invalid WebUI.Path: %s", err)
+		}
+	}
+
+	if cfg.Bundle != "" {
+		root, err := importWebUIBundle(ctx, node, cfg.Bundle)
+		if err != nil {
+			return "", fmt.Errorf("importing the WebUI bundle %s failed: %s", cfg.Bundle, err)
+		}
+		if p == "" {
+			p = "/ipfs/" + root
+		}
+	}
+
+	if p == "" {
+		p = corehttp.WebUIPath
+	}
+	return p, nil
+}
+
+// importWebUIBundle adds the blocks of the CAR file fname and pins its root.
+func importWebUIBundle(ctx context.Context, node *core.IpfsNode, fname string) (string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	defer node.Blockstore.PinLock().Unlock()
+
+	roots, _, err := corerepo.ImportCar(ctx, node.Blockstore, f)
+	if err != nil {
+		return "", err
+	}
+	if len(roots) != 1 {
+		return "", fmt.Errorf("the bundle has %d roots, expected one", len(roots))
+	}
+
+	root, err := node.DAG.Get(ctx, roots[0])
+	if err != nil {
+		return "", err
+	}
+	if err := node.Pinning.Pin(ctx, root, true); err != nil {
+		return "", err
+	}
+	if err := node.Pinning.Flush(); err != nil {
+		return "", err
+	}
+	return roots[0].String(), nil
+}
+
+// webuiPaths returns the paths the API serves without --unrestricted-api:
+// the past WebUIs and the one served.
+func webuiPaths(current string) []string {
+	for _, p := range corehttp.WebUIPaths {
+		if p == current {
+			return corehttp.WebUIPaths
+		}
+	}
+	return append([]string{current}, corehttp.WebUIPaths...)
+}
@@ -27,6 +27,8 @@ type Context struct {
 	api           coreiface.CoreAPI
 	node          *core.IpfsNode
 	ConstructNode func() (*core.IpfsNode, error)
+
+	ctx context.Context
 }
 
 // GetConfig returns the config of the current Command execution
@@ -42,6 +44,11 @@ func (c *Context) GetConfig() (*config.Config, error) {
 	return c.config, err
 }
 
+// SetConfig replaces the config of the current Command execution context.
+func (c *Context) SetConfig(cfg *config.Config) {
+	c.config = cfg
+}
+
 // GetNode returns the node of the current Command execution
 // context. It may construct it with the provided function.
 func (c *Context) GetNode() (*core.IpfsNode, error) {
@@ -68,8 +75,12 @@ func (c *Context) GetApi() (coreiface.CoreAPI, error) {
 	return c.api, nil
 }
 
-// Context returns the node's context.
+// Context returns the node's context, or the one set by WithContext.
 func (c *Context) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+
 	n, err := c.GetNode()
 	if err != nil {
 		log.Debug("error getting node: ", err)
@@ -79,6 +90,15 @@ func (c *Context) Context() context.Context {
 	return n.Context()
 }
 
+// WithContext returns a copy of c whose Context method returns ctx, so that
+// the commands run with the values of ctx. ctx should be derived from the
+// node's context.
+func (c *Context) WithContext(ctx context.Context) *Context {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
 // LogRequest adds the passed request to the request log and
 // returns a function that should be called when the request
 // lifetime is over.
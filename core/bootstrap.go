@@ -51,6 +51,10 @@ type BootstrapConfig struct {
 	// for the bootstrap process to use. This makes it possible for clients
 	// to control the peers the process uses at any moment.
 	BootstrapPeers func() []pstore.PeerInfo
+
+	// Health records the dials of the bootstrap peers, the dead ones are
+	// dialed last. Nil disables the tracking.
+	Health *BootstrapHealth
 }
 
 // DefaultBootstrapConfig specifies default sane parameters for bootstrapping.
@@ -146,15 +150,15 @@ func bootstrapRound(ctx context.Context, host host.Host, cfg BootstrapConfig) er
 		return ErrNotEnoughBootstrapPeers
 	}
 
-	// connect to a random susbset of bootstrap candidates
-	randSubset := randomSubsetOfPeers(notConnected, numToDial)
+	// connect to a random susbset of bootstrap candidates, healthy first
+	randSubset := cfg.Health.subset(notConnected, numToDial)
 
 	defer log.EventBegin(ctx, "bootstrapStart", id).Done()
 	log.Debugf("%s bootstrapping to %d nodes: %s", id, numToDial, randSubset)
-	return bootstrapConnect(ctx, host, randSubset)
+	return bootstrapConnect(ctx, host, randSubset, cfg.Health)
 }
 
-func bootstrapConnect(ctx context.Context, ph host.Host, peers []pstore.PeerInfo) error {
+func bootstrapConnect(ctx context.Context, ph host.Host, peers []pstore.PeerInfo, health *BootstrapHealth) error {
 	if len(peers) < 1 {
 		return ErrNotEnoughBootstrapPeers
 	}
@@ -175,7 +179,10 @@ func bootstrapConnect(ctx context.Context, ph host.Host, peers []pstore.PeerInfo
 			log.Debugf("%s bootstrapping to %s", ph.ID(), p.ID)
 
 			ph.Peerstore().AddAddrs(p.ID, p.Addrs, pstore.PermanentAddrTTL)
-			if err := ph.Connect(ctx, p); err != nil {
+			start := time.Now()
+			err := ph.Connect(ctx, p)
+			health.Record(p.ID, time.Since(start), err)
+			if err != nil {
 				log.Event(ctx, "bootstrapDialFailed", p.ID)
 				log.Debugf("failed to bootstrap with %v: %s", p.ID, err)
 				errs <- err
@@ -0,0 +1,255 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	swarm "gx/ipfs/QmeDpqUwwdye8ABKVMPXKuWwPVURFdqTqssbTUB39E2Nwd/go-libp2p-swarm"
+)
+
+// DefaultBootstrapMaxFailures is the number of consecutive failed dials
+// after which a bootstrap peer is considered dead, when
+// BootstrapCheck.MaxFailures is unset.
+const DefaultBootstrapMaxFailures = 3
+
+// BootstrapPeerHealth is the dial history of a bootstrap peer.
+type BootstrapPeerHealth struct {
+	ID peer.ID
+
+	// Failures is the number of consecutive failed dials.
+	Failures    int
+	LastSuccess time.Time
+	LastFailure time.Time
+
+	// Latency is the duration of the last successful dial.
+	Latency time.Duration
+	// Error is the error of the last failed dial.
+	Error string `json:",omitempty"`
+}
+
+// BootstrapHealth records the dials of the bootstrap peers, so that the
+// dead ones are dialed after the healthy ones. A nil BootstrapHealth records
+// nothing.
+type BootstrapHealth struct {
+	lk          sync.Mutex
+	maxFailures int
+	peers       map[peer.ID]*BootstrapPeerHealth
+}
+
+// NewBootstrapHealth returns a BootstrapHealth considering the peers dead
+// after maxFailures consecutive failed dials.
+func NewBootstrapHealth(maxFailures int) *BootstrapHealth {
+	if maxFailures <= 0 {
+		maxFailures = DefaultBootstrapMaxFailures
+	}
+	return &BootstrapHealth{
+		maxFailures: maxFailures,
+		peers:       make(map[peer.ID]*BootstrapPeerHealth),
+	}
+}
+
+// Record records the result of a dial of the bootstrap peer id.
+func (h *BootstrapHealth) Record(id peer.ID, latency time.Duration, err error) {
+	if h == nil {
+		return
+	}
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	p, ok := h.peers[id]
+	if !ok {
+		p = &BootstrapPeerHealth{ID: id}
+		h.peers[id] = p
+	}
+	if err != nil {
+		p.Failures++
+		p.LastFailure = time.Now()
+		p.Error = err.Error()
+		return
+	}
+	p.Failures = 0
+	p.LastSuccess = time.Now()
+	p.Latency = latency
+	p.Error = ""
+}
+
+// Get returns the dial history of the bootstrap peer id.
+func (h *BootstrapHealth) Get(id peer.ID) (BootstrapPeerHealth, bool) {
+	if h == nil {
+		return BootstrapPeerHealth{}, false
+	}
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	p, ok := h.peers[id]
+	if !ok {
+		return BootstrapPeerHealth{ID: id}, false
+	}
+	return *p, true
+}
+
+// Dead returns whether the last dials of the bootstrap peer id all failed.
+func (h *BootstrapHealth) Dead(id peer.ID) bool {
+	p, _ := h.Get(id)
+	return h != nil && p.Failures >= h.maxFailures
+}
+
+// subset returns a random subset of at most max peers. The dead peers are
+// only picked when there aren't enough healthy ones.
+func (h *BootstrapHealth) subset(in []pstore.PeerInfo, max int) []pstore.PeerInfo {
+	var healthy, dead []pstore.PeerInfo
+	for _, p := range in {
+		if h.Dead(p.ID) {
+			dead = append(dead, p)
+		} else {
+			healthy = append(healthy, p)
+		}
+	}
+
+	out := randomSubsetOfPeers(healthy, max)
+	if len(out) < max && len(dead) > 0 {
+		log.Debugf("dialing %d dead bootstrap peers", max-len(out))
+		out = append(out, randomSubsetOfPeers(dead, max-len(out))...)
+	}
+	return out
+}
+
+// BootstrapCheck is the result of the check of a bootstrap peer.
+type BootstrapCheck struct {
+	ID    peer.ID
+	Addrs []string
+
+	// Connected is set when the node was already connected to the peer,
+	// which wasn't dialed again. Latency is then the latency measured on
+	// the connection.
+	Connected bool
+	Reachable bool
+	Latency   time.Duration
+	Error     string `json:",omitempty"`
+
+	// Failures is the number of consecutive failed dials of the peer, and
+	// Dead whether the peer is dialed last when bootstrapping.
+	Failures int
+	Dead     bool
+}
+
+// CheckBootstrapPeers dials the bootstrap peers the node isn't connected to,
+// each dial bounded by timeout, and records the results in the bootstrap
+// health. The results are sorted by peer ID.
+func (n *IpfsNode) CheckBootstrapPeers(ctx context.Context, timeout time.Duration) ([]BootstrapCheck, error) {
+	peers, err := n.loadBootstrapPeers()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BootstrapCheck, len(peers))
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p pstore.PeerInfo) {
+			defer wg.Done()
+			results[i] = n.checkBootstrapPeer(ctx, p, timeout)
+		}(i, p)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+	return results, nil
+}
+
+func (n *IpfsNode) checkBootstrapPeer(ctx context.Context, p pstore.PeerInfo, timeout time.Duration) BootstrapCheck {
+	c := BootstrapCheck{ID: p.ID, Addrs: make([]string, len(p.Addrs))}
+	for i, a := range p.Addrs {
+		c.Addrs[i] = a.String()
+	}
+
+	var err error
+	if n.PeerHost.Network().Connectedness(p.ID) == inet.Connected {
+		c.Connected = true
+		c.Latency = n.Peerstore.LatencyEWMA(p.ID)
+	} else {
+		// the check must not be skipped because of an earlier failure
+		if swrm, ok := n.PeerHost.Network().(*swarm.Swarm); ok {
+			swrm.Backoff().Clear(p.ID)
+		}
+		n.Peerstore.AddAddrs(p.ID, p.Addrs, pstore.PermanentAddrTTL)
+
+		dctx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err = n.PeerHost.Connect(dctx, p)
+		cancel()
+		if err != nil {
+			c.Error = err.Error()
+		} else {
+			c.Latency = time.Since(start)
+		}
+	}
+	c.Reachable = err == nil
+	n.BootstrapHealth.Record(p.ID, c.Latency, err)
+
+	h, _ := n.BootstrapHealth.Get(p.ID)
+	c.Failures = h.Failures
+	c.Dead = n.BootstrapHealth.Dead(p.ID)
+	return c
+}
+
+// startBootstrapCheck sets up the bootstrap health, and checks the
+// bootstrap peers periodically when BootstrapCheck.Enabled is set.
+func (n *IpfsNode) startBootstrapCheck() error {
+	cfg, err := extconfig.LoadBootstrapCheck(n.Repo)
+	if err != nil {
+		return err
+	}
+
+	n.BootstrapHealth = NewBootstrapHealth(cfg.MaxFailures)
+	if !cfg.Enabled.WithDefault(false) {
+		return nil
+	}
+
+	interval := extconfig.DefaultBootstrapCheckInterval
+	if cfg.Interval != "" {
+		interval = cfg.Interval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("parsing BootstrapCheck.Interval: %s", err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("BootstrapCheck.Interval must be positive, got %s", interval)
+	}
+
+	go n.bootstrapCheckLoop(d)
+	return nil
+}
+
+func (n *IpfsNode) bootstrapCheckLoop(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			results, err := n.CheckBootstrapPeers(n.Context(), DefaultBootstrapConfig.ConnectionTimeout)
+			if err != nil {
+				log.Warningf("checking the bootstrap peers: %s", err)
+				continue
+			}
+			for _, c := range results {
+				if c.Dead {
+					log.Warningf("bootstrap peer %s failed the last %d dials, consider removing it with 'ipfs bootstrap rm': %s",
+						c.ID.Pretty(), c.Failures, c.Error)
+				}
+			}
+		case <-n.Process().Closing():
+			return
+		}
+	}
+}
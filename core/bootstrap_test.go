@@ -1,8 +1,10 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	testutil "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
 	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
@@ -54,3 +56,47 @@ func TestMultipleAddrsPerPeer(t *testing.T) {
 		t.Fatal("expected fewer peers")
 	}
 }
+
+func TestBootstrapHealthDialsDeadPeersLast(t *testing.T) {
+	var ps []pstore.PeerInfo
+	for i := 0; i < 4; i++ {
+		pid, err := testutil.RandPeerID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps = append(ps, pstore.PeerInfo{ID: pid})
+	}
+
+	h := NewBootstrapHealth(2)
+	dead := ps[0].ID
+	h.Record(dead, 0, errors.New("dial failed"))
+	if h.Dead(dead) {
+		t.Fatal("peer dead after a single failure")
+	}
+	h.Record(dead, 0, errors.New("dial failed"))
+	if !h.Dead(dead) {
+		t.Fatal("peer not dead after two failures")
+	}
+
+	for i := 0; i < 20; i++ {
+		for _, p := range h.subset(ps, 3) {
+			if p.ID == dead {
+				t.Fatal("dead peer picked before the healthy ones")
+			}
+		}
+	}
+	if out := h.subset(ps, 4); len(out) != 4 {
+		t.Fatalf("expected the dead peer to fill the subset, got %d peers", len(out))
+	}
+
+	h.Record(dead, time.Millisecond, nil)
+	if h.Dead(dead) {
+		t.Fatal("peer still dead after a successful dial")
+	}
+
+	var nilHealth *BootstrapHealth
+	nilHealth.Record(dead, 0, errors.New("dial failed"))
+	if out := nilHealth.subset(ps, 2); len(out) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(out))
+	}
+}
@@ -5,13 +5,19 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
 	"syscall"
 	"time"
 
+	blockcache "github.com/ipfs/go-ipfs/blockcache"
+	blockpolicy "github.com/ipfs/go-ipfs/blockpolicy"
+	diskio "github.com/ipfs/go-ipfs/diskio"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	pin "github.com/ipfs/go-ipfs/pin"
 	repo "github.com/ipfs/go-ipfs/repo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 	cidv0v1 "github.com/ipfs/go-ipfs/thirdparty/cidv0v1"
 	"github.com/ipfs/go-ipfs/thirdparty/verifbs"
 	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
@@ -31,7 +37,6 @@ import (
 	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
 	record "gx/ipfs/QmdHb9aBELnQKTVhvvA3hsQbRgUAwsWUzBP2vZ6Y5FBYvE/go-libp2p-record"
 	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
-	pstoremem "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore/pstoremem"
 	p2phost "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
 	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
 	metrics "gx/ipfs/QmekzFM3hPZjTjUFGTABdQkEnQ3PTiMstY198PwSFr5w1Q/go-metrics-interface"
@@ -58,6 +63,16 @@ type BuildCfg struct {
 	Routing RoutingOption
 	Host    HostOption
 	Repo    repo.Repo
+
+	// Peerstore constructs the peerstore, DefaultPeerstoreOption if nil.
+	Peerstore PeerstoreOption
+
+	// Blockstore wraps the blockstore built on the repo, if not nil.
+	Blockstore BlockstoreOption
+
+	// Exchange constructs the exchange of an online node, BitswapOption
+	// if nil.
+	Exchange ExchangeOption
 }
 
 func (cfg *BuildCfg) getOpt(key string) bool {
@@ -95,6 +110,14 @@ func (cfg *BuildCfg) fillDefaults() error {
 		cfg.Host = DefaultHostOption
 	}
 
+	if cfg.Peerstore == nil {
+		cfg.Peerstore = DefaultPeerstoreOption
+	}
+
+	if cfg.Exchange == nil {
+		cfg.Exchange = BitswapOption
+	}
+
 	return nil
 }
 
@@ -126,7 +149,8 @@ func defaultRepo(dstore repo.Datastore) (repo.Repo, error) {
 	}, nil
 }
 
-// NewNode constructs and returns an IpfsNode using the given cfg.
+// NewNode constructs and returns an IpfsNode using the given cfg. See New
+// for the order the subsystems are assembled in.
 func NewNode(ctx context.Context, cfg *BuildCfg) (*IpfsNode, error) {
 	if cfg == nil {
 		cfg = new(BuildCfg)
@@ -139,11 +163,16 @@ func NewNode(ctx context.Context, cfg *BuildCfg) (*IpfsNode, error) {
 
 	ctx = metrics.CtxScope(ctx, "ipfs")
 
+	ps, err := cfg.Peerstore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	n := &IpfsNode{
 		mode:      offlineMode,
 		Repo:      cfg.Repo,
 		ctx:       ctx,
-		Peerstore: pstoremem.NewPeerstore(),
+		Peerstore: ps,
 	}
 
 	n.RecordValidator = record.NamespacedValidator{
@@ -166,6 +195,27 @@ func NewNode(ctx context.Context, cfg *BuildCfg) (*IpfsNode, error) {
 	return n, nil
 }
 
+// setupPinBatching replays the pin changes journaled before the node
+// stopped, and coalesces the writes of the pinset per Pinning.FlushInterval.
+func (n *IpfsNode) setupPinBatching(d ds.Datastore) error {
+	if err := pin.ReplayJournal(n.Pinning, d); err != nil {
+		return fmt.Errorf("cannot replay the pin journal: %v", err)
+	}
+
+	cfg, err := extconfig.LoadPinning(n.Repo)
+	if err != nil {
+		return err
+	}
+	interval, err := cfg.FlushIntervalDuration()
+	if err != nil {
+		return err
+	}
+	if interval > 0 {
+		n.Pinning, err = pin.NewBatchingPinner(n.Pinning, d, n.GCLocker, interval)
+	}
+	return err
+}
+
 func isTooManyFDError(err error) bool {
 	perr, ok := err.(*os.PathError)
 	if ok && perr.Err == syscall.EMFILE {
@@ -207,16 +257,38 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 	}
 
 	if !cfg.NilRepo {
-		bs, err = bstore.CachedBlockstore(ctx, bs, opts)
+		bcfg, err := extconfig.LoadBlockCache(n.Repo)
 		if err != nil {
 			return err
 		}
+		switch {
+		case bcfg.ARCCacheSize < 0:
+			opts.HasARCCacheSize = 0
+		case bcfg.ARCCacheSize > 0:
+			opts.HasARCCacheSize = bcfg.ARCCacheSize
+		}
+		if bcfg.BloomFilterHashes > 0 {
+			opts.HasBloomFilterHashes = bcfg.BloomFilterHashes
+		}
+
+		n.BlockCache, err = blockcache.New(ctx, bs, opts)
+		if err != nil {
+			return err
+		}
+		bs = n.BlockCache.Blockstore()
 	}
 
 	bs = bstore.NewIdStore(bs)
 
 	bs = cidv0v1.NewBlockstore(bs)
 
+	if cfg.Blockstore != nil {
+		bs, err = cfg.Blockstore(ctx, bs)
+		if err != nil {
+			return err
+		}
+	}
+
 	n.BaseBlocks = bs
 	n.GCLocker = bstore.NewGCLocker()
 	n.Blockstore = bstore.NewGCBlockstore(bs, n.GCLocker)
@@ -228,6 +300,19 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		n.Blockstore = &verifbs.VerifBSGC{GCBlockstore: n.Blockstore}
 	}
 
+	n.DiskIO = diskio.NewAccountant()
+	n.Blockstore = diskio.GCBlockstore(n.Blockstore, n.DiskIO.Subsystem(diskio.Total))
+
+	bpcfg, err := extconfig.LoadBlockPolicy(n.Repo)
+	if err != nil {
+		return err
+	}
+	policy, err := blockpolicy.New(bpcfg)
+	if err != nil {
+		return err
+	}
+	n.Blockstore = blockpolicy.GCBlockstore(n.Blockstore, policy)
+
 	rcfg, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -237,6 +322,10 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		bs.HashOnRead(true)
 	}
 
+	if err := n.loadDenylist(); err != nil {
+		return err
+	}
+
 	hostOption := cfg.Host
 	if cfg.DisableEncryptedConnections {
 		innerHostOption := hostOption
@@ -248,25 +337,35 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 	}
 
 	if cfg.Online {
-		do := setupDiscoveryOption(rcfg.Discovery)
-		if err := n.startOnlineServices(ctx, cfg.Routing, hostOption, do, cfg.getOpt("pubsub"), cfg.getOpt("ipnsps"), cfg.getOpt("mplex")); err != nil {
+		mdns, err := extconfig.LoadMDNS(n.Repo)
+		if err != nil {
+			return err
+		}
+		do := n.setupDiscoveryOption(rcfg.Discovery, mdns.ServiceTag)
+		if err := n.startOnlineServices(ctx, cfg.Routing, hostOption, cfg.Exchange, do, cfg.getOpt("pubsub"), cfg.getOpt("ipnsps"), cfg.getOpt("mplex")); err != nil {
 			return err
 		}
 	} else {
 		n.Exchange = offline.Exchange(n.Blockstore)
 	}
 
-	n.Blocks = bserv.New(n.Blockstore, n.Exchange)
+	n.Blocks = rp.OnDemandBlockService(diskio.BlockService(bserv.New(n.Blockstore, n.Exchange), n.DiskIO), n.Provider)
 	n.DAG = dag.NewDAGService(n.Blocks)
 
-	internalDag := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
-	n.Pinning, err = pin.LoadPinner(n.Repo.Datastore(), n.DAG, internalDag)
+	pinCounter := n.DiskIO.Subsystem(diskio.Pinner)
+	pinBlocks := diskio.Blockstore(n.Blockstore, pinCounter)
+	pinDatastore := diskio.Datastore(n.Repo.Datastore(), pinCounter)
+	internalDag := dag.NewDAGService(bserv.New(pinBlocks, offline.Exchange(pinBlocks)))
+	n.Pinning, err = pin.LoadPinner(pinDatastore, n.DAG, internalDag)
 	if err != nil {
 		// TODO: we should move towards only running 'NewPinner' explicitly on
 		// node init instead of implicitly here as a result of the pinner keys
 		// not being found in the datastore.
 		// this is kinda sketchy and could cause data loss
-		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG, internalDag)
+		n.Pinning = pin.NewPinner(pinDatastore, n.DAG, internalDag)
+	}
+	if err := n.setupPinBatching(pinDatastore); err != nil {
+		return err
 	}
 	n.Resolver = resolver.NewBasicResolver(n.DAG)
 
@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	gopath "path"
 	"strings"
 
+	chunk "github.com/ipfs/go-ipfs/chunker"
 	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	progress "github.com/ipfs/go-ipfs/core/commands/progress"
 	"github.com/ipfs/go-ipfs/core/coreunix"
 	filestore "github.com/ipfs/go-ipfs/filestore"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
 	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
 	dagtest "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag/test"
@@ -17,12 +21,12 @@ import (
 
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
-	pb "gx/ipfs/QmPtj12fdwuAqj9sBSTNUxBNu8kCGNp8b3o8yUzMm5GHpq/pb"
 	cidutil "gx/ipfs/QmQJSeE3CX4zos9qeaG8EhecEK9zvrTEfTG84J8C5NVRwt/go-cidutil"
 	mfs "gx/ipfs/QmRkrpnhZqDxTxwGCsDbuZMr7uCFZHH6SGfrcjgEQwxF3t/go-mfs"
 	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
 	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
 )
 
@@ -30,28 +34,106 @@ import (
 var ErrDepthLimitExceeded = fmt.Errorf("depth limit exceeded")
 
 const (
-	quietOptionName       = "quiet"
-	quieterOptionName     = "quieter"
-	silentOptionName      = "silent"
-	progressOptionName    = "progress"
-	trickleOptionName     = "trickle"
-	wrapOptionName        = "wrap-with-directory"
-	stdinPathName         = "stdin-name"
-	hiddenOptionName      = "hidden"
-	onlyHashOptionName    = "only-hash"
-	chunkerOptionName     = "chunker"
-	pinOptionName         = "pin"
-	rawLeavesOptionName   = "raw-leaves"
-	noCopyOptionName      = "nocopy"
-	fstoreCacheOptionName = "fscache"
-	cidVersionOptionName  = "cid-version"
-	hashOptionName        = "hash"
-	inlineOptionName      = "inline"
-	inlineLimitOptionName = "inline-limit"
+	quietOptionName         = "quiet"
+	quieterOptionName       = "quieter"
+	silentOptionName        = "silent"
+	progressOptionName      = "progress"
+	trickleOptionName       = "trickle"
+	wrapOptionName          = "wrap-with-directory"
+	stdinPathName           = "stdin-name"
+	hiddenOptionName        = "hidden"
+	onlyHashOptionName      = "only-hash"
+	chunkerOptionName       = "chunker"
+	pinOptionName           = "pin"
+	rawLeavesOptionName     = "raw-leaves"
+	noCopyOptionName        = "nocopy"
+	fstoreCacheOptionName   = "fscache"
+	cidVersionOptionName    = "cid-version"
+	hashOptionName          = "hash"
+	inlineOptionName        = "inline"
+	inlineLimitOptionName   = "inline-limit"
+	preserveModeOptionName  = "preserve-mode"
+	preserveMtimeOptionName = "preserve-mtime"
+	toFilesOptionName       = "to-files"
+	ignoreRulesOptionName   = "ignore-rules-path"
+	ignoreOptionName        = "ignore"
+	autoShardOptionName     = "auto-shard"
+	workersOptionName       = "workers"
+	followLinksOptionName   = "follow-symlinks"
+	derefArgsOptionName     = "dereference-args"
+	specialFilesOptionName  = "special-files"
 )
 
 const adderOutChanSize = 8
 
+// addToFiles puts the added root at dst in the files API, creating the
+// missing directories.
+func addToFiles(r *mfs.Root, dst string, nd ipld.Node) error {
+	if err := ensureContainingDirectoryExists(r, dst, nil); err != nil {
+		return fmt.Errorf("cannot create the parent directories of %s: %s", dst, err)
+	}
+	if err := mfs.PutNode(r, dst, nd); err != nil {
+		return fmt.Errorf("cannot put the root in path %s: %s", dst, err)
+	}
+	return mfs.FlushPath(r, dst)
+}
+
+// applyLinkPolicy reads the symlinks and the special files of the files to
+// add with the policy of the options. Like filterIgnored, it runs on the
+// client, which reads the files.
+func applyLinkPolicy(req *cmds.Request) error {
+	if req.Files == nil {
+		return nil
+	}
+
+	special, _ := req.Options[specialFilesOptionName].(string)
+	special, err := coreunix.ParseSpecialFiles(special)
+	if err != nil {
+		return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+	}
+	p := coreunix.LinkPolicy{
+		SpecialFiles: special,
+		Warn: func(msg string) {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", msg)
+		},
+	}
+	p.FollowSymlinks, _ = req.Options[followLinksOptionName].(bool)
+	p.DerefArgs, _ = req.Options[derefArgsOptionName].(bool)
+	p.Recursive, _ = req.Options["recursive"].(bool)
+	p.Hidden, _ = req.Options[hiddenOptionName].(bool)
+
+	req.Files = coreunix.ApplyLinkPolicy(req.Files, p)
+	return nil
+}
+
+// filterIgnored leaves the ignored files out of the directories to add. It
+// runs on the client, so that the ignored files are never read nor sent.
+func filterIgnored(req *cmds.Request) error {
+	if req.Files == nil {
+		return nil
+	}
+
+	rules := new(coreunix.IgnoreRules)
+	if path, _ := req.Options[ignoreRulesOptionName].(string); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := rules.Parse(f, ""); err != nil {
+			return fmt.Errorf("cannot read the ignore rules in %s: %s", path, err)
+		}
+	}
+	if patterns, _ := req.Options[ignoreOptionName].(string); patterns != "" {
+		for _, p := range strings.Split(patterns, ",") {
+			rules.AddPattern(p, "")
+		}
+	}
+
+	req.Files = coreunix.FilterIgnored(req.Files, rules)
+	return nil
+}
+
 var AddCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Add a file or directory to ipfs.",
@@ -81,10 +163,13 @@ You can now refer to the added file in a gateway, like so:
 The chunker option, '-s', specifies the chunking strategy that dictates
 how to break files into blocks. Blocks with same content can
 be deduplicated. The default is a fixed block size of
-256 * 1024 bytes, 'size-262144'. Alternatively, you can use the
+256 * 1024 bytes, 'size-262144', unless set by the "Import.UnixFSChunker"
+config key. Alternatively, you can use the
 rabin chunker for content defined chunking by specifying
 rabin-[min]-[avg]-[max] (where min/avg/max refer to the resulting
-chunk sizes). Using other chunking strategies will produce
+chunk sizes), or 'rabin-[avg]', or the faster buzhash chunker with
+'buzhash', cutting chunks of 128KiB to 512KiB. The chunks can't be larger
+than 1MiB. Using other chunking strategies will produce
 different hashes for the same file.
 
   > ipfs add --chunker=size-2048 ipfs-logo.svg
@@ -101,6 +186,52 @@ You can now check what blocks have been created by:
   QmY6yj1GsermExDXoosVE3aSPxdMNYr6aKuw3nA8LoWPRS 2059
   QmerURi9k4XzKCaaPbsK6BL5pMEjF7PGphjDvkkjDtsVf3 868
   QmQB28iwSriSUSMqG2nXDTLtdPHgWb4rebBrU7Q1j4vxPv 338
+
+The '--preserve-mode' and '--preserve-mtime' options store the permissions
+and the modification time of the files and directories in their nodes, in
+the mode and mtime fields of UnixFS. They are shown by 'ipfs files stat' and
+restored by 'ipfs get'. The nodes, and so the hashes, are different from the
+ones added without these options. The attributes are read from the local
+files and sent with them to the daemon. They are not stored for stdin.
+
+The '--to-files' option puts the root in the files API (MFS) at the given
+path, creating the missing directories. With a trailing slash, the root is
+put in that directory under its name:
+
+  > ipfs add -r --to-files=/photos/ holidays
+  > ipfs files ls /photos
+  holidays
+
+Recursive adds skip the files matched by the gitignore-style rules of the
+'.ipfsignore' files of the added directories, of the file given with
+'--ignore-rules-path', and of the comma separated patterns of '--ignore'.
+The patterns are relative to the added directories, and the ignored files
+are not read:
+
+  > ipfs add -r --ignore='*.log,build/' project
+
+The symlinks are added as symlinks, restored by 'ipfs get'. With
+'--follow-symlinks', the targets of the symlinks found in the directories
+are added instead, and with '--dereference-args', the targets of the
+symlinks given as arguments. The dangling symlinks are kept, and the cycles
+of symlinks fail the add. The sockets, devices and named pipes fail the add,
+unless they are ignored or skipped with a warning with '--special-files=skip'.
+
+The '--auto-shard' option shards the directories whose estimated size, the
+sum of the lengths of the names and CIDs of their entries, is above
+Import.UnixFSHAMTDirectorySizeThreshold (256KiB by default), and only them.
+The smaller directories are not sharded even with
+Experimental.ShardingEnabled, so their hashes don't depend on it. It's
+enabled by default with Import.AutoShard:
+
+  > ipfs config --json Import.AutoShard true
+  > ipfs config Import.UnixFSHAMTDirectorySizeThreshold 1MiB
+
+The '--workers' option chunks and hashes several files concurrently, and
+writes the blocks in batches, which speeds up the adds of many small files.
+The files of up to 1MiB are read in memory to be handed to the workers. The
+hashes and the order of the output don't depend on it. It's ignored with
+'--nocopy'.
 `,
 	},
 
@@ -118,17 +249,41 @@ You can now check what blocks have been created by:
 		cmdkit.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
 		cmdkit.StringOption(stdinPathName, "Assign a name if the file source is stdin."),
 		cmdkit.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add."),
-		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes] or rabin-[min]-[avg]-[max]").WithDefault("size-262144"),
+		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max] or buzhash. Defaults to Import.UnixFSChunker or size-262144."),
 		cmdkit.BoolOption(pinOptionName, "Pin this object when adding.").WithDefault(true),
-		cmdkit.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
+		cmdkit.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. Defaults to Import.RawLeaves. (experimental)"),
 		cmdkit.BoolOption(noCopyOptionName, "Add the file using filestore. Implies raw-leaves. (experimental)"),
 		cmdkit.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
-		cmdkit.IntOption(cidVersionOptionName, "CID version. Defaults to 0 unless an option that depends on CIDv1 is passed. (experimental)"),
-		cmdkit.StringOption(hashOptionName, "Hash function to use. Implies CIDv1 if not sha2-256. (experimental)").WithDefault("sha2-256"),
+		cmdkit.IntOption(cidVersionOptionName, "CID version. Defaults to Import.CidVersion, or 0 unless an option that depends on CIDv1 is passed. (experimental)"),
+		cmdkit.StringOption(hashOptionName, "Hash function to use. Implies CIDv1 if not sha2-256. Defaults to Import.HashFunction or sha2-256. (experimental)"),
 		cmdkit.BoolOption(inlineOptionName, "Inline small blocks into CIDs. (experimental)"),
 		cmdkit.IntOption(inlineLimitOptionName, "Maximum block size to inline. (experimental)").WithDefault(32),
+		cmdkit.BoolOption(preserveModeOptionName, "Store the permissions of the files in their nodes. (experimental)"),
+		cmdkit.BoolOption(preserveMtimeOptionName, "Store the modification time of the files in their nodes. (experimental)"),
+		cmdkit.StringOption(toFilesOptionName, "Add the root to the files API (MFS) at this path."),
+		cmdkit.StringOption(ignoreRulesOptionName, "A file of gitignore-style rules excluding files from recursive adds."),
+		cmdkit.StringOption(ignoreOptionName, "Comma separated gitignore-style patterns excluding files from recursive adds."),
+		cmdkit.IntOption(workersOptionName, "Number of files chunked and hashed concurrently. (experimental)").WithDefault(1),
+		cmdkit.BoolOption(followLinksOptionName, "Add the targets of the symlinks found in the directories instead of the symlinks."),
+		cmdkit.BoolOption(derefArgsOptionName, "Add the targets of the symlinks given as arguments instead of the symlinks."),
+		cmdkit.StringOption(specialFilesOptionName, "What to do with the sockets, devices and named pipes of the directories: 'error' or 'skip' them with a warning.").WithDefault(coreunix.SpecialFilesError),
+		cmdkit.BoolOption(autoShardOptionName, "Shard the directories above Import.UnixFSHAMTDirectorySizeThreshold, and only them. Defaults to Import.AutoShard."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		// the ignore rules apply to the files read with the policy
+		if err := applyLinkPolicy(req); err != nil {
+			return err
+		}
+		if err := filterIgnored(req); err != nil {
+			return err
+		}
+		// the attributes are read on the client, and sent with the files
+		preserveMode, _ := req.Options[preserveModeOptionName].(bool)
+		preserveMtime, _ := req.Options[preserveMtimeOptionName].(bool)
+		if req.Files != nil && (preserveMode || preserveMtime) {
+			req.Files = coreunix.SendFileAttrs(req.Files, preserveMode, preserveMtime)
+		}
+
 		quiet, _ := req.Options[quietOptionName].(bool)
 		quieter, _ := req.Options[quieterOptionName].(bool)
 		quiet = quiet || quieter
@@ -148,7 +303,7 @@ You can now check what blocks have been created by:
 		return nil
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		n, err := cmdenv.GetNode(env)
+		n, err := cmdenv.GetRequestNode(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -181,6 +336,11 @@ You can now check what blocks have been created by:
 		inline, _ := req.Options[inlineOptionName].(bool)
 		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
 		pathName, _ := req.Options[stdinPathName].(string)
+		preserveMode, _ := req.Options[preserveModeOptionName].(bool)
+		preserveMtime, _ := req.Options[preserveMtimeOptionName].(bool)
+		toFiles, _ := req.Options[toFilesOptionName].(string)
+		autoShard, autoShardSet := req.Options[autoShardOptionName].(bool)
+		workers, _ := req.Options[workersOptionName].(int)
 
 		// The arguments are subject to the following constraints.
 		//
@@ -190,6 +350,53 @@ You can now check what blocks have been created by:
 
 		// NOTE: 'rawblocks -> cidv1' is missing. Legacy reasons.
 
+		if toFiles != "" {
+			if hash {
+				return cmdkit.Errorf(cmdkit.ErrClient, "the %s option can't be used with %s", toFilesOptionName, onlyHashOptionName)
+			}
+			toFiles, err = checkPath(toFiles)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+			}
+		}
+
+		if workers < 1 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the %s option must be positive", workersOptionName)
+		}
+
+		importCfg, err := extconfig.LoadImport(n.Repo)
+		if err != nil {
+			return err
+		}
+		if !autoShardSet {
+			autoShard = importCfg.AutoShard.WithDefault(false)
+		}
+		// the Import section sets the defaults of the options
+		if chunker == "" {
+			chunker = importCfg.Chunker()
+		}
+		if err := chunk.Validate(chunker); err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+		}
+		if hashFunStr == "" {
+			hashFunStr = importCfg.Hash()
+		}
+		if !cidVerSet && importCfg.CidVersion != nil {
+			cidVer = *importCfg.CidVersion
+		}
+		rbflag := rbset
+		if !rbset && importCfg.RawLeaves != extconfig.Default {
+			rawblks, rbset = importCfg.RawLeaves.WithDefault(false), true
+		}
+
+		var shardThreshold int64
+		if autoShard {
+			shardThreshold, err = importCfg.HAMTDirectorySizeThreshold()
+			if err != nil {
+				return err
+			}
+		}
+
 		// nocopy -> filestoreEnabled
 		if nocopy && !cfg.Experimental.FilestoreEnabled {
 			return cmdkit.Errorf(cmdkit.ErrClient, filestore.ErrFilestoreNotEnabled.Error())
@@ -198,7 +405,7 @@ You can now check what blocks have been created by:
 		// nocopy -> rawblocks
 		if nocopy && !rawblks {
 			// fixed?
-			if rbset {
+			if rbflag {
 				return fmt.Errorf("nocopy option requires '--raw-leaves' to be enabled as well")
 			}
 			// No, satisfy mandatory constraint.
@@ -276,6 +483,14 @@ You can now check what blocks have been created by:
 		fileAdder.NoCopy = nocopy
 		fileAdder.Name = pathName
 		fileAdder.CidBuilder = prefix
+		fileAdder.PreserveMode = preserveMode
+		fileAdder.PreserveMtime = preserveMtime
+		if req.Files != nil && (preserveMode || preserveMtime) {
+			// the attributes sent by the client with the files
+			req.Files = coreunix.ReceiveFileAttrs(req.Files)
+		}
+		fileAdder.ShardThreshold = shardThreshold
+		fileAdder.Workers = workers
 
 		if inline {
 			fileAdder.CidBuilder = cidutil.InlineBuilder{
@@ -298,6 +513,10 @@ You can now check what blocks have been created by:
 		}
 
 		addAllAndPin := func(f files.File) error {
+			// the names of the top-level files, to name the root in the
+			// files API
+			var names []string
+
 			// Iterate over each top-level file and add individually. Otherwise the
 			// single files.File f is treated as a directory, affecting hidden file
 			// semantics.
@@ -309,6 +528,7 @@ You can now check what blocks have been created by:
 				} else if err != nil {
 					return err
 				}
+				names = append(names, file.FileName())
 				if err := fileAdder.AddFile(file); err != nil {
 					return err
 				}
@@ -324,7 +544,26 @@ You can now check what blocks have been created by:
 				return nil
 			}
 
-			return fileAdder.PinRoot()
+			if err := fileAdder.PinRoot(); err != nil {
+				return err
+			}
+
+			if toFiles == "" {
+				return nil
+			}
+			root, err := fileAdder.RootNode()
+			if err != nil {
+				return err
+			}
+			dst := toFiles
+			if dst[len(dst)-1] == '/' {
+				name := root.Cid().String()
+				if len(names) == 1 && !wrap && names[0] != "" {
+					name = gopath.Base(names[0])
+				}
+				dst += name
+			}
+			return addToFiles(n.FilesRoot, dst, root)
 		}
 
 		errCh := make(chan error)
@@ -374,16 +613,11 @@ You can now check what blocks have been created by:
 				quieter, _ := req.Options[quieterOptionName].(bool)
 				quiet = quiet || quieter
 
-				progress, _ := req.Options[progressOptionName].(bool)
+				showProgress, _ := req.Options[progressOptionName].(bool)
 
-				var bar *pb.ProgressBar
-				if progress {
-					bar = pb.New64(0).SetUnits(pb.U_BYTES)
-					bar.ManualUpdate = true
-					bar.ShowTimeLeft = false
-					bar.ShowPercent = false
-					bar.Output = os.Stderr
-					bar.Start()
+				var bar *progress.Bar
+				if showProgress {
+					bar = progress.New(os.Stderr)
 				}
 
 				lastFile := ""
@@ -408,9 +642,9 @@ You can now check what blocks have been created by:
 								continue
 							}
 
-							if progress {
+							if showProgress {
 								// clear progress bar line before we print "added x" output
-								fmt.Fprintf(os.Stderr, "\033[2K\r")
+								bar.Clear()
 							}
 							if quiet {
 								fmt.Fprintf(os.Stdout, "%s\n", output.Hash)
@@ -419,7 +653,7 @@ You can now check what blocks have been created by:
 							}
 
 						} else {
-							if !progress {
+							if !showProgress {
 								continue
 							}
 
@@ -432,18 +666,12 @@ You can now check what blocks have been created by:
 							}
 							lastBytes = output.Bytes
 							delta := prevFiles + lastBytes - totalProgress
-							totalProgress = bar.Add64(delta)
-						}
-
-						if progress {
-							bar.Update()
+							bar.AddBytes(delta)
+							totalProgress += delta
 						}
 					case size := <-sizeChan:
-						if progress {
-							bar.Total = size
-							bar.ShowPercent = true
-							bar.ShowBar = true
-							bar.ShowTimeLeft = true
+						if showProgress {
+							bar.SetTotal(size, 0)
 						}
 					case <-req.Context.Done():
 						// don't set or print error here, that happens in the goroutine below
@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	gopath "path"
+
+	archive "github.com/ipfs/go-ipfs/archive"
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	dagtransfer "github.com/ipfs/go-ipfs/dagtransfer"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
+)
+
+const (
+	archiveFormatOptionName        = "format"
+	archivePinOptionName           = "pin"
+	archivePreserveModeOptionName  = "preserve-mode"
+	archivePreserveMtimeOptionName = "preserve-mtime"
+	archiveRawLeavesOptionName     = "raw-leaves"
+)
+
+// ArchiveCmd is the 'ipfs archive' command
+var ArchiveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import and export tar and zip archives.",
+		ShortDescription: `
+'ipfs archive add' imports a tar or zip archive as a UnixFS directory, keeping
+the paths of its entries, and 'ipfs archive export' writes a UnixFS tree as an
+archive. Both stream the content of the files, so archives larger than the
+memory can be imported and exported.
+
+The exported archives are deterministic: the same tree is always written as
+the same bytes, which makes them suitable for reproducible builds.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":    archiveAddCmd,
+		"export": archiveExportCmd,
+	},
+}
+
+// ArchiveAddOutput is the output of 'ipfs archive add'.
+type ArchiveAddOutput struct {
+	Hash    string
+	Entries int
+	Skipped int `json:",omitempty"`
+}
+
+var archiveAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import an archive as a UnixFS directory.",
+		ShortDescription: `
+'ipfs archive add' imports a tar archive, possibly gzip compressed, or a zip
+archive, and writes the hash of the directory holding its entries. The format
+is detected from the first bytes of the archive unless --format is set.
+
+The entries keep their paths, confined to the directory: the leading '/' and
+the '..' escaping the directory are dropped. Symbolic links are kept, hard
+links become copies, and the devices and fifos are skipped. A zip archive is
+first copied to a temporary file, as its index is at its end.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "The archive to import.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(archiveFormatOptionName, "The format of the archive, \"tar\" or \"zip\". Detected when unset."),
+		cmdkit.BoolOption(archivePinOptionName, "Pin the imported directory.").WithDefault(true),
+		cmdkit.BoolOption(archivePreserveModeOptionName, "Store the permissions of the files."),
+		cmdkit.BoolOption(archivePreserveMtimeOptionName, "Store the modification times of the files."),
+		cmdkit.BoolOption(archiveRawLeavesOptionName, "Use raw blocks for the data of the files."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetRequestNode(env, req.Options)
+		if err != nil {
+			return err
+		}
+
+		formatStr, _ := req.Options[archiveFormatOptionName].(string)
+		format, err := archive.ParseFormat(formatStr)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+		}
+		dopin, _ := req.Options[archivePinOptionName].(bool)
+		preserveMode, _ := req.Options[archivePreserveModeOptionName].(bool)
+		preserveMtime, _ := req.Options[archivePreserveMtimeOptionName].(bool)
+		rawLeaves, _ := req.Options[archiveRawLeavesOptionName].(bool)
+
+		file, err := req.Files.NextFile()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		defer n.Blockstore.PinLock().Unlock()
+
+		out, err := archive.Import(req.Context, file, n.DAG, archive.ImportOptions{
+			Format:        format,
+			PreserveMode:  preserveMode,
+			PreserveMtime: preserveMtime,
+			RawLeaves:     rawLeaves,
+		})
+		if err != nil {
+			return err
+		}
+
+		c := out.Root.Cid()
+		if dopin {
+			n.Pinning.PinWithMode(c, pin.Recursive)
+			if err := n.Pinning.Flush(); err != nil {
+				return err
+			}
+		}
+
+		return cmds.EmitOnce(res, &ArchiveAddOutput{
+			Hash:    c.String(),
+			Entries: out.Entries,
+			Skipped: out.Skipped,
+		})
+	},
+	Type: ArchiveAddOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*ArchiveAddOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			if out.Skipped > 0 {
+				fmt.Fprintf(w, "skipped %d entries of unsupported types\n", out.Skipped)
+			}
+			fmt.Fprintln(w, out.Hash)
+			return nil
+		}),
+	},
+}
+
+var archiveExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write a UnixFS tree as an archive.",
+		ShortDescription: `
+'ipfs archive export' writes the UnixFS tree at the path to stdout as a tar
+or zip archive. The entries of a directory are written relative to it, so
+that exporting the hash of 'ipfs archive add' writes the entries of the
+imported archive.
+
+The archive is deterministic: the entries are sorted by path and have no
+owner. The permissions and the modification times stored in the nodes, with
+'ipfs add --preserve-mode --preserve-mtime' for instance, are written;
+otherwise the files get mode 0644, the directories 0755, and every entry the
+earliest time of the format.
+
+Example:
+
+    $ ipfs archive export --format=zip /ipfs/<hash> > site.zip
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "The path of the tree to export.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(archiveFormatOptionName, "The format of the archive, \"tar\" or \"zip\".").WithDefault("tar"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetRequestNode(env, req.Options)
+		if err != nil {
+			return err
+		}
+
+		formatStr, _ := req.Options[archiveFormatOptionName].(string)
+		format, err := archive.ParseFormat(formatStr)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+		}
+
+		p, err := path.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		nd, err := core.Resolve(req.Context, n.Namesys, n.Resolver, p)
+		if err != nil {
+			return err
+		}
+
+		r, w := io.Pipe()
+		go func() {
+			dagtransfer.Prefetch(req.Context, n.DAGTransfer, nd.Cid())
+			w.CloseWithError(archive.Export(req.Context, w, nd, n.DAG, archive.ExportOptions{
+				Format: format,
+				// the name of the entry when the path is a file
+				Name: gopath.Base(p.String()),
+			}))
+		}()
+		return res.Emit(r)
+	},
+}
@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"bufio"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	util "github.com/ipfs/go-ipfs/blocks/blockstoreutil"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
@@ -12,6 +16,7 @@ import (
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 	"github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
 	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
@@ -41,6 +46,7 @@ multihash.
 		"get":  blockGetCmd,
 		"put":  blockPutCmd,
 		"rm":   blockRmCmd,
+		"deny": blockDenyCmd,
 	},
 }
 
@@ -61,7 +67,7 @@ on raw IPFS blocks. It outputs the following to stdout:
 		cmdkit.StringArg("key", true, false, "The base58 multihash of an existing block to stat.").EnableStdin(),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		api, err := cmdenv.GetApi(env)
+		api, err := cmdenv.GetRequestApi(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -107,7 +113,7 @@ It outputs to stdout, and <key> is a base58 encoded multihash.
 		cmdkit.StringArg("key", true, false, "The base58 multihash of an existing block to get.").EnableStdin(),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		api, err := cmdenv.GetApi(env)
+		api, err := cmdenv.GetRequestApi(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -126,6 +132,17 @@ It outputs to stdout, and <key> is a base58 encoded multihash.
 	},
 }
 
+// blockPutBatchSize and blockPutBatchBytes bound the blocks written to the
+// blockstore at once by 'ipfs block put'.
+const (
+	blockPutBatchSize  = 128
+	blockPutBatchBytes = 8 << 20
+)
+
+// maxStreamBlockSize bounds the size of the blocks read by 'ipfs block put
+// --stream', so that a corrupted size does not exhaust the memory.
+const maxStreamBlockSize = 4 << 20
+
 var blockPutCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Store input as an IPFS block.",
@@ -135,24 +152,40 @@ It reads from stdin, and <key> is a base58 encoded multihash.
 
 By default CIDv0 is going to be generated. Setting 'mhtype' to anything other
 than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
+`,
+		LongDescription: `
+'ipfs block put' is a plumbing command for storing raw IPFS blocks.
+It reads from stdin, and <key> is a base58 encoded multihash.
+
+By default CIDv0 is going to be generated. Setting 'mhtype' to anything other
+than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
+
+Several blocks can be stored at once, either by giving several files, each
+file being a block, or with --stream, where each file is a stream of blocks,
+each block preceded by its size as an unsigned varint. The key of every
+block is output as it is stored, and the blocks are written to the
+blockstore in batches.
+
+--cid-codec sets the codec of the CIDs, like raw, dag-pb, dag-cbor or
+dag-json, and replaces --format.
+
+The blocks larger than the Datastore.MaxBlockSize config, 2MiB by default,
+are rejected, as are the ones hashed with insecure functions such as md5.
 `,
 	},
 
 	Arguments: []cmdkit.Argument{
-		cmdkit.FileArg("data", true, false, "The data to be stored as an IPFS block.").EnableStdin(),
+		cmdkit.FileArg("data", true, true, "The data to be stored as IPFS blocks.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("format", "f", "cid format for blocks to be created with."),
+		cmdkit.StringOption("cid-codec", "Multicodec of the CIDs of the blocks, like raw or dag-cbor."),
 		cmdkit.StringOption("mhtype", "multihash hash function").WithDefault("sha2-256"),
 		cmdkit.IntOption("mhlen", "multihash hash length").WithDefault(-1),
+		cmdkit.BoolOption("stream", "Read the blocks from streams of blocks prefixed by their size as varints."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		api, err := cmdenv.GetApi(env)
-		if err != nil {
-			return err
-		}
-
-		file, err := req.Files.NextFile()
+		api, err := cmdenv.GetRequestApi(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -169,6 +202,12 @@ than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
 		}
 
 		format, formatSet := req.Options["format"].(string)
+		if codec, ok := req.Options["cid-codec"].(string); ok {
+			if formatSet {
+				return errors.New("--format and --cid-codec cannot be used together")
+			}
+			format, formatSet = cidCodecFormat(codec), true
+		}
 		if !formatSet {
 			if mhtval != mh.SHA2_256 || (mhlen != -1 && mhlen != 32) {
 				format = "protobuf"
@@ -177,15 +216,75 @@ than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
 			}
 		}
 
-		p, err := api.Block().Put(req.Context, file, options.Block.Hash(mhtval, mhlen), options.Block.Format(format))
-		if err != nil {
-			return err
+		stream, _ := req.Options["stream"].(bool)
+		opts := []options.BlockPutOption{options.Block.Hash(mhtval, mhlen), options.Block.Format(format)}
+
+		var batch [][]byte
+		var batchBytes int
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			stats, err := api.Block().PutMany(req.Context, batch, opts...)
+			if err != nil {
+				return err
+			}
+			batch, batchBytes = batch[:0], 0
+			for _, p := range stats {
+				err := res.Emit(&BlockStat{
+					Key:  p.Path().Cid().String(),
+					Size: p.Size(),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		add := func(data []byte) error {
+			batch = append(batch, data)
+			batchBytes += len(data)
+			if len(batch) >= blockPutBatchSize || batchBytes >= blockPutBatchBytes {
+				return flush()
+			}
+			return nil
 		}
 
-		return cmds.EmitOnce(res, &BlockStat{
-			Key:  p.Path().Cid().String(),
-			Size: p.Size(),
-		})
+		for {
+			file, err := req.Files.NextFile()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if !stream {
+				data, err := ioutil.ReadAll(file)
+				if err != nil {
+					return err
+				}
+				if err := add(data); err != nil {
+					return err
+				}
+				continue
+			}
+
+			r := bufio.NewReader(file)
+			for {
+				data, err := readStreamBlock(r)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if err := add(data); err != nil {
+					return err
+				}
+			}
+		}
+		return flush()
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
@@ -200,63 +299,143 @@ than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
 	Type: BlockStat{},
 }
 
+// cidCodecFormat returns the format of 'ipfs block put' of the multicodec
+// named codec.
+func cidCodecFormat(codec string) string {
+	switch codec {
+	case "dag-pb":
+		return "protobuf"
+	case "dag-cbor":
+		return "cbor"
+	default:
+		return codec
+	}
+}
+
+// readStreamBlock reads a block prefixed by its size as a varint. It
+// returns io.EOF at the end of the stream only.
+func readStreamBlock(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the size of a block: %s", err)
+	}
+	if size > maxStreamBlockSize {
+		return nil, fmt.Errorf("block of %d bytes too large, the maximum is %d", size, maxStreamBlockSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("reading a block of %d bytes: %s", size, err)
+	}
+	return data, nil
+}
+
+// rmBatchSize is the number of blocks 'ipfs block rm' removes at once
+// while holding the GC lock.
+const rmBatchSize = 1024
+
 var blockRmCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Remove IPFS block(s).",
 		ShortDescription: `
 'ipfs block rm' is a plumbing command for removing raw ipfs blocks.
 It takes a list of base58 encoded multihashes to remove.
+`,
+		LongDescription: `
+'ipfs block rm' is a plumbing command for removing raw ipfs blocks.
+It takes a list of base58 encoded multihashes to remove.
+
+When no hash is given as argument, the hashes are read from stdin, one
+per line. They are read and removed in batches as they come, so that
+lists of any length can be piped in:
+
+  > ipfs refs local | ipfs block rm
+
+The blocks pinned are not removed. With --unpin, their direct pins are
+removed first; the blocks pinned recursively, or by a recursive pin of
+one of their ancestors, are still kept.
 `,
 	},
 	Arguments: []cmdkit.Argument{
-		cmdkit.StringArg("hash", true, true, "Bash58 encoded multihash of block(s) to remove."),
+		cmdkit.StringArg("hash", true, true, "Bash58 encoded multihash of block(s) to remove.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("force", "f", "Ignore nonexistent blocks."),
 		cmdkit.BoolOption("quiet", "q", "Write minimal output."),
+		cmdkit.BoolOption("unpin", "Remove the direct pins of the blocks before removing them."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		api, err := cmdenv.GetApi(env)
+		n, err := cmdenv.GetRequestNode(env, req.Options)
+		if err != nil {
+			return err
+		}
+
+		api, err := cmdenv.GetRequestApi(env, req.Options)
 		if err != nil {
 			return err
 		}
 
-		force, _ := req.Options["force"].(bool)
-		quiet, _ := req.Options["quiet"].(bool)
+		opts := util.RmBlocksOpts{}
+		opts.Force, _ = req.Options["force"].(bool)
+		opts.Quiet, _ = req.Options["quiet"].(bool)
+		opts.Unpin, _ = req.Options["unpin"].(bool)
 
-		// TODO: use batching coreapi when done
-		for _, b := range req.Arguments {
-			p, err := coreiface.ParsePath(b)
+		rmBatch := func(cids []cid.Cid) error {
+			out, err := util.RmBlocks(n.Blockstore, n.Pinning, cids, opts)
 			if err != nil {
 				return err
 			}
+			for r := range out {
+				if err := res.Emit(r); err != nil {
+					return err
+				}
+			}
+			return req.Context.Err()
+		}
 
-			rp, err := api.ResolvePath(req.Context, p)
+		args, err := blockRmArgs(req)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]cid.Cid, 0, rmBatchSize)
+		for args.Scan() {
+			arg := strings.TrimSpace(args.Text())
+			if arg == "" {
+				continue
+			}
+
+			p, err := coreiface.ParsePath(arg)
 			if err != nil {
 				return err
 			}
 
-			err = api.Block().Rm(req.Context, rp, options.Block.Force(force))
+			rp, err := api.ResolvePath(req.Context, p)
 			if err != nil {
-				err := res.Emit(&util.RemovedBlock{
-					Hash:  rp.Cid().String(),
-					Error: err.Error(),
-				})
-				if err != nil {
-					return err
-				}
+				return err
 			}
 
-			if !quiet {
-				err := res.Emit(&util.RemovedBlock{
-					Hash: rp.Cid().String(),
-				})
-				if err != nil {
+			batch = append(batch, rp.Cid())
+			if len(batch) == rmBatchSize {
+				if err := rmBatch(batch); err != nil {
 					return err
 				}
+				batch = batch[:0]
 			}
 		}
+		if err := args.Err(); err != nil {
+			return err
+		}
 
+		if len(batch) > 0 {
+			return rmBatch(batch)
+		}
 		return nil
 	},
 	PostRun: cmds.PostRunMap{
@@ -266,3 +445,18 @@ It takes a list of base58 encoded multihashes to remove.
 	},
 	Type: util.RemovedBlock{},
 }
+
+// blockRmArgs returns a scanner of the hashes to remove: the arguments, or
+// the lines of stdin when there is none. Unlike req.ParseBodyArgs, stdin is
+// not read ahead of the removal.
+func blockRmArgs(req *cmds.Request) (*bufio.Scanner, error) {
+	if len(req.Arguments) > 0 || req.Files == nil {
+		return bufio.NewScanner(strings.NewReader(strings.Join(req.Arguments, "\n"))), nil
+	}
+
+	fi, err := req.Files.NextFile()
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewScanner(fi), nil
+}
@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	denylist "github.com/ipfs/go-ipfs/denylist"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+// DenylistOutput is the output of the 'ipfs block deny' commands.
+type DenylistOutput struct {
+	Entries []denylist.Entry
+}
+
+var blockDenyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the CIDs and paths the node refuses to serve.",
+		ShortDescription: `
+The blocks of the denylist are not served through the gateway, which answers
+451 Unavailable For Legal Reasons, nor to the other peers over bitswap. An
+entry is a CID, which denies the block whatever the path it is reached
+through, or an /ipfs/ or /ipns/ path, which denies the path and everything
+under it.
+
+Besides the entries added with 'ipfs block deny add', the denylist contains
+the ones of the files and URLs of the Denylist config. The denied requests
+are logged by the "denylist" log subsystem.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add":    blockDenyAddCmd,
+		"ls":     blockDenyLsCmd,
+		"rm":     blockDenyRmCmd,
+		"reload": blockDenyReloadCmd,
+	},
+}
+
+var blockDenyAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add CIDs or paths to the local denylist.",
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("entry", true, true, "CID, /ipfs/ or /ipns/ path to deny.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.Denylist == nil {
+			return fmt.Errorf("the denylist is not loaded")
+		}
+
+		out := &DenylistOutput{}
+		for _, arg := range req.Arguments {
+			entry, err := n.Denylist.Add(arg)
+			if err != nil {
+				return err
+			}
+			out.Entries = append(out.Entries, entry)
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: DenylistOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DenylistOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			for _, entry := range out.Entries {
+				fmt.Fprintf(w, "denied %s\n", entry.Path)
+			}
+			return nil
+		}),
+	},
+}
+
+var blockDenyLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the entries of the denylist.",
+		ShortDescription: `
+'ipfs block deny ls' lists the entries of the denylist, with their source:
+"local" for the ones added with 'ipfs block deny add', or the file or URL
+they were read from.
+`,
+	},
+
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("quiet", "q", "Only write the entries."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &DenylistOutput{Entries: n.Denylist.List()})
+	},
+	Type: DenylistOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DenylistOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			quiet, _ := req.Options["quiet"].(bool)
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			for _, entry := range out.Entries {
+				if quiet {
+					fmt.Fprintf(tw, "%s\n", entry.Path)
+				} else {
+					fmt.Fprintf(tw, "%s\t%s\n", entry.Path, entry.Source)
+				}
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var blockDenyRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove CIDs or paths from the local denylist.",
+		ShortDescription: `
+'ipfs block deny rm' removes entries added with 'ipfs block deny add'. The
+entries of the files and URLs of the Denylist config must be removed from
+their source.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("entry", true, true, "CID, /ipfs/ or /ipns/ path to allow again.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.Denylist == nil {
+			return fmt.Errorf("the denylist is not loaded")
+		}
+
+		out := &DenylistOutput{}
+		for _, arg := range req.Arguments {
+			if err := n.Denylist.Remove(arg); err != nil {
+				return err
+			}
+			out.Entries = append(out.Entries, denylist.Entry{Path: arg, Source: denylist.LocalSource})
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: DenylistOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DenylistOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			for _, entry := range out.Entries {
+				fmt.Fprintf(w, "allowed %s\n", entry.Path)
+			}
+			return nil
+		}),
+	},
+}
+
+var blockDenyReloadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Read the files and URLs of the denylist again.",
+		ShortDescription: `
+The files and URLs of the Denylist config are read again every
+Denylist.RefreshInterval. 'ipfs block deny reload' reads them right away.
+`,
+	},
+
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.Denylist == nil {
+			return fmt.Errorf("the denylist is not loaded")
+		}
+
+		return n.Denylist.Load()
+	},
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+type BootstrapCheckOutput struct {
+	Peers []core.BootstrapCheck
+}
+
+var bootstrapCheckCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check the reachability of the bootstrap peers.",
+		ShortDescription: `
+'ipfs bootstrap check' dials each peer of the bootstrap list the node isn't
+connected to, and prints whether it is reachable and how long the dial took.
+For the peers already connected, the latency measured on the connection is
+printed instead.
+
+A peer whose last dials all failed is marked dead: the node dials it last when
+bootstrapping, after the healthy peers. The number of failures after which a
+peer is dead is set by BootstrapCheck.MaxFailures (3 by default), and setting
+BootstrapCheck.Enabled checks the peers periodically in the background. Dead
+peers are not removed from the bootstrap list, use 'ipfs bootstrap rm' for
+that.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("dial-timeout", "The timeout of each dial.").WithDefault("10s"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.PeerHost == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		s, _, _ := req.Option("dial-timeout").String()
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+		if timeout <= 0 {
+			res.SetError(fmt.Errorf("dial timeout must be positive"), cmdkit.ErrClient)
+			return
+		}
+
+		results, err := n.CheckBootstrapPeers(req.Context(), timeout)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(&BootstrapCheckOutput{results})
+	},
+	Type: BootstrapCheckOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*BootstrapCheckOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			reachable := 0
+			for _, c := range out.Peers {
+				fmt.Fprintf(buf, "%s %s\n", c.ID.Pretty(), bootstrapCheckStatus(c))
+				for _, a := range c.Addrs {
+					fmt.Fprintf(buf, "  %s\n", a)
+				}
+				if c.Reachable {
+					reachable++
+				}
+			}
+			fmt.Fprintf(buf, "%d/%d bootstrap peers reachable\n", reachable, len(out.Peers))
+			return buf, nil
+		},
+	},
+}
+
+func bootstrapCheckStatus(c core.BootstrapCheck) string {
+	switch {
+	case c.Connected:
+		return fmt.Sprintf("connected, latency %s", c.Latency.Round(time.Millisecond))
+	case c.Reachable:
+		return fmt.Sprintf("reachable, dialed in %s", c.Latency.Round(time.Millisecond))
+	case c.Dead:
+		return fmt.Sprintf("unreachable, dead after %d failures: %s", c.Failures, c.Error)
+	default:
+		return fmt.Sprintf("unreachable (%d failures): %s", c.Failures, c.Error)
+	}
+}
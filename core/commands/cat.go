@@ -30,7 +30,7 @@ var CatCmd = &cmds.Command{
 		cmdkit.IntOption("length", "l", "Maximum number of bytes to read."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		node, err := cmdenv.GetNode(env)
+		node, err := cmdenv.GetRequestNode(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -101,9 +101,8 @@ var CatCmd = &cmds.Command{
 				switch val := v.(type) {
 				case io.Reader:
 					bar, reader := progressBarForReader(os.Stderr, val, int64(res.Length()))
-					bar.Start()
-
 					err = re.Emit(reader)
+					bar.Finish()
 					if err != nil {
 						return err
 					}
@@ -0,0 +1,316 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	mbase "gx/ipfs/QmekxXDhCxCJRNuzmHreuaT3BsuJcsjcXWNrtV9C8DRHtd/go-multibase"
+)
+
+var CidCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Convert and inspect CIDs.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"format": cidFormatCmd,
+	},
+}
+
+// cidBases are the multibases CIDs are commonly encoded in, by name.
+var cidBases = map[string]mbase.Encoding{
+	"base16":      mbase.Base16,
+	"base32":      mbase.Base32,
+	"base32upper": mbase.Base32Upper,
+	"base58btc":   mbase.Base58BTC,
+	"base64":      mbase.Base64,
+	"base64url":   mbase.Base64url,
+}
+
+// cidBaseName returns the name of the multibase s is encoded in.
+func cidBaseName(s string) string {
+	if len(s) == 46 && strings.HasPrefix(s, "Qm") {
+		// CIDv0, without prefix
+		return "base58btc"
+	}
+	enc := mbase.Encoding(s[0])
+	for name, e := range cidBases {
+		if e == enc {
+			return name
+		}
+	}
+	return fmt.Sprintf("unknown (%q)", s[0])
+}
+
+// CidFormatOutput is a CID converted by 'ipfs cid format'.
+type CidFormatOutput struct {
+	CidStr    string
+	Formatted string `json:",omitempty"`
+	ErrorMsg  string `json:",omitempty"`
+}
+
+// CidPrefixCount is the number of CIDs sharing a prefix and a multibase.
+type CidPrefixCount struct {
+	Version int
+	Base    string
+	Codec   string
+	Hash    string
+	// Length is the length of the digest, in bytes.
+	Length int
+	Count  int
+}
+
+// CidStats is the distribution of the CIDs given to 'ipfs cid format
+// --stats'.
+type CidStats struct {
+	Total    int
+	Invalid  int
+	Versions map[string]int
+	Bases    map[string]int
+	Codecs   map[string]int
+	Hashes   map[string]int
+	// Prefixes are the groups of CIDs sharing a prefix and a multibase,
+	// the largest first.
+	Prefixes []CidPrefixCount
+}
+
+func codecName(code uint64) string {
+	if name, ok := cid.CodecToStr[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", code)
+}
+
+func hashName(code uint64) string {
+	if name, ok := mh.Codes[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", code)
+}
+
+// cidStats computes the distribution of the CIDs of args, counting the
+// invalid ones.
+func cidStats(args []string) *CidStats {
+	st := &CidStats{
+		Versions: make(map[string]int),
+		Bases:    make(map[string]int),
+		Codecs:   make(map[string]int),
+		Hashes:   make(map[string]int),
+	}
+	groups := make(map[CidPrefixCount]int)
+	for _, s := range args {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		st.Total++
+		c, err := cid.Decode(s)
+		if err != nil {
+			st.Invalid++
+			continue
+		}
+
+		p := c.Prefix()
+		g := CidPrefixCount{
+			Version: int(p.Version),
+			Base:    cidBaseName(s),
+			Codec:   codecName(p.Codec),
+			Hash:    hashName(p.MhType),
+			Length:  p.MhLength,
+		}
+		st.Versions[fmt.Sprintf("cidv%d", g.Version)]++
+		st.Bases[g.Base]++
+		st.Codecs[g.Codec]++
+		st.Hashes[g.Hash]++
+		groups[g]++
+	}
+
+	for g, n := range groups {
+		g.Count = n
+		st.Prefixes = append(st.Prefixes, g)
+	}
+	sort.Slice(st.Prefixes, func(i, j int) bool {
+		a, b := st.Prefixes[i], st.Prefixes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	})
+	return st
+}
+
+// formatCid converts c to the CID version, 0 to keep it, and to the
+// multibase named base, empty for the default of the version.
+func formatCid(c cid.Cid, version int, base string) (string, error) {
+	switch version {
+	case 0:
+	case 1:
+		if c.Version() == 0 {
+			c = cid.NewCidV1(c.Type(), c.Hash())
+		}
+	case -1:
+		// requested with -v 0
+		if c.Version() == 1 {
+			p := c.Prefix()
+			if p.Codec != cid.DagProtobuf || p.MhType != mh.SHA2_256 {
+				return "", fmt.Errorf("only dag-pb CIDs hashed with sha2-256 have a CIDv0")
+			}
+			c = cid.NewCidV0(c.Hash())
+		}
+	}
+
+	if base == "" {
+		return c.String(), nil
+	}
+	enc, ok := cidBases[base]
+	if !ok {
+		return "", fmt.Errorf("unknown multibase %q", base)
+	}
+	if c.Version() == 0 && enc != mbase.Base58BTC {
+		return "", fmt.Errorf("a CIDv0 can only be encoded in base58btc, use -v 1")
+	}
+	return c.StringOfBase(enc)
+}
+
+var cidFormatCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Convert CIDs to a CID version and a multibase, or summarize them.",
+		ShortDescription: `
+'ipfs cid format' converts the given CIDs, one per line on stdin or as
+arguments, to the CID version of -v and the multibase of -b: base16, base32,
+base32upper, base58btc, base64 or base64url. A CIDv0 can only be converted to
+base58btc, and only the dag-pb CIDs hashed with sha2-256 to CIDv0.
+
+  ipfs pin ls -q | ipfs cid format -v 1 -b base32
+
+With --stats, it prints instead how many CIDs use each CID version, multibase,
+codec and hash function, and the groups of CIDs sharing them all, the largest
+first. For instance, to tell how much of the pinset still uses CIDv0:
+
+  ipfs pin ls -q | ipfs cid format --stats
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, true, "The CIDs to convert.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("v", "The CID version to convert to, 0 or 1."),
+		cmdkit.StringOption("b", "The multibase to encode the CIDs in."),
+		cmdkit.BoolOption("stats", "Print the distribution of the CIDs rather than the CIDs."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		if stats, _ := req.Options["stats"].(bool); stats {
+			return cmds.EmitOnce(res, cidStats(req.Arguments))
+		}
+
+		version := 0
+		if v, ok := req.Options["v"].(int); ok {
+			switch v {
+			case 0:
+				version = -1
+			case 1:
+				version = 1
+			default:
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid CID version %d, expected 0 or 1", v)
+			}
+		}
+		base, _ := req.Options["b"].(string)
+		if _, ok := cidBases[base]; base != "" && !ok {
+			return cmdkit.Errorf(cmdkit.ErrClient, "unknown multibase %q", base)
+		}
+
+		for _, s := range req.Arguments {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			out := &CidFormatOutput{CidStr: s}
+			c, err := cid.Decode(s)
+			if err == nil {
+				out.Formatted, err = formatCid(c, version, base)
+			}
+			if err != nil {
+				out.ErrorMsg = err.Error()
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: CidFormatOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			switch out := v.(type) {
+			case *CidFormatOutput:
+				if out.ErrorMsg != "" {
+					fmt.Fprintf(w, "%s: error: %s\n", out.CidStr, out.ErrorMsg)
+					return nil
+				}
+				fmt.Fprintln(w, out.Formatted)
+			case *CidStats:
+				printCidStats(w, out)
+			default:
+				return e.TypeErr(out, v)
+			}
+			return nil
+		}),
+	},
+}
+
+func printCidStats(w io.Writer, st *CidStats) {
+	valid := st.Total - st.Invalid
+	fmt.Fprintf(w, "%d CIDs", st.Total)
+	if st.Invalid > 0 {
+		fmt.Fprintf(w, ", %d invalid", st.Invalid)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+	defer tw.Flush()
+	percent := func(n int) float64 {
+		if valid == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(valid)
+	}
+	for _, dist := range []struct {
+		name   string
+		counts map[string]int
+	}{
+		{"Versions", st.Versions},
+		{"Multibases", st.Bases},
+		{"Codecs", st.Codecs},
+		{"Hashes", st.Hashes},
+	} {
+		fmt.Fprintf(tw, "\n%s:\n", dist.name)
+		names := make([]string, 0, len(dist.counts))
+		for name := range dist.counts {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			a, b := dist.counts[names[i]], dist.counts[names[j]]
+			if a != b {
+				return a > b
+			}
+			return names[i] < names[j]
+		})
+		for _, name := range names {
+			n := dist.counts[name]
+			fmt.Fprintf(tw, "  %s\t%d\t%.1f%%\n", name, n, percent(n))
+		}
+	}
+
+	fmt.Fprintf(tw, "\nPrefixes:\n")
+	for _, g := range st.Prefixes {
+		fmt.Fprintf(tw, "  cidv%d %s %s %s-%d\t%d\t%.1f%%\n", g.Version, g.Base, g.Codec, g.Hash, g.Length*8, g.Count, percent(g.Count))
+	}
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"testing"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+)
+
+const (
+	testCidV0 = "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"
+	testCidV1 = "bafybeie5nqv6kd3qnfjupgvz34woh3oksc3iau6abmyajn7qvtf6d2ho34"
+)
+
+func TestCidStats(t *testing.T) {
+	st := cidStats([]string{testCidV0, testCidV0, testCidV1, "notacid", ""})
+
+	if st.Total != 4 || st.Invalid != 1 {
+		t.Fatalf("expected 4 CIDs and 1 invalid, got %d and %d", st.Total, st.Invalid)
+	}
+	if st.Versions["cidv0"] != 2 || st.Versions["cidv1"] != 1 {
+		t.Errorf("unexpected versions: %v", st.Versions)
+	}
+	if st.Bases["base58btc"] != 2 || st.Bases["base32"] != 1 {
+		t.Errorf("unexpected multibases: %v", st.Bases)
+	}
+	if st.Codecs["protobuf"] != 3 || st.Hashes["sha2-256"] != 3 {
+		t.Errorf("unexpected codecs or hashes: %v %v", st.Codecs, st.Hashes)
+	}
+	if len(st.Prefixes) != 2 || st.Prefixes[0].Count != 2 || st.Prefixes[0].Version != 0 {
+		t.Errorf("unexpected prefixes: %v", st.Prefixes)
+	}
+}
+
+func TestFormatCid(t *testing.T) {
+	c, err := cid.Decode(testCidV0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := formatCid(c, 1, "base32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, err := cid.Decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != testCidV1 {
+		t.Errorf("expected %s, got %s", testCidV1, s)
+	}
+
+	s, err = formatCid(c1, -1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != testCidV0 {
+		t.Errorf("expected %s back, got %s", testCidV0, s)
+	}
+
+	if _, err := formatCid(c, 0, "base32"); err == nil {
+		t.Error("expected a CIDv0 not to be encoded in base32")
+	}
+}
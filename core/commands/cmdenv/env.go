@@ -5,12 +5,18 @@ import (
 
 	"github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/core/coreapi"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
 )
 
+// OfflineOption is the global option running a request with the local
+// blocks only.
+const OfflineOption = "offline"
+
 // GetNode extracts the node from the environment.
 func GetNode(env interface{}) (*core.IpfsNode, error) {
 	ctx, ok := env.(*commands.Context)
@@ -31,6 +37,39 @@ func GetApi(env cmds.Environment) (coreiface.CoreAPI, error) {
 	return ctx.GetApi()
 }
 
+// GetRequestNode extracts the node from the environment, and returns the
+// one RequestNode returns for the options of the request.
+func GetRequestNode(env cmds.Environment, opts cmdkit.OptMap) (*core.IpfsNode, error) {
+	n, err := GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	return RequestNode(n, opts)
+}
+
+// GetRequestApi extracts CoreAPI instance from the environment, backed by
+// the node RequestNode returns for the options of the request.
+func GetRequestApi(env cmds.Environment, opts cmdkit.OptMap) (coreiface.CoreAPI, error) {
+	if offline, _ := opts[OfflineOption].(bool); !offline {
+		return GetApi(env)
+	}
+
+	n, err := GetRequestNode(env, opts)
+	if err != nil {
+		return nil, err
+	}
+	return coreapi.NewCoreAPI(n), nil
+}
+
+// RequestNode returns the offline view of n when the options of the request
+// have --offline set, and n otherwise.
+func RequestNode(n *core.IpfsNode, opts cmdkit.OptMap) (*core.IpfsNode, error) {
+	if offline, _ := opts[OfflineOption].(bool); !offline {
+		return n, nil
+	}
+	return n.OfflineView()
+}
+
 // GetConfig extracts the config from the environment.
 func GetConfig(env cmds.Environment) (*config.Config, error) {
 	ctx, ok := env.(*commands.Context)
@@ -22,6 +22,7 @@ func TestROCommands(t *testing.T) {
 		"/block/stat",
 		"/cat",
 		"/commands",
+		"/commands/completion",
 		"/dag",
 		"/dag/get",
 		"/dag/resolve",
@@ -69,12 +70,20 @@ func TestROCommands(t *testing.T) {
 func TestCommands(t *testing.T) {
 	list := []string{
 		"/add",
+		"/archive",
+		"/archive/add",
+		"/archive/export",
 		"/bitswap",
 		"/bitswap/ledger",
 		"/bitswap/reprovide",
 		"/bitswap/stat",
 		"/bitswap/wantlist",
 		"/block",
+		"/block/deny",
+		"/block/deny/add",
+		"/block/deny/ls",
+		"/block/deny/reload",
+		"/block/deny/rm",
 		"/block/get",
 		"/block/put",
 		"/block/rm",
@@ -82,18 +91,24 @@ func TestCommands(t *testing.T) {
 		"/bootstrap",
 		"/bootstrap/add",
 		"/bootstrap/add/default",
+		"/bootstrap/check",
 		"/bootstrap/list",
 		"/bootstrap/rm",
 		"/bootstrap/rm/all",
 		"/cat",
+		"/cid",
+		"/cid/format",
 		"/commands",
+		"/commands/completion",
 		"/config",
 		"/config/edit",
 		"/config/replace",
 		"/config/show",
 		"/config/profile",
 		"/config/profile/apply",
+		"/config/reload",
 		"/dag",
+		"/dag/diff",
 		"/dag/get",
 		"/dag/put",
 		"/dag/resolve",
@@ -108,6 +123,9 @@ func TestCommands(t *testing.T) {
 		"/diag/cmds",
 		"/diag/cmds/clear",
 		"/diag/cmds/set-time",
+		"/diag/net",
+		"/diag/profile",
+		"/diag/reachability",
 		"/diag/sys",
 		"/dns",
 		"/file",
@@ -121,10 +139,17 @@ func TestCommands(t *testing.T) {
 		"/files/mv",
 		"/files/read",
 		"/files/rm",
+		"/files/snapshot",
+		"/files/snapshot/create",
+		"/files/snapshot/ls",
+		"/files/snapshot/restore",
+		"/files/snapshot/rm",
 		"/files/stat",
 		"/filestore",
+		"/filestore/clean",
 		"/filestore/dups",
 		"/filestore/ls",
+		"/filestore/mv",
 		"/filestore/verify",
 		"/files/write",
 		"/get",
@@ -141,6 +166,9 @@ func TestCommands(t *testing.T) {
 		"/ls",
 		"/mount",
 		"/name",
+		"/name/dnslink",
+		"/name/dnslink/check",
+		"/name/dnslink/suggest",
 		"/name/publish",
 		"/name/pubsub",
 		"/name/pubsub/state",
@@ -170,11 +198,19 @@ func TestCommands(t *testing.T) {
 		"/p2p/stream/ls",
 		"/pin",
 		"/pin/add",
+		"/pin/export",
+		"/pin/import",
 		"/ping",
 		"/pin/ls",
 		"/pin/rm",
 		"/pin/update",
 		"/pin/verify",
+		"/pnet",
+		"/pnet/fingerprint",
+		"/pnet/generate-key",
+		"/provide",
+		"/provide/ls",
+		"/provide/now",
 		"/pubsub",
 		"/pubsub/ls",
 		"/pubsub/peers",
@@ -183,16 +219,35 @@ func TestCommands(t *testing.T) {
 		"/refs",
 		"/refs/local",
 		"/repo",
+		"/repo/backup",
+		"/repo/cache",
+		"/repo/cache/resize",
+		"/repo/cache/stat",
+		"/repo/dedupe-stats",
 		"/repo/fsck",
 		"/repo/gc",
+		"/repo/migrate-datastore",
+		"/repo/restore",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
 		"/resolve",
+		"/routing",
+		"/routing/findpeer",
+		"/routing/findprovs",
+		"/routing/get",
+		"/routing/provide",
+		"/routing/put",
 		"/shutdown",
 		"/stats",
 		"/stats/bitswap",
 		"/stats/bw",
+		"/stats/bw/history",
+		"/stats/dht",
+		"/stats/diskio",
+		"/stats/holepunch",
+		"/stats/provide",
+		"/stats/relay",
 		"/stats/repo",
 		"/swarm",
 		"/swarm/addrs",
@@ -200,10 +255,26 @@ func TestCommands(t *testing.T) {
 		"/swarm/addrs/local",
 		"/swarm/connect",
 		"/swarm/disconnect",
+		"/swarm/discover",
+		"/swarm/discovery",
 		"/swarm/filters",
 		"/swarm/filters/add",
 		"/swarm/filters/rm",
+		"/swarm/filters/test",
+		"/swarm/limit",
+		"/swarm/limit/bw",
+		"/swarm/peering",
+		"/swarm/peering/add",
+		"/swarm/peering/ls",
+		"/swarm/peering/rm",
 		"/swarm/peers",
+		"/swarm/peerstore",
+		"/swarm/peerstore/gc",
+		"/swarm/rendezvous",
+		"/swarm/rendezvous/add",
+		"/swarm/rendezvous/ls",
+		"/swarm/rendezvous/rm",
+		"/swarm/stats",
 		"/tar",
 		"/tar/add",
 		"/tar/cat",
@@ -211,6 +282,10 @@ func TestCommands(t *testing.T) {
 		"/urlstore",
 		"/urlstore/add",
 		"/version",
+		"/version/check",
+		"/webui",
+		"/webui/export",
+		"/webui/pin",
 	}
 
 	cmdSet := make(map[string]struct{})
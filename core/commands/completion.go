@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+// The shells 'ipfs commands completion' writes scripts for.
+var completionWriters = map[string]func(w io.Writer, tree []completionCommand, global []cmdkit.Option) error{
+	"bash": writeBashCompletion,
+	"zsh":  writeZshCompletion,
+	"fish": writeFishCompletion,
+}
+
+// completionCmd returns the command writing the completion scripts of the
+// commands under root.
+func completionCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmdkit.HelpText{
+			Tagline: "Generate shell completion scripts.",
+			ShortDescription: `
+'ipfs commands completion <shell>' writes the completion script of the ipfs
+commands, their subcommands and their options for bash, zsh or fish. The
+script is generated from the commands of the running ipfs, so it includes the
+commands of the plugins and must be generated again after an upgrade.
+
+Examples:
+
+  > ipfs commands completion bash > /etc/bash_completion.d/ipfs
+  > ipfs commands completion zsh > "${fpath[1]}/_ipfs"
+  > ipfs commands completion fish > ~/.config/fish/completions/ipfs.fish
+`,
+		},
+		Arguments: []cmdkit.Argument{
+			cmdkit.StringArg("shell", true, false, "The shell to complete in: bash, zsh or fish."),
+		},
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			write, ok := completionWriters[req.Arguments[0]]
+			if !ok {
+				return cmdkit.Errorf(cmdkit.ErrClient, "unsupported shell %q, must be bash, zsh or fish", req.Arguments[0])
+			}
+
+			buf := new(bytes.Buffer)
+			if err := write(buf, completionTree(root), root.Options); err != nil {
+				return err
+			}
+			return cmds.EmitOnce(res, buf)
+		},
+	}
+}
+
+// completionCommand is a command to complete, named by its path.
+type completionCommand struct {
+	// Path is the path of the command, without "ipfs", empty for the root.
+	Path    string
+	Tagline string
+	Subs    []string
+	Options []cmdkit.Option
+}
+
+// completionTree returns the commands under root, sorted by path.
+func completionTree(root *cmds.Command) []completionCommand {
+	var out []completionCommand
+	var walk func(p string, cmd *cmds.Command)
+	walk = func(p string, cmd *cmds.Command) {
+		c := completionCommand{
+			Path:    p,
+			Tagline: cmd.Helptext.Tagline,
+			Options: cmd.Options,
+		}
+		for name, sub := range cmd.Subcommands {
+			c.Subs = append(c.Subs, name)
+			walk(strings.TrimSpace(p+" "+name), sub)
+		}
+		sort.Strings(c.Subs)
+		out = append(out, c)
+	}
+	if root != nil {
+		// the options of the root are the global options, completed apart
+		walk("", &cmds.Command{Subcommands: root.Subcommands})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// optionFlags returns the flags of the options, as typed on the command
+// line, and the ones taking a value.
+func optionFlags(opts []cmdkit.Option) (flags, valued []string) {
+	for _, o := range opts {
+		for _, name := range o.Names() {
+			f := "--" + name
+			if len(name) == 1 {
+				f = "-" + name
+			}
+			flags = append(flags, f)
+			if o.Type() != reflect.Bool {
+				valued = append(valued, f)
+			}
+		}
+	}
+	return flags, valued
+}
+
+func writeBashCompletion(w io.Writer, tree []completionCommand, global []cmdkit.Option) error {
+	var paths []string
+	for _, c := range tree {
+		if c.Path != "" {
+			paths = append(paths, `"`+c.Path+`"`)
+		}
+	}
+	gflags, gvalued := optionFlags(global)
+
+	fmt.Fprintln(w, "# bash completion for ipfs, generated by 'ipfs commands completion bash'")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "_ipfs()")
+	fmt.Fprintln(w, "{")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    local path="" word i`)
+	fmt.Fprintln(w, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `        word="${COMP_WORDS[i]}"`)
+	fmt.Fprintln(w, `        case "${path:+$path }$word" in`)
+	fmt.Fprintf(w, "        %s)\n", strings.Join(paths, "|"))
+	fmt.Fprintln(w, `            path="${path:+$path }$word" ;;`)
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w, ``)
+	fmt.Fprintln(w, `    local subs="" flags="" valued=""`)
+	fmt.Fprintln(w, `    case "$path" in`)
+	for _, c := range tree {
+		flags, valued := optionFlags(c.Options)
+		fmt.Fprintf(w, "    %q)\n", c.Path)
+		fmt.Fprintf(w, "        subs=%q flags=%q valued=%q ;;\n",
+			strings.Join(c.Subs, " "), strings.Join(flags, " "), strings.Join(valued, " "))
+	}
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintf(w, "    flags=\"$flags %s\"\n", strings.Join(gflags, " "))
+	fmt.Fprintf(w, "    valued=\"$valued %s\"\n", strings.Join(gvalued, " "))
+	fmt.Fprintln(w, ``)
+	fmt.Fprintln(w, `    if [[ " $valued " == *" $prev "* ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=( $(compgen -f -- "$cur") )`)
+	fmt.Fprintln(w, `    elif [[ "$cur" == -* ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=( $(compgen -W "$flags" -- "$cur") )`)
+	fmt.Fprintln(w, `    elif [[ -n "$subs" ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=( $(compgen -W "$subs" -- "$cur") )`)
+	fmt.Fprintln(w, `    else`)
+	fmt.Fprintln(w, `        COMPREPLY=( $(compgen -f -- "$cur") )`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "")
+	_, err := fmt.Fprintln(w, "complete -o filenames -F _ipfs ipfs")
+	return err
+}
+
+// writeZshCompletion writes the bash script, run with the bash completion
+// emulation of zsh.
+func writeZshCompletion(w io.Writer, tree []completionCommand, global []cmdkit.Option) error {
+	fmt.Fprintln(w, "#compdef ipfs")
+	fmt.Fprintln(w, "# zsh completion for ipfs, generated by 'ipfs commands completion zsh'")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+	fmt.Fprintln(w, "")
+	return writeBashCompletion(w, tree, global)
+}
+
+func writeFishCompletion(w io.Writer, tree []completionCommand, global []cmdkit.Option) error {
+	fmt.Fprintln(w, "# fish completion for ipfs, generated by 'ipfs commands completion fish'")
+	fmt.Fprintln(w, "")
+
+	fmt.Fprint(w, "set -g __ipfs_commands")
+	for _, c := range tree {
+		if c.Path != "" {
+			fmt.Fprintf(w, " %s", fishQuote(c.Path))
+		}
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "# __ipfs_at succeeds when the command typed is the one of its argument")
+	fmt.Fprintln(w, "function __ipfs_at")
+	fmt.Fprintln(w, "    set -l path ''")
+	fmt.Fprintln(w, "    for tok in (commandline -opc)[2..-1]")
+	fmt.Fprintln(w, "        set -l next (string trim -- \"$path $tok\")")
+	fmt.Fprintln(w, "        if contains -- $next $__ipfs_commands")
+	fmt.Fprintln(w, "            set path $next")
+	fmt.Fprintln(w, "        end")
+	fmt.Fprintln(w, "    end")
+	fmt.Fprintln(w, "    test \"$path\" = \"$argv[1]\"")
+	fmt.Fprintln(w, "end")
+	fmt.Fprintln(w, "")
+
+	taglines := make(map[string]string, len(tree))
+	for _, c := range tree {
+		taglines[c.Path] = c.Tagline
+	}
+
+	writeFishOptions(w, "", global)
+	for _, c := range tree {
+		cond := fmt.Sprintf("-n %s", fishQuote("__ipfs_at "+fishQuote(c.Path)))
+		for _, sub := range c.Subs {
+			desc := taglines[strings.TrimSpace(c.Path+" "+sub)]
+			fmt.Fprintf(w, "complete -c ipfs -f %s -a %s -d %s\n", cond, fishQuote(sub), fishQuote(desc))
+		}
+		writeFishOptions(w, cond, c.Options)
+	}
+	return nil
+}
+
+func writeFishOptions(w io.Writer, cond string, opts []cmdkit.Option) {
+	for _, o := range opts {
+		line := "complete -c ipfs"
+		if cond != "" {
+			line += " " + cond
+		}
+		for _, name := range o.Names() {
+			if len(name) == 1 {
+				line += " -s " + name
+			} else {
+				line += " -l " + name
+			}
+		}
+		if o.Type() != reflect.Bool {
+			line += " -r"
+		}
+		fmt.Fprintf(w, "%s -d %s\n", line, fishQuote(o.Description()))
+	}
+}
+
+// fishQuote quotes s for fish, in which \ and ' are the only special
+// characters between single quotes.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
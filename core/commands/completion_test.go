@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+var testCompletionRoot = &cmds.Command{
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("api", "Use a specific API instance"),
+	},
+	Subcommands: map[string]*cmds.Command{
+		"pin": &cmds.Command{
+			Helptext: cmdkit.HelpText{Tagline: "Pin objects to local storage."},
+			Subcommands: map[string]*cmds.Command{
+				"add": &cmds.Command{
+					Helptext: cmdkit.HelpText{Tagline: "Pin objects."},
+					Options: []cmdkit.Option{
+						cmdkit.BoolOption("recursive", "r", "Recursively pin."),
+					},
+				},
+			},
+		},
+		"cat": &cmds.Command{
+			Helptext: cmdkit.HelpText{Tagline: "Show IPFS object data."},
+			Options: []cmdkit.Option{
+				cmdkit.IntOption("length", "l", "Maximum number of bytes to read."),
+			},
+		},
+	},
+}
+
+func TestCompletionTree(t *testing.T) {
+	tree := completionTree(testCompletionRoot)
+
+	var paths []string
+	for _, c := range tree {
+		paths = append(paths, c.Path)
+	}
+	if strings.Join(paths, ",") != ",cat,pin,pin add" {
+		t.Fatalf("unexpected commands %q", paths)
+	}
+	if strings.Join(tree[0].Subs, " ") != "cat pin" {
+		t.Fatalf("unexpected subcommands of the root %q", tree[0].Subs)
+	}
+
+	flags, valued := optionFlags(tree[1].Options)
+	if strings.Join(flags, " ") != "--length -l" || strings.Join(valued, " ") != "--length -l" {
+		t.Fatalf("unexpected flags %q, taking a value %q", flags, valued)
+	}
+	flags, valued = optionFlags(tree[3].Options)
+	if strings.Join(flags, " ") != "--recursive -r" || len(valued) != 0 {
+		t.Fatalf("unexpected flags %q, taking a value %q", flags, valued)
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	tree := completionTree(testCompletionRoot)
+
+	for shell, expected := range map[string][]string{
+		"bash": {`"cat"|"pin"|"pin add")`, `subs="" flags="--recursive -r" valued="" ;;`, "complete -o filenames -F _ipfs ipfs"},
+		"zsh":  {"bashcompinit", "complete -o filenames -F _ipfs ipfs"},
+		"fish": {
+			`complete -c ipfs -l api -r -d 'Use a specific API instance'`,
+			`complete -c ipfs -f -n '__ipfs_at \'pin\'' -a 'add' -d 'Pin objects.'`,
+			`complete -c ipfs -n '__ipfs_at \'pin add\'' -l recursive -s r -d 'Recursively pin.'`,
+		},
+	} {
+		buf := new(bytes.Buffer)
+		if err := completionWriters[shell](buf, tree, testCompletionRoot.Options); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range expected {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("the %s script doesn't contain %q:\n%s", shell, s, buf)
+			}
+		}
+	}
+}
@@ -9,11 +9,15 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"reflect"
+	"sort"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	repo "github.com/ipfs/go-ipfs/repo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
@@ -143,6 +147,7 @@ Set the value of the 'Datastore.Path' key:
 		"edit":    configEditCmd,
 		"replace": configReplaceCmd,
 		"profile": configProfileCmd,
+		"reload":  configReloadCmd,
 	},
 }
 
@@ -324,33 +329,162 @@ Available profiles:
 	},
 }
 
+// ConfigChange is a config key changed by a profile. Old is nil for the keys
+// the profile adds, and New for the ones it removes.
+type ConfigChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// ConfigProfileOutput lists the changes of a profile, sorted by key.
+type ConfigProfileOutput struct {
+	Changes []ConfigChange
+}
+
 var configProfileApplyCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Apply profile to config.",
+		ShortDescription: `
+Applies a profile to the config, and prints the keys it changed. With
+--dry-run, the keys the profile would change are printed and the config is
+left as is.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("dry-run", "Print the changes without applying them."),
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("profile", true, false, "The profile to apply to the config."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		profile, ok := config.Profiles[req.Arguments()[0]]
+		profile, ok := extconfig.Profiles[req.Arguments()[0]]
 		if !ok {
 			res.SetError(fmt.Errorf("%s is not a profile", req.Arguments()[0]), cmdkit.ErrNormal)
 			return
 		}
 
-		err := transformConfig(req.InvocContext().ConfigRoot, req.Arguments()[0], profile.Transform)
+		dryRun, _, _ := req.Option("dry-run").Bool()
+		changes, err := transformConfig(req.InvocContext().ConfigRoot, req.Arguments()[0], profile.Transform, dryRun)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
-		res.SetOutput(nil)
+		res.SetOutput(&ConfigProfileOutput{Changes: changes})
 	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ConfigProfileOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, c := range out.Changes {
+				fmt.Fprintf(buf, "%s: %s => %s\n", c.Key, changeValue(c.Old), changeValue(c.New))
+			}
+			return buf, nil
+		},
+	},
+	Type: ConfigProfileOutput{},
+}
+
+// ConfigReloadOutput lists the config sections applied by 'ipfs config
+// reload'.
+type ConfigReloadOutput struct {
+	Sections []core.ConfigReloadResult
+}
+
+var configReloadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Apply the changes of the config file to the running daemon.",
+		ShortDescription: `
+Reads the config file again and applies the sections that can change without
+restarting the daemon:
+
+  Logging.Levels         the log levels of the subsystems
+  Gateway                the HTTP headers and the path prefixes of the gateway
+  Swarm.ConnMgr          the limits of the basic connection manager
+  Swarm.BandwidthLimits  the bandwidth limits of the streams
+  Peering                the peering set
+  Denylist               the denylist files and URLs, which are read again
+
+The other sections are only read when the daemon starts. The sections that
+fail to apply are reported, and keep their previous values.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.LocalMode() {
+			res.SetError(errors.New("daemon not running"), cmdkit.ErrClient)
+			return
+		}
+
+		sections, err := n.ReloadConfig()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(&ConfigReloadOutput{Sections: sections})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ConfigReloadOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, s := range out.Sections {
+				if s.Error != "" {
+					fmt.Fprintf(buf, "%s: failed: %s\n", s.Section, s.Error)
+				} else {
+					fmt.Fprintf(buf, "%s: reloaded\n", s.Section)
+				}
+			}
+			return buf, nil
+		},
+	},
+	Type: ConfigReloadOutput{},
+}
+
+func changeValue(v interface{}) string {
+	if v == nil {
+		return "(unset)"
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(buf)
 }
 
 func buildProfileHelp() string {
 	var out string
 
-	for name, profile := range config.Profiles {
+	names := make([]string, 0, len(extconfig.Profiles))
+	for name := range extconfig.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		profile := extconfig.Profiles[name]
 		dlines := strings.Split(profile.Description, "\n")
 		for i := range dlines {
 			dlines[i] = "    " + dlines[i]
@@ -362,29 +496,81 @@ func buildProfileHelp() string {
 	return out
 }
 
-func transformConfig(configRoot string, configName string, transformer config.Transformer) error {
+// transformConfig applies transformer to the config, unless dryRun is set,
+// and returns the changes it makes.
+func transformConfig(configRoot string, configName string, transformer config.Transformer, dryRun bool) ([]ConfigChange, error) {
 	r, err := fsrepo.Open(configRoot)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer r.Close()
 
 	cfg, err := r.Config()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// the config is shared with the repo, transform a copy
+	oldMap, err := config.ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	newCfg, err := config.FromMap(oldMap)
+	if err != nil {
+		return nil, err
+	}
+
+	err = transformer(newCfg)
+	if err != nil {
+		return nil, err
 	}
 
-	err = transformer(cfg)
+	newMap, err := config.ToMap(newCfg)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	changes := diffConfig("", oldMap, newMap, nil)
+	if dryRun {
+		return changes, nil
 	}
 
 	_, err = r.BackupConfig("pre-" + configName + "-")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return r.SetConfig(cfg)
+	return changes, r.SetConfig(newCfg)
+}
+
+// diffConfig appends the keys that differ between the old and new maps to
+// changes. The nested maps are compared key by key, the other values as a
+// whole.
+func diffConfig(prefix string, old, new map[string]interface{}, changes []ConfigChange) []ConfigChange {
+	keys := make(map[string]struct{}, len(old)+len(new))
+	for k := range old {
+		keys[k] = struct{}{}
+	}
+	for k := range new {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		ov, nv := old[k], new[k]
+		om, oIsMap := ov.(map[string]interface{})
+		nm, nIsMap := nv.(map[string]interface{})
+		switch {
+		case oIsMap && nIsMap:
+			changes = diffConfig(prefix+k+".", om, nm, changes)
+		case !reflect.DeepEqual(ov, nv):
+			changes = append(changes, ConfigChange{Key: prefix + k, Old: ov, New: nv})
+		}
+	}
+	return changes
 }
 
 func getConfig(r repo.Repo, key string) (*ConfigField, error) {
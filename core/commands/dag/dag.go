@@ -2,14 +2,19 @@ package dagcmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	coredag "github.com/ipfs/go-ipfs/core/coredag"
+	dagutils "github.com/ipfs/go-ipfs/dagutils"
 	pin "github.com/ipfs/go-ipfs/pin"
 	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
 
@@ -34,6 +39,7 @@ to deprecate and replace the existing 'ipfs object' command moving forward.
 		"put":     DagPutCmd,
 		"get":     DagGetCmd,
 		"resolve": DagResolveCmd,
+		"diff":    DagDiffCmd,
 	},
 }
 
@@ -54,6 +60,27 @@ var DagPutCmd = &cmds.Command{
 		ShortDescription: `
 'ipfs dag put' accepts input from a file or stdin and parses it
 into an object of the specified format.
+`,
+		LongDescription: `
+'ipfs dag put' accepts input from a file or stdin and parses it
+into an object of the specified format.
+
+By default, the input is parsed as JSON with --input-enc and stored in the
+format given by --format. The IPLD codecs can be used instead, with
+--input-codec and --store-codec, to convert the input losslessly:
+
+  dag-json  JSON where the links are written {"/": "<cid>"} and the bytes
+            {"/": {"bytes": "<unpadded base64>"}}
+  dag-cbor  CBOR, where the links use the tag 42
+  dag-pb    protobuf, converted from a map with Data and Links, each link
+            with its Hash, Name and Tsize
+  raw       the bytes themselves
+
+The codecs added by the IPLD plugins can be used as well.
+
+For instance, to store a dag-json document as dag-cbor:
+
+  echo '{"data":{"/":{"bytes":"aGVsbG8"}}}' | ipfs dag put --input-codec=dag-json --store-codec=dag-cbor
 `,
 	},
 	Arguments: []cmdkit.Argument{
@@ -64,9 +91,11 @@ into an object of the specified format.
 		cmdkit.StringOption("input-enc", "Format that the input object will be.").WithDefault("json"),
 		cmdkit.BoolOption("pin", "Pin this object when adding."),
 		cmdkit.StringOption("hash", "Hash function to use").WithDefault(""),
+		cmdkit.StringOption("input-codec", "IPLD codec of the input, like dag-json or dag-cbor. Overrides --input-enc."),
+		cmdkit.StringOption("store-codec", "IPLD codec the object will be stored as, like dag-cbor, dag-json or dag-pb. Overrides --format."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -74,6 +103,12 @@ into an object of the specified format.
 
 		ienc, _, _ := req.Option("input-enc").String()
 		format, _, _ := req.Option("format").String()
+		if codec, found, _ := req.Option("input-codec").String(); found {
+			ienc = codec
+		}
+		if codec, found, _ := req.Option("store-codec").String(); found {
+			format = codec
+		}
 		hash, _, err := req.Option("hash").String()
 		dopin, _, err := req.Option("pin").Bool()
 		if err != nil {
@@ -189,18 +224,49 @@ var DagGetCmd = &cmds.Command{
 		ShortDescription: `
 'ipfs dag get' fetches a dag node from ipfs and prints it out in the specified
 format.
+`,
+		LongDescription: `
+'ipfs dag get' fetches a dag node from ipfs and prints it out in the specified
+format.
+
+With --output-codec, the node, or the value at the path given, is printed
+encoded with an IPLD codec, whatever the codec it is stored with:
+
+  dag-json  JSON where the links are written {"/": "<cid>"} and the bytes
+            {"/": {"bytes": "<unpadded base64>"}}
+  dag-cbor  CBOR, where the links use the tag 42
+  dag-pb    protobuf
+  raw       the bytes themselves, for the raw nodes
+
+The codecs added by the IPLD plugins can be used as well. The dag-pb nodes
+are converted as maps with their Data and their Links, each link with its
+Hash, Name and Tsize. Unlike the default output, these can be given back to
+'ipfs dag put --input-codec' without loss.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("ref", true, false, "The object to get").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("output-codec", "IPLD codec to print the object with, like dag-json or dag-cbor."),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
 
+		codec, _, err := req.Option("output-codec").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if _, ok := coredag.DefaultCodecs.Get(codec); codec != "" && !ok {
+			res.SetError(fmt.Errorf("unknown output codec %q", codec), cmdkit.ErrClient)
+			return
+		}
+
 		p, err := path.ParsePath(req.Arguments()[0])
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -228,7 +294,22 @@ format.
 			out = final
 		}
 
-		res.SetOutput(out)
+		if codec == "" {
+			res.SetOutput(out)
+			return
+		}
+
+		var data []byte
+		if len(rem) == 0 {
+			data, err = coredag.DefaultCodecs.EncodeNode(obj, codec)
+		} else {
+			data, err = coredag.DefaultCodecs.EncodeValue(out, codec)
+		}
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(bytes.NewReader(data))
 	},
 }
 
@@ -244,7 +325,7 @@ var DagResolveCmd = &cmds.Command{
 		cmdkit.StringArg("ref", true, false, "The path to resolve").EnableStdin(),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -289,6 +370,162 @@ var DagResolveCmd = &cmds.Command{
 	Type: ResolveOutput{},
 }
 
+// DiffOutput is the output type of 'dag diff' command
+type DiffOutput struct {
+	*dagutils.TreeChange
+	Err string `json:",omitempty"`
+}
+
+var DagDiffCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Display the differences between two dags.",
+		ShortDescription: `
+'ipfs dag diff' prints the paths added, removed or changed between two dags,
+of any IPLD format, like dag-pb and dag-cbor.
+`,
+		LongDescription: `
+'ipfs dag diff' prints the paths added, removed or changed between two dags,
+of any IPLD format, like dag-pb and dag-cbor.
+
+The links of dag-pb nodes are compared by name, and the fields of the other
+formats by path. When a link changed on both sides, the linked nodes are
+compared in turn, so that the changes are reported at the deepest path. The
+links unchanged are not followed, and the subtrees added or removed are
+reported at their root only.
+
+With --values, the values before and after each change are printed too: the
+CIDs for the links, and the values themselves otherwise.
+
+The changes are output as they are found, so that the diff of huge dags
+starts right away and does not need to fit in memory.
+
+Example:
+
+  > ipfs dag diff --values $(echo '{"a":1,"b":2}' | ipfs dag put) $(echo '{"a":1,"b":3,"c":4}' | ipfs dag put)
+  Changed /b: 2 -> 3
+  Added /c: 4
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("before", true, false, "The dag to compare from."),
+		cmdkit.StringArg("after", true, false, "The dag to compare to."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("values", "v", "Print the values before and after the changes."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		ctx := req.Context()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		values, _, err := req.Option("values").Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		nodes := make([]ipld.Node, 2)
+		for i, arg := range req.Arguments() {
+			p, err := path.ParsePath(arg)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+
+			nodes[i], err = core.Resolve(ctx, n.Namesys, n.Resolver, p)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+
+		go func() {
+			defer close(out)
+
+			err := dagutils.DiffTrees(ctx, n.DAG, nodes[0], nodes[1], func(c *dagutils.TreeChange) error {
+				if !values {
+					c.Before, c.After = nil, nil
+				}
+				select {
+				case out <- &DiffOutput{TreeChange: c}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if err != nil && ctx.Err() == nil {
+				select {
+				case out <- &DiffOutput{Err: err.Error()}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*DiffOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+			if out.Err != "" {
+				return nil, errors.New(out.Err)
+			}
+
+			var line string
+			switch out.Type {
+			case dagutils.Add:
+				line = "Added /" + out.Path
+				if out.After != nil {
+					line += ": " + diffValue(out.After)
+				}
+			case dagutils.Remove:
+				line = "Removed /" + out.Path
+				if out.Before != nil {
+					line += ": " + diffValue(out.Before)
+				}
+			default:
+				line = "Changed /" + out.Path
+				if out.Before != nil {
+					line += ": " + diffValue(out.Before) + " -> " + diffValue(out.After)
+				}
+			}
+			return strings.NewReader(line + "\n"), nil
+		},
+	},
+	Type: DiffOutput{},
+}
+
+// diffValue formats a value of 'dag diff' output for the terminal.
+func diffValue(v interface{}) string {
+	switch v := v.(type) {
+	case cid.Cid:
+		return v.String()
+	case string:
+		return v
+	case map[string]interface{}:
+		// a CID decoded from the JSON output of the daemon
+		if c, ok := v["/"].(string); ok && len(v) == 1 {
+			return c
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
 // copy+pasted from ../commands.go
 func unwrapOutput(i interface{}) (interface{}, error) {
 	var (
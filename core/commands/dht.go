@@ -2,7 +2,6 @@ package commands
 
 import (
 	"bytes"
-	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,24 +9,20 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	"github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
-	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
 
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 	b58 "gx/ipfs/QmWFAMPqsEyUX7gDUsRVmMWz59FxSpJ1b2v6bJ1yYzo7jY/go-base58-fast/base58"
-	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
-	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
 	notif "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing/notifications"
 	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
 )
 
 var ErrNotDHT = errors.New("routing service is not a DHT")
 
-// TODO: Factor into `ipfs dht` and `ipfs routing`.
-// Everything *except `query` goes into `ipfs routing`.
-
 var DhtCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:          "Issue commands directly through the DHT.",
@@ -46,8 +41,14 @@ var DhtCmd = &cmds.Command{
 
 var queryDhtCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline:          "Find the closest Peer IDs to a given Peer ID by querying the DHT.",
-		ShortDescription: "Outputs a list of newline-delimited Peer IDs.",
+		Tagline: "Find the closest Peer IDs to a given Peer ID by querying the DHT.",
+		ShortDescription: `
+Outputs a list of newline-delimited Peer IDs.
+
+With --verbose, every hop of the query is printed as it happens, with the time
+since the start of the query: the peers queried, their responses with the time
+they took, and their errors.
+`,
 	},
 
 	Arguments: []cmdkit.Argument{
@@ -77,7 +78,13 @@ var queryDhtCmd = &cmds.Command{
 			return
 		}
 
-		closestPeers, err := n.DHT.GetClosestPeers(ctx, string(id))
+		// the peers of both the WAN and the LAN DHTs
+		var closestPeers <-chan peer.ID
+		if n.DualDHT != nil {
+			closestPeers, err = n.DualDHT.GetClosestPeers(ctx, string(id))
+		} else {
+			closestPeers, err = n.DHT.GetClosestPeers(ctx, string(id))
+		}
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -96,53 +103,33 @@ var queryDhtCmd = &cmds.Command{
 		outChan := make(chan interface{})
 		res.SetOutput((<-chan interface{})(outChan))
 
-		go func() {
-			defer close(outChan)
-			for e := range events {
-				select {
-				case outChan <- e:
-				case <-req.Context().Done():
-					return
-				}
-			}
-		}()
+		go traceDhtQuery(req.Context(), events, outChan, notif.FinalPeer, "no peers found")
 	},
 	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func() cmds.Marshaler {
-			pfm := pfuncMap{
-				notif.PeerResponse: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
-					for _, p := range obj.Responses {
-						fmt.Fprintf(out, "%s\n", p.ID.Pretty())
-					}
-				},
-			}
-
-			return func(res cmds.Response) (io.Reader, error) {
-				v, err := unwrapOutput(res.Output())
-				if err != nil {
-					return nil, err
+		cmds.Text: dhtTraceMarshaler(map[notif.QueryEventType]dhtPrintFunc{
+			notif.FinalPeer: func(obj *DhtQueryEvent, out io.Writer, verbose bool) {
+				if verbose {
+					fmt.Fprint(out, "closest peer ")
 				}
-
-				obj, ok := v.(*notif.QueryEvent)
-				if !ok {
-					return nil, e.TypeErr(obj, v)
-				}
-
-				verbose, _, _ := res.Request().Option("v").Bool()
-
-				buf := new(bytes.Buffer)
-				printEvent(obj, buf, verbose, pfm)
-				return buf, nil
-			}
-		}(),
+				fmt.Fprintln(out, obj.ID)
+			},
+		}),
 	},
-	Type: notif.QueryEvent{},
+	Type: DhtQueryEvent{},
 }
 
 var findProvidersDhtCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline:          "Find peers that can provide a specific value, given a key.",
-		ShortDescription: "Outputs a list of newline-delimited provider Peer IDs.",
+		Tagline: "Find peers that can provide a specific value, given a key.",
+		ShortDescription: `
+Outputs a list of newline-delimited provider Peer IDs.
+
+With --verbose, every hop of the query is printed as it happens, with the time
+since the start of the query: the peers queried, their responses with the time
+they took, and their errors, which shows where a lookup stalls. When no
+provider is found, the number of peers queried and the time the query took
+are printed last.
+`,
 	},
 
 	Arguments: []cmdkit.Argument{
@@ -174,10 +161,22 @@ var findProvidersDhtCmd = &cmds.Command{
 			return
 		}
 
+		api, err := req.InvocContext().GetApi()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		c, err := cid.Parse(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		events := make(chan *notif.QueryEvent)
 		ctx := notif.RegisterForQueryEvents(req.Context(), events)
-		c, err := cid.Parse(req.Arguments()[0])
 
+		pchan, err := api.Routing().FindProviders(ctx, coreiface.IpfsPath(c), options.Routing.NumProviders(numProviders))
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -186,17 +185,7 @@ var findProvidersDhtCmd = &cmds.Command{
 		outChan := make(chan interface{})
 		res.SetOutput((<-chan interface{})(outChan))
 
-		pchan := n.Routing.FindProvidersAsync(ctx, c, numProviders)
-		go func() {
-			defer close(outChan)
-			for e := range events {
-				select {
-				case outChan <- e:
-				case <-req.Context().Done():
-					return
-				}
-			}
-		}()
+		go traceDhtQuery(req.Context(), events, outChan, notif.Provider, "no providers found")
 
 		go func() {
 			defer close(events)
@@ -210,46 +199,22 @@ var findProvidersDhtCmd = &cmds.Command{
 		}()
 	},
 	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func() func(cmds.Response) (io.Reader, error) {
-			pfm := pfuncMap{
-				notif.FinalPeer: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
-					if verbose {
-						fmt.Fprintf(out, "* closest peer %s\n", obj.ID)
-					}
-				},
-				notif.Provider: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
-					prov := obj.Responses[0]
-					if verbose {
-						fmt.Fprintf(out, "provider: ")
-					}
-					fmt.Fprintf(out, "%s\n", prov.ID.Pretty())
-					if verbose {
-						for _, a := range prov.Addrs {
-							fmt.Fprintf(out, "\t%s\n", a)
-						}
-					}
-				},
-			}
-
-			return func(res cmds.Response) (io.Reader, error) {
-				verbose, _, _ := res.Request().Option("v").Bool()
-				v, err := unwrapOutput(res.Output())
-				if err != nil {
-					return nil, err
+		cmds.Text: dhtTraceMarshaler(map[notif.QueryEventType]dhtPrintFunc{
+			notif.Provider: func(obj *DhtQueryEvent, out io.Writer, verbose bool) {
+				prov := obj.Responses[0]
+				if verbose {
+					fmt.Fprintf(out, "provider: ")
 				}
-
-				obj, ok := v.(*notif.QueryEvent)
-				if !ok {
-					return nil, e.TypeErr(obj, v)
+				fmt.Fprintf(out, "%s\n", prov.ID.Pretty())
+				if verbose {
+					for _, a := range prov.Addrs {
+						fmt.Fprintf(out, "\t%s\n", a)
+					}
 				}
-
-				buf := new(bytes.Buffer)
-				printEvent(obj, buf, verbose, pfm)
-				return buf, nil
-			}
-		}(),
+			},
+		}),
 	},
-	Type: notif.QueryEvent{},
+	Type: DhtQueryEvent{},
 }
 
 var provideRefDhtCmd = &cmds.Command{
@@ -281,6 +246,12 @@ var provideRefDhtCmd = &cmds.Command{
 			return
 		}
 
+		api, err := req.InvocContext().GetApi()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		rec, _, _ := req.Option("recursive").Bool()
 
 		var cids []cid.Cid
@@ -324,17 +295,15 @@ var provideRefDhtCmd = &cmds.Command{
 
 		go func() {
 			defer close(events)
-			var err error
-			if rec {
-				err = provideKeysRec(ctx, n.Routing, n.DAG, cids)
-			} else {
-				err = provideKeys(ctx, n.Routing, cids)
-			}
-			if err != nil {
-				notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-					Type:  notif.QueryError,
-					Extra: err.Error(),
-				})
+			for _, c := range cids {
+				err := api.Routing().Provide(ctx, coreiface.IpfsPath(c), options.Routing.Recursive(rec))
+				if err != nil {
+					notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+						Type:  notif.QueryError,
+						Extra: err.Error(),
+					})
+					return
+				}
 			}
 		}()
 	},
@@ -368,46 +337,17 @@ var provideRefDhtCmd = &cmds.Command{
 	Type: notif.QueryEvent{},
 }
 
-func provideKeys(ctx context.Context, r routing.IpfsRouting, cids []cid.Cid) error {
-	for _, c := range cids {
-		err := r.Provide(ctx, c, true)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func provideKeysRec(ctx context.Context, r routing.IpfsRouting, dserv ipld.DAGService, cids []cid.Cid) error {
-	provided := cid.NewSet()
-	for _, c := range cids {
-		kset := cid.NewSet()
-
-		err := dag.EnumerateChildrenAsync(ctx, dag.GetLinksDirect(dserv), c, kset.Visit)
-		if err != nil {
-			return err
-		}
-
-		for _, k := range kset.Keys() {
-			if provided.Has(k) {
-				continue
-			}
-
-			err = r.Provide(ctx, k, true)
-			if err != nil {
-				return err
-			}
-			provided.Add(k)
-		}
-	}
-
-	return nil
-}
-
 var findPeerDhtCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline:          "Find the multiaddresses associated with a Peer ID.",
-		ShortDescription: "Outputs a list of newline-delimited multiaddresses.",
+		Tagline: "Find the multiaddresses associated with a Peer ID.",
+		ShortDescription: `
+Outputs a list of newline-delimited multiaddresses.
+
+With --verbose, every hop of the query is printed as it happens, with the time
+since the start of the query: the peers queried, their responses with the time
+they took, and their errors. When the peer isn't found, the number of peers
+queried and the time the query took are printed last.
+`,
 	},
 
 	Arguments: []cmdkit.Argument{
@@ -440,16 +380,7 @@ var findPeerDhtCmd = &cmds.Command{
 		events := make(chan *notif.QueryEvent)
 		ctx := notif.RegisterForQueryEvents(req.Context(), events)
 
-		go func() {
-			defer close(outChan)
-			for v := range events {
-				select {
-				case outChan <- v:
-				case <-req.Context().Done():
-				}
-
-			}
-		}()
+		go traceDhtQuery(req.Context(), events, outChan, notif.FinalPeer, "peer not found")
 
 		go func() {
 			defer close(events)
@@ -469,36 +400,19 @@ var findPeerDhtCmd = &cmds.Command{
 		}()
 	},
 	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func() func(cmds.Response) (io.Reader, error) {
-			pfm := pfuncMap{
-				notif.FinalPeer: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
-					pi := obj.Responses[0]
-					for _, a := range pi.Addrs {
-						fmt.Fprintf(out, "%s\n", a)
-					}
-				},
-			}
-
-			return func(res cmds.Response) (io.Reader, error) {
-				verbose, _, _ := res.Request().Option("v").Bool()
-				v, err := unwrapOutput(res.Output())
-				if err != nil {
-					return nil, err
+		cmds.Text: dhtTraceMarshaler(map[notif.QueryEventType]dhtPrintFunc{
+			notif.FinalPeer: func(obj *DhtQueryEvent, out io.Writer, verbose bool) {
+				pi := obj.Responses[0]
+				if verbose {
+					fmt.Fprintf(out, "found %s\n", pi.ID.Pretty())
 				}
-
-				obj, ok := v.(*notif.QueryEvent)
-				if !ok {
-					return nil, e.TypeErr(obj, v)
+				for _, a := range pi.Addrs {
+					fmt.Fprintf(out, "%s\n", a)
 				}
-
-				buf := new(bytes.Buffer)
-				printEvent(obj, buf, verbose, pfm)
-
-				return buf, nil
-			}
-		}(),
+			},
+		}),
 	},
-	Type: notif.QueryEvent{},
+	Type: DhtQueryEvent{},
 }
 
 var getValueDhtCmd = &cmds.Command{
@@ -533,6 +447,12 @@ Different key types can specify other 'best' rules.
 			return
 		}
 
+		api, err := req.InvocContext().GetApi()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		dhtkey, err := escapeDhtKey(req.Arguments()[0])
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -557,7 +477,7 @@ Different key types can specify other 'best' rules.
 
 		go func() {
 			defer close(events)
-			val, err := n.Routing.GetValue(ctx, dhtkey)
+			val, err := api.Routing().GetValue(ctx, dhtkey)
 			if err != nil {
 				notif.PublishQueryEvent(ctx, &notif.QueryEvent{
 					Type:  notif.QueryError,
@@ -647,6 +567,12 @@ NOTE: A value may not exceed 2048 bytes.
 			return
 		}
 
+		api, err := req.InvocContext().GetApi()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		events := make(chan *notif.QueryEvent)
 		ctx := notif.RegisterForQueryEvents(req.Context(), events)
 
@@ -674,7 +600,7 @@ NOTE: A value may not exceed 2048 bytes.
 
 		go func() {
 			defer close(events)
-			err := n.Routing.PutValue(ctx, key, []byte(data))
+			err := api.Routing().PutValue(ctx, key, []byte(data))
 			if err != nil {
 				notif.PublishQueryEvent(ctx, &notif.QueryEvent{
 					Type:  notif.QueryError,
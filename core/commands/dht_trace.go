@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	notif "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing/notifications"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+// DhtQueryEvent is an event of a query of the DHT, with its timing. The
+// fields of notif.QueryEvent keep their names, ID being the base58 encoded
+// peer ID.
+type DhtQueryEvent struct {
+	ID        string `json:",omitempty"`
+	Type      notif.QueryEventType
+	Responses []*pstore.PeerInfo `json:",omitempty"`
+	Extra     string             `json:",omitempty"`
+
+	// Time is the time of the event since the start of the query.
+	Time time.Duration
+	// Hop is the number of peers the query went through to reach the peer
+	// of the event: 1 for the peers of the routing table, 2 for the peers
+	// they returned, and so on. 0 when the event has no peer.
+	Hop int `json:",omitempty"`
+	// Duration is, for the responses and the errors of the peers, the time
+	// since the peer was queried.
+	Duration time.Duration `json:",omitempty"`
+}
+
+// dhtTracer times the events of a query of the DHT, and follows its hops.
+type dhtTracer struct {
+	start   time.Time
+	sent    map[peer.ID]time.Time
+	hops    map[peer.ID]int
+	queried int
+	found   bool
+
+	// now is replaced by the tests
+	now func() time.Time
+}
+
+func newDhtTracer() *dhtTracer {
+	t := &dhtTracer{
+		sent: make(map[peer.ID]time.Time),
+		hops: make(map[peer.ID]int),
+		now:  time.Now,
+	}
+	t.start = t.now()
+	return t
+}
+
+// hop returns the hop of p, the peers not returned by another peer coming
+// from the routing table.
+func (t *dhtTracer) hop(p peer.ID) int {
+	if h, ok := t.hops[p]; ok {
+		return h
+	}
+	return 1
+}
+
+// trace returns ev with its timing. The events of type found are the
+// results of the query.
+func (t *dhtTracer) trace(ev *notif.QueryEvent, found notif.QueryEventType) *DhtQueryEvent {
+	now := t.now()
+	out := &DhtQueryEvent{
+		Type:      ev.Type,
+		Responses: ev.Responses,
+		Extra:     ev.Extra,
+		Time:      now.Sub(t.start),
+	}
+	if ev.Type == found {
+		t.found = true
+	}
+	if ev.ID == "" {
+		if ev.Type == notif.QueryError {
+			// the error of the whole query
+			t.found = true
+			out.Extra = fmt.Sprintf("%s (%s)", ev.Extra, t.progress(now))
+		}
+		return out
+	}
+
+	out.ID = ev.ID.Pretty()
+	out.Hop = t.hop(ev.ID)
+	switch ev.Type {
+	case notif.SendingQuery:
+		t.sent[ev.ID] = now
+		t.hops[ev.ID] = out.Hop
+		t.queried++
+	case notif.PeerResponse, notif.QueryError:
+		if sent, ok := t.sent[ev.ID]; ok {
+			out.Duration = now.Sub(sent)
+			delete(t.sent, ev.ID)
+		}
+		if ev.Type == notif.PeerResponse {
+			for _, pi := range ev.Responses {
+				if _, ok := t.hops[pi.ID]; !ok {
+					t.hops[pi.ID] = out.Hop + 1
+				}
+			}
+		}
+	}
+	return out
+}
+
+// finish returns the error to report when the query found nothing, nil
+// otherwise.
+func (t *dhtTracer) finish(notFound string) *DhtQueryEvent {
+	if t.found {
+		return nil
+	}
+	now := t.now()
+	return &DhtQueryEvent{
+		Type:  notif.QueryError,
+		Extra: fmt.Sprintf("%s (%s)", notFound, t.progress(now)),
+		Time:  now.Sub(t.start),
+	}
+}
+
+func (t *dhtTracer) progress(now time.Time) string {
+	s := fmt.Sprintf("queried %d peers in %s", t.queried, now.Sub(t.start).Round(time.Millisecond))
+	if len(t.sent) > 0 {
+		s += fmt.Sprintf(", %d without response", len(t.sent))
+	}
+	return s
+}
+
+// traceDhtQuery sends the events of a query to out with their timing, until
+// events is closed, followed by an error when no event of type found was
+// sent.
+func traceDhtQuery(ctx context.Context, events <-chan *notif.QueryEvent, out chan<- interface{}, found notif.QueryEventType, notFound string) {
+	defer close(out)
+	t := newDhtTracer()
+	send := func(ev *DhtQueryEvent) bool {
+		select {
+		case out <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for ev := range events {
+		if !send(t.trace(ev, found)) {
+			// drained, so that the query doesn't block
+			for range events {
+			}
+			return
+		}
+	}
+	if ev := t.finish(notFound); ev != nil {
+		send(ev)
+	}
+}
+
+type dhtPrintFunc func(obj *DhtQueryEvent, out io.Writer, verbose bool)
+
+// dhtTraceMarshaler prints the events of a traced query. The results are
+// printed with the functions of override, and only them without --verbose,
+// so that the output can be piped.
+func dhtTraceMarshaler(override map[notif.QueryEventType]dhtPrintFunc) cmds.Marshaler {
+	return func(res cmds.Response) (io.Reader, error) {
+		verbose, _, _ := res.Request().Option("v").Bool()
+		v, err := unwrapOutput(res.Output())
+		if err != nil {
+			return nil, err
+		}
+
+		obj, ok := v.(*DhtQueryEvent)
+		if !ok {
+			return nil, e.TypeErr(obj, v)
+		}
+
+		buf := new(bytes.Buffer)
+		printTracedEvent(obj, buf, verbose, override)
+		return buf, nil
+	}
+}
+
+func printTracedEvent(obj *DhtQueryEvent, out io.Writer, verbose bool, override map[notif.QueryEventType]dhtPrintFunc) {
+	if verbose {
+		fmt.Fprintf(out, "%8.3fs ", obj.Time.Seconds())
+		if obj.Hop > 0 {
+			fmt.Fprintf(out, "hop %d: ", obj.Hop)
+		}
+	}
+
+	if pf, ok := override[obj.Type]; ok {
+		pf(obj, out, verbose)
+		return
+	}
+
+	switch obj.Type {
+	case notif.SendingQuery:
+		if verbose {
+			fmt.Fprintf(out, "querying %s\n", obj.ID)
+		}
+	case notif.PeerResponse:
+		if verbose {
+			fmt.Fprintf(out, "%s replied in %s with %d closer peers:", obj.ID, obj.Duration.Round(time.Millisecond), len(obj.Responses))
+			for _, p := range obj.Responses {
+				fmt.Fprintf(out, " %s", p.ID.Pretty())
+			}
+			fmt.Fprintln(out)
+		}
+	case notif.QueryError:
+		switch {
+		case !verbose:
+		case obj.ID == "":
+			fmt.Fprintf(out, "error: %s\n", obj.Extra)
+		default:
+			fmt.Fprintf(out, "%s failed after %s: %s\n", obj.ID, obj.Duration.Round(time.Millisecond), obj.Extra)
+		}
+	case notif.DialingPeer:
+		if verbose {
+			fmt.Fprintf(out, "dialing %s\n", obj.ID)
+		}
+	case notif.AddingPeer:
+		if verbose {
+			fmt.Fprintf(out, "adding %s to the query\n", obj.ID)
+		}
+	case notif.FinalPeer:
+		if verbose {
+			fmt.Fprintf(out, "closest peer %s\n", obj.ID)
+		}
+	default:
+		if verbose {
+			fmt.Fprintf(out, "unrecognized event type: %d\n", obj.Type)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tu "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
+	notif "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing/notifications"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+func TestDhtTracer(t *testing.T) {
+	near := tu.RandPeerIDFatal(t)
+	far := tu.RandPeerIDFatal(t)
+
+	now := time.Unix(1000, 0)
+	tr := newDhtTracer()
+	tr.start = now
+	tr.now = func() time.Time { return now }
+
+	tr.trace(&notif.QueryEvent{ID: near, Type: notif.SendingQuery}, notif.Provider)
+	now = now.Add(300 * time.Millisecond)
+	ev := tr.trace(&notif.QueryEvent{
+		ID:        near,
+		Type:      notif.PeerResponse,
+		Responses: []*pstore.PeerInfo{{ID: far}},
+	}, notif.Provider)
+	if ev.Hop != 1 || ev.Duration != 300*time.Millisecond || ev.ID != near.Pretty() {
+		t.Fatalf("expected a response of hop 1 in 300ms, got %+v", ev)
+	}
+
+	ev = tr.trace(&notif.QueryEvent{ID: far, Type: notif.SendingQuery}, notif.Provider)
+	if ev.Hop != 2 || ev.Time != 300*time.Millisecond {
+		t.Fatalf("expected the peer returned to be queried at hop 2, got %+v", ev)
+	}
+
+	// the query ends without provider, waiting for far
+	now = now.Add(time.Minute)
+	ev = tr.finish("no providers found")
+	if ev == nil || ev.Type != notif.QueryError {
+		t.Fatalf("expected an error, got %+v", ev)
+	}
+	if !strings.Contains(ev.Extra, "queried 2 peers in 1m0.3s, 1 without response") {
+		t.Fatalf("unexpected error %q", ev.Extra)
+	}
+
+	tr.trace(&notif.QueryEvent{Type: notif.Provider, Responses: []*pstore.PeerInfo{{ID: far}}}, notif.Provider)
+	if ev := tr.finish("no providers found"); ev != nil {
+		t.Fatalf("expected no error once a provider is found, got %+v", ev)
+	}
+}
@@ -1,8 +1,9 @@
 package commands
 
 import (
-	cmds "github.com/ipfs/go-ipfs/commands"
+	lgc "github.com/ipfs/go-ipfs/commands/legacy"
 
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 )
 
@@ -12,7 +13,10 @@ var DiagCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"sys":  sysDiagCmd,
-		"cmds": ActiveReqsCmd,
+		"sys":          lgc.NewCommand(sysDiagCmd),
+		"cmds":         lgc.NewCommand(ActiveReqsCmd),
+		"reachability": lgc.NewCommand(diagReachabilityCmd),
+		"profile":      diagProfileCmd,
+		"net":          diagNetCmd,
 	},
 }
@@ -0,0 +1,274 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	geoip "github.com/ipfs/go-ipfs/geoip"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+const (
+	diagNetGeoIPOptionName     = "geoip-db"
+	diagNetProtocolsOptionName = "protocols"
+)
+
+// NetConn is a connection of the network snapshot.
+type NetConn struct {
+	Peer      string
+	Addr      string
+	Transport string
+	Direction string
+	// Latency is the moving average of the latency to the peer, 0 when
+	// it wasn't measured.
+	Latency time.Duration
+	// Age is how long the connection has been open, 0 when unknown.
+	Age       time.Duration
+	Streams   int
+	Protocols []string `json:",omitempty"`
+	Country   string   `json:",omitempty"`
+	ASN       int      `json:",omitempty"`
+}
+
+// NetGroup counts the peers of a country and autonomous system.
+type NetGroup struct {
+	Country string
+	ASN     int
+	ASName  string
+	Peers   int
+}
+
+// DiagNetOutput is the network snapshot.
+type DiagNetOutput struct {
+	Time       time.Time
+	Peers      int
+	Conns      []NetConn
+	Transports map[string]int
+	Directions map[string]int
+	Groups     []NetGroup `json:",omitempty"`
+}
+
+var diagNetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print a snapshot of the connections of the node.",
+		ShortDescription: `
+'ipfs diag net' prints the open connections with their transport, direction,
+latency, age and number of streams, followed by the number of connections
+per transport and direction.
+
+With --protocols, the protocols each peer supports are listed too.
+
+With --geoip-db, the connections are also grouped by the country and the
+autonomous system of the remote address, looked up in an offline database.
+The database is a tab separated file, optionally gzipped, in the format of
+the ip2asn databases of https://iptoasn.com:
+
+  range_start  range_end  AS_number  country_code  AS_description
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(diagNetGeoIPOptionName, "Path to the database to look up the country and AS of the peers in."),
+		cmdkit.BoolOption(diagNetProtocolsOptionName, "List the protocols supported by the peers."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if n.PeerHost == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		var db *geoip.DB
+		if path, _ := req.Options[diagNetGeoIPOptionName].(string); path != "" {
+			db, err = geoip.Open(path)
+			if err != nil {
+				return fmt.Errorf("loading the GeoIP database: %s", err)
+			}
+		}
+		protocols, _ := req.Options[diagNetProtocolsOptionName].(bool)
+
+		now := time.Now()
+		out := &DiagNetOutput{
+			Time:       now,
+			Peers:      len(n.PeerHost.Network().Peers()),
+			Conns:      []NetConn{},
+			Transports: make(map[string]int),
+			Directions: make(map[string]int),
+		}
+		groups := make(map[int]*NetGroup)
+		grouped := make(map[string]bool)
+		for _, c := range n.PeerHost.Network().Conns() {
+			pid := c.RemotePeer()
+			addr := c.RemoteMultiaddr()
+			nc := NetConn{
+				Peer:      pid.Pretty(),
+				Addr:      addr.String(),
+				Transport: transportName(addr),
+				Direction: directionString(c.Stat().Direction),
+				Latency:   n.Peerstore.LatencyEWMA(pid),
+				Streams:   len(c.GetStreams()),
+			}
+			if nc.Direction == "" {
+				nc.Direction = "unknown"
+			}
+
+			// the connection manager notes when it was told about the
+			// connections
+			if info := n.PeerHost.ConnManager().GetTagInfo(pid); info != nil {
+				if opened, ok := info.Conns[addr.String()]; ok {
+					nc.Age = now.Sub(opened)
+				}
+			}
+
+			if protocols {
+				nc.Protocols, err = n.Peerstore.GetProtocols(pid)
+				if err != nil {
+					return err
+				}
+				sort.Strings(nc.Protocols)
+			}
+
+			if db != nil {
+				if info, ok := lookupAddr(db, addr); ok {
+					nc.Country = info.Country
+					nc.ASN = info.ASN
+					g, ok := groups[info.ASN]
+					if !ok {
+						g = &NetGroup{Country: info.Country, ASN: info.ASN, ASName: info.ASName}
+						groups[info.ASN] = g
+					}
+					// peers with several connections count once
+					if !grouped[nc.Peer] {
+						grouped[nc.Peer] = true
+						g.Peers++
+					}
+				}
+			}
+
+			out.Transports[nc.Transport]++
+			out.Directions[nc.Direction]++
+			out.Conns = append(out.Conns, nc)
+		}
+
+		sort.Slice(out.Conns, func(i, j int) bool {
+			if out.Conns[i].Peer != out.Conns[j].Peer {
+				return out.Conns[i].Peer < out.Conns[j].Peer
+			}
+			return out.Conns[i].Addr < out.Conns[j].Addr
+		})
+		for _, g := range groups {
+			out.Groups = append(out.Groups, *g)
+		}
+		sort.Slice(out.Groups, func(i, j int) bool {
+			if out.Groups[i].Peers != out.Groups[j].Peers {
+				return out.Groups[i].Peers > out.Groups[j].Peers
+			}
+			return out.Groups[i].ASN < out.Groups[j].ASN
+		})
+
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DiagNetOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			writeDiagNet(w, out)
+			return nil
+		}),
+	},
+	Type: DiagNetOutput{},
+}
+
+// lookupAddr looks up the IP address of a multiaddr. For relayed
+// connections, that's the address of the relay.
+func lookupAddr(db *geoip.DB, addr ma.Multiaddr) (geoip.Info, bool) {
+	for _, code := range []int{ma.P_IP4, ma.P_IP6} {
+		if s, err := addr.ValueForProtocol(code); err == nil {
+			return db.Lookup(net.ParseIP(s))
+		}
+	}
+	return geoip.Info{}, false
+}
+
+func writeDiagNet(w io.Writer, out *DiagNetOutput) {
+	fmt.Fprintf(w, "Snapshot of %s: %d peers, %d connections\n\n",
+		out.Time.Format(time.RFC3339), out.Peers, len(out.Conns))
+
+	tw := tabwriter.NewWriter(w, 1, 2, 2, ' ', 0)
+	geo := len(out.Groups) > 0
+	if geo {
+		fmt.Fprintln(tw, "Peer\tAddress\tTransport\tDirection\tLatency\tAge\tStreams\tCountry\tASN")
+	} else {
+		fmt.Fprintln(tw, "Peer\tAddress\tTransport\tDirection\tLatency\tAge\tStreams")
+	}
+	for _, c := range out.Conns {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d", c.Peer, c.Addr, c.Transport,
+			c.Direction, formatNetDuration(c.Latency, time.Microsecond), formatNetDuration(c.Age, time.Second), c.Streams)
+		if geo {
+			country, asn := "-", "-"
+			if c.ASN != 0 {
+				country, asn = c.Country, fmt.Sprintf("AS%d", c.ASN)
+			}
+			fmt.Fprintf(tw, "\t%s\t%s", country, asn)
+		}
+		fmt.Fprintln(tw)
+		if len(c.Protocols) > 0 {
+			fmt.Fprintf(tw, "  %s\n", strings.Join(c.Protocols, " "))
+		}
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	writeNetCounts(w, "Transports", out.Transports)
+	writeNetCounts(w, "Directions", out.Directions)
+
+	if geo {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 1, 2, 2, ' ', 0)
+		fmt.Fprintln(tw, "Peers\tCountry\tASN\tAS name")
+		for _, g := range out.Groups {
+			fmt.Fprintf(tw, "%d\t%s\tAS%d\t%s\n", g.Peers, g.Country, g.ASN, g.ASName)
+		}
+		tw.Flush()
+	}
+}
+
+func writeNetCounts(w io.Writer, name string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "%s:", name)
+	for _, k := range keys {
+		fmt.Fprintf(w, " %s=%d", k, counts[k])
+	}
+	fmt.Fprintln(w)
+}
+
+// formatNetDuration rounds d to unit, or returns "n/a" when it is unknown.
+func formatNetDuration(d, unit time.Duration) string {
+	if d <= 0 {
+		return "n/a"
+	}
+	if d < unit {
+		return d.String()
+	}
+	return (d / unit * unit).String()
+}
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	oldcmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	profile "github.com/ipfs/go-ipfs/profile"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+)
+
+const (
+	profileOutputOptionName     = "output"
+	profileTimeOptionName       = "profile-time"
+	mutexFractionOptionName     = "mutex-profile-fraction"
+	profileCollectorsOptionName = "collectors"
+)
+
+var diagProfileCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Collect a diagnostics bundle of the daemon.",
+		ShortDescription: `
+'ipfs diag profile' writes a zip archive to attach to bug reports. It holds:
+
+  goroutines.stacks  the stacks of all goroutines
+  heap.pprof         the heap profile
+  version.json       the versions of go-ipfs and Go, and the system
+  metrics.txt        the prometheus metrics
+  config.json        the config, without the private key
+  cpu.pprof          the CPU profile, sampled for --profile-time
+  mutex.pprof        the mutex contention, sampled for --profile-time
+
+The parts are selected with --collectors, a comma separated list of
+goroutines, heap, version, metrics, config, cpu and mutex. All of them are
+collected by default.
+
+The archive is written to './ipfs-profile-<time>.zip', unless another path
+is given with '--output=<path>'. The profiles can be read with 'go tool
+pprof'.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(profileOutputOptionName, "o", "The path where the archive should be stored."),
+		cmdkit.StringOption(profileTimeOptionName, "The time the CPU and mutex profiles are sampled.").WithDefault(profile.DefaultProfileTime.String()),
+		cmdkit.IntOption(mutexFractionOptionName, "Report 1 in that many mutex contention events.").WithDefault(profile.DefaultMutexProfileFraction),
+		cmdkit.StringOption(profileCollectorsOptionName, "Comma separated list of the parts to collect.").WithDefault(strings.Join(profile.AllCollectors, ",")),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		_, err := getProfileOptions(req)
+		return err
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		opts, err := getProfileOptions(req)
+		if err != nil {
+			return err
+		}
+
+		cctx, ok := env.(*oldcmds.Context)
+		if !ok {
+			return fmt.Errorf("expected env to be of type %T, got %T", cctx, env)
+		}
+		opts.Config, err = redactedConfig(cctx.ConfigRoot)
+		if err != nil {
+			return err
+		}
+
+		r, w := io.Pipe()
+		go func() {
+			zw := zip.NewWriter(w)
+			err := profile.WriteProfiles(req.Context, zw, opts)
+			if cerr := zw.Close(); err == nil {
+				err = cerr
+			}
+			w.CloseWithError(err)
+		}()
+		return res.Emit(r)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+
+			outReader, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(outReader, v))
+			}
+
+			outPath, _ := res.Request().Options[profileOutputOptionName].(string)
+			if outPath == "" {
+				outPath = "ipfs-profile-" + time.Now().Format("2006-01-02T15-04-05") + ".zip"
+			}
+
+			f, err := os.Create(outPath)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, outReader); err != nil {
+				f.Close()
+				os.Remove(outPath)
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "Wrote %s\n", outPath)
+			return nil
+		},
+	},
+}
+
+func getProfileOptions(req *cmds.Request) (profile.Options, error) {
+	var opts profile.Options
+
+	timeS, _ := req.Options[profileTimeOptionName].(string)
+	d, err := time.ParseDuration(timeS)
+	if err != nil {
+		return opts, fmt.Errorf("invalid profile time: %s", err)
+	}
+	if d <= 0 {
+		return opts, fmt.Errorf("profile time must be positive")
+	}
+	opts.ProfileTime = d
+
+	fraction, _ := req.Options[mutexFractionOptionName].(int)
+	if fraction <= 0 {
+		return opts, fmt.Errorf("mutex profile fraction must be positive")
+	}
+	opts.MutexProfileFraction = fraction
+
+	collectors, _ := req.Options[profileCollectorsOptionName].(string)
+	for _, c := range strings.Split(collectors, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			opts.Collectors = append(opts.Collectors, c)
+		}
+	}
+	if len(opts.Collectors) == 0 {
+		return opts, fmt.Errorf("no collector selected")
+	}
+	if err := profile.CheckCollectors(opts.Collectors); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// redactedConfig reads the config file like 'ipfs config show', without the
+// private key.
+func redactedConfig(configRoot string) ([]byte, error) {
+	fname, err := config.Filename(configRoot)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if err := scrubValue(cfg, []string{config.IdentityTag, config.PrivKeyTag}); err != nil {
+		return nil, err
+	}
+	return config.HumanOutput(cfg)
+}
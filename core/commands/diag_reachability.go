@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	autonat "github.com/ipfs/go-ipfs/autonat"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	circuit "gx/ipfs/QmWX6RySJ3yAYmfjLSw1LtRZnDh5oVeA9kM3scNQJkysqa/go-libp2p-circuit"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+type ReachabilityOutput struct {
+	Reachability  autonat.Reachability
+	Confidence    int
+	PublicAddr    string `json:",omitempty"`
+	ObservedAddrs []string
+	LastProbe     *time.Time `json:",omitempty"`
+	Probes        autonat.ProbeStats
+	Service       *autonat.ServiceStats `json:",omitempty"`
+}
+
+var diagReachabilityCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print whether the node is reachable from the internet.",
+		ShortDescription: `
+'ipfs diag reachability' prints the reachability of the node as determined by
+AutoNAT: connected peers are periodically asked to dial the node back. The
+status is "public" when they can, "private" when they can't and "unknown"
+until a peer answered.
+
+Also printed are the addresses other peers observed or the NAT mapped for the
+node, the probe statistics and, unless AutoNAT.ServiceMode is "disabled", the
+statistics of the probes answered for other peers.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.AutoNAT == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		st := n.AutoNAT.Status()
+		out := &ReachabilityOutput{
+			Reachability:  st.Reachability,
+			Confidence:    st.Confidence,
+			ObservedAddrs: []string{},
+			Probes:        st.Stats,
+		}
+		if st.PublicAddr != nil {
+			out.PublicAddr = st.PublicAddr.String()
+		}
+		if !st.LastProbe.IsZero() {
+			out.LastProbe = &st.LastProbe
+		}
+		if n.AutoNATService != nil {
+			sst := n.AutoNATService.Stats()
+			out.Service = &sst
+		}
+
+		// addresses of the host that aren't listen addresses were learned
+		// from other peers or the NAT.
+		listen, err := n.PeerHost.Network().InterfaceListenAddresses()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		for _, a := range n.PeerHost.Addrs() {
+			if _, err := a.ValueForProtocol(circuit.P_CIRCUIT); err == nil {
+				continue
+			}
+			if !containsAddr(listen, a) {
+				out.ObservedAddrs = append(out.ObservedAddrs, a.String())
+			}
+		}
+
+		res.SetOutput(out)
+	},
+	Type: ReachabilityOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*ReachabilityOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Reachability: %s (confidence %d)\n", out.Reachability, out.Confidence)
+			if out.PublicAddr != "" {
+				fmt.Fprintf(buf, "Public address: %s\n", out.PublicAddr)
+			}
+			if out.LastProbe != nil {
+				fmt.Fprintf(buf, "Last probe: %s\n", out.LastProbe.Format(time.RFC3339))
+			}
+			fmt.Fprintln(buf, "Observed addresses:")
+			for _, a := range out.ObservedAddrs {
+				fmt.Fprintf(buf, "\t%s\n", a)
+			}
+			fmt.Fprintf(buf, "Probes: %d requests, %d successes, %d dial errors, %d errors\n",
+				out.Probes.Requests, out.Probes.Successes, out.Probes.DialErrors, out.Probes.Errors)
+			if out.Service != nil {
+				fmt.Fprintf(buf, "Service: %d successes, %d dial errors, %d throttled, %d refused\n",
+					out.Service.Successes, out.Service.DialErrors, out.Service.Throttled, out.Service.Refused)
+			}
+			return buf, nil
+		},
+	},
+}
+
+func containsAddr(addrs []ma.Multiaddr, a ma.Multiaddr) bool {
+	for _, b := range addrs {
+		if a.Equal(b) {
+			return true
+		}
+	}
+	return false
+}
@@ -10,12 +10,17 @@ import (
 	gopath "path"
 	"sort"
 	"strings"
+	"time"
 
+	chunk "github.com/ipfs/go-ipfs/chunker"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
 	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
 	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
 	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
@@ -58,16 +63,17 @@ operations.
 		cmdkit.BoolOption("f", "flush", "Flush target and ancestors after write.").WithDefault(true),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  lgc.NewCommand(filesReadCmd),
-		"write": filesWriteCmd,
-		"mv":    lgc.NewCommand(filesMvCmd),
-		"cp":    lgc.NewCommand(filesCpCmd),
-		"ls":    lgc.NewCommand(filesLsCmd),
-		"mkdir": lgc.NewCommand(filesMkdirCmd),
-		"stat":  filesStatCmd,
-		"rm":    lgc.NewCommand(filesRmCmd),
-		"flush": lgc.NewCommand(filesFlushCmd),
-		"chcid": lgc.NewCommand(filesChcidCmd),
+		"read":     lgc.NewCommand(filesReadCmd),
+		"write":    filesWriteCmd,
+		"mv":       lgc.NewCommand(filesMvCmd),
+		"cp":       lgc.NewCommand(filesCpCmd),
+		"ls":       lgc.NewCommand(filesLsCmd),
+		"mkdir":    lgc.NewCommand(filesMkdirCmd),
+		"stat":     filesStatCmd,
+		"rm":       lgc.NewCommand(filesRmCmd),
+		"flush":    lgc.NewCommand(filesFlushCmd),
+		"chcid":    lgc.NewCommand(filesChcidCmd),
+		"snapshot": filesSnapshotCmd,
 	},
 }
 
@@ -85,6 +91,14 @@ type statOutput struct {
 	WithLocality   bool   `json:",omitempty"`
 	Local          bool   `json:",omitempty"`
 	SizeLocal      uint64 `json:",omitempty"`
+
+	// the attributes stored by 'ipfs add --preserve-mode --preserve-mtime'
+	Mode       string `json:",omitempty"`
+	Mtime      int64  `json:",omitempty"`
+	MtimeNsecs int    `json:",omitempty"`
+
+	// MIME is the MIME type of a file, detected from its first bytes
+	MIME string `json:",omitempty"`
 }
 
 const defaultStatFormat = `<hash>
@@ -103,14 +117,15 @@ var filesStatCmd = &cmds.Command{
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("format", "Print statistics in given format. Allowed tokens: "+
-			"<hash> <size> <cumulsize> <type> <childs>. Conflicts with other format options.").WithDefault(defaultStatFormat),
+			"<hash> <size> <cumulsize> <type> <childs> <mime>. Conflicts with other format options.").WithDefault(defaultStatFormat),
 		cmdkit.BoolOption("hash", "Print only hash. Implies '--format=<hash>'. Conflicts with other format options."),
 		cmdkit.BoolOption("size", "Print only size. Implies '--format=<cumulsize>'. Conflicts with other format options."),
 		cmdkit.BoolOption("with-local", "Compute the amount of the dag that is local, and if possible the total size"),
+		cmdkit.BoolOption("mime", "Detect the MIME type of a file from its first bytes. Implied by '<mime>' in the format."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 
-		_, err := statGetFormatOptions(req)
+		format, err := statGetFormatOptions(req)
 		if err != nil {
 			return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
 		}
@@ -148,6 +163,13 @@ var filesStatCmd = &cmds.Command{
 			return err
 		}
 
+		withMIME, _ := req.Options["mime"].(bool)
+		if withMIME || strings.Contains(format, "<mime>") {
+			if o.MIME, err = coreunix.DetectMIME(req.Context, dagserv, nd); err != nil {
+				return err
+			}
+		}
+
 		if !withLocal {
 			return cmds.EmitOnce(res, o)
 		}
@@ -167,15 +189,28 @@ var filesStatCmd = &cmds.Command{
 				return e.TypeErr(out, v)
 			}
 
-			s, _ := statGetFormatOptions(req)
-			s = strings.Replace(s, "<hash>", out.Hash, -1)
+			format, _ := statGetFormatOptions(req)
+			s := strings.Replace(format, "<hash>", out.Hash, -1)
 			s = strings.Replace(s, "<size>", fmt.Sprintf("%d", out.Size), -1)
 			s = strings.Replace(s, "<cumulsize>", fmt.Sprintf("%d", out.CumulativeSize), -1)
 			s = strings.Replace(s, "<childs>", fmt.Sprintf("%d", out.Blocks), -1)
 			s = strings.Replace(s, "<type>", out.Type, -1)
+			s = strings.Replace(s, "<mime>", out.MIME, -1)
 
 			fmt.Fprintln(w, s)
 
+			withMIME, _ := req.Options["mime"].(bool)
+			if withMIME && out.MIME != "" && !strings.Contains(format, "<mime>") {
+				fmt.Fprintf(w, "MIME: %s\n", out.MIME)
+			}
+
+			if out.Mode != "" {
+				fmt.Fprintf(w, "Mode: %s\n", out.Mode)
+			}
+			if out.Mtime != 0 || out.MtimeNsecs != 0 {
+				fmt.Fprintf(w, "Mtime: %s\n", time.Unix(out.Mtime, int64(out.MtimeNsecs)).Format(time.RFC3339Nano))
+			}
+
 			if out.WithLocality {
 				fmt.Fprintf(w, "Local: %s of %s (%.2f%%)\n",
 					humanize.Bytes(out.SizeLocal),
@@ -238,13 +273,26 @@ func statNode(nd ipld.Node) (*statOutput, error) {
 			return nil, fmt.Errorf("unrecognized node type: %s", d.GetType())
 		}
 
-		return &statOutput{
+		o := &statOutput{
 			Hash:           c.String(),
 			Blocks:         len(nd.Links()),
 			Size:           d.GetFilesize(),
 			CumulativeSize: cumulsize,
 			Type:           ndtype,
-		}, nil
+		}
+
+		attrs, err := coreunix.FileAttrsFromData(n.Data())
+		if err != nil {
+			return nil, err
+		}
+		if attrs.HasMode {
+			o.Mode = fmt.Sprintf("%04o", attrs.UnixMode())
+		}
+		if !attrs.Mtime.IsZero() {
+			o.Mtime = attrs.Mtime.Unix()
+			o.MtimeNsecs = attrs.Mtime.Nanosecond()
+		}
+		return o, nil
 	case *dag.RawNode:
 		return &statOutput{
 			Hash:           c.String(),
@@ -688,11 +736,20 @@ If the '--create' option is specified, the file will be created if it does not
 exist. Nonexistant intermediate directories will not be created.
 
 Newly created files will have the same CID version and hash function of the
-parent directory unless the --cid-version and --hash options are used.
+parent directory unless the --cid-version and --hash options are used, or the
+"Import.CidVersion" and "Import.HashFunction" config keys are set.
 
 Newly created leaves will be in the legacy format (Protobuf) if the
 CID version is 0, or raw is the CID version is non-zero.  Use of the
---raw-leaves option will override this behavior.
+--raw-leaves option or of the "Import.RawLeaves" config key will override
+this behavior.
+
+When the '--chunker' option or the "Import.UnixFSChunker" config key is set
+and the data replaces the whole file, that is when the file is created or the
+'--truncate' option is given, without an offset, the file is imported as
+'ipfs add' would, split with that chunker, and gets the same CID. The writes
+to a part of an existing file keep its layout, and refuse the '--chunker'
+option.
 
 If the '--flush' option is set to false, changes will not be propogated to the
 merkledag root. This can make operations much faster when doing a large number
@@ -721,6 +778,7 @@ stat' on the file or any of its ancestors.
 		cmdkit.BoolOption("truncate", "t", "Truncate the file to size zero before writing."),
 		cmdkit.IntOption("count", "n", "Maximum number of bytes to read."),
 		cmdkit.BoolOption("raw-leaves", "Use raw blocks for newly created leaf nodes. (experimental)"),
+		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm of the file written whole, size-[bytes], rabin-[min]-[avg]-[max] or buzhash. Defaults to Import.UnixFSChunker."),
 		cidVersionOption,
 		hashOption,
 	},
@@ -736,12 +794,20 @@ stat' on the file or any of its ancestors.
 		flush, _ := req.Options["flush"].(bool)
 		rawLeaves, rawLeavesDef := req.Options["raw-leaves"].(bool)
 
-		prefix, err := getPrefixNew(req)
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		importCfg, err := extconfig.LoadImport(nd.Repo)
 		if err != nil {
 			return err
 		}
+		if !rawLeavesDef && importCfg.RawLeaves != extconfig.Default {
+			rawLeaves, rawLeavesDef = importCfg.RawLeaves.WithDefault(false), true
+		}
 
-		nd, err := cmdenv.GetNode(env)
+		prefix, err := getPrefixNew(req, importCfg)
 		if err != nil {
 			return err
 		}
@@ -758,6 +824,47 @@ stat' on the file or any of its ancestors.
 			}
 		}
 
+		count, countfound := req.Options["count"].(int)
+		if countfound && count < 0 {
+			return fmt.Errorf("cannot have negative byte count")
+		}
+
+		// with a chunker set, the data replacing the whole file is imported
+		// as by ipfs add
+		chunker, chunkerSet := req.Options[chunkerOptionName].(string)
+		if !chunkerSet {
+			chunker = importCfg.UnixFSChunker
+		}
+		if chunker != "" {
+			if err := chunk.Validate(chunker); err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+			}
+		}
+		_, err = mfs.Lookup(nd.FilesRoot, path)
+		whole := offset == 0 && ((err == nil && trunc) || (err == os.ErrNotExist && create))
+		if chunkerSet && !whole {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the --chunker option only applies to the files written whole, created or truncated, from offset 0")
+		}
+		if chunker != "" && whole {
+			input, err := req.Files.NextFile()
+			if err != nil {
+				return err
+			}
+
+			var r io.Reader = input
+			if countfound {
+				r = io.LimitReader(r, int64(count))
+			}
+
+			opts := importOpts{
+				chunker:      chunker,
+				rawLeaves:    rawLeaves,
+				rawLeavesDef: rawLeavesDef,
+				builder:      prefix,
+			}
+			return importFile(nd, path, r, opts, flush)
+		}
+
 		fi, err := getFileHandle(nd.FilesRoot, path, create, prefix)
 		if err != nil {
 			return err
@@ -788,11 +895,6 @@ stat' on the file or any of its ancestors.
 			}
 		}
 
-		count, countfound := req.Options["count"].(int)
-		if countfound && count < 0 {
-			return fmt.Errorf("cannot have negative byte count")
-		}
-
 		_, err = wfd.Seek(int64(offset), io.SeekStart)
 		if err != nil {
 			flog.Error("seekfail: ", err)
@@ -1037,6 +1139,11 @@ Remove files or directories.
 			return
 		}
 
+		if _, err := corerepo.AutoSnapshot(req.Context(), nd); err != nil {
+			res.SetError(fmt.Errorf("snapshot before removal: %s", err), cmdkit.ErrNormal)
+			return
+		}
+
 		dashr, _, _ := req.Option("r").Bool()
 
 		var success bool
@@ -1084,10 +1191,20 @@ Remove files or directories.
 	},
 }
 
-func getPrefixNew(req *cmds.Request) (cid.Builder, error) {
+// getPrefixNew returns the CID builder set by the options, or else by the
+// Import section of the config, nil when none is set.
+func getPrefixNew(req *cmds.Request, importCfg *extconfig.Import) (cid.Builder, error) {
 	cidVer, cidVerSet := req.Options["cid-version"].(int)
 	hashFunStr, hashFunSet := req.Options["hash"].(string)
 
+	if !cidVerSet && importCfg.CidVersion != nil {
+		cidVer, cidVerSet = *importCfg.CidVersion, true
+	}
+	// the default hash function doesn't imply CIDv1 there
+	if !hashFunSet && importCfg.Hash() != extconfig.DefaultHashFunction {
+		hashFunStr, hashFunSet = importCfg.HashFunction, true
+	}
+
 	if !cidVerSet && !hashFunSet {
 		return nil, nil
 	}
@@ -1208,6 +1325,69 @@ func getFileHandle(r *mfs.Root, path string, create bool, builder cid.Builder) (
 	}
 }
 
+// importOpts are the importer settings of the files written by importFile.
+type importOpts struct {
+	chunker      string
+	rawLeaves    bool
+	rawLeavesDef bool
+	builder      cid.Builder
+}
+
+// importFile replaces the file at path, creating it if needed, with the DAG
+// `ipfs add` builds for the data of r.
+func importFile(n *core.IpfsNode, path string, r io.Reader, opts importOpts, flush bool) error {
+	dirname, fname := gopath.Split(path)
+	pdiri, err := mfs.Lookup(n.FilesRoot, dirname)
+	if err != nil {
+		flog.Error("lookupfail ", dirname)
+		return err
+	}
+	pdir, ok := pdiri.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("%s was not a directory", dirname)
+	}
+
+	builder := opts.builder
+	if builder == nil {
+		builder = pdir.GetCidBuilder()
+	}
+	rawLeaves := opts.rawLeaves
+	if !opts.rawLeavesDef {
+		// like the leaves created by mfs: raw unless the CID version is 0
+		c, err := builder.Sum(nil)
+		if err != nil {
+			return err
+		}
+		rawLeaves = c.Version() != 0
+	}
+
+	nd, err := coreunix.BuildFile(r, n.DAG, opts.chunker, rawLeaves, builder)
+	if err != nil {
+		return err
+	}
+
+	switch child, err := pdir.Child(fname); err {
+	case nil:
+		if _, ok := child.(*mfs.File); !ok {
+			return fmt.Errorf("%s was not a file", path)
+		}
+		if err := pdir.Unlink(fname); err != nil {
+			return err
+		}
+	case os.ErrNotExist:
+	default:
+		return err
+	}
+
+	if err := pdir.AddChild(fname, nd); err != nil {
+		return err
+	}
+	if flush {
+		return mfs.FlushPath(n.FilesRoot, path)
+	}
+	return nil
+}
+
 func checkPath(p string) (string, error) {
 	if len(p) == 0 {
 		return "", fmt.Errorf("paths must not be empty")
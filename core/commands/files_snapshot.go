@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+var filesSnapshotCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Record and restore the root of the files API.",
+		ShortDescription: `
+A snapshot records the root of the files API under a name, in a log local to
+the node. The blocks of the snapshots are kept by the garbage collection, so
+the tree of a snapshot can be restored after its files were removed.
+
+With Files.AutoSnapshot set in the config, a snapshot is taken before every
+'ipfs files rm'. The automatic snapshots are named auto-<time>, and only the
+last Files.MaxAutoSnapshots of them are kept.
+
+Example:
+
+    $ ipfs files snapshot create before-cleanup
+    $ ipfs files rm -r /photos
+    $ ipfs files snapshot restore before-cleanup
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create":  filesSnapshotCreateCmd,
+		"ls":      filesSnapshotLsCmd,
+		"restore": filesSnapshotRestoreCmd,
+		"rm":      filesSnapshotRmCmd,
+	},
+}
+
+type filesSnapshotList struct {
+	Snapshots []corerepo.Snapshot
+}
+
+func writeSnapshot(w io.Writer, s *corerepo.Snapshot) {
+	auto := ""
+	if s.Auto {
+		auto = "\tauto"
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s%s\n", s.Name, s.Cid, s.Time.Local().Format(time.RFC3339), auto)
+}
+
+var filesSnapshotCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Snapshot the root of the files API.",
+		ShortDescription: `
+'ipfs files snapshot create' flushes the files API and records its root. The
+snapshot is named after its time when no name is given.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", false, false, "Name of the snapshot."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		var name string
+		if len(req.Arguments) > 0 {
+			name = req.Arguments[0]
+		}
+
+		s, err := corerepo.CreateSnapshot(req.Context, n, name)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, s)
+	},
+	Type: corerepo.Snapshot{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			s, ok := v.(*corerepo.Snapshot)
+			if !ok {
+				return e.TypeErr(s, v)
+			}
+			fmt.Fprintf(w, "created snapshot %s of %s\n", s.Name, s.Cid)
+			return nil
+		}),
+	},
+}
+
+var filesSnapshotLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the snapshots of the files API, oldest first.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		snaps, err := corerepo.ListSnapshots(n.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &filesSnapshotList{Snapshots: snaps})
+	},
+	Type: filesSnapshotList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			list, ok := v.(*filesSnapshotList)
+			if !ok {
+				return e.TypeErr(list, v)
+			}
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			for i := range list.Snapshots {
+				writeSnapshot(tw, &list.Snapshots[i])
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var filesSnapshotRestoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Restore the files API to a snapshot.",
+		ShortDescription: `
+'ipfs files snapshot restore' replaces the content of the files API with the
+tree of a snapshot. The files API is snapshotted first, so the restore can be
+undone by restoring that snapshot.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "Name of the snapshot."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		out, err := corerepo.RestoreSnapshot(req.Context, n, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: corerepo.RestoreSnapshotResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*corerepo.RestoreSnapshotResult)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			fmt.Fprintf(w, "restored snapshot %s of %s\n", out.Restored.Name, out.Restored.Cid)
+			fmt.Fprintf(w, "the previous root %s was saved as snapshot %s\n", out.Previous.Cid, out.Previous.Name)
+			return nil
+		}),
+	},
+}
+
+var filesSnapshotRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove snapshots of the files API.",
+		ShortDescription: `
+'ipfs files snapshot rm' removes snapshots from the log. Their blocks are
+removed by the next garbage collection, unless the files API or a pin still
+references them.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, true, "Names of the snapshots."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range req.Arguments {
+			if err := corerepo.RemoveSnapshot(n.Repo.Datastore(), name); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		return nil
+	},
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	oldCmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
@@ -13,6 +14,7 @@ import (
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	"github.com/ipfs/go-ipfs/filestore"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
@@ -26,6 +28,8 @@ var FileStoreCmd = &cmds.Command{
 		"ls":     lsFileStore,
 		"verify": lgc.NewCommand(verifyFileStore),
 		"dups":   lgc.NewCommand(dupsFileStore),
+		"mv":     mvFileStore,
+		"clean":  cleanFileStore,
 	},
 }
 
@@ -182,6 +186,212 @@ For ERROR entries the error will also be printed to stderr.
 	Type: filestore.ListRes{},
 }
 
+var mvFileStore = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Rewrite the references to files that were moved.",
+		ShortDescription: `
+Rewrites the filestore references to the files under <from>, once the files
+have been moved to <to>, so that the blocks added with '--nocopy' are read
+from their new location.
+`,
+		LongDescription: `
+Rewrites the filestore references to the files under <from>, once the files
+have been moved to <to>, so that the blocks added with '--nocopy' are read
+from their new location:
+
+  > mv /data/photos /archive/photos
+  > ipfs filestore mv /data/photos /archive/photos
+
+Each block is read from its new location and verified before its reference
+is rewritten. The references whose block doesn't verify are kept, and
+reported with the status of the block, as in 'ipfs filestore verify'.
+
+The output is:
+
+<status> <hash> <size> <path> <offset>
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("from", true, false, "The path the files were moved from."),
+		cmdkit.StringArg("to", true, false, "The path the files were moved to."),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		// the paths are relative to the working directory of the client
+		for i, p := range req.Arguments {
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return err
+			}
+			req.Arguments[i] = abs
+		}
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		_, fs, err := getFilestore(env)
+		if err != nil {
+			return err
+		}
+
+		next, err := filestore.MoveAll(fs, req.Arguments[0], req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		return res.Emit(listResToChan(req.Context, next))
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			var failed int
+			for {
+				v, err := res.Next()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					return err
+				}
+
+				r, ok := v.(*filestore.ListRes)
+				if !ok {
+					return e.New(e.TypeErr(r, v))
+				}
+
+				if r.Status != filestore.StatusOk {
+					failed++
+					if r.Status == filestore.StatusOtherError {
+						fmt.Fprintf(os.Stderr, "%s\n", r.ErrorMsg)
+					}
+				}
+				fmt.Fprintf(os.Stdout, "%s %s\n", r.Status.Format(), r.FormatLong())
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d references could not be moved", failed)
+			}
+			return nil
+		},
+	},
+	Type: filestore.ListRes{},
+}
+
+var cleanFileStore = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove broken references from the filestore.",
+		ShortDescription: `
+Verifies the objects in the filestore and removes the references whose
+status is one of the given <status>, as reported by 'ipfs filestore verify'.
+`,
+		LongDescription: `
+Verifies the objects in the filestore and removes the references whose
+status is one of the given <status>, as reported by 'ipfs filestore verify':
+
+changed:  the contents of the backing file have changed
+no-file:  the backing file could not be found
+error:    there was some other problem reading the file
+
+For example, to remove the references to files that were deleted or
+modified since they were added:
+
+  > ipfs filestore clean no-file changed
+
+Only the references are removed, the backing files are not touched. The
+blocks of the removed references are no longer available, even if they
+are pinned.
+
+The output is:
+
+<status> <hash> <size> <path> <offset>
+
+followed by the number of removed references for each status.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("status", true, true, "Status of the references to remove: changed, no-file or error."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("quiet", "q", "Only print the number of removed references."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		_, fs, err := getFilestore(env)
+		if err != nil {
+			return err
+		}
+
+		var statuses []filestore.Status
+		for _, arg := range req.Arguments {
+			s, err := filestore.ParseStatus(arg)
+			if err != nil {
+				return err
+			}
+			switch s {
+			case filestore.StatusFileChanged, filestore.StatusFileNotFound, filestore.StatusFileError:
+			default:
+				return fmt.Errorf("cannot clean references with status %q", arg)
+			}
+			statuses = append(statuses, s)
+		}
+
+		next, err := filestore.CleanAll(fs, statuses...)
+		if err != nil {
+			return err
+		}
+		return res.Emit(listResToChan(req.Context, next))
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			quiet, _ := res.Request().Options["quiet"].(bool)
+
+			var (
+				statuses []filestore.Status
+				removed  = make(map[filestore.Status]int)
+				size     uint64
+				failed   int
+			)
+			for {
+				v, err := res.Next()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					return err
+				}
+
+				r, ok := v.(*filestore.ListRes)
+				if !ok {
+					return e.New(e.TypeErr(r, v))
+				}
+
+				if r.Status == filestore.StatusOtherError {
+					failed++
+					fmt.Fprintf(os.Stderr, "%s\n", r.ErrorMsg)
+					continue
+				}
+
+				if _, ok := removed[r.Status]; !ok {
+					statuses = append(statuses, r.Status)
+				}
+				removed[r.Status]++
+				size += r.Size
+				if !quiet {
+					fmt.Fprintf(os.Stdout, "%s %s\n", r.Status.Format(), r.FormatLong())
+				}
+			}
+
+			var total int
+			for _, s := range statuses {
+				total += removed[s]
+				fmt.Fprintf(os.Stdout, "removed %d %s references\n", removed[s], s)
+			}
+			fmt.Fprintf(os.Stdout, "removed %d references (%s)\n", total, humanize.Bytes(size))
+
+			if failed > 0 {
+				return fmt.Errorf("%d references could not be removed", failed)
+			}
+			return nil
+		},
+	},
+	Type: filestore.ListRes{},
+}
+
 var dupsFileStore = &oldCmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List blocks that are both in the filestore and standard block storage.",
@@ -2,28 +2,56 @@ package commands
 
 import (
 	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	gopath "path"
 	"path/filepath"
 	"strings"
 
+	archive "github.com/ipfs/go-ipfs/archive"
 	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	progress "github.com/ipfs/go-ipfs/core/commands/progress"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	dagtransfer "github.com/ipfs/go-ipfs/dagtransfer"
+	common "github.com/ipfs/go-ipfs/repo/common"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	uarchive "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/archive"
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	"gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
-	"gx/ipfs/QmPtj12fdwuAqj9sBSTNUxBNu8kCGNp8b3o8yUzMm5GHpq/pb"
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
 	tar "gx/ipfs/QmQine7gvHncNevKtG9QXxf3nXcwSj6aDDmMm52mHofEEp/tar-utils"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
 	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
 	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	bservice "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
 )
 
 var ErrInvalidCompressionLevel = errors.New("compression level must be between 1 and 9")
 
+// The formats of the archives output by 'ipfs get'.
+const (
+	archiveFormatTar = "tar"
+	archiveFormatCar = "car"
+)
+
 var GetCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Download IPFS objects.",
@@ -35,8 +63,29 @@ path can be specified with '--output=<path>' or '-o=<path>'.
 
 To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
+To output a CAR (content addressable archive) of the blocks of the DAG
+instead, use '--archive-format=car'.
+
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
+
+With '--deterministic', the archives of a path are byte-identical on every
+node, so that they can be keyed by CID in build pipelines: the entries of a
+TAR archive have no owner, and the modification time stored with
+'ipfs add --preserve-mtime' or else the Unix epoch. The blocks of a CAR are
+always written in the same order: depth-first, following the links in their
+order, each block once where it's first reached.
+
+The blocks are fetched concurrently, ahead of the output. The progress bar
+shows the file being written, or the number of blocks of a CAR.
+
+With '--continue', an interrupted extraction is resumed: the files already
+present in the output path with the content of the files of the object are
+skipped, without being fetched, and the others are written again. The
+blocks are then fetched file by file, as they are written. The files present
+are hashed as 'ipfs add' would with the "Import" settings of the local repo,
+or the defaults, so the files of an object added with other settings are all
+written again.
 `,
 	},
 
@@ -46,20 +95,43 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("output", "o", "The path where the output should be stored."),
 		cmdkit.BoolOption("archive", "a", "Output a TAR archive."),
+		cmdkit.StringOption("archive-format", "The format of the archive, 'tar' or 'car'. Implies --archive.").WithDefault(archiveFormatTar),
 		cmdkit.BoolOption("compress", "C", "Compress the output with GZIP compression."),
 		cmdkit.IntOption("compression-level", "l", "The level of compression (1-9)."),
+		cmdkit.BoolOption("deterministic", "Write the same archive of a path on every node, without times nor owners."),
+		cmdkit.BoolOption("continue", "Resume an extraction, skipping the files already present with the same content."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
-		_, err := getCompressOptions(req)
-		return err
+		if _, err := getArchiveFormat(req); err != nil {
+			return err
+		}
+		if _, err := getCompressOptions(req); err != nil {
+			return err
+		}
+		cont, err := getContinue(req)
+		if err != nil || !cont {
+			return err
+		}
+		// the files already extracted are sent to the daemon, which
+		// skips them
+		opts, err := continueImport(req)
+		if err != nil {
+			return err
+		}
+		req.Files = continueManifest(getOutPath(req), opts)
+		return nil
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		cmplvl, err := getCompressOptions(req)
 		if err != nil {
 			return err
 		}
+		format, err := getArchiveFormat(req)
+		if err != nil {
+			return err
+		}
 
-		node, err := cmdenv.GetNode(env)
+		node, err := cmdenv.GetRequestNode(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -84,13 +156,54 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return err
 		}
 
-		archive, _ := req.Options["archive"].(bool)
-		reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, cmplvl)
+		cont, err := getContinue(req)
 		if err != nil {
 			return err
 		}
+		if cont {
+			present, err := readContinueManifest(req)
+			if err != nil {
+				return err
+			}
+			reader := archiveReader(gzip.NoCompression, func(w io.Writer) error {
+				return archive.Export(ctx, w, dn, node.DAG, archive.ExportOptions{
+					Name: gopath.Base(p.String()),
+					Skip: func(rel string, size int64, c cid.Cid) bool {
+						entry, ok := present[rel]
+						return ok && entry.Size == size && entry.Cid == c.String()
+					},
+				})
+			})
+			deterministic, _ := req.Options["deterministic"].(bool)
+			return res.Emit(tarWithAttrs(ctx, reader, dn, node.DAG, gzip.NoCompression, deterministic))
+		}
 
-		return res.Emit(reader)
+		// fetch the sibling subtrees concurrently, ahead of the depth-first
+		// traversal writing the output
+		go prefetchDAG(ctx, node, dn.Cid())
+
+		if format == archiveFormatCar {
+			return res.Emit(archiveReader(cmplvl, func(w io.Writer) error {
+				return corerepo.WriteCar(ctx, node.DAG, dn.Cid(), w)
+			}))
+		}
+
+		archive, _ := req.Options["archive"].(bool)
+		if !archive && cmplvl != gzip.NoCompression {
+			// a compressed file, not a tar stream
+			reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, cmplvl)
+			if err != nil {
+				return err
+			}
+			return res.Emit(reader)
+		}
+
+		reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, gzip.NoCompression)
+		if err != nil {
+			return err
+		}
+		deterministic, _ := req.Options["deterministic"].(bool)
+		return res.Emit(tarWithAttrs(ctx, reader, dn, node.DAG, cmplvl, deterministic))
 	},
 	PostRun: cmds.PostRunMap{
 		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
@@ -113,12 +226,18 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 				return err
 			}
 
+			format, err := getArchiveFormat(req)
+			if err != nil {
+				return err
+			}
+
 			archive, _ := req.Options["archive"].(bool)
 
 			gw := getWriter{
 				Out:         os.Stdout,
 				Err:         os.Stderr,
-				Archive:     archive,
+				Archive:     archive || format == archiveFormatCar,
+				Format:      format,
 				Compression: cmplvl,
 				Size:        int64(res.Length()),
 			}
@@ -142,25 +261,14 @@ func (r *clearlineReader) Read(p []byte) (n int, err error) {
 	return
 }
 
-func progressBarForReader(out io.Writer, r io.Reader, l int64) (*pb.ProgressBar, io.Reader) {
+func progressBarForReader(out io.Writer, r io.Reader, l int64) (*progress.Bar, io.Reader) {
 	bar := makeProgressBar(out, l)
-	barR := bar.NewProxyReader(r)
-	return bar, &clearlineReader{barR, out}
+	return bar, &clearlineReader{bar.NewReader(r), out}
 }
 
-func makeProgressBar(out io.Writer, l int64) *pb.ProgressBar {
-	// setup bar reader
-	// TODO: get total length of files
-	bar := pb.New64(l).SetUnits(pb.U_BYTES)
-	bar.Output = out
-
-	// the progress bar lib doesn't give us a way to get the width of the output,
-	// so as a hack we just use a callback to measure the output, then git rid of it
-	bar.Callback = func(line string) {
-		terminalWidth := len(line)
-		bar.Callback = nil
-		log.Infof("terminal width: %v\n", terminalWidth)
-	}
+func makeProgressBar(out io.Writer, l int64) *progress.Bar {
+	bar := progress.New(out)
+	bar.SetTotal(l, 0)
 	return bar
 }
 
@@ -179,6 +287,7 @@ type getWriter struct {
 	Err io.Writer // for progress bar output
 
 	Archive     bool
+	Format      string
 	Compression int
 	Size        int64
 }
@@ -191,10 +300,14 @@ func (gw *getWriter) Write(r io.Reader, fpath string) error {
 }
 
 func (gw *getWriter) writeArchive(r io.Reader, fpath string) error {
-	// adjust file name if tar
+	// adjust file name if tar or car
 	if gw.Archive {
-		if !strings.HasSuffix(fpath, ".tar") && !strings.HasSuffix(fpath, ".tar.gz") {
-			fpath += ".tar"
+		ext := "." + archiveFormatTar
+		if gw.Format == archiveFormatCar {
+			ext = "." + archiveFormatCar
+		}
+		if !strings.HasSuffix(fpath, ext) && !strings.HasSuffix(fpath, ext+".gz") {
+			fpath += ext
 		}
 	}
 
@@ -214,7 +327,13 @@ func (gw *getWriter) writeArchive(r io.Reader, fpath string) error {
 
 	fmt.Fprintf(gw.Out, "Saving archive to %s\n", fpath)
 	bar, barR := progressBarForReader(gw.Err, r, gw.Size)
-	bar.Start()
+	if gw.Format == archiveFormatCar && gw.Compression == gzip.NoCompression {
+		var blocks int
+		barR = &carProgress{r: barR, onBlock: func(n int) {
+			bar.AddBlocks(int64(n - blocks))
+			blocks = n
+		}}
+	}
 	defer bar.Finish()
 
 	_, err = io.Copy(file, barR)
@@ -224,12 +343,225 @@ func (gw *getWriter) writeArchive(r io.Reader, fpath string) error {
 func (gw *getWriter) writeExtracted(r io.Reader, fpath string) error {
 	fmt.Fprintf(gw.Out, "Saving file(s) to %s\n", fpath)
 	bar := makeProgressBar(gw.Err, gw.Size)
-	bar.Start()
-	defer bar.Finish()
-	defer bar.Set64(gw.Size)
 
-	extractor := &tar.Extractor{Path: fpath, Progress: bar.Add64}
-	return extractor.Extract(r)
+	var skipped int
+	var skippedSize int64
+	ea, r := newExtractedAttrs(r, fpath, func(name string) {
+		bar.SetPrefix(progressFileName(name) + " ")
+	}, func(name string, size int64) {
+		skipped++
+		skippedSize += size
+		bar.AddBytes(size)
+	})
+	extractor := &tar.Extractor{Path: fpath, Progress: func(n int64) int64 {
+		bar.AddBytes(n)
+		return n
+	}}
+	err := extractor.Extract(r)
+	if ferr := ea.finish(err == nil); err == nil {
+		err = ferr
+	}
+	bar.Finish()
+
+	if err == nil && skipped > 0 {
+		fmt.Fprintf(gw.Out, "Skipped %d file(s) already present, %s\n", skipped, humanize.Bytes(uint64(skippedSize)))
+	}
+	return err
+}
+
+func getArchiveFormat(req *cmds.Request) (string, error) {
+	format, _ := req.Options["archive-format"].(string)
+	switch format {
+	case "":
+		return archiveFormatTar, nil
+	case archiveFormatTar, archiveFormatCar:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown archive format %q, expected %q or %q", format, archiveFormatTar, archiveFormatCar)
+}
+
+// getContinue returns whether an extraction is resumed with --continue.
+func getContinue(req *cmds.Request) (bool, error) {
+	cont, _ := req.Options["continue"].(bool)
+	if !cont {
+		return false, nil
+	}
+	arch, _ := req.Options["archive"].(bool)
+	cmprs, _ := req.Options["compress"].(bool)
+	format, _ := getArchiveFormat(req)
+	if arch || cmprs || format != archiveFormatTar {
+		return false, errors.New("--continue resumes the extraction of files, it can't be used with an archive nor compression")
+	}
+	return true, nil
+}
+
+// continueEntry is a file of the output path of 'ipfs get --continue', at a
+// path relative to it, with the CID 'ipfs add' gives its content.
+type continueEntry struct {
+	Path string
+	Size int64
+	Cid  string
+}
+
+// continueImport returns the settings the files already extracted are hashed
+// with: those of 'ipfs add' with the Import section of the config of the
+// local repo, or the defaults without one.
+func continueImport(req *cmds.Request) (importOpts, error) {
+	repoPath, _ := req.Options["config"].(string)
+	if repoPath == "" {
+		var err error
+		if repoPath, err = fsrepo.BestKnownPath(); err != nil {
+			return importOpts{}, err
+		}
+	}
+	fname, err := config.Filename(repoPath)
+	if err != nil {
+		return importOpts{}, err
+	}
+
+	importCfg := new(extconfig.Import)
+	raw, err := ioutil.ReadFile(fname)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return importOpts{}, err
+	default:
+		var m map[string]interface{}
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return importOpts{}, err
+		}
+		if importCfg, err = extconfig.LoadImport(configMap(m)); err != nil {
+			return importOpts{}, err
+		}
+	}
+
+	var cidVer int
+	if importCfg.CidVersion != nil {
+		cidVer = *importCfg.CidVersion
+	}
+	// CIDv0 only supports sha2-256
+	hashFunStr := strings.ToLower(importCfg.Hash())
+	if hashFunStr != extconfig.DefaultHashFunction {
+		cidVer = 1
+	}
+	prefix, err := dag.PrefixForCidVersion(cidVer)
+	if err != nil {
+		return importOpts{}, err
+	}
+	hashFunCode, ok := mh.Names[hashFunStr]
+	if !ok {
+		return importOpts{}, fmt.Errorf("unrecognized hash function: %s", hashFunStr)
+	}
+	prefix.MhType = hashFunCode
+	prefix.MhLength = -1
+
+	return importOpts{
+		chunker:      importCfg.Chunker(),
+		rawLeaves:    importCfg.RawLeaves.WithDefault(cidVer > 0),
+		rawLeavesDef: true,
+		builder:      prefix,
+	}, nil
+}
+
+// configMap reads the keys of a config file decoded as a map.
+type configMap map[string]interface{}
+
+func (m configMap) GetConfigKey(key string) (interface{}, error) {
+	return common.MapGetKV(m, key)
+}
+
+// hashFile returns the CID of the DAG built for the file at p with opts, in
+// dserv.
+func hashFile(p string, opts importOpts, dserv ipld.DAGService) (cid.Cid, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return cid.Cid{}, err
+	}
+	defer f.Close()
+
+	nd, err := coreunix.BuildFile(f, dserv, opts.chunker, opts.rawLeaves, opts.builder)
+	if err != nil {
+		return cid.Cid{}, err
+	}
+	return nd.Cid(), nil
+}
+
+// continueManifest returns the file sent to the daemon by
+// 'ipfs get --continue', listing the regular files of the output path as
+// JSON continueEntry objects, hashed with opts. The output path is walked as
+// it's sent.
+func continueManifest(outPath string, opts importOpts) files.File {
+	pr, pw := io.Pipe()
+	go func() {
+		// the blocks of the files hashed are not stored
+		bs := bstore.NewBlockstore(ds.NewNullDatastore())
+		dserv := dag.NewDAGService(bservice.New(bs, offline.Exchange(bs)))
+
+		enc := json.NewEncoder(pw)
+		err := filepath.Walk(outPath, func(p string, fi os.FileInfo, err error) error {
+			if p == outPath && os.IsNotExist(err) {
+				// nothing extracted yet
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(outPath, p)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				// the output path is the file
+				rel = ""
+			}
+			c, err := hashFile(p, opts, dserv)
+			if err != nil {
+				return err
+			}
+			return enc.Encode(continueEntry{
+				Path: filepath.ToSlash(rel),
+				Size: fi.Size(),
+				Cid:  c.String(),
+			})
+		})
+		pw.CloseWithError(err)
+	}()
+	return files.NewSliceFile("", "", []files.File{
+		files.NewReaderFile("continue", "", pr, nil),
+	})
+}
+
+// readContinueManifest returns the files of the output path sent by
+// continueManifest, by path.
+func readContinueManifest(req *cmds.Request) (map[string]continueEntry, error) {
+	present := make(map[string]continueEntry)
+	if req.Files == nil {
+		return present, nil
+	}
+	f, err := req.Files.NextFile()
+	if err == io.EOF {
+		return present, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var e continueEntry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return present, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid list of the files already present: %s", err)
+		}
+		present[e.Path] = e
+	}
 }
 
 func getCompressOptions(req *cmds.Request) (int, error) {
@@ -245,3 +577,97 @@ func getCompressOptions(req *cmds.Request) (int, error) {
 	}
 	return cmplvl, nil
 }
+
+// archiveReader returns the output of write, compressed at cmplvl.
+func archiveReader(cmplvl int, write func(w io.Writer) error) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		var w io.Writer = pw
+		var gzw *gzip.Writer
+		if cmplvl != gzip.NoCompression {
+			var err error
+			gzw, err = gzip.NewWriterLevel(pw, cmplvl)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			w = gzw
+		}
+
+		err := write(w)
+		if gzw != nil {
+			if cerr := gzw.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// prefetchDAG fetches the blocks of the DAG of root with a DAG transfer
+// when DAGTransfer.FetchDAGs is set, then the missing ones with a session, fetching
+// the links of each node concurrently, until ctx is done.
+func prefetchDAG(ctx context.Context, n *core.IpfsNode, root cid.Cid) {
+	dagtransfer.Prefetch(ctx, n.DAGTransfer, root)
+	if err := dag.FetchGraph(ctx, root, n.DAG); err != nil && ctx.Err() == nil {
+		log.Debugf("prefetching %s: %s", root, err)
+	}
+}
+
+// progressFileName returns the name of a file shown by the progress bar.
+func progressFileName(name string) string {
+	const max = 30
+	name = strings.TrimSuffix(name, "/")
+	if len(name) > max {
+		name = "..." + name[len(name)-max+3:]
+	}
+	return name
+}
+
+// carProgress calls onBlock with the number of blocks of the CAR stream read
+// through it.
+type carProgress struct {
+	r       io.Reader
+	onBlock func(n int)
+
+	// the bytes left in the current section, and the bytes of the varint
+	// length of the next one
+	left     uint64
+	lenBuf   []byte
+	sections int
+}
+
+func (cp *carProgress) Read(p []byte) (int, error) {
+	n, err := cp.r.Read(p)
+	cp.scan(p[:n])
+	return n, err
+}
+
+func (cp *carProgress) scan(b []byte) {
+	for len(b) > 0 {
+		if cp.left > 0 {
+			k := cp.left
+			if uint64(len(b)) < k {
+				k = uint64(len(b))
+			}
+			b = b[k:]
+			cp.left -= k
+			continue
+		}
+
+		cp.lenBuf = append(cp.lenBuf, b[0])
+		b = b[1:]
+		if cp.lenBuf[len(cp.lenBuf)-1]&0x80 != 0 && len(cp.lenBuf) < binary.MaxVarintLen64 {
+			continue
+		}
+		cp.left, _ = binary.Uvarint(cp.lenBuf)
+		cp.lenBuf = cp.lenBuf[:0]
+
+		// the first section is the header
+		cp.sections++
+		if cp.sections > 1 {
+			cp.onBlock(cp.sections - 1)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package commands
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	archive "github.com/ipfs/go-ipfs/archive"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// The PAX records marking the tar entries whose mode and modification time
+// are the attributes stored in the nodes, to be restored on extraction.
+const (
+	paxAttrMode  = "IPFS.mode"
+	paxAttrMtime = "IPFS.mtime"
+)
+
+// deterministicTarTime is the modification time of the entries of the
+// deterministic tar archives without a stored one.
+var deterministicTarTime = time.Unix(0, 0)
+
+// tarWithAttrs rewrites the headers of the tar stream of root with the
+// attributes stored in the nodes, then compresses it at cmplvl. With
+// deterministic, the headers are normalized with normalizeTarHeader.
+func tarWithAttrs(ctx context.Context, r io.Reader, root ipld.Node, ng ipld.NodeGetter, cmplvl int, deterministic bool) io.Reader {
+	return archiveReader(cmplvl, func(w io.Writer) error {
+		return rewriteTarAttrs(ctx, r, w, root, ng, deterministic)
+	})
+}
+
+func rewriteTarAttrs(ctx context.Context, r io.Reader, w io.Writer, root ipld.Node, ng ipld.NodeGetter, deterministic bool) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+
+	// the directories by tar path, to look up the nodes of their entries
+	dirs := make(map[string]ipld.Node)
+	for first := true; ; first = false {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		nd := root
+		if !first {
+			nd = lookupTarEntry(ctx, ng, dirs, name)
+		}
+		if nd != nil {
+			if hdr.Typeflag == tar.TypeDir {
+				dirs[name] = nd
+			}
+			setTarAttrs(hdr, nd)
+		}
+		if deterministic {
+			normalizeTarHeader(hdr)
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// lookupTarEntry returns the node of a tar entry, or nil when not found, as
+// in sharded directories, whose links are not named after the entries.
+func lookupTarEntry(ctx context.Context, ng ipld.NodeGetter, dirs map[string]ipld.Node, name string) ipld.Node {
+	parent, ok := dirs[gopath.Dir(name)].(*dag.ProtoNode)
+	if !ok {
+		return nil
+	}
+	l, err := parent.GetNodeLink(gopath.Base(name))
+	if err != nil {
+		return nil
+	}
+	nd, err := l.GetNode(ctx, ng)
+	if err != nil {
+		return nil
+	}
+	return nd
+}
+
+// normalizeTarHeader clears the fields of a tar header which depend on the
+// node writing the archive or on the time it's written, so that every node
+// writes the same archive of a DAG: the times other than a stored
+// modification time, and the owner.
+func normalizeTarHeader(hdr *tar.Header) {
+	if _, ok := hdr.PAXRecords[paxAttrMtime]; !ok {
+		hdr.ModTime = deterministicTarTime
+	}
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	hdr.Uid, hdr.Gid = 0, 0
+	hdr.Uname, hdr.Gname = "", ""
+}
+
+func setTarAttrs(hdr *tar.Header, nd ipld.Node) {
+	attrs, err := coreunix.GetFileAttrs(nd)
+	if err != nil || attrs.IsZero() {
+		return
+	}
+
+	hdr.Format = tar.FormatPAX
+	if hdr.PAXRecords == nil {
+		hdr.PAXRecords = make(map[string]string)
+	}
+	if attrs.HasMode {
+		hdr.Mode = attrs.UnixMode()
+		hdr.PAXRecords[paxAttrMode] = strconv.FormatInt(hdr.Mode, 8)
+	}
+	if !attrs.Mtime.IsZero() {
+		hdr.ModTime = attrs.Mtime
+		hdr.PAXRecords[paxAttrMtime] = "1"
+	}
+}
+
+// extractedAttrs collects the attributes of the entries of a tar stream
+// while it's extracted, to apply them to the extracted files once done. The
+// symlinks are taken out of the stream and created once the files are
+// extracted, so that no file is written through them.
+type extractedAttrs struct {
+	path      string
+	rootIsDir bool
+	onEntry   func(name string)
+	onSkip    func(name string, size int64)
+
+	entries  []extractedEntry
+	symlinks []extractedEntry
+	done     chan error
+	pr       *io.PipeReader
+}
+
+type extractedEntry struct {
+	path    string
+	mode    os.FileMode
+	hasMode bool
+	mtime   time.Time
+	// the target of a symlink
+	target string
+}
+
+// newExtractedAttrs returns the reader to pass to the extractor instead of
+// r. The output path must be checked before the extraction starts. onEntry,
+// when set, is called with the name of each entry as it's extracted, and
+// onSkip with the files skipped by 'ipfs get --continue', which are left
+// out of the extraction.
+func newExtractedAttrs(r io.Reader, path string, onEntry func(name string), onSkip func(name string, size int64)) (*extractedAttrs, io.Reader) {
+	ea := &extractedAttrs{
+		path:    path,
+		onEntry: onEntry,
+		onSkip:  onSkip,
+		done:    make(chan error, 1),
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		ea.rootIsDir = true
+	}
+
+	pr, pw := io.Pipe()
+	ea.pr = pr
+	go func() {
+		err := ea.collect(r, pw)
+		pw.CloseWithError(err)
+		ea.done <- err
+	}()
+	return ea, pr
+}
+
+// collect copies the tar stream of r to w, without the symlinks.
+func (ea *extractedAttrs) collect(r io.Reader, w io.Writer) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			// the extractor may stop reading before the end of the
+			// archive
+			tw.Close()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ea.onEntry != nil {
+			ea.onEntry(hdr.Name)
+		}
+
+		p, err := ea.outputPath(hdr, i)
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeSymlink {
+			ea.symlinks = append(ea.symlinks, extractedEntry{
+				path:   p,
+				target: hdr.Linkname,
+			})
+			continue
+		}
+
+		// the symlinks restored by a previous 'ipfs get' must not be
+		// written through
+		if err := ea.checkSymlinks(p, true); err != nil {
+			return err
+		}
+
+		_, hasMode := hdr.PAXRecords[paxAttrMode]
+		_, hasMtime := hdr.PAXRecords[paxAttrMtime]
+		if hasMode || hasMtime {
+			e := extractedEntry{path: p}
+			if hasMode {
+				e.mode = coreunix.FileModeFromUnix(uint64(hdr.Mode))
+				e.hasMode = true
+			}
+			if hasMtime {
+				e.mtime = hdr.ModTime
+			}
+			ea.entries = append(ea.entries, e)
+		}
+
+		if size, ok := hdr.PAXRecords[archive.SkippedPAXRecord]; ok {
+			// already extracted, only the attributes are set again
+			if ea.onSkip != nil {
+				n, _ := strconv.ParseInt(size, 10, 64)
+				ea.onSkip(hdr.Name, n)
+			}
+			continue
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// outputPath returns the path where the extractor writes an entry. Entries
+// whose name leads outside of the output path are refused.
+func (ea *extractedAttrs) outputPath(hdr *tar.Header, i int) (string, error) {
+	elems := strings.Split(strings.TrimSuffix(hdr.Name, "/"), "/")
+	p := filepath.Join(ea.path, filepath.Join(elems[1:]...))
+	if i == 0 && hdr.Typeflag != tar.TypeDir && ea.rootIsDir {
+		// a single file is written into an existing directory
+		p = filepath.Join(p, gopath.Base(hdr.Name))
+	}
+
+	rel, err := filepath.Rel(filepath.Clean(ea.path), p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to extract %q outside of %s", hdr.Name, ea.path)
+	}
+	return p, nil
+}
+
+// checkSymlinks returns an error if a directory between the output path and
+// p, or p itself with self, is a symlink. p must be within the output path.
+func (ea *extractedAttrs) checkSymlinks(p string, self bool) error {
+	root := filepath.Clean(ea.path)
+	if !self {
+		if p == root {
+			return nil
+		}
+		p = filepath.Dir(p)
+	}
+	for ; p != root && p != filepath.Dir(p); p = filepath.Dir(p) {
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write through the symlink %s", p)
+		}
+	}
+	return nil
+}
+
+// finish creates the symlinks and sets the attributes of the extracted
+// files, once the extraction is done. It must be called even if the
+// extraction failed.
+func (ea *extractedAttrs) finish(extracted bool) error {
+	ea.pr.Close()
+	if err := <-ea.done; err != nil || !extracted {
+		return err
+	}
+
+	for _, l := range ea.symlinks {
+		if err := ea.checkSymlinks(l.path, false); err != nil {
+			return err
+		}
+		if err := extractSymlink(l.path, l.target); err != nil {
+			return err
+		}
+	}
+
+	// the children first, before their directory is made read-only
+	for i := len(ea.entries) - 1; i >= 0; i-- {
+		e := ea.entries[i]
+		if err := ea.checkSymlinks(e.path, true); err != nil {
+			return err
+		}
+		if e.hasMode {
+			if err := os.Chmod(e.path, e.mode); err != nil {
+				return err
+			}
+		}
+		if !e.mtime.IsZero() {
+			if err := os.Chtimes(e.path, e.mtime, e.mtime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// extractSymlink creates the symlink at path, replacing a file but not a
+// directory.
+func extractSymlink(path, target string) error {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.IsDir() {
+			return fmt.Errorf("cannot extract the symlink %s: a directory is in the way", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(target, path)
+}
@@ -1,9 +1,23 @@
 package commands
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	archive "github.com/ipfs/go-ipfs/archive"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	dagtest "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag/test"
 
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
@@ -67,3 +81,275 @@ func TestGetOutputPath(t *testing.T) {
 		})
 	}
 }
+
+func TestCarProgress(t *testing.T) {
+	ctx := context.Background()
+	ds := dagtest.Mock()
+
+	leaf := dag.NodeWithData([]byte("leaf"))
+	other := dag.NodeWithData([]byte("other"))
+	root := dag.NodeWithData([]byte("root"))
+	for _, child := range []*dag.ProtoNode{leaf, other, leaf} {
+		if err := root.AddNodeLink(fmt.Sprint(len(root.Links())), child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, nd := range []*dag.ProtoNode{leaf, other, root} {
+		if err := ds.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := corerepo.WriteCar(ctx, ds, root.Cid(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks int
+	cp := &carProgress{
+		r:       iotest.OneByteReader(&buf),
+		onBlock: func(n int) { blocks = n },
+	}
+	if _, err := ioutil.ReadAll(cp); err != nil {
+		t.Fatal(err)
+	}
+	// each block once
+	if blocks != 3 {
+		t.Fatalf("expected 3 blocks, got %d", blocks)
+	}
+}
+
+func TestDeterministicTar(t *testing.T) {
+	ctx := context.Background()
+	ds := dagtest.Mock()
+	root := dag.NodeWithData(nil)
+
+	writeTar := func(mtime time.Time, uid int, uname string) []byte {
+		var in bytes.Buffer
+		tw := tar.NewWriter(&in)
+		hdr := &tar.Header{
+			Name:     "file",
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     4,
+			ModTime:  mtime,
+			Uid:      uid,
+			Uname:    uname,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if err := rewriteTarAttrs(ctx, &in, &out, root, ds, true); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	a := writeTar(time.Now(), 1000, "alice")
+	b := writeTar(time.Now().Add(time.Hour), 0, "")
+	if !bytes.Equal(a, b) {
+		t.Fatal("the deterministic archives differ")
+	}
+
+	hdr, err := tar.NewReader(bytes.NewReader(a)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hdr.ModTime.Equal(deterministicTarTime) || hdr.Uid != 0 || hdr.Uname != "" {
+		t.Fatalf("unexpected header %+v", hdr)
+	}
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on windows")
+	}
+
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	for _, hdr := range []*tar.Header{
+		{Name: "root", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "root/file", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		{Name: "root/link", Typeflag: tar.TypeSymlink, Linkname: "file"},
+		{Name: "root/outside", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte("data")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	gw := getWriter{Out: ioutil.Discard, Err: ioutil.Discard}
+	if err := gw.Write(&in, out); err != nil {
+		t.Fatal(err)
+	}
+
+	for link, target := range map[string]string{"link": "file", "outside": "../../outside"} {
+		got, err := os.Readlink(filepath.Join(out, link))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != target {
+			t.Fatalf("expected %s to link to %s, got %s", link, target, got)
+		}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(out, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("expected the link to read the file, got %q", data)
+	}
+}
+
+func TestGetContinue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(filepath.Join(out, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"a": "old!", "sub/b": "partial"} {
+		if err := ioutil.WriteFile(filepath.Join(out, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the manifest sent to the daemon
+	ctx := context.Background()
+	req, err := cmds.NewRequest(ctx, []string{}, cmdkit.OptMap{}, []string{"/ipfs/out"}, continueManifest(out), GetCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	present, err := readContinueManifest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(present) != 2 || present["a"] != 4 || present["sub/b"] != 7 {
+		t.Fatalf("unexpected files present: %v", present)
+	}
+
+	// the extraction of the archive where a is skipped
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	for _, hdr := range []*tar.Header{
+		{Name: "out", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "out/a", Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatPAX,
+			PAXRecords: map[string]string{archive.SkippedPAXRecord: "4"}},
+		{Name: "out/sub", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "out/sub/b", Typeflag: tar.TypeReg, Mode: 0644, Size: 8},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte("complete")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg bytes.Buffer
+	gw := getWriter{Out: &msg, Err: ioutil.Discard}
+	if err := gw.Write(&in, out); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"a": "old!", "sub/b": "complete"} {
+		got, err := ioutil.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Fatalf("expected %s to hold %q, got %q", name, data, got)
+		}
+	}
+	if !strings.Contains(msg.String(), "Skipped 1 file(s)") {
+		t.Fatalf("expected the skipped files to be reported, got %q", msg.String())
+	}
+}
+
+func TestExtractUnsafePaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	outside := filepath.Join(dir, "outside")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// restored by a previous 'ipfs get'
+	if err := os.Symlink(outside, filepath.Join(out, "sub")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"out/../escaped", "out/sub/file"} {
+		var in bytes.Buffer
+		tw := tar.NewWriter(&in)
+		for _, hdr := range []*tar.Header{
+			{Name: "out", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		} {
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Size > 0 {
+				if _, err := tw.Write([]byte("data")); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		gw := getWriter{Out: ioutil.Discard, Err: ioutil.Discard}
+		if err := gw.Write(&in, out); err == nil {
+			t.Fatalf("expected %s to be refused", name)
+		}
+	}
+
+	for _, p := range []string{filepath.Join(dir, "escaped"), filepath.Join(outside, "file")} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Fatalf("expected %s not to be written", p)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 
 	ic "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
@@ -63,7 +64,7 @@ EXAMPLE:
 		cmdkit.StringOption("format", "f", "Optional output format."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		node, err := req.InvocContext().GetNode()
+		node, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -92,6 +93,10 @@ EXAMPLE:
 		}
 
 		// TODO handle offline mode with polymorphism instead of conditionals
+		if offline, _ := req.Options()[cmdenv.OfflineOption].(bool); offline {
+			res.SetError(errors.New("remote peers cannot be queried with --offline"), cmdkit.ErrClient)
+			return
+		}
 		if !node.OnlineMode() {
 			res.SetError(errors.New(offlineIdErrorMessage), cmdkit.ErrClient)
 			return
@@ -5,6 +5,9 @@ import (
 	"io"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	corelog "github.com/ipfs/go-ipfs/core/corelog"
+	repo "github.com/ipfs/go-ipfs/repo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 
 	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
 	lwriter "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log/writer"
@@ -39,6 +42,10 @@ var logLevelCmd = &cmds.Command{
 		ShortDescription: `
 Change the verbosity of one or all subsystems log output. This does not affect
 the event log.
+
+With --save, the level is also saved to the "Logging.Levels" config key, and
+set again when the daemon restarts. Saving the level of all the subsystems
+replaces the levels saved for the others.
 `,
 	},
 
@@ -50,6 +57,9 @@ the event log.
 			One of: debug, info, warning, error, critical.
 		`),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("save", "Save the level to the config, to set it again on restart."),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
 		args := req.Arguments()
@@ -65,6 +75,20 @@ the event log.
 		}
 
 		s := fmt.Sprintf("Changed log level of '%s' to '%s'\n", subsystem, level)
+
+		save, _, _ := req.Option("save").Bool()
+		if save {
+			n, err := req.InvocContext().GetNode()
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			if err := saveLogLevel(n.Repo, subsystem, level); err != nil {
+				res.SetError(fmt.Errorf("the log level was changed but not saved: %s", err), cmdkit.ErrNormal)
+				return
+			}
+			s = fmt.Sprintf("Changed and saved log level of '%s' to '%s'\n", subsystem, level)
+		}
 		log.Info(s)
 		res.SetOutput(&MessageOutput{s})
 	},
@@ -96,12 +120,39 @@ var logTailCmd = &cmds.Command{
 		Tagline: "Read the event log.",
 		ShortDescription: `
 Outputs event log messages (not other log messages) as they are generated.
+
+With --format, outputs the log messages written to stderr instead, at the
+levels set with 'ipfs log level', either as text or with one JSON object per
+line:
+
+  {"ts":"2018-10-16T09:44:00.034Z","level":"info","system":"core","caller":"core.go:42","msg":"..."}
 `,
 	},
-
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("format", "Output the log messages in this format: 'text' or 'json'."),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
+
+		format, _, err := req.Option("format").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if format != "" && format != extconfig.LogFormatText && format != extconfig.LogFormatJSON {
+			res.SetError(fmt.Errorf("unknown format %q, expected 'text' or 'json'", format), cmdkit.ErrClient)
+			return
+		}
+
 		r, w := io.Pipe()
+		if format != "" {
+			go func() {
+				w.CloseWithError(corelog.Tail(ctx, w, format))
+			}()
+			res.SetOutput(r)
+			return
+		}
+
 		go func() {
 			defer w.Close()
 			<-ctx.Done()
@@ -110,3 +161,20 @@ Outputs event log messages (not other log messages) as they are generated.
 		res.SetOutput(r)
 	},
 }
+
+// saveLogLevel saves the level of subsystem to the Logging.Levels config key.
+// The level of "*" replaces the saved levels, as it does for the running
+// daemon.
+func saveLogLevel(r repo.Repo, subsystem, level string) error {
+	cfg, err := extconfig.LoadLogging(r)
+	if err != nil {
+		return err
+	}
+
+	levels := cfg.Levels
+	if levels == nil || subsystem == "*" {
+		levels = make(map[string]string)
+	}
+	levels[subsystem] = level
+	return r.SetConfigKey(extconfig.LoggingLevelsKey, levels)
+}
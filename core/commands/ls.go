@@ -1,32 +1,26 @@
 package commands
 
 import (
-	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"text/tabwriter"
 
-	cmds "github.com/ipfs/go-ipfs/commands"
-	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
-	unixfs "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
-	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	options "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	unixfspb "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/pb"
-	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
-	resolver "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path/resolver"
-	merkledag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
-	blockservice "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
 
-	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
-	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
-	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 )
 
 type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfspb.Data_DataType
+	MIME       string `json:",omitempty"`
 }
 
 type LsObject struct {
@@ -38,6 +32,13 @@ type LsOutput struct {
 	Objects []LsObject
 }
 
+const (
+	lsHeadersOptionName     = "headers"
+	lsResolveTypeOptionName = "resolve-type"
+	lsStreamOptionName      = "stream"
+	lsLongOptionName        = "long"
+)
+
 var LsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List directory contents for Unix filesystem objects.",
@@ -48,6 +49,15 @@ the following format:
   <link base58 hash> <link size in bytes> <link name>
 
 The JSON output contains type information.
+
+With --stream, the links are output as they are listed instead of once the
+whole directory is, which is useful for large sharded directories. Combined
+with --resolve-type=false, the linked objects aren't fetched.
+
+With --long, the MIME types of the files are output too, detected from their
+first bytes like the gateway does:
+
+  <link base58 hash> <link size in bytes> <link MIME type> <link name>
 `,
 	},
 
@@ -55,157 +65,160 @@ The JSON output contains type information.
 		cmdkit.StringArg("ipfs-path", true, true, "The path to the IPFS object(s) to list links from.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
-		cmdkit.BoolOption("headers", "v", "Print table headers (Hash, Size, Name)."),
-		cmdkit.BoolOption("resolve-type", "Resolve linked objects to find out their types.").WithDefault(true),
+		cmdkit.BoolOption(lsHeadersOptionName, "v", "Print table headers (Hash, Size, Name)."),
+		cmdkit.BoolOption(lsResolveTypeOptionName, "Resolve linked objects to find out their types.").WithDefault(true),
+		cmdkit.BoolOption(lsStreamOptionName, "s", "Output the links as they are listed."),
+		cmdkit.BoolOption(lsLongOptionName, "l", "Output the MIME types of the files, fetching their first blocks."),
 	},
-	Run: func(req cmds.Request, res cmds.Response) {
-		nd, err := req.InvocContext().GetNode()
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
-		}
-
-		// get options early -> exit early in case of error
-		if _, _, err := req.Option("headers").Bool(); err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
-		}
-
-		resolve, _, err := req.Option("resolve-type").Bool()
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetRequestApi(env, req.Options)
 		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
+			return err
 		}
 
-		dserv := nd.DAG
-		if !resolve {
-			offlineexch := offline.Exchange(nd.Blockstore)
-			bserv := blockservice.New(nd.Blockstore, offlineexch)
-			dserv = merkledag.NewDAGService(bserv)
-		}
+		resolve, _ := req.Options[lsResolveTypeOptionName].(bool)
+		stream, _ := req.Options[lsStreamOptionName].(bool)
+		long, _ := req.Options[lsLongOptionName].(bool)
 
-		paths := req.Arguments()
-
-		var dagnodes []ipld.Node
-		for _, fpath := range paths {
-			p, err := path.ParsePath(fpath)
+		paths := req.Arguments
+		output := make([]LsObject, len(paths))
+		for i, fpath := range paths {
+			p, err := coreiface.ParsePath(fpath)
 			if err != nil {
-				res.SetError(err, cmdkit.ErrNormal)
-				return
-			}
-
-			r := &resolver.Resolver{
-				DAG:         nd.DAG,
-				ResolveOnce: uio.ResolveUnixfsOnce,
+				return err
 			}
 
-			dagnode, err := core.Resolve(req.Context(), nd.Namesys, r, p)
+			links, err := api.Unixfs().Ls(req.Context, p,
+				options.Unixfs.ResolveType(resolve),
+				options.Unixfs.ResolveSize(false),
+				options.Unixfs.ResolveMIME(long),
+			)
 			if err != nil {
-				res.SetError(err, cmdkit.ErrNormal)
-				return
-			}
-			dagnodes = append(dagnodes, dagnode)
-		}
-
-		output := make([]LsObject, len(req.Arguments()))
-
-		for i, dagnode := range dagnodes {
-			dir, err := uio.NewDirectoryFromNode(nd.DAG, dagnode)
-			if err != nil && err != uio.ErrNotADir {
-				res.SetError(fmt.Errorf("the data in %s (at %q) is not a UnixFS directory: %s", dagnode.Cid(), paths[i], err), cmdkit.ErrNormal)
-				return
+				return err
 			}
 
-			var links []*ipld.Link
-			if dir == nil {
-				links = dagnode.Links()
-			} else {
-				links, err = dir.Links(req.Context())
-				if err != nil {
-					res.SetError(err, cmdkit.ErrNormal)
-					return
+			output[i] = LsObject{Hash: fpath, Links: []LsLink{}}
+			for link := range links {
+				if link.Err != nil {
+					return link.Err
 				}
-			}
-
-			output[i] = LsObject{
-				Hash:  paths[i],
-				Links: make([]LsLink, len(links)),
-			}
-
-			for j, link := range links {
-				t := unixfspb.Data_DataType(-1)
-
-				switch link.Cid.Type() {
-				case cid.Raw:
-					// No need to check with raw leaves
-					t = unixfspb.Data_File
-				case cid.DagProtobuf:
-					linkNode, err := link.GetNode(req.Context(), dserv)
-					if err == ipld.ErrNotFound && !resolve {
-						// not an error
-						linkNode = nil
-					} else if err != nil {
-						res.SetError(err, cmdkit.ErrNormal)
-						return
-					}
 
-					if pn, ok := linkNode.(*merkledag.ProtoNode); ok {
-						d, err := unixfs.FromBytes(pn.Data())
-						if err != nil {
-							res.SetError(err, cmdkit.ErrNormal)
-							return
-						}
-						t = d.GetType()
-					}
+				lsLink := LsLink{
+					Name: link.Link.Name,
+					Hash: link.Link.Cid.String(),
+					Size: link.Link.Size,
+					Type: link.Type,
+					MIME: link.MIME,
 				}
-				output[i].Links[j] = LsLink{
-					Name: link.Name,
-					Hash: link.Cid.String(),
-					Size: link.Size,
-					Type: t,
+				if !stream {
+					output[i].Links = append(output[i].Links, lsLink)
+					continue
+				}
+
+				obj := LsObject{Hash: fpath, Links: []LsLink{lsLink}}
+				if err := res.Emit(&LsOutput{[]LsObject{obj}}); err != nil {
+					return err
 				}
 			}
 		}
 
-		res.SetOutput(&LsOutput{output})
+		if stream {
+			return nil
+		}
+		return cmds.EmitOnce(res, &LsOutput{output})
 	},
-	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			req := res.Request()
+			multiple := len(req.Arguments) > 1
+
+			lastObjectHash := ""
+			for {
+				v, err := res.Next()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					return err
+				}
 
-			v, err := unwrapOutput(res.Output())
-			if err != nil {
-				return nil, err
+				output, ok := v.(*LsOutput)
+				if !ok {
+					return e.New(e.TypeErr(output, v))
+				}
+				lastObjectHash = tabularOutput(req, os.Stdout, output, lastObjectHash)
 			}
 
-			headers, _, _ := res.Request().Option("headers").Bool()
+			if multiple && lastObjectHash != "" {
+				fmt.Fprintln(os.Stdout)
+			}
+			return nil
+		},
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
 			output, ok := v.(*LsOutput)
 			if !ok {
-				return nil, e.TypeErr(output, v)
+				return e.TypeErr(output, v)
 			}
 
-			buf := new(bytes.Buffer)
-			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
-			for _, object := range output.Objects {
-				if len(output.Objects) > 1 {
-					fmt.Fprintf(w, "%s:\n", object.Hash)
-				}
-				if headers {
-					fmt.Fprintln(w, "Hash\tSize\tName")
-				}
-				for _, link := range object.Links {
-					if link.Type == unixfspb.Data_Directory {
-						link.Name += "/"
-					}
-					fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
-				}
-				if len(output.Objects) > 1 {
-					fmt.Fprintln(w)
-				}
+			if tabularOutput(req, w, output, "") != "" && len(req.Arguments) > 1 {
+				fmt.Fprintln(w)
 			}
-			w.Flush()
-
-			return buf, nil
-		},
+			return nil
+		}),
 	},
 	Type: LsOutput{},
 }
+
+// tabularOutput writes the links of output, starting a new table when the
+// object differs from the last one written, and returns the last object.
+func tabularOutput(req *cmds.Request, w io.Writer, output *LsOutput, lastObjectHash string) string {
+	headers, _ := req.Options[lsHeadersOptionName].(bool)
+	stream, _ := req.Options[lsStreamOptionName].(bool)
+	long, _ := req.Options[lsLongOptionName].(bool)
+	multiple := len(req.Arguments) > 1
+
+	// the columns can't be aligned on the whole listing when it is
+	// streamed, so they get a minimum width instead
+	minWidth := 1
+	if stream {
+		minWidth = 10
+	}
+
+	tw := tabwriter.NewWriter(w, minWidth, 2, 1, ' ', 0)
+	for _, object := range output.Objects {
+		if object.Hash != lastObjectHash {
+			if multiple {
+				if lastObjectHash != "" {
+					fmt.Fprintln(tw)
+				}
+				fmt.Fprintf(tw, "%s:\n", object.Hash)
+			}
+			if headers && long {
+				fmt.Fprintln(tw, "Hash\tSize\tMIME\tName")
+			} else if headers {
+				fmt.Fprintln(tw, "Hash\tSize\tName")
+			}
+			lastObjectHash = object.Hash
+		}
+
+		for _, link := range object.Links {
+			if link.Type == unixfspb.Data_Directory {
+				link.Name += "/"
+			}
+			if !long {
+				fmt.Fprintf(tw, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
+				continue
+			}
+			mime := link.MIME
+			if mime == "" {
+				mime = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", link.Hash, link.Size, mime, link.Name)
+		}
+	}
+	tw.Flush()
+
+	return lastObjectHash
+}
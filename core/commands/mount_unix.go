@@ -10,11 +10,20 @@ import (
 	cmds "github.com/ipfs/go-ipfs/commands"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
 )
 
+// MountOutput is the output of 'ipfs mount'.
+type MountOutput struct {
+	config.Mounts
+
+	// MFS is the mountpoint of the files root, if it was mounted.
+	MFS string `json:",omitempty"`
+}
+
 var MountCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Mounts IPFS to the filesystem (read-only).",
@@ -29,6 +38,9 @@ You may have to create /ipfs and /ipns before using 'ipfs mount':
 > sudo chown $(whoami) /ipfs /ipns
 > ipfs daemon &
 > ipfs mount
+
+The files root of 'ipfs files' can also be mounted read-write with
+--mfs-path, or with the Mounts.MFS config setting.
 `,
 		LongDescription: `
 Mount IPFS at a read-only mountpoint on the OS. The default, /ipfs and /ipns,
@@ -36,6 +48,11 @@ are set in the configuration file, but can be overriden by the options.
 All IPFS objects will be accessible under this directory. Note that the
 root will not be listable, as it is virtual. Access known paths directly.
 
+The files root of 'ipfs files' is mounted read-write at the path of
+--mfs-path, or of the Mounts.MFS config setting. It isn't mounted when
+neither is set. The changes made through the mountpoint are written to
+the files root when the files are closed, or when they are fsynced.
+
 You may have to create /ipfs and /ipns before using 'ipfs mount':
 
 > sudo mkdir /ipfs /ipns
@@ -75,6 +92,7 @@ baz
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("ipfs-path", "f", "The path where IPFS should be mounted."),
 		cmdkit.StringOption("ipns-path", "n", "The path where IPNS should be mounted."),
+		cmdkit.StringOption("mfs-path", "m", "The path where MFS should be mounted read-write."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		cfg, err := req.InvocContext().GetConfig()
@@ -114,18 +132,32 @@ baz
 			nsdir = cfg.Mounts.IPNS // NB: be sure to not redeclare!
 		}
 
-		err = nodeMount.Mount(node, fsdir, nsdir)
+		mfsdir, found, err := req.Option("m").String()
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		if !found {
+			mfsdir, err = extconfig.LoadMountsMFS(node.Repo)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
 
-		var output config.Mounts
+		err = nodeMount.Mount(node, fsdir, nsdir, mfsdir)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		var output MountOutput
 		output.IPFS = fsdir
 		output.IPNS = nsdir
+		output.MFS = mfsdir
 		res.SetOutput(&output)
 	},
-	Type: config.Mounts{},
+	Type: MountOutput{},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
 			v, err := unwrapOutput(res.Output())
@@ -133,13 +165,16 @@ baz
 				return nil, err
 			}
 
-			mnts, ok := v.(*config.Mounts)
+			mnts, ok := v.(*MountOutput)
 			if !ok {
 				return nil, e.TypeErr(mnts, v)
 			}
 
 			s := fmt.Sprintf("IPFS mounted at: %s\n", mnts.IPFS)
 			s += fmt.Sprintf("IPNS mounted at: %s\n", mnts.IPNS)
+			if mnts.MFS != "" {
+				s += fmt.Sprintf("MFS mounted at: %s\n", mnts.MFS)
+			}
 			return strings.NewReader(s), nil
 		},
 	},
@@ -0,0 +1,285 @@
+package name
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
+	isd "gx/ipfs/QmZmmuAXgX73UQmX1jRKjTGmjzq24Jinqkq8vzkBtno4uX/go-is-domain"
+)
+
+const dnslinkPrefix = "dnslink="
+
+// DNSLinkRecord is a DNS record to set for a DNSLink.
+type DNSLinkRecord struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// String formats the record like a zone file.
+func (r DNSLinkRecord) String() string {
+	return fmt.Sprintf("%s. %s %q", r.Name, r.Type, r.Value)
+}
+
+// DNSLinkCheckOutput is the output of 'ipfs name dnslink check'.
+type DNSLinkCheckOutput struct {
+	Domain string
+	// Record is the name holding the DNSLink, empty when none was found.
+	Record   string
+	TXT      []string `json:",omitempty"`
+	Value    string   `json:",omitempty"`
+	Resolved string   `json:",omitempty"`
+	Problems []string `json:",omitempty"`
+	// Suggested is the record fixing the problems, when it's known.
+	Suggested *DNSLinkRecord `json:",omitempty"`
+	OK        bool
+}
+
+// DNSLinkCmd is the 'ipfs name dnslink' command.
+var DNSLinkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check and set up DNSLink records.",
+		ShortDescription: `
+DNSLink publishes a path under a domain name with a TXT record on the
+_dnslink subdomain:
+
+  _dnslink.example.com. TXT "dnslink=/ipfs/<hash>"
+
+'ipfs name dnslink check' diagnoses the DNSLink of a domain, and
+'ipfs name dnslink suggest' writes the record to set for a path.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"check":   dnslinkCheckCmd,
+		"suggest": dnslinkSuggestCmd,
+	},
+}
+
+var dnslinkCheckCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Diagnose the DNSLink of a domain.",
+		ShortDescription: `
+'ipfs name dnslink check' looks up the TXT records of _dnslink.<domain>, and of
+the domain itself for the older setups, checks that exactly one of them is a
+valid DNSLink and that its path resolves, and writes the problems found along
+with the record fixing them. It exits with an error when there's a problem.
+
+Use --enc=json for a machine-readable output.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("domain", true, false, "The domain to check."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		domain := strings.TrimSuffix(strings.TrimPrefix(req.Arguments[0], "/ipns/"), ".")
+		if !isd.IsDomain(domain) {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%s is not a valid domain name", domain)
+		}
+
+		out := checkDNSLink(domain, net.LookupTXT)
+		if out.Value != "" {
+			resolved, err := resolveDNSLinkPath(req, n, path.Path(out.Value))
+			if err != nil {
+				out.Problems = append(out.Problems, fmt.Sprintf("%s does not resolve: %s", out.Value, err))
+			} else {
+				out.Resolved = resolved
+			}
+		}
+		out.OK = len(out.Problems) == 0
+
+		if err := res.Emit(out); err != nil {
+			return err
+		}
+		if !out.OK {
+			return fmt.Errorf("the DNSLink of %s has problems", domain)
+		}
+		return nil
+	},
+	Type: DNSLinkCheckOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DNSLinkCheckOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			for _, txt := range out.TXT {
+				fmt.Fprintf(w, "%s: %q\n", out.Record, txt)
+			}
+			if out.Resolved != "" {
+				fmt.Fprintf(w, "%s resolves to %s\n", out.Value, out.Resolved)
+			}
+			for _, p := range out.Problems {
+				fmt.Fprintf(w, "problem: %s\n", p)
+			}
+			if out.Suggested != nil {
+				fmt.Fprintf(w, "set: %s\n", out.Suggested)
+			}
+			if out.OK {
+				fmt.Fprintf(w, "the DNSLink of %s is valid\n", out.Domain)
+			}
+			return nil
+		}),
+	},
+}
+
+var dnslinkSuggestCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write the DNSLink record to set for a path.",
+		ShortDescription: `
+'ipfs name dnslink suggest' writes the TXT record publishing the path under
+the domain, after checking that the path resolves. The path defaults to the
+IPNS name of the node, so that the domain follows its 'ipfs name publish'.
+
+Example:
+
+  > ipfs name dnslink suggest example.com /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  _dnslink.example.com. TXT "dnslink=/ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy"
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("domain", true, false, "The domain to publish the path under."),
+		cmdkit.StringArg("path", false, false, "The path to publish. Defaults to the IPNS name of the node."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		domain := strings.TrimSuffix(req.Arguments[0], ".")
+		if !isd.IsDomain(domain) {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%s is not a valid domain name", domain)
+		}
+
+		var p path.Path
+		if len(req.Arguments) > 1 {
+			p, err = path.ParsePath(req.Arguments[1])
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+			}
+		} else {
+			if n.Identity == "" {
+				return errors.New("identity not loaded")
+			}
+			p = path.FromString("/ipns/" + n.Identity.Pretty())
+		}
+
+		if p.Segments()[0] != "ipns" {
+			if _, err := resolveDNSLinkPath(req, n, p); err != nil {
+				return fmt.Errorf("%s does not resolve: %s", p, err)
+			}
+		}
+
+		return cmds.EmitOnce(res, dnslinkRecord(domain, p))
+	},
+	Type: DNSLinkRecord{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			r, ok := v.(*DNSLinkRecord)
+			if !ok {
+				return e.TypeErr(r, v)
+			}
+			_, err := fmt.Fprintln(w, r)
+			return err
+		}),
+	},
+}
+
+func dnslinkRecord(domain string, p path.Path) *DNSLinkRecord {
+	return &DNSLinkRecord{
+		Name:  "_dnslink." + domain,
+		Type:  "TXT",
+		Value: dnslinkPrefix + p.String(),
+	}
+}
+
+// resolveDNSLinkPath resolves p to the node it points to, returning its
+// /ipfs path.
+func resolveDNSLinkPath(req *cmds.Request, n *core.IpfsNode, p path.Path) (string, error) {
+	if !n.OnlineMode() {
+		if err := n.SetupOfflineRouting(); err != nil {
+			return "", err
+		}
+	}
+	nd, err := core.Resolve(req.Context, n.Namesys, n.Resolver, p)
+	if err != nil {
+		return "", err
+	}
+	return "/ipfs/" + nd.Cid().String(), nil
+}
+
+// checkDNSLink looks up the DNSLink of domain, without resolving its path.
+// The Value of the output is set when a single valid DNSLink was found.
+func checkDNSLink(domain string, lookup func(string) ([]string, error)) *DNSLinkCheckOutput {
+	out := &DNSLinkCheckOutput{Domain: domain}
+	sub := "_dnslink." + domain
+
+	found := func(name string) bool {
+		txt, err := lookup(name)
+		if err != nil {
+			return false
+		}
+		for _, t := range txt {
+			if strings.HasPrefix(t, dnslinkPrefix) || isBareHash(t) {
+				out.TXT = append(out.TXT, t)
+			}
+		}
+		if len(out.TXT) == 0 {
+			return false
+		}
+		out.Record = name
+		return true
+	}
+
+	if !found(sub) {
+		if !found(domain) {
+			out.Problems = append(out.Problems, fmt.Sprintf("no TXT record starting with %q on %s", dnslinkPrefix, sub))
+			return out
+		}
+		out.Problems = append(out.Problems, fmt.Sprintf("the DNSLink is set on %s, set it on %s", domain, sub))
+	}
+
+	if len(out.TXT) > 1 {
+		out.Problems = append(out.Problems, fmt.Sprintf("%s has %d DNSLink records, keep only one", out.Record, len(out.TXT)))
+		return out
+	}
+
+	txt := out.TXT[0]
+	if isBareHash(txt) {
+		out.Problems = append(out.Problems, fmt.Sprintf("%q is a bare hash, prefix it with %q", txt, dnslinkPrefix+"/ipfs/"))
+		out.Value = "/ipfs/" + txt
+	} else {
+		p, err := path.ParsePath(strings.TrimPrefix(txt, dnslinkPrefix))
+		if err != nil {
+			out.Problems = append(out.Problems, fmt.Sprintf("%q is not a valid path: %s", txt, err))
+			return out
+		}
+		out.Value = p.String()
+	}
+
+	if len(out.Problems) > 0 {
+		out.Suggested = dnslinkRecord(domain, path.Path(out.Value))
+	}
+	return out
+}
+
+func isBareHash(txt string) bool {
+	_, err := path.ParseCidToPath(txt)
+	return err == nil
+}
@@ -0,0 +1,77 @@
+package name
+
+import (
+	"errors"
+	"testing"
+)
+
+const testHash = "QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy"
+
+func testLookup(records map[string][]string) func(string) ([]string, error) {
+	return func(name string) ([]string, error) {
+		txt, ok := records[name]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return txt, nil
+	}
+}
+
+func TestCheckDNSLink(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		records   map[string][]string
+		value     string
+		problems  int
+		suggested bool
+	}{
+		{
+			desc:    "valid",
+			records: map[string][]string{"_dnslink.example.com": {"v=spf1 -all", "dnslink=/ipfs/" + testHash}},
+			value:   "/ipfs/" + testHash,
+		},
+		{
+			desc:     "missing",
+			records:  map[string][]string{"example.com": {"v=spf1 -all"}},
+			problems: 1,
+		},
+		{
+			desc:      "on the domain",
+			records:   map[string][]string{"example.com": {"dnslink=/ipns/example.org"}},
+			value:     "/ipns/example.org",
+			problems:  1,
+			suggested: true,
+		},
+		{
+			desc:      "bare hash",
+			records:   map[string][]string{"_dnslink.example.com": {testHash}},
+			value:     "/ipfs/" + testHash,
+			problems:  1,
+			suggested: true,
+		},
+		{
+			desc:     "several",
+			records:  map[string][]string{"_dnslink.example.com": {"dnslink=/ipfs/" + testHash, "dnslink=/ipns/example.org"}},
+			problems: 1,
+		},
+		{
+			desc:     "invalid path",
+			records:  map[string][]string{"_dnslink.example.com": {"dnslink=/ipfs/nothash"}},
+			problems: 1,
+		},
+	} {
+		out := checkDNSLink("example.com", testLookup(tc.records))
+		if out.Value != tc.value {
+			t.Errorf("%s: expected value %q, got %q", tc.desc, tc.value, out.Value)
+		}
+		if len(out.Problems) != tc.problems {
+			t.Errorf("%s: expected %d problems, got %v", tc.desc, tc.problems, out.Problems)
+		}
+		if (out.Suggested != nil) != tc.suggested {
+			t.Errorf("%s: unexpected suggestion %v", tc.desc, out.Suggested)
+		}
+		if tc.suggested && out.Suggested.String() != `_dnslink.example.com. TXT "dnslink=`+tc.value+`"` {
+			t.Errorf("%s: unexpected suggestion %s", tc.desc, out.Suggested)
+		}
+	}
+}
@@ -30,6 +30,8 @@ const (
 	nocacheOptionName        = "nocache"
 	dhtRecordCountOptionName = "dht-record-count"
 	dhtTimeoutOptionName     = "dht-timeout"
+	streamOptionName         = "stream"
+	streamIntervalOptionName = "stream-interval"
 )
 
 var IpnsCmd = &cmds.Command{
@@ -67,6 +69,17 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+Follow the updates of a name:
+
+  > ipfs name resolve --stream QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz
+  /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
+With --stream, the resolution stays open and a new line is written each time
+the value of the name changes. The name is resolved again, bypassing the
+cache, every --stream-interval, and, when IPNS over pubsub is enabled, as soon
+as a new record is published.
+
 `,
 	},
 
@@ -78,9 +91,11 @@ Resolve the value of a dnslink:
 		cmdkit.BoolOption(nocacheOptionName, "n", "Do not use cached entries."),
 		cmdkit.UintOption(dhtRecordCountOptionName, "dhtrc", "Number of records to request for DHT resolution."),
 		cmdkit.StringOption(dhtTimeoutOptionName, "dhtt", "Max time to collect values during DHT resolution eg \"30s\". Pass 0 for no timeout."),
+		cmdkit.BoolOption(streamOptionName, "s", "Keep resolving and write the new values of the name."),
+		cmdkit.StringOption(streamIntervalOptionName, "Time between the resolutions of --stream.").WithDefault("1m"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		n, err := cmdenv.GetNode(env)
+		n, err := cmdenv.GetRequestNode(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -94,6 +109,7 @@ Resolve the value of a dnslink:
 
 		nocache, _ := req.Options["nocache"].(bool)
 		local, _ := req.Options["local"].(bool)
+		stream, _ := req.Options[streamOptionName].(bool)
 
 		// default to nodes namesys resolver
 		var resolver namesys.Resolver = n.Namesys
@@ -107,7 +123,8 @@ Resolve the value of a dnslink:
 			resolver = namesys.NewIpnsResolver(offroute)
 		}
 
-		if nocache {
+		// the updates of a streamed name must not be hidden by the cache
+		if nocache || (stream && !local) {
 			resolver = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), 0)
 		}
 
@@ -148,6 +165,18 @@ Resolve the value of a dnslink:
 			name = "/ipns/" + name
 		}
 
+		if stream {
+			intervalStr, _ := req.Options[streamIntervalOptionName].(string)
+			interval, err := time.ParseDuration(intervalStr)
+			if err != nil {
+				return err
+			}
+			if interval <= 0 {
+				return errors.New("stream interval must be > 0")
+			}
+			return streamResolve(req.Context, n, resolver, name, ropts, interval, res)
+		}
+
 		output, err := resolver.Resolve(req.Context, name, ropts...)
 		if err != nil {
 			return err
@@ -63,5 +63,6 @@ Resolve the value of a dnslink:
 		"publish": PublishCmd,
 		"resolve": IpnsCmd,
 		"pubsub":  IpnsPubsubCmd,
+		"dnslink": DNSLinkCmd,
 	},
 }
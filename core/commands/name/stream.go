@@ -0,0 +1,84 @@
+package name
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	nsopts "github.com/ipfs/go-ipfs/namesys/opts"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
+	psrouter "gx/ipfs/QmYz19WrC7TskcVrSbH6sdPd1PvPMAnM9dEUnSTz4pwCWG/go-libp2p-pubsub-router"
+)
+
+// streamResolve emits the value of name, then its new value each time it
+// changes, until the request is canceled. The name is resolved again at
+// every interval and, when IPNS over pubsub is enabled, as soon as a record
+// is published on its topic.
+func streamResolve(ctx context.Context, n *core.IpfsNode, r namesys.Resolver, name string, ropts []nsopts.ResolveOpt, interval time.Duration, res cmds.ResponseEmitter) error {
+	updates := make(chan struct{}, 1)
+	if n.PSRouter != nil && n.Floodsub != nil {
+		if err := watchName(ctx, n, name, updates); err != nil {
+			log.Warningf("not watching the pubsub topic of %s: %s", name, err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last path.Path
+	for {
+		p, err := r.Resolve(ctx, name, ropts...)
+		switch {
+		case err == nil && p != last:
+			if err := res.Emit(&ResolvedPath{p}); err != nil {
+				return err
+			}
+			last = p
+		case err != nil && last == "":
+			return err
+		case err != nil:
+			// keep the last value until the name resolves again
+			log.Debugf("failed to resolve %s again: %s", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-updates:
+		}
+	}
+}
+
+// watchName signals on updates the records of name published over pubsub.
+// The dnslink names have no topic, they are only resolved at intervals.
+func watchName(ctx context.Context, n *core.IpfsNode, name string, updates chan<- struct{}) error {
+	key := strings.SplitN(strings.TrimPrefix(name, "/ipns/"), "/", 2)[0]
+	pid, err := peer.IDB58Decode(key)
+	if err != nil {
+		return nil
+	}
+
+	sub, err := n.Floodsub.Subscribe(psrouter.KeyToTopic("/ipns/" + string(pid)))
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer sub.Cancel()
+		for {
+			if _, err := sub.Next(ctx); err != nil {
+				return
+			}
+			select {
+			case updates <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return nil
+}
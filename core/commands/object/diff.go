@@ -6,6 +6,7 @@ import (
 	"io"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 	"github.com/ipfs/go-ipfs/dagutils"
@@ -52,7 +53,7 @@ Example:
 		cmdkit.BoolOption("verbose", "v", "Print extra information."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
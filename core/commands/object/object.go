@@ -12,6 +12,7 @@ import (
 
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 	"github.com/ipfs/go-ipfs/core/coreapi/interface/options"
@@ -82,7 +83,7 @@ is the raw data of the object.
 		cmdkit.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -121,7 +122,7 @@ multihash.
 		cmdkit.BoolOption("headers", "v", "Print table headers (Hash, Size, Name)."),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -230,7 +231,7 @@ Supported values are:
 		cmdkit.StringOption("data-encoding", "Encoding type of the data field, either \"text\" or \"base64\".").WithDefault("text"),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -334,7 +335,7 @@ var ObjectStatCmd = &oldcmds.Command{
 		cmdkit.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -439,7 +440,7 @@ And then run:
 		cmdkit.BoolOption("quiet", "q", "Write minimal output."),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -524,7 +525,7 @@ Available templates:
 		cmdkit.StringArg("template", false, false, "Template to use. Optional."),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -68,7 +68,7 @@ the limit will not be respected by the network.
 		cmdkit.FileArg("data", true, false, "Data to append.").EnableStdin(),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		api, err := cmdenv.GetApi(env)
+		api, err := cmdenv.GetRequestApi(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -115,7 +115,7 @@ Example:
 		cmdkit.FileArg("data", true, false, "The data to set the object to.").EnableStdin(),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 
 		root, err := coreiface.ParsePath(req.StringArguments()[0])
 		if err != nil {
@@ -155,7 +155,7 @@ Removes a link by the given name from root.
 		cmdkit.StringArg("link", true, false, "Name of the link to remove."),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -207,7 +207,7 @@ to a file containing 'bar', and returns the hash of the new object.
 		cmdkit.BoolOption("create", "p", "Create intermediary nodes."),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
-		api, err := req.InvocContext().GetApi()
+		api, err := cmdenv.GetRequestApi(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
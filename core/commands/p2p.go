@@ -14,6 +14,7 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	p2p "github.com/ipfs/go-ipfs/p2p"
 
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
 	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
@@ -29,8 +30,23 @@ type P2PListenerInfoOutput struct {
 	Protocol      string
 	ListenAddress string
 	TargetAddress string
+	AllowedPeers  []string `json:",omitempty"`
+	Status        string
+	Error         string `json:",omitempty"`
 }
 
+// Statuses of the p2p listeners
+const (
+	// P2PStatusOk is the status of the listeners forwarding connections
+	P2PStatusOk = "ok"
+	// P2PStatusFailing is the status of the listeners that failed to
+	// forward their last connection
+	P2PStatusFailing = "failing"
+	// P2PStatusFailed is the status of the listeners of the P2P.Forwards
+	// config that could not be created
+	P2PStatusFailed = "failed"
+)
+
 // P2PStreamInfoOutput is output type of streams command
 type P2PStreamInfoOutput struct {
 	HandlerID     string
@@ -79,10 +95,18 @@ Forward connections made to <listen-address> to <target-address>.
 <protocol> specifies the libp2p protocol name to use for libp2p
 connections and/or handlers. It must be prefixed with '` + P2PProtoPrefix + `'.
 
+If <listen-address> is a UDP address, the datagrams sent from each address
+are forwarded over their own libp2p stream, and the remote service must
+also forward them to a UDP address. The stream is closed once no datagram
+was sent or received for two minutes.
+
 Example:
   ipfs p2p forward ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/4567 /ipfs/QmPeer
     - Forward connections to 127.0.0.1:4567 to '` + P2PProtoPrefix + `myproto' service on /ipfs/QmPeer
 
+  ipfs p2p forward ` + P2PProtoPrefix + `dns /ip4/127.0.0.1/udp/5353 /ipfs/QmPeer
+    - Forward the datagrams sent to 127.0.0.1:5353 to '` + P2PProtoPrefix + `dns' service on /ipfs/QmPeer
+
 `,
 	},
 	Arguments: []cmdkit.Argument{
@@ -145,10 +169,20 @@ Create libp2p service and forward connections made to <target-address>.
 
 <protocol> specifies the libp2p handler name. It must be prefixed with '` + P2PProtoPrefix + `'.
 
+If <target-address> is a UDP address, the remote peers must forward the
+service from a UDP <listen-address> too, see 'ipfs p2p forward'.
+
+By default, any peer can connect to the service. The --allow-peer option
+restricts it to a comma-separated list of peer IDs, the streams of the other
+peers are reset.
+
 Example:
   ipfs p2p listen ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/1234
     - Forward connections to 'myproto' libp2p service to 127.0.0.1:1234
 
+  ipfs p2p listen --allow-peer=QmPeer1,QmPeer2 ` + P2PProtoPrefix + `dns /ip4/127.0.0.1/udp/53
+    - Forward the datagrams sent by QmPeer1 and QmPeer2 to 'dns' libp2p service to 127.0.0.1:53
+
 `,
 	},
 	Arguments: []cmdkit.Argument{
@@ -157,6 +191,7 @@ Example:
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("allow-custom-protocol", "Don't require /x/ prefix"),
+		cmdkit.StringOption("allow-peer", "Comma-separated list of the peer IDs allowed to connect. Defaults to any peer."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := p2pGetNode(req)
@@ -187,7 +222,19 @@ Example:
 			return
 		}
 
-		if err := forwardRemote(n.Context(), n.P2P, proto, target); err != nil {
+		var allowed []peer.ID
+		if allowOpt, found, _ := req.Option("allow-peer").String(); found {
+			for _, p := range strings.Split(allowOpt, ",") {
+				id, err := peer.IDB58Decode(strings.TrimSpace(p))
+				if err != nil {
+					res.SetError(fmt.Errorf("invalid peer ID %q: %s", p, err), cmdkit.ErrNormal)
+					return
+				}
+				allowed = append(allowed, id)
+			}
+		}
+
+		if err := forwardRemote(n.Context(), n.P2P, proto, target, allowed); err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
@@ -197,9 +244,9 @@ Example:
 }
 
 // forwardRemote forwards libp2p service connections to a manet address
-func forwardRemote(ctx context.Context, p *p2p.P2P, proto protocol.ID, target ma.Multiaddr) error {
+func forwardRemote(ctx context.Context, p *p2p.P2P, proto protocol.ID, target ma.Multiaddr, allowed []peer.ID) error {
 	// TODO: return some info
-	_, err := p.ForwardRemote(ctx, proto, target)
+	_, err := p.ForwardRemote(ctx, proto, target, allowed)
 	return err
 }
 
@@ -217,9 +264,20 @@ func forwardLocal(ctx context.Context, p *p2p.P2P, ps pstore.Peerstore, proto pr
 var p2pLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List active p2p listeners.",
+		ShortDescription: `
+Lists the active p2p listeners.
+
+With --detail, the peers allowed to connect to each listener are listed too,
+'*' meaning any peer, followed by the status of the listener:
+
+ok:       the last connection was forwarded
+failing:  the last connection could not be forwarded
+failed:   the listener of the P2P.Forwards config could not be created
+`,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("headers", "v", "Print table headers (Protocol, Listen, Target)."),
+		cmdkit.BoolOption("detail", "d", "Print the peers allowed to connect and the status of the listeners."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := p2pGetNode(req)
@@ -232,24 +290,33 @@ var p2pLsCmd = &cmds.Command{
 
 		n.P2P.ListenersLocal.Lock()
 		for _, listener := range n.P2P.ListenersLocal.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
-				Protocol:      string(listener.Protocol()),
-				ListenAddress: listener.ListenAddress().String(),
-				TargetAddress: listener.TargetAddress().String(),
-			})
+			output.Listeners = append(output.Listeners, listenerInfo(listener))
 		}
 		n.P2P.ListenersLocal.Unlock()
 
 		n.P2P.ListenersP2P.Lock()
 		for _, listener := range n.P2P.ListenersP2P.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
-				Protocol:      string(listener.Protocol()),
-				ListenAddress: listener.ListenAddress().String(),
-				TargetAddress: listener.TargetAddress().String(),
-			})
+			output.Listeners = append(output.Listeners, listenerInfo(listener))
 		}
 		n.P2P.ListenersP2P.Unlock()
 
+		if detail, _, _ := req.Option("detail").Bool(); detail {
+			for _, f := range n.P2P.Failed {
+				listen := f.ListenAddress
+				if listen == "" {
+					listen = "/ipfs/" + n.Identity.Pretty()
+				}
+				output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
+					Protocol:      string(f.Protocol),
+					ListenAddress: listen,
+					TargetAddress: f.TargetAddress,
+					AllowedPeers:  f.AllowedPeers,
+					Status:        P2PStatusFailed,
+					Error:         f.Err.Error(),
+				})
+			}
+		}
+
 		res.SetOutput(output)
 	},
 	Type: P2PLsOutput{},
@@ -261,15 +328,32 @@ var p2pLsCmd = &cmds.Command{
 			}
 
 			headers, _, _ := res.Request().Option("headers").Bool()
+			detail, _, _ := res.Request().Option("detail").Bool()
 			list := v.(*P2PLsOutput)
 			buf := new(bytes.Buffer)
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
 			for _, listener := range list.Listeners {
 				if headers {
-					fmt.Fprintln(w, "Protocol\tListen Address\tTarget Address")
+					if detail {
+						fmt.Fprintln(w, "Protocol\tListen Address\tTarget Address\tAllowed Peers\tStatus")
+					} else {
+						fmt.Fprintln(w, "Protocol\tListen Address\tTarget Address")
+					}
 				}
 
-				fmt.Fprintf(w, "%s\t%s\t%s\n", listener.Protocol, listener.ListenAddress, listener.TargetAddress)
+				if detail {
+					allowed := "*"
+					if len(listener.AllowedPeers) > 0 {
+						allowed = strings.Join(listener.AllowedPeers, ",")
+					}
+					status := listener.Status
+					if listener.Error != "" {
+						status += ": " + listener.Error
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", listener.Protocol, listener.ListenAddress, listener.TargetAddress, allowed, status)
+				} else {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", listener.Protocol, listener.ListenAddress, listener.TargetAddress)
+				}
 			}
 			w.Flush()
 
@@ -278,6 +362,23 @@ var p2pLsCmd = &cmds.Command{
 	},
 }
 
+func listenerInfo(listener p2p.Listener) P2PListenerInfoOutput {
+	info := P2PListenerInfoOutput{
+		Protocol:      string(listener.Protocol()),
+		ListenAddress: listener.ListenAddress().String(),
+		TargetAddress: listener.TargetAddress().String(),
+		Status:        P2PStatusOk,
+	}
+	if err := listener.LastError(); err != nil {
+		info.Status = P2PStatusFailing
+		info.Error = err.Error()
+	}
+	for _, p := range listener.AllowedPeers() {
+		info.AllowedPeers = append(info.AllowedPeers, p.Pretty())
+	}
+	return info
+}
+
 var p2pCloseCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Stop listening for new connections to forward.",
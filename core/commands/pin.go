@@ -3,13 +3,16 @@ package commands
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	progress "github.com/ipfs/go-ipfs/core/commands/progress"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	pin "github.com/ipfs/go-ipfs/pin"
 	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
@@ -22,6 +25,7 @@ import (
 	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
 	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
 	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 )
 
 var PinCmd = &cmds.Command{
@@ -35,6 +39,8 @@ var PinCmd = &cmds.Command{
 		"ls":     listPinCmd,
 		"verify": verifyPinCmd,
 		"update": updatePinCmd,
+		"export": exportPinCmd,
+		"import": importPinCmd,
 	},
 }
 
@@ -62,7 +68,7 @@ var addPinCmd = &cmds.Command{
 	},
 	Type: AddPinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -78,6 +84,15 @@ var addPinCmd = &cmds.Command{
 		}
 		showProgress, _, _ := req.Option("progress").Bool()
 
+		// the pinner fetches the missing blocks through the network, check
+		// that they are all stored first
+		if offline, _, _ := req.Option(cmdenv.OfflineOption).Bool(); offline {
+			if err := checkLocalPins(req.Context(), n, req.Arguments(), recursive); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
 		if !showProgress {
 			added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
 			if err != nil {
@@ -143,7 +158,8 @@ var addPinCmd = &cmds.Command{
 					added = out.Pins
 				} else {
 					// this can only happen if the progress option is set
-					fmt.Fprintf(res.Stderr(), "Fetched/Processed %d nodes\r", out.Progress)
+					ev := progress.Event{Blocks: int64(out.Progress)}
+					fmt.Fprintf(res.Stderr(), "\033[2K\rFetched/Processed %s", ev)
 				}
 
 				if res.Error() != nil {
@@ -187,7 +203,7 @@ collected if needed. (By default, recursively. Use -r=false for direct pins.)
 	},
 	Type: PinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -281,7 +297,7 @@ Example:
 		cmdkit.BoolOption("quiet", "q", "Write just hashes of objects."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -364,7 +380,7 @@ new pin and removing the old one.
 	},
 	Type: PinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -440,13 +456,24 @@ new pin and removing the old one.
 var verifyPinCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Verify that recursive pins are complete.",
+		ShortDescription: `
+'ipfs pin verify' checks that the blocks of every recursive pin are in the
+local blockstore. By default only the broken pins are written, with the
+blocks that are missing or invalid. Use --status-filter to select the pins
+written, and --enc=json for output meant to be read by programs.
+
+With --refetch, the missing blocks are fetched from the network before
+declaring a pin broken. This requires the daemon to be online.
+`,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("verbose", "Also write the hashes of non-broken pins."),
 		cmdkit.BoolOption("quiet", "q", "Write just hashes of broken pins."),
+		cmdkit.StringOption("status-filter", "Only write the pins with this status: \"ok\", \"broken\" or \"all\". Defaults to \"all\" with --verbose, \"broken\" otherwise."),
+		cmdkit.BoolOption("refetch", "Try to fetch the missing blocks from the network before declaring a pin broken."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -454,15 +481,39 @@ var verifyPinCmd = &cmds.Command{
 
 		verbose, _, _ := res.Request().Option("verbose").Bool()
 		quiet, _, _ := res.Request().Option("quiet").Bool()
+		filter, _, _ := res.Request().Option("status-filter").String()
+		refetch, _, _ := res.Request().Option("refetch").Bool()
 
 		if verbose && quiet {
 			res.SetError(fmt.Errorf("the --verbose and --quiet options can not be used at the same time"), cmdkit.ErrNormal)
+			return
+		}
+
+		if refetch && !n.OnlineMode() {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
 		}
 
 		opts := pinVerifyOpts{
-			explain:   !quiet,
-			includeOk: verbose,
+			explain:       !quiet,
+			includeOk:     verbose,
+			includeBroken: true,
+			refetch:       refetch,
+		}
+		switch filter {
+		case "":
+		case "all":
+			opts.includeOk = true
+		case "ok":
+			opts.includeOk = true
+			opts.includeBroken = false
+		case "broken":
+			opts.includeOk = false
+		default:
+			res.SetError(fmt.Errorf("invalid status filter %q, must be \"ok\", \"broken\" or \"all\"", filter), cmdkit.ErrClient)
+			return
 		}
+
 		out := pinVerify(req.Context(), n, opts)
 
 		res.SetOutput(out)
@@ -493,6 +544,123 @@ var verifyPinCmd = &cmds.Command{
 	},
 }
 
+var exportPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write the pinset as a manifest.",
+		ShortDescription: `
+'ipfs pin export' writes the recursive and direct pins as a JSON manifest,
+to be imported by 'ipfs pin import' on another node. The pins are sorted, so
+the manifest can be kept in version control.
+
+Example:
+	$ ipfs pin export > pins.json
+	$ IPFS_PATH=~/.ipfs-replica ipfs pin import pins.json
+`,
+	},
+	Type: corerepo.PinManifest{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(corerepo.ExportPins(n))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			m, ok := v.(*corerepo.PinManifest)
+			if !ok {
+				return nil, e.TypeErr(m, v)
+			}
+
+			buf, err := json.MarshalIndent(m, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(append(buf, '\n')), nil
+		},
+	},
+}
+
+var importPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add the pins of a manifest.",
+		ShortDescription: `
+'ipfs pin import' adds the pins of a manifest written by 'ipfs pin export',
+keeping their type. The blocks missing from the node are fetched. The pins
+already there are skipped, so an interrupted import can be run again.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("manifest", true, false, "The pin manifest.").EnableStdin(),
+	},
+	Type: corerepo.PinImportResult{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		var m corerepo.PinManifest
+		if err := json.NewDecoder(file).Decode(&m); err != nil {
+			res.SetError(fmt.Errorf("invalid pin manifest: %s", err), cmdkit.ErrNormal)
+			return
+		}
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+		defer close(out)
+
+		err = corerepo.ImportPins(req.Context(), n, &m, func(r *corerepo.PinImportResult) {
+			select {
+			case out <- r:
+			case <-req.Context().Done():
+			}
+		})
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+		}
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			r, ok := v.(*corerepo.PinImportResult)
+			if !ok {
+				return nil, e.TypeErr(r, v)
+			}
+
+			buf := new(bytes.Buffer)
+			if r.Skipped {
+				fmt.Fprintf(buf, "skipped %s, already pinned\n", r.Cid)
+			} else if r.Type == "recursive" {
+				fmt.Fprintf(buf, "pinned %s recursively\n", r.Cid)
+			} else {
+				fmt.Fprintf(buf, "pinned %s directly\n", r.Cid)
+			}
+			return buf, nil
+		},
+	},
+}
+
 type RefKeyObject struct {
 	Type string
 }
@@ -590,6 +758,14 @@ type PinVerifyRes struct {
 type PinStatus struct {
 	Ok       bool
 	BadNodes []BadNode `json:",omitempty"`
+
+	// Missing lists the blocks of the pin absent from the blockstore, a
+	// subset of BadNodes.
+	Missing []string `json:",omitempty"`
+
+	// Refetched lists the blocks of the pin fetched from the network by
+	// --refetch.
+	Refetched []string `json:",omitempty"`
 }
 
 // BadNode is used in PinVerifyRes
@@ -599,10 +775,16 @@ type BadNode struct {
 }
 
 type pinVerifyOpts struct {
-	explain   bool
-	includeOk bool
+	explain       bool
+	includeOk     bool
+	includeBroken bool
+	refetch       bool
 }
 
+// pinVerifyRefetchTimeout bounds the fetch of every missing block by
+// 'pin verify --refetch'.
+const pinVerifyRefetchTimeout = time.Minute
+
 func pinVerify(ctx context.Context, n *core.IpfsNode, opts pinVerifyOpts) <-chan interface{} {
 	visited := make(map[string]PinStatus)
 
@@ -627,23 +809,37 @@ func pinVerify(ctx context.Context, n *core.IpfsNode, opts pinVerifyOpts) <-chan
 			return status
 		}
 
+		var refetched bool
 		links, err := getLinks(ctx, root)
+		if err == ipld.ErrNotFound && opts.refetch {
+			if refetched = refetchBlock(ctx, n, root); refetched {
+				links, err = getLinks(ctx, root)
+			}
+		}
 		if err != nil {
 			status := PinStatus{Ok: false}
 			if opts.explain {
 				status.BadNodes = []BadNode{BadNode{Cid: key, Err: err.Error()}}
+				if err == ipld.ErrNotFound {
+					status.Missing = []string{key}
+				}
 			}
 			visited[key] = status
 			return status
 		}
 
 		status := PinStatus{Ok: true}
+		if refetched && opts.explain {
+			status.Refetched = []string{key}
+		}
 		for _, lnk := range links {
 			res := checkPin(lnk.Cid)
 			if !res.Ok {
 				status.Ok = false
 				status.BadNodes = append(status.BadNodes, res.BadNodes...)
+				status.Missing = append(status.Missing, res.Missing...)
 			}
+			status.Refetched = append(status.Refetched, res.Refetched...)
 		}
 
 		visited[key] = status
@@ -655,7 +851,7 @@ func pinVerify(ctx context.Context, n *core.IpfsNode, opts pinVerifyOpts) <-chan
 		defer close(out)
 		for _, cid := range recPins {
 			pinStatus := checkPin(cid)
-			if !pinStatus.Ok || opts.includeOk {
+			if (pinStatus.Ok && opts.includeOk) || (!pinStatus.Ok && opts.includeBroken) {
 				select {
 				case out <- &PinVerifyRes{cid.String(), pinStatus}:
 				case <-ctx.Done():
@@ -668,10 +864,26 @@ func pinVerify(ctx context.Context, n *core.IpfsNode, opts pinVerifyOpts) <-chan
 	return out
 }
 
+// refetchBlock fetches the block c from the network, through the online
+// DAG of n which stores it locally, and reports whether it succeeded.
+func refetchBlock(ctx context.Context, n *core.IpfsNode, c cid.Cid) bool {
+	ctx, cancel := context.WithTimeout(ctx, pinVerifyRefetchTimeout)
+	defer cancel()
+
+	if _, err := n.DAG.Get(ctx, c); err != nil {
+		log.Debugf("pin verify: could not refetch %s: %s", c, err)
+		return false
+	}
+	return true
+}
+
 // Format formats PinVerifyRes
 func (r PinVerifyRes) Format(out io.Writer) {
 	if r.Ok {
 		fmt.Fprintf(out, "%s ok\n", r.Cid)
+		for _, c := range r.Refetched {
+			fmt.Fprintf(out, "  %s: refetched\n", c)
+		}
 	} else {
 		fmt.Fprintf(out, "%s broken\n", r.Cid)
 		for _, e := range r.BadNodes {
@@ -687,3 +899,31 @@ func cidsToStrings(cs []cid.Cid) []string {
 	}
 	return out
 }
+
+// checkLocalPins returns an error if a block that pinning paths needs is
+// missing from the local blockstore of n, an offline view.
+func checkLocalPins(ctx context.Context, n *core.IpfsNode, paths []string, recursive bool) error {
+	r := &resolver.Resolver{
+		DAG:         n.DAG,
+		ResolveOnce: uio.ResolveUnixfsOnce,
+	}
+
+	for _, fpath := range paths {
+		p, err := path.ParsePath(fpath)
+		if err != nil {
+			return err
+		}
+
+		dagnode, err := core.Resolve(ctx, n.Namesys, r, p)
+		if err != nil {
+			return fmt.Errorf("pin: %s", err)
+		}
+		if !recursive {
+			continue
+		}
+		if err := dag.FetchGraph(ctx, dagnode.Cid(), n.DAG); err != nil {
+			return fmt.Errorf("pin: %s", err)
+		}
+	}
+	return nil
+}
@@ -1,26 +1,37 @@
 package commands
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"os"
 	"strings"
 	"time"
 
-	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
 
-	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	"gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
 	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	iaddr "gx/ipfs/QmePSRaGafvmURQwQkHPDBJsaGwKXC1WpBBHVCQxdr8FPn/go-ipfs-addr"
 )
 
 const kPingTimeout = 10 * time.Second
 
+const (
+	pingCountOptionName    = "count"
+	pingIntervalOptionName = "interval"
+	pingInfiniteOptionName = "infinite"
+)
+
+// PingResult is either a message, when Text is set, or the result of a
+// ping: its round-trip time when Success is set, a lost ping otherwise.
 type PingResult struct {
 	Success bool
 	Time    time.Duration
@@ -38,204 +49,300 @@ var PingCmd = &cmds.Command{
 via the routing system, sends pings, waits for pongs, and prints out round-
 trip latency information.
 		`,
+		LongDescription: `
+'ipfs ping' is a tool to test sending data to other nodes. It finds nodes
+via the routing system, sends pings, waits for pongs, and prints out round-
+trip latency information.
+
+The peer is either a peer ID or a multiaddr ending with one, such as
+/ip4/1.2.3.4/udp/4001/quic/ipfs/<peer ID>. With a multiaddr, the pings are
+sent over a connection to that address, so that a given transport can be
+tested. An existing connection to the peer over another address is closed
+first.
+
+A ping that doesn't get a pong within 10 seconds is lost. Once done, or
+interrupted, the number of lost pings and the min/avg/max/stddev of the
+round-trip times are printed.
+		`,
 	},
 	Arguments: []cmdkit.Argument{
-		cmdkit.StringArg("peer ID", true, true, "ID of peer to be pinged.").EnableStdin(),
+		cmdkit.StringArg("peer ID", true, true, "ID or multiaddr of the peer to be pinged.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
-		cmdkit.IntOption("count", "n", "Number of ping messages to send.").WithDefault(10),
+		cmdkit.IntOption(pingCountOptionName, "n", "Number of ping messages to send.").WithDefault(10),
+		cmdkit.StringOption(pingIntervalOptionName, "i", "Time to wait between the pings, eg \"500ms\".").WithDefault("1s"),
+		cmdkit.BoolOption(pingInfiniteOptionName, "Ping until interrupted, ignoring --count."),
 	},
-	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			v, err := unwrapOutput(res.Output())
-			if err != nil {
-				return nil, err
-			}
-
-			obj, ok := v.(*PingResult)
-			if !ok {
-				return nil, u.ErrCast()
-			}
-
-			buf := new(bytes.Buffer)
-			if len(obj.Text) > 0 {
-				buf = bytes.NewBufferString(obj.Text + "\n")
-			} else if obj.Success {
-				fmt.Fprintf(buf, "Pong received: time=%.2f ms\n", obj.Time.Seconds()*1000)
-			} else {
-				fmt.Fprintf(buf, "Pong failed\n")
-			}
-			return buf, nil
-		},
-	},
-	Run: func(req cmds.Request, res cmds.Response) {
-		ctx := req.Context()
-		n, err := req.InvocContext().GetNode()
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
 		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
+			return err
 		}
 
 		// Must be online!
 		if !n.OnlineMode() {
-			res.SetError(ErrNotOnline, cmdkit.ErrClient)
-			return
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
 		}
 
-		addr, peerID, err := ParsePeerParam(req.Arguments()[0])
+		addr, peerID, err := ParsePeerParam(req.Arguments[0])
 		if err != nil {
-			res.SetError(fmt.Errorf("failed to parse peer address '%s': %s", req.Arguments()[0], err), cmdkit.ErrNormal)
-			return
+			return fmt.Errorf("failed to parse peer address '%s': %s", req.Arguments[0], err)
 		}
 
 		if peerID == n.Identity {
-			res.SetError(ErrPingSelf, cmdkit.ErrNormal)
-			return
+			return ErrPingSelf
 		}
 
-		if addr != nil {
-			n.Peerstore.AddAddr(peerID, addr, pstore.TempAddrTTL) // temporary
+		numPings, _ := req.Options[pingCountOptionName].(int)
+		if infinite, _ := req.Options[pingInfiniteOptionName].(bool); infinite {
+			numPings = 0
+		} else if numPings <= 0 {
+			return fmt.Errorf("error: ping count must be greater than 0, was %d", numPings)
 		}
 
-		numPings, _, err := req.Option("count").Int()
+		intervalStr, _ := req.Options[pingIntervalOptionName].(string)
+		interval, err := time.ParseDuration(intervalStr)
 		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
+			return err
+		}
+		if interval < 0 {
+			return errors.New("ping interval must be >= 0")
 		}
 
-		if numPings <= 0 {
-			res.SetError(fmt.Errorf("error: ping count must be greater than 0, was %d", numPings), cmdkit.ErrNormal)
+		if addr != nil {
+			if err := connectOnAddr(req.Context, n, peerID, addr); err != nil {
+				return fmt.Errorf("failed to connect to %s: %s", addr, err)
+			}
 		}
 
-		outChan := pingPeer(ctx, n, peerID, numPings)
-		res.SetOutput(outChan)
+		return pingPeer(req.Context, n, peerID, numPings, interval, res.Emit)
 	},
-	Type: PingResult{},
-}
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			var stats pingStats
+			for {
+				v, err := res.Next()
+				if err == io.EOF || res.Request().Context.Err() != nil {
+					// print the statistics of an interrupted ping too
+					break
+				} else if err != nil {
+					return err
+				}
 
-func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int) <-chan interface{} {
-	outChan := make(chan interface{})
-	go func() {
-		defer close(outChan)
+				obj, ok := v.(*PingResult)
+				if !ok {
+					return e.New(e.TypeErr(obj, v))
+				}
+				stats.add(obj)
+				printPingResult(os.Stdout, obj)
+			}
 
-		if len(n.Peerstore.Addrs(pid)) == 0 {
-			// Make sure we can find the node in question
-			select {
-			case outChan <- &PingResult{
-				Text:    fmt.Sprintf("Looking up peer %s", pid.Pretty()),
-				Success: true,
-			}:
-			case <-ctx.Done():
-				return
+			stats.print(os.Stdout)
+			return nil
+		},
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			obj, ok := v.(*PingResult)
+			if !ok {
+				return e.TypeErr(obj, v)
 			}
 
-			ctx, cancel := context.WithTimeout(ctx, kPingTimeout)
-			defer cancel()
-			p, err := n.Routing.FindPeer(ctx, pid)
-			if err != nil {
-				select {
-				case outChan <- &PingResult{Text: fmt.Sprintf("Peer lookup error: %s", err)}:
-				case <-ctx.Done():
-					return
-				}
+			printPingResult(w, obj)
+			return nil
+		}),
+	},
+	Type: PingResult{},
+}
 
-				return
-			}
-			n.Peerstore.AddAddrs(p.ID, p.Addrs, pstore.TempAddrTTL)
+func printPingResult(w io.Writer, obj *PingResult) {
+	if len(obj.Text) > 0 {
+		fmt.Fprintln(w, obj.Text)
+	} else if obj.Success {
+		fmt.Fprintf(w, "Pong received: time=%.2f ms\n", obj.Time.Seconds()*1000)
+	} else {
+		fmt.Fprintln(w, "Pong failed")
+	}
+}
+
+// connectOnAddr connects to the peer on addr only, so that the pings use
+// its transport. The existing connections over other addresses are closed,
+// as the pings would reuse them.
+func connectOnAddr(ctx context.Context, n *core.IpfsNode, pid peer.ID, addr ma.Multiaddr) error {
+	for _, c := range n.PeerHost.Network().ConnsToPeer(pid) {
+		if c.RemoteMultiaddr().Equal(addr) {
+			return nil
 		}
+	}
+	if err := n.PeerHost.Network().ClosePeer(pid); err != nil {
+		return err
+	}
+	return dialAddr(ctx, n, pid, addr, kPingTimeout)
+}
 
-		select {
-		case outChan <- &PingResult{
-			Text:    fmt.Sprintf("PING %s.", pid.Pretty()),
+// pingPeer sends numPings pings to the peer, or pings it until ctx is done
+// when numPings is 0, and emits their results. The pings lost because of
+// an error or a timeout are retried on a new stream.
+func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int, interval time.Duration, emit func(interface{}) error) error {
+	if len(n.Peerstore.Addrs(pid)) == 0 {
+		// Make sure we can find the node in question
+		err := emit(&PingResult{
+			Text:    fmt.Sprintf("Looking up peer %s", pid.Pretty()),
 			Success: true,
-		}:
-		case <-ctx.Done():
-			return
+		})
+		if err != nil {
+			return err
 		}
 
-		ctx, cancel := context.WithTimeout(ctx, kPingTimeout*time.Duration(numPings))
-		defer cancel()
-		pings, err := n.Ping.Ping(ctx, pid)
+		lctx, cancel := context.WithTimeout(ctx, kPingTimeout)
+		p, err := n.Routing.FindPeer(lctx, pid)
+		cancel()
 		if err != nil {
+			return emit(&PingResult{Text: fmt.Sprintf("Peer lookup error: %s", err)})
+		}
+		n.Peerstore.AddAddrs(p.ID, p.Addrs, pstore.TempAddrTTL)
+	}
+
+	err := emit(&PingResult{
+		Text:    fmt.Sprintf("PING %s.", pid.Pretty()),
+		Success: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	var pings <-chan time.Duration
+	cancel := func() {}
+	defer func() { cancel() }()
+
+	for i := 0; numPings == 0 || i < numPings; i++ {
+		if i > 0 {
 			select {
-			case outChan <- &PingResult{
-				Success: false,
-				Text:    fmt.Sprintf("Ping error: %s", err),
-			}:
+			case <-time.After(interval):
 			case <-ctx.Done():
+				return nil
 			}
-			return
 		}
 
-		var done bool
-		var total time.Duration
-		for i := 0; i < numPings && !done; i++ {
-			select {
-			case <-ctx.Done():
-				done = true
-				break
-			case t, ok := <-pings:
-				if !ok {
-					done = true
-					break
+		if pings == nil {
+			var pctx context.Context
+			pctx, cancel = context.WithCancel(ctx)
+			pings, err = n.Ping.Ping(pctx, pid)
+			if err != nil {
+				cancel()
+				pings = nil
+				if err := emit(&PingResult{Text: fmt.Sprintf("Ping error: %s", err)}); err != nil {
+					return err
 				}
-				select {
-				case outChan <- &PingResult{
-					Success: true,
-					Time:    t,
-				}:
-				case <-ctx.Done():
-					return
+				if err := emit(&PingResult{Success: false}); err != nil {
+					return err
 				}
-				total += t
-				time.Sleep(time.Second)
+				continue
 			}
 		}
-		averagems := total.Seconds() * 1000 / float64(numPings)
+
+		res := &PingResult{Success: false}
 		select {
-		case outChan <- &PingResult{
-			Success: true,
-			Text:    fmt.Sprintf("Average latency: %.2fms", averagems),
-		}:
+		case t, ok := <-pings:
+			if ok {
+				res = &PingResult{Success: true, Time: t}
+			}
+		case <-time.After(kPingTimeout):
 		case <-ctx.Done():
+			return nil
+		}
+		if !res.Success {
+			// the stream is unusable after a failure
+			cancel()
+			pings = nil
 		}
-	}()
-	return outChan
-}
 
-func ParsePeerParam(text string) (ma.Multiaddr, peer.ID, error) {
-	// to be replaced with just multiaddr parsing, once ptp is a multiaddr protocol
-	idx := strings.LastIndex(text, "/")
-	if idx == -1 {
-		pid, err := peer.IDB58Decode(text)
-		if err != nil {
-			return nil, "", err
+		if err := emit(res); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		return nil, pid, nil
+// pingStats are the statistics of the results of a ping.
+type pingStats struct {
+	sent, received int
+	min, max       time.Duration
+	// in ms, for the standard deviation
+	sum, sumSquares float64
+}
+
+func (s *pingStats) add(r *PingResult) {
+	if r.Text != "" {
+		return
+	}
+
+	s.sent++
+	if !r.Success {
+		return
+	}
+
+	s.received++
+	if s.received == 1 || r.Time < s.min {
+		s.min = r.Time
+	}
+	if r.Time > s.max {
+		s.max = r.Time
+	}
+	ms := r.Time.Seconds() * 1000
+	s.sum += ms
+	s.sumSquares += ms * ms
+}
+
+func (s *pingStats) loss() float64 {
+	if s.sent == 0 {
+		return 0
+	}
+	return float64(s.sent-s.received) * 100 / float64(s.sent)
+}
+
+func (s *pingStats) avg() float64 {
+	if s.received == 0 {
+		return 0
 	}
+	return s.sum / float64(s.received)
+}
+
+func (s *pingStats) stddev() float64 {
+	if s.received == 0 {
+		return 0
+	}
+	avg := s.avg()
+	// rounding errors may make the variance slightly negative
+	return math.Sqrt(math.Max(0, s.sumSquares/float64(s.received)-avg*avg))
+}
 
-	addrS := text[:idx]
-	peeridS := text[idx+1:]
+func (s *pingStats) print(w io.Writer) {
+	if s.sent == 0 {
+		return
+	}
 
-	var maddr ma.Multiaddr
-	var pid peer.ID
+	fmt.Fprintf(w, "%d pings sent, %d received, %.0f%% packet loss\n", s.sent, s.received, s.loss())
+	if s.received > 0 {
+		fmt.Fprintf(w, "Latency min/avg/max/stddev: %.2f/%.2f/%.2f/%.2f ms\n",
+			s.min.Seconds()*1000, s.avg(), s.max.Seconds()*1000, s.stddev())
+	}
+}
 
-	// make sure addrS parses as a multiaddr.
-	if len(addrS) > 0 {
-		var err error
-		maddr, err = ma.NewMultiaddr(addrS)
+// ParsePeerParam parses a peer ID, or a multiaddr ending with one.
+func ParsePeerParam(text string) (ma.Multiaddr, peer.ID, error) {
+	if !strings.HasPrefix(text, "/") {
+		pid, err := peer.IDB58Decode(text)
 		if err != nil {
 			return nil, "", err
 		}
+
+		return nil, pid, nil
 	}
 
-	// make sure idS parses as a peer.ID
-	var err error
-	pid, err = peer.IDB58Decode(peeridS)
+	a, err := iaddr.ParseString(text)
 	if err != nil {
 		return nil, "", err
 	}
-
-	return maddr, pid, nil
+	return a.Transport(), a.ID(), nil
 }
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPingStats(t *testing.T) {
+	var stats pingStats
+	for _, r := range []*PingResult{
+		{Text: "PING QmPeer.", Success: true},
+		{Success: true, Time: 10 * time.Millisecond},
+		{Success: false},
+		{Success: true, Time: 30 * time.Millisecond},
+		{Success: true, Time: 20 * time.Millisecond},
+	} {
+		stats.add(r)
+	}
+
+	if stats.sent != 4 || stats.received != 3 {
+		t.Fatalf("expected 4 pings sent and 3 received, got %d and %d", stats.sent, stats.received)
+	}
+	if stats.loss() != 25 {
+		t.Fatalf("expected 25%% packet loss, got %f", stats.loss())
+	}
+	if stats.min != 10*time.Millisecond || stats.max != 30*time.Millisecond {
+		t.Fatalf("unexpected min/max: %s/%s", stats.min, stats.max)
+	}
+	if math.Abs(stats.avg()-20) > 1e-9 {
+		t.Fatalf("expected an average of 20ms, got %f", stats.avg())
+	}
+	if math.Abs(stats.stddev()-math.Sqrt(200.0/3)) > 1e-9 {
+		t.Fatalf("unexpected standard deviation %f", stats.stddev())
+	}
+
+	buf := new(bytes.Buffer)
+	stats.print(buf)
+	expected := "4 pings sent, 3 received, 25% packet loss\n" +
+		"Latency min/avg/max/stddev: 10.00/20.00/30.00/8.16 ms\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestParsePeerParam(t *testing.T) {
+	id := "QmTFauExutTsy4XP6JbMFcw2Wa9645HJt2bTqL6qYDCKfe"
+
+	addr, pid, err := ParsePeerParam(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != nil || pid.Pretty() != id {
+		t.Fatalf("unexpected result %s, %s", addr, pid.Pretty())
+	}
+
+	addr, pid, err = ParsePeerParam("/ip4/127.0.0.1/tcp/4001/ipfs/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr == nil || addr.String() != "/ip4/127.0.0.1/tcp/4001" || pid.Pretty() != id {
+		t.Fatalf("unexpected result %s, %s", addr, pid.Pretty())
+	}
+
+	if _, _, err := ParsePeerParam("/ip4/127.0.0.1/tcp/4001"); err == nil {
+		t.Fatal("expected an address without a peer ID to be rejected")
+	}
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+	swarmkey "github.com/ipfs/go-ipfs/swarmkey"
+
+	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+type PnetKeyOutput struct {
+	Key         string `json:",omitempty"`
+	Path        string `json:",omitempty"`
+	Fingerprint string
+}
+
+var PnetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the private network key.",
+		ShortDescription: `
+Nodes with a private network key (the swarm.key file of the repo) only
+connect to nodes with the same key. See the Private Networks section of
+docs/experimental-features.md.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"generate-key": pnetGenerateKeyCmd,
+		"fingerprint":  pnetFingerprintCmd,
+	},
+}
+
+var pnetGenerateKeyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Generate a new private network key.",
+		ShortDescription: `
+'ipfs pnet generate-key' prints a new random private network key. Save it as
+swarm.key in the repo of every node of the network, or pass --write to save
+it in the repo of this node. An existing key is never overwritten.
+
+The node must be restarted for the key to take effect.
+`,
+	},
+
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("write", "w", "Save the key as the swarm.key of the repo."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		write, _, err := req.Option("write").Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		key, err := swarmkey.Generate()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		fp, err := swarmkey.Fingerprint(key)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		out := &PnetKeyOutput{Fingerprint: fmt.Sprintf("%x", fp)}
+
+		if !write {
+			out.Key = string(key)
+			res.SetOutput(out)
+			return
+		}
+
+		out.Path, err = fsrepo.WriteSwarmKey(req.InvocContext().ConfigRoot, key)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(out)
+	},
+	Type: PnetKeyOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PnetKeyOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			if out.Path == "" {
+				return bytes.NewBufferString(out.Key), nil
+			}
+			return bytes.NewBufferString(fmt.Sprintf("saved private network key to %s\nfingerprint: %s\n", out.Path, out.Fingerprint)), nil
+		},
+	},
+}
+
+var pnetFingerprintCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the fingerprint of the private network key.",
+		ShortDescription: `
+'ipfs pnet fingerprint' prints the fingerprint of the swarm.key of the repo.
+All nodes of a private network have the same fingerprint, which can be
+compared without revealing the key.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		key, err := r.SwarmKey()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if key == nil {
+			res.SetError(errors.New("no private network key, this node is part of the public network"), cmdkit.ErrClient)
+			return
+		}
+
+		fp, err := swarmkey.Fingerprint(key)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(&PnetKeyOutput{Fingerprint: fmt.Sprintf("%x", fp)})
+	},
+	Type: PnetKeyOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PnetKeyOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+			return bytes.NewBufferString(out.Fingerprint + "\n"), nil
+		},
+	},
+}
@@ -0,0 +1,242 @@
+// Package progress renders the progress of the long running commands on the
+// command line.
+//
+// The commands emit Events among their outputs, or count the bytes they read
+// with a Bar directly, and the Bar draws them on one line of the terminal:
+// the bytes and blocks done, out of their totals when known, the rate and
+// the time left.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+)
+
+// refreshInterval is the minimum time between two draws of a Bar.
+const refreshInterval = 100 * time.Millisecond
+
+// clearLine erases the line of the terminal the cursor is on.
+const clearLine = "\033[2K\r"
+
+// Event is the progress of a command, emitted among its outputs. The totals
+// are zero when unknown.
+type Event struct {
+	Bytes       int64 `json:",omitempty"`
+	TotalBytes  int64 `json:",omitempty"`
+	Blocks      int64 `json:",omitempty"`
+	TotalBlocks int64 `json:",omitempty"`
+}
+
+// String formats the counts of ev, without the rate and the time left which
+// need the time the command started.
+func (ev Event) String() string {
+	var parts []string
+	if ev.Bytes > 0 || ev.TotalBytes > 0 {
+		s := humanize.Bytes(uint64(ev.Bytes))
+		if ev.TotalBytes > 0 {
+			s += fmt.Sprintf(" / %s (%d%%)", humanize.Bytes(uint64(ev.TotalBytes)), percent(ev.Bytes, ev.TotalBytes))
+		}
+		parts = append(parts, s)
+	}
+	if ev.Blocks > 0 || ev.TotalBlocks > 0 {
+		s := fmt.Sprintf("%d", ev.Blocks)
+		if ev.TotalBlocks > 0 {
+			s += fmt.Sprintf(" / %d", ev.TotalBlocks)
+		}
+		parts = append(parts, s+" blocks")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func percent(n, total int64) int64 {
+	if n >= total {
+		return 100
+	}
+	return n * 100 / total
+}
+
+// Bar draws the progress of a command. Its methods can be called
+// concurrently.
+type Bar struct {
+	lk     sync.Mutex
+	out    io.Writer
+	now    func() time.Time
+	prefix string
+	ev     Event
+	start  time.Time
+	drawn  time.Time
+}
+
+// New returns a Bar drawing on out, usually os.Stderr.
+func New(out io.Writer) *Bar {
+	return newBar(out, time.Now)
+}
+
+func newBar(out io.Writer, now func() time.Time) *Bar {
+	return &Bar{out: out, now: now, start: now()}
+}
+
+// SetPrefix sets the text drawn before the progress, such as the name of the
+// file being processed.
+func (b *Bar) SetPrefix(prefix string) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.prefix = prefix
+	b.draw(false)
+}
+
+// SetTotal sets the totals of bytes and blocks, zero when unknown.
+func (b *Bar) SetTotal(bytes, blocks int64) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.ev.TotalBytes = bytes
+	b.ev.TotalBlocks = blocks
+	b.draw(false)
+}
+
+// AddBytes adds n bytes to the bytes done.
+func (b *Bar) AddBytes(n int64) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.ev.Bytes += n
+	b.draw(false)
+}
+
+// AddBlocks adds n blocks to the blocks done.
+func (b *Bar) AddBlocks(n int64) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.ev.Blocks += n
+	b.draw(false)
+}
+
+// Update sets the progress to ev. Its zero totals keep the known ones.
+func (b *Bar) Update(ev Event) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	if ev.TotalBytes == 0 {
+		ev.TotalBytes = b.ev.TotalBytes
+	}
+	if ev.TotalBlocks == 0 {
+		ev.TotalBlocks = b.ev.TotalBlocks
+	}
+	b.ev = ev
+	b.draw(false)
+}
+
+// Clear erases the bar, so that an output can be written to the terminal.
+// The bar is drawn again at the next update.
+func (b *Bar) Clear() {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	fmt.Fprint(b.out, clearLine)
+	b.drawn = time.Time{}
+}
+
+// Finish draws the final progress and ends its line.
+func (b *Bar) Finish() {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.draw(true)
+	fmt.Fprintln(b.out)
+}
+
+// NewReader returns a reader of r adding the bytes read to b.
+func (b *Bar) NewReader(r io.Reader) io.Reader {
+	return &reader{r: r, bar: b}
+}
+
+type reader struct {
+	r   io.Reader
+	bar *Bar
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.bar.AddBytes(int64(n))
+	return n, err
+}
+
+func (b *Bar) draw(force bool) {
+	now := b.now()
+	if !force && now.Sub(b.drawn) < refreshInterval {
+		return
+	}
+	b.drawn = now
+	fmt.Fprint(b.out, clearLine+b.line(now))
+}
+
+// line formats the progress at now.
+func (b *Bar) line(now time.Time) string {
+	s := b.prefix + b.ev.String()
+
+	elapsed := now.Sub(b.start).Seconds()
+	if elapsed <= 0 {
+		return s
+	}
+	var done, total float64
+	var rate string
+	switch {
+	case b.ev.Bytes > 0:
+		done, total = float64(b.ev.Bytes), float64(b.ev.TotalBytes)
+		rate = humanize.Bytes(uint64(done/elapsed)) + "/s"
+	case b.ev.Blocks > 0:
+		done, total = float64(b.ev.Blocks), float64(b.ev.TotalBlocks)
+		rate = fmt.Sprintf("%.0f blocks/s", done/elapsed)
+	default:
+		return s
+	}
+	s += ", " + rate
+
+	if total > done {
+		left := time.Duration((total - done) / (done / elapsed) * float64(time.Second))
+		s += fmt.Sprintf(", %s left", left.Round(time.Second))
+	}
+	return s
+}
+
+// PostRun returns a command line PostRun drawing on stderr the progress
+// events that eventOf finds in the outputs of the command, and passing the
+// other outputs to its encoder.
+func PostRun(eventOf func(v interface{}) *Event) func(cmds.Response, cmds.ResponseEmitter) error {
+	return func(res cmds.Response, re cmds.ResponseEmitter) error {
+		var bar *Bar
+		defer func() {
+			if bar != nil {
+				bar.Finish()
+			}
+		}()
+
+		for {
+			v, err := res.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if ev := eventOf(v); ev != nil {
+				if bar == nil {
+					bar = New(os.Stderr)
+				}
+				bar.Update(*ev)
+				continue
+			}
+
+			if bar != nil {
+				bar.Clear()
+			}
+			if err := re.Emit(v); err != nil {
+				return err
+			}
+		}
+	}
+}
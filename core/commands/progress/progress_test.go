@@ -0,0 +1,79 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventString(t *testing.T) {
+	for _, tc := range []struct {
+		ev       Event
+		expected string
+	}{
+		{Event{}, ""},
+		{Event{Bytes: 500000}, "500 kB"},
+		{Event{Bytes: 500000, TotalBytes: 2000000, Blocks: 3}, "500 kB / 2.0 MB (25%), 3 blocks"},
+		{Event{Blocks: 3, TotalBlocks: 12}, "3 / 12 blocks"},
+	} {
+		if s := tc.ev.String(); s != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, s)
+		}
+	}
+}
+
+func TestBar(t *testing.T) {
+	now := time.Unix(1000, 0)
+	clock := func() time.Time { return now }
+
+	buf := new(bytes.Buffer)
+	b := newBar(buf, clock)
+
+	now = now.Add(time.Second)
+	b.Update(Event{Bytes: 500000, TotalBytes: 2000000})
+	expected := "500 kB / 2.0 MB (25%), 500 kB/s, 3s left"
+	if s := b.line(now); s != expected {
+		t.Fatalf("expected %q, got %q", expected, s)
+	}
+	if !strings.HasSuffix(buf.String(), clearLine+expected) {
+		t.Fatalf("the bar wasn't drawn: %q", buf.String())
+	}
+
+	// the draws are throttled
+	buf.Reset()
+	b.AddBytes(1)
+	if buf.Len() != 0 {
+		t.Fatalf("the bar was drawn again: %q", buf.String())
+	}
+
+	buf.Reset()
+	b = newBar(buf, clock)
+	now = now.Add(2 * time.Second)
+	b.SetPrefix("file ")
+	b.Update(Event{Blocks: 10})
+	expected = "file 10 blocks, 5 blocks/s"
+	if s := b.line(now); s != expected {
+		t.Fatalf("expected %q, got %q", expected, s)
+	}
+
+	buf.Reset()
+	b.Finish()
+	if buf.String() != clearLine+expected+"\n" {
+		t.Fatalf("unexpected final line %q", buf.String())
+	}
+}
+
+func TestBarReader(t *testing.T) {
+	b := New(new(bytes.Buffer))
+	r := b.NewReader(strings.NewReader("hello world"))
+	buf := make([]byte, 4)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			break
+		}
+	}
+	if b.ev.Bytes != 11 {
+		t.Fatalf("expected 11 bytes read, got %d", b.ev.Bytes)
+	}
+}
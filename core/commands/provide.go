@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+var ProvideCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and trigger the provider records of the node.",
+		ShortDescription: `
+The node announces the CIDs it stores to the routing system, so that other
+peers can find it as their provider: the new blocks when they are fetched or
+added, and all the CIDs of the Reprovider.Strategy again every
+Reprovider.Interval. 'ipfs provide' shows when each CID was last announced,
+and announces CIDs right away. 'ipfs stats provide' shows the queue and the
+reprovide cycles.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":  provideLsCmd,
+		"now": provideNowCmd,
+	},
+}
+
+// ProvideLsOutput is a CID and the time it was last announced, zero if it
+// never was.
+type ProvideLsOutput struct {
+	Cid          string
+	LastProvided time.Time
+}
+
+var provideLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the CIDs announced and when they last were.",
+		ShortDescription: `
+'ipfs provide ls' lists the CIDs the node announced to the routing system,
+with the time they were last announced. Given CIDs, it prints the time only
+for them, 'never' for the ones never announced:
+
+  ipfs provide ls QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG
+
+A CID announced more than a day ago, about the expiry of the provider
+records of the DHT, may not be found by the other peers anymore.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", false, true, "The CIDs to print."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		// the times are in the datastore, readable offline
+		tr := nd.ProvideTracker
+		if tr == nil {
+			tr = rp.NewTracker(nd.Repo.Datastore(), nil)
+		}
+
+		if len(req.Arguments) > 0 {
+			var cids []cid.Cid
+			for _, arg := range req.Arguments {
+				c, err := cid.Decode(arg)
+				if err != nil {
+					return cmdkit.Errorf(cmdkit.ErrClient, "invalid CID %q: %s", arg, err)
+				}
+				cids = append(cids, c)
+			}
+			for _, c := range cids {
+				last, err := tr.LastProvided(c)
+				if err != nil {
+					return err
+				}
+				if err := res.Emit(&ProvideLsOutput{Cid: c.String(), LastProvided: last}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		recs, err := tr.Provided(req.Context)
+		if err != nil {
+			return err
+		}
+		for r := range recs {
+			if err := res.Emit(&ProvideLsOutput{Cid: r.Cid.String(), LastProvided: r.Time}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: ProvideLsOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*ProvideLsOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			if out.LastProvided.IsZero() {
+				fmt.Fprintf(w, "%s never\n", out.Cid)
+				return nil
+			}
+			fmt.Fprintf(w, "%s %s (%s)\n", out.Cid, out.LastProvided.Format(time.RFC3339), humanize.Time(out.LastProvided))
+			return nil
+		}),
+	},
+}
+
+// ProvideNowOutput is a CID announced by 'ipfs provide now'.
+type ProvideNowOutput struct {
+	Cid string
+	// Took is the time the announce took.
+	Took time.Duration
+}
+
+var provideNowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Announce CIDs to the routing system right away.",
+		ShortDescription: `
+'ipfs provide now' announces the given CIDs, which must be stored locally,
+without waiting for the next reprovide cycle, and prints the time each took.
+The time they were announced is then listed by 'ipfs provide ls'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, true, "The CIDs to announce.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.OnlineMode() {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		var cids []cid.Cid
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid CID %q: %s", arg, err)
+			}
+			has, err := nd.Blockstore.Has(c)
+			if err != nil {
+				return err
+			}
+			if !has {
+				return fmt.Errorf("block %s not found locally, cannot provide", c)
+			}
+			cids = append(cids, c)
+		}
+
+		for _, c := range cids {
+			start := time.Now()
+			if err := nd.Routing.Provide(req.Context, c, true); err != nil {
+				return fmt.Errorf("providing %s: %s", c, err)
+			}
+			if err := res.Emit(&ProvideNowOutput{Cid: c.String(), Took: time.Since(start)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: ProvideNowOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*ProvideNowOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			fmt.Fprintf(w, "provided %s in %s\n", out.Cid, out.Took.Round(time.Millisecond))
+			return nil
+		}),
+	},
+}
+
+// ProvideStatOutput is the state of the provides of the node.
+type ProvideStatOutput struct {
+	Strategy string
+	// Queued is the number of CIDs waiting to be provided on demand.
+	Queued int
+	rp.TrackerStat
+	Reprovider rp.Stat
+}
+
+var statProvideCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the state of the provides.",
+		ShortDescription: `
+'ipfs stats provide' prints the Reprovider.Strategy, the number of CIDs
+queued to be provided on demand, the provides running, the successful and
+failed provides since the daemon started, and the state of the reprovide
+cycles: when the last one started, how long it took, how many CIDs it
+provided and when the next one starts.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.OnlineMode() {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		cfg, err := nd.Repo.Config()
+		if err != nil {
+			return err
+		}
+
+		out := &ProvideStatOutput{Strategy: cfg.Reprovider.Strategy}
+		if out.Strategy == "" {
+			out.Strategy = "all"
+		}
+		if nd.Provider != nil {
+			out.Queued = nd.Provider.Queued()
+		}
+		if nd.ProvideTracker != nil {
+			out.TrackerStat = nd.ProvideTracker.Stat()
+		}
+		if nd.Reprovider != nil {
+			out.Reprovider = nd.Reprovider.Stat()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: ProvideStatOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*ProvideStatOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			defer tw.Flush()
+			fmt.Fprintf(tw, "Strategy:\t%s\n", out.Strategy)
+			fmt.Fprintf(tw, "Queued:\t%d\n", out.Queued)
+			fmt.Fprintf(tw, "InFlight:\t%d\n", out.InFlight)
+			fmt.Fprintf(tw, "Provides:\t%d\n", out.Provides)
+			fmt.Fprintf(tw, "Failures:\t%d\n", out.Failures)
+
+			st := out.Reprovider
+			switch {
+			case st.Running:
+				fmt.Fprintf(tw, "Reprovide:\trunning since %s, %d CIDs provided\n", humanize.Time(st.LastRun), st.Provided)
+			case st.LastRun.IsZero():
+				fmt.Fprintf(tw, "Reprovide:\tnot run yet\n")
+			default:
+				fmt.Fprintf(tw, "Reprovide:\tlast run %s, took %s, %d CIDs provided\n", humanize.Time(st.LastRun), st.LastDuration.Round(time.Second), st.Provided)
+				if st.LastError != "" {
+					fmt.Fprintf(tw, "LastError:\t%s\n", st.LastError)
+				}
+			}
+			if !st.NextRun.IsZero() {
+				fmt.Fprintf(tw, "NextRun:\t%s (%s)\n", st.NextRun.Format(time.RFC3339), humanize.Time(st.NextRun))
+			}
+			return nil
+		}),
+	},
+}
@@ -9,11 +9,13 @@ import (
 	"net/http"
 	"sort"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	corepubsub "github.com/ipfs/go-ipfs/core/corepubsub"
 
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
@@ -63,6 +65,11 @@ to be used in a production environment.
 
 To use, the daemon must be run with '--enable-pubsub-experiment'.
 
+Messages are signed by their sender unless Pubsub.DisableSigning is set. Pass
+--reject-unsigned to only receive messages that carry a valid signature. To
+enforce this for every message the node relays, set
+Pubsub.StrictSignatureVerification instead.
+
 This command outputs data in the following encodings:
   * "json"
 (Specified by the "--encoding" or "--enc" flag)
@@ -73,6 +80,7 @@ This command outputs data in the following encodings:
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("discover", "try to discover other peers subscribed to the same topic"),
+		cmdkit.BoolOption("reject-unsigned", "Drop messages that are not validly signed by their author."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
@@ -114,6 +122,8 @@ This command outputs data in the following encodings:
 			f.Flush()
 		}
 
+		rejectUnsigned, _ := req.Options["reject-unsigned"].(bool)
+
 		for {
 			msg, err := sub.Next(req.Context)
 			if err == io.EOF || err == context.Canceled {
@@ -122,6 +132,11 @@ This command outputs data in the following encodings:
 				return err
 			}
 
+			if rejectUnsigned && !corepubsub.IsSigned(msg) {
+				log.Debugf("pubsub: dropping unsigned or badly signed message on topic %s", topic)
+				continue
+			}
+
 			err = res.Emit(msg)
 			if err != nil {
 				return err
@@ -294,6 +309,10 @@ ipfs pubsub peers with no arguments lists out the pubsub peers you are
 currently connected to. If given a topic, it will list connected
 peers who are subscribed to the named topic.
 
+With --scores, it instead lists the peers that sent messages on scored topics
+together with their current score, broken down per topic. This requires
+Pubsub.PeerScoring.Enabled to be set.
+
 This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
@@ -303,6 +322,9 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("topic", false, false, "topic to list connected peers of"),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("scores", "Show peer scores per topic."),
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
 		if err != nil {
@@ -323,8 +345,16 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 			topic = req.Arguments[0]
 		}
 
+		scores, _ := req.Options["scores"].(bool)
+		if scores {
+			if n.PubsubScorer == nil {
+				return errors.New("pubsub peer scoring not enabled. Set Pubsub.PeerScoring.Enabled to use")
+			}
+			return cmds.EmitOnce(res, pubsubPeerScores(n.PubsubScorer.Scores(), topic))
+		}
+
 		peers := n.Floodsub.ListPeers(topic)
-		list := &stringList{make([]string, 0, len(peers))}
+		list := &pubsubPeersOutput{Strings: make([]string, 0, len(peers))}
 
 		for _, peer := range peers {
 			list.Strings = append(list.Strings, peer.Pretty())
@@ -332,8 +362,84 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 		sort.Strings(list.Strings)
 		return cmds.EmitOnce(res, list)
 	},
-	Type: stringList{},
+	Type: pubsubPeersOutput{},
 	Encoders: cmds.EncoderMap{
-		cmds.Text: cmds.MakeEncoder(stringListEncoder),
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*pubsubPeersOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			if out.Scores == nil {
+				for _, str := range out.Strings {
+					if _, err := fmt.Fprintf(w, "%s\n", str); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			for _, ps := range out.Scores {
+				fmt.Fprintf(tw, "%s\t%.4f\t\n", ps.Peer, ps.Score)
+				for _, ts := range ps.Topics {
+					fmt.Fprintf(tw, "  %s\t%.4f\t\n", ts.Topic, ts.Score)
+				}
+			}
+			return tw.Flush()
+		}),
 	},
 }
+
+// pubsubPeersOutput is the output of 'ipfs pubsub peers'. Strings is kept
+// for compatibility with the stringList output of older versions.
+type pubsubPeersOutput struct {
+	Strings []string
+	Scores  []pubsubPeerScore `json:",omitempty"`
+}
+
+type pubsubPeerScore struct {
+	Peer   string
+	Score  float64
+	Topics []pubsubTopicScore
+}
+
+type pubsubTopicScore struct {
+	Topic string
+	Score float64
+}
+
+// pubsubPeerScores converts the scorer output, keeping only the given topic
+// if it isn't empty, and sorts it from lowest to highest score.
+func pubsubPeerScores(scores []corepubsub.PeerScore, topic string) *pubsubPeersOutput {
+	out := &pubsubPeersOutput{
+		Strings: []string{},
+		Scores:  make([]pubsubPeerScore, 0, len(scores)),
+	}
+
+	for _, s := range scores {
+		ps := pubsubPeerScore{Peer: s.Peer.Pretty()}
+		for t, score := range s.Topics {
+			if topic != "" && t != topic {
+				continue
+			}
+			ps.Topics = append(ps.Topics, pubsubTopicScore{Topic: t, Score: score})
+			ps.Score += score
+		}
+		if len(ps.Topics) == 0 {
+			continue
+		}
+		sort.Slice(ps.Topics, func(i, j int) bool {
+			return ps.Topics[i].Topic < ps.Topics[j].Topic
+		})
+
+		out.Strings = append(out.Strings, ps.Peer)
+		out.Scores = append(out.Scores, ps)
+	}
+
+	sort.Slice(out.Scores, func(i, j int) bool {
+		return out.Scores[i].Score < out.Scores[j].Score
+	})
+	sort.Strings(out.Strings)
+	return out
+}
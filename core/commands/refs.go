@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"hash/fnv"
 	"io"
+	"strconv"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
@@ -50,6 +53,33 @@ with the following format:
 
   <link base58 hash>
 
+NOTE: List all references recursively by using the flag '-r'.
+`,
+		LongDescription: `
+Lists the hashes of all the links an IPFS or IPNS object(s) contains,
+with the following format:
+
+  <link base58 hash>
+
+The output of each link can be changed with --format, which accepts the
+following tokens:
+
+  <src>       the CID of the parent of the link
+  <dst>       the CID the link points to
+  <linkname>  the name of the link
+  <depth>     the depth of the link, 1 for the links of the given objects
+
+For instance, to print the edges of the DAG with their names and depths:
+
+  ipfs refs -r --format="<depth> <src> -> <dst> <linkname>" <path>
+
+With --enc=json, every link is also output with its parent, its target, its
+name and its depth, whatever the format.
+
+--unique omits the refs already printed. The refs seen are remembered as
+16-byte hashes rather than full CIDs, so that huge DAGs can be listed without
+holding all their CIDs in memory.
+
 NOTE: List all references recursively by using the flag '-r'.
 `,
 	},
@@ -60,7 +90,7 @@ NOTE: List all references recursively by using the flag '-r'.
 		cmdkit.StringArg("ipfs-path", true, true, "Path to the object(s) to list refs from.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
-		cmdkit.StringOption("format", "Emit edges with given format. Available tokens: <src> <dst> <linkname>.").WithDefault("<dst>"),
+		cmdkit.StringOption("format", "Emit edges with given format. Available tokens: <src> <dst> <linkname> <depth>.").WithDefault("<dst>"),
 		cmdkit.BoolOption("edges", "e", "Emit edge format: `<from> -> <to>`."),
 		cmdkit.BoolOption("unique", "u", "Omit duplicate refs from output."),
 		cmdkit.BoolOption("recursive", "r", "Recursively list links of child nodes."),
@@ -68,7 +98,7 @@ NOTE: List all references recursively by using the flag '-r'.
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
-		n, err := req.InvocContext().GetNode()
+		n, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -235,6 +265,13 @@ func objectsForPaths(ctx context.Context, n *core.IpfsNode, paths []string) ([]i
 type RefWrapper struct {
 	Ref string
 	Err string
+
+	// the edge of the DAG Ref was formatted from, unset for the refs not
+	// listed from a DAG
+	Src      string `json:",omitempty"`
+	Dst      string `json:",omitempty"`
+	LinkName string `json:",omitempty"`
+	Depth    int    `json:",omitempty"`
 }
 
 type RefWriter struct {
@@ -246,7 +283,7 @@ type RefWriter struct {
 	MaxDepth int
 	PrintFmt string
 
-	seen map[string]int
+	seen *cidSet
 }
 
 // WriteRefs writes refs of the given object to the underlying writer.
@@ -285,7 +322,7 @@ func (rw *RefWriter) writeRefsRecursive(n ipld.Node, depth int) (int, error) {
 
 		// Write this node if not done before (or !Unique)
 		if shouldWrite {
-			if err := rw.WriteEdge(nc, lc, n.Links()[i].Name); err != nil {
+			if err := rw.writeEdge(nc, lc, n.Links()[i].Name, depth+1); err != nil {
 				return count, err
 			}
 			count++
@@ -339,10 +376,9 @@ func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool) {
 	// Unique == true from this point.
 	// Thus, we keep track of seen Cids, and their depth.
 	if rw.seen == nil {
-		rw.seen = make(map[string]int)
+		rw.seen = newCidSet()
 	}
-	key := string(c.Bytes())
-	oldDepth, ok := rw.seen[key]
+	oldDepth, ok := rw.seen.get(c)
 
 	// Unique == true && depth < MaxDepth (or unlimited) from this point
 
@@ -361,12 +397,16 @@ func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool) {
 	// We note down its depth because it was either not seen
 	// or is lower than last time.
 	// We print if it was not seen.
-	rw.seen[key] = depth
+	rw.seen.set(c, depth)
 	return !atMaxDepth, !ok
 }
 
 // Write one edge
 func (rw *RefWriter) WriteEdge(from, to cid.Cid, linkname string) error {
+	return rw.writeEdge(from, to, linkname, 0)
+}
+
+func (rw *RefWriter) writeEdge(from, to cid.Cid, linkname string, depth int) error {
 	if rw.Ctx != nil {
 		select {
 		case <-rw.Ctx.Done(): // just in case.
@@ -382,10 +422,46 @@ func (rw *RefWriter) WriteEdge(from, to cid.Cid, linkname string) error {
 		s = strings.Replace(s, "<src>", from.String(), -1)
 		s = strings.Replace(s, "<dst>", to.String(), -1)
 		s = strings.Replace(s, "<linkname>", linkname, -1)
+		s = strings.Replace(s, "<depth>", strconv.Itoa(depth), -1)
 	default:
 		s += to.String()
 	}
 
-	rw.out <- &RefWrapper{Ref: s}
+	rw.out <- &RefWrapper{
+		Ref:      s,
+		Src:      from.String(),
+		Dst:      to.String(),
+		LinkName: linkname,
+		Depth:    depth,
+	}
 	return nil
 }
+
+// cidSet is a set of CIDs with their depth, storing a 128-bit FNV hash of
+// the CIDs instead of the CIDs themselves. A collision, which would omit a
+// ref from the unique output, is astronomically unlikely for any DAG that
+// fits in a repo.
+type cidSet struct {
+	m map[[16]byte]int32
+}
+
+func newCidSet() *cidSet {
+	return &cidSet{m: make(map[[16]byte]int32)}
+}
+
+func (s *cidSet) key(c cid.Cid) [16]byte {
+	var k [16]byte
+	h := fnv.New128a()
+	h.Write(c.Bytes())
+	h.Sum(k[:0])
+	return k
+}
+
+func (s *cidSet) get(c cid.Cid) (int, bool) {
+	depth, ok := s.m[s.key(c)]
+	return int(depth), ok
+}
+
+func (s *cidSet) set(c cid.Cid, depth int) {
+	s.m[s.key(c)] = int32(depth)
+}
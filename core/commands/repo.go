@@ -9,14 +9,19 @@ import (
 	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
+	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	progress "github.com/ipfs/go-ipfs/core/commands/progress"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
@@ -37,11 +42,16 @@ var RepoCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"stat":    repoStatCmd,
-		"gc":      repoGcCmd,
-		"fsck":    lgc.NewCommand(RepoFsckCmd),
-		"version": lgc.NewCommand(repoVersionCmd),
-		"verify":  lgc.NewCommand(repoVerifyCmd),
+		"stat":              repoStatCmd,
+		"gc":                repoGcCmd,
+		"fsck":              lgc.NewCommand(RepoFsckCmd),
+		"version":           lgc.NewCommand(repoVersionCmd),
+		"verify":            lgc.NewCommand(repoVerifyCmd),
+		"migrate-datastore": repoMigrateDatastoreCmd,
+		"cache":             repoCacheCmd,
+		"backup":            repoBackupCmd,
+		"restore":           repoRestoreCmd,
+		"dedupe-stats":      repoDedupeStatsCmd,
 	},
 }
 
@@ -49,6 +59,20 @@ var RepoCmd = &cmds.Command{
 type GcResult struct {
 	Key   cid.Cid
 	Error string `json:",omitempty"`
+
+	// Size is the size of Key, set with --dry-run.
+	Size uint64 `json:",omitempty"`
+	// Reclaimable is set in the last result of --dry-run.
+	Reclaimable *GcReclaimable `json:",omitempty"`
+	// Progress is the number of blocks removed so far, set with --progress
+	// in the results without a Key.
+	Progress *progress.Event `json:",omitempty"`
+}
+
+// GcReclaimable is the space that a garbage collection would reclaim.
+type GcReclaimable struct {
+	Blocks uint64
+	Bytes  uint64
 }
 
 var repoGcCmd = &cmds.Command{
@@ -58,11 +82,28 @@ var repoGcCmd = &cmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+
+By default the adds are blocked during the whole collection. With
+--incremental, the blocks are removed in batches, each holding the lock
+briefly, and --max-rate limits the number of blocks removed per second. An
+interrupted incremental collection resumes where it stopped.
+
+With --dry-run, nothing is removed: the command only reports the number of
+blocks and bytes that a collection would reclaim, and lists the blocks with
+--list. It doesn't block the adds.
+
+With --progress, the number of blocks removed so far is reported
+periodically, and drawn on stderr on the command line.
 `,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("stream-errors", "Stream errors."),
 		cmdkit.BoolOption("quiet", "q", "Write minimal output."),
+		cmdkit.BoolOption("incremental", "Remove the blocks in batches, without blocking the adds."),
+		cmdkit.IntOption("max-rate", "Maximum number of blocks removed per second, implies --incremental."),
+		cmdkit.BoolOption("dry-run", "Report the space that would be reclaimed, without removing anything."),
+		cmdkit.BoolOption("list", "List the blocks that would be removed, with --dry-run."),
+		cmdkit.BoolOption("progress", "Report the number of blocks removed so far."),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
@@ -72,7 +113,31 @@ order to reclaim hard disk space.
 
 		streamErrors, _ := req.Options["stream-errors"].(bool)
 
-		gcOutChan := corerepo.GarbageCollectAsync(n, req.Context)
+		incremental, _ := req.Options["incremental"].(bool)
+		maxRate, _ := req.Options["max-rate"].(int)
+		if maxRate < 0 {
+			return errors.New("--max-rate must be positive")
+		}
+
+		if dryRun, _ := req.Options["dry-run"].(bool); dryRun {
+			if incremental || maxRate > 0 {
+				return errors.New("--dry-run can't be combined with --incremental or --max-rate")
+			}
+			return repoGcDryRun(req, re, n)
+		}
+
+		var gcOutChan <-chan gc.Result
+		if incremental || maxRate > 0 {
+			gcOutChan = corerepo.GarbageCollectIncremental(n, req.Context, gc.IncrementalOptions{MaxRate: maxRate})
+		} else {
+			gcOutChan = corerepo.GarbageCollectAsync(n, req.Context)
+		}
+
+		var prog *gcProgress
+		if p, _ := req.Options["progress"].(bool); p {
+			prog = &gcProgress{re: re}
+			defer prog.finish()
+		}
 
 		if streamErrors {
 			errs := false
@@ -82,6 +147,7 @@ order to reclaim hard disk space.
 					errs = true
 				} else {
 					re.Emit(&GcResult{Key: res.KeyRemoved})
+					prog.add()
 				}
 			}
 			if errs {
@@ -90,6 +156,7 @@ order to reclaim hard disk space.
 		} else {
 			err := corerepo.CollectResult(req.Context, gcOutChan, func(k cid.Cid) {
 				re.Emit(&GcResult{Key: k})
+				prog.add()
 			})
 			if err != nil {
 				return err
@@ -99,6 +166,14 @@ order to reclaim hard disk space.
 		return nil
 	},
 	Type: GcResult{},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: progress.PostRun(func(v interface{}) *progress.Event {
+			if obj, ok := v.(*GcResult); ok {
+				return obj.Progress
+			}
+			return nil
+		}),
+	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
 			quiet, _ := req.Options["quiet"].(bool)
@@ -108,22 +183,113 @@ order to reclaim hard disk space.
 				return e.TypeErr(obj, v)
 			}
 
+			if obj.Progress != nil {
+				return nil
+			}
+
 			if obj.Error != "" {
 				_, err := fmt.Fprintf(w, "Error: %s\n", obj.Error)
 				return err
 			}
 
-			prefix := "removed "
+			if r := obj.Reclaimable; r != nil {
+				_, err := fmt.Fprintf(w, "would remove %d blocks (%s)\n", r.Blocks, humanize.Bytes(r.Bytes))
+				return err
+			}
+
 			if quiet {
-				prefix = ""
+				_, err := fmt.Fprintf(w, "%s\n", obj.Key)
+				return err
+			}
+
+			if dryRun, _ := req.Options["dry-run"].(bool); dryRun {
+				_, err := fmt.Fprintf(w, "would remove %s (%s)\n", obj.Key, humanize.Bytes(obj.Size))
+				return err
 			}
 
-			_, err := fmt.Fprintf(w, "%s%s\n", prefix, obj.Key)
+			_, err := fmt.Fprintf(w, "removed %s\n", obj.Key)
 			return err
 		}),
 	},
 }
 
+// gcProgressInterval is the minimum time between two progress results of
+// 'ipfs repo gc --progress'.
+const gcProgressInterval = 500 * time.Millisecond
+
+// gcProgress emits the number of blocks removed by 'ipfs repo gc'. Its
+// methods do nothing on a nil gcProgress.
+type gcProgress struct {
+	re      cmds.ResponseEmitter
+	removed int64
+	emitted time.Time
+}
+
+func (p *gcProgress) add() {
+	if p == nil {
+		return
+	}
+	p.removed++
+	if time.Since(p.emitted) >= gcProgressInterval {
+		p.emit()
+	}
+}
+
+func (p *gcProgress) finish() {
+	if p != nil {
+		p.emit()
+	}
+}
+
+func (p *gcProgress) emit() {
+	p.emitted = time.Now()
+	p.re.Emit(&GcResult{Progress: &progress.Event{Blocks: p.removed}})
+}
+
+// repoGcDryRun emits the blocks that 'ipfs repo gc' would remove when
+// --list is set, then the space it would reclaim.
+func repoGcDryRun(req *cmds.Request, re cmds.ResponseEmitter, n *core.IpfsNode) error {
+	streamErrors, _ := req.Options["stream-errors"].(bool)
+	list, _ := req.Options["list"].(bool)
+
+	var (
+		total GcReclaimable
+		errs  []error
+	)
+	for res := range corerepo.GarbageCollectDryRun(n, req.Context) {
+		if res.Error != nil {
+			if streamErrors {
+				re.Emit(&GcResult{Error: res.Error.Error()})
+			}
+			errs = append(errs, res.Error)
+			continue
+		}
+
+		total.Blocks++
+		total.Bytes += uint64(res.Size)
+		if list {
+			if err := re.Emit(&GcResult{Key: res.KeyRemoved, Size: uint64(res.Size)}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := req.Context.Err(); err != nil {
+		return err
+	}
+
+	switch {
+	case len(errs) == 0:
+	case streamErrors:
+		return errors.New("encountered errors during gc run")
+	case len(errs) == 1:
+		return errs[0]
+	default:
+		return corerepo.NewMultiError(errs...)
+	}
+
+	return re.Emit(&GcResult{Reclaimable: &total})
+}
+
 var repoStatCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Get stats for the currently used repo.",
@@ -209,16 +375,39 @@ Version         string The repo version.
 
 var RepoFsckCmd = &oldcmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline: "Remove repo lockfiles.",
+		Tagline: "Remove repo lockfiles, and verify the repo with --deep.",
 		ShortDescription: `
 'ipfs repo fsck' is a plumbing command that will remove repo and level db
 lockfiles, as well as the api file. This command can only run when no ipfs
 daemons are running.
+
+With --deep, it then verifies the content of the repo: every block is
+re-hashed against its key, the pinned blocks and their descendants must be
+present, and so must the blocks of the files API (MFS). The corrupt blocks
+are only reported, unless --repair is set:
+
+  quarantine  Move the corrupt blocks to the 'quarantine' directory of the
+              repo, one file per block named after its cid.
+  delete      Remove the corrupt blocks.
+
+The blocks missing after a repair can be fetched again from the network,
+e.g. by running 'ipfs pin add' on the affected pins.
 `,
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("deep", "Verify the blocks, the pins and the files API."),
+		cmdkit.StringOption("repair", "With --deep, 'quarantine' or 'delete' the corrupt blocks."),
+	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
 		configRoot := req.InvocContext().ConfigRoot
 
+		deep, _, _ := req.Option("deep").Bool()
+		repair, _, _ := req.Option("repair").String()
+		if repair != "" && !deep {
+			res.SetError(errors.New("--repair requires --deep"), cmdkit.ErrClient)
+			return
+		}
+
 		dsPath, err := config.DataStorePath(configRoot)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -249,14 +438,88 @@ daemons are running.
 			return
 		}
 
-		res.SetOutput(&MessageOutput{"Lockfiles have been removed.\n"})
+		if !deep {
+			res.SetOutput(&MessageOutput{"Lockfiles have been removed.\n"})
+			return
+		}
+
+		r, err := fsrepo.Open(configRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		defer r.Close()
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+		defer close(out)
+
+		emit := func(v *FsckOutput) {
+			select {
+			case out <- v:
+			case <-req.Context().Done():
+			}
+		}
+
+		result, err := corerepo.Fsck(req.Context(), r, corerepo.FsckOptions{
+			Repair:        repair,
+			QuarantineDir: filepath.Join(configRoot, "quarantine"),
+			Progress: func(blocks int) {
+				emit(&FsckOutput{Blocks: blocks})
+			},
+		}, func(p *corerepo.FsckProblem) {
+			emit(&FsckOutput{Problem: p})
+		})
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if result.Problems > 0 && repair == "" {
+			res.SetError(fmt.Errorf("fsck found %d problems in %d blocks", result.Problems, result.Blocks), cmdkit.ErrNormal)
+			return
+		}
+		emit(&FsckOutput{Message: fmt.Sprintf("fsck complete, %d blocks verified, %d problems found.", result.Blocks, result.Problems)})
 	},
-	Type: MessageOutput{},
+	Type: FsckOutput{},
 	Marshalers: oldcmds.MarshalerMap{
-		oldcmds.Text: MessageTextMarshaler,
+		oldcmds.Text: func(res oldcmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			buf := new(bytes.Buffer)
+			switch obj := v.(type) {
+			case *MessageOutput:
+				buf.WriteString(obj.Message)
+			case *FsckOutput:
+				switch {
+				case obj.Problem != nil:
+					// clear the progress line
+					fmt.Fprintf(buf, "\r%s\n", obj.Problem)
+				case obj.Message != "":
+					fmt.Fprintf(buf, "\n%s\n", obj.Message)
+				default:
+					fmt.Fprintf(buf, "\r%d blocks verified.", obj.Blocks)
+				}
+			default:
+				return nil, e.TypeErr(obj, v)
+			}
+			return buf, nil
+		},
 	},
 }
 
+// FsckOutput is emitted by 'ipfs repo fsck --deep': the progress of the
+// verification, the problems found, then a final message.
+type FsckOutput struct {
+	Blocks  int                   `json:",omitempty"`
+	Problem *corerepo.FsckProblem `json:",omitempty"`
+	Message string                `json:",omitempty"`
+}
+
 type VerifyProgress struct {
 	Msg      string
 	Progress int
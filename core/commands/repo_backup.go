@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	oldcmds "github.com/ipfs/go-ipfs/commands"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+)
+
+const backupBlocksOptionName = "blocks"
+
+var repoBackupCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write a backup of the node to a file.",
+		ShortDescription: `
+'ipfs repo backup <dest>' writes a zip archive holding the config, the
+keystore, the pinset and the root of the files API. With '--blocks', the
+blocks of the pins and of the files API are added as a CAR file, so the
+content doesn't have to be fetched from the network after a restore.
+
+The backup is taken while the daemon runs: the pinset is read once, and the
+garbage collection waits for the backup to finish.
+
+The archive holds the private keys of the node, keep it safe. They are never
+sent through the API: 'ipfs repo backup' adds them from the local repo, which
+must be the one of the node. It is restored with 'ipfs repo restore'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("dest", true, false, "The path of the backup archive."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(backupBlocksOptionName, "Add the blocks of the pins and of the files API."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		cctx, ok := env.(*oldcmds.Context)
+		if !ok {
+			return fmt.Errorf("expected env to be of type %T, got %T", cctx, env)
+		}
+
+		fname, err := config.Filename(cctx.ConfigRoot)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		var cfg map[string]interface{}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return err
+		}
+		if err := scrubValue(cfg, []string{config.IdentityTag, config.PrivKeyTag}); err != nil {
+			return err
+		}
+		rawConfig, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		withBlocks, _ := req.Options[backupBlocksOptionName].(bool)
+		r, w := io.Pipe()
+		go func() {
+			w.CloseWithError(corerepo.Backup(req.Context, n, w, corerepo.BackupOptions{
+				Config: rawConfig,
+				Blocks: withBlocks,
+			}))
+		}()
+		return res.Emit(r)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+
+			outReader, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(outReader, v))
+			}
+
+			req := res.Request()
+			repoPath, _ := req.Options["config"].(string)
+			if repoPath == "" {
+				if repoPath, err = fsrepo.BestKnownPath(); err != nil {
+					return err
+				}
+			}
+
+			outPath := req.Arguments[0]
+			if err := writeBackup(outPath, outReader, repoPath); err != nil {
+				os.Remove(outPath)
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "Wrote %s\n", outPath)
+			return nil
+		},
+	},
+}
+
+// writeBackup writes the backup archive r to outPath, with the private keys
+// of the repo at repoPath. The archive is kept in a temporary file until the
+// keys are added.
+func writeBackup(outPath string, r io.Reader, repoPath string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(outPath), ".ipfs-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+
+	// the archive holds the private keys
+	f, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := corerepo.AddBackupKeys(f, zr, repoPath); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var repoRestoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create the repo from a backup.",
+		ShortDescription: `
+'ipfs repo restore <archive>' creates the repo from an archive written by
+'ipfs repo backup': the config, the keystore, the pins and the root of the
+files API are restored, with the blocks if the archive holds them. The
+restored node has the identity of the backed up node.
+
+Like 'ipfs init', it can only run when there is no repo yet. The blocks
+missing from the archive are fetched from the network by the daemon.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("archive", true, false, "The path of the backup archive."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cctx, ok := env.(*oldcmds.Context)
+		if !ok {
+			return fmt.Errorf("expected env to be of type %T, got %T", cctx, env)
+		}
+
+		zr, err := zip.OpenReader(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+
+		result, err := corerepo.Restore(req.Context, cctx.ConfigRoot, &zr.Reader)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, result)
+	},
+	Type: corerepo.RestoreResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*corerepo.RestoreResult)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			fmt.Fprintf(w, "Restored peer identity: %s\n", out.PeerID)
+			fmt.Fprintf(w, "keys: %d, pins: %d, blocks: %d\n", out.Keys, out.Pins, out.Blocks)
+			return nil
+		}),
+	},
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	blockcache "github.com/ipfs/go-ipfs/blockcache"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	tiered "github.com/ipfs/go-ipfs/repo/tiered"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+const (
+	repoCacheARCSizeOptionName     = "arc-size"
+	repoCacheBloomSizeOptionName   = "bloom-size"
+	repoCacheBloomHashesOptionName = "bloom-hashes"
+)
+
+// RepoCacheStatOutput is the output of 'ipfs repo cache stat'.
+type RepoCacheStatOutput struct {
+	// Blockstore is nil when the node has no blockstore caches.
+	Blockstore *blockcache.Stats `json:",omitempty"`
+	Caches     []tiered.Stat
+}
+
+var repoCacheCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and tune the caches of the repo.",
+		ShortDescription: `
+The blockstore has a bloom filter and an ARC cache answering whether it holds
+the blocks, sized by the Datastore.BloomFilterSize and Datastore.ARCCacheSize
+config keys, and resized with 'ipfs repo cache resize' while the daemon runs.
+
+A tiered datastore keeps a bounded local cache of a slow backing datastore.
+See docs/datastores.md for its configuration.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"stat":   repoCacheStatCmd,
+		"resize": repoCacheResizeCmd,
+	},
+}
+
+var repoCacheStatCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Get stats for the caches of the repo.",
+		ShortDescription: `
+'ipfs repo cache stat' outputs the sizes of the caches of the blockstore and
+the hits and misses of the reads going through them since the node started.
+
+It then outputs, for every tiered datastore of the repo, the size of its
+cache, the hits and misses of the reads since the datastore was opened, the
+values evicted, and in write-back mode the values not uploaded to the
+backing datastore yet.
+
+The hits and misses of the blockstore caches are exported to Prometheus as
+ipfs_blockstore_cache_reads_total and ipfs_blockstore_cache_misses_total.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		// the datastores are opened with the node
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		out := &RepoCacheStatOutput{Caches: tiered.Instances()}
+		sort.Slice(out.Caches, func(i, j int) bool { return out.Caches[i].Name < out.Caches[j].Name })
+		if n.BlockCache != nil {
+			st := n.BlockCache.Stats()
+			out.Blockstore = &st
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: RepoCacheStatOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*RepoCacheStatOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			wtr := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			defer wtr.Flush()
+
+			if b := out.Blockstore; b != nil {
+				fmt.Fprintf(wtr, "Blockstore:\t\n")
+				fmt.Fprintf(wtr, "ARCCacheSize:\t%d\n", b.ARCCacheSize)
+				fmt.Fprintf(wtr, "BloomFilterSize:\t%s\n", humanize.Bytes(uint64(b.BloomFilterSize)))
+				fmt.Fprintf(wtr, "BloomFilterHashes:\t%d\n", b.BloomFilterHashes)
+				fmt.Fprintf(wtr, "Hits:\t%d\n", b.Hits())
+				fmt.Fprintf(wtr, "Misses:\t%d\n", b.Misses)
+				if b.Reads > 0 {
+					fmt.Fprintf(wtr, "HitRatio:\t%.1f%%\n", 100*float64(b.Hits())/float64(b.Reads))
+				}
+			}
+
+			if len(out.Caches) == 0 {
+				if out.Blockstore != nil {
+					fmt.Fprintln(wtr)
+				}
+				fmt.Fprintln(wtr, "The repo has no tiered datastore.")
+				return nil
+			}
+
+			for i, c := range out.Caches {
+				if i > 0 || out.Blockstore != nil {
+					fmt.Fprintln(wtr)
+				}
+				name := c.Name
+				if name == "" {
+					name = "/"
+				}
+				fmt.Fprintf(wtr, "Datastore:\t%s\n", name)
+				fmt.Fprintf(wtr, "Eviction:\t%s\n", c.Eviction)
+				fmt.Fprintf(wtr, "WriteMode:\t%s\n", c.WriteMode)
+				fmt.Fprintf(wtr, "Size:\t%s / %s\n", humanize.Bytes(uint64(c.Size)), humanize.Bytes(uint64(c.MaxSize)))
+				fmt.Fprintf(wtr, "Entries:\t%d\n", c.Entries)
+				fmt.Fprintf(wtr, "Hits:\t%d\n", c.Hits)
+				fmt.Fprintf(wtr, "Misses:\t%d\n", c.Misses)
+				if reads := c.Hits + c.Misses; reads > 0 {
+					fmt.Fprintf(wtr, "HitRatio:\t%.1f%%\n", 100*float64(c.Hits)/float64(reads))
+				}
+				fmt.Fprintf(wtr, "Evictions:\t%d\n", c.Evictions)
+				if c.WriteMode == tiered.WriteBack {
+					fmt.Fprintf(wtr, "Dirty:\t%d\n", c.Dirty)
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var repoCacheResizeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Resize the caches of the blockstore.",
+		ShortDescription: `
+'ipfs repo cache resize' replaces the bloom filter and the ARC cache of the
+blockstore by ones of the given sizes, the sizes not given being kept. A size
+of zero disables a cache. The new ARC cache starts empty, and the new bloom
+filter is built from the keys of the blockstore in the background.
+
+The sizes are reset to the config when the daemon restarts. To keep them, set
+Datastore.BloomFilterSize, Datastore.ARCCacheSize and
+Datastore.BloomFilterHashes.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption(repoCacheARCSizeOptionName, "Number of blocks whose presence the ARC cache remembers."),
+		cmdkit.IntOption(repoCacheBloomSizeOptionName, "Size of the bloom filter in bytes."),
+		cmdkit.IntOption(repoCacheBloomHashesOptionName, "Number of hash functions of the bloom filter."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.BlockCache == nil {
+			return errors.New("the blockstore of the node has no caches")
+		}
+
+		opts := n.BlockCache.Options()
+		for name, field := range map[string]*int{
+			repoCacheARCSizeOptionName:     &opts.HasARCCacheSize,
+			repoCacheBloomSizeOptionName:   &opts.HasBloomFilterSize,
+			repoCacheBloomHashesOptionName: &opts.HasBloomFilterHashes,
+		} {
+			v, ok := req.Options[name].(int)
+			if !ok {
+				continue
+			}
+			if v < 0 {
+				return cmdkit.Errorf(cmdkit.ErrClient, "--%s must be positive", name)
+			}
+			*field = v
+		}
+		if opts.HasBloomFilterSize > 0 && opts.HasBloomFilterHashes == 0 {
+			opts.HasBloomFilterHashes = bstore.DefaultCacheOpts().HasBloomFilterHashes
+		}
+
+		if err := n.BlockCache.Resize(opts); err != nil {
+			return err
+		}
+		st := n.BlockCache.Stats()
+		return cmds.EmitOnce(res, &RepoCacheStatOutput{Blockstore: &st})
+	},
+	Type: RepoCacheStatOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*RepoCacheStatOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			b := out.Blockstore
+			_, err := fmt.Fprintf(w, "resized the blockstore caches: ARC cache of %d blocks, bloom filter of %s with %d hashes\n",
+				b.ARCCacheSize, humanize.Bytes(uint64(b.BloomFilterSize)), b.BloomFilterHashes)
+			return err
+		}),
+	},
+}
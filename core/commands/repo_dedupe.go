@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	chunker "github.com/ipfs/go-ipfs/chunker"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+const (
+	repoDedupeTopOptionName     = "top"
+	repoDedupeRechunkOptionName = "rechunk"
+)
+
+var repoDedupeStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Analyze the deduplication of the blocks of the repo.",
+		ShortDescription: `
+'ipfs repo dedupe-stats' analyzes the blocks of the given DAGs, or of the whole
+blockstore, and outputs how much space their deduplication saves: the unique
+size of the blocks, stored once, against the size they'd take if every
+reference to them had its own copy, and the blocks whose sharing saves the
+most. The blocks missing from the repo aren't fetched.
+
+With --rechunk, the content of the unixfs files is read again and chunked with
+each of the given chunkers, as accepted by 'ipfs add --chunker', to estimate
+the space their distinct chunks would take compared to the current leaves:
+
+  ipfs repo dedupe-stats --rechunk=rabin,rabin-65536-131072-262144 /ipfs/<cid>
+
+This reads every file once per chunker, and every block analyzed is kept in
+memory: analyzing a large repo takes a while.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", false, true, "Paths of the DAGs to analyze, the whole blockstore when none is given."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption(repoDedupeTopOptionName, "Number of the most shared blocks to output.").WithDefault(corerepo.DefaultDedupeTop),
+		cmdkit.StringOption(repoDedupeRechunkOptionName, "Comma separated chunkers to estimate the deduplication of the files with."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetRequestNode(env, req.Options)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetRequestApi(env, req.Options)
+		if err != nil {
+			return err
+		}
+
+		top, _ := req.Options[repoDedupeTopOptionName].(int)
+		if top < 1 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the %s option must be positive", repoDedupeTopOptionName)
+		}
+		opts := corerepo.DedupeOptions{Top: top}
+		if rechunk, _ := req.Options[repoDedupeRechunkOptionName].(string); rechunk != "" {
+			for _, c := range strings.Split(rechunk, ",") {
+				c = strings.TrimSpace(c)
+				if err := chunker.Validate(c); err != nil {
+					return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+				}
+				opts.Chunkers = append(opts.Chunkers, c)
+			}
+		}
+
+		for _, arg := range req.Arguments {
+			p, err := coreiface.ParsePath(arg)
+			if err != nil {
+				return err
+			}
+			rp, err := api.ResolvePath(req.Context, p)
+			if err != nil {
+				return err
+			}
+			opts.Roots = append(opts.Roots, rp.Cid())
+		}
+
+		st, err := corerepo.DedupeStats(req.Context, n.Blockstore, opts)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, st)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, st *corerepo.DedupeStat) error {
+			fmt.Fprintf(w, "Blocks: %d\n", st.Blocks)
+			fmt.Fprintf(w, "References: %d\n", st.References)
+			fmt.Fprintf(w, "UniqueSize: %s\n", humanize.Bytes(st.UniqueSize))
+			fmt.Fprintf(w, "ReferencedSize: %s\n", humanize.Bytes(st.ReferencedSize))
+			fmt.Fprintf(w, "Savings: %s (%s)\n", humanize.Bytes(st.Savings()), dedupePercent(st.Savings(), st.ReferencedSize))
+			if st.Missing > 0 {
+				fmt.Fprintf(w, "MissingBlocks: %d\n", st.Missing)
+			}
+			fmt.Fprintf(w, "Files: %d, %s, %s of distinct leaf data\n", st.Files, humanize.Bytes(st.FileSize), humanize.Bytes(st.FileUniqueSize))
+
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			if len(st.TopShared) > 0 {
+				fmt.Fprintln(tw, "\nMost shared blocks:")
+				fmt.Fprintln(tw, "Key\tSize\tReferences")
+				for _, b := range st.TopShared {
+					fmt.Fprintf(tw, "%s\t%s\t%d\n", b.Key, humanize.Bytes(b.Size), b.References)
+				}
+			}
+			if len(st.Rechunked) > 0 {
+				fmt.Fprintln(tw, "\nRe-chunked files:")
+				fmt.Fprintln(tw, "Chunker\tChunks\tUniqueSize\tSavings")
+				for _, r := range st.Rechunked {
+					fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", r.Chunker, r.Chunks, humanize.Bytes(r.UniqueSize), dedupeSignedBytes(r.Savings))
+				}
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: corerepo.DedupeStat{},
+}
+
+func dedupePercent(part, total uint64) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(total))
+}
+
+func dedupeSignedBytes(n int64) string {
+	if n < 0 {
+		return "-" + humanize.Bytes(uint64(-n))
+	}
+	return humanize.Bytes(uint64(n))
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	oldcmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+)
+
+// MigrateDatastoreOutput is emitted by 'ipfs repo migrate-datastore', with
+// the progress of the copy, then once with Done set.
+type MigrateDatastoreOutput struct {
+	fsrepo.MigrateProgress
+	Done   bool
+	Backup string `json:",omitempty"`
+}
+
+var repoMigrateDatastoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Convert the datastore of the repo to another datastore type.",
+		ShortDescription: `
+'ipfs repo migrate-datastore <profile>' copies the content of the datastore to
+the datastore described by the Datastore.Spec of a config profile, such as
+'badgerds' or 'default-datastore', and updates the config to use it. This
+command can only run when no ipfs daemon is running.
+
+By default the datastore is converted in place: the new datastore is built in
+the 'datastore-migration' directory of the repo, then replaces the old one,
+which is kept in the 'datastore-backup' directory until removed by hand.
+With '--to=<dir>', the new datastore is created in <dir> instead and the old
+one is left untouched.
+
+Both datastores are on disk during the migration, make sure there is enough
+free space. An interrupted migration is resumed by running the same command
+again, the entries already copied are skipped.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("profile", true, false, "The config profile describing the new datastore."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("to", "The directory to create the new datastore in, instead of the repo."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cctx, ok := env.(*oldcmds.Context)
+		if !ok {
+			return fmt.Errorf("expected env to be of type %T, got %T", cctx, env)
+		}
+
+		profile, ok := config.Profiles[req.Arguments[0]]
+		if !ok {
+			return fmt.Errorf("%s is not a profile", req.Arguments[0])
+		}
+		cfg, err := fsrepo.ConfigAt(cctx.ConfigRoot)
+		if err != nil {
+			return err
+		}
+		if err := profile.Transform(cfg); err != nil {
+			return err
+		}
+
+		to, _ := req.Options["to"].(string)
+		result, err := fsrepo.MigrateDatastore(req.Context, cctx.ConfigRoot, cfg.Datastore.Spec, fsrepo.MigrateOptions{
+			To: to,
+			Progress: func(p fsrepo.MigrateProgress) {
+				res.Emit(&MigrateDatastoreOutput{MigrateProgress: p})
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		return res.Emit(&MigrateDatastoreOutput{
+			MigrateProgress: result.MigrateProgress,
+			Done:            true,
+			Backup:          result.Backup,
+		})
+	},
+	Type: MigrateDatastoreOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*MigrateDatastoreOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			fmt.Fprintf(w, "\rcopied %d entries (%s), skipped %d", out.Copied, humanize.Bytes(out.Bytes), out.Skipped)
+			if !out.Done {
+				return nil
+			}
+
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "Datastore migrated.")
+			if out.Backup != "" {
+				fmt.Fprintf(w, "The old datastore was kept in %s, remove it once the node works.\n", out.Backup)
+			}
+			return nil
+		}),
+	},
+}
@@ -1,10 +1,12 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
@@ -57,6 +59,17 @@ Resolve the value of an IPFS DAG path:
   $ ipfs resolve /ipfs/QmeZy1fGbwgVSrqbfh9fKQrAWgeyRnj7h8fsHS1oy3k99x/beep/boop
   /ipfs/QmYRMjyvAiHKN9UTi8Bzt1HUspmSRD8T8DwxfSMzLgBon1
 
+Trace the resolution of a chained name, each step with its duration:
+
+  $ ipfs resolve --trace /ipns/docs.ipfs.io/guides
+  dnslink   /ipns/docs.ipfs.io/guides -> /ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n/guides (21ms)
+  ipns      /ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n/guides -> /ipfs/QmeZy1fGbwgVSrqbfh9fKQrAWgeyRnj7h8fsHS1oy3k99x/guides (1.2s)
+  path      /ipfs/QmeZy1fGbwgVSrqbfh9fKQrAWgeyRnj7h8fsHS1oy3k99x/guides -> /ipfs/QmYRMjyvAiHKN9UTi8Bzt1HUspmSRD8T8DwxfSMzLgBon1 (3ms)
+  /ipfs/QmYRMjyvAiHKN9UTi8Bzt1HUspmSRD8T8DwxfSMzLgBon1
+
+The trace of a failed resolution ends with the failing step. With
+--enc=json, it is output as a ResolveTrace object.
+
 `,
 	},
 
@@ -67,14 +80,15 @@ Resolve the value of an IPFS DAG path:
 		cmdkit.BoolOption("recursive", "r", "Resolve until the result is an IPFS name."),
 		cmdkit.IntOption("dht-record-count", "dhtrc", "Number of records to request for DHT resolution."),
 		cmdkit.StringOption("dht-timeout", "dhtt", "Max time to collect values during DHT resolution eg \"30s\". Pass 0 for no timeout."),
+		cmdkit.BoolOption("trace", "Output every step of the resolution until the final CID, with its duration."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		api, err := cmdenv.GetApi(env)
+		api, err := cmdenv.GetRequestApi(env, req.Options)
 		if err != nil {
 			return err
 		}
 
-		n, err := cmdenv.GetNode(env)
+		n, err := cmdenv.GetRequestNode(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -88,28 +102,33 @@ Resolve the value of an IPFS DAG path:
 
 		name := req.Arguments[0]
 		recursive, _ := req.Options["recursive"].(bool)
+		trace, _ := req.Options["trace"].(bool)
 
-		// the case when ipns is resolved step by step
-		if strings.HasPrefix(name, "/ipns/") && !recursive {
-			rc, rcok := req.Options["dht-record-count"].(uint)
-			dhtt, dhttok := req.Options["dht-timeout"].(string)
-			ropts := []options.NameResolveOption{
-				options.Name.ResolveOption(nsopts.Depth(1)),
-			}
-
-			if rcok {
-				ropts = append(ropts, options.Name.ResolveOption(nsopts.DhtRecordCount(rc)))
+		ropts := []options.NameResolveOption{
+			options.Name.ResolveOption(nsopts.Depth(1)),
+		}
+		rc, rcok := req.Options["dht-record-count"].(uint)
+		dhtt, dhttok := req.Options["dht-timeout"].(string)
+		if rcok {
+			ropts = append(ropts, options.Name.ResolveOption(nsopts.DhtRecordCount(rc)))
+		}
+		if dhttok {
+			d, err := time.ParseDuration(dhtt)
+			if err != nil {
+				return err
 			}
-			if dhttok {
-				d, err := time.ParseDuration(dhtt)
-				if err != nil {
-					return err
-				}
-				if d < 0 {
-					return errors.New("DHT timeout value must be >= 0")
-				}
-				ropts = append(ropts, options.Name.ResolveOption(nsopts.DhtTimeout(d)))
+			if d < 0 {
+				return errors.New("DHT timeout value must be >= 0")
 			}
+			ropts = append(ropts, options.Name.ResolveOption(nsopts.DhtTimeout(d)))
+		}
+
+		if trace {
+			return cmds.EmitOnce(res, traceResolve(req.Context, api, name, ropts))
+		}
+
+		// the case when ipns is resolved step by step
+		if strings.HasPrefix(name, "/ipns/") && !recursive {
 			p, err := api.Name().Resolve(req.Context, name, ropts...)
 			// ErrResolveRecursion is fine
 			if err != nil && err != ns.ErrResolveRecursion {
@@ -135,6 +154,10 @@ Resolve the value of an IPFS DAG path:
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			if trace, ok := v.(*ResolveTrace); ok {
+				return writeResolveTrace(w, trace)
+			}
+
 			output, ok := v.(*ncmd.ResolvedPath)
 			if !ok {
 				return e.TypeErr(output, v)
@@ -146,3 +169,88 @@ Resolve the value of an IPFS DAG path:
 	},
 	Type: ncmd.ResolvedPath{},
 }
+
+// resolveStepPath is the kind of the last step of a traced resolution, from
+// an /ipfs/ path to its CID.
+const resolveStepPath = "path"
+
+// ResolveStep is a step of the resolution traced by 'ipfs resolve --trace'.
+type ResolveStep struct {
+	// Kind is the kind of name resolved: dnslink, ipns, proquint, or path
+	// for the resolution of an /ipfs/ path to its CID.
+	Kind     string
+	Name     string
+	Value    string        `json:",omitempty"`
+	Duration time.Duration // nanoseconds
+	Error    string        `json:",omitempty"`
+}
+
+// ResolveTrace is the output of 'ipfs resolve --trace'. Path is empty and
+// the last step has an Error when the resolution failed.
+type ResolveTrace struct {
+	Path  string `json:",omitempty"`
+	Steps []ResolveStep
+}
+
+// traceResolve resolves name one step at a time, recording them.
+func traceResolve(ctx context.Context, api coreiface.CoreAPI, name string, ropts []options.NameResolveOption) *ResolveTrace {
+	out := &ResolveTrace{Steps: []ResolveStep{}}
+	cur := name
+	for strings.HasPrefix(cur, "/ipns/") {
+		if len(out.Steps) >= nsopts.DefaultDepthLimit {
+			out.Steps[len(out.Steps)-1].Error = ns.ErrResolveRecursion.Error()
+			return out
+		}
+
+		key := strings.SplitN(cur, "/", 4)[2]
+		step := ResolveStep{Kind: ns.NameKind(key), Name: cur}
+		start := time.Now()
+		p, err := api.Name().Resolve(ctx, cur, ropts...)
+		step.Duration = time.Since(start)
+		if err != nil && err != ns.ErrResolveRecursion {
+			step.Error = err.Error()
+			out.Steps = append(out.Steps, step)
+			return out
+		}
+		step.Value = p.String()
+		out.Steps = append(out.Steps, step)
+		cur = p.String()
+	}
+
+	step := ResolveStep{Kind: resolveStepPath, Name: cur}
+	start := time.Now()
+	p, err := coreiface.ParsePath(cur)
+	if err == nil {
+		var rp coreiface.ResolvedPath
+		if rp, err = api.ResolvePath(ctx, p); err == nil {
+			step.Value = path.FromCid(rp.Cid()).String()
+		}
+	}
+	step.Duration = time.Since(start)
+	if err != nil {
+		step.Error = err.Error()
+	} else {
+		out.Path = step.Value
+	}
+	out.Steps = append(out.Steps, step)
+	return out
+}
+
+func writeResolveTrace(w io.Writer, trace *ResolveTrace) error {
+	tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+	for _, s := range trace.Steps {
+		d := s.Duration.Round(time.Millisecond)
+		if s.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s -> error: %s (%s)\n", s.Kind, s.Name, s.Error, d)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s -> %s (%s)\n", s.Kind, s.Name, s.Value, d)
+	}
+	tw.Flush()
+
+	if trace.Path == "" {
+		return errors.New("the resolution failed")
+	}
+	fmt.Fprintln(w, trace.Path)
+	return nil
+}
@@ -2,11 +2,13 @@ package commands
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	dag "github.com/ipfs/go-ipfs/core/commands/dag"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	name "github.com/ipfs/go-ipfs/core/commands/name"
@@ -29,7 +31,7 @@ const (
 var Root = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:  "Global p2p merkle-dag filesystem.",
-		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--api=<api>] <command> ...",
+		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--offline] [--api=<api>] <command> ...",
 		Subcommands: `
 BASIC COMMANDS
   init          Initialize ipfs local configuration
@@ -44,6 +46,7 @@ DATA STRUCTURE COMMANDS
   object        Interact with raw dag nodes
   files         Interact with objects as if they were a unix filesystem
   dag           Interact with IPLD documents (experimental)
+  archive       Import and export tar and zip archives
 
 ADVANCED COMMANDS
   daemon        Start a long-running daemon process
@@ -63,6 +66,8 @@ NETWORK COMMANDS
   bootstrap     Add or remove bootstrap peers
   swarm         Manage connections to the p2p network
   dht           Query the DHT for values or peers
+  routing       Query the routing system for values, providers or peers
+  provide       Inspect and trigger the provider records of the node
   ping          Measure the latency of a connection
   diag          Print diagnostics
 
@@ -70,6 +75,8 @@ TOOL COMMANDS
   config        Manage configuration
   version       Show ipfs version information
   update        Download and apply go-ipfs updates
+  webui         Manage the WebUI served by the API
+  cid           Convert and inspect CIDs
   commands      List all available commands
 
 Use 'ipfs <command> --help' to learn more about each command.
@@ -94,6 +101,7 @@ The CLI will exit with one of the following values:
 		cmdkit.BoolOption("help", "Show the full command help text."),
 		cmdkit.BoolOption("h", "Show a short version of the command help text."),
 		cmdkit.BoolOption("local", "L", "Run the command locally, instead of using the daemon."),
+		cmdkit.BoolOption(cmdenv.OfflineOption, "Run the command offline, only reading the blocks of the local repo. The commands using the network fail."),
 		cmdkit.StringOption(ApiOption, "Use a specific API instance (defaults to /ip4/127.0.0.1/tcp/5001)"),
 
 		// global options, added to every command
@@ -108,9 +116,11 @@ var CommandsDaemonCmd = CommandsCmd(Root)
 
 var rootSubcommands = map[string]*cmds.Command{
 	"add":       AddCmd,
+	"archive":   ArchiveCmd,
 	"bitswap":   BitswapCmd,
 	"block":     BlockCmd,
 	"cat":       CatCmd,
+	"cid":       CidCmd,
 	"commands":  CommandsDaemonCmd,
 	"files":     FilesCmd,
 	"filestore": FileStoreCmd,
@@ -122,26 +132,30 @@ var rootSubcommands = map[string]*cmds.Command{
 	"config":    lgc.NewCommand(ConfigCmd),
 	"dag":       lgc.NewCommand(dag.DagCmd),
 	"dht":       lgc.NewCommand(DhtCmd),
-	"diag":      lgc.NewCommand(DiagCmd),
+	"diag":      DiagCmd,
 	"dns":       lgc.NewCommand(DNSCmd),
 	"id":        lgc.NewCommand(IDCmd),
 	"key":       KeyCmd,
 	"log":       lgc.NewCommand(LogCmd),
-	"ls":        lgc.NewCommand(LsCmd),
+	"ls":        LsCmd,
 	"mount":     lgc.NewCommand(MountCmd),
 	"name":      name.NameCmd,
 	"object":    ocmd.ObjectCmd,
 	"pin":       lgc.NewCommand(PinCmd),
-	"ping":      lgc.NewCommand(PingCmd),
+	"ping":      PingCmd,
+	"provide":   ProvideCmd,
+	"pnet":      lgc.NewCommand(PnetCmd),
 	"p2p":       lgc.NewCommand(P2PCmd),
 	"refs":      lgc.NewCommand(RefsCmd),
 	"resolve":   ResolveCmd,
+	"routing":   lgc.NewCommand(RoutingCmd),
 	"swarm":     lgc.NewCommand(SwarmCmd),
 	"tar":       lgc.NewCommand(TarCmd),
 	"file":      lgc.NewCommand(unixfs.UnixFSCmd),
 	"update":    lgc.NewCommand(ExternalBinary()),
 	"urlstore":  urlStoreCmd,
 	"version":   lgc.NewCommand(VersionCmd),
+	"webui":     WebUICmd,
 	"shutdown":  daemonShutdownCmd,
 }
 
@@ -163,7 +177,7 @@ var rootROSubcommands = map[string]*cmds.Command{
 	},
 	"get": GetCmd,
 	"dns": lgc.NewCommand(DNSCmd),
-	"ls":  lgc.NewCommand(LsCmd),
+	"ls":  LsCmd,
 	"name": &cmds.Command{
 		Subcommands: map[string]*cmds.Command{
 			"resolve": name.IpnsCmd,
@@ -203,6 +217,99 @@ func init() {
 	Root.Subcommands = rootSubcommands
 
 	RootRO.Subcommands = rootROSubcommands
+
+	seen := make(map[*cmds.Command]bool)
+	rejectOffline(Root, nil, seen)
+	rejectOffline(RootRO, nil, seen)
+}
+
+// offlineCommands are the commands supporting --offline, with their
+// subcommands: they either don't use the network, or get their node with
+// cmdenv.GetRequestNode or cmdenv.GetRequestApi. The files commands are not
+// among them, the MFS root always uses the network.
+var offlineCommands = []string{
+	"add",
+	"archive",
+	"block",
+	"bootstrap add",
+	"bootstrap list",
+	"bootstrap rm",
+	"cat",
+	"cid",
+	"commands",
+	"config",
+	"dag",
+	"diag cmds",
+	"diag profile",
+	"diag sys",
+	"file",
+	"filestore",
+	"get",
+	"id",
+	"key",
+	"log",
+	"ls",
+	"name resolve",
+	"object",
+	"pin",
+	"pnet",
+	"refs",
+	"repo",
+	"resolve",
+	"shutdown",
+	"stats",
+	"tar",
+	"urlstore",
+	"version",
+	"webui",
+}
+
+// supportsOffline returns whether the command at path supports --offline.
+func supportsOffline(path []string) bool {
+	p := strings.Join(path, " ")
+	for _, c := range offlineCommands {
+		if p == c || strings.HasPrefix(p, c+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectOffline makes the commands under cmd which don't support --offline
+// fail when it is set, rather than use the network anyway.
+func rejectOffline(cmd *cmds.Command, path []string, seen map[*cmds.Command]bool) {
+	if seen[cmd] || supportsOffline(path) {
+		return
+	}
+	seen[cmd] = true
+
+	if run := cmd.Run; run != nil {
+		name := strings.Join(append([]string{"ipfs"}, path...), " ")
+		cmd.Run = func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			if offline, _ := req.Options[cmdenv.OfflineOption].(bool); offline {
+				return cmdkit.Errorf(cmdkit.ErrClient, "'%s' does not support --%s", name, cmdenv.OfflineOption)
+			}
+			return run(req, res, env)
+		}
+	}
+
+	for n, sub := range cmd.Subcommands {
+		rejectOffline(sub, append(path[:len(path):len(path)], n), seen)
+	}
+}
+
+// AddCommand adds a top-level command, such as the ones of plugins. It
+// fails if a command has the same name. The command fails when --offline is
+// set.
+func AddCommand(name string, cmd *cmds.Command) error {
+	if _, ok := Root.Subcommands[name]; ok {
+		return fmt.Errorf("command %q already exists", name)
+	}
+
+	cmd.ProcessHelp()
+	rejectOffline(cmd, []string{name}, make(map[*cmds.Command]bool))
+	Root.Subcommands[name] = cmd
+	return nil
 }
 
 type MessageOutput struct {
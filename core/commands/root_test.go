@@ -2,6 +2,11 @@ package commands
 
 import (
 	"testing"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 )
 
 func TestCommandTree(t *testing.T) {
@@ -20,3 +25,44 @@ func TestCommandTree(t *testing.T) {
 	printErrors(Root.DebugValidate())
 	printErrors(RootRO.DebugValidate())
 }
+
+func TestAddCommand(t *testing.T) {
+	cmd := &cmds.Command{
+		Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+			return nil
+		},
+	}
+	if err := AddCommand("plugin-test", cmd); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(Root.Subcommands, "plugin-test")
+
+	if Root.Subcommands["plugin-test"] != cmd {
+		t.Fatal("expected the command to be added to the root")
+	}
+	if err := AddCommand("add", cmd); err == nil {
+		t.Fatal("expected adding a built-in command to fail")
+	}
+}
+
+func TestRejectOffline(t *testing.T) {
+	offline := &cmds.Request{Options: cmdkit.OptMap{cmdenv.OfflineOption: true}}
+
+	if err := Root.Subcommands["swarm"].Subcommands["peers"].Run(offline, nil, nil); err == nil {
+		t.Fatal("expected swarm peers to reject --offline")
+	}
+	if err := RootRO.Subcommands["dns"].Run(offline, nil, nil); err == nil {
+		t.Fatal("expected the readonly dns to reject --offline")
+	}
+
+	for _, p := range [][]string{{"cat"}, {"name", "resolve"}, {"pin", "add"}} {
+		if !supportsOffline(p) {
+			t.Errorf("expected %v to support --offline", p)
+		}
+	}
+	for _, p := range [][]string{{"files", "ls"}, {"name", "publish"}, {"bootstrap", "check"}, {"catalog"}} {
+		if supportsOffline(p) {
+			t.Errorf("expected %v not to support --offline", p)
+		}
+	}
+}
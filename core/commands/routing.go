@@ -0,0 +1,25 @@
+package commands
+
+import (
+	cmds "github.com/ipfs/go-ipfs/commands"
+
+	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+var RoutingCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Issue routing commands.",
+		ShortDescription: `
+'ipfs routing' queries the routing system of the node, whatever it is, for
+values, providers and peers. The DHT specific commands are under 'ipfs dht'.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"findprovs": findProvidersDhtCmd,
+		"findpeer":  findPeerDhtCmd,
+		"get":       getValueDhtCmd,
+		"put":       putValueDhtCmd,
+		"provide":   provideRefDhtCmd,
+	},
+}
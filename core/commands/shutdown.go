@@ -1,15 +1,33 @@
 package commands
 
 import (
+	"fmt"
+	"io"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
 
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 )
 
+const drainOptionName = "drain"
+
 var daemonShutdownCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Shut down the ipfs daemon",
+		ShortDescription: `
+Shut down the ipfs daemon. With --drain, the daemon first stops accepting
+new API and gateway requests, waits for the ones in flight, such as adds and
+pins, flushes the files root, and closes the connections to its peers before
+exiting. --timeout bounds the wait for the requests in flight, 1m by default.
+The daemon drains the same way when it receives SIGTERM.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(drainOptionName, "Wait for the requests in flight before shutting down."),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
@@ -21,10 +39,42 @@ var daemonShutdownCmd = &cmds.Command{
 			return cmdkit.Errorf(cmdkit.ErrClient, "daemon not running")
 		}
 
-		if err := nd.Process().Close(); err != nil {
-			log.Error("error while shutting down ipfs daemon:", err)
+		drain, _ := req.Options[drainOptionName].(bool)
+		if !drain {
+			if err := nd.Process().Close(); err != nil {
+				log.Error("error while shutting down ipfs daemon:", err)
+			}
+			return nil
+		}
+
+		timeout := core.DefaultDrainTimeout
+		if s, ok := req.Options[cmds.TimeoutOpt].(string); ok {
+			timeout, err = time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+		}
+		if nd.Draining() {
+			return fmt.Errorf("the daemon is already shutting down")
 		}
 
-		return nil
+		// this request is in flight too, drain once it returned
+		go func() {
+			if err := nd.Drain(timeout); err != nil {
+				log.Error("error while draining ipfs daemon:", err)
+			}
+		}()
+		return cmds.EmitOnce(re, &MessageOutput{fmt.Sprintf("Draining, shutting down within %s\n", timeout)})
+	},
+	Type: MessageOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*MessageOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			_, err := io.WriteString(w, out.Message)
+			return err
+		}),
 	},
 }
@@ -4,9 +4,19 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	bandwidth "github.com/ipfs/go-ipfs/bandwidth"
+	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	diskio "github.com/ipfs/go-ipfs/diskio"
+	dual "github.com/ipfs/go-ipfs/dual"
+	holepunch "github.com/ipfs/go-ipfs/holepunch"
+	relay "github.com/ipfs/go-ipfs/relay"
 
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
@@ -27,9 +37,14 @@ for your IPFS node.`,
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"bw":      statBwCmd,
-		"repo":    repoStatCmd,
-		"bitswap": bitswapStatCmd,
+		"bw":        statBwCmd,
+		"diskio":    statDiskIOCmd,
+		"repo":      repoStatCmd,
+		"bitswap":   bitswapStatCmd,
+		"holepunch": statHolePunchCmd,
+		"relay":     statRelayCmd,
+		"dht":       statDhtCmd,
+		"provide":   statProvideCmd,
 	},
 }
 
@@ -44,15 +59,21 @@ It displays: TotalIn, TotalOut, RateIn, RateOut.
 
 By default, overall bandwidth and all protocols are shown. To limit bandwidth
 to a particular peer, use the 'peer' option along with that peer's multihash
-id. To specify a specific protocol, use the 'proto' option. The 'peer' and
-'proto' options cannot be specified simultaneously. The protocols that are
-queried using this method are outlined in the specification:
+id. To specify a specific protocol, use the 'proto' option. The protocol may
+be a pattern where '*' matches any sequence of characters, the bandwidth of
+all the matching protocols is then summed. The 'peer' and 'proto' options
+cannot be specified simultaneously. The protocols that are queried using this
+method are outlined in the specification:
 https://github.com/libp2p/specs/blob/master/7-properties.md#757-protocol-multicodecs
 
 Example protocol options:
   - /ipfs/id/1.0.0
   - /ipfs/bitswap
   - /ipfs/dht
+  - '/ipfs/bitswap*' (all the bitswap versions)
+
+The history of the bandwidth, and the totals since the node was first
+started, are printed by 'ipfs stats bw history'.
 
 Example:
 
@@ -72,7 +93,7 @@ Example:
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("peer", "p", "Specify a peer to print bandwidth for."),
-		cmdkit.StringOption("proto", "t", "Specify a protocol to print bandwidth for."),
+		cmdkit.StringOption("proto", "t", "Specify a protocol, or a protocol pattern, to print bandwidth for."),
 		cmdkit.BoolOption("poll", "Print bandwidth at an interval."),
 		cmdkit.StringOption("interval", "i", `Time interval to wait between updating output, if 'poll' is true.
 
@@ -122,8 +143,7 @@ Example:
 				stats := nd.Reporter.GetBandwidthForPeer(pid)
 				res.Emit(&stats)
 			} else if tfound {
-				protoId := protocol.ID(tstr)
-				stats := nd.Reporter.GetBandwidthForProtocol(protoId)
+				stats := protocolBandwidth(nd, tstr)
 				res.Emit(&stats)
 			} else {
 				totals := nd.Reporter.GetBandwidthTotals()
@@ -140,6 +160,9 @@ Example:
 		}
 	},
 	Type: metrics.Stats{},
+	Subcommands: map[string]*cmds.Command{
+		"history": statBwHistoryCmd,
+	},
 	PostRun: cmds.PostRunMap{
 		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
 			polling, _ := res.Request().Options["poll"].(bool)
@@ -172,6 +195,14 @@ Example:
 	},
 }
 
+// protocolBandwidth returns the bandwidth of the protocols matching pattern.
+func protocolBandwidth(nd *core.IpfsNode, pattern string) metrics.Stats {
+	if !strings.Contains(pattern, "*") || nd.Bandwidth == nil {
+		return nd.Reporter.GetBandwidthForProtocol(protocol.ID(pattern))
+	}
+	return nd.Bandwidth.GetBandwidthForProtocols(nd.Bandwidth.MatchProtocols(pattern))
+}
+
 func printStats(out io.Writer, bs *metrics.Stats) {
 	fmt.Fprintln(out, "Bandwidth")
 	fmt.Fprintf(out, "TotalIn: %s\n", humanize.Bytes(uint64(bs.TotalIn)))
@@ -179,3 +210,372 @@ func printStats(out io.Writer, bs *metrics.Stats) {
 	fmt.Fprintf(out, "RateIn: %s/s\n", humanize.Bytes(uint64(bs.RateIn)))
 	fmt.Fprintf(out, "RateOut: %s/s\n", humanize.Bytes(uint64(bs.RateOut)))
 }
+
+// BwSeries is the bandwidth history of the node, a protocol or a peer.
+type BwSeries struct {
+	Name string
+	// Lifetime are the bytes transferred since BwHistoryOutput.Since, it
+	// isn't recorded for the peers.
+	Lifetime *bandwidth.Totals `json:",omitempty"`
+	Samples  []bandwidth.Sample
+}
+
+type BwHistoryOutput struct {
+	// Since is when the node started counting the lifetime totals.
+	Since  time.Time
+	Series []BwSeries
+}
+
+var statBwHistoryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the bandwidth history.",
+		ShortDescription: `
+'ipfs stats bw history' prints the bandwidth samples recorded by the daemon,
+for the whole node, for the protocols matching the 'proto' pattern, or for a
+peer. The bandwidth is sampled every Swarm.BandwidthHistory.Interval (10s by
+default) and the last Swarm.BandwidthHistory.Length samples (360 by default)
+are kept. The history of a peer is forgotten once it is idle for the whole
+period covered by the history.
+
+The bytes transferred since the node was first started are printed for the
+node and for the protocols. They are persisted in the datastore, so they
+survive restarts.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("peer", "p", "Specify a peer to print the history of."),
+		cmdkit.StringOption("proto", "t", "Specify a protocol, or a protocol pattern, to print the history of."),
+		cmdkit.StringOption("since", "s", "Only print the samples of this last duration, e.g. \"10m\"."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.OnlineMode() {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		if nd.Bandwidth == nil {
+			return fmt.Errorf("bandwidth reporter disabled in config")
+		}
+
+		pstr, pfound := req.Options["peer"].(string)
+		tstr, tfound := req.Options["proto"].(string)
+		if pfound && tfound {
+			return cmdkit.Errorf(cmdkit.ErrClient, "please only specify peer OR protocol")
+		}
+
+		var since time.Time
+		if s, found := req.Options["since"].(string); found {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+			}
+			since = time.Now().Add(-d)
+		}
+
+		out := &BwHistoryOutput{}
+		var total bandwidth.Totals
+		out.Since, total = nd.Bandwidth.Lifetime()
+		switch {
+		case pfound:
+			pid, err := peer.IDB58Decode(pstr)
+			if err != nil {
+				return err
+			}
+			out.Series = []BwSeries{{
+				Name:    pid.Pretty(),
+				Samples: nd.Bandwidth.PeerHistory(pid, since),
+			}}
+		case tfound:
+			for _, proto := range nd.Bandwidth.MatchProtocols(tstr) {
+				lifetime := nd.Bandwidth.ProtocolLifetime(proto)
+				out.Series = append(out.Series, BwSeries{
+					Name:     string(proto),
+					Lifetime: &lifetime,
+					Samples:  nd.Bandwidth.ProtocolHistory(proto, since),
+				})
+			}
+		default:
+			out.Series = []BwSeries{{
+				Name:     "total",
+				Lifetime: &total,
+				Samples:  nd.Bandwidth.History(since),
+			}}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: BwHistoryOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*BwHistoryOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			for i, s := range out.Series {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprint(w, s.Name)
+				if s.Lifetime != nil {
+					fmt.Fprintf(w, " (since %s: %s in, %s out)", out.Since.Format(time.RFC3339),
+						humanize.Bytes(uint64(s.Lifetime.In)), humanize.Bytes(uint64(s.Lifetime.Out)))
+				}
+				fmt.Fprintln(w)
+
+				tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "Time\tRate In\tRate Out\tTotal In\tTotal Out")
+				for _, smp := range s.Samples {
+					fmt.Fprintf(tw, "%s\t%s/s\t%s/s\t%s\t%s\n", smp.Time.Format("15:04:05"),
+						humanize.Bytes(uint64(smp.RateIn)), humanize.Bytes(uint64(smp.RateOut)),
+						humanize.Bytes(uint64(smp.TotalIn)), humanize.Bytes(uint64(smp.TotalOut)))
+				}
+				tw.Flush()
+			}
+			return nil
+		}),
+	},
+}
+
+type DiskIOOutput struct {
+	Subsystems map[string]diskio.Stats
+}
+
+var statDiskIOCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the blockstore operations of each subsystem.",
+		ShortDescription: `
+'ipfs stats diskio' prints the reads, writes and deletions of blocks made by
+the subsystems of the daemon since it started: bitswap, serving and storing
+blocks for peers; the gateway; the pinner, which also accounts for its
+datastore operations; the garbage collection and the reprovider. The total
+counts all the blockstore operations, including the ones of the API
+commands.
+
+The reads answered by the blockstore caches are counted too. The same counts
+are exported to Prometheus as ipfs_diskio_ops_total and
+ipfs_diskio_bytes_total.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.OnlineMode() {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		return cmds.EmitOnce(res, &DiskIOOutput{Subsystems: nd.DiskIO.Stats()})
+	},
+	Type: DiskIOOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DiskIOOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			// the total comes last
+			names := make([]string, 0, len(out.Subsystems))
+			for name := range out.Subsystems {
+				if name != diskio.Total {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			if _, ok := out.Subsystems[diskio.Total]; ok {
+				names = append(names, diskio.Total)
+			}
+
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "Subsystem\tReads\tRead\tWrites\tWritten\tDeletes")
+			for _, name := range names {
+				st := out.Subsystems[name]
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%d\n", name,
+					st.ReadOps, humanize.Bytes(st.ReadBytes),
+					st.WriteOps, humanize.Bytes(st.WriteBytes), st.DeleteOps)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var statHolePunchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print hole punching statistics.",
+		ShortDescription: `
+'ipfs stats holepunch' prints how many times the node tried to upgrade a
+relayed connection to a direct one, and how many of these attempts succeeded
+and failed.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.OnlineMode() {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		if nd.HolePunch == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "hole punching is disabled")
+		}
+
+		st := nd.HolePunch.Stats()
+		return cmds.EmitOnce(res, &st)
+	},
+	Type: holepunch.Stats{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			st, ok := v.(*holepunch.Stats)
+			if !ok {
+				return e.TypeErr(st, v)
+			}
+
+			fmt.Fprintln(w, "Hole punching")
+			fmt.Fprintf(w, "Attempts: %d\n", st.Attempts)
+			fmt.Fprintf(w, "Successes: %d\n", st.Successes)
+			fmt.Fprintf(w, "Failures: %d\n", st.Failures)
+			return nil
+		}),
+	},
+}
+
+var statRelayCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print relay service statistics.",
+		ShortDescription: `
+'ipfs stats relay' prints the active reservations and circuits of the relay
+service, as well as the number of bytes relayed and of refused requests.
+The relay service is enabled with Swarm.RelayService.Enabled.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.OnlineMode() {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		if nd.RelayService == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "relay service is disabled")
+		}
+
+		st := nd.RelayService.Stat()
+		return cmds.EmitOnce(res, &st)
+	},
+	Type: relay.Stats{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			st, ok := v.(*relay.Stats)
+			if !ok {
+				return e.TypeErr(st, v)
+			}
+
+			fmt.Fprintln(w, "Relay service")
+			fmt.Fprintf(w, "Reservations: %d\n", len(st.Reservations))
+			for _, r := range st.Reservations {
+				fmt.Fprintf(w, "\t%s (expires %s)\n", r.Peer, r.Expires.Format(time.RFC3339))
+			}
+			fmt.Fprintf(w, "ActiveCircuits: %d\n", st.ActiveCircuits)
+			fmt.Fprintf(w, "TotalCircuits: %d\n", st.TotalCircuits)
+			fmt.Fprintf(w, "RelayedBytes: %s\n", humanize.Bytes(st.RelayedBytes))
+			fmt.Fprintf(w, "RefusedReservations: %d\n", st.RefusedReservations)
+			fmt.Fprintf(w, "RefusedCircuits: %d\n", st.RefusedCircuits)
+			return nil
+		}),
+	},
+}
+
+// DhtStatsOutput is the routing tables of the DHTs.
+type DhtStatsOutput struct {
+	DHTs []dual.Stats
+}
+
+var statDhtCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the routing tables of the DHTs.",
+		ShortDescription: `
+'ipfs stats dht' prints the mode of the WAN and LAN DHTs, server or client,
+and the peers of their routing tables grouped in buckets by the number of
+leading bits of their key they share with the node. The WAN DHT holds the
+peers connected over public addresses, the LAN DHT the peers connected over
+private and loopback ones. Give 'wan' or 'lan' to print a single DHT:
+
+  ipfs stats dht wan
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("dht", false, false, "The DHT to print: 'wan' or 'lan'."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.OnlineMode() {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotOnline.Error())
+		}
+
+		if nd.DualDHT == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, ErrNotDHT.Error())
+		}
+
+		out := &DhtStatsOutput{}
+		which := ""
+		if len(req.Arguments) > 0 {
+			which = req.Arguments[0]
+		}
+		switch which {
+		case "":
+			out.DHTs = []dual.Stats{nd.DualDHT.WANStats(), nd.DualDHT.LANStats()}
+		case "wan":
+			out.DHTs = []dual.Stats{nd.DualDHT.WANStats()}
+		case "lan":
+			out.DHTs = []dual.Stats{nd.DualDHT.LANStats()}
+		default:
+			return cmdkit.Errorf(cmdkit.ErrClient, "unknown DHT %q, expected 'wan' or 'lan'", which)
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: DhtStatsOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DhtStatsOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			for i, st := range out.DHTs {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				mode := st.Mode
+				if st.Auto {
+					mode += ", auto"
+				}
+				fmt.Fprintf(w, "DHT %s (%s, %s): %d peers\n", st.Name, st.Protocol, mode, st.Peers)
+				tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+				for _, b := range st.Buckets {
+					fmt.Fprintf(tw, "  Bucket %d (%d peers):\n", b.CPL, len(b.Peers))
+					for _, p := range b.Peers {
+						fmt.Fprintf(tw, "    %s\t%s\t%s\n", p.ID, p.Addr, p.AgentVersion)
+					}
+				}
+				tw.Flush()
+			}
+			return nil
+		}),
+	},
+}
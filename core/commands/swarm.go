@@ -2,17 +2,21 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"path"
 	"sort"
 	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	repo "github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/fsrepo"
+	wss "github.com/ipfs/go-ipfs/wss"
 
 	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	mafilter "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
@@ -45,9 +49,16 @@ ipfs peers in the internet.
 	Subcommands: map[string]*cmds.Command{
 		"addrs":      swarmAddrsCmd,
 		"connect":    swarmConnectCmd,
+		"discover":   swarmDiscoverCmd,
 		"disconnect": swarmDisconnectCmd,
+		"discovery":  swarmDiscoveryCmd,
 		"filters":    swarmFiltersCmd,
+		"limit":      swarmLimitCmd,
+		"peering":    swarmPeeringCmd,
 		"peers":      swarmPeersCmd,
+		"peerstore":  swarmPeerstoreCmd,
+		"rendezvous": swarmRendezvousCmd,
+		"stats":      swarmStatsCmd,
 	},
 }
 
@@ -231,8 +242,12 @@ var swarmAddrsCmd = &cmds.Command{
 		Tagline: "List known addresses. Useful for debugging.",
 		ShortDescription: `
 'ipfs swarm addrs' lists all addresses this node is aware of.
+Pass --ws-only to only list WebSocket (/ws and /wss) addresses.
 `,
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("ws-only", "Only list WebSocket addresses."),
+	},
 	Subcommands: map[string]*cmds.Command{
 		"local":  swarmAddrsLocalCmd,
 		"listen": swarmAddrsListenCmd,
@@ -250,13 +265,22 @@ var swarmAddrsCmd = &cmds.Command{
 			return
 		}
 
+		wsOnly, _, _ := req.Option("ws-only").Bool()
+
 		addrs := make(map[string][]string)
 		ps := n.PeerHost.Network().Peerstore()
 		for _, p := range ps.Peers() {
 			s := p.Pretty()
 			for _, a := range ps.Addrs(p) {
+				if wsOnly && !wss.IsWebSocket(a) {
+					continue
+				}
 				addrs[s] = append(addrs[s], a.String())
 			}
+			if wsOnly && len(addrs[s]) == 0 {
+				delete(addrs, s)
+				continue
+			}
 			sort.Sort(sort.StringSlice(addrs[s]))
 		}
 
@@ -387,11 +411,24 @@ var swarmConnectCmd = &cmds.Command{
 The address format is an IPFS multiaddr:
 
 ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+
+With --verbose, the addresses of the peer are dialed one at a time, and the
+transport and the result of each attempt are printed. The failures of the
+other dials are then reported too.
+
+The dial backoff of the peer, set after failed dials, is cleared first
+unless --clear-backoff=false is passed. The global --timeout option bounds
+the whole command, --dial-timeout each dial.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("address", true, true, "Address of peer to connect to.").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("verbose", "v", "Dial the addresses one at a time, and print the result of each dial."),
+		cmdkit.StringOption("dial-timeout", "Max time to dial an address eg \"10s\". Default is no limit."),
+		cmdkit.BoolOption("clear-backoff", "Clear the dial backoff of the peer before dialing.").WithDefault(true),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
 
@@ -415,24 +452,48 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3
 			return
 		}
 
+		verbose, _, _ := req.Option("verbose").Bool()
+		clearBackoff, _, _ := req.Option("clear-backoff").Bool()
+
+		var dialTimeout time.Duration
+		if s, found, _ := req.Option("dial-timeout").String(); found {
+			dialTimeout, err = time.ParseDuration(s)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+		}
+
 		pis, err := peersWithAddresses(addrs)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
 
-		output := make([]string, len(pis))
-		for i, pi := range pis {
-			swrm.Backoff().Clear(pi.ID)
+		var output []string
+		for _, pi := range pis {
+			if clearBackoff {
+				swrm.Backoff().Clear(pi.ID)
+			}
+
+			msg := "connect " + pi.ID.Pretty()
 
-			output[i] = "connect " + pi.ID.Pretty()
+			if !verbose {
+				err := connectWithTimeout(ctx, n, pi, dialTimeout)
+				if err != nil {
+					res.SetError(fmt.Errorf("%s failure: %s", msg, err), cmdkit.ErrNormal)
+					return
+				}
+				output = append(output, msg+" success")
+				continue
+			}
 
-			err := n.PeerHost.Connect(ctx, pi)
+			attempts, err := dialEachAddr(ctx, n, swrm, pi, dialTimeout)
 			if err != nil {
-				res.SetError(fmt.Errorf("%s failure: %s", output[i], err), cmdkit.ErrNormal)
+				res.SetError(fmt.Errorf("%s failure: %s%s", msg, err, formatDialAttempts(attempts)), cmdkit.ErrNormal)
 				return
 			}
-			output[i] += " success"
+			output = append(output, msg+" success"+formatDialAttempts(attempts))
 		}
 
 		res.SetOutput(&stringList{output})
@@ -443,6 +504,111 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3
 	Type: stringList{},
 }
 
+func connectWithTimeout(ctx context.Context, n *core.IpfsNode, pi pstore.PeerInfo, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return n.PeerHost.Connect(ctx, pi)
+}
+
+// dialAttempt is the result of the dial of one address of a peer.
+type dialAttempt struct {
+	Addr ma.Multiaddr
+	Err  error
+
+	// Existing is set when the peer was already connected on Addr
+	Existing bool
+}
+
+// dialEachAddr dials the addresses of the peer one at a time, until one
+// succeeds, and returns the result of each dial. The addresses are looked
+// up with the routing system when none is given or known.
+func dialEachAddr(ctx context.Context, n *core.IpfsNode, swrm *swarm.Swarm, pi pstore.PeerInfo, timeout time.Duration) ([]dialAttempt, error) {
+	if conns := swrm.ConnsToPeer(pi.ID); len(conns) > 0 {
+		return []dialAttempt{{Addr: conns[0].RemoteMultiaddr(), Existing: true}}, nil
+	}
+
+	addrs := pi.Addrs
+	if len(addrs) == 0 {
+		addrs = n.Peerstore.Addrs(pi.ID)
+	}
+	if len(addrs) == 0 && n.Routing != nil {
+		lctx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			lctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		found, err := n.Routing.FindPeer(lctx, pi.ID)
+		if err != nil {
+			return nil, fmt.Errorf("peer lookup failed: %s", err)
+		}
+		addrs = found.Addrs
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no address known for the peer")
+	}
+
+	var attempts []dialAttempt
+	for _, a := range addrs {
+		var err error
+		if swrm.TransportForDialing(a) == nil {
+			err = errors.New("no transport can dial this address")
+		} else {
+			// the failed dials would back off the next ones
+			swrm.Backoff().Clear(pi.ID)
+			err = dialAddr(ctx, n, pi.ID, a, timeout)
+		}
+
+		attempts = append(attempts, dialAttempt{Addr: a, Err: err})
+		if err == nil {
+			return attempts, nil
+		}
+	}
+	return attempts, errors.New("all dials failed")
+}
+
+// dialAddr connects to the peer on addr only. The host dials all the known
+// addresses of a peer, so they are hidden for the time of the dial.
+func dialAddr(ctx context.Context, n *core.IpfsNode, pid peer.ID, addr ma.Multiaddr, timeout time.Duration) error {
+	known := n.Peerstore.Addrs(pid)
+	n.Peerstore.ClearAddrs(pid)
+	defer n.Peerstore.AddAddrs(pid, known, pstore.AddressTTL)
+
+	return connectWithTimeout(ctx, n, pstore.PeerInfo{ID: pid, Addrs: []ma.Multiaddr{addr}}, timeout)
+}
+
+// transportName returns the protocols of addr above the network layer,
+// eg tcp/ws for /ip4/1.2.3.4/tcp/4001/ws.
+func transportName(addr ma.Multiaddr) string {
+	protos := addr.Protocols()
+	if len(protos) < 2 {
+		return "unknown"
+	}
+
+	names := make([]string, 0, len(protos)-1)
+	for _, p := range protos[1:] {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, "/")
+}
+
+func formatDialAttempts(attempts []dialAttempt) string {
+	var out string
+	for _, a := range attempts {
+		result := "success"
+		if a.Existing {
+			result = "already connected"
+		} else if a.Err != nil {
+			result = a.Err.Error()
+		}
+		out += fmt.Sprintf("\n  %s (%s): %s", a.Addr, transportName(a.Addr), result)
+	}
+	return out
+}
+
 var swarmDisconnectCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Close connection to a given address.",
@@ -602,11 +768,15 @@ Where the above is equivalent to the standard CIDR:
     192.168.0.0/16
 
 Filters default to those specified under the "Swarm.AddrFilters" config key.
+The rules of the "Swarm.ConnGater" config key can also allow some subnets,
+autonomous systems or peers and deny the others, use 'ipfs swarm filters test'
+to check which filter or rule applies to an address.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"add": swarmFiltersAddCmd,
-		"rm":  swarmFiltersRmCmd,
+		"add":  swarmFiltersAddCmd,
+		"rm":   swarmFiltersRmCmd,
+		"test": swarmFiltersTestCmd,
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+type discoveryOutput struct {
+	// MDNS is nil when the mDNS discovery isn't running.
+	MDNS  *core.MDNSSettings
+	Peers []core.DiscoveredPeer
+}
+
+var swarmDiscoveryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the status of the local discovery.",
+		ShortDescription: `
+'ipfs swarm discovery' prints whether the mDNS discovery is running, its
+settings, and the peers it found on the local network, the most recent
+first. The node dials the peers it finds unless it is already connected to
+them, the result of the last dial is printed for each peer.
+
+The mDNS discovery is configured in the Discovery.MDNS section of the config:
+Enabled, Interval (in seconds) and ServiceTag. Nodes only discover the nodes
+announcing the same service tag.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.PeerHost == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		res.SetOutput(&discoveryOutput{
+			MDNS:  n.MDNS(),
+			Peers: n.DiscoveredPeers(),
+		})
+	},
+	Type: discoveryOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*discoveryOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			if out.MDNS == nil {
+				fmt.Fprintln(buf, "mDNS: disabled")
+			} else {
+				fmt.Fprintf(buf, "mDNS: enabled, service tag %q, interval %s\n", out.MDNS.ServiceTag, out.MDNS.Interval)
+			}
+
+			now := time.Now()
+			for _, p := range out.Peers {
+				fmt.Fprintf(buf, "%s found %s ago (first %s ago): %s\n", p.ID.Pretty(),
+					now.Sub(p.LastSeen).Round(time.Second), now.Sub(p.FirstSeen).Round(time.Second), discoveryStatus(p))
+				for _, a := range p.Addrs {
+					fmt.Fprintf(buf, "  %s\n", a)
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+func discoveryStatus(p core.DiscoveredPeer) string {
+	switch {
+	case !p.Attempted:
+		return "already connected"
+	case p.Connected:
+		return "connected"
+	case p.Error != "":
+		return "dial failed: " + p.Error
+	default:
+		return "dialing"
+	}
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	gater "github.com/ipfs/go-ipfs/gater"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	mafilter "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	swarm "gx/ipfs/QmeDpqUwwdye8ABKVMPXKuWwPVURFdqTqssbTUB39E2Nwd/go-libp2p-swarm"
+	iaddr "gx/ipfs/QmePSRaGafvmURQwQkHPDBJsaGwKXC1WpBBHVCQxdr8FPn/go-ipfs-addr"
+)
+
+// FilterTestOutput is whether a connection is allowed by the address
+// filters and the connection gater.
+type FilterTestOutput struct {
+	Address string
+	Peer    string `json:",omitempty"`
+	Allowed bool
+	// Reason is the filter or the rule deciding.
+	Reason string
+	ASN    int `json:",omitempty"`
+}
+
+var swarmFiltersTestCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check whether a connection is allowed by the filters.",
+		ShortDescription: `
+'ipfs swarm filters test' checks whether a connection over the given address
+is allowed by the "Swarm.AddrFilters" and the rules of the "Swarm.ConnGater"
+config keys, and prints the filter or the rule deciding. The address can end
+with the ID of the peer, which the rules with Peers need to match:
+
+  ipfs swarm filters test /ip4/10.1.2.3/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+
+The filters and rules of the running daemon are used, those of the config
+when the daemon isn't running.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Multiaddr to check."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		addr, p, err := parseFilterTestAddr(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+		out := &FilterTestOutput{Address: addr.String()}
+		if p != "" {
+			out.Peer = p.Pretty()
+		}
+
+		ip := gater.AddrIP(addr)
+		if n.PeerHost != nil {
+			// the filters of the swarm include the ones added by 'ipfs
+			// swarm filters add' and the subnets denied by the gater
+			swrm, ok := n.PeerHost.Network().(*swarm.Swarm)
+			if !ok {
+				res.SetError(errors.New("failed to cast network to swarm network"), cmdkit.ErrNormal)
+				return
+			}
+			for _, f := range swrm.Filters.Filters() {
+				if ip != nil && f.Contains(ip) {
+					s, _ := mafilter.ConvertIPNet(f)
+					out.Reason = "denied by the address filter " + s
+					res.SetOutput(out)
+					return
+				}
+			}
+		} else {
+			cfg, err := n.Repo.Config()
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			for _, s := range cfg.Swarm.AddrFilters {
+				f, err := mafilter.NewMask(s)
+				if err != nil {
+					res.SetError(fmt.Errorf("invalid Swarm.AddrFilters %q: %s", s, err), cmdkit.ErrNormal)
+					return
+				}
+				if ip != nil && f.Contains(ip) {
+					out.Reason = "denied by Swarm.AddrFilters " + s
+					res.SetOutput(out)
+					return
+				}
+			}
+		}
+
+		g := n.Gater
+		if g == nil {
+			gcfg, err := extconfig.LoadConnGater(n.Repo)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			g, err = gater.New(gcfg)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		d := g.Check(p, addr)
+		out.Allowed = d.Allowed
+		out.ASN = d.ASN
+		action := "denied"
+		if d.Allowed {
+			action = "allowed"
+		}
+		if d.Rule < 0 {
+			out.Reason = fmt.Sprintf("%s by default", action)
+		} else {
+			out.Reason = fmt.Sprintf("%s by Swarm.ConnGater.Rules[%d]", action, d.Rule)
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*FilterTestOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "%s: %s", out.Address, out.Reason)
+			if out.ASN != 0 {
+				fmt.Fprintf(buf, " (AS%d)", out.ASN)
+			}
+			fmt.Fprintln(buf)
+			return buf, nil
+		},
+	},
+	Type: FilterTestOutput{},
+}
+
+// parseFilterTestAddr splits the ID of the peer from the address, when it
+// has one.
+func parseFilterTestAddr(s string) (ma.Multiaddr, peer.ID, error) {
+	if a, err := iaddr.ParseString(s); err == nil {
+		return a.Transport(), a.ID(), nil
+	}
+	addr, err := ma.NewMultiaddr(s)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := addr.ValueForProtocol(ma.P_IPFS); err == nil {
+		return nil, "", errors.New("invalid peer ID in the address")
+	}
+	return addr, "", nil
+}
@@ -0,0 +1,254 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	repo "github.com/ipfs/go-ipfs/repo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+type peeringPeers struct {
+	Peers []extconfig.PeeringPeer
+}
+
+var swarmPeeringCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Modify the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering' manages the peering set: a list of peers this node keeps
+permanent connections to. Peered connections are protected from being closed
+by the connection manager and are re-established, with backoff, whenever they
+drop.
+
+The peering set is stored under the "Peering.Peers" config key, so changes
+made with these commands persist daemon restarts.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": swarmPeeringAddCmd,
+		"ls":  swarmPeeringLsCmd,
+		"rm":  swarmPeeringRmCmd,
+	},
+}
+
+var swarmPeeringAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add peers into the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering add' adds peers to the peering set. The address format is
+an IPFS multiaddr:
+
+ipfs swarm peering add /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, true, "Address of peer to add into the peering subsystem.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Peering == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		pis, err := peersWithAddresses(req.Arguments())
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		if err := peeringUpdate(r, pis, nil); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		output := make([]string, len(pis))
+		for i, pi := range pis {
+			n.Peering.AddPeer(pi)
+			output[i] = "add " + pi.ID.Pretty() + " success"
+		}
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmPeeringLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List peers registered in the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering ls' lists the peers in the peering set.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Peering == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		pis := n.Peering.ListPeers()
+		out := &peeringPeers{Peers: make([]extconfig.PeeringPeer, 0, len(pis))}
+		for _, pi := range pis {
+			out.Peers = append(out.Peers, peeringPeerFromInfo(pi))
+		}
+		sort.Slice(out.Peers, func(i, j int) bool {
+			return out.Peers[i].ID < out.Peers[j].ID
+		})
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*peeringPeers)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, p := range out.Peers {
+				fmt.Fprintf(buf, "%s\n", p.ID)
+				for _, a := range p.Addrs {
+					fmt.Fprintf(buf, "\t%s\n", a)
+				}
+			}
+			return buf, nil
+		},
+	},
+	Type: peeringPeers{},
+}
+
+var swarmPeeringRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove peers from the peering subsystem.",
+		ShortDescription: `
+'ipfs swarm peering rm' removes peers from the peering set. Open connections
+to them are left alone but are no longer protected.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ID", true, true, "ID of peer to remove from the peering subsystem.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Peering == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		ids := make([]peer.ID, len(req.Arguments()))
+		for i, arg := range req.Arguments() {
+			ids[i], err = peer.IDB58Decode(arg)
+			if err != nil {
+				res.SetError(cmds.ClientError("invalid peer ID: "+err.Error()), cmdkit.ErrClient)
+				return
+			}
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		if err := peeringUpdate(r, nil, ids); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		output := make([]string, len(ids))
+		for i, id := range ids {
+			output[i] = "remove " + id.Pretty()
+			if n.Peering.RemovePeer(id) {
+				output[i] += " success"
+			} else {
+				output[i] += " failure: not in peering set"
+			}
+		}
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+func peeringPeerFromInfo(pi pstore.PeerInfo) extconfig.PeeringPeer {
+	p := extconfig.PeeringPeer{
+		ID:    pi.ID.Pretty(),
+		Addrs: make([]string, 0, len(pi.Addrs)),
+	}
+	for _, a := range pi.Addrs {
+		p.Addrs = append(p.Addrs, a.String())
+	}
+	sort.Strings(p.Addrs)
+	return p
+}
+
+// peeringUpdate adds and removes peers from the peering set stored in the
+// config. Added peers that are already present have their addresses replaced.
+func peeringUpdate(r repo.Repo, add []pstore.PeerInfo, rm []peer.ID) error {
+	cfg, err := extconfig.LoadPeering(r)
+	if err != nil {
+		return err
+	}
+
+	drop := make(map[string]bool, len(add)+len(rm))
+	for _, pi := range add {
+		drop[pi.ID.Pretty()] = true
+	}
+	for _, id := range rm {
+		drop[id.Pretty()] = true
+	}
+
+	peers := make([]extconfig.PeeringPeer, 0, len(cfg.Peers)+len(add))
+	for _, p := range cfg.Peers {
+		if !drop[p.ID] {
+			peers = append(peers, p)
+		}
+	}
+	for _, pi := range add {
+		peers = append(peers, peeringPeerFromInfo(pi))
+	}
+
+	return r.SetConfigKey(extconfig.PeeringPeersKey, peers)
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	peerstore "github.com/ipfs/go-ipfs/peerstore"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+var swarmPeerstoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the peers persisted in the datastore.",
+		ShortDescription: `
+Unless Swarm.Peerstore.Persist is false, the addresses, protocols and
+latencies of the peers are written to the datastore, so that the node can
+reconnect to them after a restart without the bootstrap peers.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"gc": swarmPeerstoreGCCmd,
+	},
+}
+
+var swarmPeerstoreGCCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove the expired peers from the datastore.",
+		ShortDescription: `
+'ipfs swarm peerstore gc' removes the addresses that weren't seen for
+Swarm.Peerstore.AddrTTL, the peers left without addresses and the least
+recently seen peers beyond Swarm.Peerstore.MaxPeers. This is also done
+periodically by the daemon.
+
+When the daemon is running, the peerstore is first written to the datastore.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		p := n.PeerstorePersister
+		if p != nil {
+			if err := p.Flush(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		} else {
+			// offline, or persistence disabled: collect what a previous
+			// run left
+			cfg, err := extconfig.LoadPeerstore(n.Repo)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			p, err = peerstore.NewPersister(n.Identity, n.Peerstore, n.Repo.Datastore(), nil, cfg)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			defer p.Close()
+		}
+
+		gc, err := p.GC()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(&gc)
+	},
+	Type: peerstore.GCResult{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			gc, ok := v.(*peerstore.GCResult)
+			if !ok {
+				return nil, e.TypeErr(gc, v)
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "removed %d peers and %d addresses, %d peers left\n", gc.Removed, gc.RemovedAddrs, gc.Kept)
+			return buf, nil
+		},
+	},
+}
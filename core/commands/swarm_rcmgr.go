@@ -0,0 +1,516 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	bandwidth "github.com/ipfs/go-ipfs/bandwidth"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	rcmgr "github.com/ipfs/go-ipfs/rcmgr"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+var errResourceMgrDisabled = errors.New("resource manager not enabled, set Swarm.ResourceMgr.Enabled to use")
+
+const rcmgrScopeHelp = `
+Scopes are named as follows:
+
+  system              the node as a whole
+  peer                the default limit of every peer
+  protocol            the default limit of every protocol
+  peer:<peer ID>      a specific peer
+  protocol:<id>       a specific protocol, e.g. protocol:/ipfs/bitswap/1.1.0
+`
+
+var swarmLimitCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Get or set resource manager limits.",
+		ShortDescription: `
+'ipfs swarm limit <scope>' prints the limits of a resource manager scope.
+Passing a JSON limit as second argument replaces the limits of the scope and
+persists them in the "Swarm.ResourceMgr.Limits" config key:
+
+  ipfs swarm limit system '{"Conns": 1024, "ConnsInbound": 512, "Streams": 8192}'
+
+Only the fields given are persisted, the others keep their default. A value
+of -1 means unlimited. To remove the limit of a specific peer or protocol,
+pass --reset.
+` + rcmgrScopeHelp,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("scope", true, false, "Scope of the limit."),
+		cmdkit.StringArg("limit", false, false, "JSON encoded limit to set."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("reset", "Remove the limit of a specific peer or protocol."),
+	},
+	Subcommands: map[string]*cmds.Command{
+		"bw": swarmLimitBwCmd,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		rm := n.ResourceManager
+		if rm == nil {
+			res.SetError(errResourceMgrDisabled, cmdkit.ErrClient)
+			return
+		}
+
+		scope := req.Arguments()[0]
+		current, err := rcmgrLimit(rm, scope)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		reset, _, _ := req.Option("reset").Bool()
+		if len(req.Arguments()) < 2 && !reset {
+			res.SetOutput(&current)
+			return
+		}
+
+		var l extconfig.ResourceLimit
+		if !reset {
+			if err := json.Unmarshal([]byte(req.Arguments()[1]), &l); err != nil {
+				res.SetError(fmt.Errorf("invalid limit: %s", err), cmdkit.ErrClient)
+				return
+			}
+		}
+
+		// only the configured limits are persisted, not the defaults
+		limits, err := rcmgrSetLimit(rm.Config(), scope, l, reset)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		if err := r.SetConfigKey(extconfig.ResourceMgrKey+".Limits", limits); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		rm.SetLimits(limits)
+
+		current, _ = rcmgrLimit(rm, scope)
+		res.SetOutput(&current)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			l, ok := v.(*extconfig.ResourceLimit)
+			if !ok {
+				return nil, e.TypeErr(l, v)
+			}
+
+			limit := func(v int64) string {
+				if v <= 0 {
+					return "unlimited"
+				}
+				return fmt.Sprint(v)
+			}
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Conns: %s\n", limit(int64(l.Conns)))
+			fmt.Fprintf(buf, "ConnsInbound: %s\n", limit(int64(l.ConnsInbound)))
+			fmt.Fprintf(buf, "ConnsOutbound: %s\n", limit(int64(l.ConnsOutbound)))
+			fmt.Fprintf(buf, "Streams: %s\n", limit(int64(l.Streams)))
+			fmt.Fprintf(buf, "FD: %s\n", limit(int64(l.FD)))
+			fmt.Fprintf(buf, "Memory: %s\n", limit(l.Memory))
+			return buf, nil
+		},
+	},
+	Type: extconfig.ResourceLimit{},
+}
+
+// rcmgrLimit returns the limit in effect for the named scope.
+func rcmgrLimit(rm *rcmgr.ResourceManager, scope string) (extconfig.ResourceLimit, error) {
+	switch {
+	case scope == "system":
+		return rm.Limits().System, nil
+	case scope == "peer":
+		return rm.Limits().Peer, nil
+	case scope == "protocol":
+		return rm.Limits().Protocol, nil
+	case strings.HasPrefix(scope, "peer:"):
+		p, err := peer.IDB58Decode(strings.TrimPrefix(scope, "peer:"))
+		if err != nil {
+			return extconfig.ResourceLimit{}, fmt.Errorf("invalid peer ID in scope %q: %s", scope, err)
+		}
+		return rm.PeerLimit(p), nil
+	case strings.HasPrefix(scope, "protocol:"):
+		return rm.ProtocolLimit(protocol.ID(strings.TrimPrefix(scope, "protocol:"))), nil
+	default:
+		return extconfig.ResourceLimit{}, fmt.Errorf("invalid scope %q", scope)
+	}
+}
+
+// rcmgrSetLimit returns a copy of limits with the limit of scope replaced, or
+// removed if reset is set.
+func rcmgrSetLimit(limits extconfig.ResourceMgrLimits, scope string, l extconfig.ResourceLimit, reset bool) (extconfig.ResourceMgrLimits, error) {
+	copyMap := func(m map[string]extconfig.ResourceLimit) map[string]extconfig.ResourceLimit {
+		out := make(map[string]extconfig.ResourceLimit, len(m)+1)
+		for k, v := range m {
+			out[k] = v
+		}
+		return out
+	}
+
+	if reset && !strings.Contains(scope, ":") {
+		return limits, fmt.Errorf("--reset only applies to specific peers or protocols")
+	}
+
+	switch {
+	case scope == "system":
+		limits.System = l
+	case scope == "peer":
+		limits.Peer = l
+	case scope == "protocol":
+		limits.Protocol = l
+	case strings.HasPrefix(scope, "peer:"):
+		p, err := peer.IDB58Decode(strings.TrimPrefix(scope, "peer:"))
+		if err != nil {
+			return limits, fmt.Errorf("invalid peer ID in scope %q: %s", scope, err)
+		}
+		limits.Peers = copyMap(limits.Peers)
+		if reset {
+			delete(limits.Peers, p.Pretty())
+		} else {
+			limits.Peers[p.Pretty()] = l
+		}
+	case strings.HasPrefix(scope, "protocol:"):
+		proto := strings.TrimPrefix(scope, "protocol:")
+		limits.Protocols = copyMap(limits.Protocols)
+		if reset {
+			delete(limits.Protocols, proto)
+		} else {
+			limits.Protocols[proto] = l
+		}
+	default:
+		return limits, fmt.Errorf("invalid scope %q", scope)
+	}
+	return limits, nil
+}
+
+type rcmgrStats struct {
+	System         *rcmgr.Usage           `json:",omitempty"`
+	Peers          map[string]rcmgr.Usage `json:",omitempty"`
+	Protocols      map[string]rcmgr.Usage `json:",omitempty"`
+	BlockedConns   uint64
+	BlockedStreams uint64
+}
+
+var swarmStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Report resource manager usage.",
+		ShortDescription: `
+'ipfs swarm stats <scope>' reports the resources currently used in a scope,
+along with the number of connections and streams closed for exceeding a limit.
+Use 'all' to report every scope.
+` + rcmgrScopeHelp,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("scope", true, false, "Scope to report, or 'all'."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		if n.ResourceManager == nil {
+			res.SetError(errResourceMgrDisabled, cmdkit.ErrClient)
+			return
+		}
+
+		stat := n.ResourceManager.Stat()
+		out := &rcmgrStats{
+			BlockedConns:   stat.BlockedConns,
+			BlockedStreams: stat.BlockedStreams,
+		}
+
+		scope := req.Arguments()[0]
+		all := scope == "all"
+		if all || scope == "system" {
+			out.System = &stat.System
+		}
+		if all || scope == "peer" || strings.HasPrefix(scope, "peer:") {
+			want := strings.TrimPrefix(scope, "peer:")
+			out.Peers = make(map[string]rcmgr.Usage)
+			for p, u := range stat.Peers {
+				if id := p.Pretty(); !strings.HasPrefix(scope, "peer:") || id == want {
+					out.Peers[id] = u
+				}
+			}
+		}
+		if all || scope == "protocol" || strings.HasPrefix(scope, "protocol:") {
+			want := strings.TrimPrefix(scope, "protocol:")
+			out.Protocols = make(map[string]rcmgr.Usage)
+			for proto, u := range stat.Protocols {
+				if !strings.HasPrefix(scope, "protocol:") || string(proto) == want {
+					out.Protocols[string(proto)] = u
+				}
+			}
+		}
+		if out.System == nil && out.Peers == nil && out.Protocols == nil {
+			res.SetError(fmt.Errorf("invalid scope %q", scope), cmdkit.ErrClient)
+			return
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*rcmgrStats)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			printUsage := func(name string, u rcmgr.Usage) {
+				fmt.Fprintf(buf, "%s: conns in=%d out=%d, streams=%d, fd=%d", name, u.ConnsInbound, u.ConnsOutbound, u.Streams, u.FD)
+				if u.Memory > 0 {
+					fmt.Fprintf(buf, ", memory=%d", u.Memory)
+				}
+				fmt.Fprintln(buf)
+			}
+
+			if out.System != nil {
+				printUsage("system", *out.System)
+			}
+			for _, scope := range []struct {
+				prefix string
+				usage  map[string]rcmgr.Usage
+			}{{"peer:", out.Peers}, {"protocol:", out.Protocols}} {
+				names := make([]string, 0, len(scope.usage))
+				for name := range scope.usage {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					printUsage(scope.prefix+name, scope.usage[name])
+				}
+			}
+			fmt.Fprintf(buf, "blocked: conns=%d, streams=%d\n", out.BlockedConns, out.BlockedStreams)
+			return buf, nil
+		},
+	},
+	Type: rcmgrStats{},
+}
+
+var swarmLimitBwCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Get or set the bandwidth limits of the streams.",
+		ShortDescription: `
+'ipfs swarm limit bw' prints the bandwidth limits of the libp2p streams.
+Passing a scope and its JSON rates replaces the limit of the scope, applies it
+right away and persists it in the "Swarm.BandwidthLimits" config key:
+
+  ipfs swarm limit bw total '{"In": "100Mbps", "Out": "50Mbps"}'
+  ipfs swarm limit bw class:bitswap '{"Out": "50Mbps", "Protocols": ["/ipfs/bitswap"]}'
+
+Rates are in bytes per second, such as "10MB/s", or in bits per second, such
+as "50Mbps". An empty rate is unlimited. To remove a class, pass --reset.
+
+Scopes are named as follows:
+
+  total               the node as a whole
+  peer                every remote peer
+  class:<name>        the protocols and peers of a class
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("scope", false, false, "Scope of the limit."),
+		cmdkit.StringArg("limit", false, false, "JSON encoded rates to set."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("reset", "Remove the limit of a class."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		limits := n.Shaper.Limits()
+		reset, _, _ := req.Option("reset").Bool()
+		args := req.Arguments()
+		if len(args) == 0 || (len(args) < 2 && !reset) {
+			if len(args) == 1 {
+				res.SetError(fmt.Errorf("missing the rates of %s", args[0]), cmdkit.ErrClient)
+				return
+			}
+			res.SetOutput(&limits)
+			return
+		}
+
+		var c extconfig.BandwidthClass
+		if !reset {
+			if err := json.Unmarshal([]byte(args[1]), &c); err != nil {
+				res.SetError(fmt.Errorf("invalid limit: %s", err), cmdkit.ErrClient)
+				return
+			}
+		}
+		limits, err = bwSetLimit(limits, args[0], c, reset)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		// applied first, to check the rates before writing them
+		if err := n.Shaper.SetLimits(&limits); err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		if err := r.SetConfigKey(extconfig.BandwidthLimitsKey, limits); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		limits = n.Shaper.Limits()
+		res.SetOutput(&limits)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			l, ok := v.(*extconfig.BandwidthLimits)
+			if !ok {
+				return nil, e.TypeErr(l, v)
+			}
+
+			buf := new(bytes.Buffer)
+			tw := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(tw, "Scope\tIn\tOut\tMatching")
+			fmt.Fprintf(tw, "total\t%s\t%s\t\n", bwRate(l.Total.In), bwRate(l.Total.Out))
+			fmt.Fprintf(tw, "peer\t%s\t%s\t\n", bwRate(l.Peer.In), bwRate(l.Peer.Out))
+
+			names := make([]string, 0, len(l.Classes))
+			for name := range l.Classes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				c := l.Classes[name]
+				var matching []string
+				if len(c.Protocols) > 0 {
+					matching = append(matching, "protocols="+strings.Join(c.Protocols, ","))
+				}
+				if len(c.Peers) > 0 {
+					matching = append(matching, "peers="+strings.Join(c.Peers, ","))
+				}
+				if c.PerPeer {
+					matching = append(matching, "per peer")
+				}
+				fmt.Fprintf(tw, "class:%s\t%s\t%s\t%s\n", name, bwRate(c.In), bwRate(c.Out), strings.Join(matching, " "))
+			}
+			tw.Flush()
+			return buf, nil
+		},
+	},
+	Type: extconfig.BandwidthLimits{},
+}
+
+func bwRate(r string) string {
+	if r == "" {
+		return "unlimited"
+	}
+	return r
+}
+
+// bwSetLimit returns a copy of limits with the limit of scope replaced by c,
+// or removed if reset is set.
+func bwSetLimit(limits extconfig.BandwidthLimits, scope string, c extconfig.BandwidthClass, reset bool) (extconfig.BandwidthLimits, error) {
+	for _, rate := range []string{c.In, c.Out} {
+		if _, err := bandwidth.ParseRate(rate); err != nil {
+			return limits, err
+		}
+	}
+
+	switch {
+	case scope == "total" || scope == "peer":
+		if reset {
+			return limits, fmt.Errorf("--reset only applies to classes")
+		}
+		if len(c.Protocols) > 0 || len(c.Peers) > 0 || c.PerPeer {
+			return limits, fmt.Errorf("the %s scope only has rates", scope)
+		}
+		if scope == "total" {
+			limits.Total = c.BandwidthRates
+		} else {
+			limits.Peer = c.BandwidthRates
+		}
+	case strings.HasPrefix(scope, "class:") && len(scope) > len("class:"):
+		name := strings.TrimPrefix(scope, "class:")
+		classes := make(map[string]extconfig.BandwidthClass, len(limits.Classes)+1)
+		for k, v := range limits.Classes {
+			classes[k] = v
+		}
+		if reset {
+			delete(classes, name)
+		} else {
+			classes[name] = c
+		}
+		limits.Classes = classes
+	default:
+		return limits, fmt.Errorf("invalid scope %q", scope)
+	}
+	return limits, nil
+}
@@ -0,0 +1,298 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	rendezvous "github.com/ipfs/go-ipfs/rendezvous"
+	repo "github.com/ipfs/go-ipfs/repo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+)
+
+type discoveredPeer struct {
+	ID    string
+	Addrs []string
+}
+
+var swarmDiscoverCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Find the peers advertising a rendezvous topic.",
+		ShortDescription: `
+'ipfs swarm discover' finds the peers advertising a topic with
+'ipfs swarm rendezvous add', through the DHT. The topic is any string agreed
+on by the nodes of an application, such as a pubsub topic. The peers are
+printed as they are found.
+
+With --connect, the node also connects to the peers found.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("topic", true, false, "The rendezvous topic."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("verbose", "v", "Print the addresses of the peers."),
+		cmdkit.IntOption("num-peers", "n", "The number of peers to find.").WithDefault(20),
+		cmdkit.BoolOption("connect", "Connect to the peers found."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Rendezvous == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		numPeers, _, err := req.Option("num-peers").Int()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if numPeers < 1 {
+			res.SetError(fmt.Errorf("number of peers must be greater than 0"), cmdkit.ErrClient)
+			return
+		}
+		connect, _, _ := req.Option("connect").Bool()
+
+		peers, err := n.Rendezvous.FindPeers(req.Context(), req.Arguments()[0], numPeers)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+			for pi := range peers {
+				if connect {
+					if err := n.PeerHost.Connect(req.Context(), pi); err != nil {
+						log.Debugf("connecting to %s: %s", pi.ID, err)
+					}
+				}
+
+				dp := &discoveredPeer{ID: pi.ID.Pretty(), Addrs: make([]string, len(pi.Addrs))}
+				for i, a := range pi.Addrs {
+					dp.Addrs[i] = a.String()
+				}
+				select {
+				case outChan <- dp:
+				case <-req.Context().Done():
+					return
+				}
+			}
+		}()
+	},
+	Type: discoveredPeer{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			dp, ok := v.(*discoveredPeer)
+			if !ok {
+				return nil, e.TypeErr(dp, v)
+			}
+
+			verbose, _, _ := res.Request().Option("verbose").Bool()
+			buf := new(bytes.Buffer)
+			fmt.Fprintln(buf, dp.ID)
+			if verbose {
+				for _, a := range dp.Addrs {
+					fmt.Fprintf(buf, "\t%s\n", a)
+				}
+			}
+			return buf, nil
+		},
+	},
+}
+
+var swarmRendezvousCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the rendezvous topics advertised by the node.",
+		ShortDescription: `
+'ipfs swarm rendezvous' manages the topics the node advertises, so that the
+other nodes of an application find it with 'ipfs swarm discover'. A topic is
+advertised by providing a CID derived from it in the DHT, again every 12
+hours.
+
+The topics are stored under the "Discovery.Rendezvous.Topics" config key, so
+changes made with these commands persist daemon restarts.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": swarmRendezvousAddCmd,
+		"ls":  swarmRendezvousLsCmd,
+		"rm":  swarmRendezvousRmCmd,
+	},
+}
+
+var swarmRendezvousAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Advertise rendezvous topics.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("topic", true, true, "The topics to advertise.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Rendezvous == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		topics := req.Arguments()
+		for _, topic := range topics {
+			if topic == "" {
+				res.SetError(rendezvous.ErrEmptyTopic, cmdkit.ErrClient)
+				return
+			}
+		}
+		if err := rendezvousUpdate(r, topics, nil); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		output := make([]string, len(topics))
+		for i, topic := range topics {
+			if err := n.Rendezvous.Advertise(topic); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			output[i] = fmt.Sprintf("advertise %q success", topic)
+		}
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmRendezvousLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the advertised rendezvous topics.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Rendezvous == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		res.SetOutput(&stringList{n.Rendezvous.Topics()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var swarmRendezvousRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stop advertising rendezvous topics.",
+		ShortDescription: `
+'ipfs swarm rendezvous rm' stops advertising topics. The advertisements
+already made expire in the DHT within 24 hours.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("topic", true, true, "The topics to stop advertising.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.Rendezvous == nil {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		topics := req.Arguments()
+		if err := rendezvousUpdate(r, nil, topics); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		output := make([]string, len(topics))
+		for i, topic := range topics {
+			output[i] = fmt.Sprintf("remove %q", topic)
+			if n.Rendezvous.Stop(topic) {
+				output[i] += " success"
+			} else {
+				output[i] += " failure: not advertised"
+			}
+		}
+		res.SetOutput(&stringList{output})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+// rendezvousUpdate adds and removes topics from the ones stored in the
+// config.
+func rendezvousUpdate(r repo.Repo, add, rm []string) error {
+	cfg, err := extconfig.LoadRendezvous(r)
+	if err != nil {
+		return err
+	}
+
+	drop := make(map[string]bool, len(add)+len(rm))
+	for _, t := range add {
+		drop[t] = true
+	}
+	for _, t := range rm {
+		drop[t] = true
+	}
+
+	topics := make([]string, 0, len(cfg.Topics)+len(add))
+	for _, t := range cfg.Topics {
+		if !drop[t] {
+			topics = append(topics, t)
+		}
+	}
+	topics = append(topics, add...)
+
+	return r.SetConfigKey(extconfig.RendezvousTopicsKey, topics)
+}
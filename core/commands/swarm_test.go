@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+func TestFormatDialAttempts(t *testing.T) {
+	tcp, err := ma.NewMultiaddr("/ip4/1.2.3.4/tcp/4001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := ma.NewMultiaddr("/ip4/1.2.3.4/tcp/4002/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := formatDialAttempts([]dialAttempt{
+		{Addr: tcp, Err: errors.New("connection refused")},
+		{Addr: ws},
+	})
+	expected := "\n  /ip4/1.2.3.4/tcp/4001 (tcp): connection refused" +
+		"\n  /ip4/1.2.3.4/tcp/4002/ws (tcp/ws): success"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+}
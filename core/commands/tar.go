@@ -6,6 +6,7 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	"github.com/ipfs/go-ipfs/core/coreunix"
 	tar "github.com/ipfs/go-ipfs/tar"
@@ -17,7 +18,13 @@ import (
 
 var TarCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline: "Utility functions for tar files in ipfs.",
+		Tagline: "Utility functions for tar files in ipfs. Deprecated: use 'ipfs archive'.",
+		ShortDescription: `
+The tar commands store the tar headers in their own DAG format, which only
+'ipfs tar cat' reads. They are kept to export the archives added with them;
+use 'ipfs archive add' and 'ipfs archive export' instead, which import the
+archives as UnixFS directories.
+`,
 	},
 
 	Subcommands: map[string]*cmds.Command{
@@ -39,7 +46,7 @@ represent it.
 		cmdkit.FileArg("file", true, false, "Tar file to add.").EnableStdin(),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		nd, err := req.InvocContext().GetNode()
+		nd, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -94,7 +101,7 @@ var tarCatCmd = &cmds.Command{
 		cmdkit.StringArg("path", true, false, "ipfs path of archive to export.").EnableStdin(),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		nd, err := req.InvocContext().GetNode()
+		nd, err := cmdenv.GetRequestNode(req.InvocContext(), req.Options())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
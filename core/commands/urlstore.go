@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
+	chunker "github.com/ipfs/go-ipfs/chunker"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	filestore "github.com/ipfs/go-ipfs/filestore"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 
 	balanced "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/importer/balanced"
 	ihelper "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/importer/helpers"
@@ -15,7 +18,6 @@ import (
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
 	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
-	chunk "gx/ipfs/QmdSeG9s4EQ9TGruJJS9Us38TQDZtMmFGwzTYUDVqNTURm/go-ipfs-chunker"
 )
 
 var urlStoreCmd = &cmds.Command{
@@ -33,8 +35,9 @@ Add URLs to ipfs without storing the data locally.
 The URL provided must be stable and ideally on a web server under your
 control.
 
-The file is added using raw-leaves but otherwise using the default
-settings for 'ipfs add'.
+The file is added using raw-leaves and CIDv1 but otherwise using the default
+settings for 'ipfs add', including the chunker and the hash function set by
+the "Import.UnixFSChunker" and "Import.HashFunction" config keys.
 
 The file is not pinned, so this command should be followed by an 'ipfs
 pin add'.
@@ -54,7 +57,7 @@ time.
 
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		url := req.Arguments[0]
-		n, err := cmdenv.GetNode(env)
+		n, err := cmdenv.GetRequestNode(env, req.Options)
 		if err != nil {
 			return err
 		}
@@ -74,6 +77,16 @@ time.
 
 		useTrickledag, _ := req.Options[trickleOptionName].(bool)
 
+		importCfg, err := extconfig.LoadImport(n.Repo)
+		if err != nil {
+			return err
+		}
+		hashFunStr := strings.ToLower(importCfg.Hash())
+		hashFunCode, ok := mh.Names[hashFunStr]
+		if !ok {
+			return fmt.Errorf("unrecognized hash function: %s", hashFunStr)
+		}
+
 		hreq, err := http.NewRequest("GET", url, nil)
 		if err != nil {
 			return err
@@ -87,8 +100,11 @@ time.
 			return fmt.Errorf("expected code 200, got: %d", hres.StatusCode)
 		}
 
-		chk := chunk.NewSizeSplitter(hres.Body, chunk.DefaultBlockSize)
-		prefix := cid.NewPrefixV1(cid.DagProtobuf, mh.SHA2_256)
+		chk, err := chunker.FromString(hres.Body, importCfg.Chunker())
+		if err != nil {
+			return err
+		}
+		prefix := cid.NewPrefixV1(cid.DagProtobuf, hashFunCode)
 		dbp := &ihelper.DagBuilderParams{
 			Dagserv:    n.DAG,
 			RawLeaves:  true,
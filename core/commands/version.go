@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
 	version "github.com/ipfs/go-ipfs"
@@ -11,9 +14,14 @@ import (
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	"gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
 )
 
+// versionAgent is the name of the implementation in the agent versions of
+// the go-ipfs peers, go-ipfs/<version>/<commit>.
+const versionAgent = "go-ipfs"
+
 type VersionOutput struct {
 	Version string
 	Commit  string
@@ -34,6 +42,9 @@ var VersionCmd = &cmds.Command{
 		cmdkit.BoolOption("repo", "Show repo version."),
 		cmdkit.BoolOption("all", "Show all version information"),
 	},
+	Subcommands: map[string]*cmds.Command{
+		"check": versionCheckCmd,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		res.SetOutput(&VersionOutput{
 			Version: version.CurrentVersionNumber,
@@ -97,3 +108,193 @@ var VersionCmd = &cmds.Command{
 	},
 	Type: VersionOutput{},
 }
+
+// VersionCount is the number of sampled peers running a version.
+type VersionCount struct {
+	Agent   string
+	Version string
+	Count   int
+}
+
+// VersionCheckOutput is the output of 'ipfs version check'.
+type VersionCheckOutput struct {
+	// Local is the version of the node.
+	Local string
+	// Common is the go-ipfs version the most sampled peers run.
+	Common string `json:",omitempty"`
+	// Peers is the number of sampled peers with a known agent version.
+	Peers int
+	// Behind is set when the node is at least --max-behind minor releases
+	// behind Common.
+	Behind   bool
+	Warning  string `json:",omitempty"`
+	Versions []VersionCount
+}
+
+var versionCheckCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Compare the version of the node to the versions of its peers.",
+		ShortDescription: `
+'ipfs version check' samples the agent versions of the peers of the node:
+the connected peers, and the peers it connected to before, during the DHT
+queries for instance. It writes the distribution of the versions, and warns
+when the node is behind the go-ipfs version most peers run by at least
+--max-behind minor releases. Use --enc=json to collect the distribution.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("connected", "Only sample the connected peers."),
+		cmdkit.IntOption("max-behind", "Warn when the node is behind by at least this many minor releases.").WithDefault(1),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if !n.OnlineMode() {
+			res.SetError(ErrNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		connected, _, _ := req.Option("connected").Bool()
+		maxBehind, _, _ := req.Option("max-behind").Int()
+		if maxBehind < 1 {
+			res.SetError(fmt.Errorf("--max-behind must be at least 1"), cmdkit.ErrClient)
+			return
+		}
+
+		peers := n.PeerHost.Network().Peers()
+		if !connected {
+			peers = n.Peerstore.Peers()
+		}
+
+		var agents []string
+		for _, p := range peers {
+			if p == n.Identity {
+				continue
+			}
+			if agent := peerAgent(n.Peerstore.Get, p); agent != "" {
+				agents = append(agents, agent)
+			}
+		}
+
+		res.SetOutput(checkVersion(version.CurrentVersionNumber, agents, maxBehind))
+	},
+	Type: VersionCheckOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+			out, ok := v.(*VersionCheckOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "local version: %s\n", out.Local)
+			fmt.Fprintf(buf, "most deployed version: %s\n", out.Common)
+			if out.Warning != "" {
+				fmt.Fprintf(buf, "WARNING: %s\n", out.Warning)
+			}
+			fmt.Fprintf(buf, "versions of %d peers:\n", out.Peers)
+			for _, vc := range out.Versions {
+				fmt.Fprintf(buf, "%6d  %s/%s\n", vc.Count, vc.Agent, vc.Version)
+			}
+			return buf, nil
+		},
+	},
+}
+
+// peerAgent returns the agent version of p recorded by identify, empty when
+// unknown.
+func peerAgent(get func(peer.ID, string) (interface{}, error), p peer.ID) string {
+	v, err := get(p, "AgentVersion")
+	if err != nil {
+		return ""
+	}
+	s, _ := v.(string)
+	return s
+}
+
+// checkVersion computes the distribution of the agent versions and
+// compares local to the most deployed go-ipfs version.
+func checkVersion(local string, agents []string, maxBehind int) *VersionCheckOutput {
+	out := &VersionCheckOutput{Local: local, Peers: len(agents)}
+
+	counts := make(map[VersionCount]int)
+	for _, a := range agents {
+		parts := strings.SplitN(a, "/", 3)
+		vc := VersionCount{Agent: parts[0]}
+		if len(parts) > 1 {
+			vc.Version = parts[1]
+		}
+		counts[vc]++
+	}
+	for vc, count := range counts {
+		vc.Count = count
+		out.Versions = append(out.Versions, vc)
+	}
+	sort.Slice(out.Versions, func(i, j int) bool {
+		a, b := out.Versions[i], out.Versions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Agent != b.Agent {
+			return a.Agent < b.Agent
+		}
+		return a.Version < b.Version
+	})
+
+	for _, vc := range out.Versions {
+		if vc.Agent == versionAgent {
+			if _, ok := parseVersion(vc.Version); ok {
+				out.Common = vc.Version
+				break
+			}
+		}
+	}
+	if out.Common == "" {
+		return out
+	}
+
+	lv, ok := parseVersion(local)
+	if !ok {
+		return out
+	}
+	cv, _ := parseVersion(out.Common)
+	behind := cv[1] - lv[1]
+	if cv[0] != lv[0] {
+		// a new major release is behind whatever the minor releases
+		behind = (cv[0] - lv[0]) * maxBehind
+	}
+	if behind >= maxBehind {
+		out.Behind = true
+		out.Warning = fmt.Sprintf("this node runs %s, behind %s which most peers run", local, out.Common)
+	}
+	return out
+}
+
+// parseVersion parses the major, minor and patch numbers of a version like
+// 0.4.18-dev.
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
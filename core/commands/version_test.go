@@ -0,0 +1,61 @@
+package commands
+
+import "testing"
+
+func TestCheckVersion(t *testing.T) {
+	agents := []string{
+		"go-ipfs/0.4.17/",
+		"go-ipfs/0.4.17/abcdef",
+		"go-ipfs/0.4.17/",
+		"go-ipfs/0.4.16/",
+		"js-ipfs/0.31.0",
+		"go-ipfs/0.4.18-dev/",
+	}
+
+	out := checkVersion("0.4.18-dev", agents, 1)
+	if out.Peers != len(agents) || out.Common != "0.4.17" || out.Behind {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	if len(out.Versions) != 4 {
+		t.Fatalf("expected 4 versions, got %v", out.Versions)
+	}
+	if top := out.Versions[0]; top.Agent != "go-ipfs" || top.Version != "0.4.17" || top.Count != 3 {
+		t.Fatalf("unexpected most deployed version: %+v", top)
+	}
+
+	out = checkVersion("0.4.15", agents, 1)
+	if !out.Behind || out.Warning == "" {
+		t.Fatalf("expected 0.4.15 to be behind: %+v", out)
+	}
+	out = checkVersion("0.4.17", []string{"go-ipfs/1.0.0/"}, 5)
+	if !out.Behind {
+		t.Fatalf("expected an older major release to be behind: %+v", out)
+	}
+	out = checkVersion("0.4.15", agents, 3)
+	if out.Behind {
+		t.Fatalf("expected 0.4.15 to be within 3 releases: %+v", out)
+	}
+
+	out = checkVersion("0.4.15", []string{"js-ipfs/0.31.0"}, 1)
+	if out.Common != "" || out.Behind {
+		t.Fatalf("expected no go-ipfs version: %+v", out)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for s, exp := range map[string][3]int{
+		"0.4.18-dev": {0, 4, 18},
+		"v1.2.3":     {1, 2, 3},
+		"0.4.17+rc1": {0, 4, 17},
+	} {
+		v, ok := parseVersion(s)
+		if !ok || v != exp {
+			t.Fatalf("parseVersion(%q) = %v, %t", s, v, ok)
+		}
+	}
+	for _, s := range []string{"", "0.4", "a.b.c", "1.2.3.4"} {
+		if _, ok := parseVersion(s); ok {
+			t.Fatalf("expected %q to be invalid", s)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit"
+	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
+)
+
+const webuiSetPathOptionName = "set-path"
+
+// WebUICmd is the 'ipfs webui' command.
+var WebUICmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the WebUI served by the API.",
+		ShortDescription: `
+The API serves the WebUI at /webui, from the path of the WebUI.Path config or
+the WebUI released with go-ipfs. 'ipfs webui pin' fetches and pins a version
+of the WebUI, so that it's served without the network, for instance with
+'ipfs daemon --offline-webui'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"pin":    webuiPinCmd,
+		"export": webuiExportCmd,
+	},
+}
+
+// WebUIPinOutput is the output of 'ipfs webui pin'.
+type WebUIPinOutput struct {
+	Path string
+	Set  bool
+}
+
+var webuiPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Fetch and pin a version of the WebUI.",
+		ShortDescription: `
+'ipfs webui pin' fetches the whole DAG of a WebUI and pins it. The path
+defaults to WebUI.Path. With --set-path, WebUI.Path is set to the pinned
+version, served once the daemon restarts.
+
+To bundle a WebUI for the nodes without network access, write it as a CAR
+file with 'ipfs webui export' on a connected node, and set WebUI.Bundle to the
+file on the others.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", false, false, "The path of the WebUI version to pin. Defaults to WebUI.Path."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(webuiSetPathOptionName, "Set WebUI.Path to the pinned version."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetRequestNode(env, req.Options)
+		if err != nil {
+			return err
+		}
+
+		p, err := webuiPathArg(req, n)
+		if err != nil {
+			return err
+		}
+
+		defer n.Blockstore.PinLock().Unlock()
+
+		pinned, err := corerepo.Pin(n, req.Context, []string{p.String()}, true)
+		if err != nil {
+			return err
+		}
+		out := &WebUIPinOutput{Path: "/ipfs/" + pinned[0].String()}
+
+		if set, _ := req.Options[webuiSetPathOptionName].(bool); set {
+			if err := n.Repo.SetConfigKey(extconfig.WebUIKey+".Path", out.Path); err != nil {
+				return err
+			}
+			out.Set = true
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: WebUIPinOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*WebUIPinOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			fmt.Fprintf(w, "pinned %s\n", out.Path)
+			if out.Set {
+				fmt.Fprintln(w, "set WebUI.Path, restart the daemon to serve it")
+			}
+			return nil
+		}),
+	},
+}
+
+var webuiExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write a version of the WebUI as a CAR file.",
+		ShortDescription: `
+'ipfs webui export' writes the whole DAG of a WebUI to stdout as a CAR file,
+with the WebUI as its single root, to be set as WebUI.Bundle on the nodes
+without network access. The path defaults to WebUI.Path.
+
+Example:
+
+    $ ipfs webui export /ipfs/<webui-hash> > webui.car
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", false, false, "The path of the WebUI version to export. Defaults to WebUI.Path."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetRequestNode(env, req.Options)
+		if err != nil {
+			return err
+		}
+
+		p, err := webuiPathArg(req, n)
+		if err != nil {
+			return err
+		}
+		nd, err := core.Resolve(req.Context, n.Namesys, n.Resolver, p)
+		if err != nil {
+			return err
+		}
+
+		r, w := io.Pipe()
+		go func() {
+			w.CloseWithError(corerepo.WriteCar(req.Context, n.DAG, nd.Cid(), w))
+		}()
+		return res.Emit(r)
+	},
+}
+
+// webuiPathArg returns the path of the WebUI given to a command, WebUI.Path
+// by default.
+func webuiPathArg(req *cmds.Request, n *core.IpfsNode) (path.Path, error) {
+	if len(req.Arguments) > 0 {
+		p, err := path.ParsePath(req.Arguments[0])
+		if err != nil {
+			return "", cmdkit.Errorf(cmdkit.ErrClient, err.Error())
+		}
+		return p, nil
+	}
+
+	cfg, err := extconfig.LoadWebUI(n.Repo)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Path == "" {
+		return "", errors.New("WebUI.Path is not set, give the path of the WebUI")
+	}
+	return path.ParsePath(cfg.Path)
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	connmgr "gx/ipfs/QmW9pfNup4hcWxyMxDGSe25tG9xepvLqqmQUoTDaawzTZe/go-libp2p-connmgr"
+	ifconnmgr "gx/ipfs/QmWGGN1nysi1qgqto31bENwESkmZBY4YGK4sZC3qhnqhSv/go-libp2p-interface-connmgr"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+)
+
+// reloadableConnMgr is a basic connection manager whose limits can change
+// while the node runs. Changing them replaces the basic connection manager
+// by a new one, which is handed the connections and the tags of the old one.
+type reloadableConnMgr struct {
+	ifconnmgr.ConnManager
+
+	lk sync.RWMutex
+}
+
+func newReloadableConnMgr(low, high int, grace time.Duration) *reloadableConnMgr {
+	return &reloadableConnMgr{ConnManager: connmgr.NewConnManager(low, high, grace)}
+}
+
+func (cm *reloadableConnMgr) current() ifconnmgr.ConnManager {
+	cm.lk.RLock()
+	defer cm.lk.RUnlock()
+	return cm.ConnManager
+}
+
+func (cm *reloadableConnMgr) TagPeer(p peer.ID, tag string, v int) {
+	cm.current().TagPeer(p, tag, v)
+}
+
+func (cm *reloadableConnMgr) UntagPeer(p peer.ID, tag string) {
+	cm.current().UntagPeer(p, tag)
+}
+
+func (cm *reloadableConnMgr) GetTagInfo(p peer.ID) *ifconnmgr.TagInfo {
+	return cm.current().GetTagInfo(p)
+}
+
+func (cm *reloadableConnMgr) TrimOpenConns(ctx context.Context) {
+	cm.current().TrimOpenConns(ctx)
+}
+
+// Notifee forwards the notifications to the current connection manager.
+func (cm *reloadableConnMgr) Notifee() inet.Notifiee {
+	return (*connMgrNotifee)(cm)
+}
+
+// setLimits replaces the connection manager by one with the given limits.
+func (cm *reloadableConnMgr) setLimits(net inet.Network, low, high int, grace time.Duration) {
+	cm.lk.Lock()
+	defer cm.lk.Unlock()
+
+	old := cm.ConnManager
+	next := connmgr.NewConnManager(low, high, grace)
+	nn := next.Notifee()
+	for _, c := range net.Conns() {
+		nn.Connected(net, c)
+	}
+	for _, p := range net.Peers() {
+		if info := old.GetTagInfo(p); info != nil {
+			for tag, v := range info.Tags {
+				next.TagPeer(p, tag, v)
+			}
+		}
+	}
+	cm.ConnManager = next
+}
+
+type connMgrNotifee reloadableConnMgr
+
+func (nn *connMgrNotifee) notifee() inet.Notifiee {
+	return (*reloadableConnMgr)(nn).current().Notifee()
+}
+
+func (nn *connMgrNotifee) Listen(n inet.Network, addr ma.Multiaddr) {
+	nn.notifee().Listen(n, addr)
+}
+
+func (nn *connMgrNotifee) ListenClose(n inet.Network, addr ma.Multiaddr) {
+	nn.notifee().ListenClose(n, addr)
+}
+
+func (nn *connMgrNotifee) Connected(n inet.Network, c inet.Conn) {
+	nn.notifee().Connected(n, c)
+}
+
+func (nn *connMgrNotifee) Disconnected(n inet.Network, c inet.Conn) {
+	nn.notifee().Disconnected(n, c)
+}
+
+func (nn *connMgrNotifee) OpenedStream(n inet.Network, s inet.Stream) {
+	nn.notifee().OpenedStream(n, s)
+}
+
+func (nn *connMgrNotifee) ClosedStream(n inet.Network, s inet.Stream) {
+	nn.notifee().ClosedStream(n, s)
+}
+
+// connMgrLimits returns the limits of a basic connection manager config.
+func connMgrLimits(cfg config.ConnMgr) (low, high int, grace time.Duration, err error) {
+	if cfg.Type == "" {
+		// 'default' value is the basic connection manager
+		return config.DefaultConnMgrLowWater, config.DefaultConnMgrHighWater, config.DefaultConnMgrGracePeriod, nil
+	}
+
+	grace, err = time.ParseDuration(cfg.GracePeriod)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("parsing Swarm.ConnMgr.GracePeriod: %s", err)
+	}
+	return cfg.LowWater, cfg.HighWater, grace, nil
+}
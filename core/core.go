@@ -17,18 +17,39 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	version "github.com/ipfs/go-ipfs"
+	autonat "github.com/ipfs/go-ipfs/autonat"
+	bandwidth "github.com/ipfs/go-ipfs/bandwidth"
+	blockcache "github.com/ipfs/go-ipfs/blockcache"
+	coremetrics "github.com/ipfs/go-ipfs/core/coremetrics"
+	corepubsub "github.com/ipfs/go-ipfs/core/corepubsub"
+	dagtransfer "github.com/ipfs/go-ipfs/dagtransfer"
+	denylist "github.com/ipfs/go-ipfs/denylist"
+	diskio "github.com/ipfs/go-ipfs/diskio"
+	dual "github.com/ipfs/go-ipfs/dual"
+	httpfetch "github.com/ipfs/go-ipfs/exchange/httpfetch"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
+	gater "github.com/ipfs/go-ipfs/gater"
+	holepunch "github.com/ipfs/go-ipfs/holepunch"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ipnsrp "github.com/ipfs/go-ipfs/namesys/republisher"
 	p2p "github.com/ipfs/go-ipfs/p2p"
+	peering "github.com/ipfs/go-ipfs/peering"
+	peerstore "github.com/ipfs/go-ipfs/peerstore"
 	pin "github.com/ipfs/go-ipfs/pin"
+	rcmgr "github.com/ipfs/go-ipfs/rcmgr"
+	relay "github.com/ipfs/go-ipfs/relay"
+	rendezvous "github.com/ipfs/go-ipfs/rendezvous"
 	repo "github.com/ipfs/go-ipfs/repo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+	swarmkey "github.com/ipfs/go-ipfs/swarmkey"
+	wss "github.com/ipfs/go-ipfs/wss"
 
 	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
@@ -52,9 +73,6 @@ import (
 	rhost "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/host/routed"
 	identify "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/protocol/identify"
 	ping "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/protocol/ping"
-	bitswap "gx/ipfs/QmUyaGN3WPr3CTLai7DBvMikagK45V4fUi8p8cNRaJQoU1/go-bitswap"
-	bsnet "gx/ipfs/QmUyaGN3WPr3CTLai7DBvMikagK45V4fUi8p8cNRaJQoU1/go-bitswap/network"
-	connmgr "gx/ipfs/QmW9pfNup4hcWxyMxDGSe25tG9xepvLqqmQUoTDaawzTZe/go-libp2p-connmgr"
 	ifconnmgr "gx/ipfs/QmWGGN1nysi1qgqto31bENwESkmZBY4YGK4sZC3qhnqhSv/go-libp2p-interface-connmgr"
 	circuit "gx/ipfs/QmWX6RySJ3yAYmfjLSw1LtRZnDh5oVeA9kM3scNQJkysqa/go-libp2p-circuit"
 	"gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path/resolver"
@@ -64,10 +82,11 @@ import (
 	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
 	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
 	psrouter "gx/ipfs/QmYz19WrC7TskcVrSbH6sdPd1PvPMAnM9dEUnSTz4pwCWG/go-libp2p-pubsub-router"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
 	pnet "gx/ipfs/QmZaQ3K9PRd5sYYoG1xbTGPtd3N7TYiKBRmcBUTsx8HVET/go-libp2p-pnet"
 	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
 	dht "gx/ipfs/QmaXYSwxqJsX3EoGb1ZV2toZ9fXc8hWJPaBW1XAp1h2Tsp/go-libp2p-kad-dht"
-	dhtopts "gx/ipfs/QmaXYSwxqJsX3EoGb1ZV2toZ9fXc8hWJPaBW1XAp1h2Tsp/go-libp2p-kad-dht/opts"
 	yamux "gx/ipfs/QmcsgrV3nCAKjiHKZhKVXWc4oY3WBECJCqahXEMpHeMrev/go-smux-yamux"
 	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 	record "gx/ipfs/QmdHb9aBELnQKTVhvvA3hsQbRgUAwsWUzBP2vZ6Y5FBYvE/go-libp2p-record"
@@ -76,6 +95,7 @@ import (
 	metrics "gx/ipfs/QmdhwKw53CTV8EJSAsR1bpmMT5kXiWBgeAyv1EXeeDiXqR/go-libp2p-metrics"
 	mplex "gx/ipfs/QmdiBZzwGtN2yHJrWD9ojQ7ASS48nv7BcojWLkYd1ZtrV2/go-smux-multiplex"
 	p2phost "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+	ipfsaddr "gx/ipfs/QmePSRaGafvmURQwQkHPDBJsaGwKXC1WpBBHVCQxdr8FPn/go-ipfs-addr"
 	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
 )
 
@@ -84,6 +104,10 @@ const IpnsValidatorTag = "ipns"
 const kReprovideFrequency = time.Hour * 12
 const discoveryConnTimeout = time.Second * 30
 
+// maxPersistedBootstrapPeers is the number of persisted peers the bootstrap
+// picks from, besides the configured bootstrap peers.
+const maxPersistedBootstrapPeers = 32
+
 var log = logging.Logger("core")
 
 type mode int
@@ -123,27 +147,55 @@ type IpfsNode struct {
 	DAG             ipld.DAGService      // the merkle dag service, get/add objects.
 	Resolver        *resolver.Resolver   // the path resolution system
 	Reporter        metrics.Reporter
+	Bandwidth       *bandwidth.Reporter // the bandwidth history, nil if the metrics are disabled
+	Shaper          *bandwidth.Shaper   // the bandwidth limits of the streams
 	Discovery       discovery.Service
 	FilesRoot       *mfs.Root
 	RecordValidator record.Validator
+	Denylist        *denylist.Denylist // the CIDs and paths the node refuses to serve
+	DiskIO          *diskio.Accountant // the blockstore operations of the subsystems
+	BlockCache      *blockcache.Cache  // the caches in front of the blockstore
 
 	// Online
-	PeerHost     p2phost.Host        // the network host (server+client)
-	Bootstrapper io.Closer           // the periodic bootstrapper
-	Routing      routing.IpfsRouting // the routing system. recommend ipfs-dht
-	Exchange     exchange.Interface  // the block exchange + strategy (bitswap)
-	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
-	Ping         *ping.PingService
-	Reprovider   *rp.Reprovider // the value reprovider system
-	IpnsRepub    *ipnsrp.Republisher
-
-	Floodsub *floodsub.PubSub
-	PSRouter *psrouter.PubsubValueStore
-	DHT      *dht.IpfsDHT
-	P2P      *p2p.P2P
-
-	proc goprocess.Process
-	ctx  context.Context
+	PeerHost        p2phost.Host        // the network host (server+client)
+	Bootstrapper    io.Closer           // the periodic bootstrapper
+	BootstrapHealth *BootstrapHealth    // the dial history of the bootstrap peers
+	Routing         routing.IpfsRouting // the routing system. recommend ipfs-dht
+	Exchange        exchange.Interface  // the block exchange + strategy (bitswap)
+	Namesys         namesys.NameSystem  // the name system, resolves paths to hashes
+	Ping            *ping.PingService
+	Reprovider      *rp.Reprovider // the value reprovider system
+	Provider        *rp.OnDemand   // nil unless Reprovider.Strategy is "ondemand"
+	ProvideTracker  *rp.Tracker    // the time each CID was last provided
+	IpnsRepub       *ipnsrp.Republisher
+
+	Floodsub     *floodsub.PubSub
+	PubsubScorer *corepubsub.PeerScorer // nil unless Pubsub.PeerScoring is enabled
+	PSRouter     *psrouter.PubsubValueStore
+	DHT          *dht.IpfsDHT
+	DualDHT      *dual.DHT // the WAN and LAN DHTs, DHT being the WAN one
+	P2P          *p2p.P2P
+	Peering      *peering.PeeringService
+	Rendezvous   *rendezvous.Service  // the topics advertised through the routing
+	HolePunch    *holepunch.Service   // nil if relays or hole punching are disabled
+	DAGTransfer  *dagtransfer.Service // nil unless DAGTransfer.Enabled or DAGTransfer.FetchDAGs
+	WebSocket    *wss.Service         // nil unless listening on /wss addresses
+	RelayService *relay.Service       // nil unless Swarm.RelayService is enabled
+
+	AutoNAT        *autonat.AutoNAT // reachability of the node
+	AutoNATService *autonat.Service // nil if AutoNAT.ServiceMode is "disabled"
+
+	ResourceManager *rcmgr.ResourceManager // nil unless Swarm.ResourceMgr is enabled
+	Gater           *gater.Gater           // nil unless Swarm.ConnGater has rules
+
+	PeerstorePersister *peerstore.Persister // nil unless Swarm.Peerstore.Persist is enabled
+
+	proc   goprocess.Process
+	ctx    context.Context
+	drain  drainState
+	reload reloadState
+
+	discovered discoveryState
 
 	mode         mode
 	localModeSet bool
@@ -155,9 +207,10 @@ type IpfsNode struct {
 type Mounts struct {
 	Ipfs mount.Mount
 	Ipns mount.Mount
+	Mfs  mount.Mount
 }
 
-func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption, pubsub, ipnsps, mplex bool) error {
+func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, exchangeOption ExchangeOption, do DiscoveryOption, pubsub, ipnsps, mplex bool) error {
 	if n.PeerHost != nil { // already online.
 		return errors.New("node already online")
 	}
@@ -182,19 +235,63 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		libp2pOpts = append(libp2pOpts, libp2p.FilterAddresses(f))
 	}
 
+	gcfg, err := extconfig.LoadConnGater(n.Repo)
+	if err != nil {
+		return err
+	}
+	if len(gcfg.Rules) > 0 || gcfg.Default == gater.Deny {
+		n.Gater, err = gater.New(gcfg)
+		if err != nil {
+			return err
+		}
+		libp2pOpts = append(libp2pOpts, libp2p.FilterAddresses(n.Gater.Filters()...))
+	}
+
 	if !cfg.Swarm.DisableBandwidthMetrics {
 		// Set reporter
-		n.Reporter = metrics.NewBandwidthCounter()
+		bwCfg, err := extconfig.LoadBandwidthHistory(n.Repo)
+		if err != nil {
+			return err
+		}
+		n.Bandwidth, err = bandwidth.NewReporter(metrics.NewBandwidthCounter(), n.Repo.Datastore(), bwCfg)
+		if err != nil {
+			return err
+		}
+		n.Bandwidth.Start()
+		n.Reporter = n.Bandwidth
 		libp2pOpts = append(libp2pOpts, libp2p.BandwidthReporter(n.Reporter))
 	}
 
-	swarmkey, err := n.Repo.SwarmKey()
+	// the shaper wraps the muxers without limits too, so that they can be
+	// set while the node runs
+	bwLimits, err := extconfig.LoadBandwidthLimits(n.Repo)
+	if err != nil {
+		return err
+	}
+	n.Shaper, err = bandwidth.NewShaper(bwLimits)
+	if err != nil {
+		return err
+	}
+	n.OnConfigReload(extconfig.BandwidthLimitsKey, n.reloadBandwidthLimits)
+
+	// options of the host dialing back for the AutoNAT service
+	dialerOpts := []libp2p.Option{libp2p.NoListenAddrs, libp2p.DefaultTransports}
+
+	psk, err := n.Repo.SwarmKey()
+	if err != nil {
+		return err
+	}
+
+	forcePnet, err := extconfig.LoadFlag(n.Repo, extconfig.ForcePrivateNetworkKey)
 	if err != nil {
 		return err
 	}
+	if psk == nil && (forcePnet.WithDefault(false) || swarmkey.Forced()) {
+		return swarmkey.ErrNoKey
+	}
 
-	if swarmkey != nil {
-		protec, err := pnet.NewProtector(bytes.NewReader(swarmkey))
+	if psk != nil {
+		protec, err := pnet.NewProtector(bytes.NewReader(psk))
 		if err != nil {
 			return fmt.Errorf("failed to configure private network: %s", err)
 		}
@@ -219,6 +316,12 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		}()
 
 		libp2pOpts = append(libp2pOpts, libp2p.PrivateNetwork(protec))
+		dialerOpts = append(dialerOpts, libp2p.PrivateNetwork(protec))
+	}
+
+	tcfg, err := extconfig.LoadTransports(n.Repo)
+	if err != nil {
+		return err
 	}
 
 	addrsFactory, err := makeAddrsFactory(cfg.Addresses)
@@ -228,6 +331,30 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	if !cfg.Swarm.DisableRelay {
 		addrsFactory = composeAddrsFactory(addrsFactory, filterRelayAddrs)
 	}
+
+	listenAddrs, err := listenAddresses(cfg)
+	if err != nil {
+		return err
+	}
+	if wssAddrs, _ := wss.SplitAddrs(listenAddrs); len(wssAddrs) > 0 {
+		wscfg := tcfg.WebSocket
+		n.WebSocket, err = wss.NewService(wss.Config{
+			Domain:    wscfg.Domain,
+			CertFile:  wscfg.CertFile,
+			KeyFile:   wscfg.KeyFile,
+			AutoTLS:   wscfg.AutoTLS.Enabled,
+			AcceptTOS: wscfg.AutoTLS.AcceptTOS,
+			Email:     wscfg.AutoTLS.Email,
+			CA:        wscfg.AutoTLS.CA,
+			Datastore: n.Repo.Datastore(),
+		}, wssAddrs)
+		if err != nil {
+			return err
+		}
+		addrsFactory = composeAddrsFactory(addrsFactory, n.WebSocket.RewriteAddrs)
+		libp2pOpts = append(libp2pOpts, libp2p.Transport(n.WebSocket.Transport))
+		dialerOpts = append(dialerOpts, libp2p.Transport(n.WebSocket.Transport))
+	}
 	libp2pOpts = append(libp2pOpts, libp2p.AddrsFactory(addrsFactory))
 
 	connm, err := constructConnMgr(cfg.Swarm.ConnMgr)
@@ -235,8 +362,20 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		return err
 	}
 	libp2pOpts = append(libp2pOpts, libp2p.ConnectionManager(connm))
+	if rcm, ok := connm.(*reloadableConnMgr); ok {
+		n.OnConfigReload("Swarm.ConnMgr", func() error {
+			return n.reloadConnMgr(rcm)
+		})
+	}
 
-	libp2pOpts = append(libp2pOpts, makeSmuxTransportOption(mplex))
+	libp2pOpts = append(libp2pOpts, makeSmuxTransportOption(mplex, func(tpt smux.Transport) smux.Transport {
+		tpt = n.Shaper.Muxer(tpt)
+		if n.Gater != nil {
+			// the denied connections don't reach the shaper
+			tpt = n.Gater.Muxer(tpt)
+		}
+		return tpt
+	}))
 
 	if !cfg.Swarm.DisableNatPortMap {
 		libp2pOpts = append(libp2pOpts, libp2p.NATPortMap())
@@ -255,17 +394,38 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	// explicitly enable the default transports
 	libp2pOpts = append(libp2pOpts, libp2p.DefaultTransports)
 
-	if cfg.Experimental.QUIC {
+	// QUIC used to be enabled by Experimental.QUIC, it is now on unless
+	// disabled with Swarm.Transports.Network.QUIC.
+	if tcfg.Network.QUIC.WithDefault(true) {
 		libp2pOpts = append(libp2pOpts, libp2p.Transport(quic.NewTransport))
+		dialerOpts = append(dialerOpts, libp2p.Transport(quic.NewTransport))
 	}
 
+	// the hole punching service dials the peers on the punched addresses
+	// through the peerstore
+	n.Peerstore = holepunch.Peerstore(n.Peerstore)
 	peerhost, err := hostOption(ctx, n.Identity, n.Peerstore, libp2pOpts...)
 
 	if err != nil {
 		return err
 	}
 
-	if err := n.startOnlineServicesWithHost(ctx, peerhost, routingOption, pubsub, ipnsps); err != nil {
+	rmcfg, err := extconfig.LoadResourceMgr(n.Repo)
+	if err != nil {
+		return err
+	}
+	if rmcfg.Enabled {
+		n.ResourceManager = rcmgr.NewResourceManager(peerhost.Network(), rmcfg.Limits)
+	}
+	if n.Gater != nil {
+		n.Gater.Enforce(peerhost.Network())
+	}
+
+	if err := n.startPeerstorePersister(peerhost.Network()); err != nil {
+		return err
+	}
+
+	if err := n.startOnlineServicesWithHost(ctx, peerhost, routingOption, exchangeOption, pubsub, ipnsps); err != nil {
 		return err
 	}
 
@@ -275,6 +435,54 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	}
 
 	n.P2P = p2p.NewP2P(n.Identity, n.PeerHost, n.Peerstore)
+	if cfg.Experimental.Libp2pStreamMounting {
+		if err := n.startP2PForwards(); err != nil {
+			return err
+		}
+	}
+
+	if err := n.startPeering(); err != nil {
+		return err
+	}
+
+	if err := n.startRendezvous(); err != nil {
+		return err
+	}
+
+	holePunching, err := extconfig.LoadFlag(n.Repo, extconfig.EnableHolePunchingKey)
+	if err != nil {
+		return err
+	}
+	if !cfg.Swarm.DisableRelay && holePunching.WithDefault(true) {
+		n.HolePunch = holepunch.NewService(n.PeerHost)
+	}
+
+	dtcfg, err := extconfig.LoadDAGTransfer(n.Repo)
+	if err != nil {
+		return err
+	}
+	if dtcfg.Enabled || dtcfg.FetchDAGs {
+		bs := denylist.Blockstore(n.Blockstore, n.Denylist, "dagtransfer")
+		n.DAGTransfer = dagtransfer.NewService(n.PeerHost, n.Routing, bs, dtcfg)
+	}
+
+	relayCfg, err := extconfig.LoadRelayService(n.Repo)
+	if err != nil {
+		return err
+	}
+	if relayCfg.Enabled {
+		n.RelayService, err = relay.NewService(n.PeerHost, relayCfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := n.startAutoNAT(ctx, dialerOpts); err != nil {
+		return err
+	}
+	if n.DualDHT != nil {
+		n.DualDHT.WatchReachability(n.AutoNAT)
+	}
 
 	// setup local discovery
 	if do != nil {
@@ -287,23 +495,214 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		}
 	}
 
+	if err := n.startBootstrapCheck(); err != nil {
+		return err
+	}
+
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
+// startPeerstorePersister reads the peers persisted in the datastore into
+// the peerstore, and starts persisting it.
+func (n *IpfsNode) startPeerstorePersister(net inet.Network) error {
+	cfg, err := extconfig.LoadPeerstore(n.Repo)
+	if err != nil {
+		return err
+	}
+	if !cfg.Persist.WithDefault(true) {
+		return nil
+	}
+
+	p, err := peerstore.NewPersister(n.Identity, n.Peerstore, n.Repo.Datastore(), net, cfg)
+	if err != nil {
+		return err
+	}
+	count, err := p.Load()
+	if err != nil {
+		return fmt.Errorf("loading the persisted peers: %s", err)
+	}
+	log.Debugf("loaded %d persisted peers", count)
+
+	p.Start()
+	n.PeerstorePersister = p
+	return nil
+}
+
+// startPeering starts maintaining connections to the peers listed in the
+// Peering.Peers config.
+func (n *IpfsNode) startPeering() error {
+	cfg, err := extconfig.LoadPeering(n.Repo)
+	if err != nil {
+		return err
+	}
+
+	n.Peering = peering.NewPeeringService(n.PeerHost)
+	for _, p := range cfg.Peers {
+		pi, err := p.PeerInfo()
+		if err != nil {
+			return err
+		}
+		n.Peering.AddPeer(pi)
+	}
+	n.OnConfigReload("Peering", n.reloadPeering)
+	return n.Peering.Start()
+}
+
+// startRendezvous starts advertising the topics of the
+// Discovery.Rendezvous config.
+func (n *IpfsNode) startRendezvous() error {
+	n.Rendezvous = rendezvous.NewService(n.Identity, n.Routing)
+	n.OnConfigReload("Discovery.Rendezvous", n.reloadRendezvous)
+	return n.reloadRendezvous()
+}
+
+// loadDenylist reads the local denylist and the ones of the Denylist
+// config, and reloads them periodically. The lists that can't be read don't
+// fail the startup.
+func (n *IpfsNode) loadDenylist() error {
+	cfg, err := extconfig.LoadDenylist(n.Repo)
+	if err != nil {
+		return err
+	}
+
+	interval := extconfig.DefaultDenylistRefreshInterval
+	if cfg.RefreshInterval != "" {
+		interval = cfg.RefreshInterval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("parsing Denylist.RefreshInterval: %s", err)
+	}
+
+	// the URLs are fetched in the background, not to delay the startup
+	n.Denylist = denylist.New(n.Repo.Datastore(), n.denylistSources(cfg))
+	if err := n.Denylist.LoadFiles(); err != nil {
+		log.Error(err)
+	}
+	if n.OnlineMode() {
+		// the errors are logged by Load
+		go n.Denylist.Load()
+	}
+	n.Denylist.Start(d)
+	n.OnConfigReload("Denylist", n.reloadDenylist)
+	return nil
+}
+
+// denylistSources returns the files, relative to the repo, and the URLs of
+// the Denylist config. The offline nodes don't fetch the URLs.
+func (n *IpfsNode) denylistSources(cfg *extconfig.Denylist) []string {
+	sources := make([]string, 0, len(cfg.Files)+len(cfg.URLs))
+	for _, f := range cfg.Files {
+		if r, ok := n.Repo.(interface{ Path() string }); ok && !filepath.IsAbs(f) {
+			f = filepath.Join(r.Path(), f)
+		}
+		sources = append(sources, f)
+	}
+	if !n.OnlineMode() {
+		return sources
+	}
+	return append(sources, cfg.URLs...)
+}
+
+// startP2PForwards creates the p2p forwards and listeners of the
+// P2P.Forwards config. The ones that can't be created don't fail the
+// startup, they are reported by 'ipfs p2p ls'.
+func (n *IpfsNode) startP2PForwards() error {
+	cfg, err := extconfig.LoadP2P(n.Repo)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range cfg.Forwards {
+		if err := n.startP2PForward(f); err != nil {
+			log.Errorf("creating p2p forward %s: %s", f.Protocol, err)
+			n.P2P.Failed = append(n.P2P.Failed, p2p.FailedListener{
+				Protocol:      protocol.ID(f.Protocol),
+				ListenAddress: f.ListenAddress,
+				TargetAddress: f.TargetAddress,
+				AllowedPeers:  f.AllowPeers,
+				Err:           err,
+			})
+		}
+	}
+	return nil
+}
+
+func (n *IpfsNode) startP2PForward(f extconfig.P2PForward) error {
+	proto := protocol.ID(f.Protocol)
+
+	target, err := ma.NewMultiaddr(f.TargetAddress)
+	if err != nil {
+		return err
+	}
+
+	if f.IsListener() {
+		var allowed []peer.ID
+		for _, p := range f.AllowPeers {
+			id, err := peer.IDB58Decode(p)
+			if err != nil {
+				return fmt.Errorf("invalid peer ID %q: %s", p, err)
+			}
+			allowed = append(allowed, id)
+		}
+		_, err = n.P2P.ForwardRemote(n.Context(), proto, target, allowed)
+		return err
+	}
+
+	listen, err := ma.NewMultiaddr(f.ListenAddress)
+	if err != nil {
+		return err
+	}
+	addr, err := ipfsaddr.ParseMultiaddr(target)
+	if err != nil {
+		return err
+	}
+	n.Peerstore.AddAddr(addr.ID(), addr.Multiaddr(), pstore.TempAddrTTL)
+
+	_, err = n.P2P.ForwardLocal(n.Context(), addr.ID(), proto, listen)
+	return err
+}
+
+func (n *IpfsNode) startAutoNAT(ctx context.Context, dialerOpts []libp2p.Option) error {
+	cfg, err := extconfig.LoadAutoNAT(n.Repo)
+	if err != nil {
+		return err
+	}
+
+	n.AutoNAT = autonat.New(n.PeerHost)
+
+	switch cfg.ServiceMode {
+	case "", extconfig.AutoNATServiceEnabled:
+	case extconfig.AutoNATServiceDisabled:
+		return nil
+	default:
+		return fmt.Errorf("unrecognized AutoNAT.ServiceMode: %q", cfg.ServiceMode)
+	}
+
+	// dial backs come from a throwaway identity so that they can't reuse
+	// the connections of the node.
+	dialer, err := libp2p.New(ctx, dialerOpts...)
+	if err != nil {
+		return err
+	}
+	n.AutoNATService, err = autonat.NewService(n.PeerHost, dialer, cfg.Throttle)
+	if err != nil {
+		dialer.Close()
+		return err
+	}
+	return nil
+}
+
 func constructConnMgr(cfg config.ConnMgr) (ifconnmgr.ConnManager, error) {
 	switch cfg.Type {
-	case "":
-		// 'default' value is the basic connection manager
-		return connmgr.NewConnManager(config.DefaultConnMgrLowWater, config.DefaultConnMgrHighWater, config.DefaultConnMgrGracePeriod), nil
 	case "none":
 		return nil, nil
-	case "basic":
-		grace, err := time.ParseDuration(cfg.GracePeriod)
+	case "", "basic":
+		low, high, grace, err := connMgrLimits(cfg)
 		if err != nil {
-			return nil, fmt.Errorf("parsing Swarm.ConnMgr.GracePeriod: %s", err)
+			return nil, err
 		}
-
-		return connmgr.NewConnManager(cfg.LowWater, cfg.HighWater, grace), nil
+		return newReloadableConnMgr(low, high, grace), nil
 	default:
 		return nil, fmt.Errorf("unrecognized ConnMgr.Type: %q", cfg.Type)
 	}
@@ -321,11 +720,14 @@ func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 	case "all":
 		fallthrough
 	case "":
-		keyProvider = rp.NewBlockstoreProvider(n.Blockstore)
+		keyProvider = rp.NewBlockstoreProvider(diskio.Blockstore(n.Blockstore, n.DiskIO.Subsystem(diskio.Reprovider)))
 	case "roots":
 		keyProvider = rp.NewPinnedProvider(n.Pinning, n.DAG, true)
 	case "pinned":
 		keyProvider = rp.NewPinnedProvider(n.Pinning, n.DAG, false)
+	case "ondemand":
+		keyProvider = n.Provider.KeyProvider()
+		go n.Provider.Run(ctx)
 	default:
 		return fmt.Errorf("unknown reprovider strategy '%s'", cfg.Reprovider.Strategy)
 	}
@@ -346,6 +748,72 @@ func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 	return nil
 }
 
+// setupHTTPRetrieval makes the exchange retrieve the blocks from the trustless
+// gateways of the HTTPRetrieval config too.
+func (n *IpfsNode) setupHTTPRetrieval() error {
+	cfg, err := extconfig.LoadHTTPRetrieval(n.Repo)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Gateways) == 0 {
+		return nil
+	}
+
+	parse := func(name, s string, def time.Duration) (time.Duration, error) {
+		if s == "" {
+			return def, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid HTTPRetrieval.%s: %s", name, err)
+		}
+		return d, nil
+	}
+	delay, err := parse("Delay", cfg.Delay, httpfetch.DefaultDelay)
+	if err != nil {
+		return err
+	}
+	timeout, err := parse("Timeout", cfg.Timeout, httpfetch.DefaultTimeout)
+	if err != nil {
+		return err
+	}
+
+	client, err := httpfetch.NewClient(cfg.Gateways, timeout)
+	if err != nil {
+		return err
+	}
+	n.Exchange = httpfetch.NewExchange(n.Exchange, client, delay, cfg.Concurrency)
+	return nil
+}
+
+// setupOnDemandProvider creates the provider of the "ondemand" reprovider
+// strategy. It must exist before the exchange, which defers its provides to
+// it, and the blockservice, which requests the blocks read by the users.
+func (n *IpfsNode) setupOnDemandProvider() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	if cfg.Reprovider.Strategy != "ondemand" {
+		return nil
+	}
+
+	rcfg, err := extconfig.LoadReprovider(n.Repo)
+	if err != nil {
+		return err
+	}
+	var expiry time.Duration
+	if rcfg.OnDemandExpiry != "" {
+		expiry, err = time.ParseDuration(rcfg.OnDemandExpiry)
+		if err != nil {
+			return fmt.Errorf("invalid Reprovider.OnDemandExpiry: %s", err)
+		}
+	}
+
+	n.Provider, err = rp.NewOnDemand(n.Repo.Datastore(), n.Routing, expiry)
+	return err
+}
+
 func makeAddrsFactory(cfg config.Addresses) (p2pbhost.AddrsFactory, error) {
 	var annAddrs []ma.Multiaddr
 	for _, addr := range cfg.Announce {
@@ -392,7 +860,9 @@ func makeAddrsFactory(cfg config.Addresses) (p2pbhost.AddrsFactory, error) {
 	}, nil
 }
 
-func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
+// makeSmuxTransportOption returns the option of the muxers, each wrapped by
+// wrap.
+func makeSmuxTransportOption(mplexExp bool, wrap func(smux.Transport) smux.Transport) libp2p.Option {
 	const yamuxID = "/yamux/1.0.0"
 	const mplexID = "/mplex/6.7.0"
 
@@ -428,38 +898,15 @@ func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
 			continue
 		}
 		delete(muxers, id)
-		opts = append(opts, libp2p.Muxer(id, tpt))
+		opts = append(opts, libp2p.Muxer(id, wrap(tpt)))
 	}
 
 	return libp2p.ChainOptions(opts...)
 }
 
-func setupDiscoveryOption(d config.Discovery) DiscoveryOption {
-	if d.MDNS.Enabled {
-		return func(ctx context.Context, h p2phost.Host) (discovery.Service, error) {
-			if d.MDNS.Interval == 0 {
-				d.MDNS.Interval = 5
-			}
-			return discovery.NewMdnsService(ctx, h, time.Duration(d.MDNS.Interval)*time.Second, discovery.ServiceTag)
-		}
-	}
-	return nil
-}
-
-// HandlePeerFound attempts to connect to peer from `PeerInfo`, if it fails
-// logs a warning log.
-func (n *IpfsNode) HandlePeerFound(p pstore.PeerInfo) {
-	log.Warning("trying peer info: ", p)
-	ctx, cancel := context.WithTimeout(n.Context(), discoveryConnTimeout)
-	defer cancel()
-	if err := n.PeerHost.Connect(ctx, p); err != nil {
-		log.Warning("Failed to connect to peer found by discovery: ", err)
-	}
-}
-
 // startOnlineServicesWithHost  is the set of services which need to be
 // initialized with the host and _before_ we start listening.
-func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost.Host, routingOption RoutingOption, pubsub bool, ipnsps bool) error {
+func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost.Host, routingOption RoutingOption, exchangeOption ExchangeOption, pubsub bool, ipnsps bool) error {
 	// setup diagnostics service
 	n.Ping = ping.NewPingService(host)
 
@@ -469,16 +916,38 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 			return err
 		}
 
+		pscfg, err := extconfig.LoadPubsub(n.Repo)
+		if err != nil {
+			return err
+		}
+
+		psopts := []floodsub.Option{
+			floodsub.WithMessageSigning(!pscfg.DisableSigning),
+			floodsub.WithStrictSignatureVerification(pscfg.StrictSignatureVerification),
+		}
+
+		// the scorer reads the streams of the router to learn who sent
+		// each message
+		pshost := host
+		if pscfg.PeerScoring.Enabled {
+			n.PubsubScorer, err = corepubsub.NewPeerScorer(pscfg.PeerScoring)
+			if err != nil {
+				return err
+			}
+			go n.PubsubScorer.Run(ctx)
+			pshost = n.PubsubScorer.Host(host)
+		}
+
 		var service *floodsub.PubSub
 
 		switch cfg.Pubsub.Router {
 		case "":
 			fallthrough
 		case "floodsub":
-			service, err = floodsub.NewFloodSub(ctx, host)
+			service, err = floodsub.NewFloodSub(ctx, pshost, psopts...)
 
 		case "gossipsub":
-			service, err = floodsub.NewGossipSub(ctx, host)
+			service, err = floodsub.NewGossipSub(ctx, pshost, psopts...)
 
 		default:
 			err = fmt.Errorf("Unknown pubsub router %s", cfg.Pubsub.Router)
@@ -487,6 +956,16 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 		if err != nil {
 			return err
 		}
+
+		if err := corepubsub.DefaultValidators.Apply(service, pscfg.TopicValidators, n.PubsubScorer); err != nil {
+			return err
+		}
+
+		for _, topic := range pscfg.PersistentTopics {
+			if err := corepubsub.Relay(ctx, service, topic); err != nil {
+				return fmt.Errorf("subscribing to persistent topic %q: %s", topic, err)
+			}
+		}
 		n.Floodsub = service
 	}
 
@@ -510,9 +989,16 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	//    PSRouter case below.
 	// 3. Introduce some kind of service manager? (my personal favorite but
 	//    that requires a fair amount of work).
-	if dht, ok := r.(*dht.IpfsDHT); ok {
-		n.DHT = dht
+	switch r := r.(type) {
+	case *dht.IpfsDHT:
+		n.DHT = r
+	case *dual.DHT:
+		n.DualDHT = r
+		n.DHT = r.WAN
 	}
+	n.Routing = coremetrics.Routing(r)
+	n.ProvideTracker = rp.NewTracker(n.Repo.Datastore(), n.Routing)
+	n.Routing = n.ProvideTracker
 
 	if ipnsps {
 		n.PSRouter = psrouter.NewPubsubValueStore(
@@ -537,9 +1023,22 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	// Wrap standard peer host with routing system to allow unknown peer lookups
 	n.PeerHost = rhost.Wrap(host, n.Routing)
 
+	if err := n.setupOnDemandProvider(); err != nil {
+		return err
+	}
+
 	// setup exchange service
-	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
-	n.Exchange = bitswap.New(ctx, bitswapNetwork, n.Blockstore)
+	exRouting := n.Routing
+	if n.Provider != nil {
+		exRouting = rp.DeferProvides(n.Routing)
+	}
+	n.Exchange, err = exchangeOption(ctx, n.PeerHost, exRouting, diskio.Blockstore(denylist.Blockstore(n.Blockstore, n.Denylist, "bitswap"), n.DiskIO.Subsystem(diskio.Bitswap)))
+	if err != nil {
+		return err
+	}
+	if err := n.setupHTTPRetrieval(); err != nil {
+		return err
+	}
 
 	size, err := n.getCacheSize()
 	if err != nil {
@@ -639,6 +1138,10 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.FilesRoot)
 	}
 
+	if c, ok := n.Pinning.(io.Closer); ok {
+		closers = append(closers, c)
+	}
+
 	if n.Exchange != nil {
 		closers = append(closers, n.Exchange)
 	}
@@ -649,8 +1152,13 @@ func (n *IpfsNode) teardown() error {
 	if n.Mounts.Ipns != nil && !n.Mounts.Ipns.IsActive() {
 		closers = append(closers, mount.Closer(n.Mounts.Ipns))
 	}
+	if n.Mounts.Mfs != nil && !n.Mounts.Mfs.IsActive() {
+		closers = append(closers, mount.Closer(n.Mounts.Mfs))
+	}
 
-	if n.DHT != nil {
+	if n.DualDHT != nil {
+		closers = append(closers, n.DualDHT)
+	} else if n.DHT != nil {
 		closers = append(closers, n.DHT.Process())
 	}
 
@@ -662,6 +1170,56 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.Bootstrapper)
 	}
 
+	if n.Peering != nil {
+		closers = append(closers, n.Peering)
+	}
+
+	if n.Bandwidth != nil {
+		closers = append(closers, n.Bandwidth)
+	}
+
+	if n.Rendezvous != nil {
+		closers = append(closers, n.Rendezvous)
+	}
+
+	if n.Denylist != nil {
+		closers = append(closers, n.Denylist)
+	}
+
+	if n.HolePunch != nil {
+		closers = append(closers, n.HolePunch)
+	}
+
+	if n.DAGTransfer != nil {
+		closers = append(closers, n.DAGTransfer)
+	}
+
+	if n.RelayService != nil {
+		closers = append(closers, n.RelayService)
+	}
+
+	if n.AutoNATService != nil {
+		closers = append(closers, n.AutoNATService)
+	}
+
+	if n.AutoNAT != nil {
+		closers = append(closers, n.AutoNAT)
+	}
+
+	if n.ResourceManager != nil {
+		closers = append(closers, n.ResourceManager)
+	}
+
+	if n.Gater != nil {
+		closers = append(closers, n.Gater)
+	}
+
+	// flushed while the connections are still open, so that the peers are
+	// persisted as seen
+	if n.PeerstorePersister != nil {
+		closers = append(closers, n.PeerstorePersister)
+	}
+
 	if n.PeerHost != nil {
 		closers = append(closers, n.PeerHost)
 	}
@@ -723,15 +1281,42 @@ func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 				log.Warning("failed to parse bootstrap peers from config")
 				return nil
 			}
-			return ps
+			return append(ps, n.persistedBootstrapPeers()...)
 		}
 	}
+	if cfg.Health == nil {
+		cfg.Health = n.BootstrapHealth
+	}
 
 	var err error
 	n.Bootstrapper, err = Bootstrap(n, cfg)
 	return err
 }
 
+// persistedBootstrapPeers returns the most recently seen of the persisted
+// peers, so that the node reconnects to them when it starts even if the
+// bootstrap peers are unreachable. Their addresses are already in the
+// peerstore.
+func (n *IpfsNode) persistedBootstrapPeers() []pstore.PeerInfo {
+	if n.PeerstorePersister == nil {
+		return nil
+	}
+
+	ids, err := n.PeerstorePersister.Peers()
+	if err != nil {
+		log.Warningf("failed to read the persisted peers: %s", err)
+		return nil
+	}
+	if len(ids) > maxPersistedBootstrapPeers {
+		ids = ids[:maxPersistedBootstrapPeers]
+	}
+	pis := make([]pstore.PeerInfo, len(ids))
+	for i, id := range ids {
+		pis[i] = pstore.PeerInfo{ID: id}
+	}
+	return pis
+}
+
 func (n *IpfsNode) loadID() error {
 	if n.Identity != "" {
 		return errors.New("identity already loaded")
@@ -971,27 +1556,19 @@ func startListening(host p2phost.Host, cfg *config.Config) error {
 	return nil
 }
 
-func constructDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.Batching, validator record.Validator) (routing.IpfsRouting, error) {
-	return dht.New(
-		ctx, host,
-		dhtopts.Datastore(dstore),
-		dhtopts.Validator(validator),
-	)
-}
-
-func constructClientDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.Batching, validator record.Validator) (routing.IpfsRouting, error) {
-	return dht.New(
-		ctx, host,
-		dhtopts.Client(true),
-		dhtopts.Datastore(dstore),
-		dhtopts.Validator(validator),
-	)
+// constructDualDHTRouting returns the RoutingOption of the WAN and LAN DHTs
+// in the given mode of package dual.
+func constructDualDHTRouting(mode string) RoutingOption {
+	return func(ctx context.Context, host p2phost.Host, dstore ds.Batching, validator record.Validator) (routing.IpfsRouting, error) {
+		return dual.New(ctx, host, dstore, validator, mode)
+	}
 }
 
 type RoutingOption func(context.Context, p2phost.Host, ds.Batching, record.Validator) (routing.IpfsRouting, error)
 
 type DiscoveryOption func(context.Context, p2phost.Host) (discovery.Service, error)
 
-var DHTOption RoutingOption = constructDHTRouting
-var DHTClientOption RoutingOption = constructClientDHTRouting
+var DHTOption RoutingOption = constructDualDHTRouting(dual.ModeServer)
+var DHTClientOption RoutingOption = constructDualDHTRouting(dual.ModeClient)
+var DHTAutoOption RoutingOption = constructDualDHTRouting(dual.ModeAuto)
 var NilRouterOption RoutingOption = nilrouting.ConstructNilRouting
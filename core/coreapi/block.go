@@ -13,6 +13,7 @@ import (
 
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
 )
 
 type BlockAPI CoreAPI
@@ -51,7 +52,37 @@ func (api *BlockAPI) Put(ctx context.Context, src io.Reader, opts ...caopts.Bloc
 	return &BlockStat{path: coreiface.IpldPath(b.Cid()), size: len(data)}, nil
 }
 
+func (api *BlockAPI) PutMany(ctx context.Context, data [][]byte, opts ...caopts.BlockPutOption) ([]coreiface.BlockStat, error) {
+	_, pref, err := caopts.BlockPutOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	bs := make([]blocks.Block, len(data))
+	stats := make([]coreiface.BlockStat, len(data))
+	for i, d := range data {
+		bcid, err := pref.Sum(d)
+		if err != nil {
+			return nil, err
+		}
+
+		bs[i], err = blocks.NewBlockWithCid(d, bcid)
+		if err != nil {
+			return nil, err
+		}
+		stats[i] = &BlockStat{path: coreiface.IpldPath(bcid), size: len(d)}
+	}
+
+	if err := api.node.Blocks.AddBlocks(bs); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
 func (api *BlockAPI) Get(ctx context.Context, p coreiface.Path) (io.Reader, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Block.Get")
+	defer span.Finish()
+
 	rp, err := api.core().ResolvePath(ctx, p)
 	if err != nil {
 		return nil, err
@@ -104,6 +135,9 @@ func (api *BlockAPI) Rm(ctx context.Context, p coreiface.Path, opts ...caopts.Bl
 }
 
 func (api *BlockAPI) Stat(ctx context.Context, p coreiface.Path) (coreiface.BlockStat, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Block.Stat")
+	defer span.Finish()
+
 	rp, err := api.core().ResolvePath(ctx, p)
 	if err != nil {
 		return nil, err
@@ -63,6 +63,38 @@ func TestBlockPutHash(t *testing.T) {
 	}
 }
 
+func TestBlockPutMany(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := api.Block().PutMany(ctx, [][]byte{[]byte(`Hello`), []byte(`World`)}, opt.Block.Format("cbor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(res))
+	}
+	if res[0].Path().Cid().String() != "zdpuAn4amuLWo8Widi5v6VQpuo2dnpnwbVE3oB6qqs7mDSeoa" {
+		t.Errorf("got wrong cid: %s", res[0].Path().Cid().String())
+	}
+
+	r, err := api.Block().Get(ctx, res[1].Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d) != "World" {
+		t.Errorf("got wrong data: %s", string(d))
+	}
+}
+
 func TestBlockGet(t *testing.T) {
 	ctx := context.Background()
 	_, api, err := makeAPI(ctx)
@@ -22,9 +22,24 @@ type CoreAPI struct {
 	node *core.IpfsNode
 }
 
+// Decorator wraps a CoreAPI to change the behavior of some of its methods.
+type Decorator func(coreiface.CoreAPI) coreiface.CoreAPI
+
+var decorators []Decorator
+
+// AddDecorator registers a decorator applied to the CoreAPIs returned by
+// NewCoreAPI, after the ones added before it. Decorators are added by the
+// plugins, before any CoreAPI is created.
+func AddDecorator(d Decorator) {
+	decorators = append(decorators, d)
+}
+
 // NewCoreAPI creates new instance of IPFS CoreAPI backed by go-ipfs Node.
 func NewCoreAPI(n *core.IpfsNode) coreiface.CoreAPI {
-	api := &CoreAPI{n}
+	var api coreiface.CoreAPI = &CoreAPI{n}
+	for _, d := range decorators {
+		api = d(api)
+	}
 	return api
 }
 
@@ -67,3 +82,8 @@ func (api *CoreAPI) Pin() coreiface.PinAPI {
 func (api *CoreAPI) Dht() coreiface.DhtAPI {
 	return (*DhtAPI)(api)
 }
+
+// Routing returns the RoutingAPI interface implementation backed by the go-ipfs node
+func (api *CoreAPI) Routing() coreiface.RoutingAPI {
+	return (*RoutingAPI)(api)
+}
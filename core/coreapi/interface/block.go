@@ -21,6 +21,10 @@ type BlockAPI interface {
 	// Put imports raw block data, hashing it using specified settings.
 	Put(context.Context, io.Reader, ...options.BlockPutOption) (BlockStat, error)
 
+	// PutMany imports several blocks, hashing them using the same settings,
+	// and writes them to the blockstore in one batch.
+	PutMany(context.Context, [][]byte, ...options.BlockPutOption) ([]BlockStat, error)
+
 	// Get attempts to resolve the path and return a reader for data in the block
 	Get(context.Context, Path) (io.Reader, error)
 
@@ -34,6 +34,9 @@ type CoreAPI interface {
 	// Dht returns an implementation of Dht API
 	Dht() DhtAPI
 
+	// Routing returns an implementation of Routing API
+	Routing() RoutingAPI
+
 	// ResolvePath resolves the path using Unixfs resolver
 	ResolvePath(context.Context, Path) (ResolvedPath, error)
 
@@ -0,0 +1,91 @@
+package options
+
+type RoutingValueSettings struct {
+	Offline bool
+}
+
+type RoutingProvideSettings struct {
+	Recursive bool
+}
+
+type RoutingFindProvidersSettings struct {
+	NumProviders int
+}
+
+type RoutingValueOption func(*RoutingValueSettings) error
+type RoutingProvideOption func(*RoutingProvideSettings) error
+type RoutingFindProvidersOption func(*RoutingFindProvidersSettings) error
+
+func RoutingValueOptions(opts ...RoutingValueOption) (*RoutingValueSettings, error) {
+	options := &RoutingValueSettings{
+		Offline: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func RoutingProvideOptions(opts ...RoutingProvideOption) (*RoutingProvideSettings, error) {
+	options := &RoutingProvideSettings{
+		Recursive: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func RoutingFindProvidersOptions(opts ...RoutingFindProvidersOption) (*RoutingFindProvidersSettings, error) {
+	options := &RoutingFindProvidersSettings{
+		NumProviders: 20,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type routingOpts struct{}
+
+var Routing routingOpts
+
+// Offline is an option for Routing.GetValue and Routing.PutValue which
+// specifies whether to only use the records of the local datastore, which
+// lets them work when the node is offline. Default is false
+func (routingOpts) Offline(offline bool) RoutingValueOption {
+	return func(settings *RoutingValueSettings) error {
+		settings.Offline = offline
+		return nil
+	}
+}
+
+// Recursive is an option for Routing.Provide which specifies whether to
+// provide the given path recursively
+func (routingOpts) Recursive(recursive bool) RoutingProvideOption {
+	return func(settings *RoutingProvideSettings) error {
+		settings.Recursive = recursive
+		return nil
+	}
+}
+
+// NumProviders is an option for Routing.FindProviders which specifies the
+// number of peers to look for. Default is 20
+func (routingOpts) NumProviders(numProviders int) RoutingFindProvidersOption {
+	return func(settings *RoutingFindProvidersSettings) error {
+		settings.NumProviders = numProviders
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package options
+
+import (
+	chunker "github.com/ipfs/go-ipfs/chunker"
+)
+
+type UnixfsAddSettings struct {
+	Chunker string
+}
+
+type UnixfsLsSettings struct {
+	ResolveType bool
+	ResolveSize bool
+	ResolveMIME bool
+}
+
+type UnixfsAddOption func(*UnixfsAddSettings) error
+type UnixfsLsOption func(*UnixfsLsSettings) error
+
+func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, error) {
+	options := &UnixfsAddSettings{
+		Chunker: chunker.DefaultChunker,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func UnixfsLsOptions(opts ...UnixfsLsOption) (*UnixfsLsSettings, error) {
+	options := &UnixfsLsSettings{
+		ResolveType: true,
+		ResolveSize: true,
+		ResolveMIME: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type unixfsOpts struct{}
+
+var Unixfs unixfsOpts
+
+// Chunker is an option for Unixfs.Add which specifies the chunker splitting
+// the data into blocks: size-[bytes], rabin, rabin-[avg],
+// rabin-[min]-[avg]-[max] or buzhash. Default is size-262144
+func (unixfsOpts) Chunker(chunk string) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		if err := chunker.Validate(chunk); err != nil {
+			return err
+		}
+		settings.Chunker = chunk
+		return nil
+	}
+}
+
+// ResolveType is an option for Unixfs.Ls which specifies whether to fetch
+// the linked objects to find out their types. When false, the types are only
+// read from the objects stored locally. Default is true
+func (unixfsOpts) ResolveType(resolve bool) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		settings.ResolveType = resolve
+		return nil
+	}
+}
+
+// ResolveSize is an option for Unixfs.Ls which specifies whether to fetch
+// the linked files to find out their sizes. Default is true
+func (unixfsOpts) ResolveSize(resolve bool) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		settings.ResolveSize = resolve
+		return nil
+	}
+}
+
+// ResolveMIME is an option for Unixfs.Ls which specifies whether to fetch
+// the first blocks of the linked files to detect their MIME types. Default
+// is false
+func (unixfsOpts) ResolveMIME(resolve bool) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		settings.ResolveMIME = resolve
+		return nil
+	}
+}
@@ -0,0 +1,27 @@
+package iface
+
+import (
+	"context"
+
+	"github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+// RoutingAPI specifies the interface to the routing layer, whatever the
+// routing system of the node is.
+type RoutingAPI interface {
+	// GetValue returns the best value stored for the key in the routing
+	// system
+	GetValue(context.Context, string, ...options.RoutingValueOption) ([]byte, error)
+
+	// PutValue stores a value for the key in the routing system
+	PutValue(context.Context, string, []byte, ...options.RoutingValueOption) error
+
+	// FindProviders finds peers in the routing system who can provide the
+	// given path
+	FindProviders(context.Context, Path, ...options.RoutingFindProvidersOption) (<-chan pstore.PeerInfo, error)
+
+	// Provide announces to the network that you are providing given values
+	Provide(context.Context, Path, ...options.RoutingProvideOption) error
+}
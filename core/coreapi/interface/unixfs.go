@@ -4,17 +4,43 @@ import (
 	"context"
 	"io"
 
+	options "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+
+	unixfspb "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/pb"
 	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 )
 
+// LsLink is a link of a directory listed by UnixfsAPI.Ls
+type LsLink struct {
+	Link *ipld.Link
+
+	// Type is the unixfs type of the linked object, -1 when it is unknown
+	Type unixfspb.Data_DataType
+
+	// Size is the size of the linked file, 0 when it wasn't resolved
+	Size uint64
+
+	// MIME is the MIME type of the linked file, detected from its first
+	// bytes, "" when it wasn't resolved
+	MIME string
+
+	// Err is set when the listing failed, it is the last value sent
+	Err error
+}
+
 // UnixfsAPI is the basic interface to immutable files in IPFS
 type UnixfsAPI interface {
 	// Add imports the data from the reader into merkledag file
-	Add(context.Context, io.Reader) (ResolvedPath, error)
+	Add(context.Context, io.Reader, ...options.UnixfsAddOption) (ResolvedPath, error)
 
 	// Cat returns a reader for the file
 	Cat(context.Context, Path) (Reader, error)
 
-	// Ls returns the list of links in a directory
-	Ls(context.Context, Path) ([]*ipld.Link, error)
+	// Ls returns the links of a directory, sent on the channel as they are
+	// listed
+	Ls(context.Context, Path, ...options.UnixfsLsOption) (<-chan LsLink, error)
+
+	// MIME returns the MIME type of the file, detected from its first bytes
+	// like the gateway does
+	MIME(context.Context, Path) (string, error)
 }
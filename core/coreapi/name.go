@@ -17,6 +17,7 @@ import (
 	"gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
 	"gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	"gx/ipfs/QmSNe4MWVxZWk6UxxW2z2EKofFo4GdFzud1vfn1iVby3mj/go-ipfs-routing/offline"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
 )
 
 type NameAPI CoreAPI
@@ -38,6 +39,9 @@ func (e *ipnsEntry) Value() coreiface.Path {
 
 // Publish announces new IPNS name and returns the new IPNS entry.
 func (api *NameAPI) Publish(ctx context.Context, p coreiface.Path, opts ...caopts.NamePublishOption) (coreiface.IpnsEntry, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Name.Publish")
+	defer span.Finish()
+
 	options, err := caopts.NamePublishOptions(opts...)
 	if err != nil {
 		return nil, err
@@ -85,6 +89,9 @@ func (api *NameAPI) Publish(ctx context.Context, p coreiface.Path, opts ...caopt
 // Resolve attempts to resolve the newest version of the specified name and
 // returns its path.
 func (api *NameAPI) Resolve(ctx context.Context, name string, opts ...caopts.NameResolveOption) (coreiface.Path, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Name.Resolve")
+	defer span.Finish()
+
 	options, err := caopts.NameResolveOptions(opts...)
 	if err != nil {
 		return nil, err
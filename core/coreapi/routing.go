@@ -0,0 +1,173 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	cidutil "gx/ipfs/QmQJSeE3CX4zos9qeaG8EhecEK9zvrTEfTG84J8C5NVRwt/go-cidutil"
+	offroute "gx/ipfs/QmSNe4MWVxZWk6UxxW2z2EKofFo4GdFzud1vfn1iVby3mj/go-ipfs-routing/offline"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	blockservice "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	blockstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+type RoutingAPI CoreAPI
+
+func (api *RoutingAPI) GetValue(ctx context.Context, key string, opts ...caopts.RoutingValueOption) ([]byte, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Routing.GetValue")
+	defer span.Finish()
+
+	r, err := api.valueRouter(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetValue(ctx, key)
+}
+
+func (api *RoutingAPI) PutValue(ctx context.Context, key string, value []byte, opts ...caopts.RoutingValueOption) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Routing.PutValue")
+	defer span.Finish()
+
+	r, err := api.valueRouter(opts)
+	if err != nil {
+		return err
+	}
+
+	return r.PutValue(ctx, key, value)
+}
+
+// valueRouter returns the router the values are read from and written to:
+// the routing system of the node, or the local datastore in offline mode.
+func (api *RoutingAPI) valueRouter(opts []caopts.RoutingValueOption) (routing.ValueStore, error) {
+	settings, err := caopts.RoutingValueOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	n := api.node
+	if settings.Offline {
+		return offroute.NewOfflineRouter(n.Repo.Datastore(), n.RecordValidator), nil
+	}
+	if !n.OnlineMode() || n.Routing == nil {
+		return nil, coreiface.ErrOffline
+	}
+	return n.Routing, nil
+}
+
+func (api *RoutingAPI) FindProviders(ctx context.Context, p coreiface.Path, opts ...caopts.RoutingFindProvidersOption) (<-chan pstore.PeerInfo, error) {
+	settings, err := caopts.RoutingFindProvidersOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.node.Routing == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	rp, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	numProviders := settings.NumProviders
+	if numProviders < 1 {
+		return nil, fmt.Errorf("number of providers must be greater than 0")
+	}
+
+	pchan := api.node.Routing.FindProvidersAsync(ctx, rp.Cid(), numProviders)
+	return pchan, nil
+}
+
+func (api *RoutingAPI) Provide(ctx context.Context, path coreiface.Path, opts ...caopts.RoutingProvideOption) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Routing.Provide")
+	defer span.Finish()
+
+	settings, err := caopts.RoutingProvideOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	if api.node.Routing == nil {
+		return errors.New("cannot provide in offline mode")
+	}
+
+	rp, err := api.core().ResolvePath(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	c := rp.Cid()
+
+	has, err := api.node.Blockstore.Has(c)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		return fmt.Errorf("block %s not found locally, cannot provide", c)
+	}
+
+	if settings.Recursive {
+		err = provideKeysRec(ctx, api.node.Routing, api.node.Blockstore, []cid.Cid{c})
+	} else {
+		err = provideKeys(ctx, api.node.Routing, []cid.Cid{c})
+	}
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func provideKeys(ctx context.Context, r routing.IpfsRouting, cids []cid.Cid) error {
+	for _, c := range cids {
+		err := r.Provide(ctx, c, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func provideKeysRec(ctx context.Context, r routing.IpfsRouting, bs blockstore.Blockstore, cids []cid.Cid) error {
+	provided := cidutil.NewStreamingSet()
+
+	errCh := make(chan error)
+	go func() {
+		dserv := dag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+		for _, c := range cids {
+			err := dag.EnumerateChildrenAsync(ctx, dag.GetLinksDirect(dserv), c, provided.Visitor(ctx))
+			if err != nil {
+				errCh <- err
+			}
+		}
+	}()
+
+	for {
+		select {
+		case k := <-provided.New:
+			err := r.Provide(ctx, k, true)
+			if err != nil {
+				return err
+			}
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (api *RoutingAPI) core() coreiface.CoreAPI {
+	return (*CoreAPI)(api)
+}
@@ -0,0 +1,43 @@
+package coreapi_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+)
+
+func TestRoutingValueOffline(t *testing.T) {
+	ctx := context.Background()
+	nds, apis, err := makeAPISwarm(ctx, true, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the public key records are validated against the peer ID of the key
+	key := "/pk/" + string(nds[0].Identity)
+	value, err := nds[0].PrivateKey.GetPublic().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = apis[0].Routing().PutValue(ctx, key, value, options.Routing.Offline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := apis[0].Routing().GetValue(ctx, key, options.Routing.Offline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, value) {
+		t.Fatal("got an unexpected value")
+	}
+
+	// the record was only stored locally
+	_, err = apis[1].Routing().GetValue(ctx, key, options.Routing.Offline(true))
+	if err == nil {
+		t.Fatal("expected the record not to be found on the other node")
+	}
+}
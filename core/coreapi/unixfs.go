@@ -5,10 +5,17 @@ import (
 	"io"
 
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	unixfs "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
 	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
+	unixfspb "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/pb"
 
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	blockservice "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
 	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 )
 
@@ -16,8 +23,16 @@ type UnixfsAPI CoreAPI
 
 // Add builds a merkledag node from a reader, adds it to the blockstore,
 // and returns the key representing that node.
-func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader) (coreiface.ResolvedPath, error) {
-	k, err := coreunix.AddWithContext(ctx, api.node, r)
+func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader, opts ...caopts.UnixfsAddOption) (coreiface.ResolvedPath, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Unixfs.Add")
+	defer span.Finish()
+
+	settings, err := caopts.UnixfsAddOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := coreunix.AddWithChunker(ctx, api.node, r, settings.Chunker)
 	if err != nil {
 		return nil, err
 	}
@@ -30,13 +45,19 @@ func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader) (coreiface.ResolvedP
 
 // Cat returns the data contained by an IPFS or IPNS object(s) at path `p`.
 func (api *UnixfsAPI) Cat(ctx context.Context, p coreiface.Path) (coreiface.Reader, error) {
-	dget := api.node.DAG // TODO: use a session here once routing perf issues are resolved
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Unixfs.Cat")
+	defer span.Finish()
 
 	dagnode, err := api.core().ResolveNode(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
+	dget, err := coreunix.ReadAheadDAG(ctx, api.node)
+	if err != nil {
+		return nil, err
+	}
+
 	r, err := uio.NewDagReader(ctx, dagnode, dget)
 	if err == uio.ErrIsDir {
 		return nil, coreiface.ErrIsDir
@@ -46,34 +67,134 @@ func (api *UnixfsAPI) Cat(ctx context.Context, p coreiface.Path) (coreiface.Read
 	return r, nil
 }
 
-// Ls returns the contents of an IPFS or IPNS object(s) at path p, with the format:
-// `<link base58 hash> <link size in bytes> <link name>`
-func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path) ([]*ipld.Link, error) {
+// Ls returns the links of the directory at path p. They are sent on the
+// channel as the directory is read, so that large sharded directories don't
+// have to be fully listed first. The types and sizes of the linked objects
+// are resolved according to the options.
+func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path, opts ...caopts.UnixfsLsOption) (<-chan coreiface.LsLink, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Unixfs.Ls")
+	defer span.Finish()
+
+	settings, err := caopts.UnixfsLsOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	dagnode, err := api.core().ResolveNode(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
-	var ndlinks []*ipld.Link
 	dir, err := uio.NewDirectoryFromNode(api.node.DAG, dagnode)
 	switch err {
 	case nil:
-		l, err := dir.Links(ctx)
-		if err != nil {
-			return nil, err
-		}
-		ndlinks = l
 	case uio.ErrNotADir:
-		ndlinks = dagnode.Links()
+		dir = nil
 	default:
 		return nil, err
 	}
 
-	links := make([]*ipld.Link, len(ndlinks))
-	for i, l := range ndlinks {
-		links[i] = &ipld.Link{Name: l.Name, Size: l.Size, Cid: l.Cid}
+	// without resolution, only the objects stored locally are looked at
+	dserv := api.node.DAG
+	if !settings.ResolveType && !settings.ResolveSize && !settings.ResolveMIME {
+		dserv = dag.NewDAGService(blockservice.New(api.node.Blockstore, offline.Exchange(api.node.Blockstore)))
+	}
+
+	out := make(chan coreiface.LsLink)
+	go func() {
+		defer close(out)
+
+		send := func(l *ipld.Link) error {
+			select {
+			case out <- lsLink(ctx, dserv, l, settings):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		var err error
+		if dir != nil {
+			err = dir.ForEachLink(ctx, send)
+		} else {
+			for _, l := range dagnode.Links() {
+				if err = send(l); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			select {
+			case out <- coreiface.LsLink{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+func lsLink(ctx context.Context, dserv ipld.DAGService, l *ipld.Link, settings *caopts.UnixfsLsSettings) coreiface.LsLink {
+	lnk := coreiface.LsLink{
+		Link: &ipld.Link{Name: l.Name, Size: l.Size, Cid: l.Cid},
+		Type: unixfspb.Data_DataType(-1),
+	}
+
+	switch l.Cid.Type() {
+	case cid.Raw:
+		// no need to fetch the raw leaves, unless for their MIME type
+		lnk.Type = unixfspb.Data_File
+		if settings.ResolveSize {
+			lnk.Size = l.Size
+		}
+		if settings.ResolveMIME {
+			nd, err := l.GetNode(ctx, dserv)
+			if err != nil {
+				lnk.Err = err
+				return lnk
+			}
+			lnk.MIME, lnk.Err = coreunix.DetectMIME(ctx, dserv, nd)
+		}
+	case cid.DagProtobuf:
+		nd, err := l.GetNode(ctx, dserv)
+		if err == ipld.ErrNotFound && !settings.ResolveType && !settings.ResolveSize && !settings.ResolveMIME {
+			// not stored locally
+			return lnk
+		} else if err != nil {
+			lnk.Err = err
+			return lnk
+		}
+
+		pn, ok := nd.(*dag.ProtoNode)
+		if !ok {
+			return lnk
+		}
+		d, err := unixfs.FromBytes(pn.Data())
+		if err != nil {
+			lnk.Err = err
+			return lnk
+		}
+		lnk.Type = d.GetType()
+		if settings.ResolveSize && lnk.Type == unixfspb.Data_File {
+			lnk.Size = d.GetFilesize()
+		}
+		if settings.ResolveMIME && lnk.Type == unixfspb.Data_File {
+			lnk.MIME, lnk.Err = coreunix.DetectMIME(ctx, dserv, nd)
+		}
+	}
+	return lnk
+}
+
+// MIME returns the MIME type of the file at path p, detected from its first
+// bytes, or "" when p isn't a file.
+func (api *UnixfsAPI) MIME(ctx context.Context, p coreiface.Path) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CoreAPI.Unixfs.MIME")
+	defer span.Finish()
+
+	nd, err := api.core().ResolveNode(ctx, p)
+	if err != nil {
+		return "", err
 	}
-	return links, nil
+	return coreunix.DetectMIME(ctx, api.node.DAG, nd)
 }
 
 func (api *UnixfsAPI) core() coreiface.CoreAPI {
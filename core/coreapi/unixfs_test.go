@@ -20,6 +20,8 @@ import (
 	repo "github.com/ipfs/go-ipfs/repo"
 
 	unixfs "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	unixfspb "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/pb"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	cbor "gx/ipfs/QmPrv66vmh2P7vLJMpYx6DWLTNKvVB4Jdkyxs6V3QvWKvf/go-ipld-cbor"
 	ci "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
 	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
@@ -28,6 +30,7 @@ import (
 	mocknet "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/net/mock"
 	mdag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
 	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
 )
 
@@ -175,6 +178,31 @@ func TestAddEmptyFile(t *testing.T) {
 	}
 }
 
+func TestAddChunker(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strings.NewReader(helloStr), options.Unixfs.Chunker("size-4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := api.Object().Links(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != (len(helloStr)+3)/4 {
+		t.Fatalf("expected the file to be split in chunks of 4 bytes, got %d links", len(links))
+	}
+
+	_, err = api.Unixfs().Add(ctx, strings.NewReader(helloStr), options.Unixfs.Chunker("rabin-8-4-2"))
+	if err == nil {
+		t.Fatal("expected an error for an invalid chunker")
+	}
+}
+
 func TestCatBasic(t *testing.T) {
 	ctx := context.Background()
 	node, api, err := makeAPI(ctx)
@@ -330,7 +358,7 @@ func TestLs(t *testing.T) {
 		t.Error(err)
 	}
 
-	links, err := api.Unixfs().Ls(ctx, p)
+	links, err := lsLinks(ctx, api, p)
 	if err != nil {
 		t.Error(err)
 	}
@@ -338,15 +366,93 @@ func TestLs(t *testing.T) {
 	if len(links) != 1 {
 		t.Fatalf("expected 1 link, got %d", len(links))
 	}
-	if links[0].Size != 23 {
-		t.Fatalf("expected size = 23, got %d", links[0].Size)
+	if links[0].Link.Size != 23 {
+		t.Fatalf("expected size = 23, got %d", links[0].Link.Size)
+	}
+	if links[0].Link.Name != "name-of-file" {
+		t.Fatalf("expected name = name-of-file, got %s", links[0].Link.Name)
+	}
+	if links[0].Link.Cid.String() != "QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr" {
+		t.Fatalf("expected cid = QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr, got %s", links[0].Link.Cid)
+	}
+	if links[0].Type != unixfspb.Data_File {
+		t.Fatalf("expected type = File, got %s", links[0].Type)
+	}
+	if links[0].Size != 15 {
+		t.Fatalf("expected file size = 15, got %d", links[0].Size)
+	}
+
+	links, err = lsLinks(ctx, api, p, options.Unixfs.ResolveSize(false))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(links) != 1 || links[0].Size != 0 {
+		t.Fatal("expected the file size not to be resolved")
+	}
+	if links[0].MIME != "" {
+		t.Fatalf("expected the MIME type not to be resolved, got %q", links[0].MIME)
+	}
+
+	links, err = lsLinks(ctx, api, p, options.Unixfs.ResolveMIME(true))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(links) != 1 || links[0].MIME != "text/plain; charset=utf-8" {
+		t.Fatalf("expected the MIME type to be resolved, got %+v", links)
+	}
+
+	mime, err := api.Unixfs().MIME(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mime != "" {
+		t.Fatalf("expected no MIME type for a directory, got %q", mime)
+	}
+}
+
+func TestLsResolveTypeLocal(t *testing.T) {
+	ctx := context.Background()
+	node, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if links[0].Name != "name-of-file" {
-		t.Fatalf("expected name = name-of-file, got %s", links[0].Name)
+
+	// the directory links to an object which isn't stored locally
+	missing, err := cid.Decode("QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr")
+	if err != nil {
+		t.Fatal(err)
 	}
-	if links[0].Cid.String() != "QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr" {
-		t.Fatalf("expected cid = QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr, got %s", links[0].Cid)
+	dir := unixfs.EmptyDirNode()
+	if err := dir.AddRawLink("missing", &ipld.Link{Cid: missing, Size: 23}); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.DAG.Add(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := lsLinks(ctx, api, coreiface.IpfsPath(dir.Cid()), options.Unixfs.ResolveType(false), options.Unixfs.ResolveSize(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].Type != -1 {
+		t.Fatalf("expected the type of the missing object to be unknown, got %v", links)
+	}
+}
+
+func lsLinks(ctx context.Context, api coreiface.CoreAPI, p coreiface.Path, opts ...options.UnixfsLsOption) ([]coreiface.LsLink, error) {
+	out, err := api.Unixfs().Ls(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []coreiface.LsLink
+	for l := range out {
+		if l.Err != nil {
+			return nil, l.Err
+		}
+		links = append(links, l)
 	}
+	return links, nil
 }
 
 func TestLsEmptyDir(t *testing.T) {
@@ -366,7 +472,7 @@ func TestLsEmptyDir(t *testing.T) {
 		t.Error(err)
 	}
 
-	links, err := api.Unixfs().Ls(ctx, coreiface.IpfsPath(emptyDir.Cid()))
+	links, err := lsLinks(ctx, api, coreiface.IpfsPath(emptyDir.Cid()))
 	if err != nil {
 		t.Error(err)
 	}
@@ -394,7 +500,7 @@ func TestLsNonUnixfs(t *testing.T) {
 		t.Error(err)
 	}
 
-	links, err := api.Unixfs().Ls(ctx, coreiface.IpfsPath(nd.Cid()))
+	links, err := lsLinks(ctx, api, coreiface.IpfsPath(nd.Cid()))
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,281 @@
+package coredag
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	ipldcbor "gx/ipfs/QmPrv66vmh2P7vLJMpYx6DWLTNKvVB4Jdkyxs6V3QvWKvf/go-ipld-cbor"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// Codec is an IPLD codec, converting the blocks of its format from and to
+// the IPLD data model. The codecs are usable by 'ipfs dag put', 'ipfs dag
+// get' and the ?format= parameter of the gateway. Their blocks must also have
+// a decoder registered in ipld.DefaultBlockDecoder, so that their paths can
+// be resolved.
+type Codec struct {
+	// Name is the name of the codec in the multicodec table, like dag-json.
+	Name string
+
+	// Code is the multicodec of the CIDs of the blocks of the codec.
+	Code uint64
+
+	// ContentType is the media type of the blocks served by the gateway,
+	// application/vnd.ipld.<Name> when empty.
+	ContentType string
+
+	// Decode decodes the data of a block into a value of the data model.
+	// Without it, the blocks can only be output as they are.
+	Decode func(data []byte) (interface{}, error)
+
+	// Encode encodes a value of the data model. Without it, the codec can
+	// not be converted to.
+	Encode func(v interface{}) ([]byte, error)
+}
+
+// MediaType returns the media type of the blocks of the codec.
+func (c *Codec) MediaType() string {
+	if c.ContentType != "" {
+		return c.ContentType
+	}
+	return "application/vnd.ipld." + c.Name
+}
+
+// Codecs maps the codec names to the codecs.
+type Codecs map[string]*Codec
+
+// DefaultCodecs is the Codecs used everywhere
+var DefaultCodecs = Codecs{
+	"dag-json": {
+		Name:        "dag-json",
+		Code:        DagJSON,
+		ContentType: "application/vnd.ipld.dag-json",
+		Decode:      DecodeDagJSON,
+		Encode:      EncodeDagJSON,
+	},
+	"dag-cbor": {
+		Name:        "dag-cbor",
+		Code:        cid.DagCBOR,
+		ContentType: "application/vnd.ipld.dag-cbor",
+		Decode:      decodeDagCbor,
+		Encode:      encodeDagCbor,
+	},
+	"dag-pb": {
+		Name:        "dag-pb",
+		Code:        cid.DagProtobuf,
+		ContentType: "application/vnd.ipld.dag-pb",
+		Decode:      decodeDagPb,
+		Encode:      encodeDagPb,
+	},
+	"raw": {
+		Name:        "raw",
+		Code:        cid.Raw,
+		ContentType: "application/vnd.ipld.raw",
+		Decode:      decodeRaw,
+		Encode:      encodeRaw,
+	},
+}
+
+// legacyCodecNames are the format names of 'ipfs dag put' that predate the
+// codecs.
+var legacyCodecNames = map[string]string{
+	"cbor":     "dag-cbor",
+	"protobuf": "dag-pb",
+}
+
+// AddCodec registers c under its name, replacing any codec of that name.
+func (cs Codecs) AddCodec(c *Codec) {
+	cs[c.Name] = c
+}
+
+// Get returns the codec named name.
+func (cs Codecs) Get(name string) (*Codec, bool) {
+	if n, ok := legacyCodecNames[name]; ok {
+		name = n
+	}
+	c, ok := cs[name]
+	return c, ok
+}
+
+// ByCode returns the codec of the blocks with the multicodec code.
+func (cs Codecs) ByCode(code uint64) (*Codec, bool) {
+	for _, c := range cs {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// NodeObject returns the content of nd as a value of the data model.
+func (cs Codecs) NodeObject(nd ipld.Node) (interface{}, error) {
+	code := nd.Cid().Type()
+	c, ok := cs.ByCode(code)
+	if !ok {
+		return nil, fmt.Errorf("no codec registered for the multicodec 0x%x", code)
+	}
+	if c.Decode == nil {
+		return nil, fmt.Errorf("the %s codec cannot be converted to other codecs", c.Name)
+	}
+	return c.Decode(nd.RawData())
+}
+
+// EncodeNode returns nd encoded with the codec named name: its data when it
+// is stored with that codec, converted through the data model otherwise.
+func (cs Codecs) EncodeNode(nd ipld.Node, name string) ([]byte, error) {
+	c, ok := cs.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+	if nd.Cid().Type() == c.Code {
+		return nd.RawData(), nil
+	}
+
+	obj, err := cs.NodeObject(nd)
+	if err != nil {
+		return nil, err
+	}
+	return cs.EncodeValue(obj, name)
+}
+
+// EncodeValue encodes v, a value of the data model, with the codec named
+// name.
+func (cs Codecs) EncodeValue(v interface{}, name string) ([]byte, error) {
+	c, ok := cs.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+	if c.Encode == nil {
+		return nil, fmt.Errorf("cannot convert values to the %s codec", c.Name)
+	}
+	return c.Encode(DataModel(v))
+}
+
+// ParseInput decodes the content of r with the codec named in and stores
+// it as a node of the codec named out.
+func (cs Codecs) ParseInput(in, out string, r io.Reader, mhType uint64, mhLen int) ([]ipld.Node, error) {
+	ic, ok := cs.Get(in)
+	if !ok {
+		return nil, fmt.Errorf("no input parser for %q", in)
+	}
+	oc, ok := cs.Get(out)
+	if !ok {
+		return nil, fmt.Errorf("no parser for format %q using input type %q", out, in)
+	}
+	if ic.Decode == nil {
+		return nil, fmt.Errorf("the %s codec cannot be converted to other codecs", ic.Name)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := ic.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data, err = cs.EncodeValue(obj, oc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var c cid.Cid
+	if oc.Code == cid.DagProtobuf {
+		c, err = cidPrefix(mhType, mhLen).Sum(data)
+	} else {
+		if mhType == math.MaxUint64 {
+			mhType = mh.SHA2_256
+		}
+		prefix := cid.Prefix{Version: 1, Codec: oc.Code, MhType: mhType, MhLength: mhLen}
+		c, err = prefix.Sum(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := ipld.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return []ipld.Node{nd}, nil
+}
+
+// DataModel returns v with the links as cid.Cid and the maps keyed by
+// strings, as accepted by the encoders of the codecs.
+func DataModel(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *ipld.Link:
+		return v.Cid
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(k)] = DataModel(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = DataModel(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = DataModel(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func decodeDagCbor(data []byte) (interface{}, error) {
+	var obj interface{}
+	if err := ipldcbor.DecodeInto(data, &obj); err != nil {
+		return nil, err
+	}
+	return DataModel(obj), nil
+}
+
+func encodeDagCbor(v interface{}) ([]byte, error) {
+	return ipldcbor.DumpObject(v)
+}
+
+func decodeDagPb(data []byte) (interface{}, error) {
+	nd, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		return nil, err
+	}
+	return protoNodeObject(nd), nil
+}
+
+func encodeDagPb(v interface{}) ([]byte, error) {
+	nd, err := protoNodeFromObject(v)
+	if err != nil {
+		return nil, err
+	}
+	return nd.EncodeProtobuf(false)
+}
+
+func decodeRaw(data []byte) (interface{}, error) {
+	return data, nil
+}
+
+func encodeRaw(v interface{}) ([]byte, error) {
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw: only bytes can be encoded")
+	}
+	return data, nil
+}
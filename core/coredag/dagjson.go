@@ -0,0 +1,299 @@
+package coredag
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	ipldcbor "gx/ipfs/QmPrv66vmh2P7vLJMpYx6DWLTNKvVB4Jdkyxs6V3QvWKvf/go-ipld-cbor"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// DagJSON is the multicodec of dag-json, the JSON encoding of the IPLD data
+// model where the links are written {"/": "<cid>"} and the bytes
+// {"/": {"bytes": "<unpadded base64>"}}.
+const DagJSON = 0x0129
+
+func init() {
+	ipld.Register(DagJSON, DecodeDagJSONBlock)
+
+	// name the codec in the CIDs, for 'ipfs block put --cid-codec' and
+	// 'ipfs cid format'
+	cid.Codecs["dag-json"] = DagJSON
+	cid.CodecToStr[DagJSON] = "dag-json"
+}
+
+// DagJSONNode is a node encoded in dag-json. It shares the data model of
+// dag-cbor, whose node resolves its paths and lists its links.
+type DagJSONNode struct {
+	*ipldcbor.Node
+
+	raw []byte
+	cid cid.Cid
+}
+
+// NewDagJSONNode encodes obj, a value of the IPLD data model, in dag-json.
+func NewDagJSONNode(obj interface{}, mhType uint64, mhLen int) (*DagJSONNode, error) {
+	obj = DataModel(obj)
+	raw, err := EncodeDagJSON(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if mhType == math.MaxUint64 {
+		mhType = mh.SHA2_256
+	}
+	prefix := cid.Prefix{
+		Version:  1,
+		Codec:    DagJSON,
+		MhType:   mhType,
+		MhLength: mhLen,
+	}
+	c, err := prefix.Sum(raw)
+	if err != nil {
+		return nil, err
+	}
+	return newDagJSONNode(obj, raw, c)
+}
+
+// DecodeDagJSONBlock decodes a dag-json block.
+func DecodeDagJSONBlock(b blocks.Block) (ipld.Node, error) {
+	obj, err := DecodeDagJSON(b.RawData())
+	if err != nil {
+		return nil, err
+	}
+	return newDagJSONNode(obj, b.RawData(), b.Cid())
+}
+
+func newDagJSONNode(obj interface{}, raw []byte, c cid.Cid) (*DagJSONNode, error) {
+	inner, err := ipldcbor.WrapObject(obj, math.MaxUint64, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &DagJSONNode{Node: inner, raw: raw, cid: c}, nil
+}
+
+// RawData returns the dag-json encoding of the node.
+func (n *DagJSONNode) RawData() []byte {
+	return n.raw
+}
+
+// Cid returns the CID of the node.
+func (n *DagJSONNode) Cid() cid.Cid {
+	return n.cid
+}
+
+func (n *DagJSONNode) String() string {
+	return n.cid.String()
+}
+
+// Loggable returns a loggable representation of the node.
+func (n *DagJSONNode) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"node_type": "dag-json",
+		"cid":       n.cid,
+	}
+}
+
+// Size returns the size of the encoded node.
+func (n *DagJSONNode) Size() (uint64, error) {
+	return uint64(len(n.raw)), nil
+}
+
+// Copy returns a copy of the node.
+func (n *DagJSONNode) Copy() ipld.Node {
+	raw := make([]byte, len(n.raw))
+	copy(raw, n.raw)
+	return &DagJSONNode{Node: n.Node.Copy().(*ipldcbor.Node), raw: raw, cid: n.cid}
+}
+
+// MarshalJSON returns the dag-json encoding of the node.
+func (n *DagJSONNode) MarshalJSON() ([]byte, error) {
+	return n.raw, nil
+}
+
+// DecodeDagJSON decodes a dag-json document into a value of the IPLD data
+// model: the links become cid.Cid, the bytes []byte and the integers int64,
+// or uint64 when they overflow int64.
+func DecodeDagJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("dag-json: trailing data after the first value")
+	}
+	return fromJSON(v)
+}
+
+func fromJSON(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if slash, ok := v["/"]; ok && len(v) == 1 {
+			return fromJSONSlash(slash)
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			dm, err := fromJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = dm
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			dm, err := fromJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = dm
+		}
+		return out, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u, nil
+		}
+		return v.Float64()
+	default:
+		return v, nil
+	}
+}
+
+// fromJSONSlash decodes the value of the "/" key of a map holding only it,
+// a link or bytes.
+func fromJSONSlash(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		c, err := cid.Decode(v)
+		if err != nil {
+			return nil, fmt.Errorf("dag-json: invalid link %q: %s", v, err)
+		}
+		return c, nil
+	case map[string]interface{}:
+		s, ok := v["bytes"].(string)
+		if !ok || len(v) != 1 {
+			break
+		}
+		b, err := base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("dag-json: invalid bytes %q: %s", s, err)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("dag-json: a map with the single key \"/\" must be a link or bytes")
+}
+
+// EncodeDagJSON encodes v, a value of the IPLD data model, in dag-json. The
+// encoding is canonical: the keys of the maps are sorted and no whitespace is
+// written.
+func EncodeDagJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeDagJSON(&buf, DataModel(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeDagJSON(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		return encodeJSONString(buf, v)
+	case int:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int8, int16, int32, int64:
+		fmt.Fprintf(buf, "%d", v)
+	case uint, uint8, uint16, uint32, uint64:
+		fmt.Fprintf(buf, "%d", v)
+	case float32:
+		return encodeJSONFloat(buf, float64(v))
+	case float64:
+		return encodeJSONFloat(buf, v)
+	case []byte:
+		buf.WriteString(`{"/":{"bytes":"`)
+		buf.WriteString(base64.RawStdEncoding.EncodeToString(v))
+		buf.WriteString(`"}}`)
+	case cid.Cid:
+		buf.WriteString(`{"/":"`)
+		buf.WriteString(v.String())
+		buf.WriteString(`"}`)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeDagJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSONString(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeDagJSON(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("dag-json: cannot encode values of type %T", v)
+	}
+	return nil
+}
+
+func encodeJSONString(buf *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	// Encode terminates the value with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+func encodeJSONFloat(buf *bytes.Buffer, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("dag-json: cannot encode %v", f)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	// keep the floats apart from the integers when decoding
+	if !bytes.ContainsAny([]byte(s), ".eE") {
+		s += ".0"
+	}
+	buf.WriteString(s)
+	return nil
+}
@@ -1,6 +1,7 @@
 package coredag
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
@@ -64,3 +65,87 @@ func cidPrefix(mhType uint64, mhLen int) *cid.Prefix {
 
 	return prefix
 }
+
+// protoNodeObject represents nd in the IPLD data model, as a map with its
+// Data and its Links, each with its Hash, Name and Tsize.
+func protoNodeObject(nd *merkledag.ProtoNode) map[string]interface{} {
+	links := make([]interface{}, 0, len(nd.Links()))
+	for _, l := range nd.Links() {
+		links = append(links, map[string]interface{}{
+			"Hash":  l.Cid,
+			"Name":  l.Name,
+			"Tsize": l.Size,
+		})
+	}
+	return map[string]interface{}{
+		"Data":  nd.Data(),
+		"Links": links,
+	}
+}
+
+// protoNodeFromObject is the reverse of protoNodeObject.
+func protoNodeFromObject(obj interface{}) (*merkledag.ProtoNode, error) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("dag-pb: expected a map with Data and Links")
+	}
+
+	nd := new(merkledag.ProtoNode)
+	for k, v := range m {
+		switch k {
+		case "Data":
+			data, ok := v.([]byte)
+			if !ok {
+				return nil, fmt.Errorf("dag-pb: Data must be bytes")
+			}
+			nd.SetData(data)
+		case "Links":
+			links, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("dag-pb: Links must be a list")
+			}
+			for _, l := range links {
+				lnk, err := protoLinkFromObject(l)
+				if err != nil {
+					return nil, err
+				}
+				if err := nd.AddRawLink(lnk.Name, lnk); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("dag-pb: unexpected field %q", k)
+		}
+	}
+	return nd, nil
+}
+
+func protoLinkFromObject(obj interface{}) (*ipld.Link, error) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("dag-pb: expected a link with Hash, Name and Tsize")
+	}
+	c, ok := m["Hash"].(cid.Cid)
+	if !ok {
+		return nil, fmt.Errorf("dag-pb: the Hash of a link must be a link")
+	}
+	lnk := &ipld.Link{Cid: c}
+	if name, ok := m["Name"]; ok {
+		if lnk.Name, ok = name.(string); !ok {
+			return nil, fmt.Errorf("dag-pb: the Name of a link must be a string")
+		}
+	}
+	switch size := m["Tsize"].(type) {
+	case nil:
+	case int64:
+		if size < 0 {
+			return nil, fmt.Errorf("dag-pb: the Tsize of a link must be positive")
+		}
+		lnk.Size = uint64(size)
+	case uint64:
+		lnk.Size = size
+	default:
+		return nil, fmt.Errorf("dag-pb: the Tsize of a link must be an integer")
+	}
+	return lnk, nil
+}
@@ -53,8 +53,18 @@ var defaultProtobufParsers = FormatParsers{
 }
 
 // ParseInputs uses DefaultInputEncParsers to parse io.Reader described by
-// input encoding and format to an instance of ipld Node
+// input encoding and format to an instance of ipld Node. The encodings and
+// formats without a parser are looked up in DefaultCodecs, which converts
+// them through the IPLD data model.
 func ParseInputs(ienc, format string, r io.Reader, mhType uint64, mhLen int) ([]ipld.Node, error) {
+	if parsers, ok := DefaultInputEncParsers[ienc]; ok {
+		if _, ok := parsers[format]; ok {
+			return DefaultInputEncParsers.ParseInputs(ienc, format, r, mhType, mhLen)
+		}
+	}
+	if _, ok := DefaultCodecs.Get(ienc); ok {
+		return DefaultCodecs.ParseInput(ienc, format, r, mhType, mhLen)
+	}
 	return DefaultInputEncParsers.ParseInputs(ienc, format, r, mhType, mhLen)
 }
 
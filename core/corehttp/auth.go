@@ -0,0 +1,167 @@
+package corehttp
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+)
+
+// authKey is the context key of the authorization of a request.
+type authKey struct{}
+
+// APIAuthOption requires the tokens of API.Authorizations, when some are
+// configured, for every request of the handlers added after it: the
+// commands, but also /debug/ and /webui. The tokens restricted to some
+// commands, or to the read-only ones, are only allowed those; the other
+// paths need a token allowing everything.
+//
+// The CORS preflights of the commands pass without a token, browsers don't
+// send it with them.
+func APIAuthOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		auth, err := newAPIAuthorizer(n.Repo)
+		if err != nil {
+			return nil, err
+		}
+		if auth == nil {
+			return mux, nil
+		}
+
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if isPreflight(r) && strings.HasPrefix(r.URL.Path, APIPath+"/") {
+				childMux.ServeHTTP(w, r)
+				return
+			}
+
+			a, ok := auth.authorize(w, r)
+			if !ok {
+				return
+			}
+			childMux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authKey{}, a)))
+		})
+		return childMux, nil
+	}
+}
+
+// requestAuthorization returns the authorization APIAuthOption found for r.
+func requestAuthorization(r *http.Request) (extconfig.APIAuthorization, bool) {
+	a, ok := r.Context().Value(authKey{}).(extconfig.APIAuthorization)
+	return a, ok
+}
+
+// isPreflight returns whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// apiAuthorizer checks the bearer tokens of the API requests against the
+// API.Authorizations config.
+type apiAuthorizer struct {
+	auths []extconfig.APIAuthorization
+}
+
+// newAPIAuthorizer returns nil when the API doesn't require a token.
+func newAPIAuthorizer(r extconfig.KeyGetter) (*apiAuthorizer, error) {
+	auths, err := extconfig.LoadAPIAuthorizations(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(auths) == 0 {
+		return nil, nil
+	}
+
+	a := &apiAuthorizer{}
+	for _, auth := range auths {
+		a.auths = append(a.auths, auth)
+	}
+	return a, nil
+}
+
+// readOnly returns whether some tokens are restricted to the read-only API.
+func (a *apiAuthorizer) readOnly() bool {
+	for _, auth := range a.auths {
+		if auth.ReadOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize returns the authorization of the bearer token of the request,
+// or writes the error and returns false when the request isn't allowed.
+func (a *apiAuthorizer) authorize(w http.ResponseWriter, r *http.Request) (extconfig.APIAuthorization, bool) {
+	token, ok := bearerToken(r)
+	var auth extconfig.APIAuthorization
+	if ok {
+		auth, ok = a.find(token)
+	}
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ipfs"`)
+		http.Error(w, "401 - invalid or missing API token", http.StatusUnauthorized)
+		return auth, false
+	}
+
+	if !strings.HasPrefix(r.URL.Path, APIPath+"/") {
+		if len(auth.AllowedCommands) > 0 || auth.ReadOnly {
+			http.Error(w, "403 - path not allowed with this API token", http.StatusForbidden)
+			return auth, false
+		}
+		return auth, true
+	}
+	if !commandAllowed(auth.AllowedCommands, strings.TrimPrefix(r.URL.Path, APIPath)) {
+		http.Error(w, "403 - command not allowed with this API token", http.StatusForbidden)
+		return auth, false
+	}
+	return auth, true
+}
+
+// bearerToken returns the token of the Authorization header of r, false
+// when it is missing or of another scheme.
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	i := strings.IndexByte(h, ' ')
+	if i < 0 || !strings.EqualFold(h[:i], "Bearer") {
+		return "", false
+	}
+	token := strings.TrimLeft(h[i+1:], " ")
+	return token, token != ""
+}
+
+func (a *apiAuthorizer) find(token string) (extconfig.APIAuthorization, bool) {
+	found := -1
+	// compare with all the tokens so that the time taken doesn't tell
+	// which one matched
+	for i, auth := range a.auths {
+		if subtle.ConstantTimeCompare([]byte(auth.Token), []byte(token)) == 1 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return extconfig.APIAuthorization{}, false
+	}
+	return a.auths[found], true
+}
+
+// commandAllowed returns whether the command at path, or one of its parent
+// commands, is in allowed. An empty list allows every command.
+func commandAllowed(allowed []string, path string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	path = "/" + strings.Trim(path, "/")
+	for _, c := range allowed {
+		c = "/" + strings.Trim(c, "/")
+		if c == "/" || path == c || strings.HasPrefix(path, c+"/") {
+			return true
+		}
+	}
+	return false
+}
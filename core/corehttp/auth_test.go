@@ -0,0 +1,114 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+)
+
+func TestCommandAllowed(t *testing.T) {
+	allowed := []string{"/pin", "cat/"}
+	for path, exp := range map[string]bool{
+		"/pin":       true,
+		"/pin/add":   true,
+		"/pin/ls/":   true,
+		"/pinning":   false,
+		"/cat":       true,
+		"/config":    false,
+		"/block/get": false,
+		"/":          false,
+	} {
+		if commandAllowed(allowed, path) != exp {
+			t.Errorf("%s: expected %t", path, exp)
+		}
+	}
+
+	if !commandAllowed(nil, "/config") {
+		t.Error("expected an empty list to allow every command")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	a := &apiAuthorizer{auths: []extconfig.APIAuthorization{
+		{Token: "admin"},
+		{Token: "pinner", AllowedCommands: []string{"/pin"}},
+		{Token: "reader", ReadOnly: true},
+	}}
+
+	for _, tc := range []struct {
+		token    string
+		path     string
+		code     int
+		readOnly bool
+	}{
+		{"", APIPath + "/pin/add", http.StatusUnauthorized, false},
+		{"Bearer wrong", APIPath + "/pin/add", http.StatusUnauthorized, false},
+		{"admin", APIPath + "/config", http.StatusUnauthorized, false},
+		{"Basic admin", APIPath + "/config", http.StatusUnauthorized, false},
+		{"Bearer admin", APIPath + "/config", http.StatusOK, false},
+		{"bearer admin", APIPath + "/config", http.StatusOK, false},
+		{"Bearer pinner", APIPath + "/pin/add", http.StatusOK, false},
+		{"Bearer pinner", APIPath + "/config", http.StatusForbidden, false},
+		{"Bearer reader", APIPath + "/cat", http.StatusOK, true},
+		{"", "/debug/pprof/", http.StatusUnauthorized, false},
+		{"Bearer admin", "/debug/pprof/", http.StatusOK, false},
+		{"Bearer pinner", "/debug/vars", http.StatusForbidden, false},
+		{"Bearer reader", "/webui", http.StatusForbidden, false},
+	} {
+		r := httptest.NewRequest("POST", tc.path, nil)
+		if tc.token != "" {
+			r.Header.Set("Authorization", tc.token)
+		}
+		w := httptest.NewRecorder()
+
+		auth, ok := a.authorize(w, r)
+		if ok != (tc.code == http.StatusOK) || w.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.token, tc.path, tc.code, w.Code)
+			continue
+		}
+		if ok && auth.ReadOnly != tc.readOnly {
+			t.Errorf("%s: expected ReadOnly to be %t", tc.token, tc.readOnly)
+		}
+	}
+
+	if !a.readOnly() {
+		t.Error("expected a read-only token")
+	}
+}
+
+func TestIsPreflight(t *testing.T) {
+	r := httptest.NewRequest("OPTIONS", APIPath+"/cat", nil)
+	if isPreflight(r) {
+		t.Error("expected an OPTIONS request without CORS headers not to be a preflight")
+	}
+	r.Header.Set("Origin", "http://example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	if !isPreflight(r) {
+		t.Error("expected a preflight")
+	}
+	r.Method = "POST"
+	if isPreflight(r) {
+		t.Error("expected a POST not to be a preflight")
+	}
+}
+
+func TestExposeCommands(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := exposeCommands([]string{"/name/resolve", "/dag/get"}, next)
+
+	for path, code := range map[string]int{
+		"/name/resolve":  http.StatusOK,
+		"/dag/get":       http.StatusOK,
+		"/dag/resolve":   http.StatusForbidden,
+		"/cat":           http.StatusForbidden,
+		"/name/resolved": http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", APIPath+path, nil))
+		if w.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, w.Code)
+		}
+	}
+}
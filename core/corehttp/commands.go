@@ -13,9 +13,12 @@ import (
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
 	corecommands "github.com/ipfs/go-ipfs/core/commands"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 
 	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
 	cmdsHttp "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds/http"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
 	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
 	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
 )
@@ -113,7 +116,7 @@ func patchCORSVars(c *cmdsHttp.ServerConfig, addr net.Addr) {
 	c.SetAllowedOrigins(newOrigins...)
 }
 
-func commandsOption(cctx oldcmds.Context, command *cmds.Command) ServeOption {
+func commandsOption(cctx oldcmds.Context, command *cmds.Command, checkAuth bool) ServeOption {
 	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 
 		cfg := cmdsHttp.NewServerConfig()
@@ -129,22 +132,105 @@ func commandsOption(cctx oldcmds.Context, command *cmds.Command) ServeOption {
 		addCORSDefaults(cfg)
 		patchCORSVars(cfg, l.Addr())
 
-		cmdHandler := cmdsHttp.NewHandler(&cctx, command, cfg)
-		mux.Handle(APIPath+"/", cmdHandler)
+		var auth *apiAuthorizer
+		if checkAuth {
+			auth, err = newAPIAuthorizer(n.Repo)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// the blocks read by the commands are requested by the user, for the
+		// node to provide them on demand
+		env := cctx.WithContext(rp.WithRequest(n.Context()))
+
+		handlers := map[*cmds.Command]http.Handler{
+			command: cmdsHttp.NewHandler(env, command, cfg),
+		}
+		if auth != nil && auth.readOnly() {
+			handlers[corecommands.RootRO] = cmdsHttp.NewHandler(env, corecommands.RootRO, cfg)
+		}
+
+		mux.Handle(APIPath+"/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			root := command
+			if auth != nil {
+				// APIAuthOption authorized the request already, unless
+				// it is a preflight or the option isn't used
+				a, ok := requestAuthorization(r)
+				if !ok && !isPreflight(r) {
+					if a, ok = auth.authorize(w, r); !ok {
+						return
+					}
+				}
+				if a.ReadOnly {
+					root = corecommands.RootRO
+				}
+			}
+
+			span := opentracing.SpanFromContext(r.Context())
+			if span == nil {
+				handlers[root].ServeHTTP(w, r)
+				return
+			}
+
+			// commands run with the context of the environment, not the
+			// one of the request, hand them the span through it.
+			span.SetOperationName("api " + strings.TrimPrefix(r.URL.Path, APIPath+"/"))
+			env := env.WithContext(opentracing.ContextWithSpan(env.Context(), span))
+			cmdsHttp.NewHandler(env, root, cfg).ServeHTTP(w, r)
+		}))
 		return mux, nil
 	}
 }
 
 // CommandsOption constructs a ServerOption for hooking the commands into the
-// HTTP server.
+// HTTP server. The requests are checked against API.Authorizations.
 func CommandsOption(cctx oldcmds.Context) ServeOption {
-	return commandsOption(cctx, corecommands.Root)
+	return commandsOption(cctx, corecommands.Root, true)
 }
 
 // CommandsROOption constructs a ServerOption for hooking the read-only commands
-// into the HTTP server.
+// into the HTTP server. They don't require the tokens of API.Authorizations,
+// and are restricted to Gateway.ExposedAPICommands when it's set.
 func CommandsROOption(cctx oldcmds.Context) ServeOption {
-	return commandsOption(cctx, corecommands.RootRO)
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		exposed, err := extconfig.LoadGatewayExposedAPICommands(n.Repo)
+		if err != nil {
+			return nil, err
+		}
+		if exposed == nil {
+			return commandsOption(cctx, corecommands.RootRO, false)(n, l, mux)
+		}
+		if len(exposed) == 0 {
+			// the API isn't served at all
+			return mux, nil
+		}
+
+		for _, c := range exposed {
+			if _, err := corecommands.RootRO.Resolve(strings.Split(strings.Trim(c, "/"), "/")); err != nil {
+				return nil, fmt.Errorf("%s: %q is not a read-only command", extconfig.GatewayExposedAPICommandsKey, c)
+			}
+		}
+
+		child := http.NewServeMux()
+		if _, err := commandsOption(cctx, corecommands.RootRO, false)(n, l, child); err != nil {
+			return nil, err
+		}
+		mux.Handle(APIPath+"/", exposeCommands(exposed, child))
+		return mux, nil
+	}
+}
+
+// exposeCommands only passes the requests of the commands in exposed, or of
+// their subcommands, to next.
+func exposeCommands(exposed []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !commandAllowed(exposed, strings.TrimPrefix(r.URL.Path, APIPath)) {
+			http.Error(w, "403 - command not exposed by the gateway", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 // CheckVersionOption returns a ServeOption that checks whether the client ipfs version matches. Does nothing when the user agent string does not contain `/go-ipfs/`
@@ -0,0 +1,28 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+// DrainOption tracks the requests in flight, so that draining the node waits
+// for them, and refuses the new ones once the node is draining.
+func DrainOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			done, err := n.StartRequest()
+			if err != nil {
+				w.Header().Set("Connection", "close")
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			defer done()
+
+			childMux.ServeHTTP(w, r)
+		})
+		return childMux, nil
+	}
+}
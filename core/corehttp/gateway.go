@@ -30,9 +30,11 @@ func GatewayOption(writable bool, paths ...string) ServeOption {
 			Writable:     writable,
 			PathPrefixes: cfg.Gateway.PathPrefixes,
 		}, coreapi.NewCoreAPI(n))
+		n.OnConfigReload("Gateway", gateway.reloadConfig)
 
+		handler := measureGateway(gateway)
 		for _, p := range paths {
-			mux.Handle(p+"/", gateway)
+			mux.Handle(p+"/", handler)
 		}
 		return mux, nil
 	}
@@ -0,0 +1,74 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	coredag "github.com/ipfs/go-ipfs/core/coredag"
+)
+
+// requestedCodec returns the codec the node at the path of r must be served
+// with, named by the ?format= parameter or matching a media type of the
+// Accept header. It returns nil when the content is requested as files.
+func requestedCodec(r *http.Request) (*coredag.Codec, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		c, ok := coredag.DefaultCodecs.Get(format)
+		if !ok {
+			return nil, fmt.Errorf("unknown format %q", format)
+		}
+		return c, nil
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		for _, c := range coredag.DefaultCodecs {
+			if c.MediaType() == mt {
+				return c, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// serveCodec serves the node at resolvedPath encoded with c.
+func (i *gatewayHandler) serveCodec(ctx context.Context, w http.ResponseWriter, r *http.Request, urlPath string, resolvedPath coreiface.ResolvedPath, c *coredag.Codec) {
+	etag := "\"" + resolvedPath.Cid().String() + "." + c.Name + "\""
+	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	nd, err := i.api.ResolveNode(ctx, resolvedPath)
+	if err != nil {
+		webError(w, "ipfs dag get "+r.URL.EscapedPath(), err, http.StatusNotFound)
+		return
+	}
+
+	data, err := coredag.DefaultCodecs.EncodeNode(nd, c.Name)
+	if err != nil {
+		webError(w, "ipfs dag get --output-codec="+c.Name+" "+r.URL.EscapedPath(), err, http.StatusNotAcceptable)
+		return
+	}
+
+	i.addUserHeaders(w)
+	w.Header().Set("X-IPFS-Path", urlPath)
+	w.Header().Set("Etag", etag)
+	w.Header().Set("Content-Type", c.MediaType())
+	w.Header().Set("Vary", "Accept")
+
+	modtime := time.Now()
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+		modtime = time.Unix(1, 0)
+	}
+	http.ServeContent(w, r, "", modtime, bytes.NewReader(data))
+}
@@ -11,11 +11,16 @@ import (
 	gopath "path"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	"github.com/ipfs/go-ipfs/dagutils"
+	denylist "github.com/ipfs/go-ipfs/denylist"
+	diskio "github.com/ipfs/go-ipfs/diskio"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
 	"gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/importer"
 	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
@@ -25,6 +30,7 @@ import (
 
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
 	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
 	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
 	chunker "gx/ipfs/QmdSeG9s4EQ9TGruJJS9Us38TQDZtMmFGwzTYUDVqNTURm/go-ipfs-chunker"
@@ -39,9 +45,11 @@ const (
 // gatewayHandler is a HTTP handler that serves IPFS objects (accessible by default at /ipfs/<path>)
 // (it serves requests like GET /ipfs/QmVRzPKPzNtSrEzBFm2UZfxmPAgnaLke4DMcerbsGGSaFe/link)
 type gatewayHandler struct {
-	node   *core.IpfsNode
+	node *core.IpfsNode
+	api  coreiface.CoreAPI
+
+	lk     sync.RWMutex
 	config GatewayConfig
-	api    coreiface.CoreAPI
 }
 
 func newGatewayHandler(n *core.IpfsNode, c GatewayConfig, api coreiface.CoreAPI) *gatewayHandler {
@@ -53,6 +61,27 @@ func newGatewayHandler(n *core.IpfsNode, c GatewayConfig, api coreiface.CoreAPI)
 	return i
 }
 
+func (i *gatewayHandler) currentConfig() GatewayConfig {
+	i.lk.RLock()
+	defer i.lk.RUnlock()
+	return i.config
+}
+
+// reloadConfig applies the headers and the path prefixes of the Gateway
+// config.
+func (i *gatewayHandler) reloadConfig() error {
+	cfg, err := i.node.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	i.lk.Lock()
+	defer i.lk.Unlock()
+	i.config.Headers = cfg.Gateway.HTTPHeaders
+	i.config.PathPrefixes = cfg.Gateway.PathPrefixes
+	return nil
+}
+
 // TODO(cryptix):  find these helpers somewhere else
 func (i *gatewayHandler) newDagFromReader(r io.Reader) (ipld.Node, error) {
 	// TODO(cryptix): change and remove this helper once PR1136 is merged
@@ -64,7 +93,13 @@ func (i *gatewayHandler) newDagFromReader(r io.Reader) (ipld.Node, error) {
 
 // TODO(btc): break this apart into separate handlers using a more expressive muxer
 func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(i.node.Context(), time.Hour)
+	ctx := i.node.Context()
+	if span := opentracing.SpanFromContext(r.Context()); span != nil {
+		ctx = opentracing.ContextWithSpan(ctx, span)
+	}
+	ctx = diskio.WithSubsystem(ctx, diskio.Gateway)
+	ctx = rp.WithRequest(ctx)
+	ctx, cancel := context.WithTimeout(ctx, time.Hour)
 	// the hour is a hard fallback, we don't expect it to happen, but just in case
 	defer cancel()
 
@@ -87,7 +122,7 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	if i.config.Writable {
+	if i.currentConfig().Writable {
 		switch r.Method {
 		case "POST":
 			i.postHandler(ctx, w, r)
@@ -112,7 +147,7 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	errmsg := "Method " + r.Method + " not allowed: "
-	if !i.config.Writable {
+	if !i.currentConfig().Writable {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		errmsg = errmsg + "read only access"
 	} else {
@@ -140,7 +175,7 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	// It will be prepended to links in directory listings and the index.html redirect.
 	prefix := ""
 	if prfx := r.Header.Get("X-Ipfs-Gateway-Prefix"); len(prfx) > 0 {
-		for _, p := range i.config.PathPrefixes {
+		for _, p := range i.currentConfig().PathPrefixes {
 			if prfx == p || strings.HasPrefix(prfx, p+"/") {
 				prefix = prfx
 				break
@@ -166,6 +201,16 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	if i.node.Denylist.PathDenied(urlPath) {
+		i.denied(w, r, urlPath)
+		return
+	}
+
+	if wantsIPNSRecord(r) {
+		i.serveIPNSRecord(ctx, w, r, urlPath)
+		return
+	}
+
 	// Resolve path to the final DAG node for the ETag
 	resolvedPath, err := i.api.ResolvePath(ctx, parsedPath)
 	if err == coreiface.ErrOffline && !i.node.OnlineMode() {
@@ -176,6 +221,28 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	if i.node.Denylist.CidDenied(resolvedPath.Cid()) {
+		i.denied(w, r, urlPath)
+		return
+	}
+
+	roots, err := i.pathRoots(ctx, resolvedPath)
+	if err != nil {
+		webError(w, "ipfs resolve -r "+escapedURLPath, err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set(rootsHeader, strings.Join(roots, ","))
+
+	codec, err := requestedCodec(r)
+	if err != nil {
+		webError(w, "invalid format", err, http.StatusBadRequest)
+		return
+	}
+	if codec != nil {
+		i.serveCodec(ctx, w, r, urlPath, resolvedPath, codec)
+		return
+	}
+
 	dr, err := i.api.Unixfs().Cat(ctx, resolvedPath)
 	dir := false
 	switch err {
@@ -206,6 +273,9 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		"Content-Range",
 		"X-Chunked-Output",
 		"X-Stream-Output",
+		"X-IPFS-Path",
+		rootsHeader,
+		contentTypeSourceHeader,
 	}
 
 	var allowedHeaders = strings.Join(allowedHeadersArr, ", ")
@@ -260,6 +330,13 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	}
 
 	if !dir {
+		// the modification time stored by 'ipfs add --preserve-mtime'
+		if nd, err := i.api.ResolveNode(ctx, resolvedPath); err == nil {
+			if attrs, err := coreunix.GetFileAttrs(nd); err == nil && !attrs.Mtime.IsZero() {
+				modtime = attrs.Mtime
+			}
+		}
+
 		urlFilename := r.URL.Query().Get("filename")
 		var name string
 		if urlFilename != "" {
@@ -303,7 +380,7 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		defer dr.Close()
 
 		// write to request
-		http.ServeContent(w, r, "index.html", modtime, dr)
+		i.serveFile(w, r, "index.html", modtime, dr)
 		return
 	default:
 		internalWebError(w, err)
@@ -394,6 +471,10 @@ func (i *gatewayHandler) serveFile(w http.ResponseWriter, req *http.Request, nam
 		}
 	}
 
+	if err := setContentType(w, name, content); err != nil {
+		internalWebError(w, err)
+		return
+	}
 	http.ServeContent(w, req, name, modtime, content)
 }
 
@@ -594,7 +675,7 @@ func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (i *gatewayHandler) addUserHeaders(w http.ResponseWriter) {
-	for k, v := range i.config.Headers {
+	for k, v := range i.currentConfig().Headers {
 		w.Header()[k] = v
 	}
 }
@@ -611,6 +692,12 @@ func webError(w http.ResponseWriter, message string, err error, defaultCode int)
 	}
 }
 
+// denied answers the requests of the content of the denylist.
+func (i *gatewayHandler) denied(w http.ResponseWriter, r *http.Request, urlPath string) {
+	denylist.LogDenied("gateway", urlPath, r.RemoteAddr)
+	webErrorWithCode(w, "ipfs cat "+r.URL.EscapedPath(), errors.New("content blocked by the gateway denylist"), http.StatusUnavailableForLegalReasons)
+}
+
 func webErrorWithCode(w http.ResponseWriter, message string, err error, code int) {
 	w.WriteHeader(code)
 
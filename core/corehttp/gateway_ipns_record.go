@@ -0,0 +1,92 @@
+package corehttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	multibase "gx/ipfs/QmekxXDhCxCJRNuzmHreuaT3BsuJcsjcXWNrtV9C8DRHtd/go-multibase"
+)
+
+const (
+	// ipnsRecordFormat is the ?format= of the IPNS records.
+	ipnsRecordFormat = "ipns-record"
+	// ipnsRecordMediaType is the media type of the IPNS records.
+	ipnsRecordMediaType = "application/vnd.ipfs.ipns-record"
+)
+
+// wantsIPNSRecord returns whether r asks for the signed IPNS record of the
+// name rather than the content it points to, with ?format=ipns-record or the
+// media type of the records in the Accept header.
+func wantsIPNSRecord(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == ipnsRecordFormat
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mt == ipnsRecordMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// serveIPNSRecord serves the IPNS record of the name of the /ipns/<name>
+// path, as published, for the clients to verify it themselves. It's cached
+// until the value of the record must be resolved again: the TTL of the
+// record, or less when the record expires before.
+func (i *gatewayHandler) serveIPNSRecord(ctx context.Context, w http.ResponseWriter, r *http.Request, urlPath string) {
+	name := strings.TrimPrefix(urlPath, ipnsPathPrefix)
+	if !strings.HasPrefix(urlPath, ipnsPathPrefix) || name == "" || strings.Contains(strings.TrimSuffix(name, "/"), "/") {
+		webError(w, "invalid ipns record path", errors.New("IPNS records are served at /ipns/<name>"), http.StatusBadRequest)
+		return
+	}
+	name = strings.TrimSuffix(name, "/")
+
+	if i.node.Routing == nil {
+		webError(w, "ipfs name resolve "+name, errors.New("the node has no routing"), http.StatusServiceUnavailable)
+		return
+	}
+
+	raw, entry, err := namesys.NewIpnsResolver(i.node.Routing).Record(ctx, name)
+	if err != nil {
+		webError(w, "ipfs name resolve "+name, err, http.StatusNotFound)
+		return
+	}
+	ttl, err := namesys.RecordTTL(entry)
+	if err != nil {
+		webError(w, "ipfs name resolve "+name, err, http.StatusBadRequest)
+		return
+	}
+
+	sum := sha256.Sum256(raw)
+	etag, err := multibase.Encode(multibase.Base32, sum[:])
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+	etag = "\"" + etag + "\""
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	i.addUserHeaders(w)
+	w.Header().Set("Content-Type", ipnsRecordMediaType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".ipns-record"))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Etag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ttl/time.Second)))
+	w.Header().Set("Vary", "Accept")
+	if r.Method == "HEAD" {
+		return
+	}
+	w.Write(raw)
+}
@@ -0,0 +1,91 @@
+package corehttp
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	gopath "path"
+	"strings"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
+	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
+	resolver "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path/resolver"
+)
+
+// The headers describing a response in terms of immutable identifiers, so
+// that the CDNs can key their caches by them and purge the responses of an
+// IPNS name precisely when it's updated.
+const (
+	// rootsHeader lists the CIDs of the nodes along the resolved path, from
+	// its root to the node served.
+	rootsHeader = "X-Ipfs-Roots"
+
+	// contentTypeSourceHeader tells how the Content-Type of a file was
+	// detected: from the extension of its name, or by sniffing its content.
+	contentTypeSourceHeader = "X-Ipfs-Content-Type-Source"
+)
+
+// The values of contentTypeSourceHeader.
+const (
+	contentTypeFromExtension = "extension"
+	contentTypeSniffed       = "sniffed"
+)
+
+// pathRoots returns the CIDs of the nodes along resolvedPath, the root of the
+// path first. The segments of an IPLD path within a node are not included.
+func (i *gatewayHandler) pathRoots(ctx context.Context, resolvedPath coreiface.ResolvedPath) ([]string, error) {
+	p := resolvedPath.String()
+	if rest := resolvedPath.Remainder(); rest != "" {
+		p = strings.TrimSuffix(strings.TrimSuffix(p, "/"), "/"+rest)
+	}
+
+	r := &resolver.Resolver{
+		DAG:         i.node.DAG,
+		ResolveOnce: uio.ResolveUnixfsOnce,
+	}
+	if resolvedPath.Namespace() == "ipld" {
+		r.ResolveOnce = resolver.ResolveSingle
+	}
+
+	nodes, err := r.ResolvePathComponents(ctx, path.Path(p))
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]string, len(nodes))
+	for j, nd := range nodes {
+		roots[j] = nd.Cid().String()
+	}
+	return roots, nil
+}
+
+// setContentType sets the Content-Type of the file name read from content,
+// unless set by the user headers, and tells how it was detected. content is
+// left at its start.
+func setContentType(w http.ResponseWriter, name string, content io.ReadSeeker) error {
+	if w.Header().Get("Content-Type") != "" {
+		return nil
+	}
+
+	if ctype := mime.TypeByExtension(gopath.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set(contentTypeSourceHeader, contentTypeFromExtension)
+		return nil
+	}
+
+	// the same bytes as the MIME types of 'ipfs files stat' and 'ipfs ls'
+	buf := make([]byte, coreunix.SniffLen)
+	n, err := io.ReadFull(content, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(buf[:n]))
+	w.Header().Set(contentTypeSourceHeader, contentTypeSniffed)
+	return nil
+}
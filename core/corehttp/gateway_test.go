@@ -19,12 +19,16 @@ import (
 	repo "github.com/ipfs/go-ipfs/repo"
 
 	ci "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	offroute "gx/ipfs/QmSNe4MWVxZWk6UxxW2z2EKofFo4GdFzud1vfn1iVby3mj/go-ipfs-routing/offline"
 	datastore "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
 	syncds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
 	id "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/protocol/identify"
 	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
 	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
 	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	pb "gx/ipfs/QmbUUxB9ErnEQdwTzy6HTxucnBvAH4am6vsfbD8CiqKhi9/go-ipns/pb"
+	proto "gx/ipfs/QmdxUuburamoF6zF9qjeQC4WYcWGbWuRmdLacMEsW8ioD8/gogo-protobuf/proto"
 )
 
 // `ipfs object new unixfs-dir`
@@ -205,6 +209,113 @@ func TestGatewayGet(t *testing.T) {
 	}
 }
 
+func TestGatewayIPNSRecord(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ts, n := newTestServerAndNode(t, mockNamesys{})
+	defer ts.Close()
+	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.RecordValidator)
+
+	sk, pk, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := namesys.NewIpnsPublisher(n.Routing, n.Repo.Datastore()).Publish(ctx, sk, path.FromString("/ipfs/"+k)); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Get(ts.URL + "/ipns/" + pid.Pretty() + "?format=ipns-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", res.StatusCode, body)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != ipnsRecordMediaType {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+	if cc := res.Header.Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Fatalf("unexpected Cache-Control %q", cc)
+	}
+
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(body, entry); err != nil {
+		t.Fatal(err)
+	}
+	if string(entry.GetValue()) != "/ipfs/"+k {
+		t.Fatalf("unexpected value %q", entry.GetValue())
+	}
+
+	res, err = http.Get(ts.URL + "/ipns/" + pid.Pretty() + "/sub?format=ipns-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a path under the name, got %d", res.StatusCode)
+	}
+}
+
+func TestGatewayRootsHeader(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	_, dir, err := coreunix.AddWrapped(n, strings.NewReader("fnord"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := dir.Links()[0].Cid
+	ns["/ipns/example.net"] = path.FromString("/ipfs/" + dir.Cid().String())
+
+	expected := dir.Cid().String() + "," + file.String()
+	for _, p := range []string{"/ipfs/" + dir.Cid().String() + "/file.txt", "/ipns/example.net/file.txt"} {
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200 from %s, got %d", p, res.StatusCode)
+		}
+		if roots := res.Header.Get("X-Ipfs-Roots"); roots != expected {
+			t.Fatalf("%s: expected X-Ipfs-Roots %q, got %q", p, expected, roots)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+			t.Fatalf("%s: unexpected Content-Type %q", p, ct)
+		}
+		if src := res.Header.Get("X-Ipfs-Content-Type-Source"); src != "extension" {
+			t.Fatalf("%s: unexpected X-Ipfs-Content-Type-Source %q", p, src)
+		}
+	}
+
+	res, err := http.Get(ts.URL + "/ipfs/" + file.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if roots := res.Header.Get("X-Ipfs-Roots"); roots != file.String() {
+		t.Fatalf("expected X-Ipfs-Roots %q, got %q", file.String(), roots)
+	}
+	if src := res.Header.Get("X-Ipfs-Content-Type-Source"); src != "sniffed" {
+		t.Fatalf("unexpected X-Ipfs-Content-Type-Source %q", src)
+	}
+}
+
 func TestIPNSHostnameRedirect(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
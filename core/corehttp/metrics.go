@@ -3,8 +3,11 @@ package corehttp
 import (
 	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
+	coremetrics "github.com/ipfs/go-ipfs/core/coremetrics"
 
 	prometheus "gx/ipfs/QmYYv3QFnfQbiwmi1tpkgKF8o4xFnZoBrvpupTiGJwL9nH/client_golang/prometheus"
 )
@@ -26,6 +29,54 @@ func MetricsCollectionOption(handlerName string) ServeOption {
 	}
 }
 
+var gatewayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "ipfs",
+	Subsystem: "http",
+	Name:      "gw_request_duration_seconds",
+	Help:      "Duration of gateway requests by status code.",
+	Buckets:   coremetrics.LatencyBuckets,
+}, []string{"code", "method"})
+
+func init() {
+	prometheus.MustRegister(gatewayDuration)
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush keeps streaming responses working through the recorder.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify lets the gateway handler cancel requests of gone clients.
+func (r *statusRecorder) CloseNotify() <-chan bool {
+	if cn, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+// measureGateway records the duration of the requests handled by h.
+func measureGateway(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		gatewayDuration.WithLabelValues(strconv.Itoa(rec.code), r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
 var (
 	peersTotalMetric = prometheus.NewDesc(
 		prometheus.BuildFQName("ipfs", "p2p", "peers_total"),
@@ -2,6 +2,8 @@ package corehttp
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -48,3 +50,20 @@ func TestPeersTotal(t *testing.T) {
 		t.Fatalf("expected 3 peers, got %f", actual["/ip4/tcp"])
 	}
 }
+
+func TestMeasureGatewayStatus(t *testing.T) {
+	var code int
+	h := measureGateway(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.CloseNotifier); !ok {
+			t.Error("expected the writer to implement http.CloseNotifier")
+		}
+		http.NotFound(w, r)
+		code = w.(*statusRecorder).code
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/ipfs/foo", nil))
+	if code != http.StatusNotFound || rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 to be recorded and passed through, got %d and %d", code, rec.Code)
+	}
+}
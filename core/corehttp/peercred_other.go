@@ -0,0 +1,14 @@
+// +build !linux
+
+package corehttp
+
+import (
+	"fmt"
+	"net"
+)
+
+const peerCredSupported = false
+
+func peerUID(c net.Conn) (int, error) {
+	return 0, fmt.Errorf("peer credentials are not supported on this platform")
+}
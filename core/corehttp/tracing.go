@@ -0,0 +1,48 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/go-ipfs/core"
+	tracing "github.com/ipfs/go-ipfs/tracing"
+
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
+	ext "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go/ext"
+)
+
+// TracingOption starts a span for every request when traces are exported,
+// continuing the trace of the client if the request has a traceparent
+// header. The work done to answer the request is traced under that span.
+func TracingOption(handlerName string) ServeOption {
+	return func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		childMux := http.NewServeMux()
+		mux.Handle("/", traceRequests(handlerName, childMux))
+		return childMux, nil
+	}
+}
+
+func traceRequests(handlerName string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tracing.Active() {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		tracer := opentracing.GlobalTracer()
+		client, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+		span := tracer.StartSpan(handlerName+" "+r.Method, ext.RPCServerOption(client))
+		defer span.Finish()
+
+		ext.HTTPMethod.Set(span, r.Method)
+		ext.HTTPUrl.Set(span, r.URL.Path)
+
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(opentracing.ContextWithSpan(r.Context(), span)))
+
+		ext.HTTPStatusCode.Set(span, uint16(rec.code))
+		if rec.code >= http.StatusInternalServerError {
+			ext.Error.Set(span, true)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package corehttp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+// UnixSocketPath returns the path of a /unix/ multiaddr, or false when addr
+// isn't one.
+func UnixSocketPath(addr ma.Multiaddr) (string, bool) {
+	p, err := addr.ValueForProtocol(ma.P_UNIX)
+	if err != nil {
+		return "", false
+	}
+	return p, true
+}
+
+// ListenUnix listens on a unix domain socket created with the given
+// permissions, replacing the socket left by a daemon that didn't exit
+// cleanly. When allowedUIDs isn't empty, the connections of the users other
+// than the ones listed and the one running the daemon are refused.
+func ListenUnix(path string, mode os.FileMode, allowedUIDs []int) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	if len(allowedUIDs) == 0 {
+		return l, nil
+	}
+	if !peerCredSupported {
+		l.Close()
+		return nil, fmt.Errorf("checking the users of the API socket is not supported on this platform")
+	}
+
+	allowed := map[int]bool{os.Getuid(): true}
+	for _, uid := range allowedUIDs {
+		allowed[uid] = true
+	}
+	return &peerCredListener{Listener: l, allowed: allowed}, nil
+}
+
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	c, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		c.Close()
+		return fmt.Errorf("%s is in use by another daemon", path)
+	}
+	return os.Remove(path)
+}
+
+// peerCredListener refuses the connections of the users not allowed.
+type peerCredListener struct {
+	net.Listener
+	allowed map[int]bool
+}
+
+func (l *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		uid, err := peerUID(c)
+		if err != nil {
+			log.Warningf("reading the credentials of an API connection: %s", err)
+		} else if l.allowed[uid] {
+			return c, nil
+		} else {
+			log.Warningf("refused API connection of user %d", uid)
+		}
+		c.Close()
+	}
+}
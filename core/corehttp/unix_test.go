@@ -0,0 +1,74 @@
+package corehttp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "api.sock")
+
+	var allowed []int
+	if peerCredSupported {
+		// the user running the daemon is always allowed
+		allowed = []int{os.Getuid() + 1}
+	}
+	l, err := ListenUnix(path, 0600, allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected the socket mode to be 0600, got %o", fi.Mode().Perm())
+	}
+
+	go func() {
+		c, err := net.Dial("unix", path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		c.Write([]byte("x"))
+		c.Close()
+	}()
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1)
+	if _, err := c.Read(buf); err != nil || buf[0] != 'x' {
+		t.Fatalf("expected to read from the connection, got %q, %v", buf, err)
+	}
+	c.Close()
+
+	if _, err := ListenUnix(path, 0600, nil); err == nil {
+		t.Fatal("expected the socket in use to be kept")
+	}
+	l.Close()
+
+	// a socket left behind is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err = ListenUnix(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
@@ -1,5 +1,12 @@
 package corehttp
 
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
 // TODO: move to IPNS
 const WebUIPath = "/ipfs/QmQLXHs7K98JNQdWrBB2cQLJahPhmupbDjRuH1b9ibmwVa"
 
@@ -19,3 +26,19 @@ var WebUIPaths = []string{
 }
 
 var WebUIOption = RedirectOption("webui", WebUIPath)
+
+// WebUIGatewayOption serves the paths of the WebUI read-only, like
+// GatewayOption. When offline is set, they're served from the local repo
+// only, so that a WebUI missing from the repo isn't fetched from the network.
+func WebUIGatewayOption(offline bool, paths ...string) ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		if offline {
+			view, err := n.OfflineView()
+			if err != nil {
+				return nil, err
+			}
+			n = view
+		}
+		return GatewayOption(false, paths...)(n, l, mux)
+	}
+}
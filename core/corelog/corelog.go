@@ -0,0 +1,292 @@
+// Package corelog routes the log messages of the daemon.
+//
+// It replaces the backend of go-logging, which go-log writes to, with one
+// that writes stderr in the text or JSON format, copies the messages of
+// selected subsystems to rotated files at their own level, and streams the
+// messages to 'ipfs log tail'.
+package corelog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	golog "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	logging "gx/ipfs/QmcaSwFc5RBg8yCq54QURwEU4nwjfCpjbpmaAm4VbdGLKv/go-logging"
+)
+
+// envLoggingFmt is read by go-log to pick the text format, "json" selects
+// the JSON format.
+const envLoggingFmt = "IPFS_LOGGING_FMT"
+
+// envLoggingFile is read by go-log to write the logs to a file instead of
+// stderr.
+const envLoggingFile = "GOLOG_FILE"
+
+// defaultOutputLevel is the level of outputs that don't set one.
+const defaultOutputLevel = logging.INFO
+
+// ErrNotConfigured is returned when tailing the log messages of a process
+// that didn't configure the router.
+var ErrNotConfigured = errors.New("log routing is not configured")
+
+var (
+	routerLk sync.Mutex
+	current  *router
+)
+
+// Configure installs the router described by cfg. Relative paths of output
+// files are relative to repoPath. The returned closer closes the output
+// files.
+func Configure(cfg *extconfig.Logging, repoPath string) (io.Closer, error) {
+	format := cfg.Format
+	if os.Getenv(envLoggingFmt) == extconfig.LogFormatJSON {
+		format = extconfig.LogFormatJSON
+	}
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
+
+	var stderr io.Writer = os.Stderr
+	if path := os.Getenv(envLoggingFile); path != "" {
+		// go-log opened it already, but doesn't tell us.
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		stderr = f
+	}
+
+	r := &router{
+		stderr:     logging.AddModuleLevel(logging.NewLogBackend(stderr, "", 0)),
+		stderrJSON: format == extconfig.LogFormatJSON,
+		stderrOut:  stderr,
+		tails:      make(map[*tail]struct{}),
+	}
+
+	// keep the levels set by go-log and IPFS_LOGGING.
+	r.stderr.SetLevel(logging.GetLevel(""), "")
+	for _, s := range golog.GetSubsystems() {
+		r.stderr.SetLevel(logging.GetLevel(s), s)
+	}
+
+	for _, o := range cfg.Outputs {
+		out, err := newOutput(o, repoPath)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.outputs = append(r.outputs, out)
+	}
+
+	routerLk.Lock()
+	current = r
+	routerLk.Unlock()
+
+	logging.SetBackend(r)
+	return r, nil
+}
+
+// SetLevels sets the levels of the subsystems, with "*" standing for all of
+// them. The level of "*" is set first so that the others override it.
+func SetLevels(levels map[string]string) error {
+	if l, ok := levels["*"]; ok {
+		if err := golog.SetLogLevel("*", l); err != nil {
+			return fmt.Errorf("setting the log level of all subsystems: %s", err)
+		}
+	}
+
+	subsystems := make([]string, 0, len(levels))
+	for s := range levels {
+		if s != "*" {
+			subsystems = append(subsystems, s)
+		}
+	}
+	sort.Strings(subsystems)
+	for _, s := range subsystems {
+		if err := golog.SetLogLevel(s, levels[s]); err != nil {
+			return fmt.Errorf("setting the log level of %s: %s", s, err)
+		}
+	}
+	return nil
+}
+
+func checkFormat(f string) error {
+	switch f {
+	case "", extconfig.LogFormatText, extconfig.LogFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q, expected %q or %q", f, extconfig.LogFormatText, extconfig.LogFormatJSON)
+	}
+}
+
+// output is a file receiving the messages of some subsystems.
+type output struct {
+	subsystems map[string]bool // nil for all
+	level      logging.Level
+	json       bool
+
+	lk sync.Mutex
+	w  *rotatingFile
+}
+
+func newOutput(cfg extconfig.LogOutput, repoPath string) (*output, error) {
+	if cfg.File == "" {
+		return nil, errors.New("log output without a file")
+	}
+	if err := checkFormat(cfg.Format); err != nil {
+		return nil, err
+	}
+
+	o := &output{
+		level: defaultOutputLevel,
+		json:  cfg.Format != extconfig.LogFormatText,
+	}
+	if cfg.Level != "" {
+		lvl, err := logging.LogLevel(cfg.Level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level of log output %s: %s", cfg.File, err)
+		}
+		o.level = lvl
+	}
+	for _, s := range cfg.Subsystems {
+		if s == "*" || s == "all" {
+			o.subsystems = nil
+			break
+		}
+		if o.subsystems == nil {
+			o.subsystems = make(map[string]bool)
+		}
+		o.subsystems[s] = true
+	}
+
+	path := cfg.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repoPath, path)
+	}
+	w, err := openRotatingFile(path, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	o.w = w
+	return o, nil
+}
+
+func (o *output) enabled(level logging.Level, module string) bool {
+	return level <= o.level && (o.subsystems == nil || o.subsystems[module])
+}
+
+func (o *output) write(e *entry) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	if o.json {
+		o.w.Write(e.json())
+	} else {
+		o.w.Write(e.text())
+	}
+}
+
+// router is the go-logging backend of the daemon. As a LeveledBackend, it
+// holds the levels of stderr set with 'ipfs log level'; outputs have
+// theirs.
+type router struct {
+	stderr     logging.LeveledBackend
+	stderrJSON bool
+	stderrLk   sync.Mutex
+	stderrOut  io.Writer
+	outputs    []*output
+
+	tailLk sync.Mutex
+	tails  map[*tail]struct{}
+}
+
+var _ logging.LeveledBackend = (*router)(nil)
+
+func (r *router) GetLevel(module string) logging.Level {
+	return r.stderr.GetLevel(module)
+}
+
+func (r *router) SetLevel(level logging.Level, module string) {
+	r.stderr.SetLevel(level, module)
+}
+
+func (r *router) IsEnabledFor(level logging.Level, module string) bool {
+	if r.stderr.IsEnabledFor(level, module) {
+		return true
+	}
+	for _, o := range r.outputs {
+		if o.enabled(level, module) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *router) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	var e *entry
+	get := func() *entry {
+		if e == nil {
+			e = newEntry(rec, calldepth+3)
+		}
+		return e
+	}
+
+	if r.stderr.IsEnabledFor(level, rec.Module) {
+		if r.stderrJSON {
+			r.stderrLk.Lock()
+			r.stderrOut.Write(get().json())
+			r.stderrLk.Unlock()
+		} else {
+			r.stderr.Log(level, calldepth+1, rec)
+		}
+		r.tail(get())
+	}
+	for _, o := range r.outputs {
+		if o.enabled(level, rec.Module) {
+			o.write(get())
+		}
+	}
+	return nil
+}
+
+// Close closes the output files.
+func (r *router) Close() error {
+	var err error
+	for _, o := range r.outputs {
+		o.lk.Lock()
+		if cerr := o.w.Close(); cerr != nil {
+			err = cerr
+		}
+		o.lk.Unlock()
+	}
+	return err
+}
+
+// entry is a log message, encoded lazily and once per format.
+type entry struct {
+	rec    *logging.Record
+	caller string
+
+	jsonB []byte
+	textB []byte
+}
+
+func newEntry(rec *logging.Record, calldepth int) *entry {
+	e := &entry{rec: rec}
+	if _, file, line, ok := runtime.Caller(calldepth); ok {
+		e.caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	return e
+}
+
+func levelName(l logging.Level) string {
+	return strings.ToLower(l.String())
+}
@@ -0,0 +1,128 @@
+package corelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	golog "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	logging "gx/ipfs/QmcaSwFc5RBg8yCq54QURwEU4nwjfCpjbpmaAm4VbdGLKv/go-logging"
+)
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "corelog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "ipfs.log")
+	f, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"aaaaaaa\n", "bbbbbbb\n", "ccccccc\n", "ddddddd\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, expected := range map[string]string{
+		path:        "ddddddd\n",
+		path + ".1": "ccccccc\n",
+		path + ".2": "bbbbbbb\n",
+	} {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("expected %s to contain %q, got %q", name, expected, b)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected only two backups to be kept")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "corelog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var stderr bytes.Buffer
+	r := &router{
+		stderr: logging.AddModuleLevel(logging.NewLogBackend(&stderr, "", 0)),
+		tails:  make(map[*tail]struct{}),
+	}
+	r.SetLevel(logging.ERROR, "")
+
+	out, err := newOutput(extconfig.LogOutput{
+		File:       "bitswap.log",
+		Subsystems: []string{"bitswap"},
+		Level:      "debug",
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.outputs = append(r.outputs, out)
+	defer r.Close()
+
+	if !r.IsEnabledFor(logging.DEBUG, "bitswap") || r.IsEnabledFor(logging.DEBUG, "dht") {
+		t.Fatal("expected debug messages of bitswap only to be enabled")
+	}
+
+	now := time.Now()
+	r.Log(logging.DEBUG, 0, &logging.Record{Time: now, Module: "bitswap", Level: logging.DEBUG, Args: []interface{}{"wanted"}})
+	r.Log(logging.DEBUG, 0, &logging.Record{Time: now, Module: "dht", Level: logging.DEBUG, Args: []interface{}{"ignored"}})
+
+	if stderr.Len() != 0 {
+		t.Fatalf("expected nothing on stderr, got %q", stderr.String())
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "bitswap.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one message, got %q", b)
+	}
+	var e jsonEntry
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.System != "bitswap" || e.Level != "debug" || e.Message != "wanted" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	golog.Logger("corelog-a")
+	golog.Logger("corelog-b")
+
+	if err := SetLevels(map[string]string{"*": "error", "corelog-b": "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if l := logging.GetLevel("corelog-a"); l != logging.ERROR {
+		t.Fatalf("expected the level of all subsystems to apply, got %s", l)
+	}
+	if l := logging.GetLevel("corelog-b"); l != logging.DEBUG {
+		t.Fatalf("expected the level of the subsystem to override the others, got %s", l)
+	}
+
+	if err := SetLevels(map[string]string{"corelog-a": "loud"}); err == nil {
+		t.Fatal("expected an invalid level to fail")
+	}
+}
@@ -0,0 +1,118 @@
+package corelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+)
+
+// tailBuffer is the number of messages buffered for a slow tail client.
+// Messages past it are dropped rather than blocking the logging goroutines.
+const tailBuffer = 1024
+
+type tail struct {
+	json bool
+	msgs chan []byte
+}
+
+// Tail streams the messages written to stderr to w, in format, until ctx is
+// done or writing fails.
+func Tail(ctx context.Context, w io.Writer, format string) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	routerLk.Lock()
+	r := current
+	routerLk.Unlock()
+	if r == nil {
+		return ErrNotConfigured
+	}
+
+	t := &tail{
+		json: format != extconfig.LogFormatText,
+		msgs: make(chan []byte, tailBuffer),
+	}
+	r.tailLk.Lock()
+	r.tails[t] = struct{}{}
+	r.tailLk.Unlock()
+
+	defer func() {
+		r.tailLk.Lock()
+		delete(r.tails, t)
+		r.tailLk.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-t.msgs:
+			if _, err := w.Write(msg); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (r *router) tail(e *entry) {
+	r.tailLk.Lock()
+	defer r.tailLk.Unlock()
+	for t := range r.tails {
+		msg := e.text()
+		if t.json {
+			msg = e.json()
+		}
+		select {
+		case t.msgs <- msg:
+		default:
+		}
+	}
+}
+
+// jsonEntry is the JSON encoding of a message.
+type jsonEntry struct {
+	Time    string `json:"ts"`
+	Level   string `json:"level"`
+	System  string `json:"system"`
+	Caller  string `json:"caller,omitempty"`
+	Message string `json:"msg"`
+}
+
+func (e *entry) json() []byte {
+	if e.jsonB != nil {
+		return e.jsonB
+	}
+	b, err := json.Marshal(&jsonEntry{
+		Time:    e.rec.Time.UTC().Format(time.RFC3339Nano),
+		Level:   levelName(e.rec.Level),
+		System:  e.rec.Module,
+		Caller:  e.caller,
+		Message: e.rec.Message(),
+	})
+	if err != nil {
+		// only strings are encoded
+		panic(err)
+	}
+	e.jsonB = append(b, '\n')
+	return e.jsonB
+}
+
+// text is the plain text encoding of a message, without the colors of the
+// stderr format.
+func (e *entry) text() []byte {
+	if e.textB != nil {
+		return e.textB
+	}
+	e.textB = []byte(fmt.Sprintf("%s %s %s %s: %s\n",
+		e.rec.Time.UTC().Format(time.RFC3339Nano),
+		e.rec.Level,
+		e.rec.Module,
+		e.caller,
+		e.rec.Message()))
+	return e.textB
+}
@@ -0,0 +1,110 @@
+package coremetrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	bsmsg "gx/ipfs/QmUyaGN3WPr3CTLai7DBvMikagK45V4fUi8p8cNRaJQoU1/go-bitswap/message"
+	bsnet "gx/ipfs/QmUyaGN3WPr3CTLai7DBvMikagK45V4fUi8p8cNRaJQoU1/go-bitswap/network"
+)
+
+// maxPendingWants bounds the wants tracked. Wants that are never answered nor
+// canceled would otherwise accumulate.
+const maxPendingWants = 1 << 16
+
+// wantTracker remembers when blocks were first asked for.
+type wantTracker struct {
+	lk      sync.Mutex
+	pending map[cid.Cid]time.Time
+}
+
+func (t *wantTracker) sent(msg bsmsg.BitSwapMessage) {
+	now := time.Now()
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	for _, e := range msg.Wantlist() {
+		if e.Cancel {
+			delete(t.pending, e.Cid)
+			continue
+		}
+		if _, ok := t.pending[e.Cid]; ok {
+			continue
+		}
+		if len(t.pending) >= maxPendingWants {
+			t.pending = make(map[cid.Cid]time.Time)
+		}
+		t.pending[e.Cid] = now
+	}
+}
+
+func (t *wantTracker) received(msg bsmsg.BitSwapMessage) {
+	now := time.Now()
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	for _, b := range msg.Blocks() {
+		start, ok := t.pending[b.Cid()]
+		if !ok {
+			continue
+		}
+		delete(t.pending, b.Cid())
+		blockFetchDuration.Observe(now.Sub(start).Seconds())
+	}
+}
+
+// BitswapNetwork wraps the network of bitswap to measure how long blocks
+// take to arrive once requested.
+func BitswapNetwork(n bsnet.BitSwapNetwork) bsnet.BitSwapNetwork {
+	return &bitswapNetwork{
+		BitSwapNetwork: n,
+		wants:          &wantTracker{pending: make(map[cid.Cid]time.Time)},
+	}
+}
+
+type bitswapNetwork struct {
+	bsnet.BitSwapNetwork
+	wants *wantTracker
+}
+
+func (n *bitswapNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	n.wants.sent(msg)
+	return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+func (n *bitswapNetwork) NewMessageSender(ctx context.Context, p peer.ID) (bsnet.MessageSender, error) {
+	s, err := n.BitSwapNetwork.NewMessageSender(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &messageSender{MessageSender: s, wants: n.wants}, nil
+}
+
+func (n *bitswapNetwork) SetDelegate(r bsnet.Receiver) {
+	n.BitSwapNetwork.SetDelegate(&receiver{Receiver: r, wants: n.wants})
+}
+
+type messageSender struct {
+	bsnet.MessageSender
+	wants *wantTracker
+}
+
+func (s *messageSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	s.wants.sent(msg)
+	return s.MessageSender.SendMsg(ctx, msg)
+}
+
+type receiver struct {
+	bsnet.Receiver
+	wants *wantTracker
+}
+
+func (r *receiver) ReceiveMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.wants.received(msg)
+	r.Receiver.ReceiveMessage(ctx, p, msg)
+}
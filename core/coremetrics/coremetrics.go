@@ -0,0 +1,43 @@
+// Package coremetrics instruments the subsystems of the node with Prometheus
+// histograms, exported on the /debug/metrics/prometheus endpoint.
+//
+// The wrappers are transparent: they only observe the calls going through
+// them.
+package coremetrics
+
+import (
+	prometheus "gx/ipfs/QmYYv3QFnfQbiwmi1tpkgKF8o4xFnZoBrvpupTiGJwL9nH/client_golang/prometheus"
+)
+
+// LatencyBuckets are the histogram buckets, in seconds, of network
+// operations.
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	blockFetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "ipfs",
+		Subsystem: "bitswap",
+		Name:      "block_fetch_duration_seconds",
+		Help:      "Time between a block being first requested from peers and received.",
+		Buckets:   LatencyBuckets,
+	})
+
+	routingDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ipfs",
+		Subsystem: "dht",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of routing queries, until the first result for provider lookups.",
+		Buckets:   LatencyBuckets,
+	}, []string{"op", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(blockFetchDuration, routingDuration)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,87 @@
+package coremetrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
+	ropts "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing/options"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+var errNoProviders = errors.New("no providers found")
+
+// Routing wraps a routing system to measure the duration of its queries.
+func Routing(r routing.IpfsRouting) routing.IpfsRouting {
+	return &measuredRouting{IpfsRouting: r}
+}
+
+type measuredRouting struct {
+	routing.IpfsRouting
+}
+
+func observe(op string, start time.Time, err error) {
+	routingDuration.WithLabelValues(op, result(err)).Observe(time.Since(start).Seconds())
+}
+
+func (r *measuredRouting) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	start := time.Now()
+	err := r.IpfsRouting.Provide(ctx, c, announce)
+	observe("provide", start, err)
+	return err
+}
+
+// FindProvidersAsync measures the time until the first provider is found,
+// which is what delays fetching.
+func (r *measuredRouting) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan pstore.PeerInfo {
+	start := time.Now()
+	in := r.IpfsRouting.FindProvidersAsync(ctx, c, count)
+	out := make(chan pstore.PeerInfo)
+
+	go func() {
+		defer close(out)
+		first := true
+		for pi := range in {
+			if first {
+				observe("find_providers", start, nil)
+				first = false
+			}
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				// drain in so the query can finish
+				for range in {
+				}
+				return
+			}
+		}
+		if first {
+			observe("find_providers", start, errNoProviders)
+		}
+	}()
+	return out
+}
+
+func (r *measuredRouting) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	start := time.Now()
+	pi, err := r.IpfsRouting.FindPeer(ctx, p)
+	observe("find_peer", start, err)
+	return pi, err
+}
+
+func (r *measuredRouting) PutValue(ctx context.Context, key string, val []byte, opts ...ropts.Option) error {
+	start := time.Now()
+	err := r.IpfsRouting.PutValue(ctx, key, val, opts...)
+	observe("put_value", start, err)
+	return err
+}
+
+func (r *measuredRouting) GetValue(ctx context.Context, key string, opts ...ropts.Option) ([]byte, error) {
+	start := time.Now()
+	val, err := r.IpfsRouting.GetValue(ctx, key, opts...)
+	observe("get_value", start, err)
+	return val, err
+}
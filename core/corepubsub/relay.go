@@ -0,0 +1,34 @@
+package corepubsub
+
+import (
+	"context"
+
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	floodsub "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub"
+)
+
+var log = logging.Logger("corepubsub")
+
+// Relay subscribes to topic and discards the received messages until ctx is
+// canceled. Being subscribed is what makes the router forward messages on the
+// topic to other peers, so this keeps the node relaying the topic without a
+// client attached.
+func Relay(ctx context.Context, ps *floodsub.PubSub, topic string) error {
+	sub, err := ps.Subscribe(topic)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer sub.Cancel()
+		for {
+			if _, err := sub.Next(ctx); err != nil {
+				if ctx.Err() == nil {
+					log.Errorf("relay of topic %s stopped: %s", topic, err)
+				}
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,235 @@
+package corepubsub
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	floodsub "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+const (
+	defaultDecayInterval = time.Minute
+	defaultDecayFactor   = 0.9
+
+	// counters below this value are reset to zero when decaying
+	decayToZero = 0.01
+)
+
+// PeerScore is the score of a single peer, broken down per topic.
+type PeerScore struct {
+	Peer   peer.ID
+	Score  float64
+	Topics map[string]float64
+}
+
+type topicCounters struct {
+	firstDeliveries   float64
+	invalidDeliveries float64
+}
+
+// PeerScorer keeps track of the messages peers send on scored topics and
+// ignores messages from peers whose score falls below the graylist
+// threshold.
+//
+// Peers are scored by the messages they forward to the node, not by the
+// author the messages claim, which anyone can forge. The scorer learns who
+// sent each message from the streams of the pubsub router, which must be
+// built on the host returned by Host.
+type PeerScorer struct {
+	params   extconfig.PubsubPeerScoring
+	interval time.Duration
+	factor   float64
+	senders  *senders
+
+	lk    sync.Mutex
+	peers map[peer.ID]map[string]*topicCounters
+}
+
+// NewPeerScorer constructs a PeerScorer from the config parameters.
+func NewPeerScorer(params extconfig.PubsubPeerScoring) (*PeerScorer, error) {
+	interval := defaultDecayInterval
+	if params.DecayInterval != "" {
+		d, err := time.ParseDuration(params.DecayInterval)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Pubsub.PeerScoring.DecayInterval: %s", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("Pubsub.PeerScoring.DecayInterval must be positive")
+		}
+		interval = d
+	}
+
+	factor := params.DecayFactor
+	if factor == 0 {
+		factor = defaultDecayFactor
+	}
+	if factor < 0 || factor >= 1 {
+		return nil, fmt.Errorf("Pubsub.PeerScoring.DecayFactor must be in [0, 1)")
+	}
+
+	for topic, tp := range params.Topics {
+		if tp.FirstMessageDeliveriesWeight < 0 {
+			return nil, fmt.Errorf("FirstMessageDeliveriesWeight of topic %q must not be negative", topic)
+		}
+		if tp.InvalidMessageDeliveriesWeight > 0 {
+			return nil, fmt.Errorf("InvalidMessageDeliveriesWeight of topic %q must not be positive", topic)
+		}
+	}
+
+	return &PeerScorer{
+		params:   params,
+		interval: interval,
+		factor:   factor,
+		senders:  newSenders(),
+		peers:    make(map[peer.ID]map[string]*topicCounters),
+	}, nil
+}
+
+// Scored returns whether messages on the topic affect peer scores.
+func (s *PeerScorer) Scored(topic string) bool {
+	_, ok := s.params.Topics[topic]
+	return ok
+}
+
+// Host wraps h to learn the peer each message comes from. The pubsub router
+// must be built on the returned host.
+func (s *PeerScorer) Host(h host.Host) host.Host {
+	return &watchedHost{Host: h, senders: s.senders}
+}
+
+// Wrap returns a validator for topic that rejects messages sent by
+// graylisted peers and otherwise defers to v (which may be nil), recording
+// the outcome in the score of the sender. The messages published by the node
+// itself are not scored.
+func (s *PeerScorer) Wrap(topic string, v floodsub.Validator) floodsub.Validator {
+	return func(ctx context.Context, msg *floodsub.Message) bool {
+		from, ok := s.senders.sender(msg.Message)
+		if !ok {
+			return v == nil || v(ctx, msg)
+		}
+
+		if s.Score(from) < s.params.GraylistThreshold {
+			return false
+		}
+
+		valid := v == nil || v(ctx, msg)
+		s.record(from, topic, valid)
+		return valid
+	}
+}
+
+func (s *PeerScorer) record(p peer.ID, topic string, valid bool) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	topics, ok := s.peers[p]
+	if !ok {
+		topics = make(map[string]*topicCounters)
+		s.peers[p] = topics
+	}
+
+	c, ok := topics[topic]
+	if !ok {
+		c = new(topicCounters)
+		topics[topic] = c
+	}
+
+	if !valid {
+		c.invalidDeliveries++
+		return
+	}
+
+	c.firstDeliveries++
+	if limit := s.params.Topics[topic].FirstMessageDeliveriesCap; limit > 0 && c.firstDeliveries > limit {
+		c.firstDeliveries = limit
+	}
+}
+
+func (s *PeerScorer) topicScore(topic string, c *topicCounters) float64 {
+	tp := s.params.Topics[topic]
+	score := c.firstDeliveries * tp.FirstMessageDeliveriesWeight
+	score += c.invalidDeliveries * c.invalidDeliveries * tp.InvalidMessageDeliveriesWeight
+	return score * tp.TopicWeight
+}
+
+// Score returns the current score of a peer.
+func (s *PeerScorer) Score(p peer.ID) float64 {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	var score float64
+	for topic, c := range s.peers[p] {
+		score += s.topicScore(topic, c)
+	}
+	return score
+}
+
+// Scores returns the current score of every peer that sent messages on a
+// scored topic.
+func (s *PeerScorer) Scores() []PeerScore {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	out := make([]PeerScore, 0, len(s.peers))
+	for p, topics := range s.peers {
+		ps := PeerScore{
+			Peer:   p,
+			Topics: make(map[string]float64, len(topics)),
+		}
+		for topic, c := range topics {
+			ts := s.topicScore(topic, c)
+			ps.Topics[topic] = ts
+			ps.Score += ts
+		}
+		out = append(out, ps)
+	}
+	return out
+}
+
+func (s *PeerScorer) decay() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for p, topics := range s.peers {
+		for topic, c := range topics {
+			c.firstDeliveries = decayCounter(c.firstDeliveries, s.factor)
+			c.invalidDeliveries = decayCounter(c.invalidDeliveries, s.factor)
+			if c.firstDeliveries == 0 && c.invalidDeliveries == 0 {
+				delete(topics, topic)
+			}
+		}
+		if len(topics) == 0 {
+			delete(s.peers, p)
+		}
+	}
+}
+
+func decayCounter(v, factor float64) float64 {
+	v *= factor
+	if math.Abs(v) < decayToZero {
+		return 0
+	}
+	return v
+}
+
+// Run periodically decays the score counters until ctx is canceled.
+func (s *PeerScorer) Run(ctx context.Context) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			s.decay()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package corepubsub
+
+import (
+	"context"
+	"testing"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	testutil "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
+	floodsub "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub"
+	pb "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub/pb"
+	proto "gx/ipfs/QmdxUuburamoF6zF9qjeQC4WYcWGbWuRmdLacMEsW8ioD8/gogo-protobuf/proto"
+)
+
+func TestPeerScorerGraylist(t *testing.T) {
+	s, err := NewPeerScorer(extconfig.PubsubPeerScoring{
+		Enabled:           true,
+		GraylistThreshold: -10,
+		Topics: map[string]extconfig.PubsubTopicScoring{
+			"spam": {
+				TopicWeight:                    1,
+				FirstMessageDeliveriesWeight:   1,
+				FirstMessageDeliveriesCap:      5,
+				InvalidMessageDeliveriesWeight: -1,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// p forwards messages claiming to be from author
+	p, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	author, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &floodsub.Message{Message: &pb.Message{From: []byte(author), Data: []byte("x")}}
+	s.senders.record(messageID(msg.Message), p)
+
+	ctx := context.Background()
+	accept := s.Wrap("spam", nil)
+	for i := 0; i < 10; i++ {
+		if !accept(ctx, msg) {
+			t.Fatal("valid message rejected")
+		}
+	}
+	if score := s.Score(p); score != 5 {
+		t.Fatalf("expected score to be capped at 5, got %f", score)
+	}
+
+	reject := s.Wrap("spam", func(context.Context, *floodsub.Message) bool { return false })
+	for i := 0; i < 4; i++ {
+		reject(ctx, msg)
+	}
+	// 5 - 4*4
+	if score := s.Score(p); score != -11 {
+		t.Fatalf("expected score -11, got %f", score)
+	}
+
+	if accept(ctx, msg) {
+		t.Fatal("graylisted peer should be ignored")
+	}
+	if score := s.Score(author); score != 0 {
+		t.Fatalf("expected the claimed author not to be scored, got %f", score)
+	}
+
+	// 5*0.9 - (4*0.9)^2
+	s.decay()
+	if score := s.Score(p); score < -8.47 || score > -8.45 {
+		t.Fatalf("unexpected score after decay: %f", score)
+	}
+}
+
+func TestPeerScorerParams(t *testing.T) {
+	_, err := NewPeerScorer(extconfig.PubsubPeerScoring{DecayFactor: 1})
+	if err == nil {
+		t.Fatal("expected invalid decay factor to fail")
+	}
+
+	_, err = NewPeerScorer(extconfig.PubsubPeerScoring{
+		Topics: map[string]extconfig.PubsubTopicScoring{
+			"t": {InvalidMessageDeliveriesWeight: 1},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected positive invalid delivery weight to fail")
+	}
+}
+
+func TestPeerScorerLocalMessages(t *testing.T) {
+	s, err := NewPeerScorer(extconfig.PubsubPeerScoring{
+		Enabled: true,
+		Topics:  map[string]extconfig.PubsubTopicScoring{"t": {TopicWeight: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &floodsub.Message{Message: &pb.Message{From: []byte(p), Data: []byte("x")}}
+	reject := s.Wrap("t", func(context.Context, *floodsub.Message) bool { return false })
+	if reject(context.Background(), msg) {
+		t.Fatal("expected the validator to be applied to local messages")
+	}
+	if len(s.Scores()) != 0 {
+		t.Fatal("expected the messages published by the node not to be scored")
+	}
+}
+
+func TestWatchedStream(t *testing.T) {
+	sender, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := &watchedStream{senders: newSenders(), from: sender}
+
+	msgs := []*pb.Message{
+		{From: []byte("author"), Seqno: []byte("1")},
+		{From: []byte("author"), Seqno: []byte("2")},
+	}
+	var data []byte
+	for _, m := range msgs {
+		rpc := &pb.RPC{Publish: []*pb.Message{m}}
+		b, err := rpc.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, proto.EncodeVarint(uint64(len(b)))...)
+		data = append(data, b...)
+	}
+	// the RPCs are read in pieces
+	for len(data) > 0 {
+		n := 3
+		if n > len(data) {
+			n = len(data)
+		}
+		ws.feed(data[:n])
+		data = data[n:]
+	}
+
+	for _, m := range msgs {
+		if p, ok := ws.senders.sender(m); !ok || p != sender {
+			t.Fatalf("expected the sender of message %s to be recorded", m.GetSeqno())
+		}
+	}
+	if _, ok := ws.senders.sender(&pb.Message{From: []byte("author"), Seqno: []byte("3")}); ok {
+		t.Fatal("expected no sender for an unknown message")
+	}
+}
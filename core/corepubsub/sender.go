@@ -0,0 +1,144 @@
+package corepubsub
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	pb "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub/pb"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+const (
+	// senderTTL is how long the sender of a message is remembered, the
+	// message is validated long before.
+	senderTTL = 2 * time.Minute
+
+	// maxRPCSize is the size of the largest RPC the pubsub router reads.
+	maxRPCSize = 1 << 20
+)
+
+// senders records the peer each message was received from. The validators
+// are only given the message, whose From field is the author it claims, so
+// the RPCs are read again on the side as they come in.
+type senders struct {
+	lk      sync.Mutex
+	cur     map[string]peer.ID
+	prev    map[string]peer.ID
+	rotated time.Time
+}
+
+func newSenders() *senders {
+	return &senders{
+		cur:     make(map[string]peer.ID),
+		prev:    make(map[string]peer.ID),
+		rotated: time.Now(),
+	}
+}
+
+// messageID is the ID the pubsub router gives to messages.
+func messageID(m *pb.Message) string {
+	return string(m.GetFrom()) + string(m.GetSeqno())
+}
+
+// rotateLocked forgets the senders recorded more than senderTTL ago.
+func (s *senders) rotateLocked() {
+	if time.Since(s.rotated) < senderTTL {
+		return
+	}
+	s.prev, s.cur = s.cur, make(map[string]peer.ID)
+	s.rotated = time.Now()
+}
+
+// record records that p sent the message with the given ID, unless another
+// peer sent it first.
+func (s *senders) record(id string, p peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.rotateLocked()
+	if _, ok := s.cur[id]; ok {
+		return
+	}
+	if _, ok := s.prev[id]; ok {
+		return
+	}
+	s.cur[id] = p
+}
+
+// sender returns the peer m was first received from, false for the messages
+// published by the node itself.
+func (s *senders) sender(m *pb.Message) (peer.ID, bool) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.rotateLocked()
+	id := messageID(m)
+	if p, ok := s.cur[id]; ok {
+		return p, true
+	}
+	p, ok := s.prev[id]
+	return p, ok
+}
+
+// watchedHost hands the pubsub router streams that record the senders of
+// the messages read from them.
+type watchedHost struct {
+	host.Host
+	senders *senders
+}
+
+func (h *watchedHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.Host.SetStreamHandler(pid, func(s inet.Stream) {
+		handler(&watchedStream{Stream: s, senders: h.senders, from: s.Conn().RemotePeer()})
+	})
+}
+
+// watchedStream parses the varint delimited RPCs read from the stream.
+type watchedStream struct {
+	inet.Stream
+	senders *senders
+	from    peer.ID
+
+	buf    []byte
+	broken bool
+}
+
+func (s *watchedStream) Read(b []byte) (int, error) {
+	n, err := s.Stream.Read(b)
+	if n > 0 && !s.broken {
+		s.feed(b[:n])
+	}
+	return n, err
+}
+
+func (s *watchedStream) feed(b []byte) {
+	s.buf = append(s.buf, b...)
+	for {
+		l, n := binary.Uvarint(s.buf)
+		if n == 0 {
+			// incomplete length
+			return
+		}
+		if n < 0 || l > maxRPCSize {
+			// the router will drop the stream too
+			s.broken = true
+			s.buf = nil
+			return
+		}
+		if uint64(len(s.buf)-n) < l {
+			return
+		}
+
+		var rpc pb.RPC
+		if err := rpc.Unmarshal(s.buf[n : n+int(l)]); err == nil {
+			for _, m := range rpc.GetPublish() {
+				s.senders.record(messageID(m), s.from)
+			}
+		}
+		s.buf = append(s.buf[:0], s.buf[n+int(l):]...)
+	}
+}
@@ -0,0 +1,130 @@
+// Package corepubsub contains the pieces of the pubsub subsystem that are
+// configured by go-ipfs itself rather than by the pubsub router.
+package corepubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ci "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	floodsub "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub"
+	pb "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub/pb"
+)
+
+// SignedValidatorName is the name of the built-in validator rejecting
+// messages that carry no valid signature of their author.
+const SignedValidatorName = "signed"
+
+// Validators is used for mapping validator names to pubsub validators
+type Validators map[string]floodsub.Validator
+
+// DefaultValidators is the validator registry used by the node. Plugins add
+// their validators here, the config refers to them by name.
+var DefaultValidators = Validators{
+	SignedValidatorName: validateSigned,
+}
+
+// AddValidator registers a validator under the given name
+func (vs Validators) AddValidator(name string, v floodsub.Validator) error {
+	if _, ok := vs[name]; ok {
+		return fmt.Errorf("pubsub validator %q already registered", name)
+	}
+
+	vs[name] = v
+	return nil
+}
+
+// Apply registers the validators referenced by topics (a map of topic name
+// to validator name) with the pubsub service. If scorer is not nil, messages
+// on scored topics are additionally accounted in the peer scores.
+func (vs Validators) Apply(ps *floodsub.PubSub, topics map[string]string, scorer *PeerScorer) error {
+	validators := make(map[string]floodsub.Validator)
+	for topic, name := range topics {
+		v, ok := vs[name]
+		if !ok {
+			return fmt.Errorf("unknown pubsub validator %q for topic %q", name, topic)
+		}
+		validators[topic] = v
+	}
+
+	if scorer != nil {
+		for topic := range scorer.params.Topics {
+			validators[topic] = scorer.Wrap(topic, validators[topic])
+		}
+	}
+
+	for topic, v := range validators {
+		if err := ps.RegisterTopicValidator(topic, v); err != nil {
+			return fmt.Errorf("registering validator for topic %q: %s", topic, err)
+		}
+	}
+	return nil
+}
+
+// IsSigned returns whether the message carries a valid signature of its
+// author: the signature must verify against the public key of the author,
+// inlined in the message or else derived from the author ID.
+func IsSigned(msg *floodsub.Message) bool {
+	if err := verifySignature(msg.Message); err != nil {
+		log.Debugf("invalid pubsub message signature: %s", err)
+		return false
+	}
+	return true
+}
+
+// verifySignature checks the signature of m the way the pubsub router does
+// with strict signature verification.
+func verifySignature(m *pb.Message) error {
+	if len(m.GetSignature()) == 0 {
+		return errors.New("message is not signed")
+	}
+
+	author, err := peer.IDFromBytes(m.GetFrom())
+	if err != nil {
+		return fmt.Errorf("invalid author: %s", err)
+	}
+
+	var pk ci.PubKey
+	if len(m.GetKey()) == 0 {
+		// no inlined key, it must be derivable from the author ID
+		pk, err = author.ExtractPublicKey()
+		if err == nil && pk == nil {
+			err = errors.New("no key in the author ID")
+		}
+		if err != nil {
+			return fmt.Errorf("cannot extract the signing key: %s", err)
+		}
+	} else {
+		pk, err = ci.UnmarshalPublicKey(m.GetKey())
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal the signing key: %s", err)
+		}
+		if !author.MatchesPublicKey(pk) {
+			return fmt.Errorf("signing key doesn't match the author %s", author.Pretty())
+		}
+	}
+
+	// the signature covers the message without the signature and the key
+	unsigned := *m
+	unsigned.Signature = nil
+	unsigned.Key = nil
+	b, err := unsigned.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ok, err := pk.Verify(append([]byte(floodsub.SignPrefix), b...), m.GetSignature())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("signature doesn't match")
+	}
+	return nil
+}
+
+func validateSigned(_ context.Context, msg *floodsub.Message) bool {
+	return IsSigned(msg)
+}
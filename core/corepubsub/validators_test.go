@@ -0,0 +1,94 @@
+package corepubsub
+
+import (
+	"context"
+	"testing"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	testutil "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
+	floodsub "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub"
+	pb "gx/ipfs/QmY1L5krVk8dv8d74uESmJTXGpoigVYqBVxXXz1aS8aFSb/go-libp2p-floodsub/pb"
+)
+
+func TestAddValidator(t *testing.T) {
+	vs := Validators{}
+	accept := func(context.Context, *floodsub.Message) bool { return true }
+
+	if err := vs.AddValidator("accept", accept); err != nil {
+		t.Fatal(err)
+	}
+	if err := vs.AddValidator("accept", accept); err == nil {
+		t.Fatal("expected duplicate registration to fail")
+	}
+	if _, ok := vs["accept"]; !ok {
+		t.Fatal("validator not registered")
+	}
+}
+
+// signedMessage returns a message on data signed by a new key, the way the
+// pubsub router signs them.
+func signedMessage(t *testing.T, data string) *pb.Message {
+	sk, pk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := pk.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &pb.Message{From: []byte(p), Data: []byte(data), Seqno: []byte("1")}
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Signature, err = sk.Sign(append([]byte(floodsub.SignPrefix), b...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Key = key
+	return m
+}
+
+func TestSignedValidator(t *testing.T) {
+	v, ok := DefaultValidators[SignedValidatorName]
+	if !ok {
+		t.Fatal("signed validator missing from default registry")
+	}
+	ctx := context.Background()
+
+	signed := signedMessage(t, "hello")
+	if !v(ctx, &floodsub.Message{Message: signed}) {
+		t.Fatal("signed message failed validation")
+	}
+
+	unsigned := *signed
+	unsigned.Signature = nil
+	if v(ctx, &floodsub.Message{Message: &unsigned}) {
+		t.Fatal("unsigned message passed validation")
+	}
+
+	junk := *signed
+	junk.Signature = []byte("sig")
+	if v(ctx, &floodsub.Message{Message: &junk}) {
+		t.Fatal("message with a junk signature passed validation")
+	}
+
+	tampered := *signed
+	tampered.Data = []byte("goodbye")
+	if v(ctx, &floodsub.Message{Message: &tampered}) {
+		t.Fatal("tampered message passed validation")
+	}
+
+	// signed by another key than the one of the author
+	other := signedMessage(t, "hello")
+	spoofed := *other
+	spoofed.From = signed.From
+	if v(ctx, &floodsub.Message{Message: &spoofed}) {
+		t.Fatal("message signed by another peer passed validation")
+	}
+}
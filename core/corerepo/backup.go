@@ -0,0 +1,425 @@
+package corerepo
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	pin "github.com/ipfs/go-ipfs/pin"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ci "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// BackupVersion is the version of the backup format.
+const BackupVersion = 1
+
+// The files of a backup archive.
+const (
+	backupManifestFile = "manifest.json"
+	backupConfigFile   = "config"
+	backupKeystoreDir  = "keystore/"
+	backupBlocksFile   = "blocks.car"
+)
+
+// restoreBatchSize is the number of blocks written at once when reading a
+// CAR file.
+const restoreBatchSize = 256
+
+// filesRootKey is the datastore key of the root of the files API.
+var filesRootKey = ds.NewKey("/local/filesroot")
+
+// BackupManifest describes the content of a backup.
+type BackupManifest struct {
+	Version int
+	Created time.Time
+	PeerID  string
+
+	// the pinset
+	Recursive []cid.Cid
+	Direct    []cid.Cid
+
+	// FilesRoot is the root of the files API.
+	FilesRoot *cid.Cid `json:",omitempty"`
+
+	// Blocks is set when the archive holds the blocks of the pins and of
+	// the files API.
+	Blocks bool
+}
+
+// BackupOptions configure Backup.
+type BackupOptions struct {
+	// Config is the content of the config file, without the private key.
+	Config []byte
+	// Blocks adds the blocks of the pins and of the files API.
+	Blocks bool
+}
+
+// Backup writes a zip archive of the node to w: the config, the pinset and
+// the root of the files API, and the blocks they reference with opts.Blocks.
+// The private keys are left out, so that they are never sent through the
+// API: AddBackupKeys adds them from the repo.
+//
+// The node keeps running: the pinset and the files root are read once, and
+// the GC is blocked until the archive is written so that their blocks stay
+// available.
+func Backup(ctx context.Context, n *core.IpfsNode, w io.Writer, opts BackupOptions) error {
+	unlocker := n.Blockstore.PinLock()
+	defer unlocker.Unlock()
+
+	m := BackupManifest{
+		Version:   BackupVersion,
+		Created:   time.Now().UTC(),
+		PeerID:    n.Identity.Pretty(),
+		Recursive: n.Pinning.RecursiveKeys(),
+		Direct:    n.Pinning.DirectKeys(),
+		Blocks:    opts.Blocks,
+	}
+	if n.FilesRoot != nil {
+		roots, err := BestEffortRoots(n.FilesRoot)
+		if err != nil {
+			return err
+		}
+		m.FilesRoot = &roots[0]
+	}
+
+	zw := zip.NewWriter(w)
+
+	manifest, err := json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, backupManifestFile, manifest); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, backupConfigFile, opts.Config); err != nil {
+		return err
+	}
+
+	if opts.Blocks {
+		// the blocks are rarely compressible
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: backupBlocksFile, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err := backupBlocks(ctx, n.Blockstore, f, &m); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// AddBackupKeys copies the backup archive src to w, adding the private keys
+// of the repo at repoPath: the identity of its config and its keystore. The
+// repo must be the one of the backed up node. The files of the repo are read
+// in place, the daemon can keep running.
+func AddBackupKeys(w io.Writer, src *zip.Reader, repoPath string) error {
+	var m BackupManifest
+	for _, f := range src.File {
+		if f.Name == backupManifestFile {
+			if err := readZipJSON(f, &m); err != nil {
+				return fmt.Errorf("invalid backup manifest: %s", err)
+			}
+		}
+	}
+
+	fname, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	rawConfig, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(rawConfig, &cfg); err != nil {
+		return err
+	}
+	if cfg.Identity.PeerID != m.PeerID {
+		return fmt.Errorf("the backup is of %s, not of the node of the repo %s", m.PeerID, repoPath)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range src.File {
+		if f.Name == backupConfigFile {
+			if err := writeZipFile(zw, backupConfigFile, rawConfig); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyZipFile(zw, f); err != nil {
+			return err
+		}
+	}
+
+	ks, err := keystore.NewFSKeystore(filepath.Join(repoPath, "keystore"))
+	if err != nil {
+		return err
+	}
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		k, err := ks.Get(name)
+		if err != nil {
+			return err
+		}
+		b, err := k.Bytes()
+		if err != nil {
+			return err
+		}
+		if err := writeZipFile(zw, backupKeystoreDir+name, b); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func copyZipFile(zw *zip.Writer, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// backupBlocks writes the blocks of the pins and of the files root as a CAR
+// file.
+func backupBlocks(ctx context.Context, bs bstore.Blockstore, w io.Writer, m *BackupManifest) error {
+	roots := append(append([]cid.Cid{}, m.Recursive...), m.Direct...)
+	if m.FilesRoot != nil {
+		roots = append(roots, *m.FilesRoot)
+	}
+	cw, err := newCarWriter(w, roots)
+	if err != nil {
+		return err
+	}
+
+	ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	visited := cid.NewSet()
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if err := cw.put(nd); err != nil {
+			return nil, err
+		}
+		return nd.Links(), nil
+	}
+	walk := func(c cid.Cid) error {
+		if !visited.Visit(c) {
+			return nil
+		}
+		return dag.EnumerateChildren(ctx, getLinks, c, visited.Visit)
+	}
+
+	for _, c := range m.Recursive {
+		if err := walk(c); err != nil {
+			return fmt.Errorf("failed to back up the pin %s: %s", c, err)
+		}
+	}
+	if m.FilesRoot != nil {
+		if err := walk(*m.FilesRoot); err != nil {
+			return fmt.Errorf("failed to back up the files root: %s", err)
+		}
+	}
+	for _, c := range m.Direct {
+		if !visited.Visit(c) {
+			continue
+		}
+		b, err := bs.Get(c)
+		if err != nil {
+			return fmt.Errorf("failed to back up the pin %s: %s", c, err)
+		}
+		if err := cw.put(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreResult sums up a restore.
+type RestoreResult struct {
+	PeerID string
+	Keys   int
+	Pins   int
+	Blocks int
+}
+
+// Restore creates the repo at repoPath from a backup archive written by
+// Backup. The repo must not exist yet.
+func Restore(ctx context.Context, repoPath string, zr *zip.Reader) (*RestoreResult, error) {
+	if fsrepo.IsInitialized(repoPath) {
+		return nil, fmt.Errorf("a repo already exists at %s", repoPath)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var m BackupManifest
+	if err := readZipJSON(files[backupManifestFile], &m); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %s", err)
+	}
+	if m.Version != BackupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", m.Version)
+	}
+	rawConfig, err := readZipFile(files[backupConfigFile])
+	if err != nil {
+		return nil, err
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(rawConfig, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config in the backup: %s", err)
+	}
+	if cfg.Identity.PrivKey == "" {
+		return nil, errors.New("the backup doesn't hold the private key of the node, it was made through the API without 'ipfs repo backup'")
+	}
+
+	if err := fsrepo.Init(repoPath, &cfg); err != nil {
+		return nil, err
+	}
+	// keep the keys of the config unknown to config.Config
+	fname, err := config.Filename(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fname, rawConfig, 0600); err != nil {
+		return nil, err
+	}
+
+	r, err := fsrepo.Open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	res := &RestoreResult{PeerID: m.PeerID}
+
+	ks := r.Keystore()
+	for name, f := range files {
+		if !strings.HasPrefix(name, backupKeystoreDir) {
+			continue
+		}
+		b, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		k, err := ci.UnmarshalPrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %s", name, err)
+		}
+		if err := ks.Put(strings.TrimPrefix(name, backupKeystoreDir), k); err != nil {
+			return nil, err
+		}
+		res.Keys++
+	}
+
+	bs := bstore.NewBlockstore(r.Datastore())
+	if f := files[backupBlocksFile]; f != nil {
+		res.Blocks, err = restoreBlocks(ctx, bs, f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pinner := pin.NewPinner(r.Datastore(), ng, ng)
+	for _, c := range m.Recursive {
+		pinner.PinWithMode(c, pin.Recursive)
+	}
+	for _, c := range m.Direct {
+		pinner.PinWithMode(c, pin.Direct)
+	}
+	if err := pinner.Flush(); err != nil {
+		return nil, err
+	}
+	res.Pins = len(m.Recursive) + len(m.Direct)
+
+	if m.FilesRoot != nil {
+		// the node fails to start with a files root it doesn't have
+		has, err := bs.Has(*m.FilesRoot)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			if err := r.Datastore().Put(filesRootKey, m.FilesRoot.Bytes()); err != nil {
+				return nil, err
+			}
+		} else {
+			log.Warningf("the backup doesn't hold the files root %s, starting with an empty one", m.FilesRoot)
+		}
+	}
+
+	return res, nil
+}
+
+func restoreBlocks(ctx context.Context, bs bstore.Blockstore, f *zip.File) (int, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	cr, err := newCarReader(rc)
+	if err != nil {
+		return 0, err
+	}
+	return cr.putBlocks(ctx, bs)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f == nil {
+		return nil, errors.New("missing file in the backup")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+func readZipJSON(f *zip.File, v interface{}) error {
+	b, err := readZipFile(f)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,237 @@
+package corerepo
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	cbor "gx/ipfs/QmPrv66vmh2P7vLJMpYx6DWLTNKvVB4Jdkyxs6V3QvWKvf/go-ipld-cbor"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// carMaxSection bounds the sections read from a CAR file, way above the
+// size of a block.
+const carMaxSection = 32 << 20
+
+// carWriter writes a CAR (content addressable archive) v1 file: a dag-cbor
+// header listing the roots, then the blocks, each prefixed by the varint
+// length of its cid and data.
+type carWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newCarWriter(w io.Writer, roots []cid.Cid) (*carWriter, error) {
+	if roots == nil {
+		roots = []cid.Cid{}
+	}
+	header, err := cbor.DumpObject(map[string]interface{}{
+		"roots":   roots,
+		"version": 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cw := &carWriter{w: w}
+	if err := cw.section(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *carWriter) section(parts ...[]byte) error {
+	var size int
+	for _, p := range parts {
+		size += len(p)
+	}
+	n := binary.PutUvarint(cw.buf[:], uint64(size))
+	if _, err := cw.w.Write(cw.buf[:n]); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if _, err := cw.w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *carWriter) put(b blocks.Block) error {
+	return cw.section(b.Cid().Bytes(), b.RawData())
+}
+
+// WriteCar writes the DAG of root as a CAR file, each block once, in
+// depth-first order: a block is written where it's first reached, following
+// the links of the nodes in their order. The CAR of a DAG is therefore the
+// same on every node, byte for byte.
+func WriteCar(ctx context.Context, ng ipld.NodeGetter, root cid.Cid, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	cw, err := newCarWriter(bw, []cid.Cid{root})
+	if err != nil {
+		return err
+	}
+
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if err := cw.put(nd); err != nil {
+			return nil, err
+		}
+		return nd.Links(), nil
+	}
+	if err := dag.EnumerateChildren(ctx, getLinks, root, cid.NewSet().Visit); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ImportCar adds the blocks of the CAR file read from r to bs, and returns
+// the roots listed in its header and the number of blocks added.
+func ImportCar(ctx context.Context, bs bstore.Blockstore, r io.Reader) ([]cid.Cid, int, error) {
+	cr, err := newCarReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := cr.putBlocks(ctx, bs)
+	if err != nil {
+		return nil, n, err
+	}
+	return cr.roots, n, nil
+}
+
+// carReader reads the blocks of a CAR v1 file, verifying their hash.
+type carReader struct {
+	r     *bufio.Reader
+	roots []cid.Cid
+}
+
+func newCarReader(r io.Reader) (*carReader, error) {
+	cr := &carReader{r: bufio.NewReader(r)}
+	header, err := cr.section()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAR header: %s", err)
+	}
+	// the header is a dag-cbor map whose only links are the roots
+	nd, err := cbor.Decode(header, math.MaxUint64, -1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAR header: %s", err)
+	}
+	for _, l := range nd.Links() {
+		cr.roots = append(cr.roots, l.Cid)
+	}
+	return cr, nil
+}
+
+func (cr *carReader) section() ([]byte, error) {
+	size, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > carMaxSection {
+		return nil, fmt.Errorf("CAR section of %d bytes is too large", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(cr.r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// next returns the next block, or io.EOF after the last one.
+func (cr *carReader) next() (blocks.Block, error) {
+	data, err := cr.section()
+	if err != nil {
+		return nil, err
+	}
+
+	c, n, err := readCid(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[n:]
+
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("the data of %s doesn't match its hash", c)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// putBlocks adds the blocks left to bs, in batches, and returns their
+// number.
+func (cr *carReader) putBlocks(ctx context.Context, bs bstore.Blockstore) (int, error) {
+	var (
+		n     int
+		batch []blocks.Block
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		b, err := cr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		batch = append(batch, b)
+		if len(batch) == restoreBatchSize {
+			if err := bs.PutMany(batch); err != nil {
+				return n, err
+			}
+			n += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := bs.PutMany(batch); err != nil {
+		return n, err
+	}
+	return n + len(batch), nil
+}
+
+// readCid reads the cid at the start of a section, and returns its length.
+func readCid(data []byte) (cid.Cid, int, error) {
+	// CIDv0 is a bare sha2-256 multihash
+	if len(data) >= 34 && data[0] == 0x12 && data[1] == 0x20 {
+		c, err := cid.Cast(data[:34])
+		return c, 34, err
+	}
+
+	version, n1 := binary.Uvarint(data)
+	if n1 <= 0 || version != 1 {
+		return cid.Cid{}, 0, fmt.Errorf("invalid cid in CAR section")
+	}
+	_, n2 := binary.Uvarint(data[n1:])
+	if n2 <= 0 {
+		return cid.Cid{}, 0, fmt.Errorf("invalid cid in CAR section")
+	}
+	// the multihash: code, length, digest
+	_, n3 := binary.Uvarint(data[n1+n2:])
+	if n3 <= 0 {
+		return cid.Cid{}, 0, fmt.Errorf("invalid cid in CAR section")
+	}
+	length, n4 := binary.Uvarint(data[n1+n2+n3:])
+	if n4 <= 0 {
+		return cid.Cid{}, 0, fmt.Errorf("invalid cid in CAR section")
+	}
+	n := n1 + n2 + n3 + n4 + int(length)
+	if n > len(data) {
+		return cid.Cid{}, 0, fmt.Errorf("truncated cid in CAR section")
+	}
+	c, err := cid.Cast(data[:n])
+	return c, n, err
+}
@@ -0,0 +1,307 @@
+package corerepo
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"sort"
+
+	chunk "github.com/ipfs/go-ipfs/chunker"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// DefaultDedupeTop is the default number of the most shared blocks reported
+// by DedupeStats.
+const DefaultDedupeTop = 10
+
+// DedupeOptions configure DedupeStats.
+type DedupeOptions struct {
+	// Roots are the DAGs analyzed, the whole blockstore when empty.
+	Roots []cid.Cid
+	// Top is the number of the most shared blocks reported.
+	Top int
+	// Chunkers are the chunkers, as accepted by 'ipfs add --chunker',
+	// re-chunking the files to estimate their deduplication.
+	Chunkers []string
+}
+
+// SharedBlock is a block referenced more than once.
+type SharedBlock struct {
+	Key        cid.Cid
+	Size       uint64
+	References uint64
+}
+
+// RechunkStat estimates the deduplication of the files re-chunked with
+// Chunker.
+type RechunkStat struct {
+	Chunker string
+	Chunks  uint64
+	// UniqueSize is the size of the distinct chunks.
+	UniqueSize uint64
+	// Savings is the size saved compared to the current leaves of the
+	// files, negative when re-chunking takes more space.
+	Savings int64
+}
+
+// DedupeStat is the deduplication of the blocks analyzed by DedupeStats.
+type DedupeStat struct {
+	// Blocks is the number of distinct blocks, of UniqueSize bytes.
+	Blocks     uint64
+	UniqueSize uint64
+	// References is the number of links to the blocks, counting the roots
+	// once. ReferencedSize is the size the blocks would take if every
+	// reference had its own copy.
+	References     uint64
+	ReferencedSize uint64
+	// Missing is the number of blocks of the roots not in the blockstore.
+	Missing uint64 `json:",omitempty"`
+
+	// Files is the number of unixfs files, of FileSize bytes, the
+	// distinct data of their leaves taking FileUniqueSize bytes.
+	Files          uint64
+	FileSize       uint64
+	FileUniqueSize uint64
+
+	// TopShared are the blocks whose sharing saves the most space.
+	TopShared []SharedBlock
+	// Rechunked has an estimate per chunker of DedupeOptions.
+	Rechunked []RechunkStat `json:",omitempty"`
+}
+
+// Savings is the size saved by the deduplication of the blocks.
+func (s *DedupeStat) Savings() uint64 {
+	return s.ReferencedSize - s.UniqueSize
+}
+
+// dedupeBlock is a block visited by DedupeStats.
+type dedupeBlock struct {
+	// present tells whether the block is in the blockstore
+	present bool
+	size    uint64
+	refs    uint64
+	// file tells whether the block is part of a unixfs file, holding
+	// dataSize bytes of its data, of a file of fileSize bytes when it's
+	// the root of the file.
+	file     bool
+	dataSize uint64
+	fileSize uint64
+	// fileChild tells whether the block is linked from a block of a file.
+	fileChild bool
+}
+
+// DedupeStats analyzes the deduplication of the blocks of the roots, or of
+// the whole blockstore: the blocks shared by several DAGs or files are only
+// stored once. With Chunkers, the content of the files is read again to
+// estimate how other chunkers would deduplicate it, the files missing blocks
+// being left out.
+//
+// The blocks missing from bs aren't fetched. Every block analyzed is kept
+// in memory with its sizes.
+func DedupeStats(ctx context.Context, bs bstore.Blockstore, opts DedupeOptions) (*DedupeStat, error) {
+	if opts.Top <= 0 {
+		opts.Top = DefaultDedupeTop
+	}
+	ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	var res DedupeStat
+	blocks := make(map[cid.Cid]*dedupeBlock)
+	get := func(c cid.Cid) *dedupeBlock {
+		b, ok := blocks[c]
+		if !ok {
+			b = new(dedupeBlock)
+			blocks[c] = b
+		}
+		return b
+	}
+
+	// visit reads the block c and counts its links, returning them
+	visited := make(map[cid.Cid]bool)
+	visit := func(c cid.Cid) ([]*ipld.Link, error) {
+		if visited[c] {
+			return nil, nil
+		}
+		visited[c] = true
+
+		nd, err := ng.Get(ctx, c)
+		if err == ipld.ErrNotFound {
+			res.Missing++
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		b := get(c)
+		b.present = true
+		b.size = uint64(len(nd.RawData()))
+		dedupeFile(nd, b)
+		for _, l := range nd.Links() {
+			child := get(l.Cid)
+			child.refs++
+			child.fileChild = child.fileChild || b.file
+		}
+		return nd.Links(), nil
+	}
+
+	if len(opts.Roots) == 0 {
+		keys, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k := range keys {
+			if _, err := visit(k); err != nil {
+				return nil, err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, root := range opts.Roots {
+			get(root).refs++
+			stack := []cid.Cid{root}
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				links, err := visit(c)
+				if err != nil {
+					return nil, err
+				}
+				for i := len(links) - 1; i >= 0; i-- {
+					stack = append(stack, links[i].Cid)
+				}
+			}
+		}
+	}
+
+	var shared []SharedBlock
+	var fileRoots []cid.Cid
+	for c, b := range blocks {
+		if !b.present {
+			// linked, but not in the blockstore
+			continue
+		}
+		refs := b.refs
+		if refs == 0 {
+			refs = 1
+		}
+		res.Blocks++
+		res.UniqueSize += b.size
+		res.References += b.refs
+		res.ReferencedSize += refs * b.size
+		if refs > 1 {
+			shared = append(shared, SharedBlock{Key: c, Size: b.size, References: refs})
+		}
+
+		if b.file {
+			res.FileUniqueSize += b.dataSize
+			if !b.fileChild {
+				res.Files++
+				res.FileSize += b.fileSize
+				fileRoots = append(fileRoots, c)
+			}
+		}
+	}
+
+	sort.Slice(shared, func(i, j int) bool {
+		si := (shared[i].References - 1) * shared[i].Size
+		sj := (shared[j].References - 1) * shared[j].Size
+		if si != sj {
+			return si > sj
+		}
+		return shared[i].Key.KeyString() < shared[j].Key.KeyString()
+	})
+	if len(shared) > opts.Top {
+		shared = shared[:opts.Top]
+	}
+	res.TopShared = shared
+
+	for _, chunker := range opts.Chunkers {
+		st, err := rechunk(ctx, ng, fileRoots, chunker)
+		if err != nil {
+			return nil, err
+		}
+		st.Savings = int64(res.FileUniqueSize) - int64(st.UniqueSize)
+		res.Rechunked = append(res.Rechunked, *st)
+	}
+	return &res, nil
+}
+
+// dedupeFile records in b whether nd is a block of a unixfs file, and the
+// sizes of its data.
+func dedupeFile(nd ipld.Node, b *dedupeBlock) {
+	switch n := nd.(type) {
+	case *dag.RawNode:
+		b.file = true
+		b.dataSize = b.size
+		b.fileSize = b.size
+	case *dag.ProtoNode:
+		d, err := ft.FromBytes(n.Data())
+		if err != nil {
+			// not unixfs
+			return
+		}
+		switch d.GetType() {
+		case ft.TFile, ft.TRaw:
+			b.file = true
+			b.dataSize = uint64(len(d.GetData()))
+			b.fileSize = d.GetFilesize()
+		}
+	}
+}
+
+// rechunk re-chunks the content of the files, counting the distinct chunks.
+func rechunk(ctx context.Context, ng ipld.DAGService, files []cid.Cid, chunker string) (*RechunkStat, error) {
+	st := &RechunkStat{Chunker: chunker}
+	seen := make(map[[sha256.Size]byte]struct{})
+	for _, c := range files {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		r, err := uio.NewDagReader(ctx, nd, ng)
+		if err != nil {
+			return nil, err
+		}
+		spl, err := chunk.FromString(r, chunker)
+		if err != nil {
+			return nil, err
+		}
+
+		// the chunks are only counted once the whole file is read, to
+		// leave out the files missing blocks
+		var hashes [][sha256.Size]byte
+		var sizes []uint64
+		for {
+			b, err := spl.NextBytes()
+			if err == io.EOF {
+				break
+			} else if err == ipld.ErrNotFound {
+				hashes = nil
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			hashes = append(hashes, sha256.Sum256(b))
+			sizes = append(sizes, uint64(len(b)))
+		}
+
+		for i, h := range hashes {
+			st.Chunks++
+			if _, ok := seen[h]; ok {
+				continue
+			}
+			seen[h] = struct{}{}
+			st.UniqueSize += sizes[i]
+		}
+	}
+	return st, nil
+}
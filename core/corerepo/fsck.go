@@ -0,0 +1,262 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	filestore "github.com/ipfs/go-ipfs/filestore"
+	pin "github.com/ipfs/go-ipfs/pin"
+	repo "github.com/ipfs/go-ipfs/repo"
+	cidv0v1 "github.com/ipfs/go-ipfs/thirdparty/cidv0v1"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+
+	dshelp "gx/ipfs/QmPQ7bVbZAbGaJkBVJeTkkKXvLLZeN9CLWTf5fzUQ8yeWs/go-ipfs-ds-help"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// Kinds of the problems found by Fsck.
+const (
+	// FsckCorrupt is a block whose content doesn't match its hash, or
+	// can't be read.
+	FsckCorrupt = "corrupt"
+	// FsckMissingPin is a pinned block missing from the blockstore.
+	FsckMissingPin = "missing-pin"
+	// FsckMissingChild is a missing descendant of a recursive pin.
+	FsckMissingChild = "missing-child"
+	// FsckMissingFiles is a missing block of the files API (MFS).
+	FsckMissingFiles = "missing-files"
+)
+
+// Repairs of the corrupt blocks.
+const (
+	// RepairQuarantine moves the corrupt blocks to the quarantine
+	// directory.
+	RepairQuarantine = "quarantine"
+	// RepairDelete removes the corrupt blocks.
+	RepairDelete = "delete"
+)
+
+// fsckProgressInterval is the number of blocks verified between the calls
+// to FsckOptions.Progress.
+const fsckProgressInterval = 1000
+
+// FsckOptions configure Fsck.
+type FsckOptions struct {
+	// Repair is RepairQuarantine or RepairDelete to remove the corrupt
+	// blocks from the blockstore, or empty to only report them.
+	Repair string
+	// QuarantineDir is the directory receiving the quarantined blocks,
+	// one file per block named after its cid.
+	QuarantineDir string
+	// Progress, when set, is called with the number of blocks verified.
+	Progress func(blocks int)
+}
+
+// FsckProblem is a problem found by Fsck.
+type FsckProblem struct {
+	Kind  string
+	Key   cid.Cid
+	Error string `json:",omitempty"`
+	// Repair is the repair applied to the block, if any.
+	Repair string `json:",omitempty"`
+}
+
+func (p *FsckProblem) String() string {
+	s := fmt.Sprintf("%s block %s", p.Kind, p.Key)
+	if p.Error != "" {
+		s += ": " + p.Error
+	}
+	if p.Repair != "" {
+		s += fmt.Sprintf(" (%s)", p.Repair)
+	}
+	return s
+}
+
+// FsckResult sums up a Fsck run.
+type FsckResult struct {
+	Blocks   int
+	Problems int
+}
+
+// Fsck verifies the repo: it re-hashes every block of the blockstore,
+// checks that the pinned blocks and their descendants are present, and that
+// the blocks of the files API are reachable. The problems found are passed
+// to report.
+//
+// It doesn't need a node, so it works on repos that a node can't open, but
+// the repo must not be in use.
+func Fsck(ctx context.Context, r repo.Repo, opts FsckOptions, report func(*FsckProblem)) (*FsckResult, error) {
+	switch opts.Repair {
+	case "", RepairDelete:
+	case RepairQuarantine:
+		if opts.QuarantineDir == "" {
+			return nil, fmt.Errorf("no quarantine directory")
+		}
+	default:
+		return nil, fmt.Errorf("unknown repair %q, expected %q or %q", opts.Repair, RepairQuarantine, RepairDelete)
+	}
+
+	var res FsckResult
+	problem := func(p *FsckProblem) {
+		res.Problems++
+		report(p)
+	}
+
+	blocks, err := fsckBlocks(ctx, r, opts, problem)
+	res.Blocks = blocks
+	if err != nil {
+		return &res, err
+	}
+	if err := fsckReferences(ctx, r, problem); err != nil {
+		return &res, err
+	}
+	return &res, nil
+}
+
+// fsckBlocks re-hashes every block, and repairs the corrupt ones.
+func fsckBlocks(ctx context.Context, r repo.Repo, opts FsckOptions, problem func(*FsckProblem)) (int, error) {
+	bs := bstore.NewBlockstore(r.Datastore())
+	bs.HashOnRead(true)
+
+	keys, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for k := range keys {
+		if _, err := bs.Get(k); err != nil && err != bstore.ErrNotFound {
+			p := &FsckProblem{Kind: FsckCorrupt, Key: k, Error: err.Error()}
+			if opts.Repair != "" {
+				if err := repairBlock(r, bs, opts, k); err != nil {
+					return n, err
+				}
+				p.Repair = opts.Repair
+			}
+			problem(p)
+		}
+
+		n++
+		if opts.Progress != nil && n%fsckProgressInterval == 0 {
+			opts.Progress(n)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return n, err
+	}
+	if opts.Progress != nil {
+		opts.Progress(n)
+	}
+	return n, nil
+}
+
+func repairBlock(r repo.Repo, bs bstore.Blockstore, opts FsckOptions, k cid.Cid) error {
+	if opts.Repair == RepairQuarantine {
+		raw, err := r.Datastore().Get(bstore.BlockPrefix.Child(dshelp.CidToDsKey(k)))
+		switch err {
+		case nil:
+			if err := os.MkdirAll(opts.QuarantineDir, 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filepath.Join(opts.QuarantineDir, k.String()), raw, 0644); err != nil {
+				return err
+			}
+		case ds.ErrNotFound:
+		default:
+			// unreadable, there's nothing to keep
+			log.Warningf("failed to read %s for the quarantine: %s", k, err)
+		}
+	}
+
+	err := bs.DeleteBlock(k)
+	if err == bstore.ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+// fsckReferences checks that the blocks of the pins and of the files API
+// are present.
+func fsckReferences(ctx context.Context, r repo.Repo, problem func(*FsckProblem)) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	var bs bstore.Blockstore = cidv0v1.NewBlockstore(bstore.NewBlockstore(r.Datastore()))
+	if cfg.Experimental.FilestoreEnabled || cfg.Experimental.UrlstoreEnabled {
+		bs = filestore.NewFilestore(bs, r.FileManager())
+	}
+	ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	pinner, err := pin.LoadPinner(r.Datastore(), ng, ng)
+	if err != nil {
+		return fmt.Errorf("failed to load the pins: %s", err)
+	}
+
+	visited := cid.NewSet()
+	walk := func(roots []cid.Cid, rootKind, childKind string, recursive bool) error {
+		getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+			links, err := ipld.GetLinks(ctx, ng, c)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				problem(&FsckProblem{Kind: childKind, Key: c, Error: err.Error()})
+				return nil, nil
+			}
+			return links, nil
+		}
+
+		for _, c := range roots {
+			has, err := bs.Has(c)
+			if err != nil {
+				return err
+			}
+			if !has {
+				problem(&FsckProblem{Kind: rootKind, Key: c})
+				continue
+			}
+			if !recursive || !visited.Visit(c) {
+				continue
+			}
+			if err := dag.EnumerateChildren(ctx, getLinks, c, visited.Visit); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(pinner.RecursiveKeys(), FsckMissingPin, FsckMissingChild, true); err != nil {
+		return err
+	}
+	if err := walk(pinner.InternalPins(), FsckMissingPin, FsckMissingChild, true); err != nil {
+		return err
+	}
+	if err := walk(pinner.DirectKeys(), FsckMissingPin, FsckMissingChild, false); err != nil {
+		return err
+	}
+
+	// the root of the files API, as saved by the node
+	val, err := r.Datastore().Get(filesRootKey)
+	switch err {
+	case nil:
+		c, err := cid.Cast(val)
+		if err != nil {
+			return fmt.Errorf("invalid root of the files API: %s", err)
+		}
+		return walk([]cid.Cid{c}, FsckMissingFiles, FsckMissingFiles, true)
+	case ds.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+}
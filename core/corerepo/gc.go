@@ -7,13 +7,16 @@ import (
 	"time"
 
 	"github.com/ipfs/go-ipfs/core"
+	diskio "github.com/ipfs/go-ipfs/diskio"
 	gc "github.com/ipfs/go-ipfs/pin/gc"
 	repo "github.com/ipfs/go-ipfs/repo"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
 
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
 	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
 	mfs "gx/ipfs/QmRkrpnhZqDxTxwGCsDbuZMr7uCFZHH6SGfrcjgEQwxF3t/go-mfs"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
 )
 
 var log = logging.Logger("corerepo")
@@ -27,6 +30,10 @@ type GC struct {
 	StorageGC  uint64
 	SlackGB    uint64
 	Storage    uint64
+
+	// Incremental makes the collections incremental, with Options.
+	Incremental bool
+	Options     gc.IncrementalOptions
 }
 
 func NewGC(n *core.IpfsNode) (*GC, error) {
@@ -61,12 +68,22 @@ func NewGC(n *core.IpfsNode) (*GC, error) {
 		slackGB = 1
 	}
 
+	gcCfg, err := extconfig.LoadGC(r)
+	if err != nil {
+		return nil, err
+	}
+
 	return &GC{
-		Node:       n,
-		Repo:       r,
-		StorageMax: storageMax,
-		StorageGC:  storageGC,
-		SlackGB:    slackGB,
+		Node:        n,
+		Repo:        r,
+		StorageMax:  storageMax,
+		StorageGC:   storageGC,
+		SlackGB:     slackGB,
+		Incremental: gcCfg.Incremental,
+		Options: gc.IncrementalOptions{
+			MaxRate:   gcCfg.MaxRate,
+			BatchSize: gcCfg.BatchSize,
+		},
 	}, nil
 }
 
@@ -79,14 +96,28 @@ func BestEffortRoots(filesRoot *mfs.Root) ([]cid.Cid, error) {
 	return []cid.Cid{rootDag.Cid()}, nil
 }
 
+// gcRoots returns the roots kept by the garbage collection besides the pins:
+// the root of the files API and the roots of its snapshots.
+func gcRoots(n *core.IpfsNode) ([]cid.Cid, error) {
+	roots, err := BestEffortRoots(n.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := SnapshotRoots(n.Repo.Datastore())
+	if err != nil {
+		return nil, err
+	}
+	return append(roots, snaps...), nil
+}
+
 func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel() // in case error occurs during operation
-	roots, err := BestEffortRoots(n.FilesRoot)
+	roots, err := gcRoots(n)
 	if err != nil {
 		return err
 	}
-	rmed := gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	rmed := gc.GC(ctx, gcBlockstore(n), n.Repo.Datastore(), n.Pinning, roots)
 
 	return CollectResult(ctx, rmed, nil)
 }
@@ -146,7 +177,7 @@ func (e *MultiError) Error() string {
 }
 
 func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result {
-	roots, err := BestEffortRoots(n.FilesRoot)
+	roots, err := gcRoots(n)
 	if err != nil {
 		out := make(chan gc.Result)
 		out <- gc.Result{Error: err}
@@ -154,7 +185,37 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result
 		return out
 	}
 
-	return gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	return gc.GC(ctx, gcBlockstore(n), n.Repo.Datastore(), n.Pinning, roots)
+}
+
+// gcBlockstore returns the blockstore of n counting the operations of the
+// garbage collection.
+func gcBlockstore(n *core.IpfsNode) bstore.GCBlockstore {
+	return diskio.GCBlockstore(n.Blockstore, n.DiskIO.Subsystem(diskio.GC))
+}
+
+// GarbageCollectDryRun outputs the blocks that a garbage collection would
+// remove, with their size, without removing them.
+func GarbageCollectDryRun(n *core.IpfsNode, ctx context.Context) <-chan gc.Result {
+	roots, err := gcRoots(n)
+	if err != nil {
+		out := make(chan gc.Result, 1)
+		out <- gc.Result{Error: err}
+		close(out)
+		return out
+	}
+
+	return gc.DryRun(ctx, n.Blockstore, n.Pinning, roots)
+}
+
+// GarbageCollectIncremental starts an incremental garbage collection, which
+// keeps the blocks of the files API and of its snapshots as
+// GarbageCollectAsync does.
+func GarbageCollectIncremental(n *core.IpfsNode, ctx context.Context, opts gc.IncrementalOptions) <-chan gc.Result {
+	opts.BestEffortRoots = func() ([]cid.Cid, error) {
+		return gcRoots(n)
+	}
+	return gc.Incremental(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, opts)
 }
 
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
@@ -217,7 +278,12 @@ func (gc *GC) maybeGC(ctx context.Context, offset uint64) error {
 		log.Info("Watermark exceeded. Starting repo GC...")
 		defer log.EventBegin(ctx, "repoGC").Done()
 
-		if err := GarbageCollect(gc.Node, ctx); err != nil {
+		if gc.Incremental {
+			rmed := GarbageCollectIncremental(gc.Node, ctx, gc.Options)
+			if err := CollectResult(ctx, rmed, nil); err != nil {
+				return err
+			}
+		} else if err := GarbageCollect(gc.Node, ctx); err != nil {
 			return err
 		}
 		log.Infof("Repo GC done. See `ipfs repo stat` to see how much space got freed.\n")
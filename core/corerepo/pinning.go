@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/ipfs/go-ipfs/core"
+	dagtransfer "github.com/ipfs/go-ipfs/dagtransfer"
 	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
 	path "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path"
 	resolver "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path/resolver"
@@ -43,6 +44,9 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		if err != nil {
 			return nil, fmt.Errorf("pin: %s", err)
 		}
+		if recursive {
+			dagtransfer.Prefetch(ctx, n.DAGTransfer, dagnode.Cid())
+		}
 		err = n.Pinning.Pin(ctx, dagnode, recursive)
 		if err != nil {
 			return nil, fmt.Errorf("pin: %s", err)
@@ -0,0 +1,120 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ipfs/go-ipfs/core"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+)
+
+// PinManifestVersion is the version of the pinset manifest format.
+const PinManifestVersion = 1
+
+// PinManifest is a portable description of a pinset, written by ExportPins
+// and read by ImportPins.
+type PinManifest struct {
+	Version int
+	Pins    []PinManifestEntry
+}
+
+// PinManifestEntry is a pin of a PinManifest.
+type PinManifestEntry struct {
+	Cid string
+	// Type is "recursive" or "direct".
+	Type string
+}
+
+// ExportPins returns the recursive and direct pins of the node. The pins
+// are sorted, so that the manifest of an unchanged pinset doesn't change.
+func ExportPins(n *core.IpfsNode) *PinManifest {
+	m := &PinManifest{Version: PinManifestVersion}
+	add := func(keys []cid.Cid, mode pin.Mode) {
+		typ, _ := pin.ModeToString(mode)
+		start := len(m.Pins)
+		for _, c := range keys {
+			m.Pins = append(m.Pins, PinManifestEntry{Cid: c.String(), Type: typ})
+		}
+		added := m.Pins[start:]
+		sort.Slice(added, func(i, j int) bool { return added[i].Cid < added[j].Cid })
+	}
+	add(n.Pinning.RecursiveKeys(), pin.Recursive)
+	add(n.Pinning.DirectKeys(), pin.Direct)
+	return m
+}
+
+// PinImportResult is the outcome of the import of a pin.
+type PinImportResult struct {
+	Cid  cid.Cid
+	Type string
+	// Skipped is set when the pin was already there.
+	Skipped bool
+}
+
+// ImportPins adds the pins of a manifest to the node, fetching their blocks
+// as needed. The pins already there are skipped, and a direct pin of a
+// recursively pinned object is kept recursive. Each imported pin is passed
+// to report.
+//
+// The pinset is saved once at the end, or when an error stops the import so
+// that the pins already added are kept.
+func ImportPins(ctx context.Context, n *core.IpfsNode, m *PinManifest, report func(*PinImportResult)) (err error) {
+	if m.Version != PinManifestVersion {
+		return fmt.Errorf("unsupported pin manifest version %d", m.Version)
+	}
+
+	// validate the whole manifest before pinning anything
+	entries := make([]PinImportResult, len(m.Pins))
+	for i, p := range m.Pins {
+		c, err := cid.Decode(p.Cid)
+		if err != nil {
+			return fmt.Errorf("invalid pin %q: %s", p.Cid, err)
+		}
+		mode, ok := pin.StringToMode(p.Type)
+		if !ok || (mode != pin.Recursive && mode != pin.Direct) {
+			return fmt.Errorf("invalid type %q of the pin %s, expected \"recursive\" or \"direct\"", p.Type, p.Cid)
+		}
+		entries[i] = PinImportResult{Cid: c, Type: p.Type}
+	}
+
+	defer n.Blockstore.PinLock().Unlock()
+	defer func() {
+		if ferr := n.Pinning.Flush(); err == nil {
+			err = ferr
+		}
+	}()
+
+	for i := range entries {
+		res := &entries[i]
+		recursive := res.Type == "recursive"
+
+		_, pinned, err := n.Pinning.IsPinnedWithType(res.Cid, pin.Recursive)
+		if err != nil {
+			return err
+		}
+		if !pinned && !recursive {
+			_, pinned, err = n.Pinning.IsPinnedWithType(res.Cid, pin.Direct)
+			if err != nil {
+				return err
+			}
+		}
+		if pinned {
+			res.Skipped = true
+			report(res)
+			continue
+		}
+
+		nd, err := n.DAG.Get(ctx, res.Cid)
+		if err != nil {
+			return fmt.Errorf("pin %s: %s", res.Cid, err)
+		}
+		if err := n.Pinning.Pin(ctx, nd, recursive); err != nil {
+			return fmt.Errorf("pin %s: %s", res.Cid, err)
+		}
+		report(res)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+)
+
+// snapshotsPrefix is the datastore prefix of the snapshots of the files API,
+// keyed by their name.
+var snapshotsPrefix = ds.NewKey("/local/filessnapshots")
+
+// ErrSnapshotNotFound is returned for the snapshots not in the log.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+var snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// snapshotLk serializes the changes of the snapshot log.
+var snapshotLk sync.Mutex
+
+// Snapshot is a recorded root of the files API. The blocks of the snapshots
+// are kept by the garbage collection, as the blocks of the files API are.
+type Snapshot struct {
+	Name string
+	Cid  cid.Cid
+	Time time.Time
+	// Auto is set on the snapshots taken automatically, which are removed
+	// once there are more than Files.MaxAutoSnapshots of them.
+	Auto bool `json:",omitempty"`
+}
+
+// RestoreSnapshotResult is the outcome of RestoreSnapshot.
+type RestoreSnapshotResult struct {
+	// Restored is the snapshot the files API was restored to.
+	Restored Snapshot
+	// Previous is the snapshot of the files API before the restore.
+	Previous Snapshot
+}
+
+func snapshotKey(name string) ds.Key {
+	return snapshotsPrefix.ChildString(name)
+}
+
+// ListSnapshots returns the snapshots of the files API, oldest first.
+func ListSnapshots(d ds.Datastore) ([]Snapshot, error) {
+	res, err := d.Query(dsq.Query{Prefix: snapshotsPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var out []Snapshot
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var s Snapshot
+		if err := json.Unmarshal(r.Value, &s); err != nil {
+			log.Warningf("invalid files snapshot %s: %s", r.Key, err)
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// GetSnapshot returns the snapshot named name.
+func GetSnapshot(d ds.Datastore, name string) (*Snapshot, error) {
+	val, err := d.Get(snapshotKey(name))
+	if err == ds.ErrNotFound {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := new(Snapshot)
+	if err := json.Unmarshal(val, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RemoveSnapshot removes the snapshot named name from the log. Its blocks
+// are then removed by the next garbage collection, unless they are still
+// referenced.
+func RemoveSnapshot(d ds.Datastore, name string) error {
+	snapshotLk.Lock()
+	defer snapshotLk.Unlock()
+
+	if _, err := GetSnapshot(d, name); err != nil {
+		return err
+	}
+	return d.Delete(snapshotKey(name))
+}
+
+// SnapshotRoots returns the roots of the snapshots, for the garbage
+// collection.
+func SnapshotRoots(d ds.Datastore) ([]cid.Cid, error) {
+	snaps, err := ListSnapshots(d)
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]cid.Cid, 0, len(snaps))
+	for _, s := range snaps {
+		roots = append(roots, s.Cid)
+	}
+	return roots, nil
+}
+
+// CreateSnapshot flushes the files API and records its root as the snapshot
+// named name. An empty name uses the time of the snapshot.
+func CreateSnapshot(ctx context.Context, n *core.IpfsNode, name string) (*Snapshot, error) {
+	snapshotLk.Lock()
+	defer snapshotLk.Unlock()
+
+	return createSnapshot(n, name, false)
+}
+
+// AutoSnapshot records the root of the files API as an automatic snapshot,
+// when Files.AutoSnapshot is enabled, and removes the automatic snapshots
+// beyond Files.MaxAutoSnapshots. It returns nil when no snapshot was taken.
+func AutoSnapshot(ctx context.Context, n *core.IpfsNode) (*Snapshot, error) {
+	cfg, err := extconfig.LoadFiles(n.Repo)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.AutoSnapshot.WithDefault(false) {
+		return nil, nil
+	}
+
+	snapshotLk.Lock()
+	defer snapshotLk.Unlock()
+
+	s, err := createSnapshot(n, "", true)
+	if err != nil {
+		return nil, err
+	}
+	return s, pruneAutoSnapshots(n.Repo.Datastore(), cfg.MaxAuto())
+}
+
+func createSnapshot(n *core.IpfsNode, name string, auto bool) (*Snapshot, error) {
+	d := n.Repo.Datastore()
+	if name != "" {
+		if !snapshotNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid snapshot name %q: only letters, digits, '.', '_' and '-' are allowed", name)
+		}
+		if has, err := d.Has(snapshotKey(name)); err != nil {
+			return nil, err
+		} else if has {
+			return nil, fmt.Errorf("snapshot %q already exists", name)
+		}
+	}
+
+	if err := n.FilesRoot.Flush(); err != nil {
+		return nil, err
+	}
+	roots, err := BestEffortRoots(n.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if name == "" {
+		if name, err = defaultSnapshotName(d, now, auto); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Snapshot{Name: name, Cid: roots[0], Time: now, Auto: auto}
+	val, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Put(snapshotKey(name), val); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// defaultSnapshotName names a snapshot after its time, with a suffix when a
+// snapshot of that name exists.
+func defaultSnapshotName(d ds.Datastore, t time.Time, auto bool) (string, error) {
+	base := t.Format("20060102T150405Z")
+	if auto {
+		base = "auto-" + base
+	}
+	name := base
+	for i := 1; ; i++ {
+		has, err := d.Has(snapshotKey(name))
+		if err != nil {
+			return "", err
+		}
+		if !has {
+			return name, nil
+		}
+		name = base + "-" + strconv.Itoa(i)
+	}
+}
+
+func pruneAutoSnapshots(d ds.Datastore, max int) error {
+	snaps, err := ListSnapshots(d)
+	if err != nil {
+		return err
+	}
+	var auto []Snapshot
+	for _, s := range snaps {
+		if s.Auto {
+			auto = append(auto, s)
+		}
+	}
+	for len(auto) > max {
+		if err := d.Delete(snapshotKey(auto[0].Name)); err != nil {
+			return err
+		}
+		auto = auto[1:]
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces the content of the files API with the snapshot
+// named name. The files API is first snapshotted automatically, so that the
+// restore can be undone.
+func RestoreSnapshot(ctx context.Context, n *core.IpfsNode, name string) (*RestoreSnapshotResult, error) {
+	snapshotLk.Lock()
+	defer snapshotLk.Unlock()
+
+	d := n.Repo.Datastore()
+	s, err := GetSnapshot(d, name)
+	if err != nil {
+		return nil, err
+	}
+
+	nd, err := n.DAG.Get(ctx, s.Cid)
+	if err != nil {
+		return nil, fmt.Errorf("error loading snapshot %q: %s", name, err)
+	}
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, dag.ErrNotProtobuf
+	}
+
+	prev, err := createSnapshot(n, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := n.FilesRoot.GetDirectory()
+	names, err := dir.ListNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := dir.Unlink(name); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range pbnd.Links() {
+		child, err := l.GetNode(ctx, n.DAG)
+		if err != nil {
+			return nil, err
+		}
+		if err := dir.AddChild(l.Name, child); err != nil {
+			return nil, err
+		}
+	}
+	if err := n.FilesRoot.Flush(); err != nil {
+		return nil, err
+	}
+
+	return &RestoreSnapshotResult{Restored: *s, Previous: *prev}, nil
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 
+	chunker "github.com/ipfs/go-ipfs/chunker"
 	core "github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/pin"
 	unixfs "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
@@ -24,7 +25,6 @@ import (
 	mfs "gx/ipfs/QmRkrpnhZqDxTxwGCsDbuZMr7uCFZHH6SGfrcjgEQwxF3t/go-mfs"
 	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
 	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
-	chunker "gx/ipfs/QmdSeG9s4EQ9TGruJJS9Us38TQDZtMmFGwzTYUDVqNTURm/go-ipfs-chunker"
 	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
 )
 
@@ -92,6 +92,23 @@ type Adder struct {
 	tempRoot   cid.Cid
 	CidBuilder cid.Builder
 	liveNodes  uint64
+
+	// PreserveMode and PreserveMtime store the permissions and the
+	// modification time of the added files and directories in their nodes.
+	PreserveMode  bool
+	PreserveMtime bool
+	dirAttrs      map[string]FileAttrs
+
+	// ShardThreshold, when set, shards the directories whose estimated
+	// size is above it and only them, whatever uio.UseHAMTSharding is.
+	ShardThreshold int64
+
+	// Workers is the number of files chunked and hashed concurrently. The
+	// nodes are then written in batches.
+	Workers int
+	workers chan struct{}
+	queue   []*addJob
+	batch   *batchDAG
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -168,6 +185,10 @@ func (adder *Adder) RootNode() (ipld.Node, error) {
 // Recursively pins the root node of Adder and
 // writes the pin state to the backing datastore.
 func (adder *Adder) PinRoot() error {
+	if err := adder.sync(); err != nil {
+		return err
+	}
+
 	root, err := adder.RootNode()
 	if err != nil {
 		return err
@@ -182,6 +203,9 @@ func (adder *Adder) PinRoot() error {
 	if err != nil {
 		return err
 	}
+	if err := adder.sync(); err != nil {
+		return err
+	}
 
 	if adder.tempRoot.Defined() {
 		err := adder.pinning.Unpin(adder.ctx, adder.tempRoot, true)
@@ -197,6 +221,10 @@ func (adder *Adder) PinRoot() error {
 
 // Finalize flushes the mfs root directory and returns the mfs root node.
 func (adder *Adder) Finalize() (ipld.Node, error) {
+	if err := adder.sync(); err != nil {
+		return nil, err
+	}
+
 	mr, err := adder.mfsRoot()
 	if err != nil {
 		return nil, err
@@ -229,54 +257,166 @@ func (adder *Adder) Finalize() (ipld.Node, error) {
 		}
 	}
 
-	err = adder.outputDirs(name, root)
+	nd, err := adder.outputDirs(name, root)
 	if err != nil {
 		return nil, err
 	}
+	if err := adder.sync(); err != nil {
+		return nil, err
+	}
 
 	err = mr.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	if adder.rewritesDirs() {
+		// the mfs root doesn't have the rewritten directories
+		adder.root = nd
+		return nd, nil
+	}
 	return root.GetNode()
 }
 
-func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
+// fileAttrs returns the attributes of the file to preserve, if any: those
+// received with it through ReceiveFileAttrs, or else those of the local file.
+// There are none for stdin.
+func (adder *Adder) fileAttrs(file files.File) (FileAttrs, bool) {
+	if !adder.PreserveMode && !adder.PreserveMtime {
+		return FileAttrs{}, false
+	}
+	if af, ok := file.(attrsFile); ok {
+		if a, ok := af.FileAttrs(); ok {
+			return a.preserved(adder.PreserveMode, adder.PreserveMtime), true
+		}
+	}
+	fi, ok := file.(files.FileInfo)
+	if !ok || fi.Stat() == nil || fi.AbsPath() == os.Stdin.Name() {
+		return FileAttrs{}, false
+	}
+	return FileAttrsFromInfo(fi.Stat(), adder.PreserveMode, adder.PreserveMtime), true
+}
+
+func (adder *Adder) setFileAttrs(nd ipld.Node, attrs FileAttrs) (ipld.Node, error) {
+	if pi, ok := nd.(*posinfo.FilestoreNode); ok {
+		nd = pi.Node
+	}
+	pn, err := SetFileAttrs(nd, attrs, adder.CidBuilder)
+	if err != nil {
+		return nil, err
+	}
+	if err := adder.dagService.Add(adder.ctx, pn); err != nil {
+		return nil, err
+	}
+	return pn, nil
+}
+
+// outputDirs outputs the directories, setting their attributes, and returns
+// the node of fsn.
+func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) (ipld.Node, error) {
 	switch fsn := fsn.(type) {
 	case *mfs.File:
-		return nil
+		if !adder.rewritesDirs() {
+			return nil, nil
+		}
+		return fsn.GetNode()
 	case *mfs.Directory:
 		names, err := fsn.ListNames(adder.ctx)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
+		children := make(map[string]ipld.Node, len(names))
 		for _, name := range names {
 			child, err := fsn.Child(name)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			childpath := gopath.Join(path, name)
-			err = adder.outputDirs(childpath, child)
+			children[name], err = adder.outputDirs(childpath, child)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			fsn.Uncache(name)
 		}
 		nd, err := fsn.GetNode()
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if adder.ShardThreshold > 0 {
+			nd, err = adder.reshardDir(nd, children)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if adder.rewritesDirs() {
+			nd, err = adder.setDirAttrs(path, nd, children)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		return outputDagnode(adder.Out, path, nd)
+		return nd, outputDagnode(adder.Out, path, nd)
 	default:
-		return fmt.Errorf("unrecognized fsn type: %#v", fsn)
+		return nil, fmt.Errorf("unrecognized fsn type: %#v", fsn)
 	}
 }
 
+// rewritesDirs returns whether the directories built by mfs are rewritten
+// once the files are added.
+func (adder *Adder) rewritesDirs() bool {
+	return len(adder.dirAttrs) > 0 || adder.ShardThreshold > 0
+}
+
+// setDirAttrs returns the directory node with its attributes, linking to
+// the children with theirs.
+func (adder *Adder) setDirAttrs(path string, nd ipld.Node, children map[string]ipld.Node) (ipld.Node, error) {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nd, nil
+	}
+
+	var changed bool
+	links := make([]*ipld.Link, len(pn.Links()))
+	for i, l := range pn.Links() {
+		links[i] = l
+
+		child, ok := children[l.Name]
+		if !ok || child.Cid().Equals(l.Cid) {
+			continue
+		}
+		nl, err := ipld.MakeLink(child)
+		if err != nil {
+			return nil, err
+		}
+		nl.Name = l.Name
+		links[i] = nl
+		changed = true
+	}
+
+	attrs, ok := adder.dirAttrs[path]
+	if !ok && !changed {
+		return nd, nil
+	}
+
+	out := pn.Copy().(*dag.ProtoNode)
+	out.SetLinks(links)
+	if ok {
+		data, err := AppendFileAttrs(out.Data(), attrs)
+		if err != nil {
+			return nil, err
+		}
+		out.SetData(data)
+	}
+	if err := adder.dagService.Add(adder.ctx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Add builds a merkledag node from a reader, adds it to the blockstore,
 // and returns the key representing that node.
 // If you want to pin it, use NewAdder() and Adder.PinRoot().
@@ -286,12 +426,19 @@ func Add(n *core.IpfsNode, r io.Reader) (string, error) {
 
 // AddWithContext does the same as Add, but with a custom context.
 func AddWithContext(ctx context.Context, n *core.IpfsNode, r io.Reader) (string, error) {
+	return AddWithChunker(ctx, n, r, "")
+}
+
+// AddWithChunker is like AddWithContext, splitting the data with the chunker
+// described by chunkerStr, as accepted by chunker.FromString.
+func AddWithChunker(ctx context.Context, n *core.IpfsNode, r io.Reader, chunkerStr string) (string, error) {
 	defer n.Blockstore.PinLock().Unlock()
 
 	fileAdder, err := NewAdder(ctx, n.Pinning, n.Blockstore, n.DAG)
 	if err != nil {
 		return "", err
 	}
+	fileAdder.Chunker = chunkerStr
 
 	node, err := fileAdder.add(r)
 	if err != nil {
@@ -301,6 +448,24 @@ func AddWithContext(ctx context.Context, n *core.IpfsNode, r io.Reader) (string,
 	return node.Cid().String(), nil
 }
 
+// BuildFile builds in ds the DAG `ipfs add` builds for the data of r with
+// the same chunker, raw leaves and CID builder, and returns its root. The
+// DAG is neither pinned nor linked anywhere.
+func BuildFile(r io.Reader, ds ipld.DAGService, chunkerStr string, rawLeaves bool, builder cid.Builder) (ipld.Node, error) {
+	chnk, err := chunker.FromString(r, chunkerStr)
+	if err != nil {
+		return nil, err
+	}
+
+	params := ihelper.DagBuilderParams{
+		Dagserv:    ds,
+		RawLeaves:  rawLeaves,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: builder,
+	}
+	return balanced.Layout(params.New(chnk))
+}
+
 // AddR recursively adds files in |path|.
 func AddR(n *core.IpfsNode, root string) (key string, err error) {
 	defer n.Blockstore.PinLock().Unlock()
@@ -409,7 +574,12 @@ func (adder *Adder) AddFile(file files.File) error {
 		}
 	}()
 
-	return adder.addFile(file)
+	adder.startWorkers()
+	err := adder.addFile(file)
+	if serr := adder.sync(); err == nil {
+		err = serr
+	}
+	return err
 }
 
 func (adder *Adder) addFile(file files.File) error {
@@ -466,11 +636,6 @@ func (adder *Adder) addFile(file files.File) error {
 		}
 	}
 
-	dagnode, err := adder.add(reader)
-	if err != nil {
-		return err
-	}
-
 	addFileName := file.FileName()
 	addFileInfo, ok := file.(files.FileInfo)
 	if ok {
@@ -479,10 +644,33 @@ func (adder *Adder) addFile(file files.File) error {
 			adder.Name = ""
 		}
 	}
+
+	attrs, hasAttrs := adder.fileAttrs(file)
+	if adder.workers != nil {
+		return adder.addFileAsync(reader, addFileName, attrs, hasAttrs)
+	}
+
+	dagnode, err := adder.buildFile(reader, attrs, hasAttrs)
+	if err != nil {
+		return err
+	}
+
 	// patch it into the root
 	return adder.addNode(dagnode, addFileName)
 }
 
+// buildFile builds the DAG of a regular file, with its attributes.
+func (adder *Adder) buildFile(r io.Reader, attrs FileAttrs, hasAttrs bool) (ipld.Node, error) {
+	nd, err := adder.add(r)
+	if err != nil {
+		return nil, err
+	}
+	if hasAttrs {
+		return adder.setFileAttrs(nd, attrs)
+	}
+	return nd, nil
+}
+
 func (adder *Adder) addDir(dir files.File) error {
 	log.Infof("adding directory: %s", dir.FileName())
 
@@ -499,6 +687,15 @@ func (adder *Adder) addDir(dir files.File) error {
 		return err
 	}
 
+	// the directory nodes are rebuilt by mfs until the end, their
+	// attributes are set by Finalize
+	if attrs, ok := adder.fileAttrs(dir); ok {
+		if adder.dirAttrs == nil {
+			adder.dirAttrs = make(map[string]FileAttrs)
+		}
+		adder.dirAttrs[dir.FileName()] = attrs
+	}
+
 	for {
 		file, err := dir.NextFile()
 		if err != nil && err != io.EOF {
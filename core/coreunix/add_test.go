@@ -270,3 +270,33 @@ func (fi *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (fi *dummyFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *dummyFileInfo) IsDir() bool        { return false }
 func (fi *dummyFileInfo) Sys() interface{}   { return nil }
+
+func TestBuildFile(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	k, err := AddWithChunker(context.Background(), node, bytes.NewReader(data), "size-1024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := BuildFile(bytes.NewReader(data), node.DAG, "size-1024", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Cid().String() != k {
+		t.Fatalf("expected the DAG of ipfs add, %s, got %s", k, nd.Cid())
+	}
+}
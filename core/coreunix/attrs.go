@@ -0,0 +1,266 @@
+package coreunix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// The fields of the unixfs Data message holding the attributes, as in the
+// UnixFS v1.5 spec. The unixfs package doesn't know them: they are appended
+// to the encoded message, and kept as unknown fields when it's decoded and
+// encoded again.
+const (
+	attrsModeField  = 7
+	attrsMtimeField = 8
+
+	// the fields of the UnixTime message
+	mtimeSecondsField = 1
+	mtimeNanosField   = 2
+)
+
+// the protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errInvalidProto = errors.New("invalid unixfs data")
+
+// FileAttrs are the POSIX attributes of a file or directory stored in its
+// unixfs node.
+type FileAttrs struct {
+	// Mode holds the permission bits, with the setuid, setgid and sticky
+	// bits. It's only set when HasMode is.
+	Mode    os.FileMode
+	HasMode bool
+	// Mtime is the modification time, zero when not stored.
+	Mtime time.Time
+}
+
+// IsZero returns whether no attribute is set.
+func (a FileAttrs) IsZero() bool {
+	return !a.HasMode && a.Mtime.IsZero()
+}
+
+// FileAttrsFromInfo returns the attributes of a file to preserve.
+func FileAttrsFromInfo(fi os.FileInfo, mode, mtime bool) FileAttrs {
+	var a FileAttrs
+	if mode {
+		a.Mode = fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		a.HasMode = true
+	}
+	if mtime {
+		a.Mtime = fi.ModTime()
+	}
+	return a
+}
+
+// UnixMode returns the mode as the st_mode permission bits.
+func (a FileAttrs) UnixMode() int64 {
+	m := int64(a.Mode.Perm())
+	if a.Mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if a.Mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if a.Mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// FileModeFromUnix returns the mode of st_mode permission bits.
+func FileModeFromUnix(m uint64) os.FileMode {
+	mode := os.FileMode(m) & os.ModePerm
+	if m&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// AppendFileAttrs returns the unixfs data with the attributes set, replacing
+// the ones already there.
+func AppendFileAttrs(data []byte, a FileAttrs) ([]byte, error) {
+	out := make([]byte, 0, len(data)+32)
+	err := walkProtoFields(data, func(num, wire int, field, _ []byte) error {
+		if num != attrsModeField && num != attrsMtimeField {
+			out = append(out, field...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if a.HasMode {
+		out = appendProtoKey(out, attrsModeField, wireVarint)
+		out = appendUvarint(out, uint64(a.UnixMode()))
+	}
+	if !a.Mtime.IsZero() {
+		var mtime []byte
+		mtime = appendProtoKey(mtime, mtimeSecondsField, wireVarint)
+		mtime = appendUvarint(mtime, uint64(a.Mtime.Unix()))
+		if nanos := a.Mtime.Nanosecond(); nanos != 0 {
+			mtime = appendProtoKey(mtime, mtimeNanosField, wireFixed32)
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], uint32(nanos))
+			mtime = append(mtime, buf[:]...)
+		}
+		out = appendProtoKey(out, attrsMtimeField, wireBytes)
+		out = appendUvarint(out, uint64(len(mtime)))
+		out = append(out, mtime...)
+	}
+	return out, nil
+}
+
+// FileAttrsFromData returns the attributes stored in unixfs data.
+func FileAttrsFromData(data []byte) (FileAttrs, error) {
+	var a FileAttrs
+	err := walkProtoFields(data, func(num, wire int, _, value []byte) error {
+		switch {
+		case num == attrsModeField && wire == wireVarint:
+			m, _ := binary.Uvarint(value)
+			a.Mode = FileModeFromUnix(m)
+			a.HasMode = true
+		case num == attrsMtimeField && wire == wireBytes:
+			var secs, nanos uint64
+			err := walkProtoFields(value, func(num, wire int, _, value []byte) error {
+				switch {
+				case num == mtimeSecondsField && wire == wireVarint:
+					secs, _ = binary.Uvarint(value)
+				case num == mtimeNanosField && wire == wireFixed32:
+					nanos = uint64(binary.LittleEndian.Uint32(value))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if nanos > 999999999 {
+				return fmt.Errorf("invalid mtime nanoseconds %d", nanos)
+			}
+			a.Mtime = time.Unix(int64(secs), int64(nanos))
+		}
+		return nil
+	})
+	return a, err
+}
+
+// GetFileAttrs returns the attributes of a unixfs node. Raw nodes have none.
+func GetFileAttrs(nd ipld.Node) (FileAttrs, error) {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return FileAttrs{}, nil
+	}
+	return FileAttrsFromData(pn.Data())
+}
+
+// SetFileAttrs returns a copy of the unixfs node with the attributes set. A
+// raw node, which can't hold them, is wrapped in a file node. The returned
+// node is not added to the DAG.
+func SetFileAttrs(nd ipld.Node, a FileAttrs, builder cid.Builder) (*dag.ProtoNode, error) {
+	switch nd := nd.(type) {
+	case *dag.ProtoNode:
+		data, err := AppendFileAttrs(nd.Data(), a)
+		if err != nil {
+			return nil, err
+		}
+		pn := nd.Copy().(*dag.ProtoNode)
+		pn.SetData(data)
+		return pn, nil
+	case *dag.RawNode:
+		fsn := ft.NewFSNode(ft.TFile)
+		fsn.AddBlockSize(uint64(len(nd.RawData())))
+		fsnData, err := fsn.GetBytes()
+		if err != nil {
+			return nil, err
+		}
+		data, err := AppendFileAttrs(fsnData, a)
+		if err != nil {
+			return nil, err
+		}
+		pn := dag.NodeWithData(data)
+		if builder != nil {
+			pn.SetCidBuilder(builder)
+		}
+		if err := pn.AddNodeLink("", nd); err != nil {
+			return nil, err
+		}
+		return pn, nil
+	default:
+		return nil, fmt.Errorf("cannot set the attributes of a %T", nd)
+	}
+}
+
+// walkProtoFields calls fn with each field of an encoded protobuf message:
+// its number and wire type, the whole encoded field and its value.
+func walkProtoFields(data []byte, fn func(num, wire int, field, value []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errInvalidProto
+		}
+		num, wire := int(key>>3), int(key&7)
+
+		var end int
+		start := n
+		switch wire {
+		case wireVarint:
+			_, m := binary.Uvarint(data[n:])
+			if m <= 0 {
+				return errInvalidProto
+			}
+			end = n + m
+		case wireFixed64:
+			end = n + 8
+		case wireBytes:
+			l, m := binary.Uvarint(data[n:])
+			if m <= 0 || l > uint64(len(data)) {
+				return errInvalidProto
+			}
+			start = n + m
+			end = start + int(l)
+		case wireFixed32:
+			end = n + 4
+		default:
+			return errInvalidProto
+		}
+		if end > len(data) {
+			return errInvalidProto
+		}
+
+		if err := fn(num, wire, data[:end], data[start:end]); err != nil {
+			return err
+		}
+		data = data[end:]
+	}
+	return nil
+}
+
+func appendProtoKey(b []byte, num, wire int) []byte {
+	return appendUvarint(b, uint64(num)<<3|uint64(wire))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,79 @@
+package coreunix
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+)
+
+func TestFileAttrsRoundTrip(t *testing.T) {
+	fsn := ft.NewFSNode(ft.TFile)
+	fsn.SetData([]byte("hello"))
+	data, err := fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := FileAttrs{
+		Mode:    0750 | os.ModeSetgid,
+		HasMode: true,
+		Mtime:   time.Unix(1500000000, 123456789),
+	}
+	withAttrs, err := AppendFileAttrs(data, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FileAttrsFromData(withAttrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode != attrs.Mode || !got.HasMode || !got.Mtime.Equal(attrs.Mtime) {
+		t.Fatalf("expected %+v, got %+v", attrs, got)
+	}
+	if got.UnixMode() != 02750 {
+		t.Fatalf("expected mode 02750, got %o", got.UnixMode())
+	}
+
+	// unixfs still reads the data
+	fsn2, err := ft.FSNodeFromBytes(withAttrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fsn2.Data()) != "hello" {
+		t.Fatalf("unexpected data %q", fsn2.Data())
+	}
+
+	// setting the attributes again replaces them
+	replaced, err := AppendFileAttrs(withAttrs, FileAttrs{Mtime: time.Unix(42, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = FileAttrsFromData(replaced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HasMode || got.Mtime.Unix() != 42 || got.Mtime.Nanosecond() != 0 {
+		t.Fatalf("unexpected attributes %+v", got)
+	}
+}
+
+func TestFileAttrsNone(t *testing.T) {
+	data, err := ft.NewFSNode(ft.TDirectory).GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := FileAttrsFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attrs.IsZero() {
+		t.Fatalf("expected no attributes, got %+v", attrs)
+	}
+
+	if _, err := FileAttrsFromData([]byte{0x42, 0x10}); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+}
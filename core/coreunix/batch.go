@@ -0,0 +1,148 @@
+package coreunix
+
+import (
+	"context"
+	"sync"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// The limits of the nodes buffered by a batchDAG, as in ipld.Batch.
+const (
+	batchMaxNodes = 128
+	batchMaxSize  = 8 << 20
+)
+
+// batchDAG buffers the nodes added to a DAG service and writes them with
+// AddMany, once there are enough of them or on Commit. The buffered nodes
+// are returned by Get, so they can be read back before being written. It's
+// safe for concurrent use.
+type batchDAG struct {
+	ipld.DAGService
+
+	mu      sync.Mutex
+	pending map[cid.Cid]ipld.Node
+	order   []ipld.Node
+	size    int
+}
+
+func newBatchDAG(ds ipld.DAGService) *batchDAG {
+	return &batchDAG{
+		DAGService: ds,
+		pending:    make(map[cid.Cid]ipld.Node),
+	}
+}
+
+func (b *batchDAG) Add(ctx context.Context, nd ipld.Node) error {
+	return b.AddMany(ctx, []ipld.Node{nd})
+}
+
+func (b *batchDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, nd := range nds {
+		if _, ok := b.pending[nd.Cid()]; ok {
+			continue
+		}
+		b.pending[nd.Cid()] = nd
+		b.order = append(b.order, nd)
+		b.size += len(nd.RawData())
+	}
+	if len(b.order) < batchMaxNodes && b.size < batchMaxSize {
+		return nil
+	}
+	return b.commit(ctx)
+}
+
+// Commit writes the buffered nodes.
+func (b *batchDAG) Commit(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.commit(ctx)
+}
+
+// commit writes the buffered nodes. The lock is held while they are written,
+// so that they can be read in the meantime.
+func (b *batchDAG) commit(ctx context.Context) error {
+	if len(b.order) == 0 {
+		return nil
+	}
+	if err := b.DAGService.AddMany(ctx, b.order); err != nil {
+		return err
+	}
+	b.pending = make(map[cid.Cid]ipld.Node)
+	b.order = nil
+	b.size = 0
+	return nil
+}
+
+func (b *batchDAG) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	b.mu.Lock()
+	nd, ok := b.pending[c]
+	b.mu.Unlock()
+	if ok {
+		return nd, nil
+	}
+	return b.DAGService.Get(ctx, c)
+}
+
+func (b *batchDAG) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+
+	var rest []cid.Cid
+	b.mu.Lock()
+	for _, c := range cids {
+		if nd, ok := b.pending[c]; ok {
+			out <- &ipld.NodeOption{Node: nd}
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	b.mu.Unlock()
+
+	if len(rest) == 0 {
+		close(out)
+		return out
+	}
+	go func() {
+		defer close(out)
+		for opt := range b.DAGService.GetMany(ctx, rest) {
+			select {
+			case out <- opt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (b *batchDAG) Remove(ctx context.Context, c cid.Cid) error {
+	return b.RemoveMany(ctx, []cid.Cid{c})
+}
+
+func (b *batchDAG) RemoveMany(ctx context.Context, cids []cid.Cid) error {
+	b.mu.Lock()
+	removed := false
+	for _, c := range cids {
+		if nd, ok := b.pending[c]; ok {
+			delete(b.pending, c)
+			b.size -= len(nd.RawData())
+			removed = true
+		}
+	}
+	if removed {
+		order := b.order[:0]
+		for _, nd := range b.order {
+			if _, ok := b.pending[nd.Cid()]; ok {
+				order = append(order, nd)
+			}
+		}
+		b.order = order
+	}
+	b.mu.Unlock()
+
+	return b.DAGService.RemoveMany(ctx, cids)
+}
@@ -20,5 +20,9 @@ func Cat(ctx context.Context, n *core.IpfsNode, pstr string) (uio.DagReader, err
 		return nil, err
 	}
 
-	return uio.NewDagReader(ctx, dagNode, n.DAG)
+	ng, err := ReadAheadDAG(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewDagReader(ctx, dagNode, ng)
 }
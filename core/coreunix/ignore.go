@@ -0,0 +1,238 @@
+package coreunix
+
+import (
+	"bufio"
+	"io"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+)
+
+// IgnoreFileName is the name of the files holding the ignore rules of their
+// directory, in the gitignore syntax.
+const IgnoreFileName = ".ipfsignore"
+
+type ignoreRule struct {
+	// the directory of the rule, relative to the added directory
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	// anchored rules match the path relative to base, the others match
+	// the name of the files at any depth
+	anchored bool
+}
+
+// IgnoreRules are gitignore-style rules excluding files from recursive
+// adds. The last rule matching a file decides whether it's ignored.
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// AddPattern adds a rule of the gitignore syntax, relative to the base
+// directory. Blank patterns and comments are skipped.
+func (r *IgnoreRules) AddPattern(pattern, base string) {
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		// escaped leading '!' or '#'
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		rule.anchored = true
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+	if pattern == "" {
+		return
+	}
+	rule.segments = strings.Split(pattern, "/")
+	r.rules = append(r.rules, rule)
+}
+
+// Parse adds the rules of an ignore file, relative to the base directory.
+func (r *IgnoreRules) Parse(rd io.Reader, base string) error {
+	s := bufio.NewScanner(rd)
+	for s.Scan() {
+		r.AddPattern(s.Text(), base)
+	}
+	return s.Err()
+}
+
+// ParseFile adds the rules of the ignore file at path, if it exists.
+func (r *IgnoreRules) ParseFile(path, base string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Parse(f, base)
+}
+
+// Ignored returns whether the file at path, relative to the added
+// directory, is ignored.
+func (r *IgnoreRules) Ignored(path string, isDir bool) bool {
+	if r == nil {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.negate == !ignored {
+			// can't change the outcome
+			continue
+		}
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		rel := path
+		if rule.base != "" {
+			if !strings.HasPrefix(path, rule.base+"/") {
+				continue
+			}
+			rel = path[len(rule.base)+1:]
+		}
+
+		var match bool
+		if rule.anchored {
+			match = matchSegments(rule.segments, strings.Split(rel, "/"))
+		} else {
+			match = matchSegments(rule.segments, []string{gopath.Base(rel)})
+		}
+		if match {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (r *IgnoreRules) clone() *IgnoreRules {
+	c := &IgnoreRules{}
+	if r != nil {
+		c.rules = append(c.rules, r.rules...)
+	}
+	return c
+}
+
+// matchSegments matches a path against a pattern, segment by segment. A
+// "**" segment matches any number of segments.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, err := gopath.Match(pattern[0], path[0])
+	return err == nil && ok && matchSegments(pattern[1:], path[1:])
+}
+
+// FilterIgnored returns the top-level files to add, with the ignored files
+// left out of their directories. The directories are filtered with rules,
+// and with the IgnoreFileName files they hold. The files are left out before
+// being read.
+func FilterIgnored(top files.File, rules *IgnoreRules) files.File {
+	return &ignoreTop{File: top, rules: rules}
+}
+
+// ignoreTop filters the directories passed as arguments, which are the
+// roots of the relative paths of the rules.
+type ignoreTop struct {
+	files.File
+	rules *IgnoreRules
+}
+
+func (t *ignoreTop) NextFile() (files.File, error) {
+	f, err := t.File.NextFile()
+	if err != nil || f == nil || !f.IsDirectory() {
+		return f, err
+	}
+	return newIgnoreDir(f, t.rules, "")
+}
+
+func (t *ignoreTop) Size() (int64, error) {
+	sf, ok := t.File.(files.SizeFile)
+	if !ok {
+		return 0, files.ErrNotReader
+	}
+	// the ignored files are counted
+	return sf.Size()
+}
+
+// ignoreDir is a directory without its ignored files.
+type ignoreDir struct {
+	files.File
+	rules *IgnoreRules
+	// the path of the directory, relative to the added directory
+	path string
+}
+
+func newIgnoreDir(dir files.File, rules *IgnoreRules, path string) (files.File, error) {
+	fi, ok := dir.(files.FileInfo)
+	if ok && fi.AbsPath() != "" {
+		rules = rules.clone()
+		if err := rules.ParseFile(filepath.Join(fi.AbsPath(), IgnoreFileName), path); err != nil {
+			return nil, err
+		}
+	}
+	return &ignoreDir{File: dir, rules: rules, path: path}, nil
+}
+
+func (d *ignoreDir) NextFile() (files.File, error) {
+	for {
+		f, err := d.File.NextFile()
+		if err != nil || f == nil {
+			return f, err
+		}
+
+		p := gopath.Join(d.path, gopath.Base(f.FileName()))
+		if d.rules.Ignored(p, f.IsDirectory()) {
+			log.Debugf("%s is ignored, skipping", f.FileName())
+			f.Close()
+			continue
+		}
+		if f.IsDirectory() {
+			return newIgnoreDir(f, d.rules, p)
+		}
+		return f, nil
+	}
+}
+
+func (d *ignoreDir) AbsPath() string {
+	if fi, ok := d.File.(files.FileInfo); ok {
+		return fi.AbsPath()
+	}
+	return ""
+}
+
+func (d *ignoreDir) Stat() os.FileInfo {
+	if fi, ok := d.File.(files.FileInfo); ok {
+		return fi.Stat()
+	}
+	return nil
+}
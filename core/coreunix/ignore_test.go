@@ -0,0 +1,58 @@
+package coreunix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules := new(IgnoreRules)
+	err := rules.Parse(strings.NewReader(`
+# comment
+*.log
+!keep.log
+build/
+/top.txt
+docs/**/*.tmp
+\!bang
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.AddPattern("*.bak", "sub")
+
+	for _, c := range []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"a.log", false, true},
+		{"deep/dir/a.log", false, true},
+		{"keep.log", false, false},
+		{"deep/keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false},
+		{"docs/a.tmp", false, true},
+		{"docs/x/y/a.tmp", false, true},
+		{"a.tmp", false, false},
+		{"!bang", false, true},
+		{"bang", false, false},
+		{"sub/a.bak", false, true},
+		{"sub/x/a.bak", false, true},
+		{"a.bak", false, false},
+		{"subdir/a.bak", false, false},
+		{"# comment", false, false},
+	} {
+		if got := rules.Ignored(c.path, c.isDir); got != c.ignored {
+			t.Errorf("%s (dir: %t): expected ignored %t, got %t", c.path, c.isDir, c.ignored, got)
+		}
+	}
+
+	var none *IgnoreRules
+	if none.Ignored("a.log", false) {
+		t.Fatal("nil rules ignore nothing")
+	}
+}
@@ -0,0 +1,89 @@
+package coreunix
+
+import (
+	"context"
+	"net/http"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// SniffLen is the number of leading bytes of a file its MIME type is detected
+// from, as many as http.DetectContentType considers.
+const SniffLen = 512
+
+// DetectMIME returns the MIME type of the file nd, the one stored in its
+// metadata or else the one detected from the magic bytes of its first leaf
+// blocks, like the gateway does. Only the blocks holding the first SniffLen
+// bytes are fetched. It returns "" when nd isn't a file.
+func DetectMIME(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node) (string, error) {
+	if pn, ok := nd.(*dag.ProtoNode); ok {
+		d, err := ft.FromBytes(pn.Data())
+		if err != nil {
+			return "", err
+		}
+		if d.GetType() == ft.TMetadata {
+			m, err := ft.MetadataFromBytes(d.GetData())
+			if err != nil {
+				return "", err
+			}
+			if m.MimeType != "" || len(pn.Links()) == 0 {
+				return m.MimeType, nil
+			}
+			// the file is the link of the metadata
+			if nd, err = pn.Links()[0].GetNode(ctx, ng); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	buf := make([]byte, 0, SniffLen)
+	isFile, err := readLeading(ctx, ng, nd, &buf)
+	if err != nil || !isFile {
+		return "", err
+	}
+	return http.DetectContentType(buf), nil
+}
+
+// readLeading appends to buf the leading bytes of the file nd, until buf
+// holds SniffLen bytes, and tells whether nd is a file.
+func readLeading(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node, buf *[]byte) (bool, error) {
+	switch n := nd.(type) {
+	case *dag.RawNode:
+		appendLeading(buf, n.RawData())
+		return true, nil
+	case *dag.ProtoNode:
+		d, err := ft.FromBytes(n.Data())
+		if err != nil {
+			return false, err
+		}
+		if t := d.GetType(); t != ft.TFile && t != ft.TRaw {
+			return false, nil
+		}
+
+		appendLeading(buf, d.GetData())
+		for _, l := range n.Links() {
+			if len(*buf) >= SniffLen {
+				break
+			}
+			child, err := l.GetNode(ctx, ng)
+			if err != nil {
+				return false, err
+			}
+			if _, err := readLeading(ctx, ng, child, buf); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func appendLeading(buf *[]byte, data []byte) {
+	if n := SniffLen - len(*buf); len(data) > n {
+		data = data[:n]
+	}
+	*buf = append(*buf, data...)
+}
@@ -0,0 +1,62 @@
+package coreunix
+
+import (
+	"context"
+	"testing"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	mdtest "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag/test"
+)
+
+func TestDetectMIME(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	// the magic bytes of a PNG image span the first two leaves
+	leaves := []*dag.RawNode{
+		dag.NewRawNode([]byte("\x89PNG")),
+		dag.NewRawNode([]byte("\r\n\x1a\n\x00\x00\x00\x0dIHDR")),
+		dag.NewRawNode(make([]byte, 1024)),
+	}
+	fsn := ft.NewFSNode(ft.TFile)
+	root := new(dag.ProtoNode)
+	for _, l := range leaves {
+		if err := ds.Add(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink("", l); err != nil {
+			t.Fatal(err)
+		}
+		fsn.AddBlockSize(uint64(len(l.RawData())))
+	}
+	data, err := fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetData(data)
+
+	mime, err := DetectMIME(ctx, ds, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mime != "image/png" {
+		t.Fatalf("expected image/png, got %q", mime)
+	}
+
+	text, err := DetectMIME(ctx, ds, dag.NewRawNode([]byte("hello world")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "text/plain; charset=utf-8" {
+		t.Fatalf("expected text/plain, got %q", text)
+	}
+
+	dir, err := DetectMIME(ctx, ds, ft.EmptyDirNode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "" {
+		t.Fatalf("expected no MIME type for a directory, got %q", dir)
+	}
+}
@@ -0,0 +1,104 @@
+package coreunix
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// smallFileSize is the size up to which the files are read in memory, to be
+// chunked and hashed by the workers while the next files are read.
+const smallFileSize = 1 << 20
+
+// addJob is a file being added by the workers.
+type addJob struct {
+	path string
+	done chan struct{}
+	nd   ipld.Node
+	err  error
+}
+
+// startWorkers sets up the concurrent adds when Workers is above 1. The
+// filestore needs the files to be read from the disk by the DAG builder, so
+// the files added with NoCopy are added one at a time.
+func (adder *Adder) startWorkers() {
+	if adder.Workers <= 1 || adder.NoCopy || adder.workers != nil {
+		return
+	}
+	adder.workers = make(chan struct{}, adder.Workers)
+	adder.batch = newBatchDAG(adder.dagService)
+	adder.dagService = adder.batch
+}
+
+// addFileAsync adds a regular file with the workers when it's small enough
+// to be read in memory. The larger files are added by the caller, as their
+// reader must be consumed before the next file is read, while the workers
+// add the previous ones.
+func (adder *Adder) addFileAsync(r io.Reader, path string, attrs FileAttrs, hasAttrs bool) error {
+	buf, err := ioutil.ReadAll(io.LimitReader(r, smallFileSize+1))
+	if err != nil {
+		return err
+	}
+
+	job := &addJob{path: path, done: make(chan struct{})}
+	adder.queue = append(adder.queue, job)
+	if len(buf) > smallFileSize {
+		job.nd, job.err = adder.buildFile(io.MultiReader(bytes.NewReader(buf), r), attrs, hasAttrs)
+		close(job.done)
+	} else {
+		adder.workers <- struct{}{}
+		go func() {
+			defer func() { <-adder.workers }()
+			job.nd, job.err = adder.buildFile(bytes.NewReader(buf), attrs, hasAttrs)
+			close(job.done)
+		}()
+	}
+	return adder.patchAdded(false)
+}
+
+// patchAdded patches the files added by the workers into the root, in the
+// order they were read, so that the output doesn't depend on the workers.
+// Unless wait is set, it stops at the first file not added yet.
+func (adder *Adder) patchAdded(wait bool) error {
+	for len(adder.queue) > 0 {
+		job := adder.queue[0]
+		if wait {
+			<-job.done
+		} else {
+			select {
+			case <-job.done:
+			default:
+				return nil
+			}
+		}
+
+		adder.queue[0] = nil
+		adder.queue = adder.queue[1:]
+		if job.err != nil {
+			return job.err
+		}
+		if err := adder.addNode(job.nd, job.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sync waits for the workers, patches the files they added into the root,
+// and writes the buffered nodes.
+func (adder *Adder) sync() error {
+	if err := adder.patchAdded(true); err != nil {
+		// don't leave the workers writing once the add failed
+		for _, job := range adder.queue {
+			<-job.done
+		}
+		adder.queue = nil
+		return err
+	}
+	if adder.batch != nil {
+		return adder.batch.Commit(adder.ctx)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo"
+
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+	datastore "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	syncds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	dagtest "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag/test"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+)
+
+func TestAddWorkers(t *testing.T) {
+	add := func(workers int) (string, []string) {
+		r := &repo.Mock{
+			C: config.Config{
+				Identity: config.Identity{
+					PeerID: testPeerID, // required by offline node
+				},
+			},
+			D: syncds.MutexWrap(datastore.NewMapDatastore()),
+		}
+		node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := make(chan interface{}, 256)
+		adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder.Out = out
+		adder.Workers = workers
+
+		var fs []files.File
+		for i := 0; i < 100; i++ {
+			name := fmt.Sprintf("file%d", i)
+			data := []byte(name)
+			if i%25 == 0 {
+				// too large to be read in memory
+				data = bytes.Repeat(data, smallFileSize/len(data)+1)
+			}
+			fs = append(fs, files.NewReaderFile(name, "dir/"+name, ioutil.NopCloser(bytes.NewReader(data)), nil))
+		}
+		if err := adder.AddFile(files.NewSliceFile("dir", "dir", fs)); err != nil {
+			t.Fatal(err)
+		}
+		nd, err := adder.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := adder.PinRoot(); err != nil {
+			t.Fatal(err)
+		}
+		close(out)
+
+		var names []string
+		for o := range out {
+			names = append(names, o.(*AddedObject).Name)
+		}
+
+		// all the blocks are written
+		if err := dag.FetchGraph(context.Background(), nd.Cid(), node.DAG); err != nil {
+			t.Fatal(err)
+		}
+		return nd.Cid().String(), names
+	}
+
+	root, names := add(1)
+	proot, pnames := add(8)
+	if root != proot {
+		t.Fatalf("expected the root %s, got %s", root, proot)
+	}
+	if fmt.Sprint(names) != fmt.Sprint(pnames) {
+		t.Fatalf("expected the output %v, got %v", names, pnames)
+	}
+}
+
+func TestBatchDAG(t *testing.T) {
+	ctx := context.Background()
+	ds := dagtest.Mock()
+	b := newBatchDAG(ds)
+
+	nd := dag.NodeWithData([]byte("batched"))
+	if err := b.Add(ctx, nd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Get(ctx, nd.Cid()); err == nil {
+		t.Fatal("the node shouldn't be written before the commit")
+	}
+	if _, err := b.Get(ctx, nd.Cid()); err != nil {
+		t.Fatal("the buffered node should be returned:", err)
+	}
+
+	if err := b.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Get(ctx, nd.Cid()); err != nil {
+		t.Fatal("the node should be written once committed:", err)
+	}
+
+	for i := 0; i < batchMaxNodes; i++ {
+		if err := b.Add(ctx, dag.NodeWithData([]byte(fmt.Sprint(i)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ds.Get(ctx, dag.NodeWithData([]byte("0")).Cid()); err != nil {
+		t.Fatal("a full batch should be written:", err)
+	}
+}
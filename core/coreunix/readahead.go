@@ -0,0 +1,246 @@
+package coreunix
+
+import (
+	"context"
+	"sync"
+
+	core "github.com/ipfs/go-ipfs/core"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// ReadAheadDAG returns the node getter the UnixFS files are read with, ctx
+// being the context of the read: a session of the DAG of the node, which
+// prefetches the blocks ahead of the sequential reads according to
+// Internal.UnixFSReadAheadBlocks.
+func ReadAheadDAG(ctx context.Context, n *core.IpfsNode) (ipld.NodeGetter, error) {
+	cfg, err := extconfig.LoadInternal(n.Repo)
+	if err != nil {
+		return nil, err
+	}
+	blocks := cfg.ReadAheadBlocks()
+	if blocks <= 0 {
+		return n.DAG, nil
+	}
+	return NewReadAhead(ctx, dag.NewSession(ctx, n.DAG), blocks), nil
+}
+
+// position is the position of a node among the links of its parent.
+type position struct {
+	parent cid.Cid
+	links  []cid.Cid
+	index  int
+}
+
+// prefetch is a node fetched ahead of its read.
+type prefetch struct {
+	done chan struct{}
+	nd   ipld.Node
+	err  error
+}
+
+// readAhead prefetches the siblings following the nodes read in order. The
+// DAG readers fetch the children of a node in batches, and only request the
+// next batch once they read the current one, stalling at every batch
+// boundary when the blocks come from the network.
+type readAhead struct {
+	ctx    context.Context
+	ng     ipld.NodeGetter
+	blocks int
+
+	lk        sync.Mutex
+	positions map[cid.Cid]position
+	fetched   map[cid.Cid]*prefetch
+	last      position
+	ahead     int // index of the last sibling prefetched among last.links
+}
+
+// NewReadAhead returns ng prefetching up to blocks siblings after the nodes
+// read sequentially, until ctx is canceled.
+func NewReadAhead(ctx context.Context, ng ipld.NodeGetter, blocks int) ipld.NodeGetter {
+	return &readAhead{
+		ctx:       ctx,
+		ng:        ng,
+		blocks:    blocks,
+		positions: make(map[cid.Cid]position),
+		fetched:   make(map[cid.Cid]*prefetch),
+	}
+}
+
+// learn records the positions of the links of nd.
+func (r *readAhead) learn(nd ipld.Node) {
+	links := nd.Links()
+	if len(links) == 0 {
+		return
+	}
+	cids := make([]cid.Cid, len(links))
+	for i, l := range links {
+		cids[i] = l.Cid
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	for i, c := range cids {
+		r.positions[c] = position{parent: nd.Cid(), links: cids, index: i}
+	}
+}
+
+// read records the read of the nodes ks and prefetches the siblings after
+// them when they follow the previous read.
+func (r *readAhead) read(ks []cid.Cid) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	first, ok := r.positions[ks[0]]
+	if !ok {
+		return
+	}
+	lastPos, ok := r.positions[ks[len(ks)-1]]
+	if !ok {
+		return
+	}
+
+	sameParent := r.last.parent.Defined() && r.last.parent.Equals(first.parent)
+	sequential := first.index == 0 || (sameParent && first.index == r.last.index+1)
+	if !sameParent {
+		r.ahead = -1
+	}
+	r.last = lastPos
+	if !sequential {
+		r.ahead = lastPos.index
+		return
+	}
+
+	start := lastPos.index + 1
+	if r.ahead >= start {
+		start = r.ahead + 1
+	}
+	end := lastPos.index + 1 + r.blocks
+	if end > len(lastPos.links) {
+		end = len(lastPos.links)
+	}
+	if start >= end {
+		return
+	}
+	r.ahead = end - 1
+
+	var ks []cid.Cid
+	batch := make(map[cid.Cid]*prefetch)
+	for _, c := range lastPos.links[start:end] {
+		if _, ok := r.fetched[c]; ok {
+			continue
+		}
+		p := &prefetch{done: make(chan struct{})}
+		r.fetched[c] = p
+		batch[c] = p
+		ks = append(ks, c)
+	}
+	if len(ks) > 0 {
+		go r.prefetch(ks, batch)
+	}
+}
+
+// prefetch fetches the nodes ks, whose prefetch entries are in batch.
+func (r *readAhead) prefetch(ks []cid.Cid, batch map[cid.Cid]*prefetch) {
+	for opt := range r.ng.GetMany(r.ctx, ks) {
+		if opt.Err != nil {
+			continue
+		}
+		p, ok := batch[opt.Node.Cid()]
+		if !ok {
+			continue
+		}
+		delete(batch, opt.Node.Cid())
+		p.nd = opt.Node
+		close(p.done)
+	}
+
+	// the nodes not fetched are fetched again when read
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	for k, p := range batch {
+		if r.fetched[k] == p {
+			delete(r.fetched, k)
+		}
+		p.err = r.ctx.Err()
+		if p.err == nil {
+			p.err = ipld.ErrNotFound
+		}
+		close(p.done)
+	}
+}
+
+// take returns the node k when it was prefetched, waiting for it if it is
+// being fetched.
+func (r *readAhead) take(ctx context.Context, k cid.Cid) ipld.Node {
+	r.lk.Lock()
+	p, ok := r.fetched[k]
+	delete(r.fetched, k)
+	r.lk.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return nil
+	}
+	if p.err != nil {
+		return nil
+	}
+	return p.nd
+}
+
+func (r *readAhead) Get(ctx context.Context, k cid.Cid) (ipld.Node, error) {
+	r.read([]cid.Cid{k})
+	if nd := r.take(ctx, k); nd != nil {
+		r.learn(nd)
+		return nd, nil
+	}
+
+	nd, err := r.ng.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	r.learn(nd)
+	return nd, nil
+}
+
+func (r *readAhead) GetMany(ctx context.Context, ks []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(ks))
+	if len(ks) == 0 {
+		close(out)
+		return out
+	}
+	r.read(ks)
+
+	go func() {
+		defer close(out)
+
+		var missing []cid.Cid
+		for _, k := range ks {
+			nd := r.take(ctx, k)
+			if nd == nil {
+				missing = append(missing, k)
+				continue
+			}
+			r.learn(nd)
+			out <- &ipld.NodeOption{Node: nd}
+		}
+		if len(missing) == 0 {
+			return
+		}
+
+		for opt := range r.ng.GetMany(ctx, missing) {
+			if opt.Err == nil {
+				r.learn(opt.Node)
+			}
+			out <- opt
+		}
+	}()
+	return out
+}
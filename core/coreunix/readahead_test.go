@@ -0,0 +1,125 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	mdtest "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag/test"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// countingGetter counts the fetches of every node.
+type countingGetter struct {
+	ipld.NodeGetter
+
+	lk      sync.Mutex
+	fetches map[cid.Cid]int
+}
+
+func (g *countingGetter) count(ks ...cid.Cid) {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	for _, k := range ks {
+		g.fetches[k]++
+	}
+}
+
+func (g *countingGetter) fetched(k cid.Cid) int {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	return g.fetches[k]
+}
+
+func (g *countingGetter) Get(ctx context.Context, k cid.Cid) (ipld.Node, error) {
+	g.count(k)
+	return g.NodeGetter.Get(ctx, k)
+}
+
+func (g *countingGetter) GetMany(ctx context.Context, ks []cid.Cid) <-chan *ipld.NodeOption {
+	g.count(ks...)
+	return g.NodeGetter.GetMany(ctx, ks)
+}
+
+func TestReadAhead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := mdtest.Mock()
+	root := new(dag.ProtoNode)
+	for i := 0; i < 100; i++ {
+		leaf := dag.NodeWithData([]byte(fmt.Sprintf("leaf %d", i)))
+		if err := ds.Add(ctx, leaf); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink(fmt.Sprint(i), leaf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.Add(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	links := make([]cid.Cid, len(root.Links()))
+	for i, l := range root.Links() {
+		links[i] = l.Cid
+	}
+
+	g := &countingGetter{NodeGetter: ds, fetches: make(map[cid.Cid]int)}
+	ra := NewReadAhead(ctx, g, 8)
+
+	readBatch := func(ks []cid.Cid) {
+		t.Helper()
+		n := 0
+		for opt := range ra.GetMany(ctx, ks) {
+			if opt.Err != nil {
+				t.Fatal(opt.Err)
+			}
+			n++
+		}
+		if n != len(ks) {
+			t.Fatalf("expected %d nodes, got %d", len(ks), n)
+		}
+	}
+	waitFetched := func(k cid.Cid) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for g.fetched(k) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s was not prefetched", k)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if _, err := ra.Get(ctx, root.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sequential reads prefetch the 8 next leaves
+	readBatch(links[0:4])
+	waitFetched(links[11])
+	if g.fetched(links[12]) != 0 {
+		t.Fatal("prefetched beyond the readahead")
+	}
+
+	readBatch(links[4:8])
+	for _, k := range links[4:8] {
+		if g.fetched(k) != 1 {
+			t.Fatalf("expected %s to be fetched once, got %d", k, g.fetched(k))
+		}
+	}
+	waitFetched(links[15])
+
+	// a seek doesn't prefetch
+	if _, err := ra.Get(ctx, links[50]); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if g.fetched(links[51]) != 0 {
+		t.Fatal("prefetched after a seek")
+	}
+}
@@ -0,0 +1,196 @@
+package coreunix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+)
+
+// AttrsFilePrefix prefixes the names of the entries holding the attributes
+// of the entries they precede, named after them.
+const AttrsFilePrefix = ".ipfs-attrs."
+
+// maxAttrsSize bounds the entries of the attributes read by ReceiveFileAttrs.
+const maxAttrsSize = 1024
+
+// SendFileAttrs returns top with the attributes of its local files and
+// directories to preserve, read on the client, sent before each of them in
+// an entry of the same directory. The request sent to the daemon carries
+// them, and ReceiveFileAttrs gives them back to the adder.
+func SendFileAttrs(top files.File, mode, mtime bool) files.File {
+	return &attrsSender{File: top, mode: mode, mtime: mtime}
+}
+
+// attrsSender is a directory sending the attributes of its entries.
+type attrsSender struct {
+	files.File
+	mode, mtime bool
+	// next is the entry to return after its attributes
+	next files.File
+}
+
+func (s *attrsSender) NextFile() (files.File, error) {
+	if f := s.next; f != nil {
+		s.next = nil
+		return f, nil
+	}
+
+	f, err := s.File.NextFile()
+	if err != nil || f == nil {
+		return f, err
+	}
+	if f.IsDirectory() {
+		f = &attrsSender{File: f, mode: s.mode, mtime: s.mtime}
+	}
+
+	fi, ok := f.(files.FileInfo)
+	if !ok || fi.Stat() == nil || fi.AbsPath() == os.Stdin.Name() {
+		return f, nil
+	}
+	if _, ok := f.(*files.Symlink); ok {
+		return f, nil
+	}
+	if m := fi.Stat().Mode(); !m.IsRegular() && !m.IsDir() {
+		return f, nil
+	}
+
+	data, err := json.Marshal(FileAttrsFromInfo(fi.Stat(), s.mode, s.mtime))
+	if err != nil {
+		return nil, err
+	}
+	s.next = f
+	return files.NewReaderFile(
+		attrsFileName(f.FileName()),
+		attrsFileName(f.FullPath()),
+		ioutil.NopCloser(bytes.NewReader(data)),
+		nil,
+	), nil
+}
+
+func (s *attrsSender) Size() (int64, error) {
+	sf, ok := s.File.(files.SizeFile)
+	if !ok {
+		return 0, files.ErrNotReader
+	}
+	// the attributes aren't counted
+	return sf.Size()
+}
+
+func (s *attrsSender) AbsPath() string {
+	if fi, ok := s.File.(files.FileInfo); ok {
+		return fi.AbsPath()
+	}
+	return ""
+}
+
+func (s *attrsSender) Stat() os.FileInfo {
+	if fi, ok := s.File.(files.FileInfo); ok {
+		return fi.Stat()
+	}
+	return nil
+}
+
+// attrsFileName returns the name of the entry of the attributes of the entry
+// named name.
+func attrsFileName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return gopath.Join(gopath.Dir(name), AttrsFilePrefix+gopath.Base(name))
+}
+
+// ReceiveFileAttrs returns top without the entries of the attributes sent
+// by SendFileAttrs, the entries they precede holding them. The attributes
+// of the files added through it are those, whatever the local files are.
+func ReceiveFileAttrs(top files.File) files.File {
+	return &attrsReceiver{File: top}
+}
+
+// attrsFile is an entry received with its attributes.
+type attrsFile interface {
+	FileAttrs() (FileAttrs, bool)
+}
+
+// attrsReceiver is a directory receiving the attributes of its entries, or
+// an entry received with them.
+type attrsReceiver struct {
+	files.File
+	attrs    FileAttrs
+	hasAttrs bool
+}
+
+func (r *attrsReceiver) NextFile() (files.File, error) {
+	f, err := r.File.NextFile()
+	if err != nil || f == nil {
+		return f, err
+	}
+
+	var attrs FileAttrs
+	name := gopath.Base(f.FileName())
+	hasAttrs := !f.IsDirectory() && strings.HasPrefix(name, AttrsFilePrefix)
+	if hasAttrs {
+		err := json.NewDecoder(io.LimitReader(f, maxAttrsSize)).Decode(&attrs)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid attributes %s: %s", f.FileName(), err)
+		}
+
+		f, err = r.File.NextFile()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if f == nil || gopath.Base(f.FileName()) != strings.TrimPrefix(name, AttrsFilePrefix) {
+			return nil, fmt.Errorf("the attributes %s don't precede their file", name)
+		}
+	}
+
+	if f.IsDirectory() || hasAttrs {
+		return &attrsReceiver{File: f, attrs: attrs, hasAttrs: hasAttrs}, nil
+	}
+	return f, nil
+}
+
+func (r *attrsReceiver) FileAttrs() (FileAttrs, bool) {
+	return r.attrs, r.hasAttrs
+}
+
+func (r *attrsReceiver) Size() (int64, error) {
+	sf, ok := r.File.(files.SizeFile)
+	if !ok {
+		return 0, files.ErrNotReader
+	}
+	return sf.Size()
+}
+
+func (r *attrsReceiver) AbsPath() string {
+	if fi, ok := r.File.(files.FileInfo); ok {
+		return fi.AbsPath()
+	}
+	return ""
+}
+
+func (r *attrsReceiver) Stat() os.FileInfo {
+	if fi, ok := r.File.(files.FileInfo); ok {
+		return fi.Stat()
+	}
+	return nil
+}
+
+// preserved returns the attributes a with only those to preserve.
+func (a FileAttrs) preserved(mode, mtime bool) FileAttrs {
+	if !mode {
+		a.Mode, a.HasMode = 0, false
+	}
+	if !mtime {
+		a.Mtime = time.Time{}
+	}
+	return a
+}
@@ -0,0 +1,106 @@
+// +build !windows
+
+package coreunix
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+)
+
+func TestSendFileAttrs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sendattrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mtime := time.Unix(1500000000, 0)
+	if err := os.Mkdir(filepath.Join(dir, "d"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "d", "a"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{filepath.Join(dir, "d", "a"), filepath.Join(dir, "d")} {
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	open := func() files.File {
+		st, err := os.Lstat(filepath.Join(dir, "d"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := files.NewSerialFile("d", filepath.Join(dir, "d"), false, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return SendFileAttrs(files.NewSliceFile("", "", []files.File{d}), true, true)
+	}
+
+	// the attributes are sent before their entries
+	sent := make(map[string]string)
+	listFiles(t, open(), sent)
+	for _, name := range []string{"d", "d/a"} {
+		if sent[attrsFileName(name)] != "file" {
+			t.Errorf("expected the attributes of %s to be sent, got %v", name, sent)
+		}
+	}
+
+	// and given back with them, as the local files are left out
+	got := make(map[string]FileAttrs)
+	var walk func(f files.File)
+	walk = func(f files.File) {
+		for {
+			c, err := f.NextFile()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(gopath.Base(c.FileName()), AttrsFilePrefix) {
+				t.Fatalf("expected the attributes %s not to be received as a file", c.FileName())
+			}
+			af, ok := c.(attrsFile)
+			if !ok {
+				t.Fatalf("expected %s to be received with its attributes", c.FileName())
+			}
+			a, _ := af.FileAttrs()
+			got[c.FileName()] = a
+			if c.IsDirectory() {
+				walk(c)
+			}
+		}
+	}
+	walk(ReceiveFileAttrs(open()))
+
+	for name, mode := range map[string]os.FileMode{"d": 0700, "d/a": 0600} {
+		a, ok := got[name]
+		if !ok {
+			t.Fatalf("expected %s to be received, got %v", name, got)
+		}
+		if !a.HasMode || a.Mode != mode || !a.Mtime.Equal(mtime) {
+			t.Errorf("%s: expected the mode %s and the mtime %s, got %+v", name, mode, mtime, a)
+		}
+	}
+}
+
+func TestReceiveFileAttrsAlone(t *testing.T) {
+	top := ReceiveFileAttrs(files.NewSliceFile("", "", []files.File{
+		files.NewReaderFile(attrsFileName("a"), attrsFileName("a"), ioutil.NopCloser(strings.NewReader("{}")), nil),
+		files.NewReaderFile("b", "b", ioutil.NopCloser(strings.NewReader("b")), nil),
+	}))
+	if _, err := top.NextFile(); err == nil {
+		t.Fatal("expected an error for the attributes not followed by their file")
+	}
+}
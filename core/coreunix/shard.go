@@ -0,0 +1,82 @@
+package coreunix
+
+import (
+	"sort"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	hamt "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/hamt"
+	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// EstimatedDirSize returns the estimated size of a directory with the
+// entries: the sum of the lengths of their names and CIDs. It doesn't depend
+// on how the directory is encoded, so a directory is sharded or not
+// regardless of how it was built.
+func EstimatedDirSize(entries map[string]ipld.Node) int64 {
+	var size int64
+	for name, nd := range entries {
+		size += int64(len(name) + len(nd.Cid().Bytes()))
+	}
+	return size
+}
+
+// IsShardedDir returns whether the node is a HAMT sharded directory.
+func IsShardedDir(nd ipld.Node) bool {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return false
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	return err == nil && fsn.Type() == ft.THAMTShard
+}
+
+// reshardDir returns the directory with the entries, sharded when their
+// estimated size is above adder.ShardThreshold and not sharded otherwise.
+func (adder *Adder) reshardDir(nd ipld.Node, entries map[string]ipld.Node) (ipld.Node, error) {
+	shard := EstimatedDirSize(entries) > adder.ShardThreshold
+	if !shard && !IsShardedDir(nd) {
+		// the links of basic directories are updated by setDirAttrs
+		return nd, nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out ipld.Node
+	if shard {
+		s, err := hamt.NewShard(adder.dagService, uio.DefaultShardWidth)
+		if err != nil {
+			return nil, err
+		}
+		s.SetCidBuilder(adder.CidBuilder)
+		for _, name := range names {
+			if err := s.Set(adder.ctx, name, entries[name]); err != nil {
+				return nil, err
+			}
+		}
+		out, err = s.Node()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		pn := ft.EmptyDirNode()
+		pn.SetCidBuilder(adder.CidBuilder)
+		for _, name := range names {
+			if err := pn.AddNodeLink(name, entries[name]); err != nil {
+				return nil, err
+			}
+		}
+		out = pn
+	}
+
+	if err := adder.dagService.Add(adder.ctx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo"
+
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+	datastore "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	syncds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+func addDirWithThreshold(t *testing.T, entries int, threshold int64) ipld.Node {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.ShardThreshold = threshold
+
+	var fs []files.File
+	for i := 0; i < entries; i++ {
+		name := fmt.Sprintf("file%d", i)
+		data := ioutil.NopCloser(bytes.NewBufferString(name))
+		fs = append(fs, files.NewReaderFile(name, "dir/"+name, data, nil))
+	}
+	if err := adder.AddFile(files.NewSliceFile("dir", "dir", fs)); err != nil {
+		t.Fatal(err)
+	}
+	nd, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func TestAddAutoShard(t *testing.T) {
+	// each entry is about 40 bytes
+	small := addDirWithThreshold(t, 10, 1000)
+	if IsShardedDir(small) {
+		t.Fatal("expected a basic directory below the threshold")
+	}
+	if len(small.Links()) != 10 {
+		t.Fatalf("expected 10 links, got %d", len(small.Links()))
+	}
+
+	large := addDirWithThreshold(t, 100, 1000)
+	if !IsShardedDir(large) {
+		t.Fatal("expected a sharded directory above the threshold")
+	}
+
+	// the same entries give the same directory
+	if again := addDirWithThreshold(t, 100, 1000); !again.Cid().Equals(large.Cid()) {
+		t.Fatal("sharding is not deterministic")
+	}
+}
@@ -0,0 +1,283 @@
+package coreunix
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+)
+
+// The policies of the special files met by recursive adds, the files which
+// are neither regular files, directories nor symlinks: sockets, devices and
+// named pipes.
+const (
+	// SpecialFilesError fails the add when a special file is read.
+	SpecialFilesError = "error"
+	// SpecialFilesSkip leaves the special files out.
+	SpecialFilesSkip = "skip"
+)
+
+// LinkPolicy decides how the symlinks and the special files of the files to
+// add are handled.
+type LinkPolicy struct {
+	// FollowSymlinks adds the targets of the symlinks found in the
+	// directories instead of the symlinks.
+	FollowSymlinks bool
+	// DerefArgs adds the targets of the symlinks given as arguments.
+	DerefArgs bool
+	// Recursive allows the targets of the symlink arguments to be
+	// directories.
+	Recursive bool
+	// Hidden includes the hidden files of the directories.
+	Hidden bool
+	// SpecialFiles is SpecialFilesError or SpecialFilesSkip.
+	SpecialFiles string
+	// Warn, when set, is called with the special files skipped and the
+	// dangling symlinks which can't be followed.
+	Warn func(msg string)
+}
+
+// ParseSpecialFiles checks a policy of the special files, SpecialFilesError
+// when empty.
+func ParseSpecialFiles(s string) (string, error) {
+	switch s {
+	case "":
+		return SpecialFilesError, nil
+	case SpecialFilesError, SpecialFilesSkip:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown special files policy %q, expected %q or %q", s, SpecialFilesError, SpecialFilesSkip)
+}
+
+// ApplyLinkPolicy returns the top-level files to add, with their symlinks
+// and special files handled by p. The directories of the local filesystem
+// are read again with the policy, the other files are left as they are.
+func ApplyLinkPolicy(top files.File, p LinkPolicy) files.File {
+	return &linkTop{File: top, policy: &p}
+}
+
+// linkTop applies the policy to the files passed as arguments.
+type linkTop struct {
+	files.File
+	policy *LinkPolicy
+}
+
+func (t *linkTop) NextFile() (files.File, error) {
+	f, err := t.File.NextFile()
+	if err != nil || f == nil {
+		return f, err
+	}
+
+	var path string
+	if _, ok := f.(*files.Symlink); ok {
+		path = f.FullPath()
+	} else if fi, ok := f.(files.FileInfo); ok && f.IsDirectory() {
+		path = fi.AbsPath()
+	}
+	if path == "" {
+		// a regular file, or not from the local filesystem
+		return f, nil
+	}
+
+	st, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return t.policy.open(f.FileName(), path, st, nil, true)
+}
+
+func (t *linkTop) Size() (int64, error) {
+	sf, ok := t.File.(files.SizeFile)
+	if !ok {
+		return 0, files.ErrNotReader
+	}
+	// the symlinks aren't followed
+	return sf.Size()
+}
+
+// open returns the file to add at path, or nil when it's left out. The
+// parents are the real paths of the directories holding it, to detect the
+// cycles of the symlinks followed.
+func (p *LinkPolicy) open(name, path string, st os.FileInfo, parents []string, arg bool) (files.File, error) {
+	if st.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		if !p.FollowSymlinks && !(arg && p.DerefArgs) {
+			return files.NewLinkFile(name, path, target, st), nil
+		}
+
+		tst, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			p.warn(fmt.Sprintf("%s is a dangling symlink to %s, adding the symlink", path, target))
+			return files.NewLinkFile(name, path, target, st), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		st = tst
+	}
+
+	switch mode := st.Mode(); {
+	case mode.IsRegular():
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return files.NewReaderPathFile(name, path, f, st), nil
+
+	case mode.IsDir():
+		if arg && !p.Recursive {
+			return nil, fmt.Errorf("%s is a directory, use the '-r' flag to specify directories", path)
+		}
+		if p.FollowSymlinks {
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, parent := range parents {
+				if parent == real {
+					return nil, fmt.Errorf("%s is a cycle of symlinks back to %s", path, real)
+				}
+			}
+			parents = append(parents[:len(parents):len(parents)], real)
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		return &linkDir{name: name, path: path, stat: st, entries: entries, policy: p, parents: parents}, nil
+
+	default:
+		if p.SpecialFiles == SpecialFilesSkip {
+			p.warn(fmt.Sprintf("skipping %s, a %s", path, specialKind(mode)))
+			return nil, nil
+		}
+		// the error is returned when the file is read, so that it can
+		// be ignored first
+		return &specialFile{
+			name: name,
+			path: path,
+			err:  fmt.Errorf("cannot add %s, a %s: only regular files, directories and symlinks can be added", path, specialKind(mode)),
+		}, nil
+	}
+}
+
+func (p *LinkPolicy) warn(msg string) {
+	if p.Warn != nil {
+		p.Warn(msg)
+	} else {
+		log.Warning(msg)
+	}
+}
+
+// specialKind describes the type of a special file.
+func specialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	}
+	return "special file"
+}
+
+// linkDir is a directory of the local filesystem, read with a policy.
+type linkDir struct {
+	name    string
+	path    string
+	stat    os.FileInfo
+	entries []os.FileInfo
+	policy  *LinkPolicy
+	parents []string
+}
+
+func (d *linkDir) NextFile() (files.File, error) {
+	for len(d.entries) > 0 {
+		st := d.entries[0]
+		d.entries = d.entries[1:]
+		if !d.policy.Hidden && strings.HasPrefix(st.Name(), ".") {
+			continue
+		}
+
+		name := filepath.ToSlash(filepath.Join(d.name, st.Name()))
+		path := filepath.ToSlash(filepath.Join(d.path, st.Name()))
+		f, err := d.policy.open(name, path, st, d.parents, false)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			return f, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+func (d *linkDir) Read(p []byte) (int, error) {
+	return 0, files.ErrNotReader
+}
+
+func (d *linkDir) Close() error {
+	return nil
+}
+
+func (d *linkDir) FileName() string {
+	return d.name
+}
+
+func (d *linkDir) FullPath() string {
+	return d.path
+}
+
+func (d *linkDir) IsDirectory() bool {
+	return true
+}
+
+func (d *linkDir) AbsPath() string {
+	return d.path
+}
+
+func (d *linkDir) Stat() os.FileInfo {
+	return d.stat
+}
+
+// specialFile is a special file which fails when it's read.
+type specialFile struct {
+	name string
+	path string
+	err  error
+}
+
+func (f *specialFile) Read(p []byte) (int, error) {
+	return 0, f.err
+}
+
+func (f *specialFile) Close() error {
+	return nil
+}
+
+func (f *specialFile) FileName() string {
+	return f.name
+}
+
+func (f *specialFile) FullPath() string {
+	return f.path
+}
+
+func (f *specialFile) IsDirectory() bool {
+	return false
+}
+
+func (f *specialFile) NextFile() (files.File, error) {
+	return nil, files.ErrNotDirectory
+}
@@ -0,0 +1,181 @@
+// +build !windows
+
+package coreunix
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	files "gx/ipfs/QmSP88ryZkHSRn1fnngAaV2Vcn63WUJzAavnRM9CVdU1Ky/go-ipfs-cmdkit/files"
+)
+
+// listFiles returns the files of the tree of f, by name, as "file", "dir",
+// "-> target" or the error reading them.
+func listFiles(t *testing.T, f files.File, out map[string]string) {
+	for {
+		c, err := f.NextFile()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch c := c.(type) {
+		case *files.Symlink:
+			out[c.FileName()] = "-> " + c.Target
+		default:
+			if c.IsDirectory() {
+				out[c.FileName()] = "dir"
+				listFiles(t, c, out)
+			} else if _, err := ioutil.ReadAll(c); err != nil {
+				out[c.FileName()] = "error"
+			} else {
+				out[c.FileName()] = "file"
+			}
+		}
+	}
+}
+
+func TestLinkPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	for _, d := range []string{"root/sub", "other"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"root/file", "root/.hidden", "root/sub/x", "other/y"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"root/link":     "file",
+		"root/dirlink":  "../other",
+		"root/dangling": "nowhere",
+		"rootlink":      "root",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := net.Listen("unix", filepath.Join(root, "sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	add := func(p LinkPolicy, arg string) map[string]string {
+		top := files.NewSliceFile("", "", []files.File{
+			files.NewLinkFile("arg", filepath.Join(dir, arg), "", nil),
+		})
+		out := make(map[string]string)
+		listFiles(t, ApplyLinkPolicy(top, p), out)
+		return out
+	}
+
+	got := add(LinkPolicy{DerefArgs: true, Recursive: true, SpecialFiles: SpecialFilesError}, "rootlink")
+	expected := map[string]string{
+		"arg":          "dir",
+		"arg/file":     "file",
+		"arg/sub":      "dir",
+		"arg/sub/x":    "file",
+		"arg/link":     "-> file",
+		"arg/dirlink":  "-> ../other",
+		"arg/dangling": "-> nowhere",
+		"arg/sock":     "error",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	var warnings []string
+	got = add(LinkPolicy{
+		FollowSymlinks: true,
+		Recursive:      true,
+		Hidden:         true,
+		SpecialFiles:   SpecialFilesSkip,
+		Warn:           func(msg string) { warnings = append(warnings, msg) },
+	}, "root")
+	expected = map[string]string{
+		"arg":           "dir",
+		"arg/.hidden":   "file",
+		"arg/file":      "file",
+		"arg/sub":       "dir",
+		"arg/sub/x":     "file",
+		"arg/link":      "file",
+		"arg/dirlink":   "dir",
+		"arg/dirlink/y": "file",
+		"arg/dangling":  "-> nowhere",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected warnings for the dangling symlink and the socket, got %v", warnings)
+	}
+
+	// without -r, the target of a symlink argument can't be a directory
+	top := ApplyLinkPolicy(files.NewSliceFile("", "", []files.File{
+		files.NewLinkFile("arg", filepath.Join(dir, "rootlink"), "root", nil),
+	}), LinkPolicy{DerefArgs: true})
+	if _, err := top.NextFile(); err == nil {
+		t.Fatal("expected an error for a directory without -r")
+	}
+}
+
+func TestLinkPolicyCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(dir, "a", "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := os.Lstat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arg, err := files.NewSerialFile("arg", dir, false, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := ApplyLinkPolicy(files.NewSliceFile("", "", []files.File{arg}), LinkPolicy{FollowSymlinks: true, Recursive: true})
+
+	var walk func(f files.File) error
+	walk = func(f files.File) error {
+		for {
+			c, err := f.NextFile()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if c.IsDirectory() {
+				if err := walk(c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := walk(top); err == nil {
+		t.Fatal("expected an error for the cycle")
+	}
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	discovery "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/discovery"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	p2phost "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+// DefaultMDNSInterval is the interval of the mDNS queries when
+// Discovery.MDNS.Interval is unset.
+const DefaultMDNSInterval = 5 * time.Second
+
+// maxDiscoveredPeers bounds the number of peers remembered by the discovery
+// state, the least recently found are forgotten first.
+const maxDiscoveredPeers = 256
+
+// MDNSSettings are the settings the mDNS discovery was started with.
+type MDNSSettings struct {
+	Interval   time.Duration
+	ServiceTag string
+}
+
+// DiscoveredPeer is a peer found by the local discovery.
+type DiscoveredPeer struct {
+	ID        peer.ID
+	Addrs     []string
+	FirstSeen time.Time
+	LastSeen  time.Time
+
+	// Attempted is whether the node dialed the peer when it was last
+	// found. It doesn't when it is already connected.
+	Attempted bool
+	// Connected is whether the last dial succeeded.
+	Connected bool
+	// Error is the error of the last dial that failed.
+	Error string `json:",omitempty"`
+}
+
+// discoveryState remembers the peers found by the local discovery.
+type discoveryState struct {
+	lk    sync.Mutex
+	mdns  *MDNSSettings
+	peers map[peer.ID]*DiscoveredPeer
+}
+
+// setupDiscoveryOption returns the option starting the mDNS discovery, or
+// nil if it is disabled. serviceTag defaults to the libp2p one.
+func (n *IpfsNode) setupDiscoveryOption(d config.Discovery, serviceTag string) DiscoveryOption {
+	if !d.MDNS.Enabled {
+		return nil
+	}
+
+	settings := &MDNSSettings{
+		Interval:   time.Duration(d.MDNS.Interval) * time.Second,
+		ServiceTag: serviceTag,
+	}
+	if settings.Interval <= 0 {
+		settings.Interval = DefaultMDNSInterval
+	}
+	if settings.ServiceTag == "" {
+		settings.ServiceTag = discovery.ServiceTag
+	}
+	return func(ctx context.Context, h p2phost.Host) (discovery.Service, error) {
+		service, err := discovery.NewMdnsService(ctx, h, settings.Interval, settings.ServiceTag)
+		if err != nil {
+			return nil, err
+		}
+		n.setMDNS(settings)
+		return service, nil
+	}
+}
+
+// MDNS returns the settings of the mDNS discovery, or nil when it isn't
+// running.
+func (n *IpfsNode) MDNS() *MDNSSettings {
+	n.discovered.lk.Lock()
+	defer n.discovered.lk.Unlock()
+	return n.discovered.mdns
+}
+
+func (n *IpfsNode) setMDNS(s *MDNSSettings) {
+	n.discovered.lk.Lock()
+	defer n.discovered.lk.Unlock()
+	n.discovered.mdns = s
+}
+
+// DiscoveredPeers returns the peers found by the local discovery, the most
+// recently found first.
+func (n *IpfsNode) DiscoveredPeers() []DiscoveredPeer {
+	n.discovered.lk.Lock()
+	defer n.discovered.lk.Unlock()
+
+	out := make([]DiscoveredPeer, 0, len(n.discovered.peers))
+	for _, p := range n.discovered.peers {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].LastSeen.After(out[j].LastSeen)
+	})
+	return out
+}
+
+// peerFound records a discovered peer, which is dialed unless already
+// connected.
+func (n *IpfsNode) peerFound(p pstore.PeerInfo, connected bool) {
+	n.discovered.lk.Lock()
+	defer n.discovered.lk.Unlock()
+
+	if n.discovered.peers == nil {
+		n.discovered.peers = make(map[peer.ID]*DiscoveredPeer)
+	}
+	now := time.Now()
+	dp, ok := n.discovered.peers[p.ID]
+	if !ok {
+		if len(n.discovered.peers) >= maxDiscoveredPeers {
+			n.forgetOldestDiscoveredPeer()
+		}
+		dp = &DiscoveredPeer{ID: p.ID, FirstSeen: now}
+		n.discovered.peers[p.ID] = dp
+	}
+	dp.LastSeen = now
+	dp.Addrs = make([]string, 0, len(p.Addrs))
+	for _, a := range p.Addrs {
+		dp.Addrs = append(dp.Addrs, a.String())
+	}
+	dp.Attempted = !connected
+	dp.Connected = connected
+	dp.Error = ""
+}
+
+// forgetOldestDiscoveredPeer must be called with the lock held.
+func (n *IpfsNode) forgetOldestDiscoveredPeer() {
+	var oldest *DiscoveredPeer
+	for _, p := range n.discovered.peers {
+		if oldest == nil || p.LastSeen.Before(oldest.LastSeen) {
+			oldest = p
+		}
+	}
+	if oldest != nil {
+		delete(n.discovered.peers, oldest.ID)
+	}
+}
+
+func (n *IpfsNode) peerDialed(id peer.ID, err error) {
+	n.discovered.lk.Lock()
+	defer n.discovered.lk.Unlock()
+
+	dp, ok := n.discovered.peers[id]
+	if !ok {
+		return
+	}
+	dp.Connected = err == nil
+	if err != nil {
+		dp.Error = err.Error()
+	}
+}
+
+// HandlePeerFound attempts to connect to peer from `PeerInfo`, if it fails
+// logs a warning log.
+func (n *IpfsNode) HandlePeerFound(p pstore.PeerInfo) {
+	connected := n.PeerHost.Network().Connectedness(p.ID) == inet.Connected
+	n.peerFound(p, connected)
+	if connected {
+		return
+	}
+
+	log.Debug("trying peer info: ", p)
+	ctx, cancel := context.WithTimeout(n.Context(), discoveryConnTimeout)
+	defer cancel()
+	err := n.PeerHost.Connect(ctx, p)
+	if err != nil {
+		log.Warning("Failed to connect to peer found by discovery: ", err)
+	}
+	n.peerDialed(p.ID, err)
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+func TestDiscoveredPeers(t *testing.T) {
+	n := new(IpfsNode)
+	a, err := ma.NewMultiaddr("/ip4/192.168.1.2/tcp/4001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.peerFound(pstore.PeerInfo{ID: peer.ID("a"), Addrs: []ma.Multiaddr{a}}, false)
+	n.peerDialed(peer.ID("a"), errors.New("refused"))
+	n.peerFound(pstore.PeerInfo{ID: peer.ID("b")}, true)
+
+	peers := n.DiscoveredPeers()
+	if len(peers) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(peers))
+	}
+	if peers[0].ID != "b" || peers[0].Attempted {
+		t.Fatalf("expected the connected peer not to be dialed: %+v", peers[0])
+	}
+	if p := peers[1]; !p.Attempted || p.Connected || p.Error != "refused" || len(p.Addrs) != 1 {
+		t.Fatalf("unexpected failed peer %+v", p)
+	}
+
+	// found again, and dialed successfully this time
+	n.peerFound(pstore.PeerInfo{ID: peer.ID("a")}, false)
+	n.peerDialed(peer.ID("a"), nil)
+	peers = n.DiscoveredPeers()
+	if p := peers[0]; p.ID != "a" || !p.Connected || p.Error != "" {
+		t.Fatalf("unexpected peer %+v", p)
+	}
+}
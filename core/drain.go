@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout bounds the wait for the requests in flight when the
+// node is drained.
+const DefaultDrainTimeout = time.Minute
+
+// ErrDraining is returned by StartRequest once the node is draining.
+var ErrDraining = errors.New("the node is shutting down")
+
+// drainState tracks the requests in flight so that a shutdown can wait for
+// them.
+type drainState struct {
+	lk       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+}
+
+// StartRequest registers a request in flight, which the node waits for when
+// it is drained. done must be called when the request is over. It returns
+// ErrDraining once the node stopped accepting requests.
+func (n *IpfsNode) StartRequest() (done func(), err error) {
+	n.drain.lk.Lock()
+	defer n.drain.lk.Unlock()
+	if n.drain.draining {
+		return nil, ErrDraining
+	}
+
+	n.drain.inflight.Add(1)
+	var once sync.Once
+	return func() { once.Do(n.drain.inflight.Done) }, nil
+}
+
+// Draining returns whether the node stopped accepting requests.
+func (n *IpfsNode) Draining() bool {
+	n.drain.lk.Lock()
+	defer n.drain.lk.Unlock()
+	return n.drain.draining
+}
+
+// Drain shuts the node down gracefully: it stops accepting requests, waits
+// up to timeout for the ones in flight, such as adds and pins, flushes the
+// files root and the pins, closes the connections to the peers so they
+// notice the departure right away, and closes the node.
+func (n *IpfsNode) Drain(timeout time.Duration) error {
+	n.drain.lk.Lock()
+	if n.drain.draining {
+		n.drain.lk.Unlock()
+		return ErrDraining
+	}
+	n.drain.draining = true
+	n.drain.lk.Unlock()
+
+	log.Info("draining: waiting for the requests in flight")
+	finished := make(chan struct{})
+	go func() {
+		n.drain.inflight.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		log.Warningf("draining: requests still in flight after %s, shutting down anyway", timeout)
+	case <-n.Process().Closing():
+		return nil
+	}
+
+	if n.FilesRoot != nil {
+		if err := n.FilesRoot.Flush(); err != nil {
+			log.Errorf("draining: flushing the files root: %s", err)
+		}
+	}
+	if n.Pinning != nil {
+		if err := n.Pinning.Flush(); err != nil {
+			log.Errorf("draining: flushing the pins: %s", err)
+		}
+	}
+
+	if n.PeerHost != nil {
+		net := n.PeerHost.Network()
+		for _, p := range net.Peers() {
+			if err := net.ClosePeer(p); err != nil {
+				log.Debugf("draining: closing the connections to %s: %s", p, err)
+			}
+		}
+	}
+
+	log.Info("draining: done, shutting down")
+	return n.Process().Close()
+}
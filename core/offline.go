@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	offroute "gx/ipfs/QmSNe4MWVxZWk6UxxW2z2EKofFo4GdFzud1vfn1iVby3mj/go-ipfs-routing/offline"
+	resolver "gx/ipfs/QmX7uSbkNz76yNwBhuwYwRbhihLnJqM73VTCjS3UMJud9A/go-path/resolver"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// NotFoundLocallyError is returned by the block and DAG services of an
+// offline view when a block isn't in the local blockstore.
+type NotFoundLocallyError struct {
+	Cid cid.Cid
+}
+
+func (e *NotFoundLocallyError) Error() string {
+	return fmt.Sprintf("block not found locally: %s", e.Cid)
+}
+
+// OfflineView returns a shallow copy of the node whose blocks, DAG, path
+// resolution and name system only use the local repo, so that a single
+// request can run offline while the node stays online. The other services
+// are shared with the node.
+func (n *IpfsNode) OfflineView() (*IpfsNode, error) {
+	if n.Blockstore == nil {
+		return nil, fmt.Errorf("node has no blockstore")
+	}
+
+	view := *n
+	view.Exchange = offline.Exchange(n.Blockstore)
+	bs := bserv.New(n.Blockstore, view.Exchange)
+	view.Blocks = &localBlocks{bs}
+	view.DAG = &localDAG{dag.NewDAGService(bs)}
+	view.Resolver = resolver.NewBasicResolver(view.DAG)
+
+	// IPNS names only resolve with the records of the datastore
+	view.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.RecordValidator)
+	view.Namesys = namesys.NewNameSystem(view.Routing, n.Repo.Datastore(), 0)
+	view.DAGTransfer = nil
+
+	return &view, nil
+}
+
+// localBlocks reports the blocks missing from the local blockstore with a
+// NotFoundLocallyError.
+type localBlocks struct {
+	bserv.BlockService
+}
+
+func (s *localBlocks) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b, err := s.BlockService.GetBlock(ctx, c)
+	if err == bserv.ErrNotFound {
+		return nil, &NotFoundLocallyError{Cid: c}
+	}
+	return b, err
+}
+
+// localDAG reports the blocks missing from the local blockstore with a
+// NotFoundLocallyError.
+type localDAG struct {
+	ipld.DAGService
+}
+
+func (d *localDAG) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	nd, err := d.DAGService.Get(ctx, c)
+	if err == ipld.ErrNotFound {
+		return nil, &NotFoundLocallyError{Cid: c}
+	}
+	return nd, err
+}
+
+func (d *localDAG) GetMany(ctx context.Context, keys []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(keys))
+	go func() {
+		defer close(out)
+
+		missing := cid.NewSet()
+		for _, c := range keys {
+			missing.Add(c)
+		}
+
+		for opt := range d.DAGService.GetMany(ctx, keys) {
+			if opt.Err == nil {
+				missing.Remove(opt.Node.Cid())
+			}
+			select {
+			case out <- opt:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// the blocks that aren't stored are silently skipped by the
+		// blockservice
+		if ctx.Err() == nil && missing.Len() > 0 {
+			out <- &ipld.NodeOption{Err: &NotFoundLocallyError{Cid: missing.Keys()[0]}}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+
+	coremetrics "github.com/ipfs/go-ipfs/core/coremetrics"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	exchange "gx/ipfs/QmR1nncPsZR14A4hWr39mq8Lm7BGgS68bHVT9nop8NpWEM/go-ipfs-exchange-interface"
+	bitswap "gx/ipfs/QmUyaGN3WPr3CTLai7DBvMikagK45V4fUi8p8cNRaJQoU1/go-bitswap"
+	bsnet "gx/ipfs/QmUyaGN3WPr3CTLai7DBvMikagK45V4fUi8p8cNRaJQoU1/go-bitswap/network"
+	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	pstoremem "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore/pstoremem"
+	p2phost "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// PeerstoreOption constructs the peerstore of the node.
+type PeerstoreOption func(ctx context.Context) (pstore.Peerstore, error)
+
+// BlockstoreOption wraps or replaces the blockstore built on the datastore of
+// the repo. The node adds its GC locking and, when enabled, the filestore on
+// top of the returned blockstore.
+type BlockstoreOption func(ctx context.Context, bs bstore.Blockstore) (bstore.Blockstore, error)
+
+// ExchangeOption constructs the exchange of an online node. bs is the
+// blockstore the exchange serves the blocks of, with the denylist applied.
+type ExchangeOption func(ctx context.Context, host p2phost.Host, rt routing.IpfsRouting, bs bstore.Blockstore) (exchange.Interface, error)
+
+func constructPeerstore(ctx context.Context) (pstore.Peerstore, error) {
+	return pstoremem.NewPeerstore(), nil
+}
+
+func constructBitswap(ctx context.Context, host p2phost.Host, rt routing.IpfsRouting, bs bstore.Blockstore) (exchange.Interface, error) {
+	network := coremetrics.BitswapNetwork(bsnet.NewFromIpfsHost(host, rt))
+	return bitswap.New(ctx, network, bs), nil
+}
+
+var DefaultPeerstoreOption PeerstoreOption = constructPeerstore
+var BitswapOption ExchangeOption = constructBitswap
+
+// Option configures the assembly of a node created with New.
+type Option func(cfg *BuildCfg) error
+
+// New constructs an IpfsNode from the given options. It is NewNode with the
+// BuildCfg filled by the options: a node is assembled in this order, each
+// subsystem defaulting to the one of the daemon when no option replaces it:
+//
+//   - the repo, an in-memory one by default
+//   - the peerstore (WithPeerstore)
+//   - the blockstore, built on the datastore of the repo (WithBlockstore)
+//   - when online, the libp2p host (WithHost), the routing (WithRouting)
+//     and the exchange (WithExchange), bitswap by default
+//   - the blockservice, the DAG service, the pinner and the files root
+func New(ctx context.Context, opts ...Option) (*IpfsNode, error) {
+	cfg := new(BuildCfg)
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return NewNode(ctx, cfg)
+}
+
+// Online makes the node connect to the network.
+func Online() Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Online = true
+		return nil
+	}
+}
+
+// Permanent makes the node run the processes that only pay off in the long
+// run, such as the bloom filter of the blockstore.
+func Permanent() Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Permanent = true
+		return nil
+	}
+}
+
+// WithRepo sets the repo of the node.
+func WithRepo(r repo.Repo) Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Repo = r
+		return nil
+	}
+}
+
+// WithExtraOption sets one of the ExtraOpts of the BuildCfg, such as
+// "pubsub".
+func WithExtraOption(key string, value bool) Option {
+	return func(cfg *BuildCfg) error {
+		if cfg.ExtraOpts == nil {
+			cfg.ExtraOpts = make(map[string]bool)
+		}
+		cfg.ExtraOpts[key] = value
+		return nil
+	}
+}
+
+// WithPeerstore sets the constructor of the peerstore.
+func WithPeerstore(opt PeerstoreOption) Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Peerstore = opt
+		return nil
+	}
+}
+
+// WithBlockstore sets the function wrapping the blockstore.
+func WithBlockstore(opt BlockstoreOption) Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Blockstore = opt
+		return nil
+	}
+}
+
+// WithHost sets the constructor of the libp2p host.
+func WithHost(opt HostOption) Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Host = opt
+		return nil
+	}
+}
+
+// WithRouting sets the constructor of the routing, such as DHTClientOption.
+func WithRouting(opt RoutingOption) Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Routing = opt
+		return nil
+	}
+}
+
+// WithExchange sets the constructor of the exchange.
+func WithExchange(opt ExchangeOption) Option {
+	return func(cfg *BuildCfg) error {
+		cfg.Exchange = opt
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/repo"
+
+	datastore "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	syncds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	pstoremem "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore/pstoremem"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+type wrappedBlockstore struct {
+	bstore.Blockstore
+}
+
+func TestNewWithOptions(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{Identity: testIdentity},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+
+	ps := pstoremem.NewPeerstore()
+	var wrapped *wrappedBlockstore
+	n, err := New(context.Background(),
+		WithRepo(r),
+		WithPeerstore(func(context.Context) (pstore.Peerstore, error) {
+			return ps, nil
+		}),
+		WithBlockstore(func(_ context.Context, bs bstore.Blockstore) (bstore.Blockstore, error) {
+			wrapped = &wrappedBlockstore{Blockstore: bs}
+			return wrapped, nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	if n.Peerstore != ps {
+		t.Fatal("expected the node to use the given peerstore")
+	}
+	if wrapped == nil || n.BaseBlocks != wrapped {
+		t.Fatal("expected the node to use the wrapped blockstore")
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+)
+
+// ErrReloadUnsupported is returned by ReloadConfig when the repo of the node
+// can't read its config again.
+var ErrReloadUnsupported = errors.New("the repo doesn't support reloading its config")
+
+// ConfigReloadResult is the outcome of applying a section of the config.
+type ConfigReloadResult struct {
+	Section string
+	Error   string `json:",omitempty"`
+}
+
+type configReloader struct {
+	section string
+	reload  func() error
+}
+
+// reloadState holds the functions applying the config sections that can
+// change while the node runs.
+type reloadState struct {
+	lk        sync.Mutex
+	reloaders []configReloader
+}
+
+// OnConfigReload registers a function applying a section of the config,
+// called by ReloadConfig after the config was read again.
+func (n *IpfsNode) OnConfigReload(section string, reload func() error) {
+	n.reload.lk.Lock()
+	defer n.reload.lk.Unlock()
+	n.reload.reloaders = append(n.reload.reloaders, configReloader{section, reload})
+}
+
+// ReloadConfig reads the config of the repo again and applies the sections
+// that can change without restarting the node. A section that fails to apply
+// doesn't prevent the others from being applied, its error is part of the
+// results.
+func (n *IpfsNode) ReloadConfig() ([]ConfigReloadResult, error) {
+	r, ok := n.Repo.(interface{ ReloadConfig() error })
+	if !ok {
+		return nil, ErrReloadUnsupported
+	}
+
+	n.reload.lk.Lock()
+	defer n.reload.lk.Unlock()
+
+	if err := r.ReloadConfig(); err != nil {
+		return nil, err
+	}
+
+	// a section can be applied by several functions, such as the gateways
+	// of the API and of the gateway address, it has a single result.
+	var results []ConfigReloadResult
+	index := make(map[string]int)
+	for _, rl := range n.reload.reloaders {
+		i, ok := index[rl.section]
+		if !ok {
+			i = len(results)
+			index[rl.section] = i
+			results = append(results, ConfigReloadResult{Section: rl.section})
+		}
+		if err := rl.reload(); err != nil {
+			log.Errorf("reloading %s: %s", rl.section, err)
+			if results[i].Error == "" {
+				results[i].Error = err.Error()
+			}
+		}
+	}
+	return results, nil
+}
+
+// reloadConnMgr applies the limits of the Swarm.ConnMgr config. Changing the
+// type of the connection manager requires a restart.
+func (n *IpfsNode) reloadConnMgr(cm *reloadableConnMgr) error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	switch cfg.Swarm.ConnMgr.Type {
+	case "", "basic":
+	default:
+		return fmt.Errorf("changing Swarm.ConnMgr.Type to %q requires a restart", cfg.Swarm.ConnMgr.Type)
+	}
+
+	low, high, grace, err := connMgrLimits(cfg.Swarm.ConnMgr)
+	if err != nil {
+		return err
+	}
+	cm.setLimits(n.PeerHost.Network(), low, high, grace)
+	return nil
+}
+
+// reloadPeering adds the peers of the Peering.Peers config missing from the
+// peering set, updates the addresses of the others, and removes the peers
+// that are no longer listed.
+func (n *IpfsNode) reloadPeering() error {
+	cfg, err := extconfig.LoadPeering(n.Repo)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[peer.ID]bool, len(cfg.Peers))
+	for _, p := range cfg.Peers {
+		pi, err := p.PeerInfo()
+		if err != nil {
+			return err
+		}
+		listed[pi.ID] = true
+		n.Peering.AddPeer(pi)
+	}
+	for _, pi := range n.Peering.ListPeers() {
+		if !listed[pi.ID] {
+			n.Peering.RemovePeer(pi.ID)
+		}
+	}
+	return nil
+}
+
+// reloadDenylist applies the files and URLs of the Denylist config, and
+// reads the denylists again. Changing the refresh interval requires a
+// restart.
+func (n *IpfsNode) reloadDenylist() error {
+	cfg, err := extconfig.LoadDenylist(n.Repo)
+	if err != nil {
+		return err
+	}
+	n.Denylist.SetSources(n.denylistSources(cfg))
+	return n.Denylist.Load()
+}
+
+// reloadRendezvous advertises the topics of the Discovery.Rendezvous config,
+// and stops advertising the others.
+func (n *IpfsNode) reloadRendezvous() error {
+	cfg, err := extconfig.LoadRendezvous(n.Repo)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(cfg.Topics))
+	for _, topic := range cfg.Topics {
+		if err := n.Rendezvous.Advertise(topic); err != nil {
+			return err
+		}
+		listed[topic] = true
+	}
+	for _, topic := range n.Rendezvous.Topics() {
+		if !listed[topic] {
+			n.Rendezvous.Stop(topic)
+		}
+	}
+	return nil
+}
+
+// reloadBandwidthLimits applies the Swarm.BandwidthLimits config.
+func (n *IpfsNode) reloadBandwidthLimits() error {
+	cfg, err := extconfig.LoadBandwidthLimits(n.Repo)
+	if err != nil {
+		return err
+	}
+	return n.Shaper.SetLimits(cfg)
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoutingOptions maps names to the routers of plugins.
+type RoutingOptions map[string]RoutingOption
+
+// CustomRoutingOptions is the registry of the routers of plugins, used by the
+// daemon when Routing.Type is "custom". Routing.Custom names the router.
+var CustomRoutingOptions = RoutingOptions{}
+
+// AddRoutingOption registers a router under the given name. The option can
+// wrap a built-in router, such as DHTOption, by calling it.
+func (ro RoutingOptions) AddRoutingOption(name string, opt RoutingOption) error {
+	if _, ok := ro[name]; ok {
+		return fmt.Errorf("router %q already registered", name)
+	}
+
+	ro[name] = opt
+	return nil
+}
+
+// Get returns the router registered under name. An empty name selects the
+// router when there's only one.
+func (ro RoutingOptions) Get(name string) (RoutingOption, error) {
+	if name == "" {
+		switch len(ro) {
+		case 0:
+			return nil, fmt.Errorf("no plugin registered a router")
+		case 1:
+			for _, opt := range ro {
+				return opt, nil
+			}
+		default:
+			names := make([]string, 0, len(ro))
+			for n := range ro {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("several routers are registered, set Routing.Custom to one of: %s", strings.Join(names, ", "))
+		}
+	}
+
+	opt, ok := ro[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown router %q", name)
+	}
+	return opt, nil
+}
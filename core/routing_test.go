@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestRoutingOptions(t *testing.T) {
+	ro := RoutingOptions{}
+	if _, err := ro.Get(""); err == nil {
+		t.Fatal("expected an empty registry to fail")
+	}
+
+	if err := ro.AddRoutingOption("indexer", NilRouterOption); err != nil {
+		t.Fatal(err)
+	}
+	if err := ro.AddRoutingOption("indexer", DHTOption); err == nil {
+		t.Fatal("expected duplicate registration to fail")
+	}
+	if _, err := ro.Get(""); err != nil {
+		t.Fatalf("expected the only router to be selected: %s", err)
+	}
+
+	if err := ro.AddRoutingOption("wrapped-dht", DHTOption); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ro.Get(""); err == nil {
+		t.Fatal("expected an empty name to be ambiguous")
+	}
+	if _, err := ro.Get("wrapped-dht"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ro.Get("missing"); err == nil {
+		t.Fatal("expected an unknown router to fail")
+	}
+}
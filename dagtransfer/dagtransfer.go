@@ -0,0 +1,476 @@
+// Package dagtransfer transfers whole DAGs between peers in a single request.
+//
+// With bitswap, a node fetching a DAG only learns the links of a block once
+// it received it, so that fetching a deep DAG takes a round trip per level.
+// With a DAG transfer, the node asks a peer for the DAG under a root, up to
+// a depth, and the peer streams back every block of it that it has, in the
+// order of a depth-first traversal.
+//
+// The protocol is specific to go-ipfs: it is not graphsync and doesn't
+// interoperate with its implementations. Only the go-ipfs nodes with
+// DAGTransfer.Enabled answer it.
+//
+// A request is a JSON line. The response starts with a JSON line accepting
+// or refusing it, followed by the blocks, each written as the varint length
+// of its CID, the CID, the varint length of its data and the data, and ends
+// with a zero length and a JSON line telling whether all the blocks were
+// sent.
+package dagtransfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+var log = logging.Logger("dagtransfer")
+
+// ProtocolID is the protocol of the DAG transfers, specific to this package.
+const ProtocolID = protocol.ID("/go-ipfs/dag-transfer/1.0.0")
+
+// AllDepths requests the whole DAG under the root.
+const AllDepths = -1
+
+// Limits and timeouts. Variables so tests can change them.
+var (
+	// idleTimeout bounds the time waited for the next message of a
+	// transfer.
+	idleTimeout = time.Minute
+
+	// maxBlockSize is the largest block accepted.
+	maxBlockSize = 4 << 20
+
+	// maxProviders is the number of providers of a DAG tried by FetchDAG.
+	maxProviders = 10
+
+	// findTimeout bounds the search of the providers by FetchDAG.
+	findTimeout = 30 * time.Second
+)
+
+const (
+	statusOK       = "OK"
+	statusRefused  = "REFUSED"
+	statusComplete = "COMPLETE"
+	statusPartial  = "PARTIAL"
+)
+
+// ErrIncomplete is returned by Fetch when the peer didn't have all the
+// blocks of the DAG.
+var ErrIncomplete = errors.New("dagtransfer: the peer did not send the whole DAG")
+
+type request struct {
+	Root  string
+	Depth int
+}
+
+type response struct {
+	Status string
+	Error  string `json:",omitempty"`
+}
+
+// Stats counts the transfers of the service.
+type Stats struct {
+	// Served is the number of requests of the peers answered.
+	Served uint64
+	// BlocksSent is the number of blocks sent to the peers.
+	BlocksSent uint64
+	// BlocksReceived is the number of blocks fetched from the peers.
+	BlocksReceived uint64
+}
+
+// Service answers the DAG transfer requests of the peers and fetches DAGs from
+// them.
+type Service struct {
+	host      host.Host
+	rt        routing.ContentRouting
+	bs        bstore.Blockstore
+	serve     bool
+	fetchDAGs bool
+
+	served   uint64
+	sent     uint64
+	received uint64
+}
+
+// NewService starts the DAG transfer service on the host. The blocks fetched are
+// stored in bs, and the requests of the peers are answered from bs when
+// cfg.Enabled is set. rt finds the providers of the DAGs fetched by
+// FetchDAG.
+func NewService(h host.Host, rt routing.ContentRouting, bs bstore.Blockstore, cfg *extconfig.DAGTransfer) *Service {
+	s := &Service{
+		host:      h,
+		rt:        rt,
+		bs:        bs,
+		serve:     cfg.Enabled,
+		fetchDAGs: cfg.FetchDAGs,
+	}
+	if s.serve {
+		h.SetStreamHandler(ProtocolID, s.handleStream)
+	}
+	return s
+}
+
+// Close stops answering requests.
+func (s *Service) Close() error {
+	if s.serve {
+		s.host.RemoveStreamHandler(ProtocolID)
+	}
+	return nil
+}
+
+// FetchDAGs returns whether the commands reading whole DAGs fetch them with
+// FetchDAG.
+func (s *Service) FetchDAGs() bool {
+	return s.fetchDAGs
+}
+
+// Stats returns the transfer counters.
+func (s *Service) Stats() Stats {
+	return Stats{
+		Served:         atomic.LoadUint64(&s.served),
+		BlocksSent:     atomic.LoadUint64(&s.sent),
+		BlocksReceived: atomic.LoadUint64(&s.received),
+	}
+}
+
+func (s *Service) handleStream(str inet.Stream) {
+	defer str.Close()
+	p := str.Conn().RemotePeer()
+
+	r := bufio.NewReader(str)
+	w := bufio.NewWriter(str)
+
+	str.SetReadDeadline(time.Now().Add(idleTimeout))
+	var req request
+	if err := readMessage(r, &req); err != nil {
+		log.Debugf("invalid request from %s: %s", p.Pretty(), err)
+		str.Reset()
+		return
+	}
+
+	root, err := cid.Decode(req.Root)
+	if err != nil {
+		writeMessage(w, &response{Status: statusRefused, Error: "invalid root: " + err.Error()})
+		w.Flush()
+		return
+	}
+
+	atomic.AddUint64(&s.served, 1)
+	if err := writeMessage(w, &response{Status: statusOK}); err != nil {
+		str.Reset()
+		return
+	}
+
+	complete, err := s.sendDAG(str, w, root, req.Depth)
+	if err != nil {
+		log.Debugf("sending %s to %s: %s", root, p.Pretty(), err)
+		str.Reset()
+		return
+	}
+
+	status := statusComplete
+	if !complete {
+		status = statusPartial
+	}
+	if err := writeMessage(w, &response{Status: status}); err != nil {
+		str.Reset()
+		return
+	}
+	if err := w.Flush(); err != nil {
+		str.Reset()
+	}
+}
+
+// sendDAG writes the blocks of the DAG under root, up to depth, that are in
+// the blockstore, depth first. It returns false if some were missing.
+func (s *Service) sendDAG(str inet.Stream, w *bufio.Writer, root cid.Cid, depth int) (bool, error) {
+	type entry struct {
+		c     cid.Cid
+		depth int
+	}
+
+	complete := true
+	seen := cid.NewSet()
+	stack := []entry{{root, 0}}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !seen.Visit(e.c) {
+			continue
+		}
+
+		b, err := s.bs.Get(e.c)
+		if err != nil {
+			complete = false
+			continue
+		}
+
+		str.SetWriteDeadline(time.Now().Add(idleTimeout))
+		if err := writeBlock(w, b); err != nil {
+			return false, err
+		}
+		atomic.AddUint64(&s.sent, 1)
+
+		if depth != AllDepths && e.depth >= depth {
+			continue
+		}
+		nd, err := ipld.Decode(b)
+		if err != nil {
+			// a format we cannot read the links of
+			continue
+		}
+		links := nd.Links()
+		for i := len(links) - 1; i >= 0; i-- {
+			stack = append(stack, entry{links[i].Cid, e.depth + 1})
+		}
+	}
+
+	// end of the blocks
+	str.SetWriteDeadline(time.Now().Add(idleTimeout))
+	if err := writeUvarint(w, 0); err != nil {
+		return false, err
+	}
+	return complete, nil
+}
+
+// Fetch requests the DAG under root, up to depth, from p and stores the
+// blocks it sends. It returns the number of blocks received, and
+// ErrIncomplete when p didn't have all of them.
+func (s *Service) Fetch(ctx context.Context, p peer.ID, root cid.Cid, depth int) (int, error) {
+	str, err := s.host.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return 0, err
+	}
+	defer str.Close()
+
+	// unblock the reads when ctx is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			str.Reset()
+		case <-done:
+		}
+	}()
+
+	r := bufio.NewReader(str)
+	w := bufio.NewWriter(str)
+
+	str.SetDeadline(time.Now().Add(idleTimeout))
+	if err := writeMessage(w, &request{Root: root.String(), Depth: depth}); err != nil {
+		str.Reset()
+		return 0, err
+	}
+	if err := w.Flush(); err != nil {
+		str.Reset()
+		return 0, err
+	}
+
+	var resp response
+	if err := readMessage(r, &resp); err != nil {
+		str.Reset()
+		return 0, err
+	}
+	if resp.Status != statusOK {
+		return 0, fmt.Errorf("dagtransfer: request refused: %s", resp.Error)
+	}
+
+	// the blocks must belong to the DAG requested: each must be the root or
+	// linked by a block received before, within the depth.
+	expected := map[cid.Cid]int{root: 0}
+	received := 0
+	for {
+		str.SetReadDeadline(time.Now().Add(idleTimeout))
+		b, err := readBlock(r)
+		if err != nil {
+			str.Reset()
+			return received, err
+		}
+		if b == nil {
+			break
+		}
+
+		d, ok := expected[b.Cid()]
+		if !ok {
+			str.Reset()
+			return received, fmt.Errorf("dagtransfer: unexpected block %s", b.Cid())
+		}
+		delete(expected, b.Cid())
+
+		if err := s.bs.Put(b); err != nil {
+			str.Reset()
+			return received, err
+		}
+		received++
+		atomic.AddUint64(&s.received, 1)
+
+		if depth != AllDepths && d >= depth {
+			continue
+		}
+		nd, err := ipld.Decode(b)
+		if err != nil {
+			continue
+		}
+		for _, l := range nd.Links() {
+			if _, ok := expected[l.Cid]; !ok {
+				expected[l.Cid] = d + 1
+			}
+		}
+	}
+
+	if err := readMessage(r, &resp); err != nil {
+		str.Reset()
+		return received, err
+	}
+	if resp.Status != statusComplete {
+		return received, ErrIncomplete
+	}
+	return received, nil
+}
+
+// FetchDAG fetches the whole DAG under root from one of its providers
+// supporting DAG transfers. It returns an error when none sent the whole DAG,
+// the blocks received are kept and the rest can be fetched with bitswap.
+func (s *Service) FetchDAG(ctx context.Context, root cid.Cid) error {
+	findCtx, cancel := context.WithTimeout(ctx, findTimeout)
+	defer cancel()
+
+	tried := 0
+	for pi := range s.rt.FindProvidersAsync(findCtx, root, maxProviders) {
+		if pi.ID == s.host.ID() {
+			continue
+		}
+		if err := s.host.Connect(ctx, pi); err != nil {
+			continue
+		}
+
+		tried++
+		n, err := s.Fetch(ctx, pi.ID, root, AllDepths)
+		if err == nil {
+			log.Debugf("fetched %s from %s: %d blocks", root, pi.ID.Pretty(), n)
+			return nil
+		}
+		log.Debugf("fetching %s from %s: %s", root, pi.ID.Pretty(), err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("dagtransfer: none of the %d providers of %s sent the whole DAG", tried, root)
+}
+
+// Prefetch fetches the DAG under root with the FetchDAG of s, when s is not
+// nil and FetchDAGs is set. The failures are only logged: the blocks still
+// missing are fetched with bitswap as they are read.
+func Prefetch(ctx context.Context, s *Service, root cid.Cid) {
+	if s == nil || !s.FetchDAGs() {
+		return
+	}
+	if err := s.FetchDAG(ctx, root); err != nil {
+		log.Debugf("fetching %s: %s", root, err)
+	}
+}
+
+func writeMessage(w *bufio.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readMessage(r *bufio.Reader, v interface{}) error {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(line, v)
+}
+
+func writeUvarint(w *bufio.Writer, x uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, x)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+func writeBlock(w *bufio.Writer, b blocks.Block) error {
+	c := b.Cid().Bytes()
+	if err := writeUvarint(w, uint64(len(c))); err != nil {
+		return err
+	}
+	if _, err := w.Write(c); err != nil {
+		return err
+	}
+	if err := writeUvarint(w, uint64(len(b.RawData()))); err != nil {
+		return err
+	}
+	_, err := w.Write(b.RawData())
+	return err
+}
+
+// readBlock reads the next block, or returns nil at the end of the blocks.
+// The data of the block is checked against its CID.
+func readBlock(r *bufio.Reader) (blocks.Block, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n > 128 {
+		return nil, fmt.Errorf("CID of %d bytes too long", n)
+	}
+	cb := make([]byte, n)
+	if _, err := io.ReadFull(r, cb); err != nil {
+		return nil, err
+	}
+	c, err := cid.Cast(cb)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(maxBlockSize) {
+		return nil, fmt.Errorf("block %s of %d bytes too large", c, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("the data of block %s does not match its CID", c)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
@@ -0,0 +1,141 @@
+package dagtransfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"testing"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	mocknet "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/net/mock"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+func newBlockstore() bstore.Blockstore {
+	return bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+}
+
+// buildDAG stores a root linking to two children, the first of which links
+// to a leaf, and returns them depth first.
+func buildDAG(t *testing.T, bs bstore.Blockstore) []*dag.ProtoNode {
+	leaf := dag.NodeWithData([]byte("leaf"))
+	child1 := dag.NodeWithData([]byte("child1"))
+	child2 := dag.NodeWithData([]byte("child2"))
+	root := dag.NodeWithData([]byte("root"))
+	for _, l := range []struct {
+		from, to *dag.ProtoNode
+		name     string
+	}{
+		{child1, leaf, "leaf"},
+		{root, child1, "child1"},
+		{root, child2, "child2"},
+	} {
+		if err := l.from.AddNodeLink(l.name, l.to); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nodes := []*dag.ProtoNode{root, child1, leaf, child2}
+	for _, nd := range nodes {
+		if err := bs.Put(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nodes
+}
+
+func TestFetch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1, h2 := mn.Hosts()[0], mn.Hosts()[1]
+
+	serverBs := newBlockstore()
+	nodes := buildDAG(t, serverBs)
+	server := NewService(h2, nil, serverBs, &extconfig.DAGTransfer{Enabled: true})
+	defer server.Close()
+
+	// the root and its children only
+	clientBs := newBlockstore()
+	client := NewService(h1, nil, clientBs, &extconfig.DAGTransfer{})
+	n, err := client.Fetch(ctx, h2.ID(), nodes[0].Cid(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 blocks, got %d", n)
+	}
+	if has, _ := clientBs.Has(nodes[2].Cid()); has {
+		t.Fatal("the leaf is deeper than requested")
+	}
+
+	// the whole DAG
+	clientBs = newBlockstore()
+	client = NewService(h1, nil, clientBs, &extconfig.DAGTransfer{})
+	n, err = client.Fetch(ctx, h2.ID(), nodes[0].Cid(), AllDepths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(nodes) {
+		t.Fatalf("expected %d blocks, got %d", len(nodes), n)
+	}
+	for _, nd := range nodes {
+		if has, _ := clientBs.Has(nd.Cid()); !has {
+			t.Fatalf("block %s not fetched", nd.Cid())
+		}
+	}
+	if st := server.Stats(); st.Served != 2 || st.BlocksSent != 7 {
+		t.Fatalf("unexpected server stats %+v", st)
+	}
+
+	// a DAG the server has a part of
+	if err := serverBs.DeleteBlock(nodes[1].Cid()); err != nil {
+		t.Fatal(err)
+	}
+	clientBs = newBlockstore()
+	client = NewService(h1, nil, clientBs, &extconfig.DAGTransfer{})
+	n, err = client.Fetch(ctx, h2.ID(), nodes[0].Cid(), AllDepths)
+	if err != ErrIncomplete {
+		t.Fatalf("expected ErrIncomplete, got %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 blocks, got %d", n)
+	}
+}
+
+func TestReadBlockChecksData(t *testing.T) {
+	b := blocks.NewBlock([]byte("data"))
+	forged, err := blocks.NewBlockWithCid([]byte("other data"), b.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		b  blocks.Block
+		ok bool
+	}{{b, true}, {forged, false}} {
+		buf := new(bytes.Buffer)
+		w := bufio.NewWriter(buf)
+		if err := writeBlock(w, tc.b); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+
+		got, err := readBlock(bufio.NewReader(buf))
+		if tc.ok && (err != nil || !bytes.Equal(got.RawData(), b.RawData())) {
+			t.Fatalf("expected the block to be read back, got %v", err)
+		}
+		if !tc.ok && err == nil {
+			t.Fatal("expected the forged block to be rejected")
+		}
+	}
+}
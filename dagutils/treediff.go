@@ -0,0 +1,186 @@
+package dagutils
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// TreeChange is a change between two IPLD trees found by DiffTrees. Before
+// and After are the values at Path, a CID for the links and the values
+// themselves otherwise. Before is nil for additions and After for removals.
+type TreeChange struct {
+	Type   coreiface.ChangeType
+	Path   string
+	Before interface{} `json:",omitempty"`
+	After  interface{} `json:",omitempty"`
+}
+
+// DiffTrees compares the trees rooted at a and b, of any IPLD format, and
+// calls emit for each path added, removed or changed, in order.
+//
+// The values of a node are compared by path: the links of dag-pb nodes by
+// name, the fields of the other formats as listed by their Tree method. When
+// a link changed on both sides, the linked nodes are fetched from ng and
+// compared in turn; the links unchanged are never followed, and the
+// subtrees added or removed are reported at their root only. Two nodes
+// without values, like raw leaves, are reported as changed when their CIDs
+// differ.
+//
+// The changes are found depth-first and emitted as they are, so only the
+// nodes on the current path are held in memory. An error returned by emit
+// stops the diff and is returned.
+func DiffTrees(ctx context.Context, ng ipld.NodeGetter, a, b ipld.Node, emit func(*TreeChange) error) error {
+	return diffTrees(ctx, ng, "", a, b, emit)
+}
+
+func diffTrees(ctx context.Context, ng ipld.NodeGetter, p string, a, b ipld.Node, emit func(*TreeChange) error) error {
+	if a.Cid().Equals(b.Cid()) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	va, err := treeValues(a)
+	if err != nil {
+		return err
+	}
+	vb, err := treeValues(b)
+	if err != nil {
+		return err
+	}
+
+	if len(va) == 0 && len(vb) == 0 {
+		return emit(&TreeChange{Type: Mod, Path: p, Before: a.Cid(), After: b.Cid()})
+	}
+
+	keys := make([]string, 0, len(va)+len(vb))
+	for k := range va {
+		keys = append(keys, k)
+	}
+	for k := range vb {
+		if _, ok := va[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		before, inA := va[k]
+		after, inB := vb[k]
+		kp := path.Join(p, k)
+
+		var c *TreeChange
+		switch {
+		case !inB:
+			c = &TreeChange{Type: Remove, Path: kp, Before: treeValue(before)}
+		case !inA:
+			c = &TreeChange{Type: Add, Path: kp, After: treeValue(after)}
+		default:
+			la, aIsLink := before.(*ipld.Link)
+			lb, bIsLink := after.(*ipld.Link)
+			if aIsLink && bIsLink {
+				if la.Cid.Equals(lb.Cid) {
+					continue
+				}
+				if err := diffLinks(ctx, ng, kp, la.Cid, lb.Cid, emit); err != nil {
+					return err
+				}
+				continue
+			}
+			if !aIsLink && !bIsLink && reflect.DeepEqual(before, after) {
+				continue
+			}
+			c = &TreeChange{Type: Mod, Path: kp, Before: treeValue(before), After: treeValue(after)}
+		}
+		if err := emit(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffLinks(ctx context.Context, ng ipld.NodeGetter, p string, a, b cid.Cid, emit func(*TreeChange) error) error {
+	na, err := ng.Get(ctx, a)
+	if err != nil {
+		return fmt.Errorf("get %s: %s", a, err)
+	}
+	nb, err := ng.Get(ctx, b)
+	if err != nil {
+		return fmt.Errorf("get %s: %s", b, err)
+	}
+	return diffTrees(ctx, ng, p, na, nb, emit)
+}
+
+// treeValues returns the values of nd by path, the links as *ipld.Link.
+func treeValues(nd ipld.Node) (map[string]interface{}, error) {
+	if pb, ok := nd.(*dag.ProtoNode); ok {
+		out := make(map[string]interface{}, len(pb.Links()))
+		for _, l := range pb.Links() {
+			// keep the first of the links sharing a name, like ResolveLink
+			if _, ok := out[l.Name]; !ok {
+				out[l.Name] = l
+			}
+		}
+		return out, nil
+	}
+
+	// only the leaves of the tree are values, their parents are maps or
+	// lists holding them
+	paths := nd.Tree("", -1)
+	parents := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		for i := strings.LastIndexByte(p, '/'); i > 0; i = strings.LastIndexByte(p[:i], '/') {
+			parents[p[:i]] = true
+		}
+	}
+
+	out := make(map[string]interface{}, len(paths))
+	for _, p := range paths {
+		if parents[p] {
+			continue
+		}
+		v, rest, err := nd.Resolve(strings.Split(p, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s in %s: %s", p, nd.Cid(), err)
+		}
+		if len(rest) > 0 {
+			return nil, fmt.Errorf("resolve %s in %s: path goes through a link", p, nd.Cid())
+		}
+		out[p] = v
+	}
+	return out, nil
+}
+
+// treeValue returns v as reported in a TreeChange.
+func treeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *ipld.Link:
+		return v.Cid
+	case map[interface{}]interface{}:
+		// not representable in JSON
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(k)] = treeValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = treeValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
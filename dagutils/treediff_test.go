@@ -0,0 +1,127 @@
+package dagutils
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	cbor "gx/ipfs/QmPrv66vmh2P7vLJMpYx6DWLTNKvVB4Jdkyxs6V3QvWKvf/go-ipld-cbor"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	mdtest "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag/test"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+func collectTreeDiff(t *testing.T, ds ipld.DAGService, a, b ipld.Node) []*TreeChange {
+	var out []*TreeChange
+	err := DiffTrees(context.Background(), ds, a, b, func(c *TreeChange) error {
+		out = append(out, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func checkTreeChange(t *testing.T, c *TreeChange, typ coreiface.ChangeType, p string) {
+	t.Helper()
+	if c.Type != typ || c.Path != p {
+		t.Fatalf("expected change %d at %q, got %d at %q", typ, p, c.Type, c.Path)
+	}
+}
+
+func TestDiffTreesProtobuf(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	addNode := func(nd ipld.Node) ipld.Node {
+		if err := ds.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+	dir := func(links map[string]ipld.Node) ipld.Node {
+		nd := new(dag.ProtoNode)
+		for name, child := range links {
+			if err := nd.AddNodeLink(name, child); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return addNode(nd)
+	}
+
+	one := addNode(dag.NodeWithData([]byte("1")))
+	two := addNode(dag.NodeWithData([]byte("2")))
+	three := addNode(dag.NodeWithData([]byte("3")))
+
+	a := dir(map[string]ipld.Node{
+		"same": one,
+		"gone": one,
+		"sub":  dir(map[string]ipld.Node{"file": one, "other": two}),
+	})
+	b := dir(map[string]ipld.Node{
+		"same": one,
+		"new":  three,
+		"sub":  dir(map[string]ipld.Node{"file": two, "other": two}),
+	})
+
+	changes := collectTreeDiff(t, ds, a, b)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	checkTreeChange(t, changes[0], Remove, "gone")
+	checkTreeChange(t, changes[1], Add, "new")
+	checkTreeChange(t, changes[2], Mod, "sub/file")
+	if !changes[2].Before.(cid.Cid).Equals(one.Cid()) || !changes[2].After.(cid.Cid).Equals(two.Cid()) {
+		t.Fatal("expected the CIDs of the files before and after")
+	}
+
+	if changes := collectTreeDiff(t, ds, a, a); len(changes) != 0 {
+		t.Fatalf("expected no change, got %d", len(changes))
+	}
+}
+
+func TestDiffTreesCbor(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	wrap := func(obj map[string]interface{}) ipld.Node {
+		nd, err := cbor.WrapObject(obj, math.MaxUint64, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ds.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+
+	leafA := wrap(map[string]interface{}{"value": 1})
+	leafB := wrap(map[string]interface{}{"value": 2})
+
+	a := wrap(map[string]interface{}{
+		"name":  "a",
+		"meta":  map[string]interface{}{"size": 1, "tags": []interface{}{"x"}},
+		"child": leafA.Cid(),
+	})
+	b := wrap(map[string]interface{}{
+		"name":  "b",
+		"meta":  map[string]interface{}{"size": 1, "owner": "me"},
+		"child": leafB.Cid(),
+	})
+
+	changes := collectTreeDiff(t, ds, a, b)
+	if len(changes) != 4 {
+		t.Fatalf("expected 4 changes, got %d", len(changes))
+	}
+	checkTreeChange(t, changes[0], Mod, "child/value")
+	checkTreeChange(t, changes[1], Add, "meta/owner")
+	checkTreeChange(t, changes[2], Remove, "meta/tags/0")
+	checkTreeChange(t, changes[3], Mod, "name")
+	if changes[3].Before != "a" || changes[3].After != "b" {
+		t.Fatalf("unexpected values %v and %v", changes[3].Before, changes[3].After)
+	}
+}
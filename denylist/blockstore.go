@@ -0,0 +1,50 @@
+package denylist
+
+import (
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// blockstore hides the denied blocks from a service serving them to other
+// peers.
+type blockstore struct {
+	bstore.Blockstore
+	d       *Denylist
+	service string
+}
+
+// Blockstore wraps bs so that the blocks denied by d look missing to
+// service, which serves them to other peers.
+func Blockstore(bs bstore.Blockstore, d *Denylist, service string) bstore.Blockstore {
+	return &blockstore{Blockstore: bs, d: d, service: service}
+}
+
+func (bs *blockstore) denied(c cid.Cid) bool {
+	if !bs.d.CidDenied(c) {
+		return false
+	}
+	LogDenied(bs.service, c.String(), "a peer")
+	return true
+}
+
+func (bs *blockstore) Has(c cid.Cid) (bool, error) {
+	if bs.denied(c) {
+		return false, nil
+	}
+	return bs.Blockstore.Has(c)
+}
+
+func (bs *blockstore) Get(c cid.Cid) (blocks.Block, error) {
+	if bs.denied(c) {
+		return nil, bstore.ErrNotFound
+	}
+	return bs.Blockstore.Get(c)
+}
+
+func (bs *blockstore) GetSize(c cid.Cid) (int, error) {
+	if bs.denied(c) {
+		return -1, bstore.ErrNotFound
+	}
+	return bs.Blockstore.GetSize(c)
+}
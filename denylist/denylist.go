@@ -0,0 +1,382 @@
+// Package denylist maintains the set of CIDs and paths the node refuses to
+// serve, through its gateway and to other peers over bitswap.
+//
+// The entries are either added locally, and stored in the datastore, or
+// read from files and http(s) URLs, one per line. An entry is a CID, which
+// denies the block whatever the path it is reached through, or an /ipfs/ or
+// /ipns/ path, which denies the path and everything under it.
+package denylist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	gopath "path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+)
+
+// log is also the audit log of the denied requests, they are logged at the
+// info level.
+var log = logging.Logger("denylist")
+
+// LocalSource is the source of the entries added with Add.
+const LocalSource = "local"
+
+// dsPrefix is the datastore prefix of the local entries.
+var dsPrefix = ds.NewKey("/local/denylist")
+
+// fetchTimeout bounds the download of a denylist URL.
+var fetchTimeout = time.Minute
+
+// Entry is a denied CID or path.
+type Entry struct {
+	// Path is the entry as it was added or written in its source.
+	Path string
+
+	// Source is LocalSource, or the file or URL the entry was read from.
+	Source string
+}
+
+// Denylist is the set of the denied CIDs and paths. Its query methods can
+// be called on a nil Denylist, which denies nothing.
+type Denylist struct {
+	ds      ds.Datastore
+	sources []string
+	client  *http.Client
+
+	lk sync.RWMutex
+	// the entries of each source, by normalized path
+	entries map[string]map[string]string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New returns a Denylist storing its local entries in d, and reading the
+// others from the given files and URLs. Load must be called to read them.
+func New(d ds.Datastore, sources []string) *Denylist {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Denylist{
+		ds:      d,
+		sources: sources,
+		client:  &http.Client{Timeout: fetchTimeout},
+		entries: make(map[string]map[string]string),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Load reads the local entries and the sources. The sources that can't be
+// read keep the entries they had, and the first error is returned.
+func (d *Denylist) Load() error {
+	return d.load(true)
+}
+
+// LoadFiles reads the local entries and the file sources, like Load but
+// without fetching the URLs.
+func (d *Denylist) LoadFiles() error {
+	return d.load(false)
+}
+
+func (d *Denylist) load(urls bool) error {
+	local, err := d.loadLocal()
+	if err != nil {
+		return err
+	}
+	d.lk.Lock()
+	d.entries[LocalSource] = local
+	d.lk.Unlock()
+
+	d.lk.RLock()
+	sources := d.sources
+	d.lk.RUnlock()
+
+	var firstErr error
+	for _, src := range sources {
+		if !urls && isURL(src) {
+			continue
+		}
+		entries, err := d.readSource(src)
+		if err != nil {
+			log.Errorf("reading denylist %s: %s", src, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("reading denylist %s: %s", src, err)
+			}
+			continue
+		}
+
+		d.lk.Lock()
+		d.entries[src] = entries
+		d.lk.Unlock()
+	}
+	return firstErr
+}
+
+// SetSources replaces the files and URLs the entries are read from. The
+// entries of the removed sources are dropped, Load must be called to read
+// the new ones.
+func (d *Denylist) SetSources(sources []string) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	keep := map[string]bool{LocalSource: true}
+	for _, src := range sources {
+		keep[src] = true
+	}
+	for src := range d.entries {
+		if !keep[src] {
+			delete(d.entries, src)
+		}
+	}
+	d.sources = sources
+}
+
+// Start reloads the entries every interval, until the Denylist is closed.
+func (d *Denylist) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// the errors are logged by Load
+				d.Load()
+			case <-d.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic reloads.
+func (d *Denylist) Close() error {
+	d.cancel()
+	return nil
+}
+
+func (d *Denylist) loadLocal() (map[string]string, error) {
+	res, err := d.ds.Query(dsq.Query{Prefix: dsPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	entries := make(map[string]string)
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		v, ok := r.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("invalid denylist entry %s", r.Key)
+		}
+		p, err := normalize(string(v))
+		if err != nil {
+			return nil, err
+		}
+		entries[p] = string(v)
+	}
+	return entries, nil
+}
+
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+func (d *Denylist) readSource(src string) (map[string]string, error) {
+	if !isURL(src) {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseList(f, src)
+	}
+
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req.WithContext(d.ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parseList(resp.Body, src)
+}
+
+// parseList reads one entry per line, skipping the empty lines and the
+// comments starting with #. The invalid entries are logged and skipped.
+func parseList(r io.Reader, src string) (map[string]string, error) {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		p, err := normalize(s)
+		if err != nil {
+			log.Warningf("%s:%d: %s", src, line, err)
+			continue
+		}
+		entries[p] = s
+	}
+	return entries, scanner.Err()
+}
+
+// normalize returns the /ipfs/ or /ipns/ path of an entry, with the CID of
+// an /ipfs/ path replaced by its multihash so that all the versions and
+// encodings of a CID match.
+func normalize(entry string) (string, error) {
+	p := entry
+	if !strings.HasPrefix(p, "/") {
+		p = "/ipfs/" + p
+	}
+
+	parts := strings.SplitN(gopath.Clean(p)[1:], "/", 3)
+	if len(parts) < 2 || parts[1] == "" {
+		return "", fmt.Errorf("invalid denylist entry %q", entry)
+	}
+	switch parts[0] {
+	case "ipfs":
+		c, err := cid.Decode(parts[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid denylist entry %q: %s", entry, err)
+		}
+		parts[1] = c.Hash().B58String()
+	case "ipns":
+	default:
+		return "", fmt.Errorf("invalid denylist entry %q: not a CID, /ipfs/ or /ipns/ path", entry)
+	}
+	return "/" + strings.Join(parts, "/"), nil
+}
+
+// Add adds a local entry, and returns it.
+func (d *Denylist) Add(entry string) (Entry, error) {
+	p, err := normalize(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := d.ds.Put(dsPrefix.Child(ds.NewKey(p)), []byte(entry)); err != nil {
+		return Entry{}, err
+	}
+
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	if d.entries[LocalSource] == nil {
+		d.entries[LocalSource] = make(map[string]string)
+	}
+	d.entries[LocalSource][p] = entry
+	return Entry{Path: entry, Source: LocalSource}, nil
+}
+
+// Remove removes a local entry. The entries of the sources can't be
+// removed.
+func (d *Denylist) Remove(entry string) error {
+	p, err := normalize(entry)
+	if err != nil {
+		return err
+	}
+
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	if _, ok := d.entries[LocalSource][p]; !ok {
+		return fmt.Errorf("%s is not in the local denylist", entry)
+	}
+	if err := d.ds.Delete(dsPrefix.Child(ds.NewKey(p))); err != nil {
+		return err
+	}
+	delete(d.entries[LocalSource], p)
+	return nil
+}
+
+// List returns the entries of every source, sorted by path.
+func (d *Denylist) List() []Entry {
+	if d == nil {
+		return nil
+	}
+
+	d.lk.RLock()
+	var out []Entry
+	for src, entries := range d.entries {
+		for _, e := range entries {
+			out = append(out, Entry{Path: e, Source: src})
+		}
+	}
+	d.lk.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Source < out[j].Source
+	})
+	return out
+}
+
+func (d *Denylist) has(p string) bool {
+	for _, entries := range d.entries {
+		if _, ok := entries[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CidDenied returns whether the block c is denied.
+func (d *Denylist) CidDenied(c cid.Cid) bool {
+	if d == nil {
+		return false
+	}
+
+	d.lk.RLock()
+	defer d.lk.RUnlock()
+	return d.has("/ipfs/" + c.Hash().B58String())
+}
+
+// PathDenied returns whether p, or one of its parents, is denied.
+func (d *Denylist) PathDenied(p string) bool {
+	if d == nil {
+		return false
+	}
+
+	np, err := normalize(p)
+	if err != nil {
+		return false
+	}
+	segments := strings.Split(np[1:], "/")
+
+	d.lk.RLock()
+	defer d.lk.RUnlock()
+	for i := 2; i <= len(segments); i++ {
+		if d.has("/" + strings.Join(segments[:i], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogDenied writes a denied request to the audit log.
+func LogDenied(service, target, requester string) {
+	log.Infof("denied %s request for %s from %s", service, target, requester)
+}
@@ -0,0 +1,177 @@
+package denylist
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+func testCids(data string) (cid.Cid, cid.Cid) {
+	v0 := blocks.NewBlock([]byte(data)).Cid()
+	v1 := cid.NewCidV1(cid.DagProtobuf, v0.Hash())
+	return v0, v1
+}
+
+func TestCidAndPathEntries(t *testing.T) {
+	d := New(dssync.MutexWrap(ds.NewMapDatastore()), nil)
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	denied, deniedV1 := testCids("denied")
+	parent, _ := testCids("parent")
+	other, _ := testCids("other")
+
+	if _, err := d.Add(deniedV1.String()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Add("/ipfs/" + parent.String() + "/sub/dir"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Add("/ipns/example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Add("/foo/bar"); err == nil {
+		t.Fatal("expected an invalid entry to be rejected")
+	}
+
+	if !d.CidDenied(denied) || !d.CidDenied(deniedV1) {
+		t.Fatal("expected both versions of the CID to be denied")
+	}
+	if d.CidDenied(parent) || d.CidDenied(other) {
+		t.Fatal("expected the other CIDs to be allowed")
+	}
+
+	for p, exp := range map[string]bool{
+		"/ipfs/" + denied.String():                    true,
+		"/ipfs/" + denied.String() + "/a/b":           true,
+		"/ipfs/" + parent.String():                    false,
+		"/ipfs/" + parent.String() + "/sub":           false,
+		"/ipfs/" + parent.String() + "/sub/dir":       true,
+		"/ipfs/" + parent.String() + "/sub/dir/file":  true,
+		"/ipfs/" + parent.String() + "/sub/directory": false,
+		"/ipns/example.com/index.html":                true,
+		"/ipns/example.org":                           false,
+	} {
+		if d.PathDenied(p) != exp {
+			t.Errorf("PathDenied(%s): expected %t", p, exp)
+		}
+	}
+
+	// the local entries are persisted
+	d2 := New(d.ds, nil)
+	if err := d2.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(d2.List()) != 3 {
+		t.Fatalf("expected 3 entries, got %v", d2.List())
+	}
+
+	if err := d2.Remove(denied.String()); err != nil {
+		t.Fatal(err)
+	}
+	if d2.CidDenied(deniedV1) {
+		t.Fatal("expected the removed CID to be allowed")
+	}
+	if err := d2.Remove(other.String()); err == nil {
+		t.Fatal("expected removing a missing entry to fail")
+	}
+}
+
+func TestSources(t *testing.T) {
+	fromFile, _ := testCids("file")
+	fromURL, _ := testCids("url")
+
+	dir, err := ioutil.TempDir("", "denylist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "list")
+	list := fmt.Sprintf("# a comment\n\n%s\nnot an entry\n", fromFile)
+	if err := ioutil.WriteFile(file, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var served atomic.Value
+	served.Store(fromURL.String())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, served.Load())
+	}))
+	defer srv.Close()
+
+	d := New(ds.NewMapDatastore(), []string{file, srv.URL})
+	if err := d.LoadFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if !d.CidDenied(fromFile) || d.CidDenied(fromURL) {
+		t.Fatal("expected LoadFiles to read the file but not the URL")
+	}
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !d.CidDenied(fromFile) || !d.CidDenied(fromURL) {
+		t.Fatal("expected the entries of the sources to be denied")
+	}
+	if err := d.Remove(fromFile.String()); err == nil {
+		t.Fatal("expected the entries of the sources not to be removable")
+	}
+
+	// reloading picks the changes of the sources, the entries of a
+	// failing source are kept
+	served.Store("/ipns/example.com")
+	os.Remove(file)
+	if err := d.Load(); err == nil {
+		t.Fatal("expected the missing file to fail the reload")
+	}
+	if !d.CidDenied(fromFile) {
+		t.Fatal("expected the entries of the missing file to be kept")
+	}
+	if d.CidDenied(fromURL) || !d.PathDenied("/ipns/example.com") {
+		t.Fatal("expected the entries of the URL to be reloaded")
+	}
+
+	// the entries of a removed source are dropped
+	d.SetSources([]string{file})
+	if d.PathDenied("/ipns/example.com") {
+		t.Fatal("expected the entries of the removed URL to be dropped")
+	}
+}
+
+func TestBlockstore(t *testing.T) {
+	bs := bstore.NewBlockstore(ds.NewMapDatastore())
+	denied := blocks.NewBlock([]byte("denied"))
+	allowed := blocks.NewBlock([]byte("allowed"))
+	for _, b := range []blocks.Block{denied, allowed} {
+		if err := bs.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := New(ds.NewMapDatastore(), nil)
+	if _, err := d.Add(denied.Cid().String()); err != nil {
+		t.Fatal(err)
+	}
+	wrapped := Blockstore(bs, d, "test")
+
+	if has, _ := wrapped.Has(denied.Cid()); has {
+		t.Fatal("expected the denied block to be hidden")
+	}
+	if _, err := wrapped.Get(denied.Cid()); err != bstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := wrapped.Get(allowed.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,182 @@
+package diskio
+
+import (
+	"context"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+type blockstore struct {
+	bstore.Blockstore
+	c *Counter
+}
+
+// Blockstore wraps bs so that its operations are counted by c.
+func Blockstore(bs bstore.Blockstore, c *Counter) bstore.Blockstore {
+	if c == nil {
+		return bs
+	}
+	return &blockstore{Blockstore: bs, c: c}
+}
+
+func (bs *blockstore) Has(k cid.Cid) (bool, error) {
+	bs.c.read(0)
+	return bs.Blockstore.Has(k)
+}
+
+func (bs *blockstore) Get(k cid.Cid) (blocks.Block, error) {
+	b, err := bs.Blockstore.Get(k)
+	if err != nil {
+		bs.c.read(0)
+		return nil, err
+	}
+	bs.c.read(len(b.RawData()))
+	return b, nil
+}
+
+func (bs *blockstore) GetSize(k cid.Cid) (int, error) {
+	bs.c.read(0)
+	return bs.Blockstore.GetSize(k)
+}
+
+func (bs *blockstore) Put(b blocks.Block) error {
+	bs.c.write(len(b.RawData()))
+	return bs.Blockstore.Put(b)
+}
+
+func (bs *blockstore) PutMany(bls []blocks.Block) error {
+	for _, b := range bls {
+		bs.c.write(len(b.RawData()))
+	}
+	return bs.Blockstore.PutMany(bls)
+}
+
+func (bs *blockstore) DeleteBlock(k cid.Cid) error {
+	bs.c.delete()
+	return bs.Blockstore.DeleteBlock(k)
+}
+
+type gcBlockstore struct {
+	bstore.Blockstore
+	bstore.GCLocker
+}
+
+// GCBlockstore wraps bs so that its operations are counted by c.
+func GCBlockstore(bs bstore.GCBlockstore, c *Counter) bstore.GCBlockstore {
+	if c == nil {
+		return bs
+	}
+	return &gcBlockstore{Blockstore: Blockstore(bs, c), GCLocker: bs}
+}
+
+type datastore struct {
+	ds.Datastore
+	c *Counter
+}
+
+// Datastore wraps d so that its operations, but the queries, are counted by
+// c. The wrapper hides the optional interfaces of d, such as batching.
+func Datastore(d ds.Datastore, c *Counter) ds.Datastore {
+	if c == nil {
+		return d
+	}
+	return &datastore{Datastore: d, c: c}
+}
+
+func (d *datastore) Get(k ds.Key) ([]byte, error) {
+	v, err := d.Datastore.Get(k)
+	d.c.read(len(v))
+	return v, err
+}
+
+func (d *datastore) Has(k ds.Key) (bool, error) {
+	d.c.read(0)
+	return d.Datastore.Has(k)
+}
+
+func (d *datastore) Put(k ds.Key, v []byte) error {
+	d.c.write(len(v))
+	return d.Datastore.Put(k, v)
+}
+
+func (d *datastore) Delete(k ds.Key) error {
+	d.c.delete()
+	return d.Datastore.Delete(k)
+}
+
+type blockService struct {
+	bserv.BlockService
+	a *Accountant
+}
+
+// BlockService wraps s so that the blocks read from its blockstore within a
+// context set up with WithSubsystem are counted for that subsystem. The
+// other blocks, fetched from the network, are counted as writes of the
+// exchange.
+func BlockService(s bserv.BlockService, a *Accountant) bserv.BlockService {
+	if a == nil {
+		return s
+	}
+	return &blockService{BlockService: s, a: a}
+}
+
+// local returns the blockstore reading the blocks of the subsystem of ctx,
+// or nil when ctx has none.
+func (s *blockService) local(ctx context.Context) bstore.Blockstore {
+	subsystem := subsystemFrom(ctx)
+	if subsystem == "" {
+		return nil
+	}
+	return Blockstore(s.BlockService.Blockstore(), s.a.Subsystem(subsystem))
+}
+
+func (s *blockService) GetBlock(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	local := s.local(ctx)
+	if local == nil {
+		return s.BlockService.GetBlock(ctx, k)
+	}
+	if b, err := local.Get(k); err == nil {
+		return b, nil
+	}
+	return s.BlockService.GetBlock(ctx, k)
+}
+
+func (s *blockService) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Block {
+	local := s.local(ctx)
+	if local == nil {
+		return s.BlockService.GetBlocks(ctx, ks)
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		var missing []cid.Cid
+		for _, k := range ks {
+			b, err := local.Get(k)
+			if err != nil {
+				missing = append(missing, k)
+				continue
+			}
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if len(missing) == 0 {
+			return
+		}
+		for b := range s.BlockService.GetBlocks(ctx, missing) {
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,200 @@
+// Package diskio accounts the reads and writes of the blockstore and the
+// datastore to the subsystems of the node, so that operators can tell
+// whether the peers leeching from the node or its own pinning keep its disks
+// busy.
+//
+// The subsystems get their own wrapped blockstore or datastore, counting
+// the operations going through it. The subsystems sharing the blockservice of
+// the node, like the gateway, set their name in the context of their
+// requests with WithSubsystem instead. The counts are exported to Prometheus
+// too.
+//
+// The operations are counted as seen by the blockstore: the reads answered
+// by its caches are included.
+package diskio
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	prometheus "gx/ipfs/QmYYv3QFnfQbiwmi1tpkgKF8o4xFnZoBrvpupTiGJwL9nH/client_golang/prometheus"
+)
+
+// The subsystems accounted by the node.
+const (
+	// Total counts all the blockstore operations, including the ones of
+	// the other subsystems and of the API.
+	Total      = "total"
+	Bitswap    = "bitswap"
+	Gateway    = "gateway"
+	Pinner     = "pinner"
+	GC         = "gc"
+	Reprovider = "reprovider"
+)
+
+var (
+	opsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "diskio",
+		Name:      "ops_total",
+		Help:      "Blockstore and datastore operations by subsystem.",
+	}, []string{"subsystem", "op"})
+
+	bytesMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "diskio",
+		Name:      "bytes_total",
+		Help:      "Bytes read from and written to the blockstore and the datastore by subsystem.",
+	}, []string{"subsystem", "op"})
+)
+
+func init() {
+	prometheus.MustRegister(opsMetric, bytesMetric)
+}
+
+// Stats are the operations of a subsystem.
+type Stats struct {
+	ReadOps    uint64
+	ReadBytes  uint64
+	WriteOps   uint64
+	WriteBytes uint64
+	DeleteOps  uint64
+}
+
+// Counter counts the operations of a subsystem. A nil Counter counts
+// nothing.
+type Counter struct {
+	readOps    uint64
+	readBytes  uint64
+	writeOps   uint64
+	writeBytes uint64
+	deleteOps  uint64
+
+	readOpsMetric    prometheus.Counter
+	readBytesMetric  prometheus.Counter
+	writeOpsMetric   prometheus.Counter
+	writeBytesMetric prometheus.Counter
+	deleteOpsMetric  prometheus.Counter
+}
+
+func newCounter(subsystem string) *Counter {
+	return &Counter{
+		readOpsMetric:    opsMetric.WithLabelValues(subsystem, "read"),
+		readBytesMetric:  bytesMetric.WithLabelValues(subsystem, "read"),
+		writeOpsMetric:   opsMetric.WithLabelValues(subsystem, "write"),
+		writeBytesMetric: bytesMetric.WithLabelValues(subsystem, "write"),
+		deleteOpsMetric:  opsMetric.WithLabelValues(subsystem, "delete"),
+	}
+}
+
+func (c *Counter) read(n int) {
+	if c == nil {
+		return
+	}
+	atomic.AddUint64(&c.readOps, 1)
+	c.readOpsMetric.Inc()
+	if n > 0 {
+		atomic.AddUint64(&c.readBytes, uint64(n))
+		c.readBytesMetric.Add(float64(n))
+	}
+}
+
+func (c *Counter) write(n int) {
+	if c == nil {
+		return
+	}
+	atomic.AddUint64(&c.writeOps, 1)
+	c.writeOpsMetric.Inc()
+	if n > 0 {
+		atomic.AddUint64(&c.writeBytes, uint64(n))
+		c.writeBytesMetric.Add(float64(n))
+	}
+}
+
+func (c *Counter) delete() {
+	if c == nil {
+		return
+	}
+	atomic.AddUint64(&c.deleteOps, 1)
+	c.deleteOpsMetric.Inc()
+}
+
+// Stats returns the operations counted so far.
+func (c *Counter) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	return Stats{
+		ReadOps:    atomic.LoadUint64(&c.readOps),
+		ReadBytes:  atomic.LoadUint64(&c.readBytes),
+		WriteOps:   atomic.LoadUint64(&c.writeOps),
+		WriteBytes: atomic.LoadUint64(&c.writeBytes),
+		DeleteOps:  atomic.LoadUint64(&c.deleteOps),
+	}
+}
+
+// Accountant holds the counters of the subsystems of a node. A nil
+// Accountant counts nothing.
+type Accountant struct {
+	lk       sync.Mutex
+	counters map[string]*Counter
+}
+
+// NewAccountant returns an Accountant without any counter.
+func NewAccountant() *Accountant {
+	return &Accountant{counters: make(map[string]*Counter)}
+}
+
+// Subsystem returns the counter of subsystem, creating it if needed.
+func (a *Accountant) Subsystem(subsystem string) *Counter {
+	if a == nil {
+		return nil
+	}
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	c, ok := a.counters[subsystem]
+	if !ok {
+		c = newCounter(subsystem)
+		a.counters[subsystem] = c
+	}
+	return c
+}
+
+// Subsystems returns the names of the subsystems counted, sorted.
+func (a *Accountant) Subsystems() []string {
+	if a == nil {
+		return nil
+	}
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	names := make([]string, 0, len(a.counters))
+	for name := range a.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats returns the operations of every subsystem.
+func (a *Accountant) Stats() map[string]Stats {
+	out := make(map[string]Stats)
+	for _, name := range a.Subsystems() {
+		out[name] = a.Subsystem(name).Stats()
+	}
+	return out
+}
+
+type subsystemKey struct{}
+
+// WithSubsystem returns a context attributing the blocks read through the
+// blockservice returned by BlockService to subsystem.
+func WithSubsystem(ctx context.Context, subsystem string) context.Context {
+	return context.WithValue(ctx, subsystemKey{}, subsystem)
+}
+
+func subsystemFrom(ctx context.Context) string {
+	s, _ := ctx.Value(subsystemKey{}).(string)
+	return s
+}
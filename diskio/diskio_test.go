@@ -0,0 +1,87 @@
+package diskio
+
+import (
+	"context"
+	"testing"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+func TestBlockstoreCounts(t *testing.T) {
+	a := NewAccountant()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	base := bstore.NewBlockstore(d)
+	bs := Blockstore(base, a.Subsystem(Bitswap))
+
+	b := blocks.NewBlock([]byte("hello disk"))
+	if err := bs.Put(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.Get(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteBlock(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.Get(b.Cid()); err != bstore.ErrNotFound {
+		t.Fatalf("expected the block to be gone, got %v", err)
+	}
+
+	st := a.Stats()[Bitswap]
+	expected := Stats{ReadOps: 2, ReadBytes: 10, WriteOps: 1, WriteBytes: 10, DeleteOps: 1}
+	if st != expected {
+		t.Fatalf("expected %+v, got %+v", expected, st)
+	}
+
+	pins := Datastore(d, a.Subsystem(Pinner))
+	if err := pins.Put(ds.NewKey("/pins"), []byte("root")); err != nil {
+		t.Fatal(err)
+	}
+	if st := a.Subsystem(Pinner).Stats(); st.WriteOps != 1 || st.WriteBytes != 4 {
+		t.Fatalf("unexpected pinner stats %+v", st)
+	}
+
+	// nil counters leave the blockstore untouched
+	if Blockstore(base, nil) != base {
+		t.Fatal("expected the blockstore to be returned as is")
+	}
+}
+
+func TestBlockServiceAttribution(t *testing.T) {
+	a := NewAccountant()
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	s := BlockService(bserv.New(bs, offline.Exchange(bs)), a)
+
+	b := blocks.NewBlock([]byte("served"))
+	if err := s.AddBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetBlock(context.Background(), b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Subsystems()) != 0 {
+		t.Fatalf("expected reads without a subsystem not to be counted, got %v", a.Subsystems())
+	}
+
+	ctx := WithSubsystem(context.Background(), Gateway)
+	if _, err := s.GetBlock(ctx, b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	var got int
+	for range s.GetBlocks(ctx, []cid.Cid{b.Cid()}) {
+		got++
+	}
+	if got != 1 {
+		t.Fatalf("expected 1 block, got %d", got)
+	}
+	if st := a.Subsystem(Gateway).Stats(); st.ReadOps != 2 || st.ReadBytes != 12 {
+		t.Fatalf("unexpected gateway stats %+v", st)
+	}
+}
@@ -0,0 +1,409 @@
+// Package dual runs two DHTs side by side: the WAN DHT, speaking the public
+// IPFS DHT protocol with the peers connected over public addresses, and the
+// LAN DHT, speaking its own protocol with the peers connected over loopback,
+// link-local and private addresses.
+//
+// Each DHT stores the records and the providers it receives in its own
+// datastore and only exchanges with the peers of its addresses, so that the
+// records of the private networks are never served to the public DHT. The
+// queries of the node go to both, the records it publishes to both.
+package dual
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	autonat "github.com/ipfs/go-ipfs/autonat"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	namespace "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/namespace"
+	kb "gx/ipfs/QmWXTuqkFh63jvYxAn1h3MZe6JkHAdvhm5fvQpBzkMQMfE/go-libp2p-kbucket"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	dht "gx/ipfs/QmaXYSwxqJsX3EoGb1ZV2toZ9fXc8hWJPaBW1XAp1h2Tsp/go-libp2p-kad-dht"
+	dhtopts "gx/ipfs/QmaXYSwxqJsX3EoGb1ZV2toZ9fXc8hWJPaBW1XAp1h2Tsp/go-libp2p-kad-dht/opts"
+	record "gx/ipfs/QmdHb9aBELnQKTVhvvA3hsQbRgUAwsWUzBP2vZ6Y5FBYvE/go-libp2p-record"
+	ropts "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing/options"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+var log = logging.Logger("dual")
+
+// LANProtocol is the protocol of the LAN DHT.
+const LANProtocol = protocol.ID("/ipfs/lan/kad/1.0.0")
+
+// The modes of the DHTs. In client mode, a DHT queries the other peers but
+// doesn't answer their queries. In auto mode, the WAN DHT is a server when
+// AutoNAT finds the node publicly reachable, a client otherwise.
+const (
+	ModeServer = "server"
+	ModeClient = "client"
+	ModeAuto   = "auto"
+)
+
+// lanNamespace prefixes the keys of the records of the LAN DHT. The WAN DHT
+// keeps the keys of the single DHT it replaces.
+var lanNamespace = ds.NewKey("/lan")
+
+// reachabilityInterval is the delay between the checks of the reachability
+// in auto mode. A variable so tests can speed it up.
+var reachabilityInterval = time.Minute
+
+// DHT is the pair of the WAN and LAN DHTs, routing through both.
+type DHT struct {
+	WAN *dht.IpfsDHT
+	LAN *dht.IpfsDHT
+
+	wanHost   *filteredHost
+	lanHost   *filteredHost
+	mode      string
+	validator record.Validator
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New starts the WAN and LAN DHTs on h in the given mode. In client mode,
+// both are clients; in auto mode, the WAN DHT is a client until
+// WatchReachability finds the node publicly reachable.
+func New(ctx context.Context, h host.Host, dstore ds.Batching, validator record.Validator, mode string) (*DHT, error) {
+	switch mode {
+	case ModeServer, ModeClient, ModeAuto:
+	default:
+		return nil, fmt.Errorf("invalid DHT mode %q", mode)
+	}
+
+	d := &DHT{
+		wanHost:   newFilteredHost(h, wanFilter, mode == ModeServer),
+		lanHost:   newFilteredHost(h, lanFilter, mode != ModeClient),
+		mode:      mode,
+		validator: validator,
+	}
+	d.ctx, d.cancel = context.WithCancel(ctx)
+
+	var err error
+	d.WAN, err = dht.New(ctx, d.wanHost,
+		dhtopts.Datastore(dstore),
+		dhtopts.Validator(validator),
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.LAN, err = dht.New(ctx, d.lanHost,
+		dhtopts.Datastore(namespace.Wrap(dstore, lanNamespace)),
+		dhtopts.Validator(validator),
+		dhtopts.Protocols(LANProtocol),
+	)
+	if err != nil {
+		d.WAN.Process().Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// WatchReachability makes the WAN DHT a server while a is publicly
+// reachable, in auto mode. It does nothing in the other modes.
+func (d *DHT) WatchReachability(a *autonat.AutoNAT) {
+	if d.mode != ModeAuto || a == nil {
+		return
+	}
+	go func() {
+		t := time.NewTicker(reachabilityInterval)
+		defer t.Stop()
+		for {
+			public := a.Status().Reachability == autonat.ReachabilityPublic
+			if public != d.wanHost.isServer() {
+				log.Infof("node publicly reachable: %t, switching the WAN DHT to server mode: %t", public, public)
+				d.wanHost.setServer(public)
+			}
+			select {
+			case <-t.C:
+			case <-d.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops both DHTs.
+func (d *DHT) Close() error {
+	d.cancel()
+	werr := d.WAN.Process().Close()
+	lerr := d.LAN.Process().Close()
+	if werr != nil {
+		return werr
+	}
+	return lerr
+}
+
+// both calls f on both DHTs concurrently, succeeding when one of them
+// succeeds, the WAN DHT having no peer on a private network.
+func (d *DHT) both(f func(*dht.IpfsDHT) error) error {
+	var wg sync.WaitGroup
+	var werr, lerr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		werr = f(d.WAN)
+	}()
+	go func() {
+		defer wg.Done()
+		lerr = f(d.LAN)
+	}()
+	wg.Wait()
+	if werr == nil || lerr == nil {
+		return nil
+	}
+	return werr
+}
+
+func (d *DHT) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	return d.both(func(r *dht.IpfsDHT) error {
+		return r.Provide(ctx, c, announce)
+	})
+}
+
+// FindProvidersAsync returns the providers found by both DHTs, each once.
+func (d *DHT) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan pstore.PeerInfo {
+	ctx, cancel := context.WithCancel(ctx)
+	wan := d.WAN.FindProvidersAsync(ctx, c, count)
+	lan := d.LAN.FindProvidersAsync(ctx, c, count)
+	out := make(chan pstore.PeerInfo)
+
+	go func() {
+		defer close(out)
+		defer cancel()
+		seen := make(map[peer.ID]struct{})
+		for wan != nil || lan != nil {
+			var pi pstore.PeerInfo
+			var ok bool
+			select {
+			case pi, ok = <-wan:
+				if !ok {
+					wan = nil
+					continue
+				}
+			case pi, ok = <-lan:
+				if !ok {
+					lan = nil
+					continue
+				}
+			}
+			if _, ok := seen[pi.ID]; ok {
+				continue
+			}
+			seen[pi.ID] = struct{}{}
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				return
+			}
+			if count > 0 && len(seen) >= count {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FindPeer returns the first address information found by one of the DHTs.
+func (d *DHT) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		pi  pstore.PeerInfo
+		err error
+	}
+	results := make(chan result, 2)
+	for _, r := range []*dht.IpfsDHT{d.WAN, d.LAN} {
+		go func(r *dht.IpfsDHT) {
+			pi, err := r.FindPeer(ctx, p)
+			results <- result{pi, err}
+		}(r)
+	}
+
+	var err error
+	for i := 0; i < 2; i++ {
+		res := <-results
+		if res.err == nil {
+			return res.pi, nil
+		}
+		if err == nil {
+			err = res.err
+		}
+	}
+	return pstore.PeerInfo{}, err
+}
+
+func (d *DHT) PutValue(ctx context.Context, key string, val []byte, opts ...ropts.Option) error {
+	return d.both(func(r *dht.IpfsDHT) error {
+		return r.PutValue(ctx, key, val, opts...)
+	})
+}
+
+// GetValue returns the best of the records found by the DHTs.
+func (d *DHT) GetValue(ctx context.Context, key string, opts ...ropts.Option) ([]byte, error) {
+	var lk sync.Mutex
+	var vals [][]byte
+	err := d.both(func(r *dht.IpfsDHT) error {
+		val, err := r.GetValue(ctx, key, opts...)
+		if err != nil {
+			return err
+		}
+		lk.Lock()
+		vals = append(vals, val)
+		lk.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 1 {
+		return vals[0], nil
+	}
+	i, err := d.validator.Select(key, vals)
+	if err != nil {
+		return nil, err
+	}
+	return vals[i], nil
+}
+
+// GetClosestPeers returns the peers closest to key found by both DHTs, each
+// once.
+func (d *DHT) GetClosestPeers(ctx context.Context, key string) (<-chan peer.ID, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	wan, werr := d.WAN.GetClosestPeers(ctx, key)
+	lan, lerr := d.LAN.GetClosestPeers(ctx, key)
+	if werr != nil && lerr != nil {
+		cancel()
+		return nil, werr
+	}
+	out := make(chan peer.ID)
+
+	go func() {
+		defer close(out)
+		defer cancel()
+		seen := make(map[peer.ID]struct{})
+		for wan != nil || lan != nil {
+			var p peer.ID
+			var ok bool
+			select {
+			case p, ok = <-wan:
+				if !ok {
+					wan = nil
+					continue
+				}
+			case p, ok = <-lan:
+				if !ok {
+					lan = nil
+					continue
+				}
+			}
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (d *DHT) Bootstrap(ctx context.Context) error {
+	return d.both(func(r *dht.IpfsDHT) error {
+		return r.Bootstrap(ctx)
+	})
+}
+
+// PeerStats is a peer of the routing table of a DHT.
+type PeerStats struct {
+	ID string
+	// Addr is the remote address of the connection to the peer.
+	Addr         string
+	AgentVersion string `json:",omitempty"`
+}
+
+// BucketStats is the peers of the routing table sharing the first CPL bits
+// of their key with the node.
+type BucketStats struct {
+	CPL   int
+	Peers []PeerStats
+}
+
+// Stats is the mode and the routing table of a DHT.
+type Stats struct {
+	Name     string
+	Protocol protocol.ID
+	// Mode is server or client, Auto telling whether it follows the
+	// reachability of the node.
+	Mode    string
+	Auto    bool `json:",omitempty"`
+	Peers   int
+	Buckets []BucketStats
+}
+
+// WANStats returns the routing table of the WAN DHT.
+func (d *DHT) WANStats() Stats {
+	s := tableStats(d.wanHost, dht.ProtocolDHT)
+	s.Name = "wan"
+	s.Auto = d.mode == ModeAuto
+	return s
+}
+
+// LANStats returns the routing table of the LAN DHT.
+func (d *DHT) LANStats() Stats {
+	s := tableStats(d.lanHost, LANProtocol)
+	s.Name = "lan"
+	return s
+}
+
+// tableStats returns the peers the DHT of h keeps in its routing table: the
+// DHT servers connected over the addresses of the filter, by common prefix
+// length.
+func tableStats(h *filteredHost, proto protocol.ID) Stats {
+	s := Stats{Protocol: proto, Mode: ModeClient}
+	if h.isServer() {
+		s.Mode = ModeServer
+	}
+
+	network := h.Host.Network()
+	ps := h.Host.Peerstore()
+	self := kb.ConvertPeerID(h.ID())
+	buckets := make(map[int][]PeerStats)
+	for _, p := range network.Peers() {
+		if protos, err := ps.SupportsProtocols(p, string(proto)); err != nil || len(protos) == 0 {
+			continue
+		}
+		for _, c := range network.ConnsToPeer(p) {
+			if !h.filter(c.RemoteMultiaddr()) {
+				continue
+			}
+			st := PeerStats{ID: p.Pretty(), Addr: c.RemoteMultiaddr().String()}
+			if v, err := ps.Get(p, "AgentVersion"); err == nil {
+				st.AgentVersion, _ = v.(string)
+			}
+			cpl := kb.CommonPrefixLen(self, kb.ConvertPeerID(p))
+			buckets[cpl] = append(buckets[cpl], st)
+			s.Peers++
+			break
+		}
+	}
+
+	for cpl, peers := range buckets {
+		sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+		s.Buckets = append(s.Buckets, BucketStats{CPL: cpl, Peers: peers})
+	}
+	sort.Slice(s.Buckets, func(i, j int) bool { return s.Buckets[i].CPL < s.Buckets[j].CPL })
+	return s
+}
@@ -0,0 +1,127 @@
+package dual
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tu "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
+	mocknet "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/net/mock"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+func TestIsLANAddr(t *testing.T) {
+	for addr, lan := range map[string]bool{
+		"/ip4/127.0.0.1/tcp/4001":              true,
+		"/ip4/10.1.2.3/tcp/4001":               true,
+		"/ip4/172.20.0.5/udp/4001/quic":        true,
+		"/ip4/192.168.1.10/tcp/4001":           true,
+		"/ip4/169.254.3.4/tcp/4001":            true,
+		"/ip6/::1/tcp/4001":                    true,
+		"/ip6/fd00::1/tcp/4001":                true,
+		"/ip6/fe80::1/tcp/4001":                true,
+		"/ip4/172.32.0.5/tcp/4001":             false,
+		"/ip4/8.8.8.8/tcp/4001":                false,
+		"/ip6/2001:db8::1/tcp/4001":            false,
+		"/dns4/localhost/tcp/4001":             false,
+		"/ip4/10.1.2.3/tcp/4001/p2p-circuit":   false,
+		"/ip4/104.131.131.82/tcp/4001/ws":      false,
+		"/ip4/192.168.1.10/tcp/4001/ws":        true,
+		"/ip4/100.64.1.1/tcp/4001":             true,
+		"/ip4/100.128.1.1/tcp/4001":            false,
+		"/ip6/2604:a880:1:20::203:d001/tcp/80": false,
+	} {
+		if got := IsLANAddr(ma.StringCast(addr)); got != lan {
+			t.Errorf("%s: expected LAN=%t, got %t", addr, lan, got)
+		}
+	}
+}
+
+func hasProtocol(protos []string, p string) bool {
+	for _, s := range protos {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFilteredHostMode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := mn.Hosts()[0]
+
+	fh := newFilteredHost(h, lanFilter, false)
+	fh.SetStreamHandler(LANProtocol, func(s inet.Stream) { s.Close() })
+	if hasProtocol(h.Mux().Protocols(), string(LANProtocol)) {
+		t.Fatal("expected the protocol not to be registered in client mode")
+	}
+
+	fh.setServer(true)
+	if !hasProtocol(h.Mux().Protocols(), string(LANProtocol)) {
+		t.Fatal("expected the protocol to be registered in server mode")
+	}
+
+	fh.setServer(false)
+	if hasProtocol(h.Mux().Protocols(), string(LANProtocol)) {
+		t.Fatal("expected the protocol to be removed in client mode")
+	}
+}
+
+func TestFilteredNetworkNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+	addPeer := func(addr string) host.Host {
+		sk, _, err := tu.RandTestKeyPair(512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := mn.AddPeer(sk, ma.StringCast(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	h1 := addPeer("/ip4/192.168.1.1/tcp/4001")
+	h2 := addPeer("/ip4/192.168.1.2/tcp/4001")
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	lan := newFilteredHost(h1, lanFilter, true)
+	wan := newFilteredHost(h1, wanFilter, true)
+	lanConns := make(chan inet.Conn, 1)
+	wanConns := make(chan inet.Conn, 1)
+	lan.Network().Notify(&inet.NotifyBundle{ConnectedF: func(_ inet.Network, c inet.Conn) { lanConns <- c }})
+	wan.Network().Notify(&inet.NotifyBundle{ConnectedF: func(_ inet.Network, c inet.Conn) { wanConns <- c }})
+
+	if _, err := mn.ConnectPeers(h1.ID(), h2.ID()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-lanConns:
+		if c.RemotePeer() != h2.ID() {
+			t.Fatalf("expected a connection to %s, got %s", h2.ID(), c.RemotePeer())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the LAN DHT to be notified of the connection")
+	}
+	select {
+	case <-wanConns:
+		t.Fatal("expected the WAN DHT not to be notified of a private connection")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if !lan.accepts(h2.ID()) || wan.accepts(h2.ID()) {
+		t.Fatal("expected only the LAN DHT to accept the peer")
+	}
+}
@@ -0,0 +1,241 @@
+package dual
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	gater "github.com/ipfs/go-ipfs/gater"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	circuit "gx/ipfs/QmWX6RySJ3yAYmfjLSw1LtRZnDh5oVeA9kM3scNQJkysqa/go-libp2p-circuit"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+var errFiltered = errors.New("peer not reachable over the addresses of this DHT")
+
+var lanSubnets = parseSubnets(
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
+func parseSubnets(cidrs ...string) []*net.IPNet {
+	out := make([]*net.IPNet, 0, len(cidrs))
+	for _, s := range cidrs {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// IsLANAddr tells whether addr is a loopback, link-local or private
+// address. Relayed addresses and the addresses without IP aren't.
+func IsLANAddr(addr ma.Multiaddr) bool {
+	if _, err := addr.ValueForProtocol(circuit.P_CIRCUIT); err == nil {
+		return false
+	}
+	ip := gater.AddrIP(addr)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, n := range lanSubnets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrFilter tells whether an address belongs to a DHT.
+type addrFilter func(ma.Multiaddr) bool
+
+func lanFilter(addr ma.Multiaddr) bool { return IsLANAddr(addr) }
+
+func wanFilter(addr ma.Multiaddr) bool { return !IsLANAddr(addr) }
+
+// filteredHost is the host given to one of the DHTs, restricting it to the
+// peers connected over the addresses of filter: the other connections
+// aren't notified, their streams are reset and the streams to peers only
+// reachable over other addresses are refused. It also switches the DHT
+// between the server and client modes, by registering its protocols or
+// not.
+type filteredHost struct {
+	host.Host
+	filter addrFilter
+
+	lk       sync.Mutex
+	server   bool
+	handlers map[protocol.ID]inet.StreamHandler
+	notifees map[inet.Notifiee]inet.Notifiee
+}
+
+func newFilteredHost(h host.Host, filter addrFilter, server bool) *filteredHost {
+	return &filteredHost{
+		Host:     h,
+		filter:   filter,
+		server:   server,
+		handlers: make(map[protocol.ID]inet.StreamHandler),
+		notifees: make(map[inet.Notifiee]inet.Notifiee),
+	}
+}
+
+// accepts tells whether the DHT can talk to p: when p is connected, over
+// one of the addresses of the filter, otherwise when one of its known
+// addresses is.
+func (h *filteredHost) accepts(p peer.ID) bool {
+	conns := h.Host.Network().ConnsToPeer(p)
+	for _, c := range conns {
+		if h.filter(c.RemoteMultiaddr()) {
+			return true
+		}
+	}
+	if len(conns) > 0 {
+		return false
+	}
+	for _, a := range h.Host.Peerstore().Addrs(p) {
+		if h.filter(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *filteredHost) Connect(ctx context.Context, pi pstore.PeerInfo) error {
+	addrs := make([]ma.Multiaddr, 0, len(pi.Addrs))
+	for _, a := range pi.Addrs {
+		if h.filter(a) {
+			addrs = append(addrs, a)
+		}
+	}
+	pi.Addrs = addrs
+	if len(addrs) == 0 && !h.accepts(pi.ID) {
+		return errFiltered
+	}
+	if err := h.Host.Connect(ctx, pi); err != nil {
+		return err
+	}
+	if !h.accepts(pi.ID) {
+		return errFiltered
+	}
+	return nil
+}
+
+func (h *filteredHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	if !h.accepts(p) {
+		return nil, errFiltered
+	}
+	return h.Host.NewStream(ctx, p, pids...)
+}
+
+func (h *filteredHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	h.handlers[pid] = handler
+	if h.server {
+		h.Host.SetStreamHandler(pid, h.wrap(handler))
+	}
+}
+
+func (h *filteredHost) RemoveStreamHandler(pid protocol.ID) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	delete(h.handlers, pid)
+	h.Host.RemoveStreamHandler(pid)
+}
+
+// wrap resets the inbound streams of the connections of other addresses.
+func (h *filteredHost) wrap(handler inet.StreamHandler) inet.StreamHandler {
+	return func(s inet.Stream) {
+		if !h.filter(s.Conn().RemoteMultiaddr()) {
+			s.Reset()
+			return
+		}
+		handler(s)
+	}
+}
+
+// setServer registers the protocols of the DHT in server mode, and removes
+// them in client mode.
+func (h *filteredHost) setServer(server bool) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	if h.server == server {
+		return
+	}
+	h.server = server
+	for pid, handler := range h.handlers {
+		if server {
+			h.Host.SetStreamHandler(pid, h.wrap(handler))
+		} else {
+			h.Host.RemoveStreamHandler(pid)
+		}
+	}
+}
+
+func (h *filteredHost) isServer() bool {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return h.server
+}
+
+func (h *filteredHost) Network() inet.Network {
+	return &filteredNetwork{Network: h.Host.Network(), host: h}
+}
+
+// filteredNetwork notifies the connections of the addresses of the filter
+// only.
+type filteredNetwork struct {
+	inet.Network
+	host *filteredHost
+}
+
+func (n *filteredNetwork) Notify(f inet.Notifiee) {
+	h := n.host
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	if _, ok := h.notifees[f]; ok {
+		return
+	}
+	nf := &inet.NotifyBundle{
+		ListenF:      f.Listen,
+		ListenCloseF: f.ListenClose,
+		ConnectedF: func(_ inet.Network, c inet.Conn) {
+			if h.filter(c.RemoteMultiaddr()) {
+				f.Connected(n, c)
+			}
+		},
+		DisconnectedF: func(_ inet.Network, c inet.Conn) {
+			if h.filter(c.RemoteMultiaddr()) {
+				f.Disconnected(n, c)
+			}
+		},
+		OpenedStreamF: func(_ inet.Network, s inet.Stream) { f.OpenedStream(n, s) },
+		ClosedStreamF: func(_ inet.Network, s inet.Stream) { f.ClosedStream(n, s) },
+	}
+	h.notifees[f] = nf
+	n.Network.Notify(nf)
+}
+
+func (n *filteredNetwork) StopNotify(f inet.Notifiee) {
+	h := n.host
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	if nf, ok := h.notifees[f]; ok {
+		n.Network.StopNotify(nf)
+		delete(h.notifees, f)
+	}
+}
@@ -0,0 +1,151 @@
+// Package httpfetch retrieves blocks from trustless HTTP gateways.
+//
+// The gateways serve the raw blocks, requested with ?format=raw, and the
+// node verifies them against their CID before using them, so the gateways
+// need not be trusted. The Exchange runs these retrievals alongside bitswap,
+// for nodes which the peers having the content can't reach, such as the
+// nodes behind hostile NATs.
+package httpfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+)
+
+var log = logging.Logger("httpfetch")
+
+// Defaults of the settings left unset in the config.
+const (
+	DefaultDelay       = time.Second
+	DefaultTimeout     = 30 * time.Second
+	DefaultConcurrency = 8
+)
+
+// maxBlockSize is the largest block accepted from a gateway.
+var maxBlockSize int64 = 4 << 20
+
+// rawMediaType is the media type of the raw blocks served by the gateways.
+const rawMediaType = "application/vnd.ipld.raw"
+
+// ErrNotFound is returned by Fetch when no gateway had the block.
+var ErrNotFound = errors.New("httpfetch: block not found on the gateways")
+
+// Stats counts the retrievals of a Client.
+type Stats struct {
+	// Fetched is the number of blocks retrieved.
+	Fetched uint64
+	// Failed is the number of blocks none of the gateways returned.
+	Failed uint64
+	// Invalid is the number of blocks returned that didn't match their CID.
+	Invalid uint64
+}
+
+// Client retrieves blocks from trustless gateways.
+type Client struct {
+	gateways []string
+	http     *http.Client
+
+	// next is the index of the gateway tried first by the next fetch, so
+	// that the requests are spread over the gateways.
+	next uint32
+
+	fetched uint64
+	failed  uint64
+	invalid uint64
+}
+
+// NewClient returns a Client of the gateways, given by their base URLs like
+// https://ipfs.io. The requests taking longer than timeout are aborted.
+func NewClient(gateways []string, timeout time.Duration) (*Client, error) {
+	if len(gateways) == 0 {
+		return nil, errors.New("httpfetch: no gateway")
+	}
+	c := &Client{
+		http: &http.Client{Timeout: timeout},
+	}
+	for _, g := range gateways {
+		if !strings.HasPrefix(g, "http://") && !strings.HasPrefix(g, "https://") {
+			return nil, fmt.Errorf("httpfetch: invalid gateway URL %q", g)
+		}
+		c.gateways = append(c.gateways, strings.TrimSuffix(g, "/"))
+	}
+	return c, nil
+}
+
+// Stats returns the retrieval counters.
+func (c *Client) Stats() Stats {
+	return Stats{
+		Fetched: atomic.LoadUint64(&c.fetched),
+		Failed:  atomic.LoadUint64(&c.failed),
+		Invalid: atomic.LoadUint64(&c.invalid),
+	}
+}
+
+// Fetch retrieves the block k from the first gateway returning it.
+func (c *Client) Fetch(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	first := int(atomic.AddUint32(&c.next, 1))
+	for i := range c.gateways {
+		g := c.gateways[(first+i)%len(c.gateways)]
+		b, err := c.fetchFrom(ctx, g, k)
+		if err == nil {
+			atomic.AddUint64(&c.fetched, 1)
+			return b, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Debugf("fetching %s from %s: %s", k, g, err)
+	}
+	atomic.AddUint64(&c.failed, 1)
+	return nil, ErrNotFound
+}
+
+func (c *Client) fetchFrom(ctx context.Context, gateway string, k cid.Cid) (blocks.Block, error) {
+	req, err := http.NewRequest("GET", gateway+"/ipfs/"+k.String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", rawMediaType)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBlockSize {
+		return nil, fmt.Errorf("block larger than %d bytes", maxBlockSize)
+	}
+
+	// the gateway is not trusted, the data must hash to the CID
+	sum, err := k.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(k) {
+		atomic.AddUint64(&c.invalid, 1)
+		return nil, fmt.Errorf("the data returned does not match the CID")
+	}
+	return blocks.NewBlockWithCid(data, k)
+}
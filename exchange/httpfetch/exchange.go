@@ -0,0 +1,238 @@
+package httpfetch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	exchange "gx/ipfs/QmR1nncPsZR14A4hWr39mq8Lm7BGgS68bHVT9nop8NpWEM/go-ipfs-exchange-interface"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+)
+
+// Exchange retrieves the blocks from the gateways of a Client alongside an
+// exchange, usually bitswap. The blocks missing from the exchange after a
+// delay are requested from the gateways too, and the first block received
+// from either is used. The blocks retrieved over HTTP are handed to the
+// exchange with HasBlock, which stores them and serves its pending requests.
+type Exchange struct {
+	exchange.Interface
+
+	client *Client
+	delay  time.Duration
+	sem    chan struct{}
+}
+
+// NewExchange returns inner retrieving the blocks from the gateways of client
+// too, once they are missing for delay. At most concurrency blocks are
+// retrieved over HTTP at once.
+func NewExchange(inner exchange.Interface, client *Client, delay time.Duration, concurrency int) *Exchange {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	return &Exchange{
+		Interface: inner,
+		client:    client,
+		delay:     delay,
+		sem:       make(chan struct{}, concurrency),
+	}
+}
+
+// Client returns the client of the gateways.
+func (e *Exchange) Client() *Client {
+	return e.client
+}
+
+// GetBlock retrieves the block k from the exchange or the gateways.
+func (e *Exchange) GetBlock(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	return e.fetcher(e.Interface).GetBlock(ctx, k)
+}
+
+// GetBlocks retrieves the blocks ks from the exchange or the gateways.
+func (e *Exchange) GetBlocks(ctx context.Context, ks []cid.Cid) (<-chan blocks.Block, error) {
+	return e.fetcher(e.Interface).GetBlocks(ctx, ks)
+}
+
+type sessionExchange interface {
+	NewSession(context.Context) exchange.Fetcher
+}
+
+// NewSession returns a session of the exchange, retrieving the blocks from
+// the gateways too, when the exchange supports sessions.
+func (e *Exchange) NewSession(ctx context.Context) exchange.Fetcher {
+	if se, ok := e.Interface.(sessionExchange); ok {
+		return e.fetcher(se.NewSession(ctx))
+	}
+	return e
+}
+
+func (e *Exchange) fetcher(inner exchange.Fetcher) *fetcher {
+	return &fetcher{inner: inner, e: e}
+}
+
+// wait waits for the delay after which the blocks are requested from the
+// gateways.
+func (e *Exchange) wait(ctx context.Context) error {
+	select {
+	case <-time.After(e.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetchHTTP retrieves k from the gateways and hands it to the exchange.
+func (e *Exchange) fetchHTTP(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	select {
+	case e.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-e.sem }()
+
+	b, err := e.client.Fetch(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Interface.HasBlock(b); err != nil {
+		log.Errorf("storing the block %s retrieved over HTTP: %s", k, err)
+	}
+	return b, nil
+}
+
+// fetcher merges the blocks of an exchange, or a session of it, with the
+// blocks retrieved over HTTP.
+type fetcher struct {
+	inner exchange.Fetcher
+	e     *Exchange
+}
+
+type result struct {
+	b    blocks.Block
+	err  error
+	http bool
+}
+
+func (f *fetcher) GetBlock(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, 2)
+	go func() {
+		b, err := f.inner.GetBlock(ctx, k)
+		results <- result{b: b, err: err}
+	}()
+	go func() {
+		if err := f.e.wait(ctx); err != nil {
+			results <- result{err: err, http: true}
+			return
+		}
+		b, err := f.e.fetchHTTP(ctx, k)
+		results <- result{b: b, err: err, http: true}
+	}()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err == nil {
+			return r.b, nil
+		}
+		// report the error of the exchange rather than the one of HTTP
+		if err == nil || !r.http {
+			err = r.err
+		}
+	}
+	return nil, err
+}
+
+func (f *fetcher) GetBlocks(ctx context.Context, ks []cid.Cid) (<-chan blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	fromExchange, err := f.inner.GetBlocks(ctx, ks)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var lk sync.Mutex
+	remaining := cid.NewSet()
+	for _, k := range ks {
+		remaining.Add(k)
+	}
+	isRemaining := func(k cid.Cid) bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return remaining.Has(k)
+	}
+
+	fromHTTP := make(chan blocks.Block)
+	go func() {
+		defer close(fromHTTP)
+		if err := f.e.wait(ctx); err != nil {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, k := range ks {
+			if !isRemaining(k) {
+				continue
+			}
+			wg.Add(1)
+			go func(k cid.Cid) {
+				defer wg.Done()
+				b, err := f.e.fetchHTTP(ctx, k)
+				if err != nil {
+					return
+				}
+				select {
+				case fromHTTP <- b:
+				case <-ctx.Done():
+				}
+			}(k)
+		}
+		wg.Wait()
+	}()
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		for fromExchange != nil || fromHTTP != nil {
+			var b blocks.Block
+			var ok bool
+			select {
+			case b, ok = <-fromExchange:
+				if !ok {
+					fromExchange = nil
+					continue
+				}
+			case b, ok = <-fromHTTP:
+				if !ok {
+					fromHTTP = nil
+					continue
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			lk.Lock()
+			wanted := remaining.Has(b.Cid())
+			remaining.Remove(b.Cid())
+			left := remaining.Len()
+			lk.Unlock()
+			if !wanted {
+				continue
+			}
+
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+			if left == 0 {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package httpfetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// gateway serves the blocks by CID, replacing their data with the one of
+// forge when set.
+func gateway(t *testing.T, bls []blocks.Block, forge []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "raw" {
+			t.Errorf("expected a raw block request, got %s", r.URL)
+		}
+		for _, b := range bls {
+			if strings.TrimPrefix(r.URL.Path, "/ipfs/") == b.Cid().String() {
+				w.Header().Set("Content-Type", rawMediaType)
+				if forge != nil {
+					w.Write(forge)
+				} else {
+					w.Write(b.RawData())
+				}
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+}
+
+func TestClientVerifies(t *testing.T) {
+	b := blocks.NewBlock([]byte("block"))
+	good := gateway(t, []blocks.Block{b}, nil)
+	defer good.Close()
+	bad := gateway(t, []blocks.Block{b}, []byte("forged"))
+	defer bad.Close()
+
+	c, err := NewClient([]string{bad.URL, good.URL}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// whichever gateway is tried first, the forged data is rejected
+	for i := 0; i < 2; i++ {
+		got, err := c.Fetch(context.Background(), b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.RawData()) != "block" {
+			t.Fatalf("unexpected block data %q", got.RawData())
+		}
+	}
+	if st := c.Stats(); st.Fetched != 2 || st.Invalid != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+
+	missing := blocks.NewBlock([]byte("missing"))
+	if _, err := c.Fetch(context.Background(), missing.Cid()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	local := blocks.NewBlock([]byte("local"))
+	remote1 := blocks.NewBlock([]byte("remote 1"))
+	remote2 := blocks.NewBlock([]byte("remote 2"))
+
+	gw := gateway(t, []blocks.Block{remote1, remote2}, nil)
+	defer gw.Close()
+	c, err := NewClient([]string{gw.URL}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := bs.Put(local); err != nil {
+		t.Fatal(err)
+	}
+	ex := NewExchange(offline.Exchange(bs), c, 0, 2)
+
+	b, err := ex.GetBlock(ctx, remote1.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Cid().Equals(remote1.Cid()) {
+		t.Fatalf("got block %s, expected %s", b.Cid(), remote1.Cid())
+	}
+	if has, _ := bs.Has(remote1.Cid()); !has {
+		t.Fatal("the block retrieved over HTTP was not stored")
+	}
+
+	ch, err := ex.GetBlocks(ctx, []cid.Cid{local.Cid(), remote2.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for b := range ch {
+		got[b.Cid().String()] = true
+	}
+	if len(got) != 2 || !got[local.Cid().String()] || !got[remote2.Cid().String()] {
+		t.Fatalf("unexpected blocks %v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package reprovide
+
+import (
+	"context"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+)
+
+type requestKey struct{}
+
+// WithRequest marks ctx as the context of a request of a user of the node,
+// through the gateway or the API. The blocks read within it are provided on
+// demand.
+func WithRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestKey{}, true)
+}
+
+func isRequest(ctx context.Context) bool {
+	v, _ := ctx.Value(requestKey{}).(bool)
+	return v
+}
+
+type blockService struct {
+	bserv.BlockService
+	od *OnDemand
+}
+
+// OnDemandBlockService wraps s so that the blocks read from it within a
+// context set up with WithRequest are requested from od.
+func OnDemandBlockService(s bserv.BlockService, od *OnDemand) bserv.BlockService {
+	if od == nil {
+		return s
+	}
+	return &blockService{BlockService: s, od: od}
+}
+
+func (s *blockService) GetBlock(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	b, err := s.BlockService.GetBlock(ctx, k)
+	if err == nil && isRequest(ctx) {
+		s.od.Request(b.Cid())
+	}
+	return b, err
+}
+
+func (s *blockService) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Block {
+	in := s.BlockService.GetBlocks(ctx, ks)
+	if !isRequest(ctx) {
+		return in
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for b := range in {
+			s.od.Request(b.Cid())
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,257 @@
+package reprovide
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
+)
+
+// DefaultOnDemandExpiry is how long a CID is reprovided after it was last
+// requested, when Reprovider.OnDemandExpiry is unset.
+const DefaultOnDemandExpiry = 7 * 24 * time.Hour
+
+var (
+	onDemandQueuePrefix     = ds.NewKey("/local/provider/queue")
+	onDemandRequestedPrefix = ds.NewKey("/local/provider/requested")
+)
+
+var (
+	// onDemandRefresh is how often the time a CID was last requested is
+	// written, at most.
+	onDemandRefresh = time.Hour
+
+	// onDemandProvideTimeout bounds the time spent providing a CID.
+	onDemandProvideTimeout = time.Minute
+
+	// onDemandRetryDelay is the time waited after a failed provide before
+	// providing the next CID.
+	onDemandRetryDelay = 30 * time.Second
+)
+
+// OnDemand provides the CIDs when they are first requested, instead of when
+// their blocks are added. The CIDs requested are queued in the datastore and
+// provided one after the other by Run, and the time they were last requested
+// is recorded so that KeyProvider only reprovides the content that is
+// actually used.
+type OnDemand struct {
+	ds     ds.Datastore
+	rsys   routing.ContentRouting
+	queue  *Queue
+	expiry time.Duration
+
+	lk      sync.Mutex
+	pending map[cid.Cid]struct{}
+}
+
+// NewOnDemand returns an OnDemand keeping its queue and the times the CIDs
+// were requested in d, and providing them through rsys. The CIDs not
+// requested for expiry are no longer reprovided.
+func NewOnDemand(d ds.Datastore, rsys routing.ContentRouting, expiry time.Duration) (*OnDemand, error) {
+	q, err := NewQueue(d, onDemandQueuePrefix)
+	if err != nil {
+		return nil, err
+	}
+	if expiry <= 0 {
+		expiry = DefaultOnDemandExpiry
+	}
+	return &OnDemand{
+		ds:      d,
+		rsys:    rsys,
+		queue:   q,
+		expiry:  expiry,
+		pending: make(map[cid.Cid]struct{}),
+	}, nil
+}
+
+func requestedKey(c cid.Cid) ds.Key {
+	return onDemandRequestedPrefix.ChildString(c.String())
+}
+
+// lastRequested returns the time c was last requested after it was
+// provided, or the zero time if it wasn't provided.
+func (od *OnDemand) lastRequested(c cid.Cid) (time.Time, error) {
+	v, err := od.ds.Get(requestedKey(c))
+	if err == ds.ErrNotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return decodeRequested(v), nil
+}
+
+func (od *OnDemand) setRequested(c cid.Cid, t time.Time) error {
+	return od.ds.Put(requestedKey(c), encodeUnix(t))
+}
+
+func encodeUnix(t time.Time) []byte {
+	v := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(v, t.Unix())
+	return v[:n]
+}
+
+func decodeRequested(v []byte) time.Time {
+	sec, n := binary.Varint(v)
+	if n <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Request records that c was requested. The first time, or when c was not
+// requested for the expiry, c is queued to be provided right away.
+func (od *OnDemand) Request(c cid.Cid) {
+	last, err := od.lastRequested(c)
+	if err != nil {
+		log.Errorf("reading the last request of %s: %s", c, err)
+		return
+	}
+
+	now := time.Now()
+	if !last.IsZero() && now.Sub(last) < od.expiry {
+		if now.Sub(last) < onDemandRefresh {
+			return
+		}
+		if err := od.setRequested(c, now); err != nil {
+			log.Errorf("recording the request of %s: %s", c, err)
+		}
+		return
+	}
+
+	od.lk.Lock()
+	defer od.lk.Unlock()
+	if _, ok := od.pending[c]; ok {
+		return
+	}
+	if err := od.queue.Enqueue(c); err != nil {
+		log.Errorf("queueing %s to be provided: %s", c, err)
+		return
+	}
+	od.pending[c] = struct{}{}
+}
+
+// Queued returns the number of CIDs waiting to be provided.
+func (od *OnDemand) Queued() int {
+	return od.queue.Len()
+}
+
+// Run provides the queued CIDs until ctx is canceled.
+func (od *OnDemand) Run(ctx context.Context) {
+	for {
+		c, err := od.queue.Dequeue(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("reading the provide queue: %s", err)
+			}
+			return
+		}
+
+		if err := od.provide(ctx, c); err != nil {
+			log.Debugf("failed to provide %s on demand: %s", c, err)
+
+			// keep it for later, after the queued CIDs
+			if err := od.queue.Enqueue(c); err != nil {
+				log.Errorf("queueing %s to be provided: %s", c, err)
+			}
+			select {
+			case <-time.After(onDemandRetryDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (od *OnDemand) provide(ctx context.Context, c cid.Cid) error {
+	last, err := od.lastRequested(c)
+	if err != nil {
+		return err
+	}
+	// queued again by a previous run
+	if !last.IsZero() && time.Since(last) < od.expiry {
+		od.done(c)
+		return nil
+	}
+
+	pctx, cancel := context.WithTimeout(ctx, onDemandProvideTimeout)
+	defer cancel()
+	if err := od.rsys.Provide(pctx, c, true); err != nil {
+		return err
+	}
+
+	od.done(c)
+	return od.setRequested(c, time.Now())
+}
+
+func (od *OnDemand) done(c cid.Cid) {
+	od.lk.Lock()
+	delete(od.pending, c)
+	od.lk.Unlock()
+}
+
+// KeyProvider returns the key provider of the "ondemand" strategy, streaming
+// the CIDs provided on demand and requested within the expiry. The CIDs
+// that expired are forgotten, they are provided again on their next
+// request.
+func (od *OnDemand) KeyProvider() KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		res, err := od.ds.Query(dsq.Query{Prefix: onDemandRequestedPrefix.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			defer res.Close()
+
+			for r := range res.Next() {
+				if r.Error != nil {
+					log.Errorf("listing the CIDs provided on demand: %s", r.Error)
+					return
+				}
+				k := ds.RawKey(r.Key)
+				c, err := cid.Decode(k.BaseNamespace())
+				if err != nil {
+					log.Warningf("invalid CID provided on demand %s: %s", k, err)
+					continue
+				}
+				v, _ := r.Value.([]byte)
+				if time.Since(decodeRequested(v)) >= od.expiry {
+					if err := od.ds.Delete(k); err != nil {
+						log.Errorf("forgetting %s: %s", c, err)
+					}
+					continue
+				}
+
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+type deferProvides struct {
+	routing.IpfsRouting
+}
+
+// DeferProvides returns rt ignoring the provides, for the exchange of a node
+// providing on demand: the blocks it fetches or adds are provided when they
+// are requested.
+func DeferProvides(rt routing.IpfsRouting) routing.IpfsRouting {
+	return deferProvides{rt}
+}
+
+func (deferProvides) Provide(context.Context, cid.Cid, bool) error {
+	return nil
+}
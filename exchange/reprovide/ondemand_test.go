@@ -0,0 +1,125 @@
+package reprovide_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testutil "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
+	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
+	mock "gx/ipfs/QmSNe4MWVxZWk6UxxW2z2EKofFo4GdFzud1vfn1iVby3mj/go-ipfs-routing/mock"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+
+	. "github.com/ipfs/go-ipfs/exchange/reprovide"
+)
+
+func TestQueuePersists(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	prefix := ds.NewKey("/queue")
+
+	q, err := NewQueue(d, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cids []blocks.Block
+	for _, s := range []string{"a", "b", "c"} {
+		b := blocks.NewBlock([]byte(s))
+		cids = append(cids, b)
+		if err := q.Enqueue(b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c, err := q.Dequeue(ctx); err != nil || !c.Equals(cids[0].Cid()) {
+		t.Fatalf("expected %s first, got %s (%v)", cids[0].Cid(), c, err)
+	}
+
+	// reopened, the queue holds the CIDs not dequeued, in order
+	q, err = NewQueue(d, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 2 {
+		t.Fatalf("expected 2 queued CIDs, got %d", q.Len())
+	}
+	for _, b := range cids[1:] {
+		c, err := q.Dequeue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.Equals(b.Cid()) {
+			t.Fatalf("expected %s, got %s", b.Cid(), c)
+		}
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := q.Dequeue(short); err != context.DeadlineExceeded {
+		t.Fatalf("expected the empty queue to block, got %v", err)
+	}
+}
+
+func TestOnDemand(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mrserv := mock.NewServer()
+	idA := testutil.RandIdentityOrFatal(t)
+	idB := testutil.RandIdentityOrFatal(t)
+	clA := mrserv.Client(idA)
+	clB := mrserv.Client(idB)
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	od, err := NewOnDemand(d, clA, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blk := blocks.NewBlock([]byte("requested"))
+	od.Request(blk.Cid())
+	od.Request(blk.Cid())
+	if od.Queued() != 1 {
+		t.Fatalf("expected 1 queued CID, got %d", od.Queued())
+	}
+
+	go od.Run(ctx)
+
+	reprovided := func() []string {
+		keys, err := od.KeyProvider()(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for c := range keys {
+			out = append(out, c.String())
+		}
+		return out
+	}
+
+	// the CID is recorded once provided
+	var keys []string
+	for i := 0; i < 50 && len(keys) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		keys = reprovided()
+	}
+	if len(keys) != 1 || keys[0] != blk.Cid().String() {
+		t.Fatalf("expected the requested CID to be reprovided, got %v", keys)
+	}
+
+	var providers []string
+	for p := range clB.FindProvidersAsync(ctx, blk.Cid(), 1) {
+		providers = append(providers, p.ID.Pretty())
+	}
+	if len(providers) != 1 || providers[0] != idA.ID().Pretty() {
+		t.Fatalf("expected the requested CID to be provided by A, got %v", providers)
+	}
+
+	// provided already, it isn't queued again
+	od.Request(blk.Cid())
+	if od.Queued() != 0 {
+		t.Fatalf("expected no queued CID, got %d", od.Queued())
+	}
+}
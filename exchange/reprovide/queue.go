@@ -0,0 +1,135 @@
+package reprovide
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+)
+
+// Queue is a FIFO queue of CIDs kept in a datastore, so that the CIDs still
+// queued when the node stops are dequeued after it restarts.
+type Queue struct {
+	ds     ds.Datastore
+	prefix ds.Key
+
+	lk     sync.Mutex
+	head   uint64 // sequence number of the first queued CID
+	tail   uint64 // sequence number of the next enqueued CID
+	notify chan struct{}
+}
+
+// NewQueue opens the queue stored under prefix in d.
+func NewQueue(d ds.Datastore, prefix ds.Key) (*Queue, error) {
+	q := &Queue{
+		ds:     d,
+		prefix: prefix,
+		notify: make(chan struct{}, 1),
+	}
+
+	res, err := d.Query(dsq.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	first := true
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		seq, err := strconv.ParseUint(ds.RawKey(r.Key).BaseNamespace(), 10, 64)
+		if err != nil {
+			log.Warningf("invalid provide queue entry %s", r.Key)
+			continue
+		}
+		if first || seq < q.head {
+			q.head = seq
+		}
+		if first || seq >= q.tail {
+			q.tail = seq + 1
+		}
+		first = false
+	}
+	return q, nil
+}
+
+func (q *Queue) key(seq uint64) ds.Key {
+	// zero padded, for the keys to sort in the order of the queue
+	return q.prefix.ChildString(fmt.Sprintf("%020d", seq))
+}
+
+// Len returns the number of queued CIDs.
+func (q *Queue) Len() int {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	return int(q.tail - q.head)
+}
+
+// Enqueue adds c at the end of the queue.
+func (q *Queue) Enqueue(c cid.Cid) error {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	if err := q.ds.Put(q.key(q.tail), c.Bytes()); err != nil {
+		return err
+	}
+	q.tail++
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Dequeue removes the first CID of the queue and returns it, waiting for
+// one to be enqueued when the queue is empty.
+func (q *Queue) Dequeue(ctx context.Context) (cid.Cid, error) {
+	for {
+		c, ok, err := q.pop()
+		if err != nil || ok {
+			return c, err
+		}
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return cid.Cid{}, ctx.Err()
+		}
+	}
+}
+
+func (q *Queue) pop() (cid.Cid, bool, error) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	for q.head < q.tail {
+		k := q.key(q.head)
+		v, err := q.ds.Get(k)
+		if err == ds.ErrNotFound {
+			q.head++
+			continue
+		}
+		if err != nil {
+			return cid.Cid{}, false, err
+		}
+
+		if err := q.ds.Delete(k); err != nil {
+			return cid.Cid{}, false, err
+		}
+		q.head++
+
+		c, err := cid.Cast(v)
+		if err != nil {
+			log.Warningf("invalid CID in the provide queue at %s: %s", k, err)
+			continue
+		}
+		return c, true, nil
+	}
+	return cid.Cid{}, false, nil
+}
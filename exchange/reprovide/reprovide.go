@@ -3,6 +3,7 @@ package reprovide
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	backoff "gx/ipfs/QmPJUtEJsm5YLUWhF6imvyCH8KZXRJa9Wup7FDMwTy5Ufz/backoff"
@@ -26,6 +27,27 @@ type Reprovider struct {
 	rsys routing.ContentRouting
 
 	keyProvider KeyChanFunc
+
+	lk   sync.Mutex
+	stat Stat
+}
+
+// Stat is the state of the reprovide cycles.
+type Stat struct {
+	// Running tells whether a cycle is running.
+	Running bool
+	// Provided is the number of CIDs provided by the running cycle, or by
+	// the last one.
+	Provided int
+	// LastRun is the start of the last cycle, zero before the first one.
+	LastRun time.Time
+	// LastDuration is the time the last finished cycle took.
+	LastDuration time.Duration
+	// LastError is the error that stopped the last cycle.
+	LastError string `json:",omitempty"`
+	// NextRun is the time of the next cycle, zero when they are only
+	// triggered.
+	NextRun time.Time
 }
 
 // NewReprovider creates new Reprovider instance.
@@ -45,10 +67,12 @@ func (rp *Reprovider) Run(tick time.Duration) {
 	// may have just started the daemon and shutting it down immediately.
 	// probability( up another minute | uptime ) increases with uptime.
 	after := time.After(time.Minute)
+	rp.setNextRun(time.Minute)
 	var done doneFunc
 	for {
 		if tick == 0 {
 			after = make(chan time.Time)
+			rp.setNextRun(0)
 		}
 
 		select {
@@ -62,11 +86,27 @@ func (rp *Reprovider) Run(tick time.Duration) {
 		//a 'reprovider is already running' error is returned
 		unmute := rp.muteTrigger()
 
+		start := time.Now()
+		rp.lk.Lock()
+		rp.stat.Running = true
+		rp.stat.Provided = 0
+		rp.stat.LastRun = start
+		rp.lk.Unlock()
+
 		err := rp.Reprovide()
 		if err != nil {
 			log.Debug(err)
 		}
 
+		rp.lk.Lock()
+		rp.stat.Running = false
+		rp.stat.LastDuration = time.Since(start)
+		rp.stat.LastError = ""
+		if err != nil {
+			rp.stat.LastError = err.Error()
+		}
+		rp.lk.Unlock()
+
 		if done != nil {
 			done(err)
 		}
@@ -74,6 +114,7 @@ func (rp *Reprovider) Run(tick time.Duration) {
 		unmute()
 
 		after = time.After(tick)
+		rp.setNextRun(tick)
 	}
 }
 
@@ -104,10 +145,31 @@ func (rp *Reprovider) Reprovide() error {
 			log.Debugf("Providing failed after number of retries: %s", err)
 			return err
 		}
+
+		rp.lk.Lock()
+		rp.stat.Provided++
+		rp.lk.Unlock()
 	}
 	return nil
 }
 
+func (rp *Reprovider) setNextRun(after time.Duration) {
+	rp.lk.Lock()
+	defer rp.lk.Unlock()
+	if after == 0 {
+		rp.stat.NextRun = time.Time{}
+		return
+	}
+	rp.stat.NextRun = time.Now().Add(after)
+}
+
+// Stat returns the state of the reprovide cycles.
+func (rp *Reprovider) Stat() Stat {
+	rp.lk.Lock()
+	defer rp.lk.Unlock()
+	return rp.stat
+}
+
 // Trigger starts reprovision process in rp.Run and waits for it
 func (rp *Reprovider) Trigger(ctx context.Context) error {
 	progressCtx, done := context.WithCancel(ctx)
@@ -3,6 +3,7 @@ package reprovide_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	testutil "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
 	blocks "gx/ipfs/QmRcHuYzAyswytBuMF78rj3LTChYszomRFXNg4685ZN1WM/go-block-format"
@@ -54,4 +55,55 @@ func TestReprovide(t *testing.T) {
 	if providers[0].ID != idA.ID() {
 		t.Fatal("Somehow got the wrong peer back as a provider.")
 	}
+
+	if st := reprov.Stat(); st.Provided != 1 {
+		t.Fatalf("expected 1 CID provided, got %d", st.Provided)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mrserv := mock.NewServer()
+	cl := mrserv.Client(testutil.RandIdentityOrFatal(t))
+	tr := NewTracker(dssync.MutexWrap(ds.NewMapDatastore()), cl)
+
+	provided := blocks.NewBlock([]byte("provided")).Cid()
+	other := blocks.NewBlock([]byte("not provided")).Cid()
+	start := time.Now().Add(-time.Second)
+	if err := tr.Provide(ctx, provided, true); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := tr.LastProvided(provided)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Before(start) {
+		t.Fatalf("expected %s to be provided after %s, got %s", provided, start, last)
+	}
+	last, err = tr.LastProvided(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.IsZero() {
+		t.Fatalf("expected %s never to be provided, got %s", other, last)
+	}
+
+	recs, err := tr.Provided(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []ProvideRecord
+	for r := range recs {
+		all = append(all, r)
+	}
+	if len(all) != 1 || !all[0].Cid.Equals(provided) {
+		t.Fatalf("expected only %s to be listed, got %v", provided, all)
+	}
+
+	if st := tr.Stat(); st.Provides != 1 || st.Failures != 0 || st.InFlight != 0 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
 }
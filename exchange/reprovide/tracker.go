@@ -0,0 +1,128 @@
+package reprovide
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
+)
+
+var providedPrefix = ds.NewKey("/local/provider/provided")
+
+// Tracker wraps the routing of the node to record the time each CID was
+// last announced, whoever provides it: the reprovider, the exchange, the
+// provider on demand or the commands. The times are kept in the datastore,
+// so that they survive the restarts.
+type Tracker struct {
+	routing.IpfsRouting
+	ds ds.Datastore
+
+	inFlight int64
+	provides uint64
+	failures uint64
+}
+
+// TrackerStat counts the provides through a Tracker.
+type TrackerStat struct {
+	// InFlight is the number of provides running.
+	InFlight int64
+	// Provides is the number of successful provides since the start.
+	Provides uint64
+	// Failures is the number of failed provides since the start.
+	Failures uint64
+}
+
+// ProvideRecord is a CID and the time it was last announced.
+type ProvideRecord struct {
+	Cid  cid.Cid
+	Time time.Time
+}
+
+// NewTracker returns rt recording the time of its provides in d.
+func NewTracker(d ds.Datastore, rt routing.IpfsRouting) *Tracker {
+	return &Tracker{IpfsRouting: rt, ds: d}
+}
+
+func providedKey(c cid.Cid) ds.Key {
+	return providedPrefix.ChildString(c.String())
+}
+
+func (t *Tracker) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	atomic.AddInt64(&t.inFlight, 1)
+	defer atomic.AddInt64(&t.inFlight, -1)
+
+	if err := t.IpfsRouting.Provide(ctx, c, announce); err != nil {
+		atomic.AddUint64(&t.failures, 1)
+		return err
+	}
+	atomic.AddUint64(&t.provides, 1)
+
+	// the provides without announce are only recorded locally
+	if announce {
+		if err := t.ds.Put(providedKey(c), encodeUnix(time.Now())); err != nil {
+			log.Errorf("recording the provide of %s: %s", c, err)
+		}
+	}
+	return nil
+}
+
+// LastProvided returns the time c was last announced, the zero time if it
+// never was.
+func (t *Tracker) LastProvided(c cid.Cid) (time.Time, error) {
+	v, err := t.ds.Get(providedKey(c))
+	if err == ds.ErrNotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return decodeRequested(v), nil
+}
+
+// Provided streams the CIDs announced, with the time they last were.
+func (t *Tracker) Provided(ctx context.Context) (<-chan ProvideRecord, error) {
+	res, err := t.ds.Query(dsq.Query{Prefix: providedPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan ProvideRecord)
+	go func() {
+		defer close(out)
+		defer res.Close()
+
+		for r := range res.Next() {
+			if r.Error != nil {
+				log.Errorf("listing the provided CIDs: %s", r.Error)
+				return
+			}
+			k := ds.RawKey(r.Key)
+			c, err := cid.Decode(k.BaseNamespace())
+			if err != nil {
+				log.Warningf("invalid provided CID %s: %s", k, err)
+				continue
+			}
+			v, _ := r.Value.([]byte)
+
+			select {
+			case out <- ProvideRecord{Cid: c, Time: decodeRequested(v)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Stat returns the counts of the provides.
+func (t *Tracker) Stat() TrackerStat {
+	return TrackerStat{
+		InFlight: atomic.LoadInt64(&t.inFlight),
+		Provides: atomic.LoadUint64(&t.provides),
+		Failures: atomic.LoadUint64(&t.failures),
+	}
+}
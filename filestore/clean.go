@@ -0,0 +1,65 @@
+package filestore
+
+import (
+	"fmt"
+)
+
+// ParseStatus returns the Status with the given name, as returned by
+// Status.String().
+func ParseStatus(s string) (Status, error) {
+	for _, st := range []Status{
+		StatusOk,
+		StatusFileError,
+		StatusFileNotFound,
+		StatusFileChanged,
+		StatusOtherError,
+		StatusKeyNotFound,
+	} {
+		if st.String() == s {
+			return st, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown filestore status %q", s)
+}
+
+// CleanAll verifies every reference of the Filestore's FileManager and
+// removes the ones whose status is one of the given statuses. It returns a
+// function as an iterator which, once invoked, returns one by one each
+// removed reference, with the status it had. The references that could
+// not be removed are returned with a StatusOtherError.
+//
+// Only the references are removed, the backing files are not touched.
+func CleanAll(fs *Filestore, statuses ...Status) (func() *ListRes, error) {
+	remove := make(map[Status]bool)
+	for _, s := range statuses {
+		if s == StatusOk {
+			return nil, fmt.Errorf("cannot clean valid filestore references")
+		}
+		remove[s] = true
+	}
+
+	// the references are all collected before being verified, so they
+	// can be removed while iterating
+	next, err := listAllFileOrder(fs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() *ListRes {
+		for {
+			r := next()
+			if r == nil {
+				return nil
+			}
+			if !remove[r.Status] {
+				continue
+			}
+
+			if err := fs.fm.DeleteBlock(r.Key); err != nil {
+				r.Status = StatusOtherError
+				r.ErrorMsg = fmt.Sprintf("removing %s: %s", r.Key, err)
+			}
+			return r
+		}
+	}, nil
+}
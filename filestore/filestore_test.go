@@ -5,6 +5,8 @@ import (
 	"context"
 	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 
 	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
@@ -175,3 +177,128 @@ func TestIsURL(t *testing.T) {
 		t.Fatal("IsURL recognized non-url")
 	}
 }
+
+func TestMoveAll(t *testing.T) {
+	dir, fs := newTestFilestore(t)
+
+	olddir := filepath.Join(dir, "old")
+	if err := os.Mkdir(olddir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	moved, movedCids := randomFileAdd(t, fs, olddir, 100)
+	changed, changedCids := randomFileAdd(t, fs, olddir, 100)
+	_, otherCids := randomFileAdd(t, fs, dir, 100)
+
+	newdir := filepath.Join(dir, "new")
+	if err := os.Rename(olddir, newdir); err != nil {
+		t.Fatal(err)
+	}
+	changedPath := filepath.Join(newdir, filepath.Base(changed))
+	if err := ioutil.WriteFile(changedPath, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := MoveAll(fs, olddir, newdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := make(map[string]Status)
+	for r := next(); r != nil; r = next() {
+		status[r.Key.KeyString()] = r.Status
+	}
+	if len(status) != len(movedCids)+len(changedCids) {
+		t.Fatalf("expected %d moved references, got %d", len(movedCids)+len(changedCids), len(status))
+	}
+
+	for _, c := range movedCids {
+		if status[c.KeyString()] != StatusOk {
+			t.Fatalf("expected %s to be moved, got %s", c, status[c.KeyString()])
+		}
+		r := List(fs, c)
+		if r.FilePath != filepath.ToSlash(filepath.Join("new", filepath.Base(moved))) {
+			t.Fatalf("unexpected path %s", r.FilePath)
+		}
+		if _, err := fs.Get(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range changedCids {
+		if status[c.KeyString()] != StatusFileChanged {
+			t.Fatalf("expected %s to be changed, got %s", c, status[c.KeyString()])
+		}
+		r := List(fs, c)
+		if r.FilePath != filepath.ToSlash(filepath.Join("old", filepath.Base(changed))) {
+			t.Fatalf("the reference %s should be kept, got %s", c, r.FilePath)
+		}
+	}
+	for _, c := range otherCids {
+		if _, err := fs.Get(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCleanAll(t *testing.T) {
+	dir, fs := newTestFilestore(t)
+
+	_, okCids := randomFileAdd(t, fs, dir, 100)
+	removed, removedCids := randomFileAdd(t, fs, dir, 100)
+	changed, changedCids := randomFileAdd(t, fs, dir, 100)
+
+	if err := os.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(changed, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CleanAll(fs, StatusOk); err == nil {
+		t.Fatal("expected valid references not to be cleaned")
+	}
+
+	next, err := CleanAll(fs, StatusFileNotFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for r := next(); r != nil; r = next() {
+		if r.Status != StatusFileNotFound {
+			t.Fatalf("unexpected status %s for %s", r.Status, r.Key)
+		}
+		n++
+	}
+	if n != len(removedCids) {
+		t.Fatalf("expected %d cleaned references, got %d", len(removedCids), n)
+	}
+
+	for _, c := range removedCids {
+		if r := List(fs, c); r.Status != StatusKeyNotFound {
+			t.Fatalf("expected the reference %s to be removed", c)
+		}
+	}
+	for _, c := range changedCids {
+		if r := Verify(fs, c); r.Status != StatusFileChanged {
+			t.Fatalf("expected the reference %s to be kept, got %s", c, r.Status)
+		}
+	}
+	for _, c := range okCids {
+		if _, err := fs.Get(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for _, s := range []Status{StatusFileError, StatusFileNotFound, StatusFileChanged} {
+		p, err := ParseStatus(s.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != s {
+			t.Fatalf("expected %s, got %s", s, p)
+		}
+	}
+	if _, err := ParseStatus("foo"); err == nil {
+		t.Fatal("expected an error for an unknown status")
+	}
+}
@@ -0,0 +1,113 @@
+package filestore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "github.com/ipfs/go-ipfs/filestore/pb"
+
+	dshelp "gx/ipfs/QmPQ7bVbZAbGaJkBVJeTkkKXvLLZeN9CLWTf5fzUQ8yeWs/go-ipfs-ds-help"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	proto "gx/ipfs/QmdxUuburamoF6zF9qjeQC4WYcWGbWuRmdLacMEsW8ioD8/gogo-protobuf/proto"
+)
+
+// MoveAll rewrites the references to the files under the from path so that
+// they point to the same files under the to path, once they have been moved
+// there. Both paths are absolute. The blocks are read from their new
+// location and verified before their reference is rewritten, the references
+// that don't verify are kept. Each reference under from is returned with the
+// status of the block at its new location, and its new path when rewritten.
+func MoveAll(fs *Filestore, from, to string) (func() *ListRes, error) {
+	if !filepath.IsAbs(from) || !filepath.IsAbs(to) {
+		return nil, fmt.Errorf("the paths to move must be absolute")
+	}
+	from = filepath.Clean(from)
+	to = filepath.Clean(to)
+	if !filepath.HasPrefix(to, fs.fm.root) {
+		return nil, fmt.Errorf("cannot move filestore references outside ipfs root (%s)", fs.fm.root)
+	}
+
+	// collect the references first, they can't be rewritten while the
+	// query runs
+	qr, err := fs.fm.ds.Query(dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	var entries []dsq.Entry
+	for {
+		v, ok := qr.NextSync()
+		if !ok {
+			break
+		}
+		if v.Error != nil {
+			return nil, v.Error
+		}
+		entries = append(entries, v.Entry)
+	}
+
+	i := 0
+	return func() *ListRes {
+		for i < len(entries) {
+			v := entries[i]
+			i++
+
+			c, err := dshelp.DsKeyToCid(ds.RawKey(v.Key))
+			if err != nil {
+				return mkListRes(c, nil, fmt.Errorf("decoding cid from filestore: %s", err))
+			}
+			dobj, err := unmarshalDataObj(v.Value)
+			if err != nil {
+				return mkListRes(c, nil, err)
+			}
+
+			newPath, ok := fs.fm.movedPath(dobj.GetFilePath(), from, to)
+			if !ok {
+				continue
+			}
+			return fs.fm.move(c, dobj, newPath)
+		}
+		return nil
+	}, nil
+}
+
+// movedPath returns the path relative to the root of a reference moved from
+// the from path to the to path, if it's under from.
+func (f *FileManager) movedPath(p, from, to string) (string, bool) {
+	if IsURL(p) {
+		return "", false
+	}
+	abspath := filepath.Join(f.root, filepath.FromSlash(p))
+	if abspath != from && !strings.HasPrefix(abspath, from+string(os.PathSeparator)) {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(f.root, to+abspath[len(from):])
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (f *FileManager) move(c cid.Cid, dobj *pb.DataObj, newPath string) *ListRes {
+	moved := *dobj
+	moved.FilePath = newPath
+	if _, err := f.readFileDataObj(c, &moved); err != nil {
+		res := mkListRes(c, &moved, err)
+		// the reference wasn't rewritten
+		res.FilePath = dobj.GetFilePath()
+		return res
+	}
+
+	data, err := proto.Marshal(&moved)
+	if err == nil {
+		err = f.ds.Put(dshelp.CidToDsKey(c), data)
+	}
+	if err != nil {
+		return mkListRes(c, dobj, err)
+	}
+	return mkListRes(c, &moved, nil)
+}
@@ -306,6 +306,9 @@ func (s *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	case *mfs.Directory:
 		return &Directory{dir: child}, nil
 	case *mfs.File:
+		if target, ok := symlinkTarget(child); ok {
+			return &Link{Target: target}, nil
+		}
 		return &FileNode{fi: child}, nil
 	default:
 		// NB: if this happens, we do not want to continue, unpredictable behaviour
@@ -329,6 +332,14 @@ func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 			dirent.Type = fuse.DT_Dir
 		case mfs.TFile:
 			dirent.Type = fuse.DT_File
+			// mfs lists the symlinks as files
+			if child, err := dir.dir.Child(entry.Name); err == nil {
+				if fi, ok := child.(*mfs.File); ok {
+					if _, ok := symlinkTarget(fi); ok {
+						dirent.Type = fuse.DT_Link
+					}
+				}
+			}
 		}
 
 		entries = append(entries, dirent)
@@ -6,8 +6,11 @@ import (
 	"context"
 	"os"
 
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	mfs "gx/ipfs/QmRkrpnhZqDxTxwGCsDbuZMr7uCFZHH6SGfrcjgEQwxF3t/go-mfs"
 	"gx/ipfs/QmSJBsmLP1XMjv8hxYg2rUMdPDB7YUpyBo9idjrJ6Cmq6F/fuse"
 	"gx/ipfs/QmSJBsmLP1XMjv8hxYg2rUMdPDB7YUpyBo9idjrJ6Cmq6F/fuse/fs"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
 )
 
 type Link struct {
@@ -26,3 +29,35 @@ func (l *Link) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string,
 }
 
 var _ fs.NodeReadlinker = (*Link)(nil)
+
+// Symlink creates a unixfs symlink in the directory.
+func (dir *Directory) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	data, err := ft.SymlinkData(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	if err := dir.dir.AddChild(req.NewName, dag.NodeWithData(data)); err != nil {
+		return nil, err
+	}
+	return &Link{Target: req.Target}, nil
+}
+
+var _ fs.NodeSymlinker = (*Directory)(nil)
+
+// symlinkTarget returns the target of fi when it's a unixfs symlink, which
+// mfs handles as a file.
+func symlinkTarget(fi *mfs.File) (string, bool) {
+	nd, err := fi.GetNode()
+	if err != nil {
+		return "", false
+	}
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return "", false
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil || fsn.Type() != ft.TSymlink {
+		return "", false
+	}
+	return string(fsn.Data()), true
+}
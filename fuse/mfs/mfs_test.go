@@ -0,0 +1,162 @@
+// +build !nofuse
+
+package mfs
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+
+	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	ci "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil/ci"
+	mfs "gx/ipfs/QmRkrpnhZqDxTxwGCsDbuZMr7uCFZHH6SGfrcjgEQwxF3t/go-mfs"
+	fstest "gx/ipfs/QmSJBsmLP1XMjv8hxYg2rUMdPDB7YUpyBo9idjrJ6Cmq6F/fuse/fs/fstestutil"
+)
+
+func setupMfsTest(t *testing.T) (*core.IpfsNode, *fstest.Mount) {
+	if ci.NoFuse() {
+		t.Skip("Skipping FUSE tests")
+	}
+
+	node, err := core.NewNode(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mnt, err := fstest.MountedT(t, NewFileSystem(node.FilesRoot), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node, mnt
+}
+
+// readMfs reads a file through the mfs API, bypassing the mount
+func readMfs(t *testing.T, node *core.IpfsNode, path string) []byte {
+	fsn, err := mfs.Lookup(node.FilesRoot, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, ok := fsn.(*mfs.File)
+	if !ok {
+		t.Fatalf("%s is not a file", path)
+	}
+
+	fd, err := fi.Open(mfs.OpenReadOnly, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	data, err := ioutil.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestWriteThroughMount(t *testing.T) {
+	node, mnt := setupMfsTest(t)
+	defer mnt.Close()
+
+	if err := os.MkdirAll(filepath.Join(mnt.Dir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 500000)
+	u.NewTimeSeededRand().Read(data)
+	path := filepath.Join(mnt.Dir, "a", "b", "file")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(readMfs(t, node, "/a/b/file"), data) {
+		t.Fatal("the file of the files root differs from the written data")
+	}
+
+	read, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Fatal("the file read from the mount differs from the written data")
+	}
+}
+
+func TestTruncateAndAppend(t *testing.T) {
+	node, mnt := setupMfsTest(t)
+	defer mnt.Close()
+
+	path := filepath.Join(mnt.Dir, "file")
+	if err := ioutil.WriteFile(path, []byte("some longer content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("short"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fi.Write([]byte(" end")); err != nil {
+		t.Fatal(err)
+	}
+	// truncating an open file goes through its descriptor
+	if err := fi.Truncate(7); err != nil {
+		t.Fatal(err)
+	}
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(readMfs(t, node, "/file")); got != "short e" {
+		t.Fatalf("expected %q, got %q", "short e", got)
+	}
+}
+
+func TestRenameAndRemove(t *testing.T) {
+	node, mnt := setupMfsTest(t)
+	defer mnt.Close()
+
+	src := filepath.Join(mnt.Dir, "src")
+	dst := filepath.Join(mnt.Dir, "dst")
+	if err := ioutil.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the existing entry is replaced
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readMfs(t, node, "/dst")); got != "new" {
+		t.Fatalf("expected %q, got %q", "new", got)
+	}
+	if _, err := mfs.Lookup(node.FilesRoot, "/src"); err == nil {
+		t.Fatal("expected /src to be gone")
+	}
+
+	dir := filepath.Join(mnt.Dir, "dir")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(dst, filepath.Join(dir, "dst")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(dir); err == nil {
+		t.Fatal("expected removing a non-empty directory to fail")
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfs.Lookup(node.FilesRoot, "/dir"); err == nil {
+		t.Fatal("expected /dir to be gone")
+	}
+}
@@ -0,0 +1,484 @@
+// +build !nofuse
+
+// package fuse/mfs implements a read-write fuse filesystem exposing the
+// mutable file system of the node, the one of 'ipfs files'.
+package mfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	ft "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	mfs "gx/ipfs/QmRkrpnhZqDxTxwGCsDbuZMr7uCFZHH6SGfrcjgEQwxF3t/go-mfs"
+	fuse "gx/ipfs/QmSJBsmLP1XMjv8hxYg2rUMdPDB7YUpyBo9idjrJ6Cmq6F/fuse"
+	fs "gx/ipfs/QmSJBsmLP1XMjv8hxYg2rUMdPDB7YUpyBo9idjrJ6Cmq6F/fuse/fs"
+)
+
+var log = logging.Logger("fuse/mfs")
+
+// FileSystem is the readwrite MFS Fuse Filesystem.
+type FileSystem struct {
+	root *mfs.Root
+
+	// the files open for writing, mfs only allows one writer per file
+	lk      sync.Mutex
+	writers map[*mfs.File]*File
+}
+
+// NewFileSystem constructs a new fs exposing the given mfs root.
+func NewFileSystem(root *mfs.Root) *FileSystem {
+	return &FileSystem{
+		root:    root,
+		writers: make(map[*mfs.File]*File),
+	}
+}
+
+// Root returns the root directory of the filesystem.
+func (f *FileSystem) Root() (fs.Node, error) {
+	return &Directory{fs: f, dir: f.root.GetDirectory()}, nil
+}
+
+func (f *FileSystem) writer(fi *mfs.File) *File {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.writers[fi]
+}
+
+// Destroy flushes the mfs root when the filesystem is unmounted. The root
+// itself belongs to the node and is left open.
+func (f *FileSystem) Destroy() {
+	if err := f.root.Flush(); err != nil {
+		log.Errorf("error flushing the files root: %s", err)
+	}
+}
+
+// Directory is wrapper over an mfs directory to satisfy the fuse fs interface
+type Directory struct {
+	fs  *FileSystem
+	dir *mfs.Directory
+}
+
+// FileNode is wrapper over an mfs file to satisfy the fuse fs interface
+type FileNode struct {
+	fs *FileSystem
+	fi *mfs.File
+}
+
+// Link is a unixfs symlink, which mfs handles as a file
+type Link struct {
+	target string
+}
+
+// File is an open mfs file
+type File struct {
+	node *FileNode
+
+	// the requests of a handle are served concurrently
+	lk sync.Mutex
+	fd mfs.FileDescriptor
+}
+
+// Attr returns the attributes of a given node.
+func (d *Directory) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = os.ModeDir | 0755
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+	return nil
+}
+
+// Lookup performs a lookup under this node.
+func (d *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	child, err := d.dir.Child(name)
+	if err != nil {
+		return nil, fuse.ENOENT
+	}
+
+	switch child := child.(type) {
+	case *mfs.Directory:
+		return &Directory{fs: d.fs, dir: child}, nil
+	case *mfs.File:
+		if target, ok := symlinkTarget(child); ok {
+			return &Link{target: target}, nil
+		}
+		return &FileNode{fs: d.fs, fi: child}, nil
+	default:
+		return nil, fuse.EIO
+	}
+}
+
+// ReadDirAll lists the entries of the directory.
+func (d *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	listing, err := d.dir.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]fuse.Dirent, 0, len(listing))
+	for _, entry := range listing {
+		dirent := fuse.Dirent{Name: entry.Name}
+		switch mfs.NodeType(entry.Type) {
+		case mfs.TDir:
+			dirent.Type = fuse.DT_Dir
+		case mfs.TFile:
+			dirent.Type = fuse.DT_File
+			if child, err := d.dir.Child(entry.Name); err == nil {
+				if fi, ok := child.(*mfs.File); ok {
+					if _, ok := symlinkTarget(fi); ok {
+						dirent.Type = fuse.DT_Link
+					}
+				}
+			}
+		}
+		entries = append(entries, dirent)
+	}
+	return entries, nil
+}
+
+// Mkdir creates a directory.
+func (d *Directory) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	child, err := d.dir.Mkdir(req.Name)
+	if err != nil {
+		if err == os.ErrExist {
+			return nil, fuse.EEXIST
+		}
+		return nil, err
+	}
+	return &Directory{fs: d.fs, dir: child}, nil
+}
+
+// Symlink creates a unixfs symlink.
+func (d *Directory) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	data, err := ft.SymlinkData(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	nd := dag.NodeWithData(data)
+	nd.SetCidBuilder(d.dir.GetCidBuilder())
+	if err := d.dir.AddChild(req.NewName, nd); err != nil {
+		return nil, err
+	}
+	return &Link{target: req.Target}, nil
+}
+
+// Attr returns the attributes of a symlink.
+func (l *Link) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = os.ModeSymlink | 0777
+	a.Size = uint64(len(l.target))
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+	return nil
+}
+
+// Readlink returns the target of a symlink.
+func (l *Link) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	return l.target, nil
+}
+
+// symlinkTarget returns the target of fi when it's a unixfs symlink.
+func symlinkTarget(fi *mfs.File) (string, bool) {
+	nd, err := fi.GetNode()
+	if err != nil {
+		return "", false
+	}
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return "", false
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil || fsn.Type() != ft.TSymlink {
+		return "", false
+	}
+	return string(fsn.Data()), true
+}
+
+// Create creates an empty file and opens it.
+func (d *Directory) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+	nd.SetCidBuilder(d.dir.GetCidBuilder())
+	if err := d.dir.AddChild(req.Name, nd); err != nil {
+		return nil, nil, err
+	}
+
+	child, err := d.dir.Child(req.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, ok := child.(*mfs.File)
+	if !ok {
+		return nil, nil, errors.New("child creation failed")
+	}
+
+	node := &FileNode{fs: d.fs, fi: fi}
+	h, err := node.open(req.Flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, h, nil
+}
+
+// Remove removes a file or an empty directory.
+func (d *Directory) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	child, err := d.dir.Child(req.Name)
+	if err != nil {
+		return fuse.ENOENT
+	}
+
+	if req.Dir {
+		cdir, ok := child.(*mfs.Directory)
+		if !ok {
+			return fuse.Errno(syscall.ENOTDIR)
+		}
+		names, err := cdir.ListNames(ctx)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return fuse.Errno(syscall.ENOTEMPTY)
+		}
+	}
+
+	return d.dir.Unlink(req.Name)
+}
+
+// Rename moves an entry to newDir, replacing the existing entry of the new
+// name as rename(2) does.
+func (d *Directory) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	dst, ok := newDir.(*Directory)
+	if !ok {
+		return fuse.Errno(syscall.ENOTDIR)
+	}
+	if dst.dir == d.dir && req.OldName == req.NewName {
+		return nil
+	}
+
+	cur, err := d.dir.Child(req.OldName)
+	if err != nil {
+		return fuse.ENOENT
+	}
+	nd, err := cur.GetNode()
+	if err != nil {
+		return err
+	}
+
+	if _, err := dst.dir.Child(req.NewName); err == nil {
+		if err := dst.dir.Unlink(req.NewName); err != nil {
+			return err
+		}
+	}
+	if err := dst.dir.AddChild(req.NewName, nd); err != nil {
+		return err
+	}
+	return d.dir.Unlink(req.OldName)
+}
+
+// Fsync flushes the directory up to the mfs root.
+func (d *Directory) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	return d.dir.Flush()
+}
+
+// Attr returns the attributes of a given node.
+func (fi *FileNode) Attr(ctx context.Context, a *fuse.Attr) error {
+	size, err := fi.fi.Size()
+	if err != nil {
+		return err
+	}
+	a.Mode = 0644
+	a.Size = uint64(size)
+	a.Uid = uint32(os.Getuid())
+	a.Gid = uint32(os.Getgid())
+	return nil
+}
+
+// Open opens the file.
+func (fi *FileNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	return fi.open(req.Flags)
+}
+
+func (fi *FileNode) open(flags fuse.OpenFlags) (*File, error) {
+	var mode int
+	switch {
+	case flags.IsReadOnly():
+		mode = mfs.OpenReadOnly
+	case flags.IsWriteOnly():
+		mode = mfs.OpenWriteOnly
+	case flags.IsReadWrite():
+		mode = mfs.OpenReadWrite
+	default:
+		return nil, errors.New("unsupported open mode")
+	}
+
+	// the writes are synced up to the root when the file is closed
+	fd, err := fi.fi.Open(mode, true)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{node: fi, fd: fd}
+	if flags.IsReadOnly() {
+		return f, nil
+	}
+
+	if flags&fuse.OpenTruncate != 0 {
+		err = fd.Truncate(0)
+	} else if flags&fuse.OpenAppend != 0 {
+		_, err = fd.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	fi.fs.lk.Lock()
+	fi.fs.writers[fi.fi] = f
+	fi.fs.lk.Unlock()
+	return f, nil
+}
+
+// Setattr truncates the file to the requested size. The other attributes
+// aren't stored by mfs.
+func (fi *FileNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if req.Valid.Size() {
+		if err := fi.truncate(int64(req.Size)); err != nil {
+			return err
+		}
+	}
+	return fi.Attr(ctx, &resp.Attr)
+}
+
+func (fi *FileNode) truncate(size int64) error {
+	// truncate through the open writer if any, opening another one would
+	// wait for it to be closed
+	if w := fi.fs.writer(fi.fi); w != nil {
+		w.lk.Lock()
+		defer w.lk.Unlock()
+		return w.fd.Truncate(size)
+	}
+
+	fd, err := fi.fi.Open(mfs.OpenWriteOnly, true)
+	if err != nil {
+		return err
+	}
+	err = fd.Truncate(size)
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Fsync flushes the file, with the writes of its open writer, up to the mfs
+// root.
+func (fi *FileNode) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	if w := fi.fs.writer(fi.fi); w != nil {
+		w.lk.Lock()
+		defer w.lk.Unlock()
+		return w.fd.Flush()
+	}
+	return fi.fi.Flush()
+}
+
+// Read reads from the file at the requested offset.
+func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	if _, err := f.fd.Seek(req.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	n, err := f.fd.CtxReadFull(ctx, resp.Data[:req.Size])
+	resp.Data = resp.Data[:n]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return err
+}
+
+// Write writes to the file at the requested offset.
+func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	n, err := f.fd.WriteAt(req.Data, req.Offset)
+	if err != nil {
+		return err
+	}
+	resp.Size = n
+	return nil
+}
+
+// Flush is called on each close of the file, it flushes the writes up to
+// the mfs root so that they are visible through 'ipfs files'.
+func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	errs := make(chan error, 1)
+	go func() {
+		f.lk.Lock()
+		defer f.lk.Unlock()
+		errs <- f.fd.Flush()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release closes the file once it isn't used anymore.
+func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	fsys := f.node.fs
+	fsys.lk.Lock()
+	if fsys.writers[f.node.fi] == f {
+		delete(fsys.writers, f.node.fi)
+	}
+	fsys.lk.Unlock()
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.fd.Close()
+}
+
+// to check that our nodes implement all the interfaces we want
+type mfsDirectory interface {
+	fs.Node
+	fs.HandleReadDirAller
+	fs.NodeCreater
+	fs.NodeFsyncer
+	fs.NodeMkdirer
+	fs.NodeRemover
+	fs.NodeRenamer
+	fs.NodeStringLookuper
+	fs.NodeSymlinker
+}
+
+var _ mfsDirectory = (*Directory)(nil)
+
+type mfsFileNode interface {
+	fs.Node
+	fs.NodeFsyncer
+	fs.NodeOpener
+	fs.NodeSetattrer
+}
+
+var _ mfsFileNode = (*FileNode)(nil)
+
+type mfsFile interface {
+	fs.HandleFlusher
+	fs.HandleReader
+	fs.HandleWriter
+	fs.HandleReleaser
+}
+
+var _ mfsFile = (*File)(nil)
+
+type mfsLink interface {
+	fs.Node
+	fs.NodeReadlinker
+}
+
+var _ mfsLink = (*Link)(nil)
@@ -0,0 +1,23 @@
+// +build linux darwin freebsd netbsd openbsd
+// +build !nofuse
+
+package mfs
+
+import (
+	core "github.com/ipfs/go-ipfs/core"
+	mount "github.com/ipfs/go-ipfs/fuse/mount"
+)
+
+// Mount mounts the files root of the node at a given location, and returns
+// a mount.Mount instance.
+func Mount(ipfs *core.IpfsNode, mountpoint string) (mount.Mount, error) {
+	cfg, err := ipfs.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	allow_other := cfg.Mounts.FuseAllowOther
+
+	fsys := NewFileSystem(ipfs.FilesRoot)
+	return mount.NewMount(ipfs.Process(), fsys, mountpoint, allow_other)
+}
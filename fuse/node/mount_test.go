@@ -70,7 +70,7 @@ func TestExternalUnmount(t *testing.T) {
 	mkdir(t, ipfsDir)
 	mkdir(t, ipnsDir)
 
-	err = Mount(node, ipfsDir, ipnsDir)
+	err = Mount(node, ipfsDir, ipnsDir, "")
 	if err != nil {
 		t.Fatal(err)
 	}
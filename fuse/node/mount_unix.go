@@ -10,6 +10,7 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	ipns "github.com/ipfs/go-ipfs/fuse/ipns"
+	fusemfs "github.com/ipfs/go-ipfs/fuse/mfs"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
 	rofs "github.com/ipfs/go-ipfs/fuse/readonly"
 
@@ -30,7 +31,9 @@ var platformFuseChecks = func(*core.IpfsNode) error {
 	return nil
 }
 
-func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
+// Mount mounts ipfs at fsdir and ipns at nsdir. The files root is mounted
+// read-write at mfsdir, unless it is empty.
+func Mount(node *core.IpfsNode, fsdir, nsdir, mfsdir string) error {
 	// check if we already have live mounts.
 	// if the user said "Mount", then there must be something wrong.
 	// so, close them and try again.
@@ -40,15 +43,18 @@ func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
 	if node.Mounts.Ipns != nil && node.Mounts.Ipns.IsActive() {
 		node.Mounts.Ipns.Unmount()
 	}
+	if node.Mounts.Mfs != nil && node.Mounts.Mfs.IsActive() {
+		node.Mounts.Mfs.Unmount()
+	}
 
 	if err := platformFuseChecks(node); err != nil {
 		return err
 	}
 
-	return doMount(node, fsdir, nsdir)
+	return doMount(node, fsdir, nsdir, mfsdir)
 }
 
-func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
+func doMount(node *core.IpfsNode, fsdir, nsdir, mfsdir string) error {
 	fmtFuseErr := func(err error, mountpoint string) error {
 		s := err.Error()
 		if strings.Contains(s, fuseNoDirectory) {
@@ -63,9 +69,9 @@ func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
 		return err
 	}
 
-	// this sync stuff is so that both can be mounted simultaneously.
-	var fsmount, nsmount mount.Mount
-	var err1, err2 error
+	// this sync stuff is so that all can be mounted simultaneously.
+	var fsmount, nsmount, mfsmount mount.Mount
+	var err1, err2, err3 error
 
 	var wg sync.WaitGroup
 
@@ -83,6 +89,14 @@ func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
 		}()
 	}
 
+	if mfsdir != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mfsmount, err3 = fusemfs.Mount(node, mfsdir)
+		}()
+	}
+
 	wg.Wait()
 
 	if err1 != nil {
@@ -93,22 +107,33 @@ func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
 		log.Errorf("error mounting: %s", err2)
 	}
 
-	if err1 != nil || err2 != nil {
+	if err3 != nil {
+		log.Errorf("error mounting: %s", err3)
+	}
+
+	if err1 != nil || err2 != nil || err3 != nil {
 		if fsmount != nil {
 			fsmount.Unmount()
 		}
 		if nsmount != nil {
 			nsmount.Unmount()
 		}
+		if mfsmount != nil {
+			mfsmount.Unmount()
+		}
 
 		if err1 != nil {
 			return fmtFuseErr(err1, fsdir)
 		}
-		return fmtFuseErr(err2, nsdir)
+		if err2 != nil {
+			return fmtFuseErr(err2, nsdir)
+		}
+		return fmtFuseErr(err3, mfsdir)
 	}
 
 	// setup node state, so that it can be cancelled
 	node.Mounts.Ipfs = fsmount
 	node.Mounts.Ipns = nsmount
+	node.Mounts.Mfs = mfsmount
 	return nil
 }
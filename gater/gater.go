@@ -0,0 +1,274 @@
+// Package gater allows or denies the connections of the libp2p host by the
+// subnet and the autonomous system of the remote address, and by the ID of
+// the remote peer.
+//
+// The rules are evaluated in order, the first matching a connection
+// deciding. The subnets denied before any rule allowing something are
+// returned by Filters, to be refused by the swarm before dialing and before
+// the handshake of the inbound connections. The other rules need the remote
+// peer, they are checked by Muxer once the handshake authenticated it,
+// before the swarm gets the connection, whether it was dialed or accepted.
+// The relayed connections are checked by Enforce, and closed before the
+// swarm accepts streams on them.
+package gater
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	geoip "github.com/ipfs/go-ipfs/geoip"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	mamask "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+)
+
+var log = logging.Logger("gater")
+
+// The actions of the rules.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Decision is the outcome of checking a connection.
+type Decision struct {
+	Allowed bool
+	// Rule is the index of the rule deciding, -1 for the default action.
+	Rule int
+	// IP is the IP address of the remote address, nil when it has none.
+	IP net.IP `json:",omitempty"`
+	// ASN is the autonomous system of IP, 0 when unknown or when the
+	// rules have no ASNs.
+	ASN int `json:",omitempty"`
+}
+
+type rule struct {
+	allow   bool
+	subnets []*net.IPNet
+	asns    map[int]bool
+	peers   map[peer.ID]bool
+}
+
+// match tells whether the rule matches the connection to p from ip, of the
+// autonomous system asn. The rules with peers don't match an unknown peer.
+func (r *rule) match(p peer.ID, ip net.IP, asn int) bool {
+	if r.subnets != nil {
+		if ip == nil || !containsIP(r.subnets, ip) {
+			return false
+		}
+	}
+	if r.asns != nil && !r.asns[asn] {
+		return false
+	}
+	if r.peers != nil && !r.peers[p] {
+		return false
+	}
+	return true
+}
+
+func containsIP(subnets []*net.IPNet, ip net.IP) bool {
+	for _, n := range subnets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Gater checks the connections against the rules of the config.
+type Gater struct {
+	rules []rule
+	allow bool
+	db    *geoip.DB
+
+	network inet.Network
+	notifee *inet.NotifyBundle
+
+	lk      sync.Mutex
+	blocked uint64
+}
+
+// New returns a Gater applying the rules of cfg, loading its GeoIP database.
+func New(cfg *extconfig.ConnGater) (*Gater, error) {
+	g := &Gater{allow: true}
+	switch cfg.Default {
+	case "", Allow:
+	case Deny:
+		g.allow = false
+	default:
+		return nil, fmt.Errorf("Swarm.ConnGater.Default: invalid action %q, expected %q or %q", cfg.Default, Allow, Deny)
+	}
+
+	needDB := false
+	for i, rc := range cfg.Rules {
+		r, err := parseRule(rc)
+		if err != nil {
+			return nil, fmt.Errorf("Swarm.ConnGater.Rules[%d]: %s", i, err)
+		}
+		needDB = needDB || r.asns != nil
+		g.rules = append(g.rules, r)
+	}
+
+	if needDB {
+		if cfg.GeoIPDatabase == "" {
+			return nil, fmt.Errorf("Swarm.ConnGater.GeoIPDatabase is required by the rules with ASNs")
+		}
+		db, err := geoip.Open(cfg.GeoIPDatabase)
+		if err != nil {
+			return nil, fmt.Errorf("loading the GeoIP database: %s", err)
+		}
+		g.db = db
+	}
+	return g, nil
+}
+
+func parseRule(rc extconfig.ConnGaterRule) (rule, error) {
+	var r rule
+	switch rc.Action {
+	case Allow:
+		r.allow = true
+	case Deny:
+	default:
+		return r, fmt.Errorf("invalid action %q, expected %q or %q", rc.Action, Allow, Deny)
+	}
+	if len(rc.Subnets) == 0 && len(rc.ASNs) == 0 && len(rc.Peers) == 0 {
+		return r, fmt.Errorf("a rule needs Subnets, ASNs or Peers, use Default to match every connection")
+	}
+
+	for _, s := range rc.Subnets {
+		n, err := ParseSubnet(s)
+		if err != nil {
+			return r, err
+		}
+		r.subnets = append(r.subnets, n)
+	}
+	if len(rc.ASNs) > 0 {
+		r.asns = make(map[int]bool, len(rc.ASNs))
+		for _, asn := range rc.ASNs {
+			r.asns[asn] = true
+		}
+	}
+	if len(rc.Peers) > 0 {
+		r.peers = make(map[peer.ID]bool, len(rc.Peers))
+		for _, s := range rc.Peers {
+			p, err := peer.IDB58Decode(s)
+			if err != nil {
+				return r, fmt.Errorf("invalid peer ID %q: %s", s, err)
+			}
+			r.peers[p] = true
+		}
+	}
+	return r, nil
+}
+
+// ParseSubnet parses a CIDR such as "10.0.0.0/8" or a multiaddr filter such
+// as "/ip4/10.0.0.0/ipcidr/8".
+func ParseSubnet(s string) (*net.IPNet, error) {
+	if strings.HasPrefix(s, "/") {
+		return mamask.NewMask(s)
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %q", s)
+	}
+	return n, nil
+}
+
+// Check decides whether the connection to p over addr is allowed. p may be
+// empty when the peer is unknown, the rules with peers not matching then.
+// For relayed addresses, the IP address checked is the one of the relay.
+func (g *Gater) Check(p peer.ID, addr ma.Multiaddr) Decision {
+	d := Decision{Rule: -1, IP: AddrIP(addr)}
+	if g.db != nil && d.IP != nil {
+		if info, ok := g.db.Lookup(d.IP); ok {
+			d.ASN = info.ASN
+		}
+	}
+
+	for i, r := range g.rules {
+		if r.match(p, d.IP, d.ASN) {
+			d.Allowed = r.allow
+			d.Rule = i
+			return d
+		}
+	}
+	d.Allowed = g.allow
+	return d
+}
+
+// AddrIP returns the IP address of addr, nil when it has none.
+func AddrIP(addr ma.Multiaddr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	for _, code := range []int{ma.P_IP4, ma.P_IP6} {
+		if s, err := addr.ValueForProtocol(code); err == nil {
+			return net.ParseIP(s)
+		}
+	}
+	return nil
+}
+
+// Filters returns the subnets denied whatever the peer and the autonomous
+// system: the ones of the rules denying subnets only, before any rule
+// allowing connections. They can be given to the swarm, to refuse them
+// before the connections are opened.
+func (g *Gater) Filters() []*net.IPNet {
+	var out []*net.IPNet
+	for _, r := range g.rules {
+		if r.allow {
+			break
+		}
+		if r.asns == nil && r.peers == nil {
+			out = append(out, r.subnets...)
+		}
+	}
+	return out
+}
+
+// Enforce closes the connections of network the rules deny, as the swarm
+// adds them. It catches the relayed connections, that Muxer can't check.
+func (g *Gater) Enforce(network inet.Network) {
+	g.network = network
+	g.notifee = &inet.NotifyBundle{ConnectedF: g.connected}
+	network.Notify(g.notifee)
+}
+
+// connected runs before the swarm starts accepting the streams of c, which
+// is closed right away when denied.
+func (g *Gater) connected(_ inet.Network, c inet.Conn) {
+	d := g.Check(c.RemotePeer(), c.RemoteMultiaddr())
+	if d.Allowed {
+		return
+	}
+	g.deny(c.RemotePeer(), c.RemoteMultiaddr(), d)
+	c.Close()
+}
+
+func (g *Gater) deny(p peer.ID, addr ma.Multiaddr, d Decision) {
+	g.lk.Lock()
+	g.blocked++
+	g.lk.Unlock()
+	log.Debugf("denied connection to %s over %s by rule %d", p.Pretty(), addr, d.Rule)
+}
+
+// Blocked returns the number of connections refused or closed by the rules.
+func (g *Gater) Blocked() uint64 {
+	g.lk.Lock()
+	defer g.lk.Unlock()
+	return g.blocked
+}
+
+// Close stops enforcing the rules.
+func (g *Gater) Close() error {
+	if g.network != nil {
+		g.network.StopNotify(g.notifee)
+	}
+	return nil
+}
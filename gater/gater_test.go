@@ -0,0 +1,94 @@
+package gater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+const (
+	trustedPeer = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+	otherPeer   = "QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gater")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "ip2asn.tsv")
+	if err := ioutil.WriteFile(db, []byte("1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := New(&extconfig.ConnGater{
+		Rules: []extconfig.ConnGaterRule{
+			{Action: Deny, Subnets: []string{"/ip4/192.168.0.0/ipcidr/16"}},
+			{Action: Allow, Peers: []string{trustedPeer}},
+			{Action: Deny, Subnets: []string{"10.0.0.0/8"}},
+			{Action: Allow, ASNs: []int{13335}},
+		},
+		Default:       Deny,
+		GeoIPDatabase: db,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted, err := peer.IDB58Decode(trustedPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := peer.IDB58Decode(otherPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		peer    peer.ID
+		addr    string
+		allowed bool
+		rule    int
+	}{
+		{trusted, "/ip4/192.168.1.1/tcp/4001", false, 0},
+		{trusted, "/ip4/10.1.2.3/tcp/4001", true, 1},
+		{other, "/ip4/10.1.2.3/tcp/4001", false, 2},
+		{"", "/ip4/10.1.2.3/tcp/4001", false, 2},
+		{other, "/ip4/1.0.0.1/udp/4001/quic", true, 3},
+		{other, "/ip4/8.8.8.8/tcp/4001", false, -1},
+		{other, "/ip6/::1/tcp/4001", false, -1},
+	} {
+		d := g.Check(c.peer, ma.StringCast(c.addr))
+		if d.Allowed != c.allowed || d.Rule != c.rule {
+			t.Errorf("%s over %s: expected allowed=%t by rule %d, got %+v", c.peer.Pretty(), c.addr, c.allowed, c.rule, d)
+		}
+	}
+
+	// only the first subnet is denied whatever the peer
+	filters := g.Filters()
+	if len(filters) != 1 || filters[0].String() != "192.168.0.0/16" {
+		t.Fatalf("expected the filters to be 192.168.0.0/16, got %v", filters)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, cfg := range []*extconfig.ConnGater{
+		{Default: "reject"},
+		{Rules: []extconfig.ConnGaterRule{{Action: "block", Subnets: []string{"10.0.0.0/8"}}}},
+		{Rules: []extconfig.ConnGaterRule{{Action: Deny}}},
+		{Rules: []extconfig.ConnGaterRule{{Action: Deny, Subnets: []string{"10.0.0.0"}}}},
+		{Rules: []extconfig.ConnGaterRule{{Action: Deny, Peers: []string{"not a peer"}}}},
+		{Rules: []extconfig.ConnGaterRule{{Action: Deny, ASNs: []int{13335}}}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected %+v to be invalid", cfg)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package gater
+
+import (
+	"fmt"
+	"net"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	smux "gx/ipfs/QmY9JXR3FupnYAYJWK9aMr9bCpqWKcToQ1tz8DVGTrHpHw/go-stream-muxer"
+)
+
+// Muxer returns tpt refusing the connections the rules deny. The muxers
+// run over the secure connections, once the remote peer is authenticated:
+// the connections are checked there, whether they were dialed or accepted,
+// before the swarm gets them, so a denied dial fails and a denied inbound
+// connection is dropped.
+//
+// The connections without an IP address, the relayed ones, are left to
+// Enforce, which checks them with the address of the relay.
+func (g *Gater) Muxer(tpt smux.Transport) smux.Transport {
+	return &gatedTransport{Transport: tpt, gater: g}
+}
+
+type gatedTransport struct {
+	smux.Transport
+	gater *Gater
+}
+
+func (t *gatedTransport) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	pc, ok := c.(interface{ RemotePeer() peer.ID })
+	if !ok {
+		return t.Transport.NewConn(c, isServer)
+	}
+	addr, err := manet.FromNetAddr(c.RemoteAddr())
+	if err != nil || AddrIP(addr) == nil {
+		return t.Transport.NewConn(c, isServer)
+	}
+
+	p := pc.RemotePeer()
+	if d := t.gater.Check(p, addr); !d.Allowed {
+		t.gater.deny(p, addr, d)
+		c.Close()
+		return nil, fmt.Errorf("connection to %s over %s denied by rule %d", p.Pretty(), addr, d.Rule)
+	}
+	return t.Transport.NewConn(c, isServer)
+}
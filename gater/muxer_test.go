@@ -0,0 +1,74 @@
+package gater
+
+import (
+	"net"
+	"testing"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	smux "gx/ipfs/QmY9JXR3FupnYAYJWK9aMr9bCpqWKcToQ1tz8DVGTrHpHw/go-stream-muxer"
+)
+
+// secureConn looks like a secure connection with peer over a TCP address.
+type secureConn struct {
+	net.Conn
+	peer peer.ID
+	addr net.Addr
+}
+
+func (c *secureConn) RemotePeer() peer.ID  { return c.peer }
+func (c *secureConn) RemoteAddr() net.Addr { return c.addr }
+
+// countingTransport counts the connections it muxes.
+type countingTransport struct {
+	conns int
+}
+
+func (t *countingTransport) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	t.conns++
+	return nil, nil
+}
+
+func TestMuxer(t *testing.T) {
+	g, err := New(&extconfig.ConnGater{
+		Rules: []extconfig.ConnGaterRule{
+			{Action: Allow, Peers: []string{trustedPeer}},
+		},
+		Default: Deny,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := peer.IDB58Decode(trustedPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := peer.IDB58Decode(otherPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner := &countingTransport{}
+	tpt := g.Muxer(inner)
+	addr := &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 4001}
+	for _, isServer := range []bool{false, true} {
+		a, b := net.Pipe()
+		defer b.Close()
+		if _, err := tpt.NewConn(&secureConn{Conn: a, peer: trusted, addr: addr}, isServer); err != nil {
+			t.Fatalf("expected the trusted peer to be allowed: %s", err)
+		}
+
+		a, b = net.Pipe()
+		defer b.Close()
+		if _, err := tpt.NewConn(&secureConn{Conn: a, peer: other, addr: addr}, isServer); err == nil {
+			t.Fatal("expected the other peer to be denied")
+		}
+	}
+	if inner.conns != 2 {
+		t.Fatalf("expected the allowed connections only to be muxed, got %d", inner.conns)
+	}
+	if g.Blocked() != 2 {
+		t.Fatalf("expected 2 blocked connections, got %d", g.Blocked())
+	}
+}
@@ -0,0 +1,130 @@
+// Package geoip looks up the country and autonomous system of IP addresses
+// in an offline database.
+//
+// The database is a tab separated file in the format of the ip2asn
+// databases (https://iptoasn.com), one address range per line:
+//
+//	range_start	range_end	AS_number	country_code	AS_description
+//
+// IPv4 and IPv6 ranges can be mixed in the same file.
+package geoip
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Info is what the database knows about an address.
+type Info struct {
+	// Country is the ISO 3166 code of the country, "None" or empty when
+	// unknown.
+	Country string
+	// ASN is the number of the autonomous system, 0 when unknown.
+	ASN int
+	// ASName is the description of the autonomous system.
+	ASName string
+}
+
+type ipRange struct {
+	start, end net.IP
+	info       Info
+}
+
+// DB is a loaded database.
+type DB struct {
+	ranges []ipRange
+}
+
+// Open loads the database at path, which may be gzipped.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return Read(r)
+}
+
+// Read loads a database.
+func Read(r io.Reader) (*DB, error) {
+	db := new(DB)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := scanner.Text()
+		if strings.TrimSpace(s) == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(s, "\t", 5)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: expected at least 4 fields, got %d", line, len(fields))
+		}
+		start := net.ParseIP(fields[0])
+		end := net.ParseIP(fields[1])
+		if start == nil || end == nil {
+			return nil, fmt.Errorf("line %d: invalid address range %s-%s", line, fields[0], fields[1])
+		}
+		asn, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid AS number %q", line, fields[2])
+		}
+
+		rg := ipRange{
+			start: start.To16(),
+			end:   end.To16(),
+			info:  Info{Country: fields[3], ASN: asn},
+		}
+		if len(fields) == 5 {
+			rg.info.ASName = fields[4]
+		}
+		db.ranges = append(db.ranges, rg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+	return db, nil
+}
+
+// Lookup returns the info of the range ip is in, and whether there is one.
+// The ranges of AS number 0 are the unrouted ones, and aren't reported.
+func (db *DB) Lookup(ip net.IP) (Info, bool) {
+	ip = ip.To16()
+	if ip == nil {
+		return Info{}, false
+	}
+
+	// the last range starting at or before ip
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 {
+		return Info{}, false
+	}
+	rg := db.ranges[i]
+	if bytes.Compare(ip, rg.end) > 0 || rg.info.ASN == 0 {
+		return Info{}, false
+	}
+	return rg.info, true
+}
@@ -0,0 +1,47 @@
+package geoip
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testDB = `1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+1.0.1.0	1.0.3.255	0	None	Not routed
+5.9.0.0	5.9.255.255	24940	DE	HETZNER-AS
+2a01:4f8::	2a01:4f8:ffff:ffff:ffff:ffff:ffff:ffff	24940	DE	HETZNER-AS
+`
+
+func TestLookup(t *testing.T) {
+	db, err := Read(strings.NewReader(testDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, exp := range map[string]int{
+		"1.0.0.1":          13335,
+		"1.0.2.1":          0,
+		"5.9.10.20":        24940,
+		"5.10.0.1":         0,
+		"0.0.0.1":          0,
+		"2a01:4f8:c17::1":  24940,
+		"2a01:4f9::1":      0,
+		"::ffff:5.9.10.20": 24940,
+	} {
+		info, ok := db.Lookup(net.ParseIP(ip))
+		if ok != (exp != 0) || info.ASN != exp {
+			t.Errorf("Lookup(%s): expected AS%d, got AS%d (found %t)", ip, exp, info.ASN, ok)
+		}
+	}
+
+	info, _ := db.Lookup(net.ParseIP("5.9.10.20"))
+	if info.Country != "DE" || info.ASName != "HETZNER-AS" {
+		t.Fatalf("unexpected info %+v", info)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	if _, err := Read(strings.NewReader("1.0.0.0\tfoo\t1\tUS\n")); err == nil {
+		t.Fatal("expected an invalid range to fail")
+	}
+}
@@ -0,0 +1,381 @@
+// Package holepunch upgrades relayed connections to direct ones with libp2p's
+// DCUtR protocol (direct connection upgrade through relay).
+//
+// When a peer behind a NAT accepts a connection through a circuit relay, it
+// opens a DCUtR stream over that connection: both sides exchange their
+// public addresses in CONNECT messages, the initiator measures the round
+// trip time and sends SYNC, and both then dial each other at the same time,
+// so that the outgoing packets of each side open a hole in its own NAT for
+// the incoming packets of the other.
+//
+// The relayed connection stays up until a direct dial got through. The swarm
+// reuses any connection it has to a peer when dialing it, so the hole is
+// punched with dials at the transport level, outside of the swarm; once one
+// succeeded, the initiator closes the relayed connection and dials the peer
+// again through the swarm on the address that worked.
+package holepunch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/holepunch/pb"
+	p2putil "github.com/ipfs/go-ipfs/internal/p2putil"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	swarm "gx/ipfs/QmeDpqUwwdye8ABKVMPXKuWwPVURFdqTqssbTUB39E2Nwd/go-libp2p-swarm"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+var log = logging.Logger("holepunch")
+
+// ProtocolID is the DCUtR protocol.
+const ProtocolID = protocol.ID("/libp2p/dcutr")
+
+// Timing parameters. Variables so tests can speed them up.
+var (
+	// streamTimeout bounds the address exchange.
+	streamTimeout = time.Minute
+	// dialTimeout bounds the direct dials.
+	dialTimeout = 15 * time.Second
+	// holdTime is how long the responder keeps its punching connection
+	// open, for the initiator to dial through the hole.
+	holdTime = 15 * time.Second
+	// retryInterval is the minimum time between two attempts with the same
+	// peer.
+	retryInterval = 10 * time.Minute
+)
+
+// Stats counts hole punching attempts.
+type Stats struct {
+	// Attempts is the number of direct dials attempted.
+	Attempts uint64
+	// Successes is the number of attempts whose direct dial got through.
+	Successes uint64
+	// Failures is the number of attempts whose direct dial did not.
+	Failures uint64
+}
+
+// probeFunc dials p on addrs outside of the swarm, returning the address
+// that answered and the connection made, which keeps the hole open until it
+// is closed.
+type probeFunc func(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) (ma.Multiaddr, io.Closer, error)
+
+// Service coordinates hole punches with peers connected through a relay.
+type Service struct {
+	host    host.Host
+	notifee *inet.NotifyBundle
+	ctx     context.Context
+	cancel  context.CancelFunc
+	probe   probeFunc
+
+	lk   sync.Mutex
+	last map[peer.ID]time.Time
+
+	attempts  uint64
+	successes uint64
+	failures  uint64
+}
+
+// NewService starts the hole punching service on the host, whose peerstore
+// must be wrapped by Peerstore.
+func NewService(h host.Host) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		host:   h,
+		ctx:    ctx,
+		cancel: cancel,
+		last:   make(map[peer.ID]time.Time),
+	}
+	s.probe = s.probeDirect
+
+	h.SetStreamHandler(ProtocolID, s.handleStream)
+	s.notifee = &inet.NotifyBundle{
+		ConnectedF: s.connected,
+	}
+	h.Network().Notify(s.notifee)
+	return s
+}
+
+// Close stops the service.
+func (s *Service) Close() error {
+	s.host.Network().StopNotify(s.notifee)
+	s.host.RemoveStreamHandler(ProtocolID)
+	s.cancel()
+	return nil
+}
+
+// Stats returns the attempt counters.
+func (s *Service) Stats() Stats {
+	return Stats{
+		Attempts:  atomic.LoadUint64(&s.attempts),
+		Successes: atomic.LoadUint64(&s.successes),
+		Failures:  atomic.LoadUint64(&s.failures),
+	}
+}
+
+// connected starts a hole punch when a peer reaches us through a relay. Only
+// the side that accepted the relayed connection initiates, that's the side
+// which is known to be unreachable.
+func (s *Service) connected(_ inet.Network, c inet.Conn) {
+	if !p2putil.IsRelayAddr(c.RemoteMultiaddr()) || c.Stat().Direction != inet.DirInbound {
+		return
+	}
+	go s.initiate(c.RemotePeer())
+}
+
+// begin records an attempt with p, returning false if one was made recently.
+func (s *Service) begin(p peer.ID) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if t, ok := s.last[p]; ok && time.Since(t) < retryInterval {
+		return false
+	}
+	s.last[p] = time.Now()
+	return true
+}
+
+func (s *Service) initiate(p peer.ID) {
+	if s.hasDirectConn(p) || !s.begin(p) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, streamTimeout)
+	defer cancel()
+
+	str, err := s.host.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		log.Debugf("failed to open hole punching stream to %s: %s", p.Pretty(), err)
+		return
+	}
+	defer str.Close()
+	str.SetDeadline(time.Now().Add(streamTimeout))
+	br := bufio.NewReader(str)
+
+	start := time.Now()
+	if err := p2putil.WriteMsg(str, s.connectMsg()); err != nil {
+		str.Reset()
+		return
+	}
+	addrs, err := readConnect(br)
+	if err != nil {
+		log.Debugf("hole punching with %s: %s", p.Pretty(), err)
+		str.Reset()
+		return
+	}
+	rtt := time.Since(start)
+
+	if err := p2putil.WriteMsg(str, &pb.HolePunch{Type: pb.HolePunch_SYNC.Enum()}); err != nil {
+		str.Reset()
+		return
+	}
+
+	// the SYNC message reaches the other side after about half a round
+	// trip, that's when it starts dialing.
+	select {
+	case <-time.After(rtt / 2):
+	case <-s.ctx.Done():
+		return
+	}
+	s.upgrade(p, addrs)
+}
+
+func (s *Service) handleStream(str inet.Stream) {
+	defer str.Close()
+	str.SetDeadline(time.Now().Add(streamTimeout))
+	br := bufio.NewReader(str)
+
+	p := str.Conn().RemotePeer()
+	if !p2putil.IsRelayAddr(str.Conn().RemoteMultiaddr()) || !s.begin(p) {
+		str.Reset()
+		return
+	}
+
+	addrs, err := readConnect(br)
+	if err != nil {
+		log.Debugf("hole punching with %s: %s", p.Pretty(), err)
+		str.Reset()
+		return
+	}
+	if err := p2putil.WriteMsg(str, s.connectMsg()); err != nil {
+		str.Reset()
+		return
+	}
+
+	var msg pb.HolePunch
+	if err := p2putil.ReadMsg(br, &msg); err != nil || msg.GetType() != pb.HolePunch_SYNC {
+		str.Reset()
+		return
+	}
+	s.punch(p, addrs)
+}
+
+// connectMsg returns the CONNECT message carrying our addresses.
+func (s *Service) connectMsg() *pb.HolePunch {
+	msg := &pb.HolePunch{Type: pb.HolePunch_CONNECT.Enum()}
+	for _, a := range s.host.Addrs() {
+		if !p2putil.IsRelayAddr(a) {
+			msg.ObsAddrs = append(msg.ObsAddrs, a.Bytes())
+		}
+	}
+	return msg
+}
+
+// readConnect reads a CONNECT message and returns its direct addresses.
+func readConnect(r *bufio.Reader) ([]ma.Multiaddr, error) {
+	var msg pb.HolePunch
+	if err := p2putil.ReadMsg(r, &msg); err != nil {
+		return nil, err
+	}
+	if msg.GetType() != pb.HolePunch_CONNECT {
+		return nil, fmt.Errorf("expected CONNECT message, got %s", msg.GetType())
+	}
+
+	var addrs []ma.Multiaddr
+	for _, b := range msg.GetObsAddrs() {
+		a, err := ma.NewMultiaddrBytes(b)
+		if err != nil {
+			log.Debugf("ignoring invalid address: %s", err)
+			continue
+		}
+		if !p2putil.IsRelayAddr(a) {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no direct addresses")
+	}
+	return addrs, nil
+}
+
+// punch is the side of the responder: it dials p to open the hole in its
+// NAT, and keeps the connection open while the initiator dials back through
+// the hole and replaces the relayed connection.
+func (s *Service) punch(p peer.ID, addrs []ma.Multiaddr) {
+	atomic.AddUint64(&s.attempts, 1)
+
+	ctx, cancel := context.WithTimeout(s.ctx, dialTimeout)
+	defer cancel()
+
+	_, c, err := s.probe(ctx, p, addrs)
+	if err != nil {
+		atomic.AddUint64(&s.failures, 1)
+		log.Debugf("hole punching to %s failed: %s", p.Pretty(), err)
+		return
+	}
+	atomic.AddUint64(&s.successes, 1)
+	defer c.Close()
+
+	select {
+	case <-time.After(holdTime):
+	case <-s.ctx.Done():
+	}
+}
+
+// upgrade is the side of the initiator: it dials p, and once a dial got
+// through, replaces the relayed connections to p by a direct one. The
+// relayed connections are left alone when no dial does.
+func (s *Service) upgrade(p peer.ID, addrs []ma.Multiaddr) {
+	atomic.AddUint64(&s.attempts, 1)
+
+	ctx, cancel := context.WithTimeout(s.ctx, dialTimeout)
+	defer cancel()
+
+	addr, c, err := s.probe(ctx, p, addrs)
+	if err != nil {
+		atomic.AddUint64(&s.failures, 1)
+		log.Debugf("hole punching to %s failed: %s", p.Pretty(), err)
+		return
+	}
+	// the hole stays open until the swarm connected through it
+	defer c.Close()
+
+	if err := s.replaceRelayed(ctx, p, addr); err != nil {
+		atomic.AddUint64(&s.failures, 1)
+		log.Debugf("connecting to %s through the hole failed: %s", p.Pretty(), err)
+		return
+	}
+	atomic.AddUint64(&s.successes, 1)
+	log.Debugf("hole punched to %s", p.Pretty())
+}
+
+// replaceRelayed closes the connections to p, relayed or made by the probe
+// of the responder, and has the swarm dial p again on addr only: the
+// peerstore answers addr for p for the time of the dial, the concurrent
+// dials to p joining this one. p is dialed through the relays again if that
+// fails.
+func (s *Service) replaceRelayed(ctx context.Context, p peer.ID, addr ma.Multiaddr) error {
+	ps, ok := s.host.Peerstore().(*dialPeerstore)
+	if !ok {
+		return errors.New("the peerstore of the host isn't wrapped by Peerstore")
+	}
+
+	var relayed []ma.Multiaddr
+	for _, c := range s.host.Network().ConnsToPeer(p) {
+		if p2putil.IsRelayAddr(c.RemoteMultiaddr()) {
+			relayed = append(relayed, c.RemoteMultiaddr())
+		}
+	}
+	s.host.Network().ClosePeer(p)
+
+	done := ps.dialOnly(p, []ma.Multiaddr{addr})
+	_, err := s.host.Network().DialPeer(ctx, p)
+	done()
+	if err == nil && s.hasDirectConn(p) {
+		return nil
+	}
+	if err == nil {
+		err = errors.New("no direct connection")
+	}
+
+	if len(relayed) > 0 && s.host.Network().Connectedness(p) != inet.Connected {
+		if err := s.host.Connect(ctx, pstore.PeerInfo{ID: p, Addrs: relayed}); err != nil {
+			log.Debugf("failed to reconnect to %s through relay: %s", p.Pretty(), err)
+		}
+	}
+	return err
+}
+
+// probeDirect dials p on addrs with the transports of the swarm, bypassing
+// the connections the swarm already has to p.
+func (s *Service) probeDirect(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) (ma.Multiaddr, io.Closer, error) {
+	swrm, ok := s.host.Network().(*swarm.Swarm)
+	if !ok {
+		return nil, nil, errors.New("the network cannot dial outside of the swarm")
+	}
+
+	err := errors.New("no transport can dial the addresses")
+	for _, a := range addrs {
+		tpt := swrm.TransportForDialing(a)
+		if tpt == nil {
+			continue
+		}
+		c, derr := tpt.Dial(ctx, a, p)
+		if derr == nil {
+			return a, c, nil
+		}
+		err = derr
+	}
+	return nil, nil, err
+}
+
+func (s *Service) hasDirectConn(p peer.ID) bool {
+	for _, c := range s.host.Network().ConnsToPeer(p) {
+		if !p2putil.IsRelayAddr(c.RemoteMultiaddr()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package holepunch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/holepunch/pb"
+	p2putil "github.com/ipfs/go-ipfs/internal/p2putil"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	tu "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
+	mocknet "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/net/mock"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	pstoremem "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore/pstoremem"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+func connectMsg(addrs ...string) *pb.HolePunch {
+	msg := &pb.HolePunch{Type: pb.HolePunch_CONNECT.Enum()}
+	for _, a := range addrs {
+		msg.ObsAddrs = append(msg.ObsAddrs, ma.StringCast(a).Bytes())
+	}
+	return msg
+}
+
+func TestReadConnect(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p2putil.WriteMsg(buf, connectMsg(
+		"/ip4/1.2.3.4/tcp/4001",
+		"/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit",
+		"/ip4/1.2.3.4/udp/4001/quic",
+	))
+
+	addrs, err := readConnect(bufio.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("expected relay address to be dropped, got %v", addrs)
+	}
+}
+
+func TestReadConnectErrors(t *testing.T) {
+	for _, msg := range []*pb.HolePunch{
+		{Type: pb.HolePunch_SYNC.Enum()},
+		{Type: pb.HolePunch_CONNECT.Enum(), ObsAddrs: [][]byte{[]byte("not an address")}},
+		connectMsg(),
+	} {
+		buf := new(bytes.Buffer)
+		p2putil.WriteMsg(buf, msg)
+		if _, err := readConnect(bufio.NewReader(buf)); err == nil {
+			t.Fatalf("expected error for %v", msg)
+		}
+	}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// relayedPeers returns two hosts connected through what looks like a relay:
+// the connection of b is inbound, with a p2p-circuit address, so that b is
+// the initiator.
+func relayedPeers(t *testing.T, ctx context.Context) (a, b host.Host) {
+	mn := mocknet.New(ctx)
+	relayed := ma.StringCast("/ip4/10.0.0.1/tcp/4001/ipfs/" + tu.RandPeerIDFatal(t).Pretty() + "/p2p-circuit")
+
+	var hosts []host.Host
+	for i := 0; i < 2; i++ {
+		sk, pk, err := tu.RandTestKeyPair(512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := peer.IDFromPublicKey(pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps := Peerstore(pstoremem.NewPeerstore())
+		ps.AddPrivKey(id, sk)
+		ps.AddPubKey(id, pk)
+		ps.AddAddr(id, relayed, pstore.PermanentAddrTTL)
+		h, err := mn.AddPeerWithPeerstore(id, ps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, h)
+	}
+	a, b = hosts[0], hosts[1]
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mn.ConnectPeers(a.ID(), b.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the direct addresses are announced once the relayed connection is up,
+	// which keeps its p2p-circuit address
+	a.Peerstore().AddAddr(a.ID(), ma.StringCast("/ip4/1.1.1.1/tcp/4001"), pstore.PermanentAddrTTL)
+	b.Peerstore().AddAddr(b.ID(), ma.StringCast("/ip4/2.2.2.2/tcp/4001"), pstore.PermanentAddrTTL)
+	return a, b
+}
+
+func TestHolePunch(t *testing.T) {
+	holdTime = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := relayedPeers(t, ctx)
+	sa, sb := NewService(a), NewService(b)
+	defer sa.Close()
+	defer sb.Close()
+
+	punched := make(chan []ma.Multiaddr, 1)
+	sa.probe = func(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) (ma.Multiaddr, io.Closer, error) {
+		if a.Network().Connectedness(p) != inet.Connected {
+			t.Error("expected the relayed connection to be up while the responder dials")
+		}
+		punched <- addrs
+		return addrs[0], nopCloser{}, nil
+	}
+	sb.probe = func(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) (ma.Multiaddr, io.Closer, error) {
+		if b.Network().Connectedness(p) != inet.Connected {
+			t.Error("expected the relayed connection to be up while the initiator dials")
+		}
+		if len(addrs) != 1 || addrs[0].String() != "/ip4/1.1.1.1/tcp/4001" {
+			t.Errorf("expected the direct address of the responder, got %v", addrs)
+		}
+		// the next connections to a are made on its direct address
+		a.Peerstore().ClearAddrs(a.ID())
+		a.Peerstore().AddAddr(a.ID(), addrs[0], pstore.PermanentAddrTTL)
+		return addrs[0], nopCloser{}, nil
+	}
+
+	sb.initiate(a.ID())
+
+	select {
+	case addrs := <-punched:
+		if len(addrs) != 1 || addrs[0].String() != "/ip4/2.2.2.2/tcp/4001" {
+			t.Errorf("expected the direct address of the initiator, got %v", addrs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the responder did not dial")
+	}
+
+	if st := sb.Stats(); st.Attempts != 1 || st.Successes != 1 {
+		t.Fatalf("expected a successful attempt, got %+v", st)
+	}
+	for _, c := range b.Network().ConnsToPeer(a.ID()) {
+		if p2putil.IsRelayAddr(c.RemoteMultiaddr()) {
+			t.Fatal("expected the relayed connection to be replaced")
+		}
+	}
+	if !sb.hasDirectConn(a.ID()) {
+		t.Fatal("expected a direct connection")
+	}
+}
+
+func TestHolePunchFailure(t *testing.T) {
+	holdTime = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := relayedPeers(t, ctx)
+	sa, sb := NewService(a), NewService(b)
+	defer sa.Close()
+	defer sb.Close()
+
+	failed := func(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) (ma.Multiaddr, io.Closer, error) {
+		return nil, nil, errors.New("timed out")
+	}
+	sa.probe, sb.probe = failed, failed
+
+	sb.initiate(a.ID())
+
+	if st := sb.Stats(); st.Attempts != 1 || st.Failures != 1 {
+		t.Fatalf("expected a failed attempt, got %+v", st)
+	}
+	conns := b.Network().ConnsToPeer(a.ID())
+	if len(conns) != 1 || !p2putil.IsRelayAddr(conns[0].RemoteMultiaddr()) {
+		t.Fatalf("expected the relayed connection to be kept, got %v", conns)
+	}
+}
+
+func TestDialPeerstore(t *testing.T) {
+	p := tu.RandPeerIDFatal(t)
+	stored := ma.StringCast("/ip4/1.1.1.1/tcp/4001")
+	punched := ma.StringCast("/ip4/1.1.1.1/tcp/5001")
+
+	ps := Peerstore(pstoremem.NewPeerstore())
+	ps.AddAddr(p, stored, pstore.PermanentAddrTTL)
+
+	done := ps.(*dialPeerstore).dialOnly(p, []ma.Multiaddr{punched})
+	if addrs := ps.Addrs(p); len(addrs) != 1 || !addrs[0].Equal(punched) {
+		t.Fatalf("expected the punched address only, got %v", addrs)
+	}
+	done()
+	if addrs := ps.Addrs(p); len(addrs) != 1 || !addrs[0].Equal(stored) {
+		t.Fatalf("expected the stored address, got %v", addrs)
+	}
+}
@@ -0,0 +1,52 @@
+package holepunch
+
+import (
+	"sync"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+// Peerstore wraps ps, the peerstore of the host given to NewService, so
+// that the service can have the swarm dial a peer on the address it punched
+// a hole to, without changing the addresses stored for the peer.
+func Peerstore(ps pstore.Peerstore) pstore.Peerstore {
+	return &dialPeerstore{
+		Peerstore: ps,
+		dials:     make(map[peer.ID][]ma.Multiaddr),
+	}
+}
+
+// dialPeerstore answers the addresses of the peers being dialed on the
+// punched addresses with those addresses only. The stored addresses and
+// their TTLs are left alone.
+type dialPeerstore struct {
+	pstore.Peerstore
+
+	lk    sync.RWMutex
+	dials map[peer.ID][]ma.Multiaddr
+}
+
+func (ps *dialPeerstore) Addrs(p peer.ID) []ma.Multiaddr {
+	ps.lk.RLock()
+	addrs, ok := ps.dials[p]
+	ps.lk.RUnlock()
+	if ok {
+		return addrs
+	}
+	return ps.Peerstore.Addrs(p)
+}
+
+// dialOnly makes p answer addrs only, until the returned function is called.
+func (ps *dialPeerstore) dialOnly(p peer.ID, addrs []ma.Multiaddr) func() {
+	ps.lk.Lock()
+	ps.dials[p] = addrs
+	ps.lk.Unlock()
+
+	return func() {
+		ps.lk.Lock()
+		delete(ps.dials, p)
+		ps.lk.Unlock()
+	}
+}
@@ -0,0 +1,73 @@
+// Package p2putil holds the helpers shared by the libp2p protocols the node
+// implements itself: the framing of their protobuf messages and the
+// classification of the addresses of their peers.
+package p2putil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	circuit "gx/ipfs/QmWX6RySJ3yAYmfjLSw1LtRZnDh5oVeA9kM3scNQJkysqa/go-libp2p-circuit"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+// MaxMessageSize is the size of the largest message ReadMsg accepts.
+const MaxMessageSize = 4096
+
+// ErrMessageTooLarge is returned by ReadMsg for messages larger than
+// MaxMessageSize.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// Message is a protobuf message.
+type Message interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// ReadMsg reads a varint length prefixed message.
+func ReadMsg(r *bufio.Reader, m Message) error {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if l > MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return m.Unmarshal(buf)
+}
+
+// WriteMsg writes a varint length prefixed message.
+func WriteMsg(w io.Writer, m Message) error {
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, binary.MaxVarintLen64+len(b))
+	n := binary.PutUvarint(buf, uint64(len(b)))
+	n += copy(buf[n:], b)
+	_, err = w.Write(buf[:n])
+	return err
+}
+
+// IsRelayAddr returns whether a goes through a circuit relay.
+func IsRelayAddr(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(circuit.P_CIRCUIT)
+	return err == nil
+}
+
+// IPOf returns the IP of a, or "" if a is not an IP address.
+func IPOf(a ma.Multiaddr) string {
+	if v, err := a.ValueForProtocol(ma.P_IP4); err == nil {
+		return v
+	}
+	if v, err := a.ValueForProtocol(ma.P_IP6); err == nil {
+		return v
+	}
+	return ""
+}
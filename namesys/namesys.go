@@ -64,6 +64,28 @@ func (ns *mpns) Resolve(ctx context.Context, name string, options ...opts.Resolv
 	return resolve(ctx, ns, name, opts.ProcessOpts(options), "/ipns/")
 }
 
+// The kinds of names, resolved by different resolvers.
+const (
+	KindIPNS     = "ipns"
+	KindDNSLink  = "dnslink"
+	KindProquint = "proquint"
+)
+
+// NameKind returns the kind of the name key, the first segment of an /ipns/
+// path:
+// 1. if it is a multihash, it is resolved through "ipns".
+// 2. if it is a domain name, it is resolved through "dnslink"
+// 3. otherwise it is resolved through the "proquint" resolver
+func NameKind(key string) string {
+	if _, err := mh.FromB58String(key); err == nil {
+		return KindIPNS
+	}
+	if isd.IsDomain(key) {
+		return KindDNSLink
+	}
+	return KindProquint
+}
+
 // resolveOnce implements resolver.
 func (ns *mpns) resolveOnce(ctx context.Context, name string, options *opts.ResolveOpts) (path.Path, time.Duration, error) {
 	if !strings.HasPrefix(name, "/ipns/") {
@@ -80,16 +102,13 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string, options *opts.Reso
 	p, ok := ns.cacheGet(key)
 	var err error
 	if !ok {
-		// Resolver selection:
-		// 1. if it is a multihash resolve through "ipns".
-		// 2. if it is a domain name, resolve through "dns"
-		// 3. otherwise resolve through the "proquint" resolver
 		var res resolver
-		if _, err := mh.FromB58String(key); err == nil {
+		switch NameKind(key) {
+		case KindIPNS:
 			res = ns.ipnsResolver
-		} else if isd.IsDomain(key) {
+		case KindDNSLink:
 			res = ns.dnsResolver
-		} else {
+		default:
 			res = ns.proquintResolver
 		}
 
@@ -105,3 +105,15 @@ func TestPublishWithCache0(t *testing.T) {
 	}
 	nsys.Publish(context.Background(), priv, p)
 }
+
+func TestNameKind(t *testing.T) {
+	for key, kind := range map[string]string{
+		"QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy": KindIPNS,
+		"ipfs.io":     KindDNSLink,
+		"dabij-babij": KindProquint,
+	} {
+		if k := NameKind(key); k != kind {
+			t.Errorf("expected %s to be a %s name, got %s", key, kind, k)
+		}
+	}
+}
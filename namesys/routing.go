@@ -62,15 +62,53 @@ func (r *IpnsResolver) resolveOnce(ctx context.Context, name string, options *op
 		return "", 0, err
 	}
 
+	_, entry, err := r.record(ctx, pid, options)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var p path.Path
+	// check for old style record:
+	if valh, err := mh.Cast(entry.GetValue()); err == nil {
+		// Its an old style multihash record
+		log.Debugf("encountered CIDv0 ipns entry: %s", valh)
+		p = path.FromCid(cid.NewCidV0(valh))
+	} else {
+		// Not a multihash, probably a new record
+		p, err = path.ParsePath(string(entry.GetValue()))
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	ttl, err := RecordTTL(entry)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return p, ttl, nil
+}
+
+// Record returns the IPNS record of the peer ID name, as published and
+// parsed, after verifying its signature.
+func (r *IpnsResolver) Record(ctx context.Context, name string, options ...opts.ResolveOpt) ([]byte, *pb.IpnsEntry, error) {
+	pid, err := peer.IDB58Decode(strings.TrimPrefix(name, "/ipns/"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.record(ctx, pid, opts.ProcessOpts(options))
+}
+
+func (r *IpnsResolver) record(ctx context.Context, pid peer.ID, options *opts.ResolveOpts) ([]byte, *pb.IpnsEntry, error) {
 	// Name should be the hash of a public key retrievable from ipfs.
 	// We retrieve the public key here to make certain that it's in the peer
 	// store before calling GetValue() on the DHT - the DHT will call the
 	// ipns validator, which in turn will get the public key from the peer
 	// store to verify the record signature
-	_, err = routing.GetPublicKey(r.routing, ctx, pid)
+	_, err := routing.GetPublicKey(r.routing, ctx, pid)
 	if err != nil {
-		log.Debugf("RoutingResolver: could not retrieve public key %s: %s\n", name, err)
-		return "", 0, err
+		log.Debugf("RoutingResolver: could not retrieve public key %s: %s\n", pid.Pretty(), err)
+		return nil, nil, err
 	}
 
 	// Use the routing system to get the name.
@@ -79,31 +117,22 @@ func (r *IpnsResolver) resolveOnce(ctx context.Context, name string, options *op
 	ipnsKey := ipns.RecordKey(pid)
 	val, err := r.routing.GetValue(ctx, ipnsKey, dht.Quorum(int(options.DhtRecordCount)))
 	if err != nil {
-		log.Debugf("RoutingResolver: dht get for name %s failed: %s", name, err)
-		return "", 0, err
+		log.Debugf("RoutingResolver: dht get for name %s failed: %s", pid.Pretty(), err)
+		return nil, nil, err
 	}
 
 	entry := new(pb.IpnsEntry)
 	err = proto.Unmarshal(val, entry)
 	if err != nil {
-		log.Debugf("RoutingResolver: could not unmarshal value for name %s: %s", name, err)
-		return "", 0, err
-	}
-
-	var p path.Path
-	// check for old style record:
-	if valh, err := mh.Cast(entry.GetValue()); err == nil {
-		// Its an old style multihash record
-		log.Debugf("encountered CIDv0 ipns entry: %s", valh)
-		p = path.FromCid(cid.NewCidV0(valh))
-	} else {
-		// Not a multihash, probably a new record
-		p, err = path.ParsePath(string(entry.GetValue()))
-		if err != nil {
-			return "", 0, err
-		}
+		log.Debugf("RoutingResolver: could not unmarshal value for name %s: %s", pid.Pretty(), err)
+		return nil, nil, err
 	}
+	return val, entry, nil
+}
 
+// RecordTTL returns how long the value of entry can be cached: its TTL, or
+// less when it expires before.
+func RecordTTL(entry *pb.IpnsEntry) (time.Duration, error) {
 	ttl := DefaultResolverCacheTTL
 	if entry.Ttl != nil {
 		ttl = time.Duration(*entry.Ttl)
@@ -121,8 +150,7 @@ func (r *IpnsResolver) resolveOnce(ctx context.Context, name string, options *op
 		}
 	default:
 		log.Errorf("encountered error when parsing EOL: %s", err)
-		return "", 0, err
+		return 0, err
 	}
-
-	return p, ttl, nil
+	return ttl, nil
 }
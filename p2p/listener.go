@@ -4,6 +4,7 @@ import (
 	"errors"
 	"sync"
 
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
 	net "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
 	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
@@ -16,12 +17,48 @@ type Listener interface {
 	ListenAddress() ma.Multiaddr
 	TargetAddress() ma.Multiaddr
 
+	// AllowedPeers returns the peers allowed to connect to the listener,
+	// or nil if any peer is
+	AllowedPeers() []peer.ID
+
+	// LastError returns the error of the last connection the listener
+	// failed to forward, or nil if the last one was forwarded
+	LastError() error
+
 	key() string
 
 	// close closes the listener. Does not affect child streams
 	close()
 }
 
+// health tracks whether a listener manages to forward its connections
+type health struct {
+	lk  sync.Mutex
+	err error
+}
+
+func (h *health) update(err error) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	h.err = err
+}
+
+func (h *health) LastError() error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return h.err
+}
+
+// FailedListener is a listener declared in the config that could not be
+// created
+type FailedListener struct {
+	Protocol      protocol.ID
+	ListenAddress string
+	TargetAddress string
+	AllowedPeers  []string
+	Err           error
+}
+
 // Listeners manages a group of Listener implementations,
 // checking for conflicts and optionally dispatching connections
 type Listeners struct {
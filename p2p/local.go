@@ -23,6 +23,8 @@ type localListener struct {
 	peer  peer.ID
 
 	listener manet.Listener
+
+	health
 }
 
 // ForwardLocal creates new P2P stream to a remote listener
@@ -37,7 +39,12 @@ func (p2p *P2P) ForwardLocal(ctx context.Context, peer peer.ID, proto protocol.I
 		peer:  peer,
 	}
 
-	maListener, err := manet.Listen(listener.laddr)
+	listen := manet.Listen
+	if isUDP(bindAddr) {
+		listen = listenDatagram
+	}
+
+	maListener, err := listen(listener.laddr)
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +83,7 @@ func (l *localListener) acceptConns() {
 
 func (l *localListener) setupStream(local manet.Conn) {
 	remote, err := l.dial(l.ctx)
+	l.update(err)
 	if err != nil {
 		local.Close()
 		log.Warningf("failed to dial to remote %s/%s", l.peer.Pretty(), l.proto)
@@ -118,6 +126,11 @@ func (l *localListener) TargetAddress() ma.Multiaddr {
 	return addr
 }
 
+// AllowedPeers returns nil, any local connection is forwarded
+func (l *localListener) AllowedPeers() []peer.ID {
+	return nil
+}
+
 func (l *localListener) key() string {
 	return l.ListenAddress().String()
 }
@@ -15,6 +15,10 @@ type P2P struct {
 	ListenersP2P   *Listeners
 	Streams        *StreamRegistry
 
+	// Failed lists the listeners of the config that could not be created
+	// when the node started
+	Failed []FailedListener
+
 	identity  peer.ID
 	peerHost  p2phost.Host
 	peerstore pstore.Peerstore
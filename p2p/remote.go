@@ -3,6 +3,7 @@ package p2p
 import (
 	"context"
 
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
 	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
 	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
 	net "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
@@ -20,15 +21,22 @@ type remoteListener struct {
 
 	// Address to proxy the incoming connections to
 	addr ma.Multiaddr
+
+	// Peers allowed to connect, any peer is if empty
+	allowed []peer.ID
+
+	health
 }
 
-// ForwardRemote creates new p2p listener
-func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr) (Listener, error) {
+// ForwardRemote creates new p2p listener. If allowed isn't empty, only the
+// streams of the allowed peers are proxied.
+func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, allowed []peer.ID) (Listener, error) {
 	listener := &remoteListener{
 		p2p: p2p,
 
-		proto: proto,
-		addr:  addr,
+		proto:   proto,
+		addr:    addr,
+		allowed: allowed,
 	}
 
 	if err := p2p.ListenersP2P.Register(listener); err != nil {
@@ -39,13 +47,24 @@ func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Mu
 }
 
 func (l *remoteListener) handleStream(remote net.Stream) {
-	local, err := manet.Dial(l.addr)
-	if err != nil {
+	peer := remote.Conn().RemotePeer()
+	if !l.isAllowed(peer) {
+		log.Warningf("rejected %s stream from %s", l.proto, peer.Pretty())
 		remote.Reset()
 		return
 	}
 
-	peer := remote.Conn().RemotePeer()
+	dial := manet.Dial
+	if isUDP(l.addr) {
+		dial = dialDatagram
+	}
+
+	local, err := dial(l.addr)
+	l.update(err)
+	if err != nil {
+		remote.Reset()
+		return
+	}
 
 	peerMa, err := ma.NewMultiaddr(maPrefix + peer.Pretty())
 	if err != nil {
@@ -85,6 +104,23 @@ func (l *remoteListener) TargetAddress() ma.Multiaddr {
 	return l.addr
 }
 
+// AllowedPeers returns the peers allowed to connect, or nil if any peer is
+func (l *remoteListener) AllowedPeers() []peer.ID {
+	return l.allowed
+}
+
+func (l *remoteListener) isAllowed(p peer.ID) bool {
+	if len(l.allowed) == 0 {
+		return true
+	}
+	for _, a := range l.allowed {
+		if a == p {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *remoteListener) close() {}
 
 func (l *remoteListener) key() string {
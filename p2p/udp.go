@@ -0,0 +1,333 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	tec "gx/ipfs/QmWHgLqrghM9zw77nF6gdvT9ExQ2RB9pLxkd8sDHZf1rWb/go-temp-err-catcher"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+// maxDatagramSize is the size of the largest UDP datagram
+const maxDatagramSize = 1<<16 - 1
+
+// udpSessionTimeout is how long a forwarded UDP session is kept without
+// traffic in either direction
+var udpSessionTimeout = 2 * time.Minute
+
+var errListenerClosed = errors.New("listener closed")
+
+// isUDP returns whether addr is a UDP address
+func isUDP(addr ma.Multiaddr) bool {
+	for _, p := range addr.Protocols() {
+		if p.Code == ma.P_UDP {
+			return true
+		}
+	}
+	return false
+}
+
+// datagramConn carries the datagrams of a connection as a stream of frames,
+// prefixed with their big-endian uint16 length, so they can be copied to and
+// from libp2p streams without losing their boundaries.
+type datagramConn struct {
+	net.Conn
+
+	// the frame being read
+	rbuf []byte
+	rpos int
+
+	// the frames being written, the last one can be partial
+	wbuf []byte
+}
+
+func newDatagramConn(c net.Conn) (manet.Conn, error) {
+	return manet.WrapNetConn(&datagramConn{
+		Conn: c,
+		rbuf: make([]byte, 0, 2+maxDatagramSize),
+	})
+}
+
+// Read reads the next frame, one datagram at a time
+func (c *datagramConn) Read(p []byte) (int, error) {
+	if c.rpos == len(c.rbuf) {
+		buf := c.rbuf[:cap(c.rbuf)]
+		n, err := c.Conn.Read(buf[2:])
+		if err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint16(buf, uint16(n))
+		c.rbuf = buf[:2+n]
+		c.rpos = 0
+	}
+
+	n := copy(p, c.rbuf[c.rpos:])
+	c.rpos += n
+	return n, nil
+}
+
+// Write sends the datagram of each complete frame
+func (c *datagramConn) Write(p []byte) (int, error) {
+	c.wbuf = append(c.wbuf, p...)
+
+	off := 0
+	for len(c.wbuf)-off >= 2 {
+		size := int(binary.BigEndian.Uint16(c.wbuf[off:]))
+		if len(c.wbuf)-off < 2+size {
+			break
+		}
+		if _, err := c.Conn.Write(c.wbuf[off+2 : off+2+size]); err != nil {
+			return 0, err
+		}
+		off += 2 + size
+	}
+	c.wbuf = c.wbuf[:copy(c.wbuf, c.wbuf[off:])]
+
+	return len(p), nil
+}
+
+// dialDatagram dials a UDP address, framing its datagrams
+func dialDatagram(addr ma.Multiaddr) (manet.Conn, error) {
+	network, host, err := manet.DialArgs(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := net.Dial(network, host)
+	if err != nil {
+		return nil, err
+	}
+	return newDatagramConn(c)
+}
+
+// udpListener accepts a session for each address sending datagrams to a UDP
+// socket. Closing the listener closes its sessions, as they share the
+// socket.
+type udpListener struct {
+	pc    net.PacketConn
+	laddr ma.Multiaddr
+
+	lk       sync.Mutex
+	sessions map[string]*udpSession
+
+	accept    chan *udpSession
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// listenDatagram listens on a UDP address, framing the datagrams of its
+// sessions
+func listenDatagram(laddr ma.Multiaddr) (manet.Listener, error) {
+	network, host, err := manet.DialArgs(laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err := net.ListenPacket(network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &udpListener{
+		pc:       pc,
+		laddr:    laddr,
+		sessions: make(map[string]*udpSession),
+		accept:   make(chan *udpSession),
+		closed:   make(chan struct{}),
+	}
+	go l.readDatagrams()
+
+	return l, nil
+}
+
+func (l *udpListener) readDatagrams() {
+	defer l.Close()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.pc.ReadFrom(buf)
+		if err != nil {
+			if tec.ErrIsTemporary(err) {
+				continue
+			}
+			return
+		}
+
+		s, isNew := l.session(addr)
+		if s == nil {
+			return
+		}
+		if isNew {
+			select {
+			case l.accept <- s:
+			case <-l.closed:
+				return
+			}
+		}
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		s.deliver(data)
+	}
+}
+
+// session returns the session of addr, and whether it was just created. It
+// returns nil once the listener is closed.
+func (l *udpListener) session(addr net.Addr) (*udpSession, bool) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if l.sessions == nil {
+		return nil, false
+	}
+	if s, ok := l.sessions[addr.String()]; ok {
+		return s, false
+	}
+
+	s := &udpSession{
+		l:     l,
+		raddr: addr,
+		in:    make(chan []byte, 64),
+		done:  make(chan struct{}),
+	}
+	s.touch()
+	l.sessions[addr.String()] = s
+	return s, true
+}
+
+func (l *udpListener) Accept() (manet.Conn, error) {
+	select {
+	case s := <-l.accept:
+		return newDatagramConn(s)
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *udpListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.pc.Close()
+
+		l.lk.Lock()
+		sessions := l.sessions
+		l.sessions = nil
+		l.lk.Unlock()
+
+		for _, s := range sessions {
+			s.Close()
+		}
+	})
+	return err
+}
+
+func (l *udpListener) Multiaddr() ma.Multiaddr {
+	return l.laddr
+}
+
+func (l *udpListener) Addr() net.Addr {
+	return l.pc.LocalAddr()
+}
+
+// udpSession is the connection of a single remote address to a udpListener
+type udpSession struct {
+	l     *udpListener
+	raddr net.Addr
+
+	in        chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// the time of the last datagram, in unix nanoseconds
+	active int64
+}
+
+func (s *udpSession) touch() {
+	atomic.StoreInt64(&s.active, time.Now().UnixNano())
+}
+
+// deliver queues a datagram received from the remote address, the datagram
+// is dropped if the session isn't reading fast enough
+func (s *udpSession) deliver(data []byte) {
+	s.touch()
+	select {
+	case s.in <- data:
+	case <-s.done:
+	default:
+		log.Debugf("dropping datagram from %s", s.raddr)
+	}
+}
+
+// Read reads a datagram, the session is closed once it's idle for
+// udpSessionTimeout
+func (s *udpSession) Read(p []byte) (int, error) {
+	timer := time.NewTimer(udpSessionTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case data := <-s.in:
+			return copy(p, data), nil
+		case <-s.done:
+			return 0, io.EOF
+		case <-timer.C:
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(&s.active)))
+			if idle >= udpSessionTimeout {
+				s.Close()
+				return 0, io.EOF
+			}
+			timer.Reset(udpSessionTimeout - idle)
+		}
+	}
+}
+
+func (s *udpSession) Write(p []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, errListenerClosed
+	default:
+	}
+
+	s.touch()
+	return s.l.pc.WriteTo(p, s.raddr)
+}
+
+func (s *udpSession) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		s.l.lk.Lock()
+		if s.l.sessions[s.raddr.String()] == s {
+			delete(s.l.sessions, s.raddr.String())
+		}
+		s.l.lk.Unlock()
+	})
+	return nil
+}
+
+func (s *udpSession) LocalAddr() net.Addr {
+	return s.l.pc.LocalAddr()
+}
+
+func (s *udpSession) RemoteAddr() net.Addr {
+	return s.raddr
+}
+
+func (s *udpSession) SetDeadline(t time.Time) error {
+	return errors.New("deadlines are not supported by UDP sessions")
+}
+
+func (s *udpSession) SetReadDeadline(t time.Time) error {
+	return s.SetDeadline(t)
+}
+
+func (s *udpSession) SetWriteDeadline(t time.Time) error {
+	return s.SetDeadline(t)
+}
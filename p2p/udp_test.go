@@ -0,0 +1,129 @@
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+type recordConn struct {
+	net.Conn
+	writes [][]byte
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	c.writes = append(c.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestDatagramConnWrite(t *testing.T) {
+	rc := &recordConn{}
+	c := &datagramConn{Conn: rc}
+
+	frames := []byte{0, 3, 'a', 'b', 'c', 0, 0, 0, 2, 'd', 'e'}
+	// write the frames one byte at a time
+	for i := range frames {
+		if _, err := c.Write(frames[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := [][]byte{[]byte("abc"), {}, []byte("de")}
+	if len(rc.writes) != len(expected) {
+		t.Fatalf("expected %d datagrams, got %d", len(expected), len(rc.writes))
+	}
+	for i, w := range rc.writes {
+		if !bytes.Equal(w, expected[i]) {
+			t.Fatalf("datagram %d: expected %q, got %q", i, expected[i], w)
+		}
+	}
+	if len(c.wbuf) != 0 {
+		t.Fatal("expected the write buffer to be empty")
+	}
+}
+
+func TestUDPForwarding(t *testing.T) {
+	// the service echoes the datagrams
+	echo, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, addr, err := echo.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			echo.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	laddr, err := ma.NewMultiaddr("/ip4/127.0.0.1/udp/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := listenDatagram(laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	client, err := net.Dial("udp4", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	datagrams := []string{"first", "second datagram", "third"}
+	for _, d := range datagrams {
+		if _, err := client.Write([]byte(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	local, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	taddr, err := ma.NewMultiaddr("/ip4/127.0.0.1/udp/" + portOf(t, echo.LocalAddr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := dialDatagram(taddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	// the frames are copied as a libp2p stream would
+	go io.Copy(remote, local)
+	go io.Copy(local, remote)
+
+	client.SetReadDeadline(time.Now().Add(10 * time.Second))
+	buf := make([]byte, maxDatagramSize)
+	for _, d := range datagrams {
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != d {
+			t.Fatalf("expected %q, got %q", d, buf[:n])
+		}
+	}
+}
+
+func portOf(t *testing.T, addr net.Addr) string {
+	_, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
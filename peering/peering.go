@@ -0,0 +1,255 @@
+// Package peering maintains permanent connections to a configured set of
+// peers.
+package peering
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+var log = logging.Logger("peering")
+
+// Tag is the connection manager tag applied to peered peers.
+const Tag = "ipfs-peering"
+
+// tagValue is high enough to keep peered connections from ever being trimmed
+// in favour of regular connections.
+const tagValue = 1000
+
+// Reconnect backoff parameters. Variables so tests can speed them up.
+var (
+	initialDelay  = 5 * time.Second
+	maxDelay      = 10 * time.Minute
+	backoffFactor = 1.5
+	dialTimeout   = 30 * time.Second
+)
+
+// handler reconnects to a single peer.
+type handler struct {
+	ps    *PeeringService
+	id    peer.ID
+	addrs []ma.Multiaddr
+
+	// guarded by ps.lk
+	timer     *time.Timer
+	nextDelay time.Duration
+}
+
+// PeeringService keeps connections to a set of peers open, redialing them with
+// exponential backoff whenever they drop. Peered connections are tagged in the
+// connection manager so they are not pruned.
+type PeeringService struct {
+	host host.Host
+
+	lk      sync.Mutex
+	peers   map[peer.ID]*handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+	notifee *inet.NotifyBundle
+}
+
+// NewPeeringService constructs a new, not yet started, peering service.
+func NewPeeringService(h host.Host) *PeeringService {
+	return &PeeringService{
+		host:  h,
+		peers: make(map[peer.ID]*handler),
+	}
+}
+
+// Start starts the service and dials all peers added so far.
+func (ps *PeeringService) Start() error {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	if ps.started {
+		return nil
+	}
+	ps.started = true
+	ps.ctx, ps.cancel = context.WithCancel(context.Background())
+
+	ps.notifee = &inet.NotifyBundle{
+		ConnectedF:    ps.connected,
+		DisconnectedF: ps.disconnected,
+	}
+	ps.host.Network().Notify(ps.notifee)
+
+	for _, h := range ps.peers {
+		h.reconnectLocked(0)
+	}
+	return nil
+}
+
+// Close stops the service. Peered connections are left open but are no longer
+// maintained.
+func (ps *PeeringService) Close() error {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	if !ps.started {
+		return nil
+	}
+	ps.started = false
+	ps.cancel()
+	ps.host.Network().StopNotify(ps.notifee)
+
+	for _, h := range ps.peers {
+		h.stopLocked()
+	}
+	return nil
+}
+
+// AddPeer adds a peer to the peering set, or updates its addresses if it is
+// already a member.
+func (ps *PeeringService) AddPeer(pi pstore.PeerInfo) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	ps.host.Peerstore().AddAddrs(pi.ID, pi.Addrs, pstore.PermanentAddrTTL)
+	ps.host.ConnManager().TagPeer(pi.ID, Tag, tagValue)
+
+	if h, ok := ps.peers[pi.ID]; ok {
+		h.addrs = pi.Addrs
+		return
+	}
+
+	h := &handler{
+		ps:        ps,
+		id:        pi.ID,
+		addrs:     pi.Addrs,
+		nextDelay: initialDelay,
+	}
+	ps.peers[pi.ID] = h
+
+	if ps.started && ps.host.Network().Connectedness(pi.ID) != inet.Connected {
+		h.reconnectLocked(0)
+	}
+}
+
+// RemovePeer removes a peer from the peering set. The connection to it, if
+// any, is left open but will no longer be protected.
+func (ps *PeeringService) RemovePeer(id peer.ID) bool {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	h, ok := ps.peers[id]
+	if !ok {
+		return false
+	}
+	h.stopLocked()
+	delete(ps.peers, id)
+
+	ps.host.ConnManager().UntagPeer(id, Tag)
+	// the addresses were added with a permanent TTL, downgrade them.
+	ps.host.Peerstore().UpdateAddrs(id, pstore.PermanentAddrTTL, pstore.TempAddrTTL)
+	return true
+}
+
+// ListPeers lists the peers in the peering set.
+func (ps *PeeringService) ListPeers() []pstore.PeerInfo {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	out := make([]pstore.PeerInfo, 0, len(ps.peers))
+	for id, h := range ps.peers {
+		out = append(out, pstore.PeerInfo{ID: id, Addrs: h.addrs})
+	}
+	return out
+}
+
+func (ps *PeeringService) connected(_ inet.Network, c inet.Conn) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	if h, ok := ps.peers[c.RemotePeer()]; ok {
+		h.stopLocked()
+		h.nextDelay = initialDelay
+	}
+}
+
+func (ps *PeeringService) disconnected(_ inet.Network, c inet.Conn) {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	h, ok := ps.peers[c.RemotePeer()]
+	if !ok || !ps.started {
+		return
+	}
+	// we may still have other connections to the peer.
+	if ps.host.Network().Connectedness(h.id) == inet.Connected {
+		return
+	}
+	h.reconnectLocked(jitter(h.nextDelay))
+}
+
+// reconnectLocked schedules a dial after delay. ps.lk must be held.
+func (h *handler) reconnectLocked(delay time.Duration) {
+	h.stopLocked()
+	h.timer = time.AfterFunc(delay, h.dial)
+}
+
+// stopLocked cancels the scheduled dial, if any. ps.lk must be held.
+func (h *handler) stopLocked() {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+}
+
+func (h *handler) dial() {
+	h.ps.lk.Lock()
+	ctx := h.ps.ctx
+	addrs := h.addrs
+	started := h.ps.started
+	h.ps.lk.Unlock()
+
+	if !started {
+		return
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	err := h.ps.host.Connect(dctx, pstore.PeerInfo{ID: h.id, Addrs: addrs})
+	cancel()
+	if err == nil {
+		return
+	}
+
+	h.ps.lk.Lock()
+	defer h.ps.lk.Unlock()
+
+	// the peer may have been removed, or the service stopped, while we
+	// were dialing.
+	if cur, ok := h.ps.peers[h.id]; !ok || cur != h || !h.ps.started {
+		return
+	}
+
+	delay := h.nextDelay
+	h.nextDelay = time.Duration(float64(h.nextDelay) * backoffFactor)
+	if h.nextDelay > maxDelay {
+		h.nextDelay = maxDelay
+	}
+
+	log.Debugf("failed to connect to peered peer %s, retrying in %s: %s", h.id.Pretty(), delay, err)
+	h.reconnectLocked(jitter(delay))
+}
+
+// jitter spreads reconnects by +/- 10%.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	spread := int64(d) / 5
+	if spread == 0 {
+		return d
+	}
+	return d - time.Duration(spread/2) + time.Duration(rand.Int63n(spread))
+}
@@ -0,0 +1,67 @@
+package peering
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mocknet "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/net/mock"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPeeringReconnects(t *testing.T) {
+	initialDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1, h2 := mn.Hosts()[0], mn.Hosts()[1]
+
+	ps := NewPeeringService(h1)
+	ps.AddPeer(pstore.PeerInfo{ID: h2.ID(), Addrs: h2.Addrs()})
+	if err := ps.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	connected := func() bool {
+		return h1.Network().Connectedness(h2.ID()) == inet.Connected
+	}
+	waitFor(t, connected)
+
+	if err := h1.Network().ClosePeer(h2.ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, connected)
+
+	if !ps.RemovePeer(h2.ID()) {
+		t.Fatal("expected peer to be removed")
+	}
+	if len(ps.ListPeers()) != 0 {
+		t.Fatal("peering set should be empty")
+	}
+
+	if err := h1.Network().ClosePeer(h2.ID()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if connected() {
+		t.Fatal("removed peer should not be reconnected")
+	}
+}
@@ -0,0 +1,421 @@
+// Package peerstore keeps the peerstore of the node in the datastore, so
+// that the addresses, protocols and latencies learned about the peers
+// survive restarts and the node can reconnect to them without going through
+// the bootstrap peers.
+//
+// The peerstore itself stays in memory: it is written to the datastore
+// periodically and when the node stops, and read back when it starts. Every
+// persisted address carries the time the node last saw it, and is dropped
+// once it wasn't seen for the address TTL.
+package peerstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+var log = logging.Logger("peerstore")
+
+// Defaults of the settings left unset in the config.
+const (
+	DefaultAddrTTL       = 24 * time.Hour
+	DefaultFlushInterval = 5 * time.Minute
+	DefaultMaxPeers      = 1000
+)
+
+// dsPrefix is the datastore prefix of the persisted peers.
+var dsPrefix = ds.NewKey("/local/peerstore")
+
+// addrRecord is a persisted address.
+type addrRecord struct {
+	Addr     string
+	LastSeen time.Time
+}
+
+// peerRecord is what is persisted of a peer.
+type peerRecord struct {
+	Addrs     []addrRecord
+	Protocols []string      `json:",omitempty"`
+	Latency   time.Duration `json:",omitempty"`
+}
+
+// lastSeen returns the last time one of the addresses was seen.
+func (r *peerRecord) lastSeen() time.Time {
+	var t time.Time
+	for _, a := range r.Addrs {
+		if a.LastSeen.After(t) {
+			t = a.LastSeen
+		}
+	}
+	return t
+}
+
+// GCResult is the outcome of a garbage collection of the persisted peers.
+type GCResult struct {
+	// Kept is the number of peers left.
+	Kept int
+	// Removed is the number of peers removed.
+	Removed int
+	// RemovedAddrs is the number of addresses removed, including the ones
+	// of the removed peers.
+	RemovedAddrs int
+}
+
+// Persister writes a peerstore to a datastore and reads it back.
+type Persister struct {
+	self    peer.ID
+	ps      pstore.Peerstore
+	ds      ds.Datastore
+	net     inet.Network
+	addrTTL time.Duration
+	flush   time.Duration
+	max     int
+
+	// lk serializes the accesses to the datastore
+	lk sync.Mutex
+	// the addresses read by Load, with the time they were last seen.
+	// Finding them in the peerstore doesn't mean they were seen again.
+	loaded map[peer.ID]map[string]time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPersister returns a Persister of the peerstore ps of the node self.
+// net tells which peers are connected, it is nil for an offline node, which
+// can only use the Persister to collect the garbage.
+func NewPersister(self peer.ID, ps pstore.Peerstore, d ds.Datastore, net inet.Network, cfg *extconfig.Peerstore) (*Persister, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Persister{
+		self:    self,
+		ps:      ps,
+		ds:      d,
+		net:     net,
+		addrTTL: DefaultAddrTTL,
+		flush:   DefaultFlushInterval,
+		max:     DefaultMaxPeers,
+		loaded:  make(map[peer.ID]map[string]time.Time),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	var err error
+	if cfg.AddrTTL != "" {
+		p.addrTTL, err = time.ParseDuration(cfg.AddrTTL)
+		if err != nil || p.addrTTL <= 0 {
+			cancel()
+			return nil, fmt.Errorf("invalid Swarm.Peerstore.AddrTTL %q", cfg.AddrTTL)
+		}
+	}
+	if cfg.FlushInterval != "" {
+		p.flush, err = time.ParseDuration(cfg.FlushInterval)
+		if err != nil || p.flush <= 0 {
+			cancel()
+			return nil, fmt.Errorf("invalid Swarm.Peerstore.FlushInterval %q", cfg.FlushInterval)
+		}
+	}
+	if cfg.MaxPeers > 0 {
+		p.max = cfg.MaxPeers
+	}
+	return p, nil
+}
+
+func peerKey(id peer.ID) ds.Key {
+	return dsPrefix.ChildString(id.Pretty())
+}
+
+// records reads all the persisted peers.
+func (p *Persister) records() (map[peer.ID]*peerRecord, error) {
+	res, err := p.ds.Query(dsq.Query{Prefix: dsPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	records := make(map[peer.ID]*peerRecord)
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		id, err := peer.IDB58Decode(ds.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			log.Warningf("invalid persisted peer %s: %s", r.Key, err)
+			continue
+		}
+		v, ok := r.Value.([]byte)
+		if !ok {
+			log.Warningf("invalid persisted peer %s", r.Key)
+			continue
+		}
+		rec := new(peerRecord)
+		if err := json.Unmarshal(v, rec); err != nil {
+			log.Warningf("invalid persisted peer %s: %s", r.Key, err)
+			continue
+		}
+		records[id] = rec
+	}
+	return records, nil
+}
+
+func (p *Persister) put(id peer.ID, rec *peerRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return p.ds.Put(peerKey(id), b)
+}
+
+// Load adds the persisted peers to the peerstore, and returns how many
+// were added. Their addresses expire from the peerstore when they reach the
+// address TTL.
+func (p *Persister) Load() (int, error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	records, err := p.records()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	count := 0
+	for id, rec := range records {
+		if id == p.self {
+			continue
+		}
+
+		loaded := make(map[string]time.Time)
+		for _, ar := range rec.Addrs {
+			ttl := p.addrTTL - now.Sub(ar.LastSeen)
+			if ttl <= 0 {
+				continue
+			}
+			a, err := ma.NewMultiaddr(ar.Addr)
+			if err != nil {
+				continue
+			}
+			p.ps.AddAddr(id, a, ttl)
+			loaded[ar.Addr] = ar.LastSeen
+		}
+		if len(loaded) == 0 {
+			continue
+		}
+		p.loaded[id] = loaded
+
+		if len(rec.Protocols) > 0 {
+			if err := p.ps.AddProtocols(id, rec.Protocols...); err != nil {
+				return count, err
+			}
+		}
+		if rec.Latency > 0 && p.ps.LatencyEWMA(id) == 0 {
+			p.ps.RecordLatency(id, rec.Latency)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Flush writes the peers of the peerstore to the datastore. The addresses
+// of the connected peers, and the ones learned since Load, are marked as
+// seen now.
+func (p *Persister) Flush() error {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	records, err := p.records()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range p.ps.PeersWithAddrs() {
+		if id == p.self {
+			continue
+		}
+		addrs := p.ps.Addrs(id)
+		if len(addrs) == 0 {
+			continue
+		}
+
+		connected := p.net != nil && p.net.Connectedness(id) == inet.Connected
+		if connected {
+			// seen again, the loaded times don't matter anymore
+			delete(p.loaded, id)
+		}
+
+		rec, ok := records[id]
+		if !ok {
+			rec = new(peerRecord)
+		}
+		seen := make(map[string]time.Time)
+		for _, ar := range rec.Addrs {
+			seen[ar.Addr] = ar.LastSeen
+		}
+		for _, a := range addrs {
+			s := a.String()
+			if t, ok := p.loaded[id][s]; ok {
+				if t.After(seen[s]) {
+					seen[s] = t
+				}
+				continue
+			}
+			seen[s] = now
+		}
+
+		rec.Addrs = rec.Addrs[:0]
+		for a, t := range seen {
+			rec.Addrs = append(rec.Addrs, addrRecord{Addr: a, LastSeen: t})
+		}
+		sort.Slice(rec.Addrs, func(i, j int) bool {
+			return rec.Addrs[i].Addr < rec.Addrs[j].Addr
+		})
+
+		if protos, err := p.ps.GetProtocols(id); err == nil && len(protos) > 0 {
+			sort.Strings(protos)
+			rec.Protocols = protos
+		}
+		if lat := p.ps.LatencyEWMA(id); lat > 0 {
+			rec.Latency = lat
+		}
+
+		if err := p.put(id, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GC removes the addresses that weren't seen for the address TTL, the
+// peers left without addresses, and the least recently seen peers beyond
+// the maximum number of peers.
+func (p *Persister) GC() (GCResult, error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	var res GCResult
+	records, err := p.records()
+	if err != nil {
+		return res, err
+	}
+
+	now := time.Now()
+	type kept struct {
+		id       peer.ID
+		lastSeen time.Time
+		addrs    int
+	}
+	var keep []kept
+	for id, rec := range records {
+		addrs := rec.Addrs[:0]
+		for _, ar := range rec.Addrs {
+			if now.Sub(ar.LastSeen) < p.addrTTL {
+				addrs = append(addrs, ar)
+			}
+		}
+		removed := len(rec.Addrs) - len(addrs)
+		res.RemovedAddrs += removed
+
+		switch {
+		case id == p.self || len(addrs) == 0:
+			if err := p.ds.Delete(peerKey(id)); err != nil {
+				return res, err
+			}
+			res.Removed++
+			res.RemovedAddrs += len(addrs)
+			continue
+		case removed > 0:
+			rec.Addrs = addrs
+			if err := p.put(id, rec); err != nil {
+				return res, err
+			}
+		}
+		keep = append(keep, kept{id: id, lastSeen: rec.lastSeen(), addrs: len(rec.Addrs)})
+	}
+
+	if len(keep) > p.max {
+		sort.Slice(keep, func(i, j int) bool {
+			return keep[i].lastSeen.After(keep[j].lastSeen)
+		})
+		for _, k := range keep[p.max:] {
+			if err := p.ds.Delete(peerKey(k.id)); err != nil {
+				return res, err
+			}
+			res.Removed++
+			res.RemovedAddrs += k.addrs
+		}
+		keep = keep[:p.max]
+	}
+	res.Kept = len(keep)
+	return res, nil
+}
+
+// Peers returns the persisted peers, the most recently seen first.
+func (p *Persister) Peers() ([]peer.ID, error) {
+	p.lk.Lock()
+	records, err := p.records()
+	p.lk.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]peer.ID, 0, len(records))
+	for id := range records {
+		if id != p.self {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return records[ids[i]].lastSeen().After(records[ids[j]].lastSeen())
+	})
+	return ids, nil
+}
+
+// Start flushes the peerstore and collects the garbage every flush
+// interval, until the Persister is closed.
+func (p *Persister) Start() {
+	go func() {
+		ticker := time.NewTicker(p.flush)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.Flush(); err != nil {
+					log.Errorf("flushing the peerstore: %s", err)
+					continue
+				}
+				if _, err := p.GC(); err != nil {
+					log.Errorf("collecting the persisted peers: %s", err)
+				}
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic flushes, and flushes the peerstore a last time.
+func (p *Persister) Close() error {
+	p.cancel()
+	if p.net == nil {
+		return nil
+	}
+	if err := p.Flush(); err != nil {
+		return err
+	}
+	_, err := p.GC()
+	return err
+}
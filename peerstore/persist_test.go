@@ -0,0 +1,128 @@
+package peerstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+	pstoremem "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore/pstoremem"
+)
+
+var (
+	testSelf = testID("self")
+	testPeer = testID("peer")
+)
+
+// testID returns a valid peer ID, the persisted peers are keyed by their
+// base58 encoding.
+func testID(name string) peer.ID {
+	h, err := mh.Sum([]byte(name), mh.SHA2_256, -1)
+	if err != nil {
+		panic(err)
+	}
+	return peer.ID(h)
+}
+
+func testAddr(t *testing.T, s string) ma.Multiaddr {
+	a, err := ma.NewMultiaddr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestFlushLoad(t *testing.T) {
+	d := ds.NewMapDatastore()
+	a := testAddr(t, "/ip4/1.2.3.4/tcp/4001")
+
+	ps := pstoremem.NewPeerstore()
+	ps.AddAddr(testSelf, testAddr(t, "/ip4/127.0.0.1/tcp/4001"), pstore.PermanentAddrTTL)
+	ps.AddAddr(testPeer, a, pstore.TempAddrTTL)
+	ps.AddProtocols(testPeer, "/ipfs/bitswap/1.1.0")
+	ps.RecordLatency(testPeer, 42*time.Millisecond)
+
+	p, err := NewPersister(testSelf, ps, d, nil, &extconfig.Peerstore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted node
+	ps2 := pstoremem.NewPeerstore()
+	p2, err := NewPersister(testSelf, ps2, d, nil, &extconfig.Peerstore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := p2.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 peer to be loaded, got %d", count)
+	}
+	addrs := ps2.Addrs(testPeer)
+	if len(addrs) != 1 || !addrs[0].Equal(a) {
+		t.Fatalf("expected %s, got %v", a, addrs)
+	}
+	if protos, _ := ps2.GetProtocols(testPeer); len(protos) != 1 {
+		t.Fatalf("expected the protocols to be loaded, got %v", protos)
+	}
+	if ps2.LatencyEWMA(testPeer) != 42*time.Millisecond {
+		t.Fatalf("expected the latency to be loaded, got %s", ps2.LatencyEWMA(testPeer))
+	}
+	if len(ps2.Addrs(testSelf)) != 0 {
+		t.Fatal("expected the node itself not to be persisted")
+	}
+}
+
+func TestGC(t *testing.T) {
+	d := ds.NewMapDatastore()
+	p, err := NewPersister(testSelf, pstoremem.NewPeerstore(), d, nil, &extconfig.Peerstore{
+		AddrTTL:  "1h",
+		MaxPeers: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	put := func(name string, seen ...time.Time) {
+		rec := new(peerRecord)
+		for i, s := range seen {
+			addr := fmt.Sprintf("/ip4/1.2.3.4/tcp/%d", 4001+i)
+			rec.Addrs = append(rec.Addrs, addrRecord{Addr: addr, LastSeen: s})
+		}
+		if err := p.put(testID(name), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("expired", now.Add(-2*time.Hour))
+	put("partly", now.Add(-2*time.Hour), now.Add(-time.Minute))
+	put("recent", now)
+	put("oldest", now.Add(-30*time.Minute))
+
+	res, err := p.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Kept != 2 || res.Removed != 2 || res.RemovedAddrs != 3 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	ids, err := p.Peers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != testID("recent") || ids[1] != testID("partly") {
+		t.Fatalf("unexpected peers %v", ids)
+	}
+}
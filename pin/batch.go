@@ -0,0 +1,306 @@
+package pin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// journalPrefix is the datastore prefix of the pin changes not flushed yet
+// by a BatchingPinner.
+var journalPrefix = ds.NewKey("/local/pins/journal")
+
+// journalEntry is a change of the pins. The changes are replayed on the
+// pinset they were made after, or on a later one: they must give the same
+// pins when applied again.
+type journalEntry struct {
+	Add  bool
+	Cid  string
+	Mode string
+}
+
+// BatchingPinner is a Pinner whose flushes are coalesced: the pinset is
+// written at most once per interval, and the changes made in between are
+// kept in a journal in the datastore, replayed by ReplayJournal if the
+// node stops before the pinset is written.
+type BatchingPinner struct {
+	Pinner
+	dstore   ds.Datastore
+	locker   bstore.GCLocker
+	interval time.Duration
+
+	lk      sync.Mutex
+	seq     uint64
+	pending *time.Timer
+	closed  bool
+	// flushes tracks the scheduled flush, waited for by Close
+	flushes sync.WaitGroup
+}
+
+// NewBatchingPinner returns a BatchingPinner writing the pinset of p at most
+// once per interval. The pinset is written holding the pin lock of locker,
+// so that the garbage collections don't remove its blocks. The journal of d
+// must have been replayed on p with ReplayJournal.
+func NewBatchingPinner(p Pinner, d ds.Datastore, locker bstore.GCLocker, interval time.Duration) (*BatchingPinner, error) {
+	keys, err := journalKeys(d)
+	if err != nil {
+		return nil, err
+	}
+
+	bp := &BatchingPinner{
+		Pinner:   p,
+		dstore:   d,
+		locker:   locker,
+		interval: interval,
+	}
+	// the changes left in the journal must be cleared by the next flush,
+	// and replayed before the new ones until then
+	if len(keys) > 0 {
+		last, err := strconv.ParseUint(ds.NewKey(keys[len(keys)-1]).Name(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid journaled pin change key %s", keys[len(keys)-1])
+		}
+		bp.seq = last + 1
+	}
+	return bp, nil
+}
+
+// Pin pins node and journals the change.
+func (p *BatchingPinner) Pin(ctx context.Context, node ipld.Node, recurse bool) error {
+	if err := p.Pinner.Pin(ctx, node, recurse); err != nil {
+		return err
+	}
+	if recurse {
+		// a recursive pin replaces a direct one
+		return p.journal(
+			journalEntry{Cid: node.Cid().String(), Mode: linkDirect},
+			journalEntry{Add: true, Cid: node.Cid().String(), Mode: linkRecursive},
+		)
+	}
+	return p.journal(journalEntry{Add: true, Cid: node.Cid().String(), Mode: linkDirect})
+}
+
+// Unpin unpins c and journals the change.
+func (p *BatchingPinner) Unpin(ctx context.Context, c cid.Cid, recursive bool) error {
+	if err := p.Pinner.Unpin(ctx, c, recursive); err != nil {
+		return err
+	}
+	return p.journal(
+		journalEntry{Cid: c.String(), Mode: linkRecursive},
+		journalEntry{Cid: c.String(), Mode: linkDirect},
+	)
+}
+
+// Update updates the recursive pin of from to to and journals the change.
+func (p *BatchingPinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error {
+	if err := p.Pinner.Update(ctx, from, to, unpin); err != nil {
+		return err
+	}
+	entries := []journalEntry{{Add: true, Cid: to.String(), Mode: linkRecursive}}
+	if unpin {
+		entries = append(entries, journalEntry{Cid: from.String(), Mode: linkRecursive})
+	}
+	return p.journal(entries...)
+}
+
+// PinWithMode pins c with mode and journals the change.
+func (p *BatchingPinner) PinWithMode(c cid.Cid, mode Mode) {
+	p.Pinner.PinWithMode(c, mode)
+	p.journalMode(true, c, mode)
+}
+
+// RemovePinWithMode unpins c of mode and journals the change.
+func (p *BatchingPinner) RemovePinWithMode(c cid.Cid, mode Mode) {
+	p.Pinner.RemovePinWithMode(c, mode)
+	p.journalMode(false, c, mode)
+}
+
+func (p *BatchingPinner) journalMode(add bool, c cid.Cid, mode Mode) {
+	name, ok := ModeToString(mode)
+	if !ok || (mode != Recursive && mode != Direct) {
+		return
+	}
+	if err := p.journal(journalEntry{Add: add, Cid: c.String(), Mode: name}); err != nil {
+		log.Errorf("journaling the pin of %s: %s", c, err)
+	}
+}
+
+func (p *BatchingPinner) journal(entries ...journalEntry) error {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := p.dstore.Put(journalKey(p.seq), b); err != nil {
+			return fmt.Errorf("cannot journal pin change: %v", err)
+		}
+		p.seq++
+	}
+	return nil
+}
+
+// Flush schedules the write of the pinset, the changes being journaled.
+func (p *BatchingPinner) Flush() error {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	if p.closed {
+		return p.Pinner.Flush()
+	}
+	if p.pending == nil {
+		p.flushes.Add(1)
+		p.pending = time.AfterFunc(p.interval, func() {
+			defer p.flushes.Done()
+			if err := p.flush(); err != nil {
+				log.Errorf("writing the pinset: %s", err)
+			}
+		})
+	}
+	return nil
+}
+
+// flush writes the pinset and removes the journaled changes it includes.
+func (p *BatchingPinner) flush() error {
+	unlock := p.locker.PinLock()
+	defer unlock.Unlock()
+
+	p.lk.Lock()
+	p.pending = nil
+	upto := p.seq
+	p.lk.Unlock()
+
+	// the changes journaled until now are in the pinset, the later ones
+	// are replayed again if the node stops before the next flush
+	if err := p.Pinner.Flush(); err != nil {
+		return err
+	}
+	return clearJournal(p.dstore, upto)
+}
+
+// Close writes the pinset if changes are pending.
+func (p *BatchingPinner) Close() error {
+	p.lk.Lock()
+	p.closed = true
+	stopped := p.pending != nil && p.pending.Stop()
+	p.lk.Unlock()
+
+	if !stopped {
+		// a flush may be running
+		p.flushes.Wait()
+		return nil
+	}
+	p.flushes.Done()
+	return p.flush()
+}
+
+// ReplayJournal applies to p the changes journaled by a BatchingPinner and
+// not written with its pinset, and writes the pinset. The journal is kept
+// when the pinset can't be written, for instance in a repo opened read-only.
+func ReplayJournal(p Pinner, d ds.Datastore) error {
+	entries, err := readJournal(d)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	log.Infof("replaying %d journaled pin changes", len(entries))
+	for _, e := range entries {
+		c, err := cid.Decode(e.Cid)
+		if err != nil {
+			return fmt.Errorf("invalid journaled pin change: %v", err)
+		}
+		mode, ok := StringToMode(e.Mode)
+		if !ok || (mode != Recursive && mode != Direct) {
+			return fmt.Errorf("invalid journaled pin mode %q", e.Mode)
+		}
+		if e.Add {
+			p.PinWithMode(c, mode)
+		} else {
+			p.RemovePinWithMode(c, mode)
+		}
+	}
+
+	if err := p.Flush(); err != nil {
+		log.Warningf("cannot write the pinset, keeping the pin journal: %s", err)
+		return nil
+	}
+	return clearJournal(d, math.MaxUint64)
+}
+
+func journalKey(seq uint64) ds.Key {
+	return journalPrefix.ChildString(fmt.Sprintf("%020d", seq))
+}
+
+// readJournal returns the journaled changes in the order they were made.
+func readJournal(d ds.Datastore) ([]journalEntry, error) {
+	res, err := d.Query(dsq.Query{Prefix: journalPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	all, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+
+	entries := make([]journalEntry, 0, len(all))
+	for _, r := range all {
+		var e journalEntry
+		if err := json.Unmarshal(r.Value, &e); err != nil {
+			return nil, fmt.Errorf("invalid journaled pin change: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// journalKeys returns the keys of the journaled changes, sorted.
+func journalKeys(d ds.Datastore) ([]string, error) {
+	res, err := d.Query(dsq.Query{Prefix: journalPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	all, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(all))
+	for _, r := range all {
+		keys = append(keys, r.Key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// clearJournal removes the journaled changes before the seq-th.
+func clearJournal(d ds.Datastore, seq uint64) error {
+	keys, err := journalKeys(d)
+	if err != nil {
+		return err
+	}
+
+	end := journalKey(seq).String()
+	for _, k := range keys {
+		if k >= end {
+			break
+		}
+		if err := d.Delete(ds.NewKey(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
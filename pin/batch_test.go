@@ -0,0 +1,83 @@
+package pin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mdag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bs "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	blockstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+func TestBatchingPinnerJournal(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+
+	inner := NewPinner(dstore, dserv, dserv)
+	if err := inner.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewBatchingPinner(inner, dstore, blockstore.NewGCLocker(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, ak := randNode()
+	b, bk := randNode()
+	for _, nd := range []*mdag.ProtoNode{a, b} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unpin(ctx, bk, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the node stops before the pinset is written
+	loaded, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUnpinned(t, loaded, ak, "the pinset was written before the interval")
+
+	if err := ReplayJournal(loaded, dstore); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, loaded, ak, "the journaled pin wasn't replayed")
+	assertUnpinned(t, loaded, bk, "the journaled unpin wasn't replayed")
+
+	entries, err := readJournal(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the journal to be cleared, got %d entries", len(entries))
+	}
+
+	// closing writes the pending pinset
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadPinner(dstore, dserv, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, reloaded, ak, "the pinset wasn't written on close")
+}
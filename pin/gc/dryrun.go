@@ -0,0 +1,80 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// DryRun performs the mark phase of GC and outputs the blocks that the
+// sweep would remove, with their size, without removing them.
+//
+// It doesn't take the GC lock, so the adds are not blocked, and blocks
+// added and pinned meanwhile may be reported.
+func DryRun(ctx context.Context, bs bstore.GCBlockstore, pn pin.Pinner, bestEffortRoots []cid.Cid) <-chan Result {
+	emark := log.EventBegin(ctx, "GC.dryRun")
+
+	bsrv := bserv.New(bs, offline.Exchange(bs))
+	ds := dag.NewDAGService(bsrv)
+
+	output := make(chan Result, 128)
+
+	go func() {
+		defer close(output)
+		defer emark.Done()
+
+		gcs, err := ColoredSet(ctx, pn, ds, bestEffortRoots, output)
+		if err != nil {
+			select {
+			case output <- Result{Error: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		keychan, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			select {
+			case output <- Result{Error: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		var unmarked uint64
+		for k := range keychan {
+			if gcs.Has(k) {
+				continue
+			}
+			size, err := bs.GetSize(k)
+			if err == bstore.ErrNotFound {
+				// removed meanwhile
+				continue
+			}
+			res := Result{KeyRemoved: k, Size: size}
+			if err != nil {
+				res = Result{Error: err}
+			}
+			unmarked++
+			select {
+			case output <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+		emark.Append(logging.LoggableMap{
+			"blackSetSize": fmt.Sprintf("%d", gcs.Len()),
+			"whiteSetSize": fmt.Sprintf("%d", unmarked),
+		})
+	}()
+
+	return output
+}
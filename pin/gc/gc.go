@@ -26,7 +26,9 @@ var log = logging.Logger("gc")
 // run.  It contains either an error, or the cid of a removed object.
 type Result struct {
 	KeyRemoved cid.Cid
-	Error      error
+	// Size is the size of the object, only set by DryRun.
+	Size  int
+	Error error
 }
 
 // GC performs a mark and sweep garbage collection of the blocks in the blockstore
@@ -144,6 +146,12 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 // adds them to the given cid.Set, using the provided dag.GetLinks function
 // to walk the tree.
 func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots []cid.Cid) error {
+	return descendants(ctx, getLinks, set.Visit, roots)
+}
+
+// descendants walks the given roots and their descendants, descending into
+// the nodes for which visit returns true.
+func descendants(ctx context.Context, getLinks dag.GetLinks, visit func(cid.Cid) bool, roots []cid.Cid) error {
 	verifyGetLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
 		err := verifcid.ValidateCid(c)
 		if err != nil {
@@ -165,10 +173,10 @@ func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots
 	}
 
 	for _, c := range roots {
-		set.Add(c)
+		visit(c)
 
 		// EnumerateChildren recursively walks the dag and adds the keys to the given set
-		err := dag.EnumerateChildren(ctx, verifyGetLinks, c, set.Visit)
+		err := dag.EnumerateChildren(ctx, verifyGetLinks, c, visit)
 
 		if err != nil {
 			err = verboseCidError(err)
@@ -0,0 +1,454 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+	dag "gx/ipfs/QmXv5mwmQ74r4aiHcNeQ4GAmfB3aWJuqaE4WyDfDfvkgLM/go-merkledag"
+	bserv "gx/ipfs/Qma2KhbQarYTkmSJAeaMGRAg8HAXAhEWK8ge4SReG7ZSD3/go-blockservice"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	dstore "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	offline "gx/ipfs/QmcRC35JF2pJQneAxa5LdQBQRumWggccWErogSrCkS1h8T/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+	bstore "gx/ipfs/QmegPGspn3RpTMQ23Fd3GVVMopo1zsEMurudbFMZ5UXBLH/go-ipfs-blockstore"
+)
+
+// DefaultBatchSize is the number of unmarked blocks removed per acquisition
+// of the GC lock by an incremental garbage collection.
+const DefaultBatchSize = 1000
+
+// ErrGCRunning is returned when an incremental garbage collection is
+// started while another one runs.
+var ErrGCRunning = errors.New("an incremental garbage collection is already running")
+
+var (
+	// the state of an interrupted garbage collection, resumed by the next
+	// one
+	stateKey     = dstore.NewKey("/local/gc/state")
+	markedPrefix = dstore.NewKey("/local/gc/marked")
+)
+
+// stateBatchSize is the number of keys of the marked set written or removed
+// at once.
+const stateBatchSize = 1024
+
+// the value of stateKey once the marked set is saved
+var stateSweep = []byte("sweep")
+
+var incrementalRunning int32
+
+// IncrementalOptions configure an incremental garbage collection.
+type IncrementalOptions struct {
+	// BatchSize is the number of unmarked blocks removed per acquisition
+	// of the GC lock. Defaults to DefaultBatchSize.
+	BatchSize int
+
+	// MaxRate is the maximum number of blocks removed per second, zero
+	// for no limit.
+	MaxRate int
+
+	// BestEffortRoots returns the roots kept, with their descendants,
+	// when they are in the blockstore. It's called again before every
+	// batch since they may change meanwhile, e.g. the root of the files
+	// API.
+	BestEffortRoots func() ([]cid.Cid, error)
+}
+
+// Incremental performs a garbage collection without stopping the adds for
+// its whole duration, unlike GC.
+//
+// The marked set is computed without the GC lock, then the blockstore is
+// swept in batches: for every batch of unmarked blocks, the GC lock is
+// taken, the pins added meanwhile are marked, and the blocks still unmarked
+// are removed. The marked set is saved in the datastore, so an interrupted
+// collection resumes with the sweep.
+func Incremental(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, opts IncrementalOptions) <-chan Result {
+	output := make(chan Result, 128)
+
+	if !atomic.CompareAndSwapInt32(&incrementalRunning, 0, 1) {
+		output <- Result{Error: ErrGCRunning}
+		close(output)
+		return output
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MaxRate > 0 && opts.BatchSize > opts.MaxRate {
+		opts.BatchSize = opts.MaxRate
+	}
+	if opts.BestEffortRoots == nil {
+		opts.BestEffortRoots = func() ([]cid.Cid, error) { return nil, nil }
+	}
+
+	bsrv := bserv.New(bs, offline.Exchange(bs))
+	m := &marker{
+		ng:     dag.NewDAGService(bsrv),
+		pn:     pn,
+		dstor:  dstor,
+		roots:  opts.BestEffortRoots,
+		marked: cid.NewSet(),
+		output: output,
+	}
+
+	go func() {
+		defer close(output)
+		defer atomic.StoreInt32(&incrementalRunning, 0)
+
+		if err := incremental(ctx, bs, m, opts); err != nil {
+			select {
+			case output <- Result{Error: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		defer log.EventBegin(ctx, "GC.datastore").Done()
+		gds, ok := dstor.(dstore.GCDatastore)
+		if !ok {
+			return
+		}
+		if err := gds.CollectGarbage(); err != nil {
+			select {
+			case output <- Result{Error: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return output
+}
+
+func incremental(ctx context.Context, bs bstore.GCBlockstore, m *marker, opts IncrementalOptions) error {
+	resumed, err := m.load()
+	if err != nil {
+		return err
+	}
+	if resumed {
+		log.Infof("resuming the garbage collection with %d marked blocks", m.marked.Len())
+	}
+	// when resuming, only the pins added since the interruption are marked
+	emark := log.EventBegin(ctx, "GC.mark")
+	if err := m.mark(ctx); err != nil {
+		return err
+	}
+	emark.Append(logging.LoggableMap{
+		"blackSetSize": fmt.Sprintf("%d", m.marked.Len()),
+	})
+	emark.Done()
+	if err := m.save(true); err != nil {
+		return err
+	}
+
+	esweep := log.EventBegin(ctx, "GC.sweep")
+	keychan, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch   []cid.Cid
+		removed int
+		failed  bool
+		start   = time.Now()
+	)
+	sweep := func() error {
+		n, ok, err := m.sweepBatch(ctx, bs, batch)
+		batch = batch[:0]
+		removed += n
+		failed = failed || !ok
+		if err != nil {
+			return err
+		}
+		if opts.MaxRate > 0 {
+			// wait until the rate of removals is back under the limit
+			next := start.Add(time.Duration(removed) * time.Second / time.Duration(opts.MaxRate))
+			select {
+			case <-time.After(time.Until(next)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+loop:
+	for {
+		select {
+		case k, ok := <-keychan:
+			if !ok {
+				break loop
+			}
+			if m.keep(k) {
+				continue
+			}
+			batch = append(batch, k)
+			if len(batch) < opts.BatchSize {
+				continue
+			}
+			if err := sweep(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if len(batch) > 0 {
+		if err := sweep(); err != nil {
+			return err
+		}
+	}
+	esweep.Append(logging.LoggableMap{
+		"whiteSetSize": fmt.Sprintf("%d", removed),
+	})
+	esweep.Done()
+
+	if failed {
+		// keep the marked set for the next collection
+		return ErrCannotDeleteSomeBlocks
+	}
+	return m.clear()
+}
+
+// marker maintains the marked set of an incremental garbage collection.
+// Every marked block has its descendants marked too, except for the
+// missing descendants of the best effort roots.
+type marker struct {
+	ng    ipld.NodeGetter
+	pn    pin.Pinner
+	dstor dstore.Datastore
+	roots func() ([]cid.Cid, error)
+
+	marked *cid.Set
+	// direct holds the direct pins, which are kept without their
+	// descendants.
+	direct *cid.Set
+	// added holds the blocks marked since the last save.
+	added []cid.Cid
+
+	output chan<- Result
+}
+
+func (m *marker) visit(c cid.Cid) bool {
+	if !m.marked.Visit(c) {
+		return false
+	}
+	m.added = append(m.added, c)
+	return true
+}
+
+func (m *marker) keep(c cid.Cid) bool {
+	return m.marked.Has(c) || (m.direct != nil && m.direct.Has(c))
+}
+
+// mark adds the pins, and the best effort roots, not marked yet with their
+// descendants to the marked set.
+func (m *marker) mark(ctx context.Context) error {
+	errors := false
+	links := func(bestEffort bool) dag.GetLinks {
+		return func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+			links, err := ipld.GetLinks(ctx, m.ng, c)
+			if err != nil && !(bestEffort && err == ipld.ErrNotFound) {
+				errors = true
+				select {
+				case m.output <- Result{Error: &CannotFetchLinksError{c, err}}:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+			return links, nil
+		}
+	}
+	unmarked := func(roots []cid.Cid) []cid.Cid {
+		var out []cid.Cid
+		for _, c := range roots {
+			if !m.marked.Has(c) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+
+	bestEffortRoots, err := m.roots()
+	if err != nil {
+		return err
+	}
+	for _, step := range []struct {
+		roots      []cid.Cid
+		bestEffort bool
+	}{
+		{m.pn.RecursiveKeys(), false},
+		{bestEffortRoots, true},
+		{m.pn.InternalPins(), false},
+	} {
+		err := descendants(ctx, links(step.bestEffort), m.visit, unmarked(step.roots))
+		if err != nil {
+			return err
+		}
+	}
+
+	m.direct = cid.NewSet()
+	for _, k := range m.pn.DirectKeys() {
+		m.direct.Add(k)
+	}
+
+	if errors {
+		return ErrCannotFetchAllLinks
+	}
+	return nil
+}
+
+// sweepBatch removes the blocks of batch still unmarked once the pins added
+// since the last batch are marked. It returns the number of blocks removed
+// and false if some of them couldn't be.
+func (m *marker) sweepBatch(ctx context.Context, bs bstore.GCBlockstore, batch []cid.Cid) (int, bool, error) {
+	unlocker := bs.GCLock()
+
+	if err := m.mark(ctx); err != nil {
+		unlocker.Unlock()
+		return 0, true, err
+	}
+
+	var removed []cid.Cid
+	ok := true
+	for _, k := range batch {
+		if m.keep(k) {
+			continue
+		}
+		if err := bs.DeleteBlock(k); err != nil {
+			ok = false
+			m.output <- Result{Error: &CannotDeleteBlockError{k, err}}
+			continue
+		}
+		removed = append(removed, k)
+	}
+	unlocker.Unlock()
+
+	if err := m.save(false); err != nil {
+		return len(removed), ok, err
+	}
+	for _, k := range removed {
+		select {
+		case m.output <- Result{KeyRemoved: k}:
+		case <-ctx.Done():
+			return len(removed), ok, ctx.Err()
+		}
+	}
+	return len(removed), ok, nil
+}
+
+func markedKey(c cid.Cid) dstore.Key {
+	return markedPrefix.ChildString(c.String())
+}
+
+// load reads the marked set of an interrupted garbage collection. It
+// returns false if there was none, or if it was interrupted before the
+// marked set was complete.
+func (m *marker) load() (bool, error) {
+	state, err := m.dstor.Get(stateKey)
+	switch {
+	case err == dstore.ErrNotFound:
+	case err != nil:
+		return false, err
+	}
+	if string(state) != string(stateSweep) {
+		// incomplete
+		return false, m.clear()
+	}
+
+	res, err := m.dstor.Query(dsq.Query{Prefix: markedPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return false, err
+	}
+	defer res.Close()
+	for r := range res.Next() {
+		if r.Error != nil {
+			return false, r.Error
+		}
+		c, err := cid.Decode(dstore.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			log.Warningf("invalid marked block %s: %s", r.Key, err)
+			continue
+		}
+		m.marked.Add(c)
+	}
+	return true, nil
+}
+
+// save writes the blocks marked since the last save. The first save marks
+// the saved set as complete.
+func (m *marker) save(first bool) error {
+	for len(m.added) > 0 {
+		n := len(m.added)
+		if n > stateBatchSize {
+			n = stateBatchSize
+		}
+		if err := m.putAll(m.added[:n]); err != nil {
+			return err
+		}
+		m.added = m.added[n:]
+	}
+	m.added = nil
+
+	if first {
+		return m.dstor.Put(stateKey, stateSweep)
+	}
+	return nil
+}
+
+func (m *marker) putAll(cids []cid.Cid) error {
+	bds, ok := m.dstor.(dstore.Batching)
+	if !ok {
+		for _, c := range cids {
+			if err := m.dstor.Put(markedKey(c), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	b, err := bds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, c := range cids {
+		if err := b.Put(markedKey(c), nil); err != nil {
+			return err
+		}
+	}
+	return b.Commit()
+}
+
+// clear removes the saved state.
+func (m *marker) clear() error {
+	for {
+		res, err := m.dstor.Query(dsq.Query{Prefix: markedPrefix.String(), KeysOnly: true, Limit: stateBatchSize})
+		if err != nil {
+			return err
+		}
+		keys, err := res.Rest()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			break
+		}
+		for _, e := range keys {
+			if err := m.dstor.Delete(dstore.RawKey(e.Key)); err != nil && err != dstore.ErrNotFound {
+				return err
+			}
+		}
+	}
+
+	err := m.dstor.Delete(stateKey)
+	if err == dstore.ErrNotFound {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,15 @@
+package plugin
+
+import (
+	cmds "gx/ipfs/QmPXR4tNdLbp8HsZiPMjpsgqphX9Vhw2J6Jh5MKH2ovW3D/go-ipfs-cmds"
+)
+
+// PluginCommand is an interface that can be implemented to add top-level
+// commands, available on the command line and over the HTTP API
+type PluginCommand interface {
+	Plugin
+
+	// Commands returns the commands of the plugin by name. The names can't
+	// be the ones of built-in commands.
+	Commands() map[string]*cmds.Command
+}
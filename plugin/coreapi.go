@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+)
+
+// PluginCoreAPI is an interface that can be implemented to wrap the CoreAPI
+// used by the commands and the gateway, to change the behavior of some of
+// its methods
+type PluginCoreAPI interface {
+	Plugin
+
+	DecorateCoreAPI(api coreiface.CoreAPI) coreiface.CoreAPI
+}
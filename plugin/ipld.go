@@ -13,4 +13,9 @@ type PluginIPLD interface {
 
 	RegisterBlockDecoders(dec ipld.BlockDecoder) error
 	RegisterInputEncParsers(iec coredag.InputEncParsers) error
+
+	// RegisterCodecs registers the codecs of the formats, which makes them
+	// usable by 'ipfs dag put --input-codec/--store-codec', 'ipfs dag get
+	// --output-codec' and the ?format= parameter of the gateway.
+	RegisterCodecs(cs coredag.Codecs) error
 }
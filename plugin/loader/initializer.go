@@ -1,8 +1,16 @@
 package loader
 
 import (
+	"fmt"
+	"sort"
+
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/core/commands"
+	"github.com/ipfs/go-ipfs/core/coreapi"
 	"github.com/ipfs/go-ipfs/core/coredag"
+	"github.com/ipfs/go-ipfs/core/corepubsub"
 	"github.com/ipfs/go-ipfs/plugin"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
 	"gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
 
 	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
@@ -32,6 +40,28 @@ func run(plugins []plugin.Plugin) error {
 			if err != nil {
 				return err
 			}
+		case plugin.PluginPubsubValidator:
+			err := runPubsubValidatorPlugin(pl)
+			if err != nil {
+				return err
+			}
+		case plugin.PluginRouting:
+			err := pl.RegisterRoutingOptions(core.CustomRoutingOptions)
+			if err != nil {
+				return err
+			}
+		case plugin.PluginCommand:
+			err := runCommandPlugin(pl)
+			if err != nil {
+				return err
+			}
+		case plugin.PluginCoreAPI:
+			coreapi.AddDecorator(pl.DecorateCoreAPI)
+		case plugin.PluginDatastore:
+			err := fsrepo.AddDatastoreConfigHandler(pl.DatastoreTypeName(), pl.DatastoreConfigParser())
+			if err != nil {
+				return err
+			}
 		default:
 			panic(pl)
 		}
@@ -44,7 +74,11 @@ func runIPLDPlugin(pl plugin.PluginIPLD) error {
 	if err != nil {
 		return err
 	}
-	return pl.RegisterInputEncParsers(coredag.DefaultInputEncParsers)
+	err = pl.RegisterInputEncParsers(coredag.DefaultInputEncParsers)
+	if err != nil {
+		return err
+	}
+	return pl.RegisterCodecs(coredag.DefaultCodecs)
 }
 
 func runTracerPlugin(pl plugin.PluginTracer) error {
@@ -55,3 +89,23 @@ func runTracerPlugin(pl plugin.PluginTracer) error {
 	opentracing.SetGlobalTracer(tracer)
 	return nil
 }
+
+func runPubsubValidatorPlugin(pl plugin.PluginPubsubValidator) error {
+	return pl.RegisterPubsubValidators(corepubsub.DefaultValidators)
+}
+
+func runCommandPlugin(pl plugin.PluginCommand) error {
+	cmds := pl.Commands()
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := commands.AddCommand(name, cmds[name]); err != nil {
+			return fmt.Errorf("plugin %s: %s", pl.Name(), err)
+		}
+	}
+	return nil
+}
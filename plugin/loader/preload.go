@@ -3,6 +3,7 @@ package loader
 import (
 	"github.com/ipfs/go-ipfs/plugin"
 	pluginipldgit "github.com/ipfs/go-ipfs/plugin/plugins/git"
+	plugins3ds "github.com/ipfs/go-ipfs/plugin/plugins/s3ds"
 )
 
 // DO NOT EDIT THIS FILE
@@ -11,4 +12,5 @@ import (
 
 var preloadPlugins = []plugin.Plugin{
 	pluginipldgit.Plugins[0],
+	plugins3ds.Plugins[0],
 }
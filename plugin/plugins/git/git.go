@@ -2,6 +2,7 @@ package git
 
 import (
 	"compress/zlib"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -47,6 +48,29 @@ func (*gitPlugin) RegisterInputEncParsers(iec coredag.InputEncParsers) error {
 	return nil
 }
 
+func (*gitPlugin) RegisterCodecs(cs coredag.Codecs) error {
+	cs.AddCodec(&coredag.Codec{
+		Name:   "git-raw",
+		Code:   cid.GitRaw,
+		Decode: decodeGit,
+	})
+	return nil
+}
+
+// decodeGit converts a git object through its JSON representation, where the
+// links are written like in dag-json.
+func decodeGit(data []byte) (interface{}, error) {
+	nd, err := git.ParseObjectFromBuffer(data)
+	if err != nil {
+		return nil, err
+	}
+	js, err := json.Marshal(nd)
+	if err != nil {
+		return nil, err
+	}
+	return coredag.DecodeDagJSON(js)
+}
+
 func parseRawGit(r io.Reader, mhType uint64, mhLen int) ([]format.Node, error) {
 	if mhType != math.MaxUint64 && mhType != mh.SHA1 {
 		return nil, fmt.Errorf("unsupported mhType %d", mhType)
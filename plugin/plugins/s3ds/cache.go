@@ -0,0 +1,83 @@
+package s3ds
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is the read-through cache of the values of a bucket, evicting the
+// least recently used values past maxSize bytes.
+type cache struct {
+	maxSize int
+
+	lk      sync.Mutex
+	size    int
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	value []byte
+}
+
+func newCache(maxSize int) *cache {
+	return &cache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *cache) get(key string) ([]byte, bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).value, true
+}
+
+// add caches a copy of value. Values larger than the cache are not cached.
+func (c *cache) add(key string, value []byte) {
+	if len(value) > c.maxSize {
+		c.remove(key)
+		return
+	}
+	value = append([]byte(nil), value...)
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		c.size += len(value) - len(e.value)
+		e.value = value
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, value: value})
+		c.size += len(value)
+	}
+
+	for c.size > c.maxSize {
+		el := c.lru.Back()
+		e := el.Value.(*cacheEntry)
+		c.lru.Remove(el)
+		delete(c.entries, e.key)
+		c.size -= len(e.value)
+	}
+}
+
+func (c *cache) remove(key string) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		c.size -= len(el.Value.(*cacheEntry).value)
+	}
+}
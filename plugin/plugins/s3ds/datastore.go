@@ -0,0 +1,249 @@
+package s3ds
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+)
+
+// S3Bucket is a datastore storing every value as an object of an S3 bucket,
+// named after its key under the root directory.
+type S3Bucket struct {
+	client  *client
+	root    string // without leading or trailing slash
+	workers int
+	cache   *cache // nil when disabled
+}
+
+var _ ds.Batching = (*S3Bucket)(nil)
+
+// fullName returns the name of the object of key.
+func (s *S3Bucket) fullName(k ds.Key) string {
+	return strings.TrimPrefix(path.Join(s.root, k.String()), "/")
+}
+
+// key returns the key of the object name.
+func (s *S3Bucket) key(name string) ds.Key {
+	return ds.NewKey(strings.TrimPrefix(name, s.root))
+}
+
+// Put uploads the value of key.
+func (s *S3Bucket) Put(k ds.Key, value []byte) error {
+	if err := s.client.putObject(context.Background(), s.fullName(k), value); err != nil {
+		return err
+	}
+	if s.cache != nil {
+		s.cache.add(k.String(), value)
+	}
+	return nil
+}
+
+// Get returns the value of key, from the cache when it holds it.
+func (s *S3Bucket) Get(k ds.Key) ([]byte, error) {
+	if s.cache != nil {
+		if v, ok := s.cache.get(k.String()); ok {
+			return v, nil
+		}
+	}
+
+	v, err := s.client.getObject(context.Background(), s.fullName(k))
+	if err == errNotFound {
+		return nil, ds.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		s.cache.add(k.String(), v)
+	}
+	return v, nil
+}
+
+// Has returns whether key has a value.
+func (s *S3Bucket) Has(k ds.Key) (bool, error) {
+	_, err := s.GetSize(k)
+	switch err {
+	case nil:
+		return true, nil
+	case ds.ErrNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// GetSize returns the size of the value of key.
+func (s *S3Bucket) GetSize(k ds.Key) (int, error) {
+	if s.cache != nil {
+		if v, ok := s.cache.get(k.String()); ok {
+			return len(v), nil
+		}
+	}
+
+	size, err := s.client.headObject(context.Background(), s.fullName(k))
+	if err == errNotFound {
+		return -1, ds.ErrNotFound
+	}
+	if err != nil {
+		return -1, err
+	}
+	return int(size), nil
+}
+
+// Delete removes the value of key. Deleting a missing key is not an error
+// of S3, so it isn't reported.
+func (s *S3Bucket) Delete(k ds.Key) error {
+	if s.cache != nil {
+		s.cache.remove(k.String())
+	}
+	err := s.client.deleteObject(context.Background(), s.fullName(k))
+	if err == errNotFound {
+		return nil
+	}
+	return err
+}
+
+// Query lists the objects of the bucket under the query prefix, fetching
+// their values unless q.KeysOnly is set. Filters and orders are applied
+// locally.
+func (s *S3Bucket) Query(q dsq.Query) (dsq.Results, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	prefix := s.fullName(ds.NewKey(q.Prefix))
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var (
+		page  *listResult
+		index int
+		token string
+		done  bool
+	)
+	next := func() (dsq.Result, bool) {
+		for page == nil || index >= len(page.Contents) {
+			if done {
+				return dsq.Result{}, false
+			}
+			var err error
+			page, err = s.client.listObjects(ctx, prefix, token)
+			if err != nil {
+				done = true
+				return dsq.Result{Error: err}, true
+			}
+			index = 0
+			token = page.NextContinuationToken
+			done = !page.IsTruncated
+		}
+
+		obj := page.Contents[index]
+		index++
+
+		k := s.key(obj.Key)
+		e := dsq.Entry{Key: k.String()}
+		if !q.KeysOnly {
+			v, err := s.Get(k)
+			if err != nil {
+				return dsq.Result{Error: err}, true
+			}
+			e.Value = v
+		}
+		return dsq.Result{Entry: e}, true
+	}
+
+	qr := dsq.ResultsFromIterator(q, dsq.Iterator{
+		Next: next,
+		Close: func() error {
+			cancel()
+			return nil
+		},
+	})
+	return dsq.NaiveQueryApply(q, qr), nil
+}
+
+// Close releases nothing, the bucket holds no resources.
+func (s *S3Bucket) Close() error {
+	return nil
+}
+
+// Batch returns a batch whose operations are sent in parallel on commit.
+func (s *S3Bucket) Batch() (ds.Batch, error) {
+	return &s3Batch{s: s, ops: make(map[ds.Key]batchOp)}, nil
+}
+
+type batchOp struct {
+	value  []byte
+	delete bool
+}
+
+type s3Batch struct {
+	s   *S3Bucket
+	ops map[ds.Key]batchOp
+}
+
+func (b *s3Batch) Put(k ds.Key, value []byte) error {
+	b.ops[k] = batchOp{value: value}
+	return nil
+}
+
+func (b *s3Batch) Delete(k ds.Key) error {
+	b.ops[k] = batchOp{delete: true}
+	return nil
+}
+
+// Commit runs the operations of the batch with the workers of the bucket,
+// and returns the first error.
+func (b *s3Batch) Commit() error {
+	jobs := make(chan ds.Key)
+	errs := make(chan error, b.s.workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				op := b.ops[k]
+				var err error
+				if op.delete {
+					err = b.s.Delete(k)
+				} else {
+					err = b.s.Put(k, op.value)
+				}
+				if err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	var err error
+loop:
+	for k := range b.ops {
+		select {
+		case jobs <- k:
+		case err = <-errs:
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	if err == nil {
+		b.ops = make(map[ds.Key]batchOp)
+	}
+	return err
+}
@@ -0,0 +1,224 @@
+// Package s3ds is a datastore plugin storing the values in a bucket of S3 or
+// of a compatible object store.
+//
+// It is meant to hold the blocks, mounted on /blocks, while the rest of the
+// datastore stays local. See docs/datastores.md for the spec.
+package s3ds
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-ipfs/plugin"
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+)
+
+var log = logging.Logger("s3ds")
+
+const (
+	defaultWorkers    = 16
+	defaultMaxRetries = 5
+	defaultCacheSize  = 64 << 20
+	requestTimeout    = time.Minute
+)
+
+// Plugins is exported list of plugins that will be loaded
+var Plugins = []plugin.Plugin{
+	&s3Plugin{},
+}
+
+type s3Plugin struct{}
+
+var _ plugin.PluginDatastore = (*s3Plugin)(nil)
+
+func (*s3Plugin) Name() string {
+	return "ds-s3"
+}
+
+func (*s3Plugin) Version() string {
+	return "0.0.1"
+}
+
+func (*s3Plugin) Init() error {
+	return nil
+}
+
+func (*s3Plugin) DatastoreTypeName() string {
+	return "s3ds"
+}
+
+func (*s3Plugin) DatastoreConfigParser() fsrepo.ConfigFromMap {
+	return ParseConfig
+}
+
+// Config is the configuration of an S3Bucket.
+type Config struct {
+	Region         string
+	Bucket         string
+	RootDirectory  string
+	RegionEndpoint string
+
+	// The credentials default to the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// Workers is the number of parallel uploads of a batch.
+	Workers int
+	// MaxRetries is the number of retries of a failed request.
+	MaxRetries int
+	// CacheSize is the size of the read-through cache, 0 disables it.
+	CacheSize int
+}
+
+// ParseConfig returns the configuration of an S3Bucket from its spec.
+func ParseConfig(params map[string]interface{}) (fsrepo.DatastoreConfig, error) {
+	c := &Config{
+		Workers:    defaultWorkers,
+		MaxRetries: defaultMaxRetries,
+		CacheSize:  defaultCacheSize,
+	}
+
+	for _, f := range []struct {
+		name     string
+		dst      *string
+		required bool
+	}{
+		{"region", &c.Region, true},
+		{"bucket", &c.Bucket, true},
+		{"rootDirectory", &c.RootDirectory, false},
+		{"regionEndpoint", &c.RegionEndpoint, false},
+		{"accessKey", &c.AccessKey, false},
+		{"secretKey", &c.SecretKey, false},
+		{"sessionToken", &c.SessionToken, false},
+	} {
+		v, ok := params[f.name]
+		if !ok {
+			if f.required {
+				return nil, fmt.Errorf("'%s' field is missing", f.name)
+			}
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("'%s' field was not a string", f.name)
+		}
+		*f.dst = s
+	}
+	c.RootDirectory = strings.Trim(c.RootDirectory, "/")
+
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{
+		{"workers", &c.Workers},
+		{"maxRetries", &c.MaxRetries},
+	} {
+		v, ok := params[f.name]
+		if !ok {
+			continue
+		}
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != float64(int(n)) {
+			return nil, fmt.Errorf("'%s' field was not a positive integer", f.name)
+		}
+		*f.dst = int(n)
+	}
+	if c.Workers == 0 {
+		return nil, fmt.Errorf("'workers' field must be at least 1")
+	}
+
+	if v, ok := params["cacheSize"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("'cacheSize' field was not a string")
+		}
+		size, err := humanize.ParseBytes(s)
+		if err != nil {
+			return nil, err
+		}
+		c.CacheSize = int(size)
+	}
+
+	return c, nil
+}
+
+// DiskSpec identifies the bucket; the credentials and tuning may change
+// without migrating the datastore.
+func (c *Config) DiskSpec() fsrepo.DiskSpec {
+	return fsrepo.DiskSpec{
+		"type":          "s3ds",
+		"region":        c.Region,
+		"bucket":        c.Bucket,
+		"rootDirectory": c.RootDirectory,
+	}
+}
+
+// Create returns the S3Bucket. The repo path is unused.
+func (c *Config) Create(string) (repo.Datastore, error) {
+	return NewS3Bucket(c)
+}
+
+// NewS3Bucket returns a datastore storing its values in the bucket of cfg.
+func NewS3Bucket(cfg *Config) (*S3Bucket, error) {
+	endpoint := "https://s3." + cfg.Region + ".amazonaws.com"
+	if cfg.RegionEndpoint != "" {
+		endpoint = cfg.RegionEndpoint
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %s", err)
+	}
+
+	c := &client{
+		endpoint: u,
+		// custom endpoints, such as minio, rarely resolve the bucket
+		// subdomains.
+		pathStyle:    cfg.RegionEndpoint != "",
+		region:       cfg.Region,
+		bucket:       cfg.Bucket,
+		accessKey:    cfg.AccessKey,
+		secretKey:    cfg.SecretKey,
+		sessionToken: cfg.SessionToken,
+		maxRetries:   cfg.MaxRetries,
+		http: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConnsPerHost: cfg.Workers,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		now: time.Now,
+	}
+	if c.accessKey == "" {
+		c.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		c.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		c.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, fmt.Errorf("no credentials for the S3 bucket %s", cfg.Bucket)
+	}
+
+	s := &S3Bucket{
+		client:  c,
+		root:    cfg.RootDirectory,
+		workers: cfg.Workers,
+	}
+	if cfg.CacheSize > 0 {
+		s.cache = newCache(cfg.CacheSize)
+	}
+	return s, nil
+}
@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-ipfs/core/corepubsub"
+)
+
+// PluginPubsubValidator is an interface that can be implemented to add named
+// pubsub message validators which can then be assigned to topics with the
+// Pubsub.TopicValidators config option
+type PluginPubsubValidator interface {
+	Plugin
+
+	RegisterPubsubValidators(vs corepubsub.Validators) error
+}
@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-ipfs/core"
+)
+
+// PluginRouting is an interface that can be implemented to add named content
+// routers, which the daemon uses when Routing.Type is "custom" and
+// Routing.Custom names them
+type PluginRouting interface {
+	Plugin
+
+	RegisterRoutingOptions(ro core.RoutingOptions) error
+}
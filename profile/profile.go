@@ -0,0 +1,250 @@
+// Package profile collects the runtime profiles and state of the daemon into
+// a zip archive meant to be attached to bug reports.
+package profile
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	version "github.com/ipfs/go-ipfs"
+
+	prometheus "gx/ipfs/QmYYv3QFnfQbiwmi1tpkgKF8o4xFnZoBrvpupTiGJwL9nH/client_golang/prometheus"
+)
+
+// Collectors select the parts of the archive.
+const (
+	// CollectGoroutines dumps the stacks of all goroutines.
+	CollectGoroutines = "goroutines"
+	// CollectHeap writes the heap profile.
+	CollectHeap = "heap"
+	// CollectCPU profiles the CPU for the profile time.
+	CollectCPU = "cpu"
+	// CollectMutex profiles the mutex contention for the profile time.
+	CollectMutex = "mutex"
+	// CollectVersion writes the version of go-ipfs, Go and the system.
+	CollectVersion = "version"
+	// CollectMetrics writes the prometheus metrics.
+	CollectMetrics = "metrics"
+	// CollectConfig writes Options.Config.
+	CollectConfig = "config"
+)
+
+// AllCollectors lists every collector, in the order they run.
+var AllCollectors = []string{
+	CollectGoroutines,
+	CollectHeap,
+	CollectVersion,
+	CollectMetrics,
+	CollectConfig,
+	CollectCPU,
+	CollectMutex,
+}
+
+// DefaultProfileTime is the time the CPU and mutex profiles are sampled.
+const DefaultProfileTime = 30 * time.Second
+
+// DefaultMutexProfileFraction is the rate of the mutex profile, on average 1
+// in that many contention events is reported.
+const DefaultMutexProfileFraction = 4
+
+// Options configure WriteProfiles.
+type Options struct {
+	// Collectors are the parts to collect, all of them when empty.
+	Collectors []string
+
+	// ProfileTime is the time the CPU and mutex profiles are sampled.
+	ProfileTime time.Duration
+
+	// MutexProfileFraction is the rate of the mutex profile while it's
+	// sampled.
+	MutexProfileFraction int
+
+	// Config is the redacted config of the node, written by CollectConfig.
+	Config []byte
+}
+
+// VersionInfo is the content of version.json.
+type VersionInfo struct {
+	Version string
+	Commit  string
+	Go      string
+	System  string
+	NumCPU  int
+}
+
+// CheckCollectors returns an error if one of the collectors is unknown.
+func CheckCollectors(collectors []string) error {
+	for _, c := range collectors {
+		if !isCollector(c) {
+			return fmt.Errorf("unknown collector %q, expected one of %v", c, AllCollectors)
+		}
+	}
+	return nil
+}
+
+func isCollector(c string) bool {
+	for _, a := range AllCollectors {
+		if a == c {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteProfiles writes the collected parts to zw, as one file each. The CPU
+// and mutex profiles are sampled for opts.ProfileTime or until ctx is done.
+func WriteProfiles(ctx context.Context, zw *zip.Writer, opts Options) error {
+	if err := CheckCollectors(opts.Collectors); err != nil {
+		return err
+	}
+	enabled := func(c string) bool {
+		if len(opts.Collectors) == 0 {
+			return true
+		}
+		for _, o := range opts.Collectors {
+			if o == c {
+				return true
+			}
+		}
+		return false
+	}
+
+	// the instant profiles first, so they show the state the node was in
+	// when asked.
+	if enabled(CollectGoroutines) {
+		err := writeFile(zw, "goroutines.stacks", func(w io.Writer) error {
+			return pprof.Lookup("goroutine").WriteTo(w, 2)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if enabled(CollectHeap) {
+		err := writeFile(zw, "heap.pprof", func(w io.Writer) error {
+			return pprof.Lookup("heap").WriteTo(w, 0)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if enabled(CollectVersion) {
+		err := writeFile(zw, "version.json", func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(&VersionInfo{
+				Version: version.CurrentVersionNumber,
+				Commit:  version.CurrentCommit,
+				Go:      runtime.Version(),
+				System:  runtime.GOARCH + "/" + runtime.GOOS,
+				NumCPU:  runtime.NumCPU(),
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if enabled(CollectMetrics) {
+		if err := writeFile(zw, "metrics.txt", writeMetrics); err != nil {
+			return err
+		}
+	}
+	if enabled(CollectConfig) && opts.Config != nil {
+		err := writeFile(zw, "config.json", func(w io.Writer) error {
+			_, err := w.Write(opts.Config)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cpu, mutex := enabled(CollectCPU), enabled(CollectMutex)
+	if !cpu && !mutex {
+		return nil
+	}
+	return writeSampled(ctx, zw, cpu, mutex, opts)
+}
+
+// writeSampled samples the CPU and mutex profiles together, they can't be
+// written to zw while sampling as the zip entries are sequential.
+func writeSampled(ctx context.Context, zw *zip.Writer, cpu, mutex bool, opts Options) error {
+	profileTime := opts.ProfileTime
+	if profileTime <= 0 {
+		profileTime = DefaultProfileTime
+	}
+
+	if mutex {
+		fraction := opts.MutexProfileFraction
+		if fraction <= 0 {
+			fraction = DefaultMutexProfileFraction
+		}
+		prev := runtime.SetMutexProfileFraction(fraction)
+		defer runtime.SetMutexProfileFraction(prev)
+	}
+
+	var cpuW io.Writer
+	if cpu {
+		var err error
+		cpuW, err = zw.Create("cpu.pprof")
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(cpuW); err != nil {
+			return fmt.Errorf("failed to start the cpu profile: %s", err)
+		}
+	}
+
+	t := time.NewTimer(profileTime)
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		t.Stop()
+	}
+
+	if cpu {
+		pprof.StopCPUProfile()
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if mutex {
+		return writeFile(zw, "mutex.pprof", func(w io.Writer) error {
+			return pprof.Lookup("mutex").WriteTo(w, 0)
+		})
+	}
+	return nil
+}
+
+func writeFile(zw *zip.Writer, name string, write func(io.Writer) error) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return write(w)
+}
+
+// writeMetrics writes the metrics as served on /debug/metrics/prometheus.
+func writeMetrics(w io.Writer) error {
+	req, err := http.NewRequest("GET", "/debug/metrics/prometheus", nil)
+	if err != nil {
+		return err
+	}
+	rec := httptest.NewRecorder()
+	prometheus.UninstrumentedHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("failed to gather the metrics: %s", rec.Body.String())
+	}
+	_, err = rec.Body.WriteTo(w)
+	return err
+}
@@ -0,0 +1,80 @@
+package profile
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWriteProfiles(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := WriteProfiles(context.Background(), zw, Options{
+		Collectors:  []string{CollectGoroutines, CollectVersion, CollectConfig, CollectCPU, CollectMutex},
+		ProfileTime: 100 * time.Millisecond,
+		Config:      []byte(`{"Datastore":{}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	var names []string
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = b
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	expected := []string{"config.json", "cpu.pprof", "goroutines.stacks", "mutex.pprof", "version.json"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+	}
+
+	if !bytes.Contains(files["goroutines.stacks"], []byte("TestWriteProfiles")) {
+		t.Error("expected the stack of the test in the goroutine dump")
+	}
+	if string(files["config.json"]) != `{"Datastore":{}}` {
+		t.Errorf("unexpected config %q", files["config.json"])
+	}
+	var v VersionInfo
+	if err := json.Unmarshal(files["version.json"], &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Version == "" || v.Go == "" {
+		t.Errorf("incomplete version info %+v", v)
+	}
+}
+
+func TestUnknownCollector(t *testing.T) {
+	zw := zip.NewWriter(ioutil.Discard)
+	if err := WriteProfiles(context.Background(), zw, Options{Collectors: []string{"threads"}}); err == nil {
+		t.Fatal("expected an error for an unknown collector")
+	}
+}
@@ -0,0 +1,420 @@
+// Package rcmgr implements a resource manager limiting the connections,
+// streams, file descriptors and memory used by the libp2p host.
+//
+// Limits are enforced at three scopes: the system as a whole, each remote
+// peer and each protocol. Connections and streams exceeding a limit are
+// closed as soon as they are opened.
+package rcmgr
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+var log = logging.Logger("rcmgr")
+
+// Stream protocols are only known once negotiated, after the stream has been
+// opened, so protocol limits are enforced periodically.
+var protocolCheckInterval = time.Second
+
+// heap usage is sampled at most this often
+var memSampleInterval = time.Second
+
+// DefaultLimits are the limits used for the fields not set in the config. Zero
+// means unlimited.
+var DefaultLimits = extconfig.ResourceMgrLimits{
+	System: extconfig.ResourceLimit{
+		Conns:        2048,
+		ConnsInbound: 1024,
+		Streams:      16384,
+	},
+	Peer: extconfig.ResourceLimit{
+		Conns:   8,
+		Streams: 512,
+	},
+	Protocol: extconfig.ResourceLimit{
+		Streams: 4096,
+	},
+}
+
+// Usage is the current resource usage of a scope.
+type Usage struct {
+	ConnsInbound  int
+	ConnsOutbound int
+	Streams       int
+	FD            int
+	Memory        int64 `json:",omitempty"`
+}
+
+// Conns returns the total number of connections.
+func (u Usage) Conns() int {
+	return u.ConnsInbound + u.ConnsOutbound
+}
+
+// Stats is a snapshot of the usage and blocked counts of every scope.
+type Stats struct {
+	System    Usage
+	Peers     map[peer.ID]Usage
+	Protocols map[protocol.ID]Usage
+
+	// BlockedConns and BlockedStreams count the connections and streams
+	// closed for exceeding a limit.
+	BlockedConns   uint64
+	BlockedStreams uint64
+}
+
+// ResourceManager enforces resource limits on a libp2p network.
+type ResourceManager struct {
+	network inet.Network
+	notifee *inet.NotifyBundle
+	closing chan struct{}
+
+	lk        sync.Mutex
+	config    extconfig.ResourceMgrLimits
+	limits    extconfig.ResourceMgrLimits
+	system    Usage
+	peers     map[peer.ID]*Usage
+	conns     map[inet.Conn]struct{}
+	streams   map[inet.Stream]struct{}
+	blockedC  uint64
+	blockedS  uint64
+	memSample time.Time
+	memInUse  int64
+}
+
+// NewResourceManager starts enforcing the limits on the network. Fields left
+// unset in limits use the corresponding DefaultLimits.
+func NewResourceManager(network inet.Network, limits *extconfig.ResourceMgrLimits) *ResourceManager {
+	var cfg extconfig.ResourceMgrLimits
+	if limits != nil {
+		cfg = *limits
+	}
+
+	rm := &ResourceManager{
+		network: network,
+		closing: make(chan struct{}),
+		config:  cfg,
+		limits:  withDefaults(cfg),
+		peers:   make(map[peer.ID]*Usage),
+		conns:   make(map[inet.Conn]struct{}),
+		streams: make(map[inet.Stream]struct{}),
+	}
+
+	rm.notifee = &inet.NotifyBundle{
+		ConnectedF:    rm.connected,
+		DisconnectedF: rm.disconnected,
+		OpenedStreamF: rm.openedStream,
+		ClosedStreamF: rm.closedStream,
+	}
+	network.Notify(rm.notifee)
+
+	go rm.enforceProtocolLimits()
+	return rm
+}
+
+// withDefaults returns the limits in effect for the configured limits: the
+// unset fields of the scopes take the default of the scope, and those of
+// specific peers and protocols the limit of the peer and protocol scopes.
+func withDefaults(cfg extconfig.ResourceMgrLimits) extconfig.ResourceMgrLimits {
+	out := extconfig.ResourceMgrLimits{
+		System:   mergeLimit(cfg.System, DefaultLimits.System),
+		Peer:     mergeLimit(cfg.Peer, DefaultLimits.Peer),
+		Protocol: mergeLimit(cfg.Protocol, DefaultLimits.Protocol),
+	}
+	if len(cfg.Peers) > 0 {
+		out.Peers = make(map[string]extconfig.ResourceLimit, len(cfg.Peers))
+		for p, l := range cfg.Peers {
+			out.Peers[p] = mergeLimit(l, out.Peer)
+		}
+	}
+	if len(cfg.Protocols) > 0 {
+		out.Protocols = make(map[string]extconfig.ResourceLimit, len(cfg.Protocols))
+		for proto, l := range cfg.Protocols {
+			out.Protocols[proto] = mergeLimit(l, out.Protocol)
+		}
+	}
+	return out
+}
+
+// mergeLimit returns l with its unset fields taken from def.
+func mergeLimit(l, def extconfig.ResourceLimit) extconfig.ResourceLimit {
+	pick := func(v, d int) int {
+		if v == 0 {
+			return d
+		}
+		return v
+	}
+	l.Conns = pick(l.Conns, def.Conns)
+	l.ConnsInbound = pick(l.ConnsInbound, def.ConnsInbound)
+	l.ConnsOutbound = pick(l.ConnsOutbound, def.ConnsOutbound)
+	l.Streams = pick(l.Streams, def.Streams)
+	l.FD = pick(l.FD, def.FD)
+	if l.Memory == 0 {
+		l.Memory = def.Memory
+	}
+	return l
+}
+
+// Close stops enforcing limits.
+func (rm *ResourceManager) Close() error {
+	rm.network.StopNotify(rm.notifee)
+	close(rm.closing)
+	return nil
+}
+
+// Limits returns the limits currently in effect, defaults included.
+func (rm *ResourceManager) Limits() extconfig.ResourceMgrLimits {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+	return rm.limits
+}
+
+// Config returns the configured limits, without the defaults.
+func (rm *ResourceManager) Config() extconfig.ResourceMgrLimits {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+	return rm.config
+}
+
+// SetLimits replaces the configured limits; unset fields use the defaults.
+// Already open connections and streams are not closed, but count towards the
+// new limits.
+func (rm *ResourceManager) SetLimits(limits extconfig.ResourceMgrLimits) {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+	rm.config = limits
+	rm.limits = withDefaults(limits)
+}
+
+// PeerLimit returns the limit applied to the given peer.
+func (rm *ResourceManager) PeerLimit(p peer.ID) extconfig.ResourceLimit {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+	return rm.peerLimitLocked(p)
+}
+
+func (rm *ResourceManager) peerLimitLocked(p peer.ID) extconfig.ResourceLimit {
+	if l, ok := rm.limits.Peers[peer.IDB58Encode(p)]; ok {
+		return l
+	}
+	return rm.limits.Peer
+}
+
+// ProtocolLimit returns the limit applied to the given protocol.
+func (rm *ResourceManager) ProtocolLimit(proto protocol.ID) extconfig.ResourceLimit {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+	return rm.protocolLimitLocked(proto)
+}
+
+func (rm *ResourceManager) protocolLimitLocked(proto protocol.ID) extconfig.ResourceLimit {
+	if l, ok := rm.limits.Protocols[string(proto)]; ok {
+		return l
+	}
+	return rm.limits.Protocol
+}
+
+// Stat returns a snapshot of the current usage.
+func (rm *ResourceManager) Stat() Stats {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+
+	st := Stats{
+		System:         rm.system,
+		Peers:          make(map[peer.ID]Usage, len(rm.peers)),
+		Protocols:      make(map[protocol.ID]Usage),
+		BlockedConns:   rm.blockedC,
+		BlockedStreams: rm.blockedS,
+	}
+	st.System.Memory = rm.memoryLocked()
+	for p, u := range rm.peers {
+		st.Peers[p] = *u
+	}
+	for s := range rm.streams {
+		u := st.Protocols[s.Protocol()]
+		u.Streams++
+		st.Protocols[s.Protocol()] = u
+	}
+	return st
+}
+
+// memoryLocked returns the sampled heap usage. rm.lk must be held.
+func (rm *ResourceManager) memoryLocked() int64 {
+	if time.Since(rm.memSample) > memSampleInterval {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		rm.memInUse = int64(ms.HeapInuse)
+		rm.memSample = time.Now()
+	}
+	return rm.memInUse
+}
+
+func (rm *ResourceManager) peerUsageLocked(p peer.ID) *Usage {
+	u, ok := rm.peers[p]
+	if !ok {
+		u = new(Usage)
+		rm.peers[p] = u
+	}
+	return u
+}
+
+func (rm *ResourceManager) releasePeerLocked(p peer.ID) {
+	if u, ok := rm.peers[p]; ok && *u == (Usage{}) {
+		delete(rm.peers, p)
+	}
+}
+
+// exceedsConn returns whether adding a connection in direction dir to a scope
+// with usage u would exceed limit l.
+func exceedsConn(u Usage, l extconfig.ResourceLimit, dir inet.Direction) bool {
+	switch {
+	case l.Conns > 0 && u.Conns()+1 > l.Conns:
+		return true
+	case l.FD > 0 && u.FD+1 > l.FD:
+		return true
+	case dir == inet.DirInbound && l.ConnsInbound > 0 && u.ConnsInbound+1 > l.ConnsInbound:
+		return true
+	case dir == inet.DirOutbound && l.ConnsOutbound > 0 && u.ConnsOutbound+1 > l.ConnsOutbound:
+		return true
+	}
+	return false
+}
+
+func (rm *ResourceManager) overMemoryLocked() bool {
+	return rm.limits.System.Memory > 0 && rm.memoryLocked() > rm.limits.System.Memory
+}
+
+func (rm *ResourceManager) connected(_ inet.Network, c inet.Conn) {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+
+	p := c.RemotePeer()
+	dir := c.Stat().Direction
+	pu := rm.peerUsageLocked(p)
+
+	if exceedsConn(rm.system, rm.limits.System, dir) ||
+		exceedsConn(*pu, rm.peerLimitLocked(p), dir) ||
+		(dir == inet.DirInbound && rm.overMemoryLocked()) {
+		rm.blockedC++
+		rm.releasePeerLocked(p)
+		log.Debugf("closing connection to %s: resource limit exceeded", p.Pretty())
+		go c.Close()
+		return
+	}
+
+	rm.conns[c] = struct{}{}
+	for _, u := range []*Usage{&rm.system, pu} {
+		u.FD++
+		if dir == inet.DirInbound {
+			u.ConnsInbound++
+		} else {
+			u.ConnsOutbound++
+		}
+	}
+}
+
+func (rm *ResourceManager) disconnected(_ inet.Network, c inet.Conn) {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+
+	if _, ok := rm.conns[c]; !ok {
+		return
+	}
+	delete(rm.conns, c)
+
+	p := c.RemotePeer()
+	dir := c.Stat().Direction
+	for _, u := range []*Usage{&rm.system, rm.peerUsageLocked(p)} {
+		u.FD--
+		if dir == inet.DirInbound {
+			u.ConnsInbound--
+		} else {
+			u.ConnsOutbound--
+		}
+	}
+	rm.releasePeerLocked(p)
+}
+
+func (rm *ResourceManager) openedStream(_ inet.Network, s inet.Stream) {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+
+	p := s.Conn().RemotePeer()
+	pu := rm.peerUsageLocked(p)
+	pl := rm.peerLimitLocked(p)
+
+	if (rm.limits.System.Streams > 0 && rm.system.Streams+1 > rm.limits.System.Streams) ||
+		(pl.Streams > 0 && pu.Streams+1 > pl.Streams) ||
+		rm.overMemoryLocked() {
+		rm.blockedS++
+		rm.releasePeerLocked(p)
+		go s.Reset()
+		return
+	}
+
+	rm.streams[s] = struct{}{}
+	rm.system.Streams++
+	pu.Streams++
+}
+
+func (rm *ResourceManager) closedStream(_ inet.Network, s inet.Stream) {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+
+	if _, ok := rm.streams[s]; !ok {
+		return
+	}
+	delete(rm.streams, s)
+
+	p := s.Conn().RemotePeer()
+	rm.system.Streams--
+	rm.peerUsageLocked(p).Streams--
+	rm.releasePeerLocked(p)
+}
+
+func (rm *ResourceManager) enforceProtocolLimits() {
+	t := time.NewTicker(protocolCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			for _, s := range rm.excessProtocolStreams() {
+				s.Reset()
+			}
+		case <-rm.closing:
+			return
+		}
+	}
+}
+
+// excessProtocolStreams returns the streams exceeding their protocol limit.
+func (rm *ResourceManager) excessProtocolStreams() []inet.Stream {
+	rm.lk.Lock()
+	defer rm.lk.Unlock()
+
+	counts := make(map[protocol.ID]int)
+	var excess []inet.Stream
+	for s := range rm.streams {
+		proto := s.Protocol()
+		if proto == "" {
+			// not negotiated yet
+			continue
+		}
+
+		counts[proto]++
+		if l := rm.protocolLimitLocked(proto); l.Streams > 0 && counts[proto] > l.Streams {
+			excess = append(excess, s)
+		}
+	}
+	rm.blockedS += uint64(len(excess))
+	return excess
+}
@@ -0,0 +1,109 @@
+package rcmgr
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	mocknet "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/net/mock"
+)
+
+func TestSystemInboundLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+
+	rm := NewResourceManager(hosts[0].Network(), &extconfig.ResourceMgrLimits{
+		System: extconfig.ResourceLimit{ConnsInbound: 1},
+	})
+	defer rm.Close()
+
+	if _, err := mn.ConnectPeers(hosts[1].ID(), hosts[0].ID()); err != nil {
+		t.Fatal(err)
+	}
+	// the second inbound connection is accepted by the network, then closed
+	mn.ConnectPeers(hosts[2].ID(), hosts[0].ID())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(hosts[0].Network().ConnsToPeer(hosts[2].ID())) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("connection over the limit was not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	st := rm.Stat()
+	if st.System.ConnsInbound != 1 {
+		t.Fatalf("expected 1 inbound connection, got %d", st.System.ConnsInbound)
+	}
+	if st.BlockedConns != 1 {
+		t.Fatalf("expected 1 blocked connection, got %d", st.BlockedConns)
+	}
+	if _, ok := st.Peers[hosts[1].ID()]; !ok {
+		t.Fatal("expected usage for the connected peer")
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	p := "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+	l := withDefaults(extconfig.ResourceMgrLimits{
+		System: extconfig.ResourceLimit{Conns: extconfig.Unlimited, ConnsInbound: 10},
+		Peers:  map[string]extconfig.ResourceLimit{p: {Streams: 5}},
+	})
+
+	if l.System.Conns != extconfig.Unlimited {
+		t.Fatalf("expected unlimited connections to be kept, got %d", l.System.Conns)
+	}
+	if l.System.ConnsInbound != 10 {
+		t.Fatalf("expected configured inbound limit, got %d", l.System.ConnsInbound)
+	}
+	if l.System.Streams != DefaultLimits.System.Streams {
+		t.Fatalf("expected default stream limit, got %d", l.System.Streams)
+	}
+	if l.Peer != DefaultLimits.Peer {
+		t.Fatalf("expected default peer limit, got %+v", l.Peer)
+	}
+	if pl := l.Peers[p]; pl.Streams != 5 || pl.Conns != DefaultLimits.Peer.Conns {
+		t.Fatalf("expected peer limit merged with the peer scope, got %+v", pl)
+	}
+}
+
+func TestUnlimitedSurvivesReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &extconfig.ResourceMgrLimits{
+		Peer: extconfig.ResourceLimit{Conns: extconfig.Unlimited},
+	}
+	rm := NewResourceManager(mn.Hosts()[0].Network(), cfg)
+	defer rm.Close()
+
+	// the persisted limits are the configured ones, without the defaults
+	b, err := json.Marshal(rm.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reloaded extconfig.ResourceMgrLimits
+	if err := json.Unmarshal(b, &reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Peer.Streams != 0 || reloaded.System != (extconfig.ResourceLimit{}) {
+		t.Fatalf("expected only the configured fields to be persisted, got %s", b)
+	}
+	if l := withDefaults(reloaded).Peer; l.Conns != extconfig.Unlimited {
+		t.Fatalf("expected peer connections to stay unlimited, got %d", l.Conns)
+	}
+}
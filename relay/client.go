@@ -0,0 +1,297 @@
+package relay
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	p2putil "github.com/ipfs/go-ipfs/internal/p2putil"
+	pb "github.com/ipfs/go-ipfs/relay/pb"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+// Limit is the limit a relay applies to a relayed connection, zero values
+// meaning no limit.
+type Limit struct {
+	Duration time.Duration
+	Data     int64
+}
+
+func limitFromPB(l *pb.Limit) Limit {
+	return Limit{
+		Duration: time.Duration(l.GetDuration()) * time.Second,
+		Data:     int64(l.GetData()),
+	}
+}
+
+// Reservation is a slot held on a relay, through which the other peers can
+// connect to the node until it expires.
+type Reservation struct {
+	Relay  peer.ID
+	Expire time.Time
+	// Addrs are the addresses of the node through the relay.
+	Addrs []ma.Multiaddr
+	// Limit applies to every connection relayed to the node.
+	Limit   Limit
+	Voucher *Voucher
+}
+
+// Conn is a connection relayed between two peers, the stream of each with
+// the relay. The peers run the libp2p handshakes over it like over a TCP
+// connection.
+type Conn struct {
+	inet.Stream
+	r *bufio.Reader
+
+	relay  peer.ID
+	remote peer.ID
+	limit  Limit
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// Relay returns the relay of the connection.
+func (c *Conn) Relay() peer.ID { return c.relay }
+
+// RemotePeer returns the peer at the other end of the connection.
+func (c *Conn) RemotePeer() peer.ID { return c.remote }
+
+// Limit returns the limit the relay applies to the connection.
+func (c *Conn) Limit() Limit { return c.limit }
+
+// Client holds reservations on relays for its host and accepts the
+// connections they relay to it.
+type Client struct {
+	host    host.Host
+	handler func(*Conn)
+
+	lk   sync.Mutex
+	rsvp map[peer.ID]*Reservation
+}
+
+// NewClient accepts the connections relayed to h by the relays it holds a
+// reservation with, and hands them to handler.
+func NewClient(h host.Host, handler func(*Conn)) *Client {
+	c := &Client{
+		host:    h,
+		handler: handler,
+		rsvp:    make(map[peer.ID]*Reservation),
+	}
+	h.SetStreamHandler(ProtoStop, c.handleStop)
+	return c
+}
+
+// Close stops accepting relayed connections.
+func (c *Client) Close() error {
+	c.host.RemoveStreamHandler(ProtoStop)
+	return nil
+}
+
+// Reserve reserves a slot on relay, or renews it. The reservation must be
+// renewed before it expires.
+func (c *Client) Reserve(ctx context.Context, relay peer.ID) (*Reservation, error) {
+	r, err := reserve(ctx, c.host, relay)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lk.Lock()
+	c.rsvp[relay] = r
+	c.lk.Unlock()
+	return r, nil
+}
+
+// Reservations returns the reservations that haven't expired.
+func (c *Client) Reservations() []*Reservation {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	var rs []*Reservation
+	now := time.Now()
+	for p, r := range c.rsvp {
+		if r.Expire.Before(now) {
+			delete(c.rsvp, p)
+			continue
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+func reserve(ctx context.Context, h host.Host, relay peer.ID) (*Reservation, error) {
+	str, err := h.NewStream(ctx, relay, ProtoHop)
+	if err != nil {
+		return nil, err
+	}
+	defer str.Close()
+	str.SetDeadline(time.Now().Add(streamTimeout))
+
+	if err := p2putil.WriteMsg(str, &pb.HopMessage{Type: pb.HopMessage_RESERVE}); err != nil {
+		str.Reset()
+		return nil, err
+	}
+	var resp pb.HopMessage
+	if err := p2putil.ReadMsg(bufio.NewReader(str), &resp); err != nil {
+		str.Reset()
+		return nil, err
+	}
+	if resp.Type != pb.HopMessage_STATUS {
+		return nil, fmt.Errorf("unexpected %s message", resp.Type)
+	}
+	if resp.GetStatus() != pb.Status_OK {
+		return nil, fmt.Errorf("reservation refused: %s", resp.GetStatus())
+	}
+	if resp.Reservation == nil {
+		return nil, errors.New("missing reservation")
+	}
+
+	r := &Reservation{
+		Relay:  relay,
+		Expire: time.Unix(int64(resp.Reservation.GetExpire()), 0),
+		Limit:  limitFromPB(resp.Limit),
+	}
+	if r.Expire.Before(time.Now()) {
+		return nil, errors.New("the reservation has already expired")
+	}
+
+	if resp.Reservation.Voucher == nil {
+		return nil, errors.New("missing reservation voucher")
+	}
+	r.Voucher, err = OpenVoucher(resp.Reservation.Voucher)
+	if err != nil {
+		return nil, err
+	}
+	if r.Voucher.Relay != relay || r.Voucher.Peer != h.ID() || !r.Voucher.Expiration.Equal(r.Expire) {
+		return nil, errors.New("the voucher doesn't match the reservation")
+	}
+
+	circuit, err := ma.NewMultiaddr("/ipfs/" + relay.Pretty() + "/p2p-circuit")
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range resp.Reservation.Addrs {
+		a, err := ma.NewMultiaddrBytes(b)
+		if err != nil {
+			log.Debugf("reservation address from %s: %s", relay.Pretty(), err)
+			continue
+		}
+		r.Addrs = append(r.Addrs, a.Encapsulate(circuit))
+	}
+	return r, nil
+}
+
+func (c *Client) handleStop(str inet.Stream) {
+	str.SetDeadline(time.Now().Add(streamTimeout))
+	relay := str.Conn().RemotePeer()
+
+	br := bufio.NewReader(str)
+	var msg pb.StopMessage
+	if err := p2putil.ReadMsg(br, &msg); err != nil {
+		stopStatus(str, pb.Status_MALFORMED_MESSAGE)
+		return
+	}
+	if msg.Type != pb.StopMessage_CONNECT {
+		stopStatus(str, pb.Status_UNEXPECTED_MESSAGE)
+		return
+	}
+	if msg.Peer == nil {
+		stopStatus(str, pb.Status_MALFORMED_MESSAGE)
+		return
+	}
+	src, err := peer.IDFromBytes(msg.Peer.Id)
+	if err != nil {
+		stopStatus(str, pb.Status_MALFORMED_MESSAGE)
+		return
+	}
+
+	// only the relays the node reserved a slot with relay to it
+	c.lk.Lock()
+	r, ok := c.rsvp[relay]
+	c.lk.Unlock()
+	if !ok || r.Expire.Before(time.Now()) {
+		stopStatus(str, pb.Status_NO_RESERVATION)
+		return
+	}
+
+	err = p2putil.WriteMsg(str, &pb.StopMessage{
+		Type:   pb.StopMessage_STATUS,
+		Status: pb.Status_OK.Enum(),
+	})
+	if err != nil {
+		str.Reset()
+		return
+	}
+	str.SetDeadline(time.Time{})
+
+	c.handler(&Conn{
+		Stream: str,
+		r:      br,
+		relay:  relay,
+		remote: src,
+		limit:  limitFromPB(msg.Limit),
+	})
+}
+
+// Connect opens a connection to dst through relay, which must hold a
+// reservation for dst.
+func Connect(ctx context.Context, h host.Host, relay, dst peer.ID) (*Conn, error) {
+	str, err := h.NewStream(ctx, relay, ProtoHop)
+	if err != nil {
+		return nil, err
+	}
+	str.SetDeadline(time.Now().Add(streamTimeout))
+
+	err = p2putil.WriteMsg(str, &pb.HopMessage{
+		Type: pb.HopMessage_CONNECT,
+		Peer: &pb.Peer{Id: []byte(dst)},
+	})
+	if err != nil {
+		str.Reset()
+		return nil, err
+	}
+
+	br := bufio.NewReader(str)
+	var resp pb.HopMessage
+	if err := p2putil.ReadMsg(br, &resp); err != nil {
+		str.Reset()
+		return nil, err
+	}
+	if resp.Type != pb.HopMessage_STATUS {
+		str.Reset()
+		return nil, fmt.Errorf("unexpected %s message", resp.Type)
+	}
+	if resp.GetStatus() != pb.Status_OK {
+		str.Close()
+		return nil, fmt.Errorf("relay refused the connection: %s", resp.GetStatus())
+	}
+	str.SetDeadline(time.Time{})
+
+	return &Conn{
+		Stream: str,
+		r:      br,
+		relay:  relay,
+		remote: dst,
+		limit:  limitFromPB(resp.Limit),
+	}, nil
+}
+
+func stopStatus(str inet.Stream, status pb.Status) {
+	err := p2putil.WriteMsg(str, &pb.StopMessage{
+		Type:   pb.StopMessage_STATUS,
+		Status: status.Enum(),
+	})
+	if err != nil {
+		str.Reset()
+		return
+	}
+	str.Close()
+}
@@ -0,0 +1,465 @@
+// Package relay implements the circuit relay v2 protocol: the service, run
+// by the relays, and the client, run by the peers using them.
+//
+// Peers that can't be reached directly reserve a slot with the relay, after
+// which other peers can reach them through it. The relay signs a voucher
+// proving the reservation. Unlike the v1 hop relay, every relayed connection
+// is limited in duration and data, and reservations are limited in number,
+// so running a relay has a bounded cost.
+package relay
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	p2putil "github.com/ipfs/go-ipfs/internal/p2putil"
+	pb "github.com/ipfs/go-ipfs/relay/pb"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	ic "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+var log = logging.Logger("relay")
+
+const (
+	// ProtoHop is spoken between the relay and the peers using it.
+	ProtoHop = protocol.ID("/libp2p/circuit/relay/0.2.0/hop")
+	// ProtoStop is spoken by the relay to the destination of a circuit.
+	ProtoStop = protocol.ID("/libp2p/circuit/relay/0.2.0/stop")
+)
+
+// Tag is the connection manager tag of peers holding a reservation.
+const Tag = "relay-reservation"
+
+const (
+	streamTimeout  = time.Minute
+	connectTimeout = 30 * time.Second
+)
+
+// Defaults of the limits left unset in the config.
+const (
+	DefaultConnectionDurationLimit = 2 * time.Minute
+	DefaultConnectionDataLimit     = 1 << 17 // 128KiB
+	DefaultReservationTTL          = time.Hour
+	DefaultMaxReservations         = 128
+	DefaultMaxReservationsPerIP    = 8
+	DefaultMaxCircuits             = 16
+	DefaultBufferSize              = 2048
+)
+
+// Service is a circuit relay v2 service.
+type Service struct {
+	host   host.Host
+	key    ic.PrivKey
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	duration    time.Duration
+	data        int64
+	ttl         time.Duration
+	maxRsvp     int
+	maxRsvpIP   int
+	maxCircuits int
+	bufSize     int
+
+	lk       sync.Mutex
+	rsvp     map[peer.ID]time.Time
+	circuits map[peer.ID]int
+	active   int
+
+	totalCircuits   uint64
+	refusedRsvp     uint64
+	refusedCircuits uint64
+	relayedBytes    uint64
+}
+
+// ReservationStat describes an active reservation.
+type ReservationStat struct {
+	Peer    string
+	Expires time.Time
+}
+
+// Stats reports the activity of the relay.
+type Stats struct {
+	Reservations        []ReservationStat
+	ActiveCircuits      int
+	TotalCircuits       uint64
+	RefusedReservations uint64
+	RefusedCircuits     uint64
+	RelayedBytes        uint64
+}
+
+// NewService starts relaying for h with the limits in cfg.
+func NewService(h host.Host, cfg *extconfig.RelayService) (*Service, error) {
+	sk := h.Peerstore().PrivKey(h.ID())
+	if sk == nil {
+		return nil, errors.New("the key of the host, signing the reservation vouchers, is missing")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		host:        h,
+		key:         sk,
+		ctx:         ctx,
+		cancel:      cancel,
+		duration:    DefaultConnectionDurationLimit,
+		data:        DefaultConnectionDataLimit,
+		ttl:         DefaultReservationTTL,
+		maxRsvp:     DefaultMaxReservations,
+		maxRsvpIP:   DefaultMaxReservationsPerIP,
+		maxCircuits: DefaultMaxCircuits,
+		bufSize:     DefaultBufferSize,
+		rsvp:        make(map[peer.ID]time.Time),
+		circuits:    make(map[peer.ID]int),
+	}
+
+	var err error
+	if cfg.ConnectionDurationLimit != "" {
+		s.duration, err = time.ParseDuration(cfg.ConnectionDurationLimit)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Swarm.RelayService.ConnectionDurationLimit: %s", err)
+		}
+	}
+	if cfg.ReservationTTL != "" {
+		s.ttl, err = time.ParseDuration(cfg.ReservationTTL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Swarm.RelayService.ReservationTTL: %s", err)
+		}
+	}
+	if cfg.ConnectionDataLimit > 0 {
+		s.data = cfg.ConnectionDataLimit
+	}
+	if cfg.MaxReservations > 0 {
+		s.maxRsvp = cfg.MaxReservations
+	}
+	if cfg.MaxReservationsPerIP > 0 {
+		s.maxRsvpIP = cfg.MaxReservationsPerIP
+	}
+	if cfg.MaxCircuits > 0 {
+		s.maxCircuits = cfg.MaxCircuits
+	}
+	if cfg.BufferSize > 0 {
+		s.bufSize = cfg.BufferSize
+	}
+
+	h.SetStreamHandler(ProtoHop, s.handleHop)
+	go s.gc()
+	return s, nil
+}
+
+// Close stops the service. Circuits in progress are left running until they
+// hit their limits.
+func (s *Service) Close() error {
+	s.host.RemoveStreamHandler(ProtoHop)
+	s.cancel()
+	return nil
+}
+
+// Stat returns the current activity of the relay.
+func (s *Service) Stat() Stats {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	st := Stats{
+		TotalCircuits:       atomic.LoadUint64(&s.totalCircuits),
+		RefusedReservations: atomic.LoadUint64(&s.refusedRsvp),
+		RefusedCircuits:     atomic.LoadUint64(&s.refusedCircuits),
+		RelayedBytes:        atomic.LoadUint64(&s.relayedBytes),
+	}
+	now := time.Now()
+	for p, exp := range s.rsvp {
+		if exp.After(now) {
+			st.Reservations = append(st.Reservations, ReservationStat{Peer: p.Pretty(), Expires: exp})
+		}
+	}
+	st.ActiveCircuits = s.active
+	return st
+}
+
+// gc drops expired reservations.
+func (s *Service) gc() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-s.ctx.Done():
+			return
+		}
+
+		now := time.Now()
+		s.lk.Lock()
+		for p, exp := range s.rsvp {
+			if exp.Before(now) {
+				delete(s.rsvp, p)
+				s.host.ConnManager().UntagPeer(p, Tag)
+			}
+		}
+		s.lk.Unlock()
+	}
+}
+
+func (s *Service) handleHop(str inet.Stream) {
+	defer str.Close()
+	str.SetDeadline(time.Now().Add(streamTimeout))
+
+	br := bufio.NewReader(str)
+	var msg pb.HopMessage
+	if err := p2putil.ReadMsg(br, &msg); err != nil {
+		s.hopStatus(str, pb.Status_MALFORMED_MESSAGE)
+		return
+	}
+
+	switch msg.Type {
+	case pb.HopMessage_RESERVE:
+		s.handleReserve(str)
+	case pb.HopMessage_CONNECT:
+		s.handleConnect(str, br, &msg)
+	default:
+		s.hopStatus(str, pb.Status_UNEXPECTED_MESSAGE)
+	}
+}
+
+func (s *Service) handleReserve(str inet.Stream) {
+	p := str.Conn().RemotePeer()
+	raddr := str.Conn().RemoteMultiaddr()
+
+	// reservations over a relayed connection wouldn't be reachable.
+	if p2putil.IsRelayAddr(raddr) {
+		atomic.AddUint64(&s.refusedRsvp, 1)
+		s.hopStatus(str, pb.Status_PERMISSION_DENIED)
+		return
+	}
+
+	s.lk.Lock()
+	if err := s.canReserveLocked(p, raddr); err != nil {
+		s.lk.Unlock()
+		log.Debugf("refusing reservation from %s: %s", p.Pretty(), err)
+		atomic.AddUint64(&s.refusedRsvp, 1)
+		s.hopStatus(str, pb.Status_RESERVATION_REFUSED)
+		return
+	}
+	expire := time.Now().Add(s.ttl)
+	s.rsvp[p] = expire
+	s.lk.Unlock()
+
+	s.host.ConnManager().TagPeer(p, Tag, 10)
+
+	voucher, err := signVoucher(s.key, &Voucher{Relay: s.host.ID(), Peer: p, Expiration: expire})
+	if err != nil {
+		log.Errorf("signing the reservation voucher of %s: %s", p.Pretty(), err)
+		s.hopStatus(str, pb.Status_RESERVATION_REFUSED)
+		return
+	}
+
+	var addrs [][]byte
+	for _, a := range s.host.Addrs() {
+		if !p2putil.IsRelayAddr(a) && !manet.IsIPLoopback(a) {
+			addrs = append(addrs, a.Bytes())
+		}
+	}
+
+	p2putil.WriteMsg(str, &pb.HopMessage{
+		Type: pb.HopMessage_STATUS,
+		Reservation: &pb.Reservation{
+			Expire:  uint64(expire.Unix()),
+			Addrs:   addrs,
+			Voucher: voucher,
+		},
+		Limit:  s.limit(),
+		Status: pb.Status_OK.Enum(),
+	})
+}
+
+// canReserveLocked checks the reservation limits. Renewals are always
+// accepted. s.lk must be held.
+func (s *Service) canReserveLocked(p peer.ID, raddr ma.Multiaddr) error {
+	now := time.Now()
+	if exp, ok := s.rsvp[p]; ok && exp.After(now) {
+		return nil
+	}
+
+	var active, fromIP int
+	ip := p2putil.IPOf(raddr)
+	for rp, exp := range s.rsvp {
+		if exp.Before(now) {
+			continue
+		}
+		active++
+		if ip == "" {
+			continue
+		}
+		for _, c := range s.host.Network().ConnsToPeer(rp) {
+			if p2putil.IPOf(c.RemoteMultiaddr()) == ip {
+				fromIP++
+				break
+			}
+		}
+	}
+
+	if active >= s.maxRsvp {
+		return errors.New("too many reservations")
+	}
+	if fromIP >= s.maxRsvpIP {
+		return errors.New("too many reservations from the same IP")
+	}
+	return nil
+}
+
+func (s *Service) handleConnect(str inet.Stream, br *bufio.Reader, msg *pb.HopMessage) {
+	src := str.Conn().RemotePeer()
+	if msg.Peer == nil {
+		s.hopStatus(str, pb.Status_MALFORMED_MESSAGE)
+		return
+	}
+	dst, err := peer.IDFromBytes(msg.Peer.Id)
+	if err != nil {
+		s.hopStatus(str, pb.Status_MALFORMED_MESSAGE)
+		return
+	}
+
+	s.lk.Lock()
+	exp, ok := s.rsvp[dst]
+	switch {
+	case !ok || exp.Before(time.Now()):
+		s.lk.Unlock()
+		atomic.AddUint64(&s.refusedCircuits, 1)
+		s.hopStatus(str, pb.Status_NO_RESERVATION)
+		return
+	case s.circuits[src] >= s.maxCircuits || s.circuits[dst] >= s.maxCircuits:
+		s.lk.Unlock()
+		atomic.AddUint64(&s.refusedCircuits, 1)
+		s.hopStatus(str, pb.Status_RESOURCE_LIMIT_EXCEEDED)
+		return
+	}
+	s.circuits[src]++
+	s.circuits[dst]++
+	s.active++
+	s.lk.Unlock()
+	defer s.releaseCircuit(src, dst)
+
+	dstStr, dstBr, err := s.connectDst(src, dst)
+	if err != nil {
+		log.Debugf("relaying from %s to %s failed: %s", src.Pretty(), dst.Pretty(), err)
+		s.hopStatus(str, pb.Status_CONNECTION_FAILED)
+		return
+	}
+	defer dstStr.Close()
+
+	err = p2putil.WriteMsg(str, &pb.HopMessage{
+		Type:   pb.HopMessage_STATUS,
+		Limit:  s.limit(),
+		Status: pb.Status_OK.Enum(),
+	})
+	if err != nil {
+		dstStr.Reset()
+		return
+	}
+
+	atomic.AddUint64(&s.totalCircuits, 1)
+	log.Debugf("relaying from %s to %s", src.Pretty(), dst.Pretty())
+
+	deadline := time.Now().Add(s.duration)
+	str.SetDeadline(deadline)
+	dstStr.SetDeadline(deadline)
+
+	done := make(chan struct{}, 2)
+	go s.pipe(dstStr, br, done)
+	go s.pipe(str, dstBr, done)
+	<-done
+	<-done
+}
+
+// connectDst opens the stop stream to dst and announces src.
+func (s *Service) connectDst(src, dst peer.ID) (inet.Stream, *bufio.Reader, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, connectTimeout)
+	defer cancel()
+
+	str, err := s.host.NewStream(ctx, dst, ProtoStop)
+	if err != nil {
+		return nil, nil, err
+	}
+	str.SetDeadline(time.Now().Add(connectTimeout))
+
+	err = p2putil.WriteMsg(str, &pb.StopMessage{
+		Type:  pb.StopMessage_CONNECT,
+		Peer:  &pb.Peer{Id: []byte(src)},
+		Limit: s.limit(),
+	})
+	if err != nil {
+		str.Reset()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(str)
+	var resp pb.StopMessage
+	if err := p2putil.ReadMsg(br, &resp); err != nil {
+		str.Reset()
+		return nil, nil, err
+	}
+	if resp.Type != pb.StopMessage_STATUS || resp.GetStatus() != pb.Status_OK {
+		str.Reset()
+		return nil, nil, fmt.Errorf("destination refused: %s", resp.GetStatus())
+	}
+	return str, br, nil
+}
+
+// pipe copies at most the data limit from r to w, then resets the streams
+// if the limit was hit.
+func (s *Service) pipe(w inet.Stream, r io.Reader, done chan<- struct{}) {
+	defer func() { done <- struct{}{} }()
+
+	buf := make([]byte, s.bufSize)
+	n, err := io.CopyBuffer(w, io.LimitReader(r, s.data), buf)
+	atomic.AddUint64(&s.relayedBytes, uint64(n))
+
+	if err == nil && n == s.data {
+		// limit reached
+		w.Reset()
+		return
+	}
+	w.Close()
+}
+
+func (s *Service) releaseCircuit(src, dst peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.active--
+	for _, p := range []peer.ID{src, dst} {
+		s.circuits[p]--
+		if s.circuits[p] <= 0 {
+			delete(s.circuits, p)
+		}
+	}
+}
+
+func (s *Service) limit() *pb.Limit {
+	d := uint32(s.duration / time.Second)
+	data := uint64(s.data)
+	return &pb.Limit{Duration: &d, Data: &data}
+}
+
+func (s *Service) hopStatus(str inet.Stream, status pb.Status) {
+	err := p2putil.WriteMsg(str, &pb.HopMessage{
+		Type:   pb.HopMessage_STATUS,
+		Status: status.Enum(),
+	})
+	if err != nil {
+		str.Reset()
+	}
+}
@@ -0,0 +1,211 @@
+package relay
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	p2putil "github.com/ipfs/go-ipfs/internal/p2putil"
+	pb "github.com/ipfs/go-ipfs/relay/pb"
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	mocknet "gx/ipfs/QmUEqyXr97aUbNmQADHYNknjwjjdVpJXEt1UZXmSG81EV4/go-libp2p/p2p/net/mock"
+	inet "gx/ipfs/QmZNJyx9GGCX4GeuHnLB8fxaxMLs4MjTjHokxfQcCd6Nve/go-libp2p-net"
+	host "gx/ipfs/QmeMYW7Nj8jnnEfs9qhm7SxKkoDPUWXu3MsxX6BFwz34tf/go-libp2p-host"
+)
+
+func hop(t *testing.T, ctx context.Context, h host.Host, relay peer.ID, req *pb.HopMessage) (inet.Stream, *bufio.Reader, *pb.HopMessage) {
+	s, err := h.NewStream(ctx, relay, ProtoHop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p2putil.WriteMsg(s, req); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(s)
+	var resp pb.HopMessage
+	if err := p2putil.ReadMsg(br, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return s, br, &resp
+}
+
+func TestReserveAndConnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	r, dst, src := hosts[0], hosts[1], hosts[2]
+
+	svc, err := NewService(r, &extconfig.RelayService{Enabled: true, ConnectionDataLimit: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	// the destination echoes what it receives.
+	dst.SetStreamHandler(ProtoStop, func(s inet.Stream) {
+		br := bufio.NewReader(s)
+		var msg pb.StopMessage
+		if err := p2putil.ReadMsg(br, &msg); err != nil {
+			s.Reset()
+			return
+		}
+		p2putil.WriteMsg(s, &pb.StopMessage{Type: pb.StopMessage_STATUS, Status: pb.Status_OK.Enum()})
+		io.Copy(s, br)
+		s.Close()
+	})
+
+	connect := &pb.HopMessage{
+		Type: pb.HopMessage_CONNECT,
+		Peer: &pb.Peer{Id: []byte(dst.ID())},
+	}
+
+	s, _, resp := hop(t, ctx, src, r.ID(), connect)
+	s.Close()
+	if resp.GetStatus() != pb.Status_NO_RESERVATION {
+		t.Fatalf("expected NO_RESERVATION, got %s", resp.GetStatus())
+	}
+
+	s, _, resp = hop(t, ctx, dst, r.ID(), &pb.HopMessage{Type: pb.HopMessage_RESERVE})
+	s.Close()
+	if resp.GetStatus() != pb.Status_OK || resp.Reservation == nil {
+		t.Fatalf("reservation failed: %s", resp.GetStatus())
+	}
+	if resp.Limit.GetData() != 1024 {
+		t.Fatalf("expected a data limit of 1024, got %d", resp.Limit.GetData())
+	}
+
+	s, br, resp := hop(t, ctx, src, r.ID(), connect)
+	if resp.GetStatus() != pb.Status_OK {
+		t.Fatalf("connect failed: %s", resp.GetStatus())
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("expected hello, got %q", buf)
+	}
+
+	st := svc.Stat()
+	if len(st.Reservations) != 1 || st.ActiveCircuits != 1 || st.RefusedCircuits != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	r, dst, src := hosts[0], hosts[1], hosts[2]
+
+	svc, err := NewService(r, &extconfig.RelayService{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+
+	// the destination echoes what it receives.
+	client := NewClient(dst, func(c *Conn) {
+		if c.RemotePeer() != src.ID() || c.Relay() != r.ID() {
+			c.Reset()
+			return
+		}
+		io.Copy(c, c)
+		c.Close()
+	})
+	defer client.Close()
+
+	if _, err := Connect(ctx, src, r.ID(), dst.ID()); err == nil {
+		t.Fatal("expected connecting without a reservation to fail")
+	}
+
+	rsvp, err := client.Reserve(ctx, r.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsvp.Voucher.Relay != r.ID() || rsvp.Voucher.Peer != dst.ID() || !rsvp.Voucher.Expiration.Equal(rsvp.Expire) {
+		t.Fatalf("unexpected voucher %+v", rsvp.Voucher)
+	}
+	if len(client.Reservations()) != 1 {
+		t.Fatal("expected the reservation to be held")
+	}
+
+	c, err := Connect(ctx, src, r.ID(), dst.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Limit().Data != DefaultConnectionDataLimit || c.Limit().Duration != DefaultConnectionDurationLimit {
+		t.Fatalf("unexpected limit %+v", c.Limit())
+	}
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("expected hello, got %q", buf)
+	}
+}
+
+func TestVoucher(t *testing.T) {
+	mn := mocknet.New(context.Background())
+	relay, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk := relay.Peerstore().PrivKey(relay.ID())
+
+	exp := time.Unix(time.Now().Add(time.Hour).Unix(), 0)
+	b, err := signVoucher(sk, &Voucher{Relay: relay.ID(), Peer: other.ID(), Expiration: exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := OpenVoucher(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Relay != relay.ID() || v.Peer != other.ID() || !v.Expiration.Equal(exp) {
+		t.Fatalf("unexpected voucher %+v", v)
+	}
+
+	// flip a byte of the signature, at the end of the envelope
+	bad := append([]byte(nil), b...)
+	bad[len(bad)-1] ^= 1
+	if _, err := OpenVoucher(bad); err == nil {
+		t.Fatal("expected a tampered voucher to be rejected")
+	}
+
+	// signed by a key that isn't the one of the relay
+	b, err = signVoucher(other.Peerstore().PrivKey(other.ID()), &Voucher{Relay: relay.ID(), Peer: other.ID(), Expiration: exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenVoucher(b); err == nil {
+		t.Fatal("expected a voucher not signed by its relay to be rejected")
+	}
+}
@@ -0,0 +1,164 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	ic "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+)
+
+// The reservation vouchers are signed envelopes, see
+// https://github.com/libp2p/specs/blob/master/RFC/0002-signed-envelopes.md
+const (
+	// voucherDomain separates the signatures of the vouchers from the
+	// other uses of the key.
+	voucherDomain = "libp2p-relay-rsvp"
+)
+
+// voucherPayloadType is the multicodec of the vouchers, 0x0302, as a varint.
+var voucherPayloadType = []byte{0x82, 0x06}
+
+// protobuf wire types
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// Voucher is the proof, signed by a relay, that it holds a reservation for
+// a peer until it expires.
+type Voucher struct {
+	Relay      peer.ID
+	Peer       peer.ID
+	Expiration time.Time
+}
+
+// signVoucher returns the envelope of v signed with sk, the key of v.Relay.
+func signVoucher(sk ic.PrivKey, v *Voucher) ([]byte, error) {
+	var payload bytes.Buffer
+	writeBytesField(&payload, 1, []byte(v.Relay))
+	writeBytesField(&payload, 2, []byte(v.Peer))
+	writeUvarint(&payload, 3<<3|wireVarint)
+	writeUvarint(&payload, uint64(v.Expiration.Unix()))
+
+	sig, err := sk.Sign(envelopeSigningData(voucherPayloadType, payload.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	pkb, err := ic.MarshalPublicKey(sk.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+
+	var env bytes.Buffer
+	writeBytesField(&env, 1, pkb)
+	writeBytesField(&env, 2, voucherPayloadType)
+	writeBytesField(&env, 3, payload.Bytes())
+	writeBytesField(&env, 5, sig)
+	return env.Bytes(), nil
+}
+
+// OpenVoucher checks the signature of the voucher envelope b and returns
+// the voucher. The voucher must be signed by the key of the relay it names.
+func OpenVoucher(b []byte) (*Voucher, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, fmt.Errorf("malformed voucher envelope: %s", err)
+	}
+	if !bytes.Equal(fields[2].bytes, voucherPayloadType) {
+		return nil, errors.New("the envelope is not a reservation voucher")
+	}
+	pk, err := ic.UnmarshalPublicKey(fields[1].bytes)
+	if err != nil {
+		return nil, fmt.Errorf("voucher key: %s", err)
+	}
+	payload := fields[3].bytes
+	ok, err := pk.Verify(envelopeSigningData(voucherPayloadType, payload), fields[5].bytes)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("invalid voucher signature")
+	}
+
+	vf, err := decodeFields(payload)
+	if err != nil {
+		return nil, fmt.Errorf("malformed voucher: %s", err)
+	}
+	v := &Voucher{Expiration: time.Unix(int64(vf[3].varint), 0)}
+	if v.Relay, err = peer.IDFromBytes(vf[1].bytes); err != nil {
+		return nil, fmt.Errorf("voucher relay: %s", err)
+	}
+	if v.Peer, err = peer.IDFromBytes(vf[2].bytes); err != nil {
+		return nil, fmt.Errorf("voucher peer: %s", err)
+	}
+	if !v.Relay.MatchesPublicKey(pk) {
+		return nil, errors.New("the voucher is not signed by its relay")
+	}
+	return v, nil
+}
+
+// envelopeSigningData returns the bytes signed in an envelope: the domain,
+// the payload type and the payload, each prefixed with its varint length.
+func envelopeSigningData(payloadType, payload []byte) []byte {
+	var buf bytes.Buffer
+	for _, b := range [][]byte{[]byte(voucherDomain), payloadType, payload} {
+		writeUvarint(&buf, uint64(len(b)))
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+func writeUvarint(buf *bytes.Buffer, x uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	buf.Write(b[:binary.PutUvarint(b, x)])
+}
+
+// writeBytesField writes the protobuf field n of the bytes type.
+func writeBytesField(buf *bytes.Buffer, n uint64, b []byte) {
+	writeUvarint(buf, n<<3|wireBytes)
+	writeUvarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+// field is the value of a protobuf field, of the bytes or varint type.
+type field struct {
+	bytes  []byte
+	varint uint64
+}
+
+// decodeFields returns the fields of the protobuf message b by number,
+// keeping the last value of the repeated ones.
+func decodeFields(b []byte) (map[uint64]field, error) {
+	fields := make(map[uint64]field)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("truncated field key")
+		}
+		b = b[n:]
+
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("truncated field")
+		}
+		b = b[n:]
+
+		switch key & 7 {
+		case wireVarint:
+			fields[key>>3] = field{varint: x}
+		case wireBytes:
+			if x > uint64(len(b)) {
+				return nil, errors.New("truncated field")
+			}
+			fields[key>>3] = field{bytes: b[:x]}
+			b = b[x:]
+		default:
+			return nil, fmt.Errorf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields, nil
+}
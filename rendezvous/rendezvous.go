@@ -0,0 +1,186 @@
+// Package rendezvous lets the nodes of an application find each other
+// through the content routing system. A node advertises a topic, an
+// application defined string, by providing the CID derived from it; the
+// nodes looking for the topic find the providers of that CID.
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	routing "gx/ipfs/QmdKS5YtmuSWKuLLgbHG176mS3VX3AKiyVmaaiAfvgcuch/go-libp2p-routing"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+var log = logging.Logger("rendezvous")
+
+// namespace is prepended to the topics before hashing them, so that their
+// CIDs don't collide with the CIDs of actual content.
+const namespace = "/ipfs/rendezvous/"
+
+// Advertisement intervals. Variables so tests can speed them up.
+var (
+	// ReadvertiseInterval is the interval at which the topics are
+	// advertised again, before the provider records expire.
+	ReadvertiseInterval = 12 * time.Hour
+
+	// retryInterval is the wait before advertising again a topic whose
+	// advertisement failed, e.g. because the node had no peers yet.
+	retryInterval = time.Minute
+
+	// provideTimeout bounds a single advertisement.
+	provideTimeout = time.Minute
+)
+
+// ErrEmptyTopic is returned for empty topics.
+var ErrEmptyTopic = errors.New("empty rendezvous topic")
+
+// TopicCid returns the CID advertised for topic.
+func TopicCid(topic string) (cid.Cid, error) {
+	if topic == "" {
+		return cid.Cid{}, ErrEmptyTopic
+	}
+	h, err := mh.Sum([]byte(namespace+topic), mh.SHA2_256, -1)
+	if err != nil {
+		return cid.Cid{}, err
+	}
+	return cid.NewCidV1(cid.Raw, h), nil
+}
+
+// Service advertises the topics of the node and finds the peers advertising
+// a topic.
+type Service struct {
+	self    peer.ID
+	routing routing.ContentRouting
+
+	lk     sync.Mutex
+	topics map[string]context.CancelFunc
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewService returns a Service advertising the node self through r.
+func NewService(self peer.ID, r routing.ContentRouting) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		self:    self,
+		routing: r,
+		topics:  make(map[string]context.CancelFunc),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Advertise starts advertising topic, until Stop is called. Advertising a
+// topic twice has no effect.
+func (s *Service) Advertise(topic string) error {
+	c, err := TopicCid(topic)
+	if err != nil {
+		return err
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.ctx.Err() != nil {
+		return errors.New("rendezvous service closed")
+	}
+	if _, ok := s.topics[topic]; ok {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.topics[topic] = cancel
+	go s.advertise(ctx, topic, c)
+	return nil
+}
+
+func (s *Service) advertise(ctx context.Context, topic string, c cid.Cid) {
+	for {
+		pctx, cancel := context.WithTimeout(ctx, provideTimeout)
+		err := s.routing.Provide(pctx, c, true)
+		cancel()
+
+		wait := ReadvertiseInterval
+		if err != nil {
+			log.Warningf("advertising rendezvous topic %q: %s", topic, err)
+			wait = retryInterval
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop stops advertising topic. The provider records already published
+// expire by themselves.
+func (s *Service) Stop(topic string) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	cancel, ok := s.topics[topic]
+	if ok {
+		cancel()
+		delete(s.topics, topic)
+	}
+	return ok
+}
+
+// Topics returns the advertised topics, sorted.
+func (s *Service) Topics() []string {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	topics := make([]string, 0, len(s.topics))
+	for t := range s.topics {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// FindPeers returns the peers advertising topic, at most limit of them. The
+// node itself is left out.
+func (s *Service) FindPeers(ctx context.Context, topic string, limit int) (<-chan pstore.PeerInfo, error) {
+	c, err := TopicCid(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more in case the node advertises the topic itself
+	providers := s.routing.FindProvidersAsync(ctx, c, limit+1)
+	out := make(chan pstore.PeerInfo)
+	go func() {
+		defer close(out)
+		found := 0
+		for pi := range providers {
+			if pi.ID == s.self {
+				continue
+			}
+			if found == limit {
+				return
+			}
+			select {
+			case out <- pi:
+				found++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close stops advertising all the topics.
+func (s *Service) Close() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.cancel()
+	s.topics = make(map[string]context.CancelFunc)
+	return nil
+}
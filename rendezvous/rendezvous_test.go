@@ -0,0 +1,74 @@
+package rendezvous
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testutil "gx/ipfs/QmRNhSdqzMcuRxX9A1egBeQ3BhDTguDV5HPwi8wRykkPU8/go-testutil"
+	mockrouting "gx/ipfs/QmSNe4MWVxZWk6UxxW2z2EKofFo4GdFzud1vfn1iVby3mj/go-ipfs-routing/mock"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+func collect(t *testing.T, ch <-chan pstore.PeerInfo) []pstore.PeerInfo {
+	var out []pstore.PeerInfo
+	for pi := range ch {
+		out = append(out, pi)
+	}
+	return out
+}
+
+func TestAdvertiseAndFind(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	serv := mockrouting.NewServer()
+	ida := testutil.RandIdentityOrFatal(t)
+	idb := testutil.RandIdentityOrFatal(t)
+	a := NewService(ida.ID(), serv.Client(ida))
+	defer a.Close()
+	b := NewService(idb.ID(), serv.Client(idb))
+	defer b.Close()
+
+	if err := a.Advertise("chat"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Advertise("chat"); err != nil {
+		t.Fatal(err)
+	}
+	if topics := a.Topics(); len(topics) != 1 || topics[0] != "chat" {
+		t.Fatalf("unexpected topics %v", topics)
+	}
+
+	// the advertisements run in the background
+	var found []pstore.PeerInfo
+	for len(found) == 0 {
+		peers, err := b.FindPeers(ctx, "chat", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = collect(t, peers)
+		if ctx.Err() != nil {
+			t.Fatal("timed out looking for the peers of the topic")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(found) != 1 || found[0].ID != ida.ID() {
+		t.Fatalf("expected to only find %s, got %v", ida.ID(), found)
+	}
+
+	peers, err := b.FindPeers(ctx, "other", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found := collect(t, peers); len(found) != 0 {
+		t.Fatalf("expected no peers for another topic, got %v", found)
+	}
+
+	if !a.Stop("chat") || a.Stop("chat") {
+		t.Fatal("expected the topic to be stopped once")
+	}
+	if _, err := TopicCid(""); err != ErrEmptyTopic {
+		t.Fatalf("expected ErrEmptyTopic, got %v", err)
+	}
+}
@@ -5,6 +5,23 @@ import (
 	"strings"
 )
 
+// KeyNotFoundError is returned by MapGetKV when the requested key, or one of
+// its parents, is not present in the map.
+type KeyNotFoundError struct {
+	// Parent is the longest prefix of the requested key that exists.
+	Parent string
+}
+
+func (e *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("%s key has no attributes", e.Parent)
+}
+
+// IsKeyNotFound returns whether err was caused by a missing key.
+func IsKeyNotFound(err error) bool {
+	_, ok := err.(*KeyNotFoundError)
+	return ok
+}
+
 func MapGetKV(v map[string]interface{}, key string) (interface{}, error) {
 	var ok bool
 	var mcursor map[string]interface{}
@@ -21,7 +38,7 @@ func MapGetKV(v map[string]interface{}, key string) (interface{}, error) {
 
 		cursor, ok = mcursor[part]
 		if !ok {
-			return nil, fmt.Errorf("%s key has no attributes", sofar)
+			return nil, &KeyNotFoundError{Parent: sofar}
 		}
 	}
 	return cursor, nil
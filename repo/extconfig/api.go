@@ -0,0 +1,88 @@
+package extconfig
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// APIAuthorizationsKey is the config key holding the tokens of the HTTP API.
+const APIAuthorizationsKey = "API.Authorizations"
+
+// APIAuthorization grants the holder of a bearer token access to a subset of
+// the commands of the HTTP API.
+type APIAuthorization struct {
+	// Token is the secret sent in the "Authorization: Bearer" header.
+	Token string
+
+	// AllowedCommands lists the commands the token may call, such as "/pin"
+	// or "/cat". A command allows its subcommands. All the commands are
+	// allowed when empty.
+	AllowedCommands []string `json:",omitempty"`
+
+	// ReadOnly restricts the token to the commands of the read-only API,
+	// the ones the gateway exposes under /api/v0.
+	ReadOnly bool `json:",omitempty"`
+}
+
+// LoadAPIAuthorizations reads the tokens of the HTTP API, by name. The API
+// doesn't require a token when there are none.
+func LoadAPIAuthorizations(r KeyGetter) (map[string]APIAuthorization, error) {
+	auths := make(map[string]APIAuthorization)
+	if err := Load(r, APIAuthorizationsKey, &auths); err != nil {
+		return nil, err
+	}
+
+	tokens := make(map[string]string, len(auths))
+	for name, a := range auths {
+		if a.Token == "" {
+			return nil, fmt.Errorf("API authorization %q has no token", name)
+		}
+		if other, ok := tokens[a.Token]; ok {
+			return nil, fmt.Errorf("API authorizations %q and %q have the same token", other, name)
+		}
+		tokens[a.Token] = name
+	}
+	return auths, nil
+}
+
+// APIUnixSocketKey is the config key of the options of the API served on a
+// /unix/ address.
+const APIUnixSocketKey = "API.UnixSocket"
+
+// DefaultAPIUnixSocketMode is the permissions of the socket of the API.
+const DefaultAPIUnixSocketMode = "0600"
+
+// APIUnixSocket configures the socket created when Addresses.API is a /unix/
+// address.
+type APIUnixSocket struct {
+	// Mode is the octal permissions of the socket file.
+	Mode string `json:",omitempty"`
+
+	// AllowedUIDs lists the users allowed to connect besides the one running
+	// the daemon, checked with the credentials of the peer of the
+	// connections. Any user the permissions allow may connect when empty.
+	AllowedUIDs []int `json:",omitempty"`
+}
+
+// FileMode parses Mode.
+func (s *APIUnixSocket) FileMode() (os.FileMode, error) {
+	mode := s.Mode
+	if mode == "" {
+		mode = DefaultAPIUnixSocketMode
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m&^0777 != 0 {
+		return 0, fmt.Errorf("invalid API.UnixSocket.Mode %q", s.Mode)
+	}
+	return os.FileMode(m), nil
+}
+
+// LoadAPIUnixSocket reads the API.UnixSocket section of the config.
+func LoadAPIUnixSocket(r KeyGetter) (*APIUnixSocket, error) {
+	cfg := new(APIUnixSocket)
+	if err := Load(r, APIUnixSocketKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,44 @@
+package extconfig
+
+// AutoNAT service modes.
+const (
+	// AutoNATServiceEnabled answers the reachability probes of other
+	// peers. The default.
+	AutoNATServiceEnabled = "enabled"
+	// AutoNATServiceDisabled only probes the reachability of the node.
+	AutoNATServiceDisabled = "disabled"
+)
+
+// AutoNAT configures the AutoNAT service, which tells other peers whether
+// they are reachable from the internet.
+type AutoNAT struct {
+	// ServiceMode is AutoNATServiceEnabled, AutoNATServiceDisabled or
+	// empty for the default.
+	ServiceMode string `json:",omitempty"`
+
+	// Throttle limits the probes answered for other peers. Zero values use
+	// the defaults of the autonat package.
+	Throttle AutoNATThrottle
+}
+
+// AutoNATThrottle limits the dial backs performed for other peers.
+type AutoNATThrottle struct {
+	// GlobalLimit is the number of probes answered per Interval.
+	GlobalLimit int `json:",omitempty"`
+
+	// PeerLimit is the number of probes answered for a single peer per
+	// Interval.
+	PeerLimit int `json:",omitempty"`
+
+	// Interval is the period the limits apply to.
+	Interval string `json:",omitempty"`
+}
+
+// LoadAutoNAT reads the AutoNAT section of the config.
+func LoadAutoNAT(r KeyGetter) (*AutoNAT, error) {
+	cfg := new(AutoNAT)
+	if err := Load(r, "AutoNAT", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
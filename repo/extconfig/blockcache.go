@@ -0,0 +1,34 @@
+package extconfig
+
+// The config keys of the caches of the blockstore, next to
+// Datastore.BloomFilterSize.
+const (
+	ARCCacheSizeKey      = "Datastore.ARCCacheSize"
+	BloomFilterHashesKey = "Datastore.BloomFilterHashes"
+)
+
+// BlockCache configures the caches in front of the blockstore, besides the
+// size of the bloom filter.
+type BlockCache struct {
+	// ARCCacheSize is the number of blocks whose presence the ARC cache
+	// remembers. Zero uses the default, a negative size disables the
+	// cache.
+	ARCCacheSize int
+
+	// BloomFilterHashes is the number of hash functions of the bloom
+	// filter. Zero uses the default.
+	BloomFilterHashes int
+}
+
+// LoadBlockCache reads the Datastore.ARCCacheSize and
+// Datastore.BloomFilterHashes keys of the config.
+func LoadBlockCache(r KeyGetter) (*BlockCache, error) {
+	cfg := new(BlockCache)
+	if err := Load(r, ARCCacheSizeKey, &cfg.ARCCacheSize); err != nil {
+		return nil, err
+	}
+	if err := Load(r, BloomFilterHashesKey, &cfg.BloomFilterHashes); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
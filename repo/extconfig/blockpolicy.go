@@ -0,0 +1,21 @@
+package extconfig
+
+// MaxBlockSizeKey is the config key of the size of the largest block
+// written to the blockstore.
+const MaxBlockSizeKey = "Datastore.MaxBlockSize"
+
+// BlockPolicy configures the blocks accepted by the blockstore.
+type BlockPolicy struct {
+	// MaxBlockSize is the size of the largest block accepted, such as
+	// "2MiB". Empty uses the default, "0" disables the limit.
+	MaxBlockSize string
+}
+
+// LoadBlockPolicy reads the Datastore.MaxBlockSize key of the config.
+func LoadBlockPolicy(r KeyGetter) (*BlockPolicy, error) {
+	cfg := new(BlockPolicy)
+	if err := Load(r, MaxBlockSizeKey, &cfg.MaxBlockSize); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,35 @@
+package extconfig
+
+// BootstrapCheckKey is the config key of the bootstrap check section.
+const BootstrapCheckKey = "BootstrapCheck"
+
+// DefaultBootstrapCheckInterval is the interval of the background checks of
+// the bootstrap peers when BootstrapCheck.Interval is unset.
+const DefaultBootstrapCheckInterval = "1h"
+
+// BootstrapCheck configures the health checks of the bootstrap peers. The
+// bootstrap peers whose last dials all failed are dialed last, after the
+// healthy ones.
+type BootstrapCheck struct {
+	// Enabled dials the bootstrap peers periodically in the background, so
+	// that dead peers are found even when the node doesn't need to
+	// bootstrap. Disabled by default.
+	Enabled Flag `json:",omitempty"`
+
+	// Interval is the interval of the background checks. Defaults to
+	// DefaultBootstrapCheckInterval.
+	Interval string `json:",omitempty"`
+
+	// MaxFailures is the number of consecutive failed dials after which a
+	// bootstrap peer is considered dead.
+	MaxFailures int `json:",omitempty"`
+}
+
+// LoadBootstrapCheck reads the BootstrapCheck section of the config.
+func LoadBootstrapCheck(r KeyGetter) (*BootstrapCheck, error) {
+	cfg := new(BootstrapCheck)
+	if err := Load(r, BootstrapCheckKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
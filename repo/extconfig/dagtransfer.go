@@ -0,0 +1,24 @@
+package extconfig
+
+// DAGTransfer configures the transfers of whole DAGs in a single request,
+// alongside bitswap, with the go-ipfs specific DAG transfer protocol.
+type DAGTransfer struct {
+	// Enabled answers the DAG transfer requests of the peers with the DAGs
+	// of the blockstore.
+	Enabled bool
+
+	// FetchDAGs makes the commands reading whole DAGs, 'ipfs pin add'
+	// pinning recursively, 'ipfs get' with its CAR exports and 'ipfs archive
+	// export', fetch them from the providers answering DAG transfers, before
+	// fetching the blocks still missing with bitswap.
+	FetchDAGs bool
+}
+
+// LoadDAGTransfer reads the DAGTransfer section of the config.
+func LoadDAGTransfer(r KeyGetter) (*DAGTransfer, error) {
+	cfg := new(DAGTransfer)
+	if err := Load(r, "DAGTransfer", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,30 @@
+package extconfig
+
+// DefaultDenylistRefreshInterval is the interval at which the denylist
+// sources are reloaded when Denylist.RefreshInterval is unset.
+const DefaultDenylistRefreshInterval = "1h"
+
+// Denylist configures the CIDs and paths the node refuses to serve through
+// its gateway and over bitswap.
+type Denylist struct {
+	// Files lists the files the denied CIDs and paths are read from, one
+	// per line. Relative paths are relative to the repo.
+	Files []string `json:",omitempty"`
+
+	// URLs lists the http(s) URLs of denylists, in the format of Files.
+	URLs []string `json:",omitempty"`
+
+	// RefreshInterval is the interval at which Files and URLs are reloaded,
+	// "0" to only load them at startup and on 'ipfs block deny reload'.
+	// Defaults to DefaultDenylistRefreshInterval.
+	RefreshInterval string `json:",omitempty"`
+}
+
+// LoadDenylist reads the Denylist section of the config.
+func LoadDenylist(r KeyGetter) (*Denylist, error) {
+	cfg := new(Denylist)
+	if err := Load(r, "Denylist", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
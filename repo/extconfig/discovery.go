@@ -0,0 +1,41 @@
+package extconfig
+
+// MDNSKey is the config key of the mDNS discovery section. Its Enabled and
+// Interval fields are part of go-ipfs-config.
+const MDNSKey = "Discovery.MDNS"
+
+// MDNS holds the mDNS discovery settings missing from go-ipfs-config.
+type MDNS struct {
+	// ServiceTag is the mDNS service the peers are announced and looked
+	// up under. Nodes only discover the nodes using the same tag, which
+	// keeps private clusters on a shared LAN apart.
+	ServiceTag string `json:",omitempty"`
+}
+
+// LoadMDNS reads the extra settings of the Discovery.MDNS section of the
+// config.
+func LoadMDNS(r KeyGetter) (*MDNS, error) {
+	cfg := new(MDNS)
+	if err := Load(r, MDNSKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// RendezvousTopicsKey is the config key of the rendezvous topics.
+const RendezvousTopicsKey = "Discovery.Rendezvous.Topics"
+
+// Rendezvous configures the rendezvous discovery.
+type Rendezvous struct {
+	// Topics lists the topics the node advertises when it starts.
+	Topics []string
+}
+
+// LoadRendezvous reads the Discovery.Rendezvous section of the config.
+func LoadRendezvous(r KeyGetter) (*Rendezvous, error) {
+	cfg := new(Rendezvous)
+	if err := Load(r, "Discovery.Rendezvous", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+// Package extconfig defines configuration sections that go-ipfs reads from
+// the repo config file but that are not (yet) part of go-ipfs-config.
+//
+// The values live in the same JSON document as the rest of the config and can
+// be set with 'ipfs config'. They are read through Repo.GetConfigKey, so any
+// key the user didn't set keeps the default value of the destination struct.
+package extconfig
+
+import (
+	"encoding/json"
+
+	common "github.com/ipfs/go-ipfs/repo/common"
+)
+
+// KeyGetter is the subset of repo.Repo needed to read config sections.
+type KeyGetter interface {
+	GetConfigKey(key string) (interface{}, error)
+}
+
+// Load decodes the config value stored under key into out. If the key is not
+// present in the config, out is left untouched.
+func Load(r KeyGetter, key string, out interface{}) error {
+	v, err := r.GetConfigKey(key)
+	if err != nil {
+		if common.IsKeyNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+
+	// round-trip through json so that the struct tags and types of out
+	// are honored the same way config.FromMap does it.
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, out)
+}
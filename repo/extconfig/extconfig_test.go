@@ -0,0 +1,82 @@
+package extconfig
+
+import (
+	"encoding/json"
+	"testing"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+)
+
+func TestLoadMissingKey(t *testing.T) {
+	r := &repo.Mock{}
+
+	cfg := &Pubsub{DisableSigning: true}
+	if err := Load(r, "DoesNotExist", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.DisableSigning {
+		t.Fatal("missing key should leave defaults untouched")
+	}
+}
+
+func TestLoadSection(t *testing.T) {
+	r := &repo.Mock{}
+	r.C.Pubsub.Router = "gossipsub"
+
+	cfg, err := LoadPubsub(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DisableSigning || cfg.StrictSignatureVerification {
+		t.Fatal("expected zero values for unset keys")
+	}
+}
+
+func TestFlagJSON(t *testing.T) {
+	for in, exp := range map[string]Flag{"true": True, "false": False, "null": Default} {
+		var f Flag
+		if err := json.Unmarshal([]byte(in), &f); err != nil {
+			t.Fatal(err)
+		}
+		if f != exp {
+			t.Fatalf("%s: expected %s, got %s", in, exp, f)
+		}
+
+		out, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != in {
+			t.Fatalf("expected %s, got %s", in, out)
+		}
+	}
+
+	var f Flag
+	if err := json.Unmarshal([]byte(`"yes"`), &f); err == nil {
+		t.Fatal("expected error for non boolean value")
+	}
+
+	if !Default.WithDefault(true) || Default.WithDefault(false) || False.WithDefault(true) {
+		t.Fatal("WithDefault returned the wrong value")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	for _, name := range []string{"server", "test", "lowpower", "server-strict", "randomports"} {
+		if _, ok := Profiles[name]; !ok {
+			t.Fatalf("missing profile %s", name)
+		}
+	}
+
+	for in, exp := range map[string]string{
+		"/ip4/0.0.0.0/tcp/4001":         "/ip4/0.0.0.0/tcp/1234",
+		"/ip6/::/udp/4001/quic":         "/ip6/::/udp/1234/quic",
+		"/ip4/127.0.0.1/tcp/5001/ws":    "/ip4/127.0.0.1/tcp/1234/ws",
+		"/dns4/example.com/tcp/443/wss": "/dns4/example.com/tcp/1234/wss",
+		"/unix/var/run/ipfs/api.sock":   "/unix/var/run/ipfs/api.sock",
+	} {
+		if out := replacePort(in, 1234); out != exp {
+			t.Errorf("replacePort(%s): expected %s, got %s", in, exp, out)
+		}
+	}
+}
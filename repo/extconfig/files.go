@@ -0,0 +1,36 @@
+package extconfig
+
+// DefaultMaxAutoSnapshots is the default number of automatic snapshots of
+// the files API kept.
+const DefaultMaxAutoSnapshots = 10
+
+// Files configures the files API, 'ipfs files'.
+type Files struct {
+	// AutoSnapshot snapshots the root of the files API before every
+	// 'ipfs files rm', so that the removed files can be restored with
+	// 'ipfs files snapshot restore'.
+	AutoSnapshot Flag `json:",omitempty"`
+
+	// MaxAutoSnapshots is the number of automatic snapshots kept, the
+	// oldest being removed first. Zero uses the default of 10. The
+	// snapshots created with 'ipfs files snapshot create' are never
+	// removed automatically.
+	MaxAutoSnapshots int `json:",omitempty"`
+}
+
+// LoadFiles reads the Files section of the config.
+func LoadFiles(r KeyGetter) (*Files, error) {
+	cfg := new(Files)
+	if err := Load(r, "Files", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// MaxAuto returns MaxAutoSnapshots, or the default when unset.
+func (c *Files) MaxAuto() int {
+	if c.MaxAutoSnapshots <= 0 {
+		return DefaultMaxAutoSnapshots
+	}
+	return c.MaxAutoSnapshots
+}
@@ -0,0 +1,73 @@
+package extconfig
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Flag is a tri-state boolean. Unset flags (null or absent in the config)
+// take the default value chosen by the code reading them, so defaults can be
+// changed in later releases without rewriting user configs.
+type Flag int8
+
+const (
+	// Default leaves the choice to the code reading the flag.
+	Default Flag = 0
+	// True forces the option on.
+	True Flag = 1
+	// False forces the option off.
+	False Flag = -1
+)
+
+// WithDefault resolves the flag, using def when it is unset.
+func (f Flag) WithDefault(def bool) bool {
+	switch f {
+	case True:
+		return true
+	case False:
+		return false
+	default:
+		return def
+	}
+}
+
+// String implements fmt.Stringer.
+func (f Flag) String() string {
+	switch f {
+	case True:
+		return "true"
+	case False:
+		return "false"
+	default:
+		return "default"
+	}
+}
+
+// MarshalJSON encodes the flag as true, false or null.
+func (f Flag) MarshalJSON() ([]byte, error) {
+	switch f {
+	case True:
+		return []byte("true"), nil
+	case False:
+		return []byte("false"), nil
+	default:
+		return []byte("null"), nil
+	}
+}
+
+// UnmarshalJSON decodes true, false or null.
+func (f *Flag) UnmarshalJSON(b []byte) error {
+	var v *bool
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("invalid flag value %s: expected true, false or null", b)
+	}
+	switch {
+	case v == nil:
+		*f = Default
+	case *v:
+		*f = True
+	default:
+		*f = False
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package extconfig
+
+// GatewayExposedAPICommandsKey is the config key of the commands of the API
+// the gateway serves.
+const GatewayExposedAPICommandsKey = "Gateway.ExposedAPICommands"
+
+// LoadGatewayExposedAPICommands reads the commands of the read-only API the
+// gateway serves under /api/v0, such as "/name/resolve" or "/dag/get". A
+// command exposes its subcommands. It returns nil when unset, for all the
+// read-only commands, and an empty list when the gateway serves no command.
+func LoadGatewayExposedAPICommands(r KeyGetter) ([]string, error) {
+	var exposed []string
+	if err := Load(r, GatewayExposedAPICommandsKey, &exposed); err != nil {
+		return nil, err
+	}
+	return exposed, nil
+}
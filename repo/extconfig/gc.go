@@ -0,0 +1,30 @@
+package extconfig
+
+// GCKey is the config key of the garbage collection section.
+const GCKey = "Datastore.GC"
+
+// GC configures the garbage collections of the daemon started with
+// --enable-gc.
+type GC struct {
+	// Incremental runs the periodic garbage collections incrementally,
+	// without blocking the adds for their whole duration.
+	Incremental bool
+
+	// MaxRate is the maximum number of blocks removed per second by an
+	// incremental garbage collection, zero for no limit.
+	MaxRate int `json:",omitempty"`
+
+	// BatchSize is the number of blocks removed per acquisition of the GC
+	// lock by an incremental garbage collection. Zero uses the default of
+	// 1000.
+	BatchSize int `json:",omitempty"`
+}
+
+// LoadGC reads the Datastore.GC section of the config.
+func LoadGC(r KeyGetter) (*GC, error) {
+	cfg := new(GC)
+	if err := Load(r, GCKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
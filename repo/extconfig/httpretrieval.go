@@ -0,0 +1,30 @@
+package extconfig
+
+// HTTPRetrieval configures the retrieval of blocks from trustless HTTP
+// gateways, alongside bitswap. Zero values use the defaults of the httpfetch
+// package.
+type HTTPRetrieval struct {
+	// Gateways lists the base URLs of the gateways, like https://ipfs.io.
+	// The retrieval is disabled when it is empty.
+	Gateways []string `json:",omitempty"`
+
+	// Delay is the time a block must be missing from bitswap before it is
+	// requested from the gateways, as a duration string.
+	Delay string `json:",omitempty"`
+
+	// Timeout bounds each request to a gateway, as a duration string.
+	Timeout string `json:",omitempty"`
+
+	// Concurrency is the maximum number of blocks requested from the
+	// gateways at once.
+	Concurrency int `json:",omitempty"`
+}
+
+// LoadHTTPRetrieval reads the HTTPRetrieval section of the config.
+func LoadHTTPRetrieval(r KeyGetter) (*HTTPRetrieval, error) {
+	cfg := new(HTTPRetrieval)
+	if err := Load(r, "HTTPRetrieval", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
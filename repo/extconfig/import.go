@@ -0,0 +1,93 @@
+package extconfig
+
+import (
+	"fmt"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+)
+
+// DefaultHAMTDirectorySizeThreshold is the default size above which the
+// automatically sharded directories are sharded.
+const DefaultHAMTDirectorySizeThreshold = "256KiB"
+
+// The defaults of the importer, when neither the options of the commands nor
+// the Import section set them.
+const (
+	DefaultUnixFSChunker = "size-262144"
+	DefaultHashFunction  = "sha2-256"
+)
+
+// Import configures how 'ipfs add', 'ipfs files write' and 'ipfs urlstore
+// add' build the DAG of the added files. The options of the commands take
+// precedence.
+type Import struct {
+	// CidVersion is the CID version of the added files. Unset, it is 0
+	// unless another option requires CIDv1.
+	CidVersion *int `json:",omitempty"`
+
+	// UnixFSChunker is the chunker of the added files,
+	// DefaultUnixFSChunker when empty.
+	UnixFSChunker string `json:",omitempty"`
+
+	// HashFunction is the hash function of the CIDs of the added files,
+	// DefaultHashFunction when empty. Other functions imply CIDv1.
+	HashFunction string `json:",omitempty"`
+
+	// RawLeaves stores the leaves of the added files as raw blocks. Unset,
+	// they are raw with CIDv1 only.
+	RawLeaves Flag
+
+	// AutoShard shards the added directories whose estimated size is above
+	// UnixFSHAMTDirectorySizeThreshold, and only them. Off by default.
+	AutoShard Flag
+
+	// UnixFSHAMTDirectorySizeThreshold is the estimated size of a directory
+	// above which it's sharded: the sum of the lengths of the names and
+	// CIDs of its entries.
+	UnixFSHAMTDirectorySizeThreshold string `json:",omitempty"`
+}
+
+// LoadImport reads the Import section of the config.
+func LoadImport(r KeyGetter) (*Import, error) {
+	cfg := new(Import)
+	if err := Load(r, "Import", cfg); err != nil {
+		return nil, err
+	}
+	if v := cfg.CidVersion; v != nil && *v != 0 && *v != 1 {
+		return nil, fmt.Errorf("invalid Import.CidVersion %d, expected 0 or 1", *v)
+	}
+	return cfg, nil
+}
+
+// Chunker returns UnixFSChunker, or the default chunker when unset.
+func (c *Import) Chunker() string {
+	if c.UnixFSChunker == "" {
+		return DefaultUnixFSChunker
+	}
+	return c.UnixFSChunker
+}
+
+// Hash returns HashFunction, or the default hash function when unset.
+func (c *Import) Hash() string {
+	if c.HashFunction == "" {
+		return DefaultHashFunction
+	}
+	return c.HashFunction
+}
+
+// HAMTDirectorySizeThreshold returns the parsed sharding threshold, or the
+// default one when unset.
+func (c *Import) HAMTDirectorySizeThreshold() (int64, error) {
+	s := c.UnixFSHAMTDirectorySizeThreshold
+	if s == "" {
+		s = DefaultHAMTDirectorySizeThreshold
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Import.UnixFSHAMTDirectorySizeThreshold %q: %s", s, err)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("Import.UnixFSHAMTDirectorySizeThreshold must be positive")
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,31 @@
+package extconfig
+
+// Init configures the content 'ipfs init' adds to a new repo. As the repo
+// doesn't exist yet, it's read from the config given to 'ipfs init', and set
+// by the profiles of InitProfiles.
+type Init struct {
+	// EmptyRepo doesn't add the getting-started docs, as
+	// 'ipfs init --empty-repo'.
+	EmptyRepo Flag `json:",omitempty"`
+
+	// SeedCAR is the path of a CAR file added to the repo instead of the
+	// getting-started docs, its roots being pinned. Embedded products ship
+	// their own welcome content this way.
+	SeedCAR string `json:",omitempty"`
+}
+
+// InitProfiles are the Init sections set by the profiles of the same name
+// in Profiles, which only transform the sections of go-ipfs-config.
+var InitProfiles = map[string]Init{
+	"emptyrepo": {EmptyRepo: True},
+}
+
+// Merge sets the fields of c set in o.
+func (c *Init) Merge(o Init) {
+	if o.EmptyRepo != Default {
+		c.EmptyRepo = o.EmptyRepo
+	}
+	if o.SeedCAR != "" {
+		c.SeedCAR = o.SeedCAR
+	}
+}
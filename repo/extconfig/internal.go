@@ -0,0 +1,31 @@
+package extconfig
+
+// DefaultUnixFSReadAheadBlocks is the default number of blocks prefetched
+// ahead of the sequential reads of UnixFS files.
+const DefaultUnixFSReadAheadBlocks = 32
+
+// Internal holds the settings of the internals of the node, which only need
+// changing to tune the node for unusual workloads.
+type Internal struct {
+	// UnixFSReadAheadBlocks is the number of blocks prefetched ahead of the
+	// sequential reads of UnixFS files, by 'ipfs cat' and the gateway.
+	// Zero disables the readahead.
+	UnixFSReadAheadBlocks *int `json:",omitempty"`
+}
+
+// LoadInternal reads the Internal section of the config.
+func LoadInternal(r KeyGetter) (*Internal, error) {
+	cfg := new(Internal)
+	if err := Load(r, "Internal", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ReadAheadBlocks returns UnixFSReadAheadBlocks, or the default when unset.
+func (c *Internal) ReadAheadBlocks() int {
+	if c.UnixFSReadAheadBlocks == nil {
+		return DefaultUnixFSReadAheadBlocks
+	}
+	return *c.UnixFSReadAheadBlocks
+}
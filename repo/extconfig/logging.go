@@ -0,0 +1,66 @@
+package extconfig
+
+// LoggingKey is the config key of the logging section.
+const LoggingKey = "Logging"
+
+// LoggingLevelsKey is the config key of the levels of the subsystems, saved
+// by 'ipfs log level --save'.
+const LoggingLevelsKey = LoggingKey + ".Levels"
+
+// Log formats.
+const (
+	// LogFormatText is the human readable format of go-log.
+	LogFormatText = "text"
+	// LogFormatJSON writes every message as a JSON object on its own line.
+	LogFormatJSON = "json"
+)
+
+// Logging configures the log output of the daemon.
+type Logging struct {
+	// Format is the format of the messages written to stderr,
+	// LogFormatText or LogFormatJSON. Defaults to text.
+	Format string `json:",omitempty"`
+
+	// Levels sets the level of stderr for some subsystems, "*" standing
+	// for all of them. They can be changed with 'ipfs config reload'.
+	Levels map[string]string `json:",omitempty"`
+
+	// Outputs route the messages of some subsystems to files, in addition
+	// to stderr.
+	Outputs []LogOutput `json:",omitempty"`
+}
+
+// LogOutput is a file receiving the messages of some subsystems.
+type LogOutput struct {
+	// File is the path of the log file. Relative paths are relative to
+	// the repo.
+	File string
+
+	// Subsystems lists the subsystems written to the file, all of them
+	// when empty.
+	Subsystems []string `json:",omitempty"`
+
+	// Level is the least severe level written to the file, independently
+	// of the level of stderr. Defaults to info.
+	Level string `json:",omitempty"`
+
+	// Format is LogFormatText or LogFormatJSON. Defaults to json.
+	Format string `json:",omitempty"`
+
+	// MaxSizeMB is the size at which the file is rotated. Zero uses the
+	// default of 100MB.
+	MaxSizeMB int `json:",omitempty"`
+
+	// MaxBackups is the number of rotated files kept. Zero uses the
+	// default of 3.
+	MaxBackups int `json:",omitempty"`
+}
+
+// LoadLogging reads the Logging section of the config.
+func LoadLogging(r KeyGetter) (*Logging, error) {
+	cfg := new(Logging)
+	if err := Load(r, LoggingKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,14 @@
+package extconfig
+
+// MountsMFSKey is the config key of the mountpoint of the files root.
+const MountsMFSKey = "Mounts.MFS"
+
+// LoadMountsMFS reads the path where the files root ('ipfs files') is
+// mounted read-write. It is empty when the files root isn't mounted.
+func LoadMountsMFS(r KeyGetter) (string, error) {
+	var mp string
+	if err := Load(r, MountsMFSKey, &mp); err != nil {
+		return "", err
+	}
+	return mp, nil
+}
@@ -0,0 +1,39 @@
+package extconfig
+
+// P2P configures the libp2p stream mounting subsystem ('ipfs p2p').
+type P2P struct {
+	// Forwards lists the p2p listeners and forwards created when the node
+	// starts.
+	Forwards []P2PForward
+}
+
+// P2PForward declares a p2p forward, as created by 'ipfs p2p forward', or
+// a p2p listener, as created by 'ipfs p2p listen', when it has no
+// ListenAddress.
+type P2PForward struct {
+	Protocol string
+
+	// ListenAddress is the local address of a forward.
+	ListenAddress string `json:",omitempty"`
+
+	// TargetAddress is the /ipfs/ address of the peer of a forward, or the
+	// local address a listener forwards the connections to.
+	TargetAddress string
+
+	// AllowPeers restricts the peers allowed to connect to a listener.
+	AllowPeers []string `json:",omitempty"`
+}
+
+// IsListener returns whether f declares a p2p listener.
+func (f P2PForward) IsListener() bool {
+	return f.ListenAddress == ""
+}
+
+// LoadP2P reads the P2P section of the config.
+func LoadP2P(r KeyGetter) (*P2P, error) {
+	cfg := new(P2P)
+	if err := Load(r, "P2P", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
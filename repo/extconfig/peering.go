@@ -0,0 +1,51 @@
+package extconfig
+
+import (
+	"fmt"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	pstore "gx/ipfs/Qmda4cPRvSRyox3SqgJN6DfSZGU5TtHufPTp9uXjFj71X6/go-libp2p-peerstore"
+)
+
+// PeeringPeersKey is the config key holding the peering set.
+const PeeringPeersKey = "Peering.Peers"
+
+// Peering configures the peering subsystem.
+type Peering struct {
+	// Peers lists the peers the node keeps permanent connections to.
+	Peers []PeeringPeer
+}
+
+// PeeringPeer is a single member of the peering set.
+type PeeringPeer struct {
+	ID    string
+	Addrs []string
+}
+
+// PeerInfo parses the peer ID and addresses.
+func (p PeeringPeer) PeerInfo() (pstore.PeerInfo, error) {
+	id, err := peer.IDB58Decode(p.ID)
+	if err != nil {
+		return pstore.PeerInfo{}, fmt.Errorf("invalid peering peer ID %q: %s", p.ID, err)
+	}
+
+	pi := pstore.PeerInfo{ID: id}
+	for _, s := range p.Addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return pstore.PeerInfo{}, fmt.Errorf("invalid address %q for peering peer %s: %s", s, p.ID, err)
+		}
+		pi.Addrs = append(pi.Addrs, a)
+	}
+	return pi, nil
+}
+
+// LoadPeering reads the Peering section of the config.
+func LoadPeering(r KeyGetter) (*Peering, error) {
+	cfg := new(Peering)
+	if err := Load(r, "Peering", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
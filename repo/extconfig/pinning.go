@@ -0,0 +1,41 @@
+package extconfig
+
+import (
+	"fmt"
+	"time"
+)
+
+// PinningKey is the config key of the pinning section.
+const PinningKey = "Pinning"
+
+// DefaultPinFlushInterval is the default of Pinning.FlushInterval.
+const DefaultPinFlushInterval = time.Second
+
+// Pinning configures the pinner of the node.
+type Pinning struct {
+	// FlushInterval is the maximum time the changes of the pins are kept in
+	// a journal before the pinset is written, coalescing the writes of the
+	// pinset of many 'ipfs pin add'. "0" writes the pinset on every change.
+	FlushInterval string `json:",omitempty"`
+}
+
+// LoadPinning reads the Pinning section of the config.
+func LoadPinning(r KeyGetter) (*Pinning, error) {
+	cfg := new(Pinning)
+	if err := Load(r, PinningKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FlushIntervalDuration parses FlushInterval.
+func (p *Pinning) FlushIntervalDuration() (time.Duration, error) {
+	if p.FlushInterval == "" {
+		return DefaultPinFlushInterval, nil
+	}
+	d, err := time.ParseDuration(p.FlushInterval)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid Pinning.FlushInterval %q", p.FlushInterval)
+	}
+	return d, nil
+}
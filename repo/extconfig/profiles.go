@@ -0,0 +1,98 @@
+package extconfig
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+)
+
+// Profiles are the config profiles of go-ipfs-config, plus the ones of
+// go-ipfs, by name. The low-power devices are covered by the 'lowpower'
+// profile of go-ipfs-config.
+var Profiles = map[string]config.Profile{
+	"server-strict": {
+		Description: `Applies the 'server' profile, and restricts the services
+the node exposes: the API and the gateway only listen on localhost, the
+gateway is read-only, the API allows no cross-origin requests and the
+libp2p stream mounting is disabled.`,
+
+		Transform: func(c *config.Config) error {
+			if err := config.Profiles["server"].Transform(c); err != nil {
+				return err
+			}
+
+			c.Addresses.API = "/ip4/127.0.0.1/tcp/5001"
+			c.Addresses.Gateway = "/ip4/127.0.0.1/tcp/8080"
+			c.Gateway.Writable = false
+			delete(c.API.HTTPHeaders, "Access-Control-Allow-Origin")
+			delete(c.API.HTTPHeaders, "Access-Control-Allow-Methods")
+			delete(c.API.HTTPHeaders, "Access-Control-Allow-Credentials")
+			c.Experimental.Libp2pStreamMounting = false
+			return nil
+		},
+	},
+
+	"emptyrepo": {
+		Description: `Initializes the repo without the getting-started docs, as
+'ipfs init --empty-repo'. It only applies to 'ipfs init'.`,
+
+		Transform: func(c *config.Config) error {
+			// see InitProfiles
+			return nil
+		},
+	},
+
+	"randomports": {
+		Description: `Uses random free ports for the swarm, the API and the
+gateway, to run several nodes on the same machine.`,
+
+		Transform: func(c *config.Config) error {
+			swarmPort, err := freePort()
+			if err != nil {
+				return err
+			}
+			apiPort, err := freePort()
+			if err != nil {
+				return err
+			}
+			gatewayPort, err := freePort()
+			if err != nil {
+				return err
+			}
+
+			for i, a := range c.Addresses.Swarm {
+				c.Addresses.Swarm[i] = replacePort(a, swarmPort)
+			}
+			c.Addresses.API = replacePort(c.Addresses.API, apiPort)
+			c.Addresses.Gateway = replacePort(c.Addresses.Gateway, gatewayPort)
+			return nil
+		},
+	},
+}
+
+func init() {
+	for name, p := range config.Profiles {
+		if _, ok := Profiles[name]; !ok {
+			Profiles[name] = p
+		}
+	}
+}
+
+var portRegexp = regexp.MustCompile(`/(tcp|udp)/[0-9]+`)
+
+// replacePort replaces the tcp and udp ports of a multiaddr.
+func replacePort(addr string, port int) string {
+	return portRegexp.ReplaceAllString(addr, fmt.Sprintf("/$1/%d", port))
+}
+
+// freePort returns a tcp port nothing listens on.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
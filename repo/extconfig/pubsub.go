@@ -0,0 +1,72 @@
+package extconfig
+
+// Pubsub contains the pubsub options not covered by config.Pubsub.
+type Pubsub struct {
+	// DisableSigning disables signing of outgoing messages.
+	DisableSigning bool
+
+	// StrictSignatureVerification makes the router drop messages without a
+	// valid signature instead of forwarding them.
+	StrictSignatureVerification bool
+
+	// TopicValidators maps topic names to the name of a registered
+	// validator that every message on the topic must pass.
+	TopicValidators map[string]string
+
+	// PersistentTopics lists topics the daemon subscribes to on startup so
+	// that it keeps relaying them without any attached subscriber.
+	PersistentTopics []string
+
+	// PeerScoring configures scoring of the peers publishing on the
+	// node's topics.
+	PeerScoring PubsubPeerScoring
+}
+
+// PubsubPeerScoring holds the peer score thresholds and per-topic weights.
+type PubsubPeerScoring struct {
+	// Enabled turns peer scoring on.
+	Enabled bool
+
+	// GraylistThreshold is the score below which all messages from a peer
+	// are ignored.
+	GraylistThreshold float64
+
+	// DecayInterval is how often the counters feeding the score decay.
+	// Defaults to one minute.
+	DecayInterval string
+
+	// DecayFactor is the factor counters are multiplied with on every
+	// decay. Defaults to 0.9.
+	DecayFactor float64
+
+	// Topics holds the scoring weights for each scored topic. Messages on
+	// topics not listed here do not affect peer scores.
+	Topics map[string]PubsubTopicScoring
+}
+
+// PubsubTopicScoring holds the weights of a single topic.
+type PubsubTopicScoring struct {
+	// TopicWeight scales the contribution of this topic to the peer score.
+	TopicWeight float64
+
+	// FirstMessageDeliveriesWeight is the (positive) weight applied to
+	// every valid message first delivered by a peer.
+	FirstMessageDeliveriesWeight float64
+
+	// FirstMessageDeliveriesCap caps the first delivery counter so that a
+	// peer can't build up an arbitrarily high score.
+	FirstMessageDeliveriesCap float64
+
+	// InvalidMessageDeliveriesWeight is the (negative) weight applied to
+	// the square of the invalid message counter.
+	InvalidMessageDeliveriesWeight float64
+}
+
+// LoadPubsub reads the Pubsub section of the config.
+func LoadPubsub(r KeyGetter) (*Pubsub, error) {
+	cfg := new(Pubsub)
+	if err := Load(r, "Pubsub", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,19 @@
+package extconfig
+
+// Reprovider configures the "ondemand" strategy of the reprovider, whose
+// Strategy and Interval are part of go-ipfs-config.
+type Reprovider struct {
+	// OnDemandExpiry is how long the "ondemand" strategy keeps reproviding
+	// a CID after it was last requested, as a duration string. Empty uses
+	// the default of a week.
+	OnDemandExpiry string `json:",omitempty"`
+}
+
+// LoadReprovider reads the Reprovider section of the config.
+func LoadReprovider(r KeyGetter) (*Reprovider, error) {
+	cfg := new(Reprovider)
+	if err := Load(r, "Reprovider", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,15 @@
+package extconfig
+
+// RoutingCustomKey is the config key naming the router of a plugin, used
+// when Routing.Type is "custom".
+const RoutingCustomKey = "Routing.Custom"
+
+// LoadRoutingCustom reads the name of the router of a plugin, empty when it
+// isn't set.
+func LoadRoutingCustom(r KeyGetter) (string, error) {
+	var name string
+	if err := Load(r, RoutingCustomKey, &name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,341 @@
+package extconfig
+
+// ResourceMgrKey is the config key of the resource manager section.
+const ResourceMgrKey = "Swarm.ResourceMgr"
+
+// ResourceMgr configures the libp2p resource manager.
+type ResourceMgr struct {
+	// Enabled turns enforcement of the limits on.
+	Enabled bool
+
+	// Limits overrides the default limits. Unset fields keep their
+	// defaults.
+	Limits *ResourceMgrLimits `json:",omitempty"`
+}
+
+// ResourceMgrLimits holds the limits of every scope.
+type ResourceMgrLimits struct {
+	// System limits the node as a whole.
+	System ResourceLimit
+
+	// Peer is the default limit of every remote peer.
+	Peer ResourceLimit
+
+	// Protocol is the default limit of every protocol.
+	Protocol ResourceLimit
+
+	// Peers overrides the limits of specific peers.
+	Peers map[string]ResourceLimit `json:",omitempty"`
+
+	// Protocols overrides the limits of specific protocols.
+	Protocols map[string]ResourceLimit `json:",omitempty"`
+}
+
+// ResourceLimit is the set of limits of a single scope. Zero, or a field left
+// out, means the default limit; Unlimited means no limit.
+type ResourceLimit struct {
+	Conns         int `json:",omitempty"`
+	ConnsInbound  int `json:",omitempty"`
+	ConnsOutbound int `json:",omitempty"`
+	Streams       int `json:",omitempty"`
+
+	// FD is the number of file descriptors the scope may use; every open
+	// connection uses one.
+	FD int `json:",omitempty"`
+
+	// Memory is the heap size, in bytes, above which new inbound
+	// connections and streams are refused. Only honored in the system
+	// scope.
+	Memory int64 `json:",omitempty"`
+}
+
+// Unlimited is the value of a ResourceLimit field that lifts the limit.
+const Unlimited = -1
+
+// LoadResourceMgr reads the Swarm.ResourceMgr section of the config.
+func LoadResourceMgr(r KeyGetter) (*ResourceMgr, error) {
+	cfg := new(ResourceMgr)
+	if err := Load(r, ResourceMgrKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// TransportsKey is the config key of the transports section.
+const TransportsKey = "Swarm.Transports"
+
+// Transports selects the libp2p transports the node uses.
+type Transports struct {
+	// Network enables or disables the network transports.
+	Network TransportsNetwork
+
+	// WebSocket configures the TLS certificate of /wss listeners.
+	WebSocket WebSocketTLS
+}
+
+// WebSocketTLS holds the certificate settings of secure WebSocket
+// listeners. Either CertFile and KeyFile or AutoTLS must be set to listen on
+// a /wss address.
+type WebSocketTLS struct {
+	// Domain is the DNS name the certificate is valid for. When set, /wss
+	// addresses are announced as /dns4/<Domain>/tcp/<port>/wss, which is
+	// what browsers need to validate the certificate.
+	Domain string `json:",omitempty"`
+
+	// CertFile and KeyFile are PEM files holding the certificate chain and
+	// its private key.
+	CertFile string `json:",omitempty"`
+	KeyFile  string `json:",omitempty"`
+
+	// AutoTLS obtains and renews a certificate for Domain over ACME.
+	AutoTLS AutoTLS
+}
+
+// AutoTLS configures automatic certificate provisioning.
+type AutoTLS struct {
+	Enabled bool
+
+	// AcceptTOS accepts the terms of service of the CA, which is required
+	// to obtain a certificate.
+	AcceptTOS bool `json:",omitempty"`
+
+	// Email is the contact address registered with the CA.
+	Email string `json:",omitempty"`
+
+	// CA is the ACME directory URL, Let's Encrypt if empty.
+	CA string `json:",omitempty"`
+}
+
+// TransportsNetwork holds one flag per network transport. Unset flags use
+// the default of each transport.
+type TransportsNetwork struct {
+	// QUIC enables the QUIC transport. Enabled by default.
+	QUIC Flag `json:",omitempty"`
+}
+
+// LoadTransports reads the Swarm.Transports section of the config.
+func LoadTransports(r KeyGetter) (*Transports, error) {
+	cfg := new(Transports)
+	if err := Load(r, TransportsKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// EnableHolePunchingKey is the config key of the hole punching flag.
+const EnableHolePunchingKey = "Swarm.EnableHolePunching"
+
+// ForcePrivateNetworkKey is the config key of the flag refusing to start
+// without a private network key.
+const ForcePrivateNetworkKey = "Swarm.ForcePrivateNetwork"
+
+// LoadFlag reads a single flag from the config.
+func LoadFlag(r KeyGetter, key string) (Flag, error) {
+	var f Flag
+	if err := Load(r, key, &f); err != nil {
+		return Default, err
+	}
+	return f, nil
+}
+
+// RelayServiceKey is the config key of the relay service section.
+const RelayServiceKey = "Swarm.RelayService"
+
+// RelayService configures the circuit relay v2 service. Zero values use the
+// defaults of the relay package.
+type RelayService struct {
+	// Enabled makes the node relay connections for peers that reserved a
+	// slot with it.
+	Enabled bool
+
+	// ConnectionDurationLimit is the time after which a relayed connection
+	// is closed.
+	ConnectionDurationLimit string `json:",omitempty"`
+
+	// ConnectionDataLimit is the number of bytes relayed in each direction
+	// after which a relayed connection is closed.
+	ConnectionDataLimit int64 `json:",omitempty"`
+
+	// ReservationTTL is the lifetime of a reservation; peers must renew
+	// them before they expire.
+	ReservationTTL string `json:",omitempty"`
+
+	// MaxReservations is the maximum number of active reservations.
+	MaxReservations int `json:",omitempty"`
+
+	// MaxReservationsPerIP is the maximum number of active reservations
+	// made from the same IP address.
+	MaxReservationsPerIP int `json:",omitempty"`
+
+	// MaxCircuits is the maximum number of relayed connections to or from
+	// a single peer.
+	MaxCircuits int `json:",omitempty"`
+
+	// BufferSize is the size of the buffer of each relayed direction.
+	BufferSize int `json:",omitempty"`
+}
+
+// LoadRelayService reads the Swarm.RelayService section of the config.
+func LoadRelayService(r KeyGetter) (*RelayService, error) {
+	cfg := new(RelayService)
+	if err := Load(r, RelayServiceKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// PeerstoreKey is the config key of the peerstore section.
+const PeerstoreKey = "Swarm.Peerstore"
+
+// Peerstore configures the persistence of the peerstore. Zero values use the
+// defaults of the peerstore package.
+type Peerstore struct {
+	// Persist stores the addresses, protocols and latencies of the peers
+	// in the datastore, so that they survive restarts. Enabled by default.
+	Persist Flag `json:",omitempty"`
+
+	// AddrTTL is how long an address is kept after the peer was last
+	// seen on it.
+	AddrTTL string `json:",omitempty"`
+
+	// FlushInterval is the interval at which the peerstore is written to
+	// the datastore.
+	FlushInterval string `json:",omitempty"`
+
+	// MaxPeers is the maximum number of peers kept in the datastore, the
+	// least recently seen are dropped first.
+	MaxPeers int `json:",omitempty"`
+}
+
+// LoadPeerstore reads the Swarm.Peerstore section of the config.
+func LoadPeerstore(r KeyGetter) (*Peerstore, error) {
+	cfg := new(Peerstore)
+	if err := Load(r, PeerstoreKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// BandwidthHistoryKey is the config key of the bandwidth history section.
+const BandwidthHistoryKey = "Swarm.BandwidthHistory"
+
+// BandwidthHistory configures the history of the bandwidth samples kept by
+// the node. Zero values use the defaults of the bandwidth package.
+type BandwidthHistory struct {
+	// Interval is the interval at which the bandwidth is sampled.
+	Interval string `json:",omitempty"`
+
+	// Length is the number of samples kept for the whole node, for each
+	// protocol and for each active peer.
+	Length int `json:",omitempty"`
+}
+
+// LoadBandwidthHistory reads the Swarm.BandwidthHistory section of the
+// config.
+func LoadBandwidthHistory(r KeyGetter) (*BandwidthHistory, error) {
+	cfg := new(BandwidthHistory)
+	if err := Load(r, BandwidthHistoryKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// BandwidthLimitsKey is the config key of the bandwidth limits section.
+const BandwidthLimitsKey = "Swarm.BandwidthLimits"
+
+// BandwidthLimits caps the rates of the libp2p streams of the node. The
+// limits are cumulative: a stream is slowed down by every limit it falls
+// under.
+type BandwidthLimits struct {
+	// Total limits the streams of the node as a whole.
+	Total BandwidthRates
+
+	// Peer limits the streams of each remote peer.
+	Peer BandwidthRates
+
+	// Classes limit the streams of some protocols or peers, by name.
+	Classes map[string]BandwidthClass `json:",omitempty"`
+}
+
+// BandwidthRates are the rates of the two directions of a limit, such as
+// "50Mbps" or "10MB/s". An empty rate is unlimited.
+type BandwidthRates struct {
+	In  string `json:",omitempty"`
+	Out string `json:",omitempty"`
+}
+
+// BandwidthClass limits the streams of the protocols and peers it lists.
+type BandwidthClass struct {
+	BandwidthRates
+
+	// Protocols are the prefixes of the protocol IDs of the class, such
+	// as "/ipfs/bitswap" for every version of bitswap. Any protocol is
+	// part of the class when empty.
+	Protocols []string `json:",omitempty"`
+
+	// Peers are the IDs of the peers of the class. Any peer is part of
+	// the class when empty.
+	Peers []string `json:",omitempty"`
+
+	// PerPeer applies the rates to each peer of the class on its own,
+	// instead of to the class as a whole.
+	PerPeer bool `json:",omitempty"`
+}
+
+// LoadBandwidthLimits reads the Swarm.BandwidthLimits section of the
+// config.
+func LoadBandwidthLimits(r KeyGetter) (*BandwidthLimits, error) {
+	cfg := new(BandwidthLimits)
+	if err := Load(r, BandwidthLimitsKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ConnGaterKey is the config key of the connection gater section.
+const ConnGaterKey = "Swarm.ConnGater"
+
+// ConnGater allows or denies the connections of the node by the remote
+// address and peer. Unlike Swarm.AddrFilters, which only denies subnets, it
+// can allow some subnets, autonomous systems or peers and deny the others.
+type ConnGater struct {
+	// Rules are evaluated in order, the first rule matching a connection
+	// decides whether it's allowed.
+	Rules []ConnGaterRule `json:",omitempty"`
+
+	// Default is the action of the connections no rule matches, "allow"
+	// when empty.
+	Default string `json:",omitempty"`
+
+	// GeoIPDatabase is the path of the ip2asn database the autonomous
+	// systems of the addresses are looked up in, required by the rules
+	// with ASNs.
+	GeoIPDatabase string `json:",omitempty"`
+}
+
+// ConnGaterRule matches the connections matching all of its criteria, a
+// connection matching a criterion when it matches any of its values.
+type ConnGaterRule struct {
+	// Action is "allow" or "deny".
+	Action string
+
+	// Subnets match the IP address of the remote address, as CIDRs such
+	// as "10.0.0.0/8" or as multiaddr filters such as
+	// "/ip4/10.0.0.0/ipcidr/8".
+	Subnets []string `json:",omitempty"`
+
+	// ASNs match the autonomous system of the remote address.
+	ASNs []int `json:",omitempty"`
+
+	// Peers match the ID of the remote peer.
+	Peers []string `json:",omitempty"`
+}
+
+// LoadConnGater reads the Swarm.ConnGater section of the config.
+func LoadConnGater(r KeyGetter) (*ConnGater, error) {
+	cfg := new(ConnGater)
+	if err := Load(r, ConnGaterKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
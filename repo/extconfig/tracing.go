@@ -0,0 +1,28 @@
+package extconfig
+
+// TracingKey is the config key of the tracing section.
+const TracingKey = "Tracing"
+
+// Tracing configures the export of OpenTelemetry traces.
+type Tracing struct {
+	// Endpoint is the base URL of an OTLP/HTTP collector, such as
+	// "http://localhost:4318". Traces are only exported when it is set.
+	Endpoint string `json:",omitempty"`
+
+	// ServiceName is the service.name the traces are reported under.
+	ServiceName string `json:",omitempty"`
+
+	// SampleRatio is the fraction of the traces started by the node that
+	// are exported, between 0 and 1. Traces started by a caller follow its
+	// sampling decision. Defaults to 1.
+	SampleRatio *float64 `json:",omitempty"`
+}
+
+// LoadTracing reads the Tracing section of the config.
+func LoadTracing(r KeyGetter) (*Tracing, error) {
+	cfg := new(Tracing)
+	if err := Load(r, TracingKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
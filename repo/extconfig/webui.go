@@ -0,0 +1,29 @@
+package extconfig
+
+// WebUIKey is the config key of the WebUI section.
+const WebUIKey = "WebUI"
+
+// WebUI configures the WebUI the API serves at /webui.
+type WebUI struct {
+	// Path is the path of the WebUI. When empty, it's the root of Bundle if
+	// set, and otherwise the WebUI released with go-ipfs.
+	Path string `json:",omitempty"`
+
+	// Bundle is a CAR file holding a WebUI, with a single root. It's
+	// imported and pinned when the daemon starts, for the nodes without
+	// access to the network.
+	Bundle string `json:",omitempty"`
+
+	// Offline serves the WebUI from the local repo only, never fetching
+	// its blocks from the network.
+	Offline Flag `json:",omitempty"`
+}
+
+// LoadWebUI reads the WebUI section of the config.
+func LoadWebUI(r KeyGetter) (*WebUI, error) {
+	cfg := new(WebUI)
+	if err := Load(r, WebUIKey, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
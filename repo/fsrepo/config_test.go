@@ -217,3 +217,139 @@ func TestMeasureConfig(t *testing.T) {
 		t.Errorf("expected '*measure.measure' got '%s'", typ)
 	}
 }
+
+func TestSetConfigPreservesUnknownKeys(t *testing.T) {
+	path := testRepoPath("config", t)
+	if err := Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.SetConfigKey("Pubsub.PersistentTopics", []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetConfigKey("Experimental.FilestoreEnabled", true); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Pubsub.Router = "gossipsub"
+	if err := r.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	topics, err := r.GetConfigKey("Pubsub.PersistentTopics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, ok := topics.([]interface{}); !ok || len(list) != 1 || list[0] != "foo" {
+		t.Fatalf("unexpected value for Pubsub.PersistentTopics: %v", topics)
+	}
+
+	router, err := r.GetConfigKey("Pubsub.Router")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if router != "gossipsub" {
+		t.Fatalf("expected Pubsub.Router to be updated, got %v", router)
+	}
+}
+
+func TestParseConfigOverrides(t *testing.T) {
+	overrides, err := ParseConfigOverrides([]string{
+		"HOME=/root",
+		"IPFS_CONFIG_Swarm_ConnMgr_HighWater=200",
+		"IPFS_CONFIG_Routing_Type=dht",
+	}, []string{
+		"Routing.Type=none",
+		`Bootstrap=["/ip4/1.2.3.4/tcp/4001"]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"Swarm.ConnMgr.HighWater": float64(200),
+		"Routing.Type":            "none",
+		"Bootstrap":               []interface{}{"/ip4/1.2.3.4/tcp/4001"},
+	}
+	if !reflect.DeepEqual(overrides, expected) {
+		t.Fatalf("expected %v, got %v", expected, overrides)
+	}
+
+	if _, err := ParseConfigOverrides(nil, []string{"Routing.Type"}); err == nil {
+		t.Fatal("expected an override without a value to fail")
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	path := testRepoPath("overrides", t)
+	if err := Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.SetConfigKey("Routing.Type", "dht"); err != nil {
+		t.Fatal(err)
+	}
+	err = r.(*FSRepo).SetConfigOverrides(map[string]interface{}{
+		"Routing.Type":            "none",
+		"Swarm.ConnMgr.HighWater": float64(200),
+		"Peering.Peers":           []interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Routing.Type != "none" || cfg.Swarm.ConnMgr.HighWater != 200 {
+		t.Fatalf("expected the overrides to apply, got %+v %+v", cfg.Routing, cfg.Swarm.ConnMgr)
+	}
+	if _, err := r.GetConfigKey("Peering.Peers"); err != nil {
+		t.Fatalf("expected the keys unknown to the config struct to be overridden: %s", err)
+	}
+
+	// writing the config doesn't persist the overrides
+	cfg.Pubsub.Router = "gossipsub"
+	if err := r.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetConfigKey("Swarm.ConnMgr.LowWater", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ConfigAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Routing.Type != "dht" || file.Swarm.ConnMgr.HighWater != 0 {
+		t.Fatalf("expected the overrides not to be written, got %+v %+v", file.Routing, file.Swarm.ConnMgr)
+	}
+	if file.Pubsub.Router != "gossipsub" || file.Swarm.ConnMgr.LowWater != 100 {
+		t.Fatal("expected the changes to be written")
+	}
+
+	cfg, err = r.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Routing.Type != "none" || cfg.Swarm.ConnMgr.HighWater != 200 || cfg.Swarm.ConnMgr.LowWater != 100 {
+		t.Fatal("expected the overrides to still apply")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 
 	repo "github.com/ipfs/go-ipfs/repo"
+	tiered "github.com/ipfs/go-ipfs/repo/tiered"
 
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
@@ -65,9 +66,22 @@ func init() {
 		"mem":      MemDatastoreConfig,
 		"log":      LogDatastoreConfig,
 		"measure":  MeasureDatastoreConfig,
+		"tiered":   TieredDatastoreConfig,
 	}
 }
 
+// AddDatastoreConfigHandler adds a datastore type, parsing the specs of
+// that type with dsc. It's used by the datastore plugins.
+func AddDatastoreConfigHandler(name string, dsc ConfigFromMap) error {
+	_, ok := datastores[name]
+	if ok {
+		return fmt.Errorf("already have a datastore named %q", name)
+	}
+
+	datastores[name] = dsc
+	return nil
+}
+
 // AnyDatastoreConfig returns a DatastoreConfig from a spec based on
 // the "type" parameter
 func AnyDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
@@ -410,3 +424,90 @@ func (c *badgerdsDatastoreConfig) Create(path string) (repo.Datastore, error) {
 
 	return badgerds.NewDatastore(p, &defopts)
 }
+
+type tieredDatastoreConfig struct {
+	cache   DatastoreConfig
+	backing DatastoreConfig
+	opts    tiered.Options
+}
+
+// TieredDatastoreConfig returns a tiered DatastoreConfig from a spec: a
+// cache datastore of cacheSize bytes in front of a backing datastore
+func TieredDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
+	var c tieredDatastoreConfig
+
+	for _, child := range []struct {
+		name string
+		dst  *DatastoreConfig
+	}{
+		{"cache", &c.cache},
+		{"backing", &c.backing},
+	} {
+		field, ok := params[child.name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'%s' field is missing or not a map", child.name)
+		}
+		dsc, err := AnyDatastoreConfig(field)
+		if err != nil {
+			return nil, err
+		}
+		*child.dst = dsc
+	}
+
+	size, ok := params["cacheSize"].(string)
+	if !ok {
+		return nil, fmt.Errorf("'cacheSize' field is missing or not a string")
+	}
+	s, err := humanize.ParseBytes(size)
+	if err != nil {
+		return nil, err
+	}
+	c.opts.MaxSize = int64(s)
+
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{
+		{"eviction", &c.opts.Eviction},
+		{"writeMode", &c.opts.WriteMode},
+		{"mountpoint", &c.opts.Name},
+	} {
+		v, ok := params[f.name]
+		if !ok {
+			continue
+		}
+		if *f.dst, ok = v.(string); !ok {
+			return nil, fmt.Errorf("'%s' field was not a string", f.name)
+		}
+	}
+
+	return &c, nil
+}
+
+func (c *tieredDatastoreConfig) DiskSpec() DiskSpec {
+	return map[string]interface{}{
+		"type":    "tiered",
+		"cache":   c.cache.DiskSpec(),
+		"backing": c.backing.DiskSpec(),
+	}
+}
+
+func (c *tieredDatastoreConfig) Create(path string) (repo.Datastore, error) {
+	cache, err := c.cache.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	backing, err := c.backing.Create(path)
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+
+	d, err := tiered.New(cache, backing, c.opts)
+	if err != nil {
+		cache.Close()
+		backing.Close()
+		return nil, err
+	}
+	return d, nil
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	repo "github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/common"
 	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+	swarmkey "github.com/ipfs/go-ipfs/swarmkey"
 	dir "github.com/ipfs/go-ipfs/thirdparty/dir"
 
 	util "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
@@ -51,6 +53,7 @@ var (
 	ErrNoVersion     = errors.New("no version file found, please run 0-to-1 migration tool.\n" + migrationInstructions)
 	ErrOldRepo       = errors.New("ipfs repo found in old '~/.go-ipfs' location, please run migration tool.\n" + migrationInstructions)
 	ErrNeedMigration = errors.New("ipfs repo needs migration")
+	ErrReadOnly      = errors.New("the repo is opened read-only")
 )
 
 type NoRepoError struct {
@@ -97,12 +100,18 @@ type FSRepo struct {
 	// path is the file-system path
 	path string
 	// lockfile is the file system lock to prevent others from opening
-	// the same fsrepo path concurrently
+	// the same fsrepo path concurrently, the shared readers lock when
+	// readOnly
 	lockfile io.Closer
+	// readOnly is set by OpenReadOnly
+	readOnly bool
 	config   *config.Config
 	ds       repo.Datastore
 	keystore keystore.Keystore
 	filemgr  *filestore.FileManager
+
+	// overrides are the config values set with SetConfigOverrides, by key
+	overrides map[string]interface{}
 }
 
 var _ repo.Repo = (*FSRepo)(nil)
@@ -142,37 +151,95 @@ func open(repoPath string) (repo.Repo, error) {
 		}
 	}()
 
-	// Check version, and error out if not matching
-	ver, err := mfsr.RepoPath(r.path).Version()
+	if err := checkVersion(r.path); err != nil {
+		return nil, err
+	}
+
+	// check repo path, then check all constituent parts.
+	if err := dir.Writable(r.path); err != nil {
+		return nil, err
+	}
+
+	if err := r.openParts(); err != nil {
+		return nil, err
+	}
+
+	keepLocked = true
+	return r, nil
+}
+
+// OpenReadOnly opens the FSRepo at path without taking its lock, so that it
+// can be read while another process, such as the daemon, owns it. It shares
+// the readers lock with the other readers instead, and fails with
+// ErrMigrating while the repo is migrated. The config and the datastore
+// can't be modified, and nothing is written to the datastore directories;
+// the leveldb datastores only see the entries written before the repo was
+// opened. Closing the repo leaves the api file of the owner.
+func OpenReadOnly(repoPath string) (repo.Repo, error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	r, err := newFSRepo(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	r.readOnly = true
+
+	if err := checkInitialized(r.path); err != nil {
+		return nil, err
+	}
+
+	r.lockfile, err = lockReaders(r.path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkVersion(r.path); err != nil {
+		r.lockfile.Close()
+		return nil, err
+	}
+
+	if err := r.openParts(); err != nil {
+		r.lockfile.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkVersion returns an error if the version of the repo at path isn't
+// the one of the program.
+func checkVersion(path string) error {
+	ver, err := mfsr.RepoPath(path).Version()
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, ErrNoVersion
+			return ErrNoVersion
 		}
-		return nil, err
+		return err
 	}
 
 	if RepoVersion > ver {
-		return nil, ErrNeedMigration
+		return ErrNeedMigration
 	} else if ver > RepoVersion {
 		// program version too low for existing repo
-		return nil, fmt.Errorf(programTooLowMessage, RepoVersion, ver)
-	}
-
-	// check repo path, then check all constituent parts.
-	if err := dir.Writable(r.path); err != nil {
-		return nil, err
+		return fmt.Errorf(programTooLowMessage, RepoVersion, ver)
 	}
+	return nil
+}
 
+// openParts opens the config, the datastore, the keystore and the file
+// manager of the repo.
+func (r *FSRepo) openParts() error {
 	if err := r.openConfig(); err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := r.openDatastore(); err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := r.openKeystore(); err != nil {
-		return nil, err
+		r.ds.Close()
+		return err
 	}
 
 	if r.config.Experimental.FilestoreEnabled || r.config.Experimental.UrlstoreEnabled {
@@ -180,9 +247,7 @@ func open(repoPath string) (repo.Repo, error) {
 		r.filemgr.AllowFiles = r.config.Experimental.FilestoreEnabled
 		r.filemgr.AllowUrls = r.config.Experimental.UrlstoreEnabled
 	}
-
-	keepLocked = true
-	return r, nil
+	return nil
 }
 
 func newFSRepo(rpath string) (*FSRepo, error) {
@@ -359,6 +424,10 @@ func (r *FSRepo) Path() string {
 
 // SetAPIAddr writes the API Addr to the /api file.
 func (r *FSRepo) SetAPIAddr(addr ma.Multiaddr) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+
 	f, err := os.Create(filepath.Join(r.path, apiFile))
 	if err != nil {
 		return err
@@ -421,7 +490,12 @@ func (r *FSRepo) openDatastore() error {
 			oldSpec, spec.String())
 	}
 
-	d, err := dsc.Create(r.path)
+	var d repo.Datastore
+	if r.readOnly {
+		d, err = createReadOnly(dsc, r.path)
+	} else {
+		d, err = dsc.Create(r.path)
+	}
 	if err != nil {
 		return err
 	}
@@ -435,7 +509,12 @@ func (r *FSRepo) openDatastore() error {
 }
 
 func (r *FSRepo) readSpec() (string, error) {
-	fn, err := config.Path(r.path, specFn)
+	return readSpec(r.path)
+}
+
+// readSpec reads the spec of the datastore on disk of the repo at repoPath.
+func readSpec(repoPath string) (string, error) {
+	fn, err := config.Path(repoPath, specFn)
 	if err != nil {
 		return "", err
 	}
@@ -455,6 +534,14 @@ func (r *FSRepo) Close() error {
 		return errors.New("repo is closed")
 	}
 
+	if r.readOnly {
+		// the api file is the owner's
+		r.closed = true
+		err := r.ds.Close()
+		r.lockfile.Close()
+		return err
+	}
+
 	err := os.Remove(filepath.Join(r.path, apiFile))
 	if err != nil && !os.IsNotExist(err) {
 		log.Warning("error removing api file: ", err)
@@ -498,6 +585,10 @@ func (r *FSRepo) FileManager() *filestore.FileManager {
 }
 
 func (r *FSRepo) BackupConfig(prefix string) (string, error) {
+	if r.readOnly {
+		return "", ErrReadOnly
+	}
+
 	temp, err := ioutil.TempFile(r.path, "config-"+prefix)
 	if err != nil {
 		return "", err
@@ -532,7 +623,7 @@ func (r *FSRepo) setConfigUnsynced(updated *config.Config) error {
 	// to avoid clobbering user-provided keys, must read the config from disk
 	// as a map, write the updated struct values to the map and write the map
 	// to disk.
-	var mapconf map[string]interface{}
+	var mapconf, orig map[string]interface{}
 	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
 		return err
 	}
@@ -540,16 +631,81 @@ func (r *FSRepo) setConfigUnsynced(updated *config.Config) error {
 	if err != nil {
 		return err
 	}
-	for k, v := range m {
-		mapconf[k] = v
+	mergeConfigMap(mapconf, m, reflect.TypeOf(*updated))
+	if len(r.overrides) > 0 {
+		if err := serialize.ReadConfigFile(configFilename, &orig); err != nil {
+			return err
+		}
+		revertOverrides(mapconf, orig, r.overrides)
 	}
 	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
 		return err
 	}
+	if len(r.overrides) > 0 {
+		return r.loadConfigMap(mapconf)
+	}
 	*r.config = *updated // copy so caller cannot modify this private config
 	return nil
 }
 
+// mergeConfigMap writes the values of src, the map form of a value of type t,
+// into dst. Keys of dst that are not fields of t, such as the sections
+// defined in repo/extconfig, are preserved; nested structs are merged
+// recursively so this also holds for keys added to existing sections.
+func mergeConfigMap(dst, src map[string]interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Struct {
+		// known fields missing from src were omitted as empty
+		for k := range dst {
+			if _, ok := src[k]; !ok {
+				if _, known := configField(t, k); known {
+					delete(dst, k)
+				}
+			}
+		}
+	}
+
+	for k, v := range src {
+		ft, ok := configField(t, k)
+		vm, vok := v.(map[string]interface{})
+		dm, dok := dst[k].(map[string]interface{})
+		if ok && vok && dok && isStruct(ft) {
+			mergeConfigMap(dm, vm, ft)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// configField returns the type of the field of struct t that is serialized
+// under the given json key.
+func configField(t reflect.Type, key string) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" {
+			name = tag
+		}
+		if name == key {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+func isStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
 // SetConfig updates the FSRepo's config.
 func (r *FSRepo) SetConfig(updated *config.Config) error {
 
@@ -557,9 +713,34 @@ func (r *FSRepo) SetConfig(updated *config.Config) error {
 	packageLock.Lock()
 	defer packageLock.Unlock()
 
+	if r.readOnly {
+		return ErrReadOnly
+	}
 	return r.setConfigUnsynced(updated)
 }
 
+// ReloadConfig reads the config file again, to pick up the changes made to
+// it while the repo is open.
+func (r *FSRepo) ReloadConfig() error {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	if r.closed {
+		return errors.New("cannot reload config, repo not open")
+	}
+
+	configFilename, err := config.Filename(r.path)
+	if err != nil {
+		return err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
+		return err
+	}
+	// the callers of Config() see the new values
+	return r.loadConfigMap(mapconf)
+}
+
 // GetConfigKey retrieves only the value of a particular key.
 func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 	packageLock.Lock()
@@ -577,6 +758,9 @@ func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 	if err := serialize.ReadConfigFile(filename, &cfg); err != nil {
 		return nil, err
 	}
+	if err := applyOverrides(cfg, r.overrides); err != nil {
+		return nil, err
+	}
 	return common.MapGetKV(cfg, key)
 }
 
@@ -588,6 +772,9 @@ func (r *FSRepo) SetConfigKey(key string, value interface{}) error {
 	if r.closed {
 		return errors.New("repo is closed")
 	}
+	if r.readOnly {
+		return ErrReadOnly
+	}
 
 	filename, err := config.Filename(r.path)
 	if err != nil {
@@ -690,7 +877,40 @@ func (r *FSRepo) SwarmKey() ([]byte, error) {
 	}
 	defer f.Close()
 
-	return ioutil.ReadAll(f)
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := swarmkey.Validate(b); err != nil {
+		return nil, fmt.Errorf("invalid private network key in %s: %s", spath, err)
+	}
+	return b, nil
+}
+
+// WriteSwarmKey saves the private network key of the repo at repoPath, and
+// returns the path of the key file. It refuses to overwrite an existing key.
+func WriteSwarmKey(repoPath string, key []byte) (string, error) {
+	if err := swarmkey.Validate(key); err != nil {
+		return "", err
+	}
+	if !IsInitialized(repoPath) {
+		return "", NoRepoError{Path: repoPath}
+	}
+	spath := filepath.Join(filepath.Clean(repoPath), swarmKeyFile)
+
+	f, err := os.OpenFile(spath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("%s already exists, remove it first to replace the key", spath)
+		}
+		return "", err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		os.Remove(spath)
+		return "", err
+	}
+	return spath, f.Close()
 }
 
 var _ io.Closer = &FSRepo{}
@@ -116,3 +116,69 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestOpenReadOnlyWhileOpen(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}), t)
+
+	r1, err := Open(path)
+	assert.Nil(err, t, "repo should open successfully")
+	k := datastore.NewKey("/local/key")
+	expected := []byte("value")
+	assert.Nil(r1.Datastore().Put(k, expected), t, "Put should be successful")
+
+	r2, err := OpenReadOnly(path)
+	assert.Nil(err, t, "repo should open read-only while it's open")
+	actual, err := r2.Datastore().Get(k)
+	assert.Nil(err, t, "Get should be successful")
+	assert.True(bytes.Equal(expected, actual), t, "data should match")
+	assert.True(r2.Datastore().Put(k, expected) == ErrReadOnly, t, "Put should fail")
+	assert.True(r2.SetConfigKey("Foo", "bar") == ErrReadOnly, t, "SetConfigKey should fail")
+
+	assert.Nil(r2.Close(), t)
+	assert.Nil(r1.Close(), t)
+}
+
+func TestOpenReadOnlyWritesNothing(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}), t)
+
+	r1, err := Open(path)
+	assert.Nil(err, t, "repo should open successfully")
+	k := datastore.NewKey("/blocks/KEY")
+	assert.Nil(r1.Datastore().Put(k, []byte("block")), t, "Put should be successful")
+	assert.Nil(r1.Close(), t)
+
+	diskUsage := filepath.Join(path, "blocks", "diskUsage.cache")
+	assert.Nil(os.Remove(diskUsage), t)
+
+	r2, err := OpenReadOnly(path)
+	assert.Nil(err, t, "repo should open read-only")
+	ok, err := r2.Datastore().Has(k)
+	assert.Nil(err, t, "Has should be successful")
+	assert.True(ok, t, "the block should be found")
+	assert.Nil(r2.Close(), t)
+
+	_, err = os.Stat(diskUsage)
+	assert.True(os.IsNotExist(err), t, "the disk usage cache should not be written")
+}
+
+func TestLockReaders(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}), t)
+
+	r, err := OpenReadOnly(path)
+	assert.Nil(err, t, "repo should open read-only")
+	_, err = LockReaders(path)
+	assert.True(err == ErrOpenedReadOnly, t, "LockReaders should fail while the repo is opened read-only")
+	assert.Nil(r.Close(), t)
+
+	lk, err := LockReaders(path)
+	assert.Nil(err, t, "LockReaders should succeed once the repo is closed")
+	_, err = OpenReadOnly(path)
+	assert.True(err == ErrMigrating, t, "OpenReadOnly should fail while the readers are locked")
+	assert.Nil(lk.Close(), t)
+}
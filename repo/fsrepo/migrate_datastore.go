@@ -0,0 +1,440 @@
+package fsrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	serialize "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config/serialize"
+	lockfile "gx/ipfs/QmZzgxSj8QpR58KmdeNj97eD66X6xeDAFNjpP2xTY9oKeQ/go-fs-lock"
+)
+
+const (
+	// migrationDir is where an in place migration builds the new
+	// datastore, relative to the repo.
+	migrationDir = "datastore-migration"
+
+	// migrationBackupDir is where an in place migration moves the old
+	// datastore, relative to the repo.
+	migrationBackupDir = "datastore-backup"
+
+	// migrationStateFile records the migration in progress, relative to
+	// the repo.
+	migrationStateFile = "datastore-migration.json"
+
+	migrationBatchSize        = 1024
+	migrationProgressInterval = time.Second
+)
+
+// ErrSameDatastore is returned when migrating a datastore in place to the
+// spec it already has.
+var ErrSameDatastore = errors.New("the datastore already uses this spec")
+
+// MigrateProgress counts the entries handled by a datastore migration.
+type MigrateProgress struct {
+	// Copied is the number of entries copied.
+	Copied uint64
+	// Skipped is the number of entries already copied by an interrupted
+	// run of the migration.
+	Skipped uint64
+	// Bytes is the size of the copied values.
+	Bytes uint64
+}
+
+// MigrateOptions configure MigrateDatastore.
+type MigrateOptions struct {
+	// To is the directory the new datastore is created in. The relative
+	// paths of the spec are made absolute under it, and the old datastore
+	// is left in place. When empty, the new datastore replaces the old one
+	// in the repo.
+	To string
+
+	// Progress is called periodically while the entries are copied.
+	Progress func(MigrateProgress)
+}
+
+// MigrateResult describes a finished datastore migration.
+type MigrateResult struct {
+	MigrateProgress
+
+	// Spec is the new Datastore.Spec of the config.
+	Spec map[string]interface{}
+
+	// Backup is the directory the old datastore was moved to by an in
+	// place migration.
+	Backup string
+}
+
+type migrationState struct {
+	Spec map[string]interface{}
+	To   string
+}
+
+// MigrateDatastore copies the entries of the datastore of the repo at
+// repoPath to a new datastore described by spec, then points the config of
+// the repo at it. The repo must not be in use, nor opened read-only.
+//
+// An interrupted migration resumes when called again with the same spec and
+// options: the entries already in the new datastore are skipped.
+func MigrateDatastore(ctx context.Context, repoPath string, spec map[string]interface{}, opts MigrateOptions) (*MigrateResult, error) {
+	lk, err := lockfile.Lock(repoPath, LockFile)
+	if err != nil {
+		return nil, err
+	}
+	defer lk.Close()
+	rlk, err := LockReaders(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rlk.Close()
+
+	if opts.To != "" {
+		to, err := filepath.Abs(opts.To)
+		if err != nil {
+			return nil, err
+		}
+		opts.To = to
+	}
+
+	configFilename, err := config.Filename(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
+		return nil, err
+	}
+	dsconf, ok := mapconf["Datastore"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("Datastore section missing from config file")
+	}
+	srcSpec, ok := dsconf["Spec"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("required Datastore.Spec entry missing from config file")
+	}
+
+	srcConfig, err := AnyDatastoreConfig(srcSpec)
+	if err != nil {
+		return nil, err
+	}
+	diskSpec, err := readSpec(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if diskSpec != srcConfig.DiskSpec().String() {
+		return nil, fmt.Errorf("datastore configuration of '%s' does not match what is on disk '%s'",
+			srcConfig.DiskSpec().String(), diskSpec)
+	}
+
+	dstConfig, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	if opts.To == "" && dstConfig.DiskSpec().String() == diskSpec {
+		return nil, ErrSameDatastore
+	}
+
+	base := opts.To
+	if base == "" {
+		base = filepath.Join(repoPath, migrationDir)
+	} else if sameFile(base, repoPath) {
+		return nil, errors.New("the new location of the datastore must not be the repo")
+	}
+
+	if err := startMigration(repoPath, spec, dstConfig, opts.To); err != nil {
+		return nil, err
+	}
+
+	res, err := copyToNewDatastore(ctx, repoPath, base, srcConfig, dstConfig, opts.Progress)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.To == "" {
+		res.Spec = spec
+		res.Backup, err = swapDatastores(repoPath, base, srcSpec, spec)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		res.Spec = absSpecPaths(spec, opts.To)
+	}
+
+	newConfig, err := AnyDatastoreConfig(res.Spec)
+	if err != nil {
+		return nil, err
+	}
+	specFile, err := config.Path(repoPath, specFn)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(specFile, newConfig.DiskSpec().Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	dsconf["Spec"] = res.Spec
+	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
+		return nil, err
+	}
+
+	return res, os.Remove(filepath.Join(repoPath, migrationStateFile))
+}
+
+// startMigration records the migration, or checks that the one in progress
+// is the same.
+func startMigration(repoPath string, spec map[string]interface{}, dstConfig DatastoreConfig, to string) error {
+	stateFile := filepath.Join(repoPath, migrationStateFile)
+	b, err := ioutil.ReadFile(stateFile)
+	switch {
+	case err == nil:
+		var state migrationState
+		if err := json.Unmarshal(b, &state); err != nil {
+			return fmt.Errorf("failed to read %s: %s", stateFile, err)
+		}
+		stateConfig, err := AnyDatastoreConfig(state.Spec)
+		if err != nil {
+			return err
+		}
+		if stateConfig.DiskSpec().String() != dstConfig.DiskSpec().String() || state.To != to {
+			return fmt.Errorf("a migration of the datastore to '%s' is in progress, resume it or remove %s",
+				stateConfig.DiskSpec().String(), stateFile)
+		}
+		return nil
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if to == "" {
+		backup := filepath.Join(repoPath, migrationBackupDir)
+		if _, err := os.Stat(backup); err == nil {
+			return fmt.Errorf("the old datastore of a previous migration is in %s, remove it first", backup)
+		}
+	}
+
+	b, err = json.Marshal(&migrationState{Spec: spec, To: to})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(stateFile, b, 0600)
+}
+
+func copyToNewDatastore(ctx context.Context, repoPath, base string, srcConfig, dstConfig DatastoreConfig, progress func(MigrateProgress)) (*MigrateResult, error) {
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+
+	src, err := srcConfig.Create(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	dst, err := dstConfig.Create(base)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := copyDatastore(ctx, src, dst, progress)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &MigrateResult{MigrateProgress: p}, nil
+}
+
+// copyDatastore puts the entries of src missing from dst into dst.
+func copyDatastore(ctx context.Context, src, dst repo.Datastore, progress func(MigrateProgress)) (MigrateProgress, error) {
+	var p MigrateProgress
+
+	res, err := src.Query(dsq.Query{})
+	if err != nil {
+		return p, err
+	}
+	defer res.Close()
+
+	batch, err := dst.Batch()
+	if err != nil {
+		return p, err
+	}
+	pending := 0
+	last := time.Now()
+
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return p, e.Error
+		}
+		if err := ctx.Err(); err != nil {
+			return p, err
+		}
+
+		k := ds.RawKey(e.Key)
+		has, err := dst.Has(k)
+		if err != nil {
+			return p, err
+		}
+		if has {
+			p.Skipped++
+		} else {
+			if err := batch.Put(k, e.Value); err != nil {
+				return p, err
+			}
+			p.Copied++
+			p.Bytes += uint64(len(e.Value))
+			pending++
+		}
+
+		if pending >= migrationBatchSize {
+			if err := batch.Commit(); err != nil {
+				return p, err
+			}
+			if batch, err = dst.Batch(); err != nil {
+				return p, err
+			}
+			pending = 0
+		}
+		if progress != nil && time.Since(last) >= migrationProgressInterval {
+			progress(p)
+			last = time.Now()
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		return p, err
+	}
+	if progress != nil {
+		progress(p)
+	}
+	return p, nil
+}
+
+// swapDatastores moves the old datastore of the repo to the backup
+// directory and the new one, built in base, into the repo.
+func swapDatastores(repoPath, base string, oldSpec, newSpec map[string]interface{}) (string, error) {
+	backup := filepath.Join(repoPath, migrationBackupDir)
+	if err := os.MkdirAll(backup, 0755); err != nil {
+		return "", err
+	}
+
+	for _, p := range specPaths(oldSpec) {
+		if filepath.IsAbs(p) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(backup, p)), 0755); err != nil {
+			return "", err
+		}
+		err := os.Rename(filepath.Join(repoPath, p), filepath.Join(backup, p))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	for _, p := range specPaths(newSpec) {
+		if filepath.IsAbs(p) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(repoPath, p)), 0755); err != nil {
+			return "", err
+		}
+		if err := os.Rename(filepath.Join(base, p), filepath.Join(repoPath, p)); err != nil {
+			return "", err
+		}
+	}
+	return backup, os.RemoveAll(base)
+}
+
+// childSpecFields are the fields of the specs wrapping other datastores.
+var childSpecFields = []string{"child", "cache", "backing"}
+
+func isChildSpecField(k string) bool {
+	for _, f := range childSpecFields {
+		if k == f {
+			return true
+		}
+	}
+	return false
+}
+
+// specPaths returns the paths of the datastores of spec.
+func specPaths(spec map[string]interface{}) []string {
+	var paths []string
+	if p, ok := spec["path"].(string); ok {
+		paths = append(paths, p)
+	}
+	for _, k := range childSpecFields {
+		if child, ok := spec[k].(map[string]interface{}); ok {
+			paths = append(paths, specPaths(child)...)
+		}
+	}
+	if mounts, ok := spec["mounts"].([]interface{}); ok {
+		for _, m := range mounts {
+			if m, ok := m.(map[string]interface{}); ok {
+				paths = append(paths, specPaths(m)...)
+			}
+		}
+	}
+	return paths
+}
+
+// absSpecPaths returns a copy of spec whose relative paths are made
+// absolute under base.
+func absSpecPaths(spec map[string]interface{}, base string) map[string]interface{} {
+	out := make(map[string]interface{}, len(spec))
+	for k, v := range spec {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if isChildSpecField(k) {
+				out[k] = absSpecPaths(v, base)
+			} else {
+				out[k] = v
+			}
+		case []interface{}:
+			if k != "mounts" {
+				out[k] = v
+				break
+			}
+			mounts := make([]interface{}, len(v))
+			for i, m := range v {
+				if m, ok := m.(map[string]interface{}); ok {
+					mounts[i] = absSpecPaths(m, base)
+				} else {
+					mounts[i] = m
+				}
+			}
+			out[k] = mounts
+		case string:
+			if k == "path" && !filepath.IsAbs(v) {
+				v = filepath.Join(base, v)
+			}
+			out[k] = v
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func sameFile(a, b string) bool {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	sb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(sa, sb)
+}
@@ -0,0 +1,97 @@
+package fsrepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	datastore "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+)
+
+func TestMigrateDatastore(t *testing.T) {
+	path := testRepoPath("migrate", t)
+	defer os.RemoveAll(path)
+
+	if err := Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]string{
+		"/blocks/CIQAAAAAAAAA": "block",
+		"/local/pins":          "pins",
+	}
+	for k, v := range entries {
+		if err := r.Datastore().Put(datastore.NewKey(k), []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := map[string]interface{}{
+		"type":        "levelds",
+		"path":        "migrated",
+		"compression": "none",
+	}
+	var last MigrateProgress
+	res, err := MigrateDatastore(context.Background(), path, spec, MigrateOptions{
+		Progress: func(p MigrateProgress) { last = p },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 2 || last.Copied != 2 {
+		t.Fatalf("expected 2 entries copied, got %+v", res.MigrateProgress)
+	}
+	if _, err := os.Stat(filepath.Join(res.Backup, "blocks")); err != nil {
+		t.Fatal("expected the old blocks in the backup:", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, migrationStateFile)); !os.IsNotExist(err) {
+		t.Fatal("expected the migration state to be removed")
+	}
+
+	if _, err := MigrateDatastore(context.Background(), path, spec, MigrateOptions{}); err != ErrSameDatastore {
+		t.Fatalf("expected %s, got %v", ErrSameDatastore, err)
+	}
+
+	r, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for k, v := range entries {
+		b, err := r.Datastore().Get(datastore.NewKey(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != v {
+			t.Fatalf("expected %s to be %q, got %q", k, v, b)
+		}
+	}
+}
+
+func TestAbsSpecPaths(t *testing.T) {
+	spec := config.DefaultDatastoreConfig().Spec
+	abs := absSpecPaths(spec, "/mnt/ipfs")
+
+	paths := specPaths(abs)
+	sort.Strings(paths)
+	expected := []string{"/mnt/ipfs/blocks", "/mnt/ipfs/datastore"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+
+	paths = specPaths(spec)
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"blocks", "datastore"}) {
+		t.Fatalf("expected the spec to be left unchanged, got %v", paths)
+	}
+}
@@ -0,0 +1,152 @@
+package mfsr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	httpfetch "github.com/ipfs/go-ipfs/exchange/httpfetch"
+
+	uio "gx/ipfs/QmPL8bYtbACcSFFiSr4s2du7Na382NxRADR8hC7D9FkEA2/go-unixfs/io"
+	cid "gx/ipfs/QmPSQnBKM9g7BaUcZCvswUJVscQ1ipjmwxN5PXCjkp9EQ7/go-cid"
+	ipld "gx/ipfs/QmdDXJs4axxefSPgK6Y1QhpJWKuDPnGJiqgq4uncb4rFHL/go-ipld-format"
+)
+
+// DefaultGateway is the gateway the blocks of a DistPath given as an /ipfs/
+// path are fetched from.
+const DefaultGateway = "https://ipfs.io"
+
+// blockTimeout bounds the retrieval of a block of the dist.
+const blockTimeout = time.Minute
+
+var errReadOnlyDAG = errors.New("the dist is read-only")
+
+// fetch reads the file at url, a path under DistPath.
+//
+// The dist on IPFS, given as an /ipfs/ path or a gateway URL of one, is read
+// block by block and every block is verified against its CID, so that the
+// files read are those of the root CID: the one of the default DistPath is
+// built into ipfs. A local copy of the dist is read from the filesystem, it
+// is trusted like the directory it was put in. The other URLs are refused,
+// their content can't be verified.
+func fetch(url string) (io.ReadCloser, error) {
+	if gateway, p, ok := splitIPFSPath(url); ok {
+		return fetchIPFS(context.Background(), gateway, p)
+	}
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("%s is not on IPFS, its content can't be verified", url)
+	}
+	return os.Open(filepath.FromSlash(url))
+}
+
+// splitIPFSPath splits url, /ipfs/<cid>/... or a gateway URL like
+// https://ipfs.io/ipfs/<cid>/..., into the gateway and the path after
+// /ipfs/.
+func splitIPFSPath(url string) (string, string, bool) {
+	if strings.HasPrefix(url, "/ipfs/") {
+		return DefaultGateway, url[len("/ipfs/"):], true
+	}
+	for _, scheme := range []string{"http://", "https://"} {
+		if !strings.HasPrefix(url, scheme) {
+			continue
+		}
+		rest := url[len(scheme):]
+		i := strings.Index(rest, "/")
+		if i < 0 || !strings.HasPrefix(rest[i:], "/ipfs/") {
+			return "", "", false
+		}
+		return scheme + rest[:i], rest[i+len("/ipfs/"):], true
+	}
+	return "", "", false
+}
+
+// fetchIPFS reads the file at /ipfs/p from the gateway.
+func fetchIPFS(ctx context.Context, gateway, p string) (io.ReadCloser, error) {
+	client, err := httpfetch.NewClient([]string{gateway}, blockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	dag := &gatewayDAG{client: client}
+
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	root, err := cid.Decode(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid path /ipfs/%s: %s", p, err)
+	}
+	nd, err := dag.Get(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range parts[1:] {
+		dir, err := uio.NewDirectoryFromNode(dag, nd)
+		if err != nil {
+			return nil, fmt.Errorf("/ipfs/%s: %s", p, err)
+		}
+		nd, err = dir.Find(ctx, name)
+		if err == os.ErrNotExist {
+			return nil, fmt.Errorf("/ipfs/%s not found", p)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := uio.NewDagReader(ctx, nd, dag)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// gatewayDAG gets the nodes of the dist from a gateway. The client verifies
+// the blocks against their CID, the gateway need not be trusted.
+type gatewayDAG struct {
+	client *httpfetch.Client
+}
+
+var _ ipld.DAGService = (*gatewayDAG)(nil)
+
+func (d *gatewayDAG) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	b, err := d.client.Fetch(ctx, c)
+	if err == httpfetch.ErrNotFound {
+		return nil, ipld.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return ipld.Decode(b)
+}
+
+func (d *gatewayDAG) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	go func() {
+		defer close(out)
+		for _, c := range cids {
+			nd, err := d.Get(ctx, c)
+			out <- &ipld.NodeOption{Node: nd, Err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (d *gatewayDAG) Add(context.Context, ipld.Node) error {
+	return errReadOnlyDAG
+}
+
+func (d *gatewayDAG) AddMany(context.Context, []ipld.Node) error {
+	return errReadOnlyDAG
+}
+
+func (d *gatewayDAG) Remove(context.Context, cid.Cid) error {
+	return errReadOnlyDAG
+}
+
+func (d *gatewayDAG) RemoveMany(context.Context, []cid.Cid) error {
+	return errReadOnlyDAG
+}
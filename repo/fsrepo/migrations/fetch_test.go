@@ -0,0 +1,58 @@
+package mfsr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitIPFSPath(t *testing.T) {
+	cases := []struct {
+		url, gateway, path string
+		ok                 bool
+	}{
+		{"/ipfs/QmRoot/fs-repo-6-to-7/versions", DefaultGateway, "QmRoot/fs-repo-6-to-7/versions", true},
+		{"https://ipfs.io/ipfs/QmRoot/versions", "https://ipfs.io", "QmRoot/versions", true},
+		{"http://127.0.0.1:8080/ipfs/QmRoot", "http://127.0.0.1:8080", "QmRoot", true},
+		{"https://example.com/dist/ipfs/QmRoot", "", "", false},
+		{"https://example.com/dist", "", "", false},
+		{"/var/dist/versions", "", "", false},
+	}
+	for _, c := range cases {
+		gateway, p, ok := splitIPFSPath(c.url)
+		if ok != c.ok || gateway != c.gateway || p != c.path {
+			t.Errorf("%s: expected %q %q %t, got %q %q %t", c.url, c.gateway, c.path, c.ok, gateway, p, ok)
+		}
+	}
+}
+
+func TestFetchUnverifiable(t *testing.T) {
+	if _, err := fetch("https://example.com/dist/versions"); err == nil {
+		t.Fatal("expected a URL outside of IPFS to be refused")
+	}
+}
+
+func TestFetchLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "versions"), []byte("v1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := fetch(filepath.ToSlash(dir) + "/versions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "v1.0.0\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+}
@@ -0,0 +1,157 @@
+// Package mg6 is the 6-to-7 repo migration, built into ipfs.
+//
+// Up to version 6, the IPNS records published by the node were only stored
+// in the datastore of the DHT, wrapped in a DHT record. From version 7, the
+// publisher keeps them under their own /ipns namespace, where the
+// republisher finds them. The migration copies the records of the keys of
+// the node to that namespace; the DHT records are left in place.
+package mg6
+
+import (
+	"path/filepath"
+
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	repo "github.com/ipfs/go-ipfs/repo"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+
+	dshelp "gx/ipfs/QmPQ7bVbZAbGaJkBVJeTkkKXvLLZeN9CLWTf5fzUQ8yeWs/go-ipfs-ds-help"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	serialize "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config/serialize"
+	lockfile "gx/ipfs/QmZzgxSj8QpR58KmdeNj97eD66X6xeDAFNjpP2xTY9oKeQ/go-fs-lock"
+	recpb "gx/ipfs/QmdHb9aBELnQKTVhvvA3hsQbRgUAwsWUzBP2vZ6Y5FBYvE/go-libp2p-record/pb"
+	proto "gx/ipfs/QmdxUuburamoF6zF9qjeQC4WYcWGbWuRmdLacMEsW8ioD8/gogo-protobuf/proto"
+	base32 "gx/ipfs/QmfVj3x4D6Jkq9SEoi5n2NmoUomLwoeiwnYz2KQa15wRw6/base32"
+)
+
+var log = logging.Logger("mg6")
+
+func init() {
+	mfsr.Register(Migration{})
+}
+
+// Migration is the 6-to-7 migration.
+type Migration struct{}
+
+func (Migration) From() int {
+	return 6
+}
+
+// Apply copies the IPNS records of the keys of the node from the DHT
+// datastore to the /ipns namespace, unless they're already there.
+func (Migration) Apply(repoPath string) error {
+	return withRepo(repoPath, func(d repo.Datastore, ids []peer.ID) error {
+		for _, id := range ids {
+			has, err := d.Has(ipnsDsKey(id))
+			if err != nil {
+				return err
+			}
+			if has {
+				continue
+			}
+
+			v, err := d.Get(dhtDsKey(id))
+			if err == ds.ErrNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			rec := new(recpb.Record)
+			if err := proto.Unmarshal(v, rec); err != nil {
+				// the record would be ignored by the DHT as well
+				log.Warningf("skipping the invalid DHT record of %s: %s", id.Pretty(), err)
+				continue
+			}
+
+			if err := d.Put(ipnsDsKey(id), rec.GetValue()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Revert removes the IPNS records of the keys of the node from the /ipns
+// namespace, which the version 6 doesn't use.
+func (Migration) Revert(repoPath string) error {
+	return withRepo(repoPath, func(d repo.Datastore, ids []peer.ID) error {
+		for _, id := range ids {
+			if err := d.Delete(ipnsDsKey(id)); err != nil && err != ds.ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// withRepo locks the repo at repoPath, and calls f with its datastore and
+// the IDs of the keys of the node: its identity and the keys of its
+// keystore.
+func withRepo(repoPath string, f func(repo.Datastore, []peer.ID) error) error {
+	lk, err := lockfile.Lock(repoPath, fsrepo.LockFile)
+	if err != nil {
+		return err
+	}
+	defer lk.Close()
+
+	filename, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	var cfg config.Config
+	if err := serialize.ReadConfigFile(filename, &cfg); err != nil {
+		return err
+	}
+
+	self, err := peer.IDB58Decode(cfg.Identity.PeerID)
+	if err != nil {
+		return err
+	}
+	ids := []peer.ID{self}
+
+	ks, err := keystore.NewFSKeystore(filepath.Join(repoPath, "keystore"))
+	if err != nil {
+		return err
+	}
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		sk, err := ks.Get(name)
+		if err != nil {
+			return err
+		}
+		id, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	dsc, err := fsrepo.AnyDatastoreConfig(cfg.Datastore.Spec)
+	if err != nil {
+		return err
+	}
+	d, err := dsc.Create(repoPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return f(d, ids)
+}
+
+// ipnsDsKey is the key of the IPNS record of id in the /ipns namespace of
+// the version 7, not taken from the publisher which may change.
+func ipnsDsKey(id peer.ID) ds.Key {
+	return ds.NewKey("/ipns/" + base32.RawStdEncoding.EncodeToString([]byte(id)))
+}
+
+// dhtDsKey is the key the DHT stores the IPNS record of id under.
+func dhtDsKey(id peer.ID) ds.Key {
+	return dshelp.NewKeyFromBinary([]byte("/ipns/" + string(id)))
+}
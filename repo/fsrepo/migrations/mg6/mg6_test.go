@@ -0,0 +1,116 @@
+package mg6
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	ci "gx/ipfs/QmPvyPwuCgJ7pDmrKDxRtsScJgBaM5h4EpRL2qQJsmXf4n/go-libp2p-crypto"
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	recpb "gx/ipfs/QmdHb9aBELnQKTVhvvA3hsQbRgUAwsWUzBP2vZ6Y5FBYvE/go-libp2p-record/pb"
+	proto "gx/ipfs/QmdxUuburamoF6zF9qjeQC4WYcWGbWuRmdLacMEsW8ioD8/gogo-protobuf/proto"
+)
+
+func genID(t *testing.T) (ci.PrivKey, peer.ID) {
+	sk, _, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk, id
+}
+
+// withDatastore calls f with the datastore of the repo at path.
+func withDatastore(t *testing.T, path string, f func(ds.Datastore)) {
+	cfg := config.DefaultDatastoreConfig()
+	dsc, err := fsrepo.AnyDatastoreConfig(cfg.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := dsc.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	f(d)
+}
+
+func TestMigration(t *testing.T) {
+	path, err := ioutil.TempDir("", "mg6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	_, self := genID(t)
+	otherKey, other := genID(t)
+	_, stranger := genID(t)
+
+	err = fsrepo.Init(path, &config.Config{
+		Identity:  config.Identity{PeerID: self.Pretty()},
+		Datastore: config.DefaultDatastoreConfig(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := keystore.NewFSKeystore(filepath.Join(path, "keystore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("other", otherKey); err != nil {
+		t.Fatal(err)
+	}
+
+	withDatastore(t, path, func(d ds.Datastore) {
+		for _, id := range []peer.ID{self, other, stranger} {
+			b, err := proto.Marshal(&recpb.Record{Value: []byte(id)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Put(dhtDsKey(id), b); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	if err := (Migration{}).Apply(path); err != nil {
+		t.Fatal(err)
+	}
+	withDatastore(t, path, func(d ds.Datastore) {
+		for _, id := range []peer.ID{self, other} {
+			v, err := d.Get(ipnsDsKey(id))
+			if err != nil {
+				t.Fatalf("record of %s: %s", id.Pretty(), err)
+			}
+			if peer.ID(v) != id {
+				t.Fatalf("unexpected record of %s", id.Pretty())
+			}
+		}
+		if _, err := d.Get(ipnsDsKey(stranger)); err != ds.ErrNotFound {
+			t.Fatalf("the record of a key of another node should not be copied, got %v", err)
+		}
+	})
+
+	if err := (Migration{}).Revert(path); err != nil {
+		t.Fatal(err)
+	}
+	withDatastore(t, path, func(d ds.Datastore) {
+		for _, id := range []peer.ID{self, other} {
+			if _, err := d.Get(ipnsDsKey(id)); err != ds.ErrNotFound {
+				t.Fatalf("the record of %s should be removed, got %v", id.Pretty(), err)
+			}
+			if _, err := d.Get(dhtDsKey(id)); err != nil {
+				t.Fatalf("the DHT record of %s should be kept, got %v", id.Pretty(), err)
+			}
+		}
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -135,7 +134,7 @@ func migrationsVersion(bin string) (int, error) {
 }
 
 func GetVersions(ipfspath, dist string) ([]string, error) {
-	rc, err := httpFetch(ipfspath + "/" + dist + "/versions")
+	rc, err := fetch(ipfspath + "/" + dist + "/versions")
 	if err != nil {
 		return nil, err
 	}
@@ -168,38 +167,18 @@ func GetLatestVersion(ipfspath, dist string) (string, error) {
 	return vs[len(vs)-1], nil
 }
 
-func httpGet(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("http.NewRequest error: %s", err)
-	}
-
-	req.Header.Set("User-Agent", "go-ipfs")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http.DefaultClient.Do error: %s", err)
+// distArchiveName returns the name of the archive of the version vers of
+// distname for this platform, and its type.
+func distArchiveName(distname, vers string) (string, string, error) {
+	archive := "tar.gz"
+	if runtime.GOOS == "windows" {
+		archive = "zip"
 	}
-
-	return resp, nil
-}
-
-func httpFetch(url string) (io.ReadCloser, error) {
-	resp, err := httpGet(url)
+	osv, err := osWithVariant()
 	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode >= 400 {
-		mes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("error reading error body: %s", err)
-		}
-
-		return nil, fmt.Errorf("GET %s error: %s: %s", url, resp.Status, string(mes))
+		return "", "", err
 	}
-
-	return resp.Body, nil
+	return fmt.Sprintf("%s_%s_%s-%s.%s", distname, vers, osv, runtime.GOARCH, archive), archive, nil
 }
 
 func GetBinaryForVersion(distname, binnom, root, vers, out string) error {
@@ -207,26 +186,22 @@ func GetBinaryForVersion(distname, binnom, root, vers, out string) error {
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(dir)
 
-	var archive string
-	switch runtime.GOOS {
-	case "windows":
-		archive = "zip"
-		binnom += ".exe"
-	default:
-		archive = "tar.gz"
-	}
-	osv, err := osWithVariant()
+	finame, archive, err := distArchiveName(distname, vers)
 	if err != nil {
 		return err
 	}
-	finame := fmt.Sprintf("%s_%s_%s-%s.%s", distname, vers, osv, runtime.GOARCH, archive)
+	if archive == "zip" {
+		binnom += ".exe"
+	}
 	distpath := fmt.Sprintf("%s/%s/%s/%s", root, distname, vers, finame)
 
-	data, err := httpFetch(distpath)
+	data, err := fetch(distpath)
 	if err != nil {
 		return err
 	}
+	defer data.Close()
 
 	arcpath := filepath.Join(dir, finame)
 	fi, err := os.Create(arcpath)
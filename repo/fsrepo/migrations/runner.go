@@ -0,0 +1,180 @@
+package mfsr
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// Migration upgrades a repo from one version to the next, in process. The
+// runner writes the version file, so Apply and Revert must not.
+type Migration interface {
+	// From is the version the migration upgrades from, to From()+1.
+	From() int
+
+	// Apply migrates the repo at repoPath. It leaves the repo unchanged
+	// when it fails.
+	Apply(repoPath string) error
+
+	// Revert undoes Apply, when a later migration failed.
+	Revert(repoPath string) error
+}
+
+var (
+	embeddedLk sync.Mutex
+	embedded   = make(map[int]Migration)
+)
+
+// Register embeds m in the binary, so that the repos are migrated without
+// the fs-repo-migrations tool.
+func Register(m Migration) {
+	embeddedLk.Lock()
+	defer embeddedLk.Unlock()
+	if _, ok := embedded[m.From()]; ok {
+		panic(fmt.Sprintf("migration %d-to-%d registered twice", m.From(), m.From()+1))
+	}
+	embedded[m.From()] = m
+}
+
+func embeddedMigration(from int) (Migration, bool) {
+	embeddedLk.Lock()
+	defer embeddedLk.Unlock()
+	m, ok := embedded[from]
+	return m, ok
+}
+
+// RunMigrations migrates the repo at repoPath to the version newv, one
+// version at a time, writing its progress to w. The embedded migrations are
+// run in process; the others are downloaded from DistPath as the
+// fs-repo-<from>-to-<to> binaries, verified against the CID of DistPath as
+// described in fetch. DistPath may be a local directory, for the air-gapped
+// nodes.
+//
+// When a migration fails, the migrations already run are reverted, so the
+// repo is left at its version.
+func RunMigrations(repoPath string, newv int, w io.Writer) error {
+	rp := RepoPath(repoPath)
+	from, err := rp.Version()
+	if err != nil {
+		return err
+	}
+	if from > newv {
+		return fmt.Errorf("repo version %d is newer than %d, downgrades are not supported", from, newv)
+	}
+
+	var done []step
+	for v := from; v < newv; v++ {
+		s, err := getStep(v, w)
+		if err != nil {
+			rollback(rp, from, done, w)
+			return err
+		}
+
+		fmt.Fprintf(w, "  => Running migration %s (%s).\n", s.name(), s.origin())
+		if err := s.apply(repoPath); err != nil {
+			fmt.Fprintf(w, "  => Failed: migration %s: %s\n", s.name(), err)
+			rollback(rp, from, done, w)
+			return fmt.Errorf("migration %s failed: %s", s.name(), err)
+		}
+		done = append(done, s)
+
+		if err := rp.WriteVersion(v + 1); err != nil {
+			rollback(rp, from, done, w)
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "  => Success: fs-repo has been migrated to version %d.\n", newv)
+	return nil
+}
+
+// rollback reverts the migrations done, latest first, and restores the
+// version from.
+func rollback(rp RepoPath, from int, done []step, w io.Writer) {
+	for i := len(done) - 1; i >= 0; i-- {
+		s := done[i]
+		fmt.Fprintf(w, "  => Reverting migration %s.\n", s.name())
+		if err := s.revert(string(rp)); err != nil {
+			fmt.Fprintf(w, "  => Failed to revert migration %s: %s\n", s.name(), err)
+			fmt.Fprintln(w, "  => The repo may be inconsistent, restore it from a backup.")
+			return
+		}
+	}
+	if err := rp.WriteVersion(from); err != nil {
+		fmt.Fprintf(w, "  => Failed to restore the repo version %d: %s\n", from, err)
+	}
+}
+
+// step is a migration from a version to the next one, either embedded or
+// an external binary.
+type step struct {
+	from     int
+	embedded Migration
+	bin      string
+}
+
+func (s step) name() string {
+	return fmt.Sprintf("%d-to-%d", s.from, s.from+1)
+}
+
+func (s step) origin() string {
+	if s.embedded != nil {
+		return "built-in"
+	}
+	return s.bin
+}
+
+func (s step) apply(repoPath string) error {
+	if s.embedded != nil {
+		return s.embedded.Apply(repoPath)
+	}
+	return runMigrationBin(s.bin, "-path", repoPath)
+}
+
+func (s step) revert(repoPath string) error {
+	if s.embedded != nil {
+		return s.embedded.Revert(repoPath)
+	}
+	return runMigrationBin(s.bin, "-path", repoPath, "-revert")
+}
+
+func runMigrationBin(bin string, args ...string) error {
+	cmd := exec.Command(bin, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// getStep returns the migration from the version from, downloading it when
+// it's not embedded.
+func getStep(from int, w io.Writer) (step, error) {
+	if m, ok := embeddedMigration(from); ok {
+		return step{from: from, embedded: m}, nil
+	}
+
+	s := step{from: from}
+	dist := "fs-repo-" + s.name()
+	fmt.Fprintf(w, "  => Migration %s is not built in, downloading %s.\n", s.name(), dist)
+
+	latest, err := GetLatestVersion(DistPath, dist)
+	if err != nil {
+		return s, fmt.Errorf("failed to find the latest %s: %s", dist, err)
+	}
+
+	dir, err := ioutil.TempDir("", "go-ipfs-migrate")
+	if err != nil {
+		return s, err
+	}
+	s.bin = filepath.Join(dir, dist)
+	if err := GetBinaryForVersion(dist, dist, DistPath, latest, s.bin); err != nil {
+		return s, fmt.Errorf("failed to download %s: %s", dist, err)
+	}
+	if err := os.Chmod(s.bin, 0755); err != nil {
+		return s, err
+	}
+	return s, nil
+}
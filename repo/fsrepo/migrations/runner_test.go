@@ -0,0 +1,98 @@
+package mfsr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type testMigration struct {
+	from     int
+	fail     bool
+	applied  *[]int
+	reverted *[]int
+}
+
+func (m *testMigration) From() int { return m.from }
+
+func (m *testMigration) Apply(string) error {
+	if m.fail {
+		return errors.New("failed")
+	}
+	*m.applied = append(*m.applied, m.from)
+	return nil
+}
+
+func (m *testMigration) Revert(string) error {
+	*m.reverted = append(*m.reverted, m.from)
+	return nil
+}
+
+// setMigrations replaces the embedded migrations, and returns a function
+// restoring them.
+func setMigrations(ms ...*testMigration) func() {
+	old := embedded
+	embedded = make(map[int]Migration)
+	for _, m := range ms {
+		Register(m)
+	}
+	return func() { embedded = old }
+}
+
+func TestRunMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rp := RepoPath(dir)
+
+	var applied, reverted []int
+	defer setMigrations(
+		&testMigration{from: 100, applied: &applied, reverted: &reverted},
+		&testMigration{from: 101, applied: &applied, reverted: &reverted},
+	)()
+
+	if err := rp.WriteVersion(100); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunMigrations(dir, 102, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := rp.CheckVersion(102); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0] != 100 || applied[1] != 101 || len(reverted) != 0 {
+		t.Fatalf("unexpected migrations: applied %v, reverted %v", applied, reverted)
+	}
+}
+
+func TestRunMigrationsRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rp := RepoPath(dir)
+
+	var applied, reverted []int
+	defer setMigrations(
+		&testMigration{from: 100, applied: &applied, reverted: &reverted},
+		&testMigration{from: 101, applied: &applied, reverted: &reverted},
+		&testMigration{from: 102, fail: true, applied: &applied, reverted: &reverted},
+	)()
+
+	if err := rp.WriteVersion(100); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunMigrations(dir, 103, ioutil.Discard); err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+	if err := rp.CheckVersion(100); err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 2 || reverted[0] != 101 || reverted[1] != 100 {
+		t.Fatalf("expected 101 then 100 to be reverted, got %v", reverted)
+	}
+}
@@ -0,0 +1,142 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-ipfs/repo/common"
+
+	config "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config"
+	serialize "gx/ipfs/QmYVqYJTVjetcf1guieEgWpK1PZtHPytP624vKzTF1P3r2/go-ipfs-config/serialize"
+)
+
+// EnvConfigOverridePrefix prefixes the environment variables overriding a
+// config key, whose dots are replaced by underscores:
+// IPFS_CONFIG_Swarm_ConnMgr_HighWater=200 overrides Swarm.ConnMgr.HighWater.
+const EnvConfigOverridePrefix = "IPFS_CONFIG_"
+
+// ParseConfigOverrides returns the config overrides of the environment
+// variables in environ, formatted as by os.Environ, and of the Key=Value
+// pairs, which take precedence. Values are parsed as JSON, and taken as
+// strings when they aren't valid JSON.
+func ParseConfigOverrides(environ []string, pairs []string) (map[string]interface{}, error) {
+	overrides := make(map[string]interface{})
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvConfigOverridePrefix) {
+			continue
+		}
+		kv = strings.TrimPrefix(kv, EnvConfigOverridePrefix)
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid config override %s%s", EnvConfigOverridePrefix, kv)
+		}
+		key := strings.Replace(kv[:i], "_", ".", -1)
+		overrides[key] = parseOverrideValue(kv[i+1:])
+	}
+
+	for _, kv := range pairs {
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid config override %q, expected Key=Value", kv)
+		}
+		overrides[kv[:i]] = parseOverrideValue(kv[i+1:])
+	}
+	return overrides, nil
+}
+
+func parseOverrideValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
+
+// SetConfigOverrides sets the values returned by Config and GetConfigKey
+// in place of the ones of the config file, until the repo is closed. They
+// are never written to the file: a config written with the value of an
+// overridden key keeps the value of the file for that key.
+func (r *FSRepo) SetConfigOverrides(overrides map[string]interface{}) error {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	configFilename, err := config.Filename(r.path)
+	if err != nil {
+		return err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
+		return err
+	}
+
+	prev := r.overrides
+	r.overrides = overrides
+	if err := r.loadConfigMap(mapconf); err != nil {
+		r.overrides = prev
+		return err
+	}
+	return nil
+}
+
+// loadConfigMap sets the config to the map form of the config file, with the
+// overrides applied.
+func (r *FSRepo) loadConfigMap(mapconf map[string]interface{}) error {
+	if err := applyOverrides(mapconf, r.overrides); err != nil {
+		return err
+	}
+	conf, err := config.FromMap(mapconf)
+	if err != nil {
+		return fmt.Errorf("invalid config override: %s", err)
+	}
+	*r.config = *conf
+	return nil
+}
+
+// applyOverrides sets the overrides in the map form of a config. The keys are
+// set in order, so that a key overrides the value of its parent.
+func applyOverrides(mapconf map[string]interface{}, overrides map[string]interface{}) error {
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := common.MapSetKV(mapconf, k, overrides[k]); err != nil {
+			return fmt.Errorf("overriding config key %s: %s", k, err)
+		}
+	}
+	return nil
+}
+
+// revertOverrides sets back the keys of mapconf whose value is still the
+// override to their value in orig, the config file, so that writing a
+// config read with Config doesn't persist the overrides.
+func revertOverrides(mapconf, orig map[string]interface{}, overrides map[string]interface{}) {
+	for k, ov := range overrides {
+		v, err := common.MapGetKV(mapconf, k)
+		if err != nil || !reflect.DeepEqual(v, ov) {
+			continue
+		}
+
+		if v, err := common.MapGetKV(orig, k); err == nil {
+			common.MapSetKV(mapconf, k, v)
+			continue
+		}
+
+		// the key isn't in the file
+		parent, name := mapconf, k
+		if i := strings.LastIndex(k, "."); i >= 0 {
+			p, err := common.MapGetKV(mapconf, k[:i])
+			m, ok := p.(map[string]interface{})
+			if err != nil || !ok {
+				continue
+			}
+			parent, name = m, k[i+1:]
+		}
+		delete(parent, name)
+	}
+}
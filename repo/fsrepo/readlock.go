@@ -0,0 +1,51 @@
+package fsrepo
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ReadersLockFile is the filename of the lock shared by the processes that
+// opened the repo with OpenReadOnly, relative to config dir. It's taken
+// exclusively while the repo is migrated.
+const ReadersLockFile = "readers.lock"
+
+var (
+	// ErrMigrating is returned by OpenReadOnly while the repo is migrated.
+	ErrMigrating = errors.New("the repo is being migrated")
+	// ErrOpenedReadOnly is returned by LockReaders while another process
+	// reads the repo.
+	ErrOpenedReadOnly = errors.New("the repo is opened read-only by another process")
+
+	errLocked = errors.New("lock held by another process")
+)
+
+// LockReaders keeps OpenReadOnly from opening the repo at repoPath until the
+// returned lock is closed, while the repo is migrated. It fails with
+// ErrOpenedReadOnly if another process has the repo opened read-only.
+func LockReaders(repoPath string) (io.Closer, error) {
+	return lockReaders(repoPath, true)
+}
+
+// lockReaders takes the readers lock of the repo at repoPath, shared by the
+// readers or exclusive. It doesn't wait for the lock.
+func lockReaders(repoPath string, exclusive bool) (io.Closer, error) {
+	f, err := os.OpenFile(filepath.Join(repoPath, ReadersLockFile), os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		if err != errLocked {
+			return nil, err
+		}
+		if exclusive {
+			return nil, ErrOpenedReadOnly
+		}
+		return nil, ErrMigrating
+	}
+	// closing the file releases the lock
+	return f, nil
+}
@@ -0,0 +1,229 @@
+package fsrepo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	mount "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/mount"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	flatfs "gx/ipfs/QmWLWtY5WPncodA5CiAJP31RmWfLSfj2XTyD9QWBaWiuri/go-ds-flatfs"
+	measure "gx/ipfs/QmcUJcDvigNo8epy9STuJRuMUpjz6UxH5BSgMNSJAfzTem/go-ds-measure"
+)
+
+// createReadOnly opens the datastore of dsc for reading while another
+// process may be writing to it. The flatfs and leveldb datastores are read in
+// place without writing to their directories. The other datastore types
+// can't be opened read-only.
+func createReadOnly(dsc DatastoreConfig, path string) (repo.Datastore, error) {
+	d, err := createReadOnlyChild(dsc, path)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyDatastore{Datastore: d}, nil
+}
+
+func createReadOnlyChild(dsc DatastoreConfig, path string) (repo.Datastore, error) {
+	switch c := dsc.(type) {
+	case *mountDatastoreConfig:
+		mounts := make([]mount.Mount, 0, len(c.mounts))
+		for _, m := range c.mounts {
+			child, err := createReadOnlyChild(m.ds, path)
+			if err != nil {
+				for _, opened := range mounts {
+					opened.Datastore.(io.Closer).Close()
+				}
+				return nil, err
+			}
+			mounts = append(mounts, mount.Mount{Prefix: m.prefix, Datastore: child})
+		}
+		return mount.New(mounts), nil
+	case *measureDatastoreConfig:
+		child, err := createReadOnlyChild(c.child, path)
+		if err != nil {
+			return nil, err
+		}
+		return measure.New(c.prefix, child), nil
+	case *logDatastoreConfig:
+		child, err := createReadOnlyChild(c.child, path)
+		if err != nil {
+			return nil, err
+		}
+		return ds.NewLogDatastore(child, c.name), nil
+	case *flatfsDatastoreConfig:
+		return openFlatfsReader(c, path)
+	case *leveldsDatastoreConfig:
+		return openLevelDBReader(c, path)
+	case *memDatastoreConfig:
+		return c.Create(path)
+	default:
+		return nil, fmt.Errorf("the %s datastore can't be opened read-only", dsc.DiskSpec()["type"])
+	}
+}
+
+// flatfsExtension is the extension of the files flatfs stores the values in.
+const flatfsExtension = ".data"
+
+// flatfsReader reads a flatfs datastore in place. Unlike the datastore of
+// flatfs.CreateOrOpen, it writes nothing to the directory, not even the disk
+// usage cache.
+type flatfsReader struct {
+	path   string
+	getDir func(string) string
+}
+
+var _ repo.Datastore = (*flatfsReader)(nil)
+
+// openFlatfsReader opens the flatfs datastore of c, which must exist and
+// use the shard function of c.
+func openFlatfsReader(c *flatfsDatastoreConfig, path string) (*flatfsReader, error) {
+	p := c.path
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(path, p)
+	}
+
+	shardFun, err := flatfs.ReadShardFunc(p)
+	if err != nil {
+		return nil, fmt.Errorf("opening the flatfs datastore %s: %s", p, err)
+	}
+	if shardFun.String() != c.shardFun.String() {
+		return nil, fmt.Errorf("the flatfs datastore %s is sharded with %s, not %s", p, shardFun, c.shardFun)
+	}
+	return &flatfsReader{path: p, getDir: shardFun.Func()}, nil
+}
+
+func (f *flatfsReader) file(key ds.Key) string {
+	noslash := key.String()[1:]
+	return filepath.Join(f.path, f.getDir(noslash), noslash+flatfsExtension)
+}
+
+func (f *flatfsReader) Get(key ds.Key) ([]byte, error) {
+	b, err := ioutil.ReadFile(f.file(key))
+	if os.IsNotExist(err) {
+		return nil, ds.ErrNotFound
+	}
+	return b, err
+}
+
+func (f *flatfsReader) Has(key ds.Key) (bool, error) {
+	_, err := os.Stat(f.file(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *flatfsReader) GetSize(key ds.Key) (int, error) {
+	fi, err := os.Stat(f.file(key))
+	if os.IsNotExist(err) {
+		return -1, ds.ErrNotFound
+	}
+	if err != nil {
+		return -1, err
+	}
+	return int(fi.Size()), nil
+}
+
+func (f *flatfsReader) Put(ds.Key, []byte) error {
+	return ErrReadOnly
+}
+
+func (f *flatfsReader) Delete(ds.Key) error {
+	return ErrReadOnly
+}
+
+func (f *flatfsReader) Batch() (ds.Batch, error) {
+	return nil, ErrReadOnly
+}
+
+// Query lists the files of the shard directories one directory at a time.
+// The values of the files removed since they were listed are skipped.
+func (f *flatfsReader) Query(q dsq.Query) (dsq.Results, error) {
+	entries, err := ioutil.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(f.path, e.Name()))
+		}
+	}
+
+	var (
+		dir   string
+		names []string
+	)
+	next := func() (dsq.Result, bool) {
+		for {
+			for len(names) == 0 {
+				if len(dirs) == 0 {
+					return dsq.Result{}, false
+				}
+				dir, dirs = dirs[0], dirs[1:]
+				d, err := os.Open(dir)
+				if os.IsNotExist(err) {
+					continue
+				} else if err != nil {
+					return dsq.Result{Error: err}, true
+				}
+				names, err = d.Readdirnames(-1)
+				d.Close()
+				if err != nil {
+					return dsq.Result{Error: err}, true
+				}
+			}
+
+			name := names[0]
+			names = names[1:]
+			if !strings.HasSuffix(name, flatfsExtension) {
+				continue
+			}
+
+			e := dsq.Entry{Key: "/" + strings.TrimSuffix(name, flatfsExtension)}
+			if !q.KeysOnly {
+				v, err := ioutil.ReadFile(filepath.Join(dir, name))
+				if os.IsNotExist(err) {
+					continue
+				} else if err != nil {
+					return dsq.Result{Error: err}, true
+				}
+				e.Value = v
+			}
+			return dsq.Result{Entry: e}, true
+		}
+	}
+
+	qr := dsq.ResultsFromIterator(q, dsq.Iterator{
+		Next:  next,
+		Close: func() error { return nil },
+	})
+	return dsq.NaiveQueryApply(q, qr), nil
+}
+
+func (f *flatfsReader) Close() error {
+	return nil
+}
+
+// readOnlyDatastore fails the writes to its datastore with ErrReadOnly.
+type readOnlyDatastore struct {
+	repo.Datastore
+}
+
+func (d *readOnlyDatastore) Put(ds.Key, []byte) error {
+	return ErrReadOnly
+}
+
+func (d *readOnlyDatastore) Delete(ds.Key) error {
+	return ErrReadOnly
+}
+
+func (d *readOnlyDatastore) Batch() (ds.Batch, error) {
+	return nil, ErrReadOnly
+}
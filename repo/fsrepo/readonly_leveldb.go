@@ -0,0 +1,239 @@
+package fsrepo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	leveldb "gx/ipfs/QmbBhyDKsY4mbY6xsKt3qu9Y7FPvMJ6qbD8AMjYYvPRw1g/goleveldb/leveldb"
+	ldbopts "gx/ipfs/QmbBhyDKsY4mbY6xsKt3qu9Y7FPvMJ6qbD8AMjYYvPRw1g/goleveldb/leveldb/opt"
+	ldbstorage "gx/ipfs/QmbBhyDKsY4mbY6xsKt3qu9Y7FPvMJ6qbD8AMjYYvPRw1g/goleveldb/leveldb/storage"
+	ldbutil "gx/ipfs/QmbBhyDKsY4mbY6xsKt3qu9Y7FPvMJ6qbD8AMjYYvPRw1g/goleveldb/leveldb/util"
+)
+
+// levelDBOpenAttempts is the number of times a leveldb database is opened
+// before giving up, its owner replacing the manifest or removing journals
+// while it's read.
+const levelDBOpenAttempts = 3
+
+// levelDBReader reads a leveldb database in place, in the read-only mode of
+// leveldb. It sees the entries written before it was opened.
+type levelDBReader struct {
+	db *leveldb.DB
+}
+
+var _ repo.Datastore = (*levelDBReader)(nil)
+
+// openLevelDBReader opens the leveldb database of c without taking its
+// lock, which belongs to the process writing it.
+func openLevelDBReader(c *leveldsDatastoreConfig, path string) (*levelDBReader, error) {
+	p := c.path
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(path, p)
+	}
+
+	var err error
+	for i := 0; i < levelDBOpenAttempts; i++ {
+		var db *leveldb.DB
+		db, err = leveldb.Open(&readOnlyStorage{dir: p}, &ldbopts.Options{
+			Compression:    c.compression,
+			ReadOnly:       true,
+			ErrorIfMissing: true,
+		})
+		if err == nil {
+			return &levelDBReader{db: db}, nil
+		}
+		log.Debugf("opening the leveldb datastore %s read-only: %s", p, err)
+	}
+	return nil, fmt.Errorf("opening the leveldb datastore %s: %s", p, err)
+}
+
+func (d *levelDBReader) Get(key ds.Key) ([]byte, error) {
+	v, err := d.db.Get(key.Bytes(), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ds.ErrNotFound
+	}
+	return v, err
+}
+
+func (d *levelDBReader) Has(key ds.Key) (bool, error) {
+	return d.db.Has(key.Bytes(), nil)
+}
+
+func (d *levelDBReader) GetSize(key ds.Key) (int, error) {
+	v, err := d.Get(key)
+	if err != nil {
+		return -1, err
+	}
+	return len(v), nil
+}
+
+func (d *levelDBReader) Put(ds.Key, []byte) error {
+	return ErrReadOnly
+}
+
+func (d *levelDBReader) Delete(ds.Key) error {
+	return ErrReadOnly
+}
+
+func (d *levelDBReader) Batch() (ds.Batch, error) {
+	return nil, ErrReadOnly
+}
+
+func (d *levelDBReader) Query(q dsq.Query) (dsq.Results, error) {
+	var rng *ldbutil.Range
+	if q.Prefix != "" {
+		rng = ldbutil.BytesPrefix([]byte(q.Prefix))
+	}
+	it := d.db.NewIterator(rng, nil)
+
+	done := false
+	next := func() (dsq.Result, bool) {
+		if done {
+			return dsq.Result{}, false
+		}
+		if !it.Next() {
+			done = true
+			if err := it.Error(); err != nil {
+				return dsq.Result{Error: err}, true
+			}
+			return dsq.Result{}, false
+		}
+
+		e := dsq.Entry{Key: string(it.Key())}
+		if !q.KeysOnly {
+			// the iterator reuses the buffer
+			e.Value = append([]byte(nil), it.Value()...)
+		}
+		return dsq.Result{Entry: e}, true
+	}
+
+	qr := dsq.ResultsFromIterator(q, dsq.Iterator{
+		Next: next,
+		Close: func() error {
+			it.Release()
+			return nil
+		},
+	})
+	return dsq.NaiveQueryApply(q, qr), nil
+}
+
+func (d *levelDBReader) Close() error {
+	return d.db.Close()
+}
+
+// readOnlyStorage is a leveldb storage that opens the files of dir for
+// reading and fails the writes. Unlike the file storage of leveldb, it
+// doesn't lock dir nor write its log there.
+type readOnlyStorage struct {
+	dir string
+}
+
+var _ ldbstorage.Storage = (*readOnlyStorage)(nil)
+
+type nopLock struct{}
+
+func (nopLock) Release() {}
+
+func (s *readOnlyStorage) Lock() (ldbstorage.Lock, error) {
+	return nopLock{}, nil
+}
+
+func (s *readOnlyStorage) Log(string) {}
+
+func (s *readOnlyStorage) SetMeta(ldbstorage.FileDesc) error {
+	return ErrReadOnly
+}
+
+// GetMeta returns the manifest named in the CURRENT file.
+func (s *readOnlyStorage) GetMeta() (ldbstorage.FileDesc, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, "CURRENT"))
+	if err != nil {
+		return ldbstorage.FileDesc{}, err
+	}
+	fd, ok := parseLevelDBName(strings.TrimSuffix(string(b), "\n"))
+	if !ok || fd.Type != ldbstorage.TypeManifest {
+		return ldbstorage.FileDesc{}, fmt.Errorf("invalid leveldb CURRENT file in %s", s.dir)
+	}
+	return fd, nil
+}
+
+func (s *readOnlyStorage) List(ft ldbstorage.FileType) ([]ldbstorage.FileDesc, error) {
+	d, err := os.Open(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var fds []ldbstorage.FileDesc
+	for _, name := range names {
+		if fd, ok := parseLevelDBName(name); ok && fd.Type&ft != 0 {
+			fds = append(fds, fd)
+		}
+	}
+	return fds, nil
+}
+
+// Open opens the file of fd. The tables of the databases written by older
+// versions of leveldb have the .sst extension.
+func (s *readOnlyStorage) Open(fd ldbstorage.FileDesc) (ldbstorage.Reader, error) {
+	f, err := os.Open(filepath.Join(s.dir, fd.String()))
+	if os.IsNotExist(err) && fd.Type == ldbstorage.TypeTable {
+		f, err = os.Open(filepath.Join(s.dir, fmt.Sprintf("%06d.sst", fd.Num)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *readOnlyStorage) Create(ldbstorage.FileDesc) (ldbstorage.Writer, error) {
+	return nil, ErrReadOnly
+}
+
+func (s *readOnlyStorage) Remove(ldbstorage.FileDesc) error {
+	return ErrReadOnly
+}
+
+func (s *readOnlyStorage) Rename(ldbstorage.FileDesc, ldbstorage.FileDesc) error {
+	return ErrReadOnly
+}
+
+func (s *readOnlyStorage) Close() error {
+	return nil
+}
+
+// parseLevelDBName parses the name of a file of a leveldb database, as the
+// file storage of leveldb does.
+func parseLevelDBName(name string) (ldbstorage.FileDesc, bool) {
+	var fd ldbstorage.FileDesc
+	var ext string
+	if _, err := fmt.Sscanf(name, "%d.%s", &fd.Num, &ext); err == nil {
+		switch ext {
+		case "log":
+			fd.Type = ldbstorage.TypeJournal
+		case "ldb", "sst":
+			fd.Type = ldbstorage.TypeTable
+		case "tmp":
+			fd.Type = ldbstorage.TypeTemp
+		default:
+			return fd, false
+		}
+		return fd, true
+	}
+	if n, _ := fmt.Sscanf(name, "MANIFEST-%d%s", &fd.Num, &ext); n == 1 {
+		fd.Type = ldbstorage.TypeManifest
+		return fd, true
+	}
+	return fd, false
+}
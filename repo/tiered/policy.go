@@ -0,0 +1,232 @@
+package tiered
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// Eviction policies.
+const (
+	// EvictLRU evicts the least recently used values.
+	EvictLRU = "lru"
+	// EvictARC is the adaptive replacement cache: it balances the recently
+	// and frequently used values, so scanning a large DAG once doesn't
+	// evict the hot values.
+	EvictARC = "arc"
+)
+
+// policy picks the values evicted from the cache. It tracks the keys of the
+// cache and their sizes; callers serialize the calls.
+type policy interface {
+	// add records a key added to the cache.
+	add(key string, size int)
+	// hit records an access to a cached key.
+	hit(key string)
+	// remove forgets a key removed from the cache.
+	remove(key string)
+	// evict removes and returns the next key to evict, skipping the keys
+	// for which skip returns true. It returns false when there is none.
+	evict(skip func(string) bool) (string, bool)
+}
+
+func newPolicy(name string) (policy, error) {
+	switch name {
+	case "", EvictLRU:
+		return newLRU(), nil
+	case EvictARC:
+		return newARC(), nil
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q, expected %q or %q", name, EvictLRU, EvictARC)
+	}
+}
+
+type entry struct {
+	key  string
+	size int
+}
+
+// lruList is a list of keys, most recently used first, with their total
+// size.
+type lruList struct {
+	l     *list.List
+	elems map[string]*list.Element
+	size  int
+}
+
+func newLRUList() *lruList {
+	return &lruList{l: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (l *lruList) has(key string) bool {
+	_, ok := l.elems[key]
+	return ok
+}
+
+func (l *lruList) pushFront(key string, size int) {
+	if el, ok := l.elems[key]; ok {
+		e := el.Value.(*entry)
+		l.size += size - e.size
+		e.size = size
+		l.l.MoveToFront(el)
+		return
+	}
+	l.elems[key] = l.l.PushFront(&entry{key: key, size: size})
+	l.size += size
+}
+
+func (l *lruList) moveToFront(key string) bool {
+	el, ok := l.elems[key]
+	if ok {
+		l.l.MoveToFront(el)
+	}
+	return ok
+}
+
+func (l *lruList) remove(key string) (int, bool) {
+	el, ok := l.elems[key]
+	if !ok {
+		return 0, false
+	}
+	e := el.Value.(*entry)
+	l.l.Remove(el)
+	delete(l.elems, key)
+	l.size -= e.size
+	return e.size, true
+}
+
+// back returns the least recently used entry not skipped.
+func (l *lruList) back(skip func(string) bool) (*entry, bool) {
+	for el := l.l.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*entry)
+		if skip == nil || !skip(e.key) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (l *lruList) len() int {
+	return l.l.Len()
+}
+
+type lru struct {
+	l *lruList
+}
+
+func newLRU() *lru {
+	return &lru{l: newLRUList()}
+}
+
+func (p *lru) add(key string, size int) { p.l.pushFront(key, size) }
+func (p *lru) hit(key string)           { p.l.moveToFront(key) }
+func (p *lru) remove(key string)        { p.l.remove(key) }
+
+func (p *lru) evict(skip func(string) bool) (string, bool) {
+	e, ok := p.l.back(skip)
+	if !ok {
+		return "", false
+	}
+	p.l.remove(e.key)
+	return e.key, true
+}
+
+// arc is the adaptive replacement cache of Megiddo and Modha, weighted by
+// the size of the values. t1 holds the keys used once recently, t2 the
+// keys used more than once; b1 and b2 remember the keys evicted from them.
+// A hit in b1 means t1 was too small and grows its target size, a hit in
+// b2 shrinks it.
+type arc struct {
+	t1, t2, b1, b2 *lruList
+
+	// target is the target size of t1.
+	target int
+}
+
+func newARC() *arc {
+	return &arc{t1: newLRUList(), t2: newLRUList(), b1: newLRUList(), b2: newLRUList()}
+}
+
+func (p *arc) add(key string, size int) {
+	switch {
+	case p.t1.has(key), p.t2.has(key):
+		p.t1.remove(key)
+		p.t2.pushFront(key, size)
+	case p.b1.has(key):
+		p.target += size * max(1, p.b2.len()/max(p.b1.len(), 1))
+		p.b1.remove(key)
+		p.t2.pushFront(key, size)
+	case p.b2.has(key):
+		p.target -= size * max(1, p.b1.len()/max(p.b2.len(), 1))
+		if p.target < 0 {
+			p.target = 0
+		}
+		p.b2.remove(key)
+		p.t2.pushFront(key, size)
+	default:
+		p.t1.pushFront(key, size)
+	}
+
+	if total := p.t1.size + p.t2.size; p.target > total {
+		p.target = total
+	}
+	p.trimGhosts()
+}
+
+func (p *arc) hit(key string) {
+	if size, ok := p.t1.remove(key); ok {
+		p.t2.pushFront(key, size)
+		return
+	}
+	p.t2.moveToFront(key)
+}
+
+func (p *arc) remove(key string) {
+	p.t1.remove(key)
+	p.t2.remove(key)
+	p.b1.remove(key)
+	p.b2.remove(key)
+}
+
+func (p *arc) evict(skip func(string) bool) (string, bool) {
+	from, ghost := p.t2, p.b2
+	if p.t1.size > p.target || p.t2.len() == 0 {
+		from, ghost = p.t1, p.b1
+	}
+
+	e, ok := from.back(skip)
+	if !ok {
+		// everything left in the preferred list is skipped
+		if from == p.t1 {
+			from, ghost = p.t2, p.b2
+		} else {
+			from, ghost = p.t1, p.b1
+		}
+		if e, ok = from.back(skip); !ok {
+			return "", false
+		}
+	}
+	from.remove(e.key)
+	ghost.pushFront(e.key, e.size)
+	p.trimGhosts()
+	return e.key, true
+}
+
+// trimGhosts bounds the ghost lists to as many keys as the cache holds.
+func (p *arc) trimGhosts() {
+	n := p.t1.len() + p.t2.len()
+	for p.b1.len()+p.b2.len() > n {
+		g := p.b1
+		if g.len() == 0 || (p.b2.len() > 0 && p.b2.len() > g.len()) {
+			g = p.b2
+		}
+		e, _ := g.back(nil)
+		g.remove(e.key)
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
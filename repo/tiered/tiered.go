@@ -0,0 +1,598 @@
+// Package tiered implements a datastore keeping a bounded local cache of the
+// values of a slower backing datastore, such as S3 or a network file
+// system, so that the hot blocks are served at local speed.
+//
+// Values read from the backing datastore are added to the cache, and the
+// eviction policy removes values from the cache once it is full. Writes go
+// to the backing datastore before the cache (write-through), or to the cache
+// only and are uploaded in the background (write-back).
+package tiered
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+)
+
+var log = logging.Logger("tiered")
+
+// Write modes.
+const (
+	// WriteThrough writes the values to the backing datastore, then to the
+	// cache.
+	WriteThrough = "write-through"
+	// WriteBack writes the values to the cache and uploads them to the
+	// backing datastore in the background. Values not uploaded yet are
+	// never evicted.
+	WriteBack = "write-back"
+)
+
+// flushRetryDelay is the delay before retrying a failed upload of
+// write-back.
+const flushRetryDelay = 5 * time.Second
+
+// Options configure a Datastore.
+type Options struct {
+	// MaxSize is the size of the cache in bytes.
+	MaxSize int64
+	// Eviction is EvictLRU or EvictARC. Defaults to LRU.
+	Eviction string
+	// WriteMode is WriteThrough or WriteBack. Defaults to write-through.
+	WriteMode string
+	// Name identifies the datastore in the stats, usually its mountpoint.
+	Name string
+}
+
+// Stat is a snapshot of the state of a Datastore.
+type Stat struct {
+	Name      string
+	Eviction  string
+	WriteMode string
+
+	Size    int64
+	MaxSize int64
+	Entries int
+	Dirty   int
+
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// Datastore is a cache datastore in front of a backing datastore.
+type Datastore struct {
+	cache   repo.Datastore
+	backing repo.Datastore
+	opts    Options
+
+	lk     sync.Mutex
+	policy policy
+	sizes  map[string]int // cached keys
+	size   int64
+	dirty  map[string]bool // written back, not uploaded yet
+
+	hits, misses, evictions uint64
+
+	flush     chan struct{}
+	closing   chan struct{}
+	flushDone chan struct{}
+}
+
+var _ repo.Datastore = (*Datastore)(nil)
+
+var (
+	instancesLk sync.Mutex
+	instances   = make(map[*Datastore]struct{})
+)
+
+// New returns a datastore caching the values of backing in cache. The cache
+// is indexed when opened; in write-back mode the values of the cache
+// missing from the backing datastore are uploaded in the background.
+func New(cache, backing repo.Datastore, opts Options) (*Datastore, error) {
+	if opts.MaxSize <= 0 {
+		return nil, errors.New("the size of the cache must be positive")
+	}
+	switch opts.WriteMode {
+	case "":
+		opts.WriteMode = WriteThrough
+	case WriteThrough, WriteBack:
+	default:
+		return nil, fmt.Errorf("unknown write mode %q, expected %q or %q", opts.WriteMode, WriteThrough, WriteBack)
+	}
+	if opts.Eviction == "" {
+		opts.Eviction = EvictLRU
+	}
+	p, err := newPolicy(opts.Eviction)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Datastore{
+		cache:   cache,
+		backing: backing,
+		opts:    opts,
+		policy:  p,
+		sizes:   make(map[string]int),
+		dirty:   make(map[string]bool),
+	}
+	if err := d.index(); err != nil {
+		return nil, err
+	}
+
+	if opts.WriteMode == WriteBack {
+		d.flush = make(chan struct{}, 1)
+		d.closing = make(chan struct{})
+		d.flushDone = make(chan struct{})
+		go d.flushWorker()
+	}
+
+	instancesLk.Lock()
+	instances[d] = struct{}{}
+	instancesLk.Unlock()
+	return d, nil
+}
+
+// Instances returns the stats of the open tiered datastores.
+func Instances() []Stat {
+	instancesLk.Lock()
+	defer instancesLk.Unlock()
+
+	stats := make([]Stat, 0, len(instances))
+	for d := range instances {
+		stats = append(stats, d.Stat())
+	}
+	return stats
+}
+
+// index records the values already in the cache, in no particular order of
+// use.
+func (d *Datastore) index() error {
+	res, err := d.cache.Query(dsq.Query{KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return e.Error
+		}
+		size, err := d.cache.GetSize(ds.RawKey(e.Key))
+		if err != nil {
+			return err
+		}
+		d.sizes[e.Key] = size
+		d.size += int64(size)
+		d.policy.add(e.Key, size)
+	}
+	d.evict()
+	return nil
+}
+
+// Stat returns the stats of the datastore.
+func (d *Datastore) Stat() Stat {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	return Stat{
+		Name:      d.opts.Name,
+		Eviction:  d.opts.Eviction,
+		WriteMode: d.opts.WriteMode,
+		Size:      d.size,
+		MaxSize:   d.opts.MaxSize,
+		Entries:   len(d.sizes),
+		Dirty:     len(d.dirty),
+		Hits:      atomic.LoadUint64(&d.hits),
+		Misses:    atomic.LoadUint64(&d.misses),
+		Evictions: d.evictions,
+	}
+}
+
+// cached adds a value written to the cache to the index, and evicts values
+// past the size of the cache.
+func (d *Datastore) cached(k ds.Key, size int, dirty bool) {
+	d.lk.Lock()
+	key := k.String()
+	if old, ok := d.sizes[key]; ok {
+		d.size -= int64(old)
+	}
+	d.sizes[key] = size
+	d.size += int64(size)
+	d.policy.add(key, size)
+	if dirty {
+		d.dirty[key] = true
+	}
+	victims := d.evictLocked()
+	d.lk.Unlock()
+
+	d.removeFromCache(victims)
+}
+
+func (d *Datastore) evict() {
+	d.lk.Lock()
+	victims := d.evictLocked()
+	d.lk.Unlock()
+
+	d.removeFromCache(victims)
+}
+
+func (d *Datastore) evictLocked() []ds.Key {
+	var victims []ds.Key
+	for d.size > d.opts.MaxSize {
+		key, ok := d.policy.evict(func(key string) bool { return d.dirty[key] })
+		if !ok {
+			// only values waiting for their upload are left
+			break
+		}
+		d.size -= int64(d.sizes[key])
+		delete(d.sizes, key)
+		d.evictions++
+		victims = append(victims, ds.RawKey(key))
+	}
+	return victims
+}
+
+func (d *Datastore) removeFromCache(keys []ds.Key) {
+	for _, k := range keys {
+		if err := d.cache.Delete(k); err != nil && err != ds.ErrNotFound {
+			log.Warningf("failed to evict %s from the cache: %s", k, err)
+		}
+	}
+}
+
+// uncache forgets a value missing from the cache.
+func (d *Datastore) uncache(k ds.Key) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	key := k.String()
+	if size, ok := d.sizes[key]; ok {
+		d.size -= int64(size)
+		delete(d.sizes, key)
+	}
+	d.policy.remove(key)
+	delete(d.dirty, key)
+}
+
+func (d *Datastore) isCached(k ds.Key) (int, bool) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	key := k.String()
+	size, ok := d.sizes[key]
+	if ok {
+		d.policy.hit(key)
+	}
+	return size, ok
+}
+
+// Put writes the value to the backing datastore and the cache, in the
+// order of the write mode.
+func (d *Datastore) Put(k ds.Key, value []byte) error {
+	if d.opts.WriteMode == WriteBack {
+		if err := d.cache.Put(k, value); err != nil {
+			return err
+		}
+		d.cached(k, len(value), true)
+		d.triggerFlush()
+		return nil
+	}
+
+	if err := d.backing.Put(k, value); err != nil {
+		return err
+	}
+	if err := d.cache.Put(k, value); err != nil {
+		// the value is safe in the backing datastore
+		log.Warningf("failed to cache %s: %s", k, err)
+		return nil
+	}
+	d.cached(k, len(value), false)
+	return nil
+}
+
+// Get returns the value from the cache, or reads it from the backing
+// datastore and caches it.
+func (d *Datastore) Get(k ds.Key) ([]byte, error) {
+	if _, ok := d.isCached(k); ok {
+		v, err := d.cache.Get(k)
+		if err == nil {
+			atomic.AddUint64(&d.hits, 1)
+			return v, nil
+		}
+		if err != ds.ErrNotFound {
+			return nil, err
+		}
+		// evicted concurrently
+		d.uncache(k)
+	}
+
+	atomic.AddUint64(&d.misses, 1)
+	v, err := d.backing.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.cache.Put(k, v); err != nil {
+		log.Warningf("failed to cache %s: %s", k, err)
+		return v, nil
+	}
+	d.cached(k, len(v), false)
+	return v, nil
+}
+
+// Has returns whether the cache or the backing datastore has the key.
+func (d *Datastore) Has(k ds.Key) (bool, error) {
+	if _, ok := d.isCached(k); ok {
+		return true, nil
+	}
+	return d.backing.Has(k)
+}
+
+// GetSize returns the size of the value of key.
+func (d *Datastore) GetSize(k ds.Key) (int, error) {
+	if size, ok := d.isCached(k); ok {
+		return size, nil
+	}
+	return d.backing.GetSize(k)
+}
+
+// Delete removes the value from both datastores.
+func (d *Datastore) Delete(k ds.Key) error {
+	d.uncache(k)
+	if err := d.cache.Delete(k); err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	err := d.backing.Delete(k)
+	if err == ds.ErrNotFound {
+		// it may have been written back and not uploaded
+		return nil
+	}
+	return err
+}
+
+// Query queries the backing datastore. In write-back mode, the values not
+// uploaded yet are returned after the others.
+func (d *Datastore) Query(q dsq.Query) (dsq.Results, error) {
+	d.lk.Lock()
+	var pending []string
+	for key := range d.dirty {
+		pending = append(pending, key)
+	}
+	d.lk.Unlock()
+
+	// the pending values are queried separately
+	backingQ := q
+	backingQ.Limit, backingQ.Offset = 0, 0
+	res, err := d.backing.Query(backingQ)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 && q.Limit == 0 && q.Offset == 0 {
+		return res, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range pending {
+		seen[key] = false
+	}
+	next := func() (dsq.Result, bool) {
+		r, ok := res.NextSync()
+		if ok {
+			if _, isPending := seen[r.Key]; isPending {
+				seen[r.Key] = true
+			}
+			return r, true
+		}
+		for len(pending) > 0 {
+			key := pending[0]
+			pending = pending[1:]
+			if seen[key] {
+				continue
+			}
+			e := dsq.Entry{Key: key}
+			if !q.KeysOnly {
+				v, err := d.cache.Get(ds.RawKey(key))
+				if err == ds.ErrNotFound {
+					// uploaded and evicted meanwhile
+					v, err = d.backing.Get(ds.RawKey(key))
+				}
+				if err == ds.ErrNotFound {
+					// deleted meanwhile
+					continue
+				}
+				if err != nil {
+					return dsq.Result{Error: err}, true
+				}
+				e.Value = v
+			}
+			return dsq.Result{Entry: e}, true
+		}
+		return dsq.Result{}, false
+	}
+
+	qr := dsq.ResultsFromIterator(q, dsq.Iterator{
+		Next:  next,
+		Close: res.Close,
+	})
+	return dsq.NaiveQueryApply(q, qr), nil
+}
+
+// Batch returns a batch writing to the backing datastore in a batch too.
+func (d *Datastore) Batch() (ds.Batch, error) {
+	return &batch{d: d, puts: make(map[ds.Key][]byte), deletes: make(map[ds.Key]bool)}, nil
+}
+
+type batch struct {
+	d       *Datastore
+	puts    map[ds.Key][]byte
+	deletes map[ds.Key]bool
+}
+
+func (b *batch) Put(k ds.Key, value []byte) error {
+	delete(b.deletes, k)
+	b.puts[k] = value
+	return nil
+}
+
+func (b *batch) Delete(k ds.Key) error {
+	delete(b.puts, k)
+	b.deletes[k] = true
+	return nil
+}
+
+func (b *batch) Commit() error {
+	d := b.d
+	for k := range b.deletes {
+		if err := d.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	if d.opts.WriteMode == WriteBack {
+		for k, v := range b.puts {
+			if err := d.Put(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	bb, err := d.backing.Batch()
+	if err != nil {
+		return err
+	}
+	for k, v := range b.puts {
+		if err := bb.Put(k, v); err != nil {
+			return err
+		}
+	}
+	if err := bb.Commit(); err != nil {
+		return err
+	}
+	for k, v := range b.puts {
+		if err := d.cache.Put(k, v); err != nil {
+			log.Warningf("failed to cache %s: %s", k, err)
+			continue
+		}
+		d.cached(k, len(v), false)
+	}
+	return nil
+}
+
+func (d *Datastore) triggerFlush() {
+	select {
+	case d.flush <- struct{}{}:
+	default:
+	}
+}
+
+// flushWorker uploads the written back values. It first looks for values
+// of the cache left dirty by the previous run.
+func (d *Datastore) flushWorker() {
+	defer close(d.flushDone)
+
+	d.recoverDirty()
+	for {
+		var retry <-chan time.Time
+		if !d.flushPending() {
+			retry = time.After(flushRetryDelay)
+		}
+		select {
+		case <-d.flush:
+		case <-retry:
+		case <-d.closing:
+			d.flushPending()
+			return
+		}
+	}
+}
+
+func (d *Datastore) recoverDirty() {
+	d.lk.Lock()
+	keys := make([]string, 0, len(d.sizes))
+	for key := range d.sizes {
+		keys = append(keys, key)
+	}
+	d.lk.Unlock()
+
+	for _, key := range keys {
+		has, err := d.backing.Has(ds.RawKey(key))
+		if err != nil {
+			log.Warningf("failed to check %s in the backing datastore: %s", key, err)
+			has = false
+		}
+		if !has {
+			d.lk.Lock()
+			if _, ok := d.sizes[key]; ok {
+				d.dirty[key] = true
+			}
+			d.lk.Unlock()
+		}
+	}
+}
+
+// flushPending uploads the dirty values, and returns false if one failed.
+func (d *Datastore) flushPending() bool {
+	d.lk.Lock()
+	keys := make([]string, 0, len(d.dirty))
+	for key := range d.dirty {
+		keys = append(keys, key)
+	}
+	d.lk.Unlock()
+
+	ok := true
+	for _, key := range keys {
+		k := ds.RawKey(key)
+		v, err := d.cache.Get(k)
+		if err == ds.ErrNotFound {
+			// deleted meanwhile
+			continue
+		}
+		if err == nil {
+			err = d.backing.Put(k, v)
+		}
+		if err != nil {
+			log.Warningf("failed to upload %s: %s", k, err)
+			ok = false
+			continue
+		}
+
+		d.lk.Lock()
+		delete(d.dirty, key)
+		d.lk.Unlock()
+	}
+	if len(keys) > 0 {
+		// the uploaded values may be evicted now
+		d.evict()
+	}
+	return ok
+}
+
+// Close uploads the written back values, then closes both datastores.
+func (d *Datastore) Close() error {
+	instancesLk.Lock()
+	delete(instances, d)
+	instancesLk.Unlock()
+
+	if d.closing != nil {
+		close(d.closing)
+		<-d.flushDone
+	}
+
+	err := d.cache.Close()
+	if berr := d.backing.Close(); err == nil {
+		err = berr
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package tiered
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	dsq "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/query"
+	dssync "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore/sync"
+)
+
+type mapDatastore struct {
+	*dssync.MutexDatastore
+	gets uint64
+}
+
+func (m *mapDatastore) Get(k ds.Key) ([]byte, error) {
+	atomic.AddUint64(&m.gets, 1)
+	return m.MutexDatastore.Get(k)
+}
+
+func (m *mapDatastore) Close() error {
+	return nil
+}
+
+func newMapDatastore() *mapDatastore {
+	return &mapDatastore{MutexDatastore: dssync.MutexWrap(ds.NewMapDatastore())}
+}
+
+func key(i int) ds.Key {
+	return ds.NewKey(fmt.Sprintf("/CIQ%d", i))
+}
+
+func TestWriteThrough(t *testing.T) {
+	cache, backing := newMapDatastore(), newMapDatastore()
+	d, err := New(cache, backing, Options{MaxSize: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := d.Put(key(i), make([]byte, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if has, _ := backing.Has(key(0)); !has {
+		t.Fatal("expected the value in the backing datastore")
+	}
+	if has, _ := cache.Has(key(0)); has {
+		t.Fatal("expected the least recently used value to be evicted")
+	}
+
+	// read through
+	if _, err := d.Get(key(0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(key(0)); err != nil {
+		t.Fatal(err)
+	}
+	st := d.Stat()
+	if st.Hits != 1 || st.Misses != 1 || st.Size != 30 || st.Entries != 3 || st.Evictions != 3 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if backing.gets != 1 {
+		t.Fatalf("expected one read of the backing datastore, got %d", backing.gets)
+	}
+
+	if err := d.Delete(key(0)); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := d.Has(key(0)); has {
+		t.Fatal("expected the value to be deleted")
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	cache, backing := newMapDatastore(), newMapDatastore()
+	d, err := New(cache, backing, Options{MaxSize: 10, WriteMode: WriteBack})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := d.Put(key(i), make([]byte, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := d.Query(dsq.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %v", entries)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if has, _ := backing.Has(key(i)); !has {
+			t.Fatalf("expected %s to be uploaded on close", key(i))
+		}
+	}
+	if st := d.Stat(); st.Dirty != 0 || st.Size > 10 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestWriteBackRecovery(t *testing.T) {
+	cache, backing := newMapDatastore(), newMapDatastore()
+	// left by a crash before the upload
+	cache.Put(key(0), []byte("value"))
+
+	d, err := New(cache, backing, Options{MaxSize: 1 << 10, WriteMode: WriteBack})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if has, _ := backing.Has(key(0)); has {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the value of the cache to be uploaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestARCScanResistance(t *testing.T) {
+	p := newARC()
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprint("hot", i)
+		p.add(key, 1)
+		p.hit(key)
+	}
+
+	// a scan of cold values evicts the cold values first
+	size := 4
+	for i := 0; i < 20; i++ {
+		p.add(fmt.Sprint("cold", i), 1)
+		size++
+		for size > 8 {
+			p.evict(nil)
+			size--
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if !p.t2.has(fmt.Sprint("hot", i)) {
+			t.Fatalf("expected hot%d to be cached", i)
+		}
+	}
+}
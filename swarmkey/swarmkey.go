@@ -0,0 +1,105 @@
+// Package swarmkey generates and checks the pre-shared keys (swarm.key) of
+// private networks.
+//
+// A key file has the form
+//
+//	/key/swarm/psk/1.0.0/
+//	/base16/
+//	<64 hex characters>
+//
+// where the encoding may also be /base64/ or /bin/.
+package swarmkey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	pnet "gx/ipfs/QmZaQ3K9PRd5sYYoG1xbTGPtd3N7TYiKBRmcBUTsx8HVET/go-libp2p-pnet"
+)
+
+// Header is the first line of a key file.
+const Header = "/key/swarm/psk/1.0.0/"
+
+// KeySize is the size of a decoded key.
+const KeySize = 32
+
+// EnvForce is the environment variable forcing private networks, shared with
+// libp2p.
+const EnvForce = "LIBP2P_FORCE_PNET"
+
+// ErrNoKey is returned when private networks are enforced but no key is set.
+var ErrNoKey = errors.New("private network is enforced but no swarm.key was found; generate one with 'ipfs pnet generate-key'")
+
+// Forced returns whether the environment requires a private network.
+func Forced() bool {
+	return os.Getenv(EnvForce) == "1"
+}
+
+// Generate returns a new random key file.
+func Generate() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return []byte(Header + "\n/base16/\n" + hex.EncodeToString(key) + "\n"), nil
+}
+
+// Validate checks the format of a key file, returning an error that points
+// at the problem.
+func Validate(b []byte) error {
+	lines := strings.SplitN(string(b), "\n", 3)
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	if lines[0] != Header {
+		if strings.TrimSpace(lines[0]) == Header {
+			return errors.New("unexpected whitespace around the key header")
+		}
+		return fmt.Errorf("expected the first line to be %q, got %q", Header, lines[0])
+	}
+	if len(lines) < 3 {
+		return errors.New("missing key encoding or key")
+	}
+
+	var (
+		key []byte
+		err error
+	)
+	switch enc := lines[1]; enc {
+	case "/base16/":
+		key, err = hex.DecodeString(strings.TrimSpace(lines[2]))
+	case "/base64/":
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(lines[2]))
+	case "/bin/":
+		key = []byte(lines[2])
+	default:
+		return fmt.Errorf("unknown key encoding %q, expected /base16/, /base64/ or /bin/", enc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s key: %s", strings.Trim(lines[1], "/"), err)
+	}
+	if len(key) != KeySize {
+		return fmt.Errorf("expected a %d byte key, got %d bytes", KeySize, len(key))
+	}
+	return nil
+}
+
+// Fingerprint returns the fingerprint of a key file. Nodes of the same
+// private network have the same fingerprint.
+func Fingerprint(b []byte) ([]byte, error) {
+	if err := Validate(b); err != nil {
+		return nil, err
+	}
+	protec, err := pnet.NewProtector(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return protec.Fingerprint(), nil
+}
@@ -0,0 +1,46 @@
+package swarmkey
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	k, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(k); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	key := strings.Repeat("ab", KeySize)
+	cases := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{"base16", Header + "\n/base16/\n" + key + "\n", ""},
+		{"crlf", Header + "\r\n/base16/\r\n" + key + "\r\n", ""},
+		{"header", "/key/swarm/psk/2.0.0/\n/base16/\n" + key, "first line"},
+		{"whitespace", " " + Header + "\n/base16/\n" + key, "whitespace"},
+		{"truncated", Header + "\n", "missing"},
+		{"encoding", Header + "\n/base32/\n" + key, "unknown key encoding"},
+		{"hex", Header + "\n/base16/\nzz", "decode"},
+		{"length", Header + "\n/base16/\n" + key[:10], "32 byte key"},
+	}
+
+	for _, c := range cases {
+		err := Validate([]byte(c.file))
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+		case c.err != "" && err == nil:
+			t.Errorf("%s: expected an error", c.name)
+		case c.err != "" && !strings.Contains(err.Error(), c.err):
+			t.Errorf("%s: expected error containing %q, got %q", c.name, c.err, err)
+		}
+	}
+}
@@ -181,6 +181,94 @@ test_expect_success "clean up ipfs dir" '
   rm -rf "$IPFS_PATH"
 '
 
+# test the key algorithms and the imported identities
+test_expect_success "'ipfs init' generates an ed25519 key by default" '
+  ipfs init --empty-repo >actual_init &&
+  grep "generating ed25519 keypair...done" actual_init &&
+  ipfs config Identity.PrivKey >privkey &&
+  ipfs config Identity.PeerID >peerid_expected
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --algorithm=secp256k1' succeeds" '
+  ipfs init --empty-repo --algorithm=secp256k1 >actual_init &&
+  grep "generating secp256k1 keypair...done" actual_init
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init' with invalid key options fails" '
+  test_must_fail ipfs init --algorithm=dsa 2>init_err &&
+  grep "unknown key algorithm" init_err &&
+  test_must_fail ipfs init --algorithm=ed25519 --bits=2048 2>init_err &&
+  grep "only applies to rsa keys" init_err &&
+  test_must_fail ipfs init --import-identity=privkey --algorithm=rsa 2>init_err &&
+  grep "can.t be used with" init_err
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --import-identity' succeeds" '
+  ipfs init --empty-repo --import-identity=privkey --profile=server >actual_init &&
+  grep "importing identity from privkey...done" actual_init
+'
+
+test_expect_success "imported identity looks good" '
+  ipfs config Identity.PeerID >peerid_actual &&
+  test_cmp peerid_expected peerid_actual &&
+  ipfs config Identity.PrivKey >privkey_actual &&
+  test_cmp privkey privkey_actual
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+# test the content added to the new repos
+test_expect_success "'ipfs init --profile=emptyrepo' succeeds" '
+  ipfs init --bits=1024 --profile=emptyrepo >actual_init
+'
+
+test_expect_success "'ipfs init --profile=emptyrepo' adds no docs" '
+  test_must_fail grep "to get started" actual_init &&
+  test_must_fail ipfs cat /ipfs/$HASH_WELCOME_DOCS/readme
+'
+
+test_expect_success "create a CAR file to seed with" '
+  mkdir welcome &&
+  echo "welcome to the product" >welcome/readme &&
+  SEED_HASH=$(ipfs add -r -Q welcome) &&
+  ipfs get --archive-format=car -o welcome.car "$SEED_HASH" &&
+  ipfs config show >seed_config &&
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init' with Init.SeedCAR succeeds" '
+  sed "\$d" seed_config >seed_config2 &&
+  printf ",\n  \"Init\": {\"SeedCAR\": \"%s\"}\n}\n" "$(pwd)/welcome.car" >>seed_config2 &&
+  ipfs init seed_config2 >actual_init &&
+  grep "seeded the repo with $SEED_HASH" actual_init
+'
+
+test_expect_success "the seeded content is pinned, without the docs" '
+  ipfs pin ls --type=recursive >pins &&
+  grep "$SEED_HASH" pins &&
+  ipfs cat "$SEED_HASH/readme" >readme_actual &&
+  test_cmp welcome/readme readme_actual &&
+  test_must_fail ipfs cat /ipfs/$HASH_WELCOME_DOCS/readme
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
 test_init_ipfs
 
 test_launch_ipfs_daemon
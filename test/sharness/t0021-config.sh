@@ -224,6 +224,37 @@ test_config_cmd() {
   # without converting first
   # test_profile_apply_revert badgerds
 
+  test_expect_success "'ipfs config profile apply --dry-run' prints the changes" '
+    ipfs config show >expected &&
+    cp expected before_profiles &&
+    ipfs config profile apply --dry-run lowpower >dry_run &&
+    grep "^Routing.Type: \"[a-z]*\" => \"dhtclient\"$" dry_run &&
+    grep "^Swarm.ConnMgr.HighWater: [0-9]* => 40$" dry_run
+  '
+
+  test_expect_success "'ipfs config profile apply --dry-run' leaves the config as is" '
+    ipfs config show >actual &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs config profile apply' prints the same changes" '
+    ipfs config profile apply lowpower >applied &&
+    test_cmp dry_run applied &&
+    echo dhtclient >expected &&
+    ipfs config Routing.Type >actual &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs config profile apply randomports' changes the ports" '
+    ipfs config profile apply randomports &&
+    ipfs config Addresses.API >actual &&
+    test_must_fail grep "/tcp/0$" actual
+  '
+
+  test_expect_success "restore the config" '
+    ipfs config replace before_profiles
+  '
+
   test_expect_success "cleanup config backups" '
     find "$IPFS_PATH" -name "config-*" -exec rm {} \;
   '
@@ -37,4 +37,57 @@ test_expect_success "daemon no longer running" '
     ! kill -0 $IPFS_PID 2>/dev/null && return
   done
 '
+
+test_launch_ipfs_daemon
+
+test_expect_success "start an add in flight" '
+  (echo "first line"; go-sleep 2s; echo "second line") | ipfs add -Q >add_out &
+  ADD_PID=$! &&
+  go-sleep 500ms
+'
+
+test_expect_success "shutdown --drain succeeds" '
+  ipfs shutdown --drain --timeout 30s >actual &&
+  echo "Draining, shutting down within 30s" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "new requests are refused while draining" '
+  test_must_fail ipfs id 2>id_err &&
+  grep "shutting down" id_err
+'
+
+test_expect_success "the add in flight finishes" '
+  wait $ADD_PID &&
+  HASH=$(cat add_out) &&
+  test -n "$HASH"
+'
+
+test_expect_success "daemon no longer running" '
+  for i in $(test_seq 1 100)
+  do
+    go-sleep 100ms
+    ! kill -0 $IPFS_PID 2>/dev/null && return
+  done
+'
+
+test_expect_success "the added content was kept" '
+  ipfs cat "$HASH" >actual &&
+  printf "first line\nsecond line\n" >expected &&
+  test_cmp expected actual
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "SIGTERM drains the daemon" '
+  kill -TERM $IPFS_PID &&
+  for i in $(test_seq 1 100)
+  do
+    go-sleep 100ms
+    ! kill -0 $IPFS_PID 2>/dev/null && break
+  done &&
+  ! kill -0 $IPFS_PID 2>/dev/null &&
+  grep "Received SIGTERM, draining" actual_daemon
+'
+
 test_done
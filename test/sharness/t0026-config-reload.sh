@@ -0,0 +1,64 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs config reload"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "ipfs config reload fails without a daemon" '
+  test_must_fail ipfs config reload 2>reload_err &&
+  grep "daemon not running" reload_err
+'
+
+test_expect_success "add the test content" '
+  HASH=$(echo "reloaded" | ipfs add -Q)
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the gateway doesn't send the header yet" '
+  curl -sI "http://127.0.0.1:$GWAY_PORT/ipfs/$HASH" >headers &&
+  test_must_fail grep "^X-Reload-Test" headers
+'
+
+test_expect_success "change the reloadable sections" '
+  ipfs config --json Gateway.HTTPHeaders.X-Reload-Test "[\"yes\"]" &&
+  ipfs config --json Logging.Levels "{\"*\": \"error\", \"bitswap\": \"debug\"}" &&
+  ipfs config --json Swarm.ConnMgr.LowWater 10
+'
+
+test_expect_success "ipfs config reload applies them" '
+  ipfs config reload >actual &&
+  grep "^Gateway: reloaded$" actual &&
+  grep "^Logging.Levels: reloaded$" actual &&
+  grep "^Swarm.ConnMgr: reloaded$" actual &&
+  grep "^Peering: reloaded$" actual &&
+  grep "^Denylist: reloaded$" actual
+'
+
+test_expect_success "the gateway sends the header" '
+  curl -sI "http://127.0.0.1:$GWAY_PORT/ipfs/$HASH" >headers &&
+  grep "^X-Reload-Test: yes" headers
+'
+
+test_expect_success "changing the connection manager type requires a restart" '
+  ipfs config Swarm.ConnMgr.Type none &&
+  ipfs config reload >actual &&
+  grep "^Swarm.ConnMgr: failed: .*requires a restart$" actual &&
+  grep "^Gateway: reloaded$" actual
+'
+
+test_expect_success "an invalid log level is reported" '
+  ipfs config Swarm.ConnMgr.Type basic &&
+  ipfs config --json Logging.Levels "{\"bitswap\": \"loud\"}" &&
+  ipfs config reload >actual &&
+  grep "^Logging.Levels: failed: " actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,71 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the config overrides of the daemon"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "set the config values" '
+  ipfs config --json Swarm.ConnMgr.HighWater 900 &&
+  ipfs config --json Swarm.ConnMgr.LowWater 600 &&
+  ipfs config Routing.Type dht &&
+  cp "$IPFS_PATH/config" config_before
+'
+
+test_expect_success "export an override" '
+  export IPFS_CONFIG_Swarm_ConnMgr_HighWater=200
+'
+
+test_launch_ipfs_daemon --config-override=Routing.Type=dhtclient;Swarm.ConnMgr.LowWater=100
+
+test_expect_success "unset the override" '
+  unset IPFS_CONFIG_Swarm_ConnMgr_HighWater
+'
+
+test_expect_success "the daemon reports the overrides" '
+  grep "Overriding config keys: Routing.Type, Swarm.ConnMgr.HighWater, Swarm.ConnMgr.LowWater" actual_daemon
+'
+
+test_expect_success "the daemon sees the overridden values" '
+  echo 200 >expected &&
+  ipfs config Swarm.ConnMgr.HighWater >actual &&
+  test_cmp expected actual &&
+  echo 100 >expected &&
+  ipfs config Swarm.ConnMgr.LowWater >actual &&
+  test_cmp expected actual &&
+  echo dhtclient >expected &&
+  ipfs config Routing.Type >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "the config file is left as is" '
+  test_cmp config_before "$IPFS_PATH/config"
+'
+
+test_expect_success "changing the config doesn't write the overrides" '
+  ipfs config --json Swarm.ConnMgr.GracePeriod "\"30s\"" &&
+  ipfs config profile apply server >/dev/null &&
+  grep "\"HighWater\": 900" "$IPFS_PATH/config" &&
+  grep "\"LowWater\": 600" "$IPFS_PATH/config" &&
+  grep "\"GracePeriod\": \"30s\"" "$IPFS_PATH/config" &&
+  grep "\"Type\": \"dht\"" "$IPFS_PATH/config"
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "the overrides don't outlive the daemon" '
+  echo 900 >expected &&
+  ipfs config Swarm.ConnMgr.HighWater >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "an invalid override fails the daemon" '
+  test_expect_code 1 ipfs daemon --config-override=Routing >actual_err 2>&1 &&
+  grep "invalid config override" actual_err
+'
+
+test_done
@@ -66,6 +66,53 @@ test_expect_success "mount directories can be removed after shutdown" '
   rmdir ipfs ipns
 '
 
+test_expect_success FUSE "'ipfs mount' mounts the files root with --mfs-path" '
+  mkdir "$(pwd)/ipfs" "$(pwd)/ipns" "$(pwd)/mfs" &&
+  ipfsi 0 mount -f "$(pwd)/ipfs" -n "$(pwd)/ipns" -m "$(pwd)/mfs" >actual
+'
+
+test_expect_success FUSE "'ipfs mount' output looks good" '
+  echo "IPFS mounted at: $(pwd)/ipfs" >expected &&
+  echo "IPNS mounted at: $(pwd)/ipns" >>expected &&
+  echo "MFS mounted at: $(pwd)/mfs" >>expected &&
+  test_cmp expected actual
+'
+
+test_expect_success FUSE "files copied to the mfs mount are added to the files root" '
+  mkdir -p src/sub &&
+  echo "hello mfs" >src/a &&
+  random 100000 42 >src/sub/b &&
+  cp -r src "$(pwd)/mfs/copied" &&
+  ipfsi 0 files read /copied/a >actual &&
+  test_cmp src/a actual &&
+  ipfsi 0 files read /copied/sub/b >actual &&
+  test_cmp src/sub/b actual
+'
+
+test_expect_success FUSE "files can be overwritten, appended and renamed on the mfs mount" '
+  echo "bye" >"$(pwd)/mfs/copied/a" &&
+  echo "again" >>"$(pwd)/mfs/copied/a" &&
+  mv "$(pwd)/mfs/copied/a" "$(pwd)/mfs/copied/c" &&
+  printf "bye\nagain\n" >expected &&
+  ipfsi 0 files read /copied/c >actual &&
+  test_cmp expected actual &&
+  test_must_fail ipfsi 0 files stat /copied/a
+'
+
+test_expect_success FUSE "changes made with 'ipfs files' show on the mfs mount" '
+  ipfsi 0 files mkdir /made &&
+  test -d "$(pwd)/mfs/made" &&
+  rmdir "$(pwd)/mfs/made" &&
+  test_must_fail ipfsi 0 files stat /made
+'
+
+test_expect_success FUSE "unmount directories" '
+  do_umount "$(pwd)/ipfs" &&
+  do_umount "$(pwd)/ipns" &&
+  do_umount "$(pwd)/mfs" &&
+  rmdir ipfs ipns mfs
+'
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
@@ -33,6 +33,24 @@ test_expect_success "test ping 0" '
   ! ipfsi 1 ping -n0 -- "$PEERID_0"
 '
 
+test_expect_success "test ping statistics" '
+  ipfsi 0 ping -n2 --interval=100ms -- "$PEERID_1" >ping_out &&
+  grep "2 pings sent, 2 received, 0% packet loss" ping_out &&
+  grep "Latency min/avg/max/stddev" ping_out
+'
+
+test_expect_success "test ping multiaddr" '
+  ADDR_1=$(ipfsi 1 swarm addrs local | head -1) &&
+  ipfsi 0 ping -n2 --interval=100ms -- "$ADDR_1/ipfs/$PEERID_1" >ping_out &&
+  grep "2 pings sent, 2 received" ping_out &&
+  ipfsi 0 swarm peers | grep "$ADDR_1/ipfs/$PEERID_1"
+'
+
+test_expect_success "test ping infinite" '
+  go-timeout 2 ipfsi 0 ping --infinite --interval=100ms -- "$PEERID_1" >ping_out
+  grep "Pong received" ping_out
+'
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
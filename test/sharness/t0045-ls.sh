@@ -62,6 +62,16 @@ EOF
     test_cmp expected_ls actual_ls
   '
 
+  test_expect_success "'ipfs ls --stream <three dir hashes>' succeeds" '
+    ipfs ls --stream QmfNy183bXiRVyrhyWtq3TwHn79yHEkiAGFr18P7YNzESj QmR3jhV4XpxxPjPT3Y8vNnWvWNvakdcT3H6vqpRBsX1MLy QmSix55yz8CzWXf5ZVM9vgEvijnEeeXiTSarVtsqiiCJss >actual_ls_stream
+  '
+
+  test_expect_success "'ipfs ls --stream <three dir hashes>' lists the same links" '
+    tr -s " " <expected_ls >expected_ls_stream &&
+    tr -s " " <actual_ls_stream >actual_ls_stream_squeezed &&
+    test_cmp expected_ls_stream actual_ls_stream_squeezed
+  '
+
   test_expect_success "'ipfs ls --headers <three dir hashes>' succeeds" '
     ipfs ls --headers QmfNy183bXiRVyrhyWtq3TwHn79yHEkiAGFr18P7YNzESj QmR3jhV4XpxxPjPT3Y8vNnWvWNvakdcT3H6vqpRBsX1MLy QmSix55yz8CzWXf5ZVM9vgEvijnEeeXiTSarVtsqiiCJss >actual_ls_headers
   '
@@ -177,6 +187,10 @@ test_expect_success "'ipfs ls --resolve-type=false' ok and does not hang" '
   go-timeout 2 ipfs ls --resolve-type=false $DIR
 '
 
+test_expect_success "'ipfs ls --stream --resolve-type=false' ok and does not hang" '
+  go-timeout 2 ipfs ls --stream --resolve-type=false $DIR
+'
+
 test_kill_ipfs_daemon
 
 test_done
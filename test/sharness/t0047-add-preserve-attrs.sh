@@ -0,0 +1,75 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs add --preserve-mode --preserve-mtime"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+export TZ=UTC
+
+test_expect_success "create files with attributes" '
+  mkdir -p attrs/sub &&
+  echo "some content" > attrs/sub/file &&
+  chmod 0640 attrs/sub/file &&
+  chmod 0750 attrs/sub &&
+  touch -t 201501020304.05 attrs/sub/file &&
+  touch -t 201601020304.05 attrs/sub
+'
+
+test_expect_success "'ipfs add' without the options doesn't store the attributes" '
+  PLAIN=$(ipfs add -Q attrs/sub/file) &&
+  ipfs files stat /ipfs/$PLAIN > plain_stat &&
+  test_must_fail grep "^Mode:" plain_stat &&
+  test_must_fail grep "^Mtime:" plain_stat
+'
+
+test_expect_success "'ipfs add --preserve-mode --preserve-mtime' succeeds" '
+  ROOT=$(ipfs add -Q -r --preserve-mode --preserve-mtime attrs) &&
+  FILE=$(ipfs add -Q --preserve-mode --preserve-mtime attrs/sub/file)
+'
+
+test_expect_success "the nodes differ from the ones without attributes" '
+  test "$FILE" != "$PLAIN"
+'
+
+test_expect_success "'ipfs files stat' shows the attributes of a file" '
+  ipfs files stat /ipfs/$ROOT/sub/file > file_stat &&
+  grep "^Mode: 0640$" file_stat &&
+  grep "^Mtime: 2015-01-02T03:04:05Z$" file_stat
+'
+
+test_expect_success "'ipfs files stat' shows the attributes of a directory" '
+  ipfs files stat /ipfs/$ROOT/sub > dir_stat &&
+  grep "^Mode: 0750$" dir_stat &&
+  grep "^Mtime: 2016-01-02T03:04:05Z$" dir_stat
+'
+
+test_expect_success "'ipfs get' restores the attributes" '
+  ipfs get -o restored $ROOT &&
+  test "$(generic_stat restored/sub/file)" = "$(generic_stat attrs/sub/file)" &&
+  test "$(generic_stat restored/sub)" = "$(generic_stat attrs/sub)" &&
+  test ! restored/sub/file -nt attrs/sub/file &&
+  test ! attrs/sub/file -nt restored/sub/file &&
+  test ! restored/sub -nt attrs/sub &&
+  test ! attrs/sub -nt restored/sub
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs add' sends the attributes to the daemon" '
+  ROOT_DAEMON=$(ipfs add -Q -r --preserve-mode --preserve-mtime attrs) &&
+  test "$ROOT_DAEMON" = "$ROOT"
+'
+
+test_expect_success "the attributes aren't added as files" '
+  ipfs ls $ROOT_DAEMON/sub > ls_out &&
+  test_must_fail grep ".ipfs-attrs" ls_out
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,56 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs add --to-files"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_add_to_files() {
+  test_expect_success "create some files" '
+    mkdir -p tofiles/dir &&
+    echo "file to add" > tofiles/file &&
+    echo "nested file" > tofiles/dir/nested
+  '
+
+  test_expect_success "'ipfs add --to-files' puts a file at the path" '
+    HASH=$(ipfs add -Q --to-files=/a/b/added tofiles/file) &&
+    test "$(ipfs files stat --hash /a/b/added)" = "$HASH"
+  '
+
+  test_expect_success "'ipfs add --to-files' with a trailing slash keeps the name" '
+    HASH=$(ipfs add -Q --to-files=/a/b/ tofiles/file) &&
+    test "$(ipfs files stat --hash /a/b/file)" = "$HASH"
+  '
+
+  test_expect_success "'ipfs add -r --to-files' puts a directory at the path" '
+    HASH=$(ipfs add -Q -r --to-files=/c/ tofiles) &&
+    test "$(ipfs files stat --hash /c/tofiles)" = "$HASH" &&
+    ipfs files read /c/tofiles/dir/nested > nested_out &&
+    test_cmp tofiles/dir/nested nested_out
+  '
+
+  test_expect_success "'ipfs add --to-files' fails on an existing path" '
+    test_must_fail ipfs add -Q --to-files=/a/b/added tofiles/file
+  '
+
+  test_expect_success "'ipfs add --to-files' fails with --only-hash" '
+    test_must_fail ipfs add -Q -n --to-files=/d tofiles/file
+  '
+
+  test_expect_success "clean up the files API" '
+    ipfs files rm -r /a &&
+    ipfs files rm -r /c
+  '
+}
+
+test_add_to_files
+
+test_launch_ipfs_daemon
+test_add_to_files
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,56 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs add -r with ignore rules"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a tree with files to ignore" '
+  mkdir -p tree/src/build tree/logs clean/src &&
+  echo "main" > tree/src/main.go &&
+  echo "main" > clean/src/main.go &&
+  echo "object" > tree/src/build/main.o &&
+  echo "log" > tree/logs/debug.log &&
+  echo "readme" > tree/README &&
+  echo "readme" > clean/README &&
+  echo "tmp" > tree/src/scratch.tmp &&
+  echo "*.tmp" > tree/src/.ipfsignore &&
+  printf "# rules\nbuild/\n" > rules
+'
+
+test_add_ignore() {
+  test_expect_success "'ipfs add -r' honors the rules and the .ipfsignore files" '
+    EXPECTED=$(ipfs add -r -Q -n clean) &&
+    ipfs add -r -Q --ignore-rules-path=rules --ignore=logs tree > actual &&
+    echo "$EXPECTED" > expected &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs add -r' keeps the files without the rules" '
+    ipfs add -r -n tree > added &&
+    grep "tree/src/build/main.o" added &&
+    grep "tree/logs/debug.log" added &&
+    test_must_fail grep "scratch.tmp" added
+  '
+
+  test_expect_success "negated patterns keep files" '
+    ipfs add -r -n --ignore="*.log,!debug.log" tree > added &&
+    grep "tree/logs/debug.log" added
+  '
+
+  test_expect_success "'ipfs add' fails with a missing rules file" '
+    test_must_fail ipfs add -r --ignore-rules-path=missing tree
+  '
+}
+
+test_add_ignore
+
+test_launch_ipfs_daemon
+test_add_ignore
+test_kill_ipfs_daemon
+
+test_done
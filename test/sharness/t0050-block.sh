@@ -169,6 +169,59 @@ test_expect_success "multi-block 'ipfs block rm -q' produces no output" '
   test ! -s block_rm_out
 '
 
+test_expect_success "'add some blocks' succeeds" '
+  echo "Hello Mars!" | ipfs block put &&
+  echo "Hello Venus!" | ipfs block put
+'
+
+test_expect_success "'ipfs block rm' reads the hashes from stdin" '
+  printf "%s\n" $HASH $HASH2 | ipfs block rm > block_rm_out &&
+  grep -F -q "removed $HASH" block_rm_out &&
+  grep -F -q "removed $HASH2" block_rm_out
+'
+
+test_expect_success "blocks read from stdin removed" '
+  test_must_fail ipfs block stat $HASH &&
+  test_must_fail ipfs block stat $HASH2
+'
+
+test_expect_success "'ipfs block rm' removes more hashes than a batch from stdin" '
+  mkdir many &&
+  for i in $(seq 1100); do echo "block $i" > many/$i || return 1; done &&
+  ipfs block put many/* > many_blocks &&
+  test_line_count = 1100 many_blocks &&
+  ipfs block rm -q < many_blocks > block_rm_out &&
+  test ! -s block_rm_out &&
+  for b in $(head -n 1 many_blocks) $(tail -n 1 many_blocks); do
+    test_must_fail ipfs block stat $b || return 1
+  done
+'
+
+test_expect_success "add directly pinned block" '
+  HASH=$(echo "Hello Mars!" | ipfs block put) &&
+  ipfs pin add -r=false $HASH
+'
+
+test_expect_success "can't remove directly pinned block" '
+  test_must_fail ipfs block rm $HASH 2> block_rm_err &&
+  grep -q "$HASH: pinned: direct" block_rm_err &&
+  ipfs block stat $HASH
+'
+
+test_expect_success "'ipfs block rm --unpin' removes directly pinned block" '
+  ipfs block rm --unpin $HASH > block_rm_out &&
+  grep -F -q "removed $HASH" block_rm_out &&
+  test_must_fail ipfs block stat $HASH &&
+  ipfs pin ls --type=direct > pin_ls_out &&
+  test_must_fail grep -q $HASH pin_ls_out
+'
+
+test_expect_success "'ipfs block rm --unpin' keeps indirectly pinned block" '
+  test_must_fail ipfs block rm --unpin $FILE1HASH 2> block_rm_err &&
+  grep -q "$FILE1HASH: pinned via $DIRHASH" block_rm_err &&
+  ipfs block stat $FILE1HASH
+'
+
 test_expect_success "can set cid format on block put" '
   HASH=$(ipfs block put --format=protobuf ../t0051-object-data/testPut.pb)
 '
@@ -221,4 +274,33 @@ test_expect_success "put with sha3 and cidv0 fails" '
   echo "foooo" | test_must_fail ipfs block put --mhtype=sha3 --mhlen=20 --format=v0
 '
 
+test_expect_success "block put stores several files" '
+  echo "first" > blk_a &&
+  echo "second" > blk_b &&
+  ipfs block put blk_a blk_b > blk_multi_out &&
+  echo "first" | ipfs block put > blk_multi_exp &&
+  echo "second" | ipfs block put >> blk_multi_exp &&
+  test_cmp blk_multi_exp blk_multi_out
+'
+
+test_expect_success "block put --stream reads length-prefixed blocks" '
+  printf "\005hello\005world" > blk_stream &&
+  ipfs block put --stream --cid-codec=raw blk_stream > blk_stream_out &&
+  test_line_count = 2 blk_stream_out &&
+  ipfs block get $(head -n1 blk_stream_out) > blk_stream_get &&
+  printf "hello" > blk_stream_exp &&
+  test_cmp blk_stream_exp blk_stream_get &&
+  printf "world" | ipfs block put --cid-codec=raw > blk_world &&
+  tail -n1 blk_stream_out | test_cmp blk_world -
+'
+
+test_expect_success "block put --stream fails on truncated blocks" '
+  printf "\005hel" | test_must_fail ipfs block put --stream 2> blk_stream_err &&
+  grep "reading a block of 5 bytes" blk_stream_err
+'
+
+test_expect_success "block put --cid-codec and --format are exclusive" '
+  echo "foooo" | test_must_fail ipfs block put --cid-codec=raw --format=raw
+'
+
 test_done
@@ -206,6 +206,80 @@ test_dag_cmd() {
     test_cmp resolve_obj_exp resolve_obj &&
     test_cmp resolve_data_exp resolve_data
   '
+
+  test_expect_success "prepare dags to diff" '
+    LEAF_A=$(echo "{\"data\":1}" | ipfs dag put) &&
+    LEAF_B=$(echo "{\"data\":2}" | ipfs dag put) &&
+    DIFF_A=$(echo "{\"name\":\"a\",\"old\":true,\"obj\":{\"/\":\"${LEAF_A}\"}}" | ipfs dag put) &&
+    DIFF_B=$(echo "{\"name\":\"b\",\"new\":true,\"obj\":{\"/\":\"${LEAF_B}\"}}" | ipfs dag put)
+  '
+
+  test_expect_success "dag diff follows the links changed" '
+    ipfs dag diff $DIFF_A $DIFF_B > diff_out &&
+    printf "Changed /name\nAdded /new\nChanged /obj/data\nRemoved /old\n" > diff_exp &&
+    test_cmp diff_exp diff_out
+  '
+
+  test_expect_success "dag diff --values prints the values" '
+    ipfs dag diff --values $DIFF_A $DIFF_B > diff_out &&
+    grep "^Changed /obj/data: 1 -> 2$" diff_out &&
+    grep "^Changed /name: a -> b$" diff_out
+  '
+
+  test_expect_success "dag diff of the same dag is empty" '
+    ipfs dag diff $DIFF_A $DIFF_A > diff_out &&
+    test_must_be_empty diff_out
+  '
+
+  test_expect_success "dag diff works on dag-pb" '
+    rm -rf diffdir && mkdir diffdir &&
+    echo "one" > diffdir/file &&
+    PB_A=$(ipfs add -r -Q diffdir) &&
+    echo "two" > diffdir/file &&
+    echo "new" > diffdir/added &&
+    PB_B=$(ipfs add -r -Q diffdir) &&
+    ipfs dag diff $PB_A $PB_B > diff_out &&
+    printf "Added /added\nChanged /file\n" > diff_exp &&
+    test_cmp diff_exp diff_out
+  '
+
+  test_expect_success "dag put --input-codec=dag-json stores bytes and links" '
+    printf "{\"bytes\":{\"/\":{\"bytes\":\"aGVsbG8\"}},\"link\":{\"/\":\"%s\"},\"num\":-3}" $LEAF_A > dagjson_in &&
+    JSON_CBOR=$(ipfs dag put --input-codec=dag-json --store-codec=dag-cbor < dagjson_in) &&
+    JSON_JSON=$(ipfs dag put --input-codec=dag-json --store-codec=dag-json < dagjson_in)
+  '
+
+  test_expect_success "dag get --output-codec=dag-json round-trips" '
+    ipfs dag get --output-codec=dag-json $JSON_CBOR > dagjson_out &&
+    test_cmp dagjson_in dagjson_out &&
+    ipfs dag get --output-codec=dag-json $JSON_JSON > dagjson_out &&
+    test_cmp dagjson_in dagjson_out
+  '
+
+  test_expect_success "dag-json nodes resolve paths through links" '
+    ipfs dag get --output-codec=dag-json $JSON_JSON/link/data > dagjson_out &&
+    printf 1 > dagjson_exp &&
+    test_cmp dagjson_exp dagjson_out
+  '
+
+  test_expect_success "dag get --output-codec=dag-cbor round-trips" '
+    ipfs dag get --output-codec=dag-cbor $JSON_CBOR > dagcbor_out &&
+    CBOR_AGAIN=$(ipfs dag put --input-codec=dag-cbor --store-codec=dag-cbor < dagcbor_out) &&
+    test "$CBOR_AGAIN" = "$JSON_CBOR" &&
+    ipfs block get $JSON_CBOR > dagcbor_block &&
+    test_cmp dagcbor_block dagcbor_out
+  '
+
+  test_expect_success "dag-pb nodes round-trip through dag-json" '
+    ipfs dag get --output-codec=dag-json $PB_B > dagpb_json &&
+    PB_AGAIN=$(ipfs dag put --input-codec=dag-json --store-codec=dag-pb < dagpb_json) &&
+    test "$PB_AGAIN" = "$PB_B"
+  '
+
+  test_expect_success "dag get rejects unknown output codecs" '
+    test_must_fail ipfs dag get --output-codec=xml $JSON_CBOR 2> codec_err &&
+    grep "unknown output codec" codec_err
+  '
 }
 
 # should work offline
@@ -214,6 +288,27 @@ test_dag_cmd
 # should work online
 test_launch_ipfs_daemon
 test_dag_cmd
+
+test_expect_success "gateway serves dag-json with ?format=" '
+  curl -sf "http://$GWAY_ADDR/ipfs/$JSON_CBOR?format=dag-json" > gw_dagjson &&
+  test_cmp dagjson_in gw_dagjson
+'
+
+test_expect_success "gateway negotiates the codec with the Accept header" '
+  curl -sfD gw_headers -H "Accept: application/vnd.ipld.dag-cbor" "http://$GWAY_ADDR/ipfs/$JSON_JSON" > gw_dagcbor &&
+  grep -i "^Content-Type: application/vnd.ipld.dag-cbor" gw_headers &&
+  test_cmp dagcbor_block gw_dagcbor
+'
+
+test_expect_success "gateway converts dag-pb nodes" '
+  curl -sf "http://$GWAY_ADDR/ipfs/$PB_B?format=dag-json" > gw_dagpb &&
+  test_cmp dagpb_json gw_dagpb
+'
+
+test_expect_success "gateway rejects unknown formats" '
+  test_expect_code 22 curl -sf "http://$GWAY_ADDR/ipfs/$JSON_CBOR?format=xml"
+'
+
 test_kill_ipfs_daemon
 
 test_done
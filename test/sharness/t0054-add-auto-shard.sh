@@ -0,0 +1,59 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs add --auto-shard"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a directory" '
+  mkdir -p dir &&
+  for i in $(seq 20); do echo "$i" > "dir/file$i"; done
+'
+
+test_expect_success "add the directory without sharding" '
+  BASIC=$(ipfs add -r -Q dir)
+'
+
+test_expect_success "small directories are not sharded" '
+  ipfs add -r -Q --auto-shard dir > actual &&
+  echo "$BASIC" > expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "directories above the threshold are sharded" '
+  ipfs config Import.UnixFSHAMTDirectorySizeThreshold 100B &&
+  SHARDED=$(ipfs add -r -Q --auto-shard dir) &&
+  test "$SHARDED" != "$BASIC" &&
+  ipfs ls "$SHARDED" | wc -l | tr -d " " > count &&
+  echo 20 > expected_count &&
+  test_cmp expected_count count
+'
+
+test_expect_success "Import.AutoShard enables it by default" '
+  ipfs config --json Import.AutoShard true &&
+  ipfs add -r -Q dir > actual &&
+  echo "$SHARDED" > expected &&
+  test_cmp expected actual &&
+  ipfs add -r -Q --auto-shard=false dir > actual &&
+  echo "$BASIC" > expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "small directories are unsharded with sharding enabled" '
+  ipfs config Import.UnixFSHAMTDirectorySizeThreshold 1MiB &&
+  ipfs config --json Experimental.ShardingEnabled true &&
+  ipfs add -r -Q dir > actual &&
+  echo "$BASIC" > expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "an invalid threshold is rejected" '
+  ipfs config Import.UnixFSHAMTDirectorySizeThreshold nope &&
+  test_must_fail ipfs add -r -Q dir
+'
+
+test_done
@@ -0,0 +1,44 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs add --workers"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a tree of small files" '
+  mkdir -p tree/a tree/b &&
+  for i in $(seq 50); do
+    echo "a $i" > "tree/a/file$i" &&
+    echo "b $i" > "tree/b/file$i"
+  done &&
+  random 2000000 42 > tree/large
+'
+
+test_add_workers() {
+  test_expect_success "'ipfs add --workers' gives the same output" '
+    ipfs add -r tree > expected &&
+    ipfs add -r --workers=8 tree > actual &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs add --workers' writes all the blocks" '
+    HASH=$(ipfs add -r -Q --workers=8 --pin=false tree) &&
+    ipfs refs -r "$HASH" > /dev/null
+  '
+
+  test_expect_success "'ipfs add --workers' rejects zero" '
+    test_must_fail ipfs add -r --workers=0 tree
+  '
+}
+
+test_add_workers
+
+test_launch_ipfs_daemon
+test_add_workers
+test_kill_ipfs_daemon
+
+test_done
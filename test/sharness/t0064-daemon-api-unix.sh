@@ -0,0 +1,69 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the API served on a unix domain socket"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+SOCK="$(pwd)/sock/api.sock"
+
+test_expect_success "configure a unix socket API address" '
+  ipfs config Addresses.API "/unix$SOCK" &&
+  peerid=$(ipfs config Identity.PeerID)
+'
+
+test_expect_success "'ipfs daemon' succeeds" '
+  ipfs daemon >actual_daemon 2>daemon_err &
+  IPFS_PID=$!
+'
+
+test_expect_success "api file shows up" '
+  test_wait_for_file 50 100ms "$IPFS_PATH/api"
+'
+
+test_expect_success "api file holds the socket address" '
+  echo "/unix$SOCK" >expected &&
+  test_cmp expected "$IPFS_PATH/api"
+'
+
+test_expect_success "daemon output shows the socket" '
+  grep "API server listening on /unix$SOCK" actual_daemon
+'
+
+test_expect_success "the socket is only accessible by its owner" '
+  test -S "$SOCK" &&
+  { stat -c "%a" "$SOCK" 2>/dev/null || stat -f "%Lp" "$SOCK"; } >actual &&
+  echo 600 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "the client finds the daemon through the socket" '
+  printf "$peerid" >expected &&
+  ipfs id -f="<id>" >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "the requests run on the daemon" '
+  HASH=$(echo "over the socket" | ipfs add -Q) &&
+  ipfs cat "$HASH" >actual &&
+  echo "over the socket" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "--api accepts the socket address" '
+  printf "$peerid" >expected &&
+  ipfs --api "/unix$SOCK" id -f="<id>" >actual &&
+  test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "the socket is removed on shutdown" '
+  ! test -e "$SOCK"
+'
+
+test_done
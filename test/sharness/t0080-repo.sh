@@ -104,6 +104,17 @@ test_expect_success "remove direct pin" '
   test_cmp expected6 actual6
 '
 
+test_expect_success "'ipfs repo gc --dry-run --list' lists file" '
+  ipfs repo gc --dry-run --list >actual_dry &&
+  grep "would remove $HASH" actual_dry &&
+  grep "would remove $PATCH_ROOT" actual_dry &&
+  grep "^would remove [0-9]* blocks" actual_dry
+'
+
+test_expect_success "'ipfs repo gc --dry-run' doesnt remove file" '
+  ipfs cat "$HASH" >/dev/null
+'
+
 test_expect_success "'ipfs repo gc' removes file" '
   ipfs repo gc >actual7 &&
   grep "removed $HASH" actual7 &&
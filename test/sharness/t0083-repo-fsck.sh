@@ -169,6 +169,43 @@ test_expect_success "'ipfs repo fsck' confirm file deletion" '
   test ! -e "$IPFS_PATH/api"
 '
 
+##########################
+# Test --deep
+##########################
+
+test_expect_success "add a file with --raw-leaves" '
+  random 1048576 42 > afile &&
+  HASH=`ipfs add --raw-leaves -q afile` &&
+  LEAF=`ipfs refs "$HASH" | head -1`
+'
+
+test_expect_success "'ipfs repo fsck --deep' succeeds on a sane repo" '
+  ipfs repo fsck --deep >fsck_deep_out &&
+  grep "0 problems found" fsck_deep_out
+'
+
+test_expect_success "corrupt a leaf of the file" '
+  LEAFFILE=`find "$IPFS_PATH/blocks" -name "*.data" -size +200k | head -1` &&
+  dd if=/dev/zero of="$LEAFFILE" count=1 bs=100 conv=notrunc
+'
+
+test_expect_success "'ipfs repo fsck --deep' reports the corrupt block" '
+  test_must_fail ipfs repo fsck --deep >fsck_deep_out2 &&
+  grep "^corrupt block" fsck_deep_out2
+'
+
+test_expect_success "'ipfs repo fsck --deep --repair=quarantine' quarantines it" '
+  ipfs repo fsck --deep --repair=quarantine >fsck_deep_out3 &&
+  grep "(quarantine)" fsck_deep_out3 &&
+  grep "^missing-child block" fsck_deep_out3 &&
+  test -n "`ls "$IPFS_PATH/quarantine"`" &&
+  test ! -e "$LEAFFILE"
+'
+
+test_expect_success "'ipfs repo fsck --repair' requires --deep" '
+  test_must_fail ipfs repo fsck --repair=delete
+'
+
 ##########################
 # Test with daemon running
 ########################## 
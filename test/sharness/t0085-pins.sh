@@ -118,8 +118,95 @@ test_pin_progress() {
   '
 }
 
+test_pin_export_import() {
+  test_expect_success "create some pins" '
+    EXP_REC=$(echo "export recursive" | ipfs add -q) &&
+    EXP_DIR=$(echo "export direct" | ipfs add -q --pin=false) &&
+    ipfs pin add -r=false $EXP_DIR
+  '
+
+  test_expect_success "'ipfs pin export' succeeds" '
+    ipfs pin export > pins.json
+  '
+
+  test_expect_success "the manifest holds the pins with their type" '
+    grep -A1 "\"Cid\": \"$EXP_REC\"" pins.json | grep "\"Type\": \"recursive\"" &&
+    grep -A1 "\"Cid\": \"$EXP_DIR\"" pins.json | grep "\"Type\": \"direct\""
+  '
+
+  test_expect_success "'ipfs pin import' restores the removed pins" '
+    ipfs pin rm $EXP_REC &&
+    ipfs pin rm -r=false $EXP_DIR &&
+    ipfs pin import pins.json > import_out &&
+    grep "pinned $EXP_REC recursively" import_out &&
+    grep "pinned $EXP_DIR directly" import_out &&
+    ipfs pin ls --type=recursive | grep $EXP_REC &&
+    ipfs pin ls --type=direct | grep $EXP_DIR
+  '
+
+  test_expect_success "'ipfs pin import' skips the existing pins" '
+    ipfs pin import < pins.json > import_out2 &&
+    grep "skipped $EXP_REC, already pinned" import_out2 &&
+    test_must_fail grep "^pinned" import_out2
+  '
+
+  test_expect_success "'ipfs pin import' rejects an invalid manifest" '
+    echo "{\"Version\": 1, \"Pins\": [{\"Cid\": \"$EXP_REC\", \"Type\": \"indirect\"}]}" > bad.json &&
+    test_must_fail ipfs pin import bad.json
+  '
+}
+
+test_pin_verify() {
+  test_expect_success "pin a file and break it" '
+    random 1048576 42 > vfile &&
+    VHASH=$(ipfs add -q vfile) &&
+    OKHASH=$(echo "verify ok" | ipfs add -q) &&
+    LEAFFILE=$(find "$IPFS_PATH/blocks" -name "*.data" -size +200k | head -1) &&
+    rm "$LEAFFILE"
+  '
+
+  test_expect_success "'ipfs pin verify' reports the broken pin" '
+    ipfs pin verify > verify_out &&
+    grep "^$VHASH broken" verify_out &&
+    test_must_fail grep "^$OKHASH" verify_out
+  '
+
+  test_expect_success "'ipfs pin verify --status-filter=ok' skips the broken pin" '
+    ipfs pin verify --status-filter=ok > verify_ok &&
+    grep "^$OKHASH ok" verify_ok &&
+    test_must_fail grep "^$VHASH" verify_ok
+  '
+
+  test_expect_success "'ipfs pin verify --status-filter=all' writes both" '
+    ipfs pin verify --status-filter=all > verify_all &&
+    grep "^$OKHASH ok" verify_all &&
+    grep "^$VHASH broken" verify_all
+  '
+
+  test_expect_success "'ipfs pin verify --enc=json' lists the missing blocks" '
+    ipfs pin verify --enc=json > verify_json &&
+    grep "\"Cid\":\"$VHASH\"" verify_json | grep "\"Missing\":\[\"" &&
+    test $(grep -c "\"Cid\":" verify_json) -eq 1
+  '
+
+  test_expect_success "'ipfs pin verify' rejects an unknown status filter" '
+    test_must_fail ipfs pin verify --status-filter=maybe 2> err &&
+    grep "invalid status filter" err
+  '
+
+  test_expect_success "'ipfs pin verify --refetch' needs the daemon online" '
+    test_must_fail ipfs pin verify --refetch
+  '
+
+  test_expect_success "unpin the broken file" '
+    ipfs pin rm $VHASH $OKHASH
+  '
+}
+
 test_init_ipfs
 
+test_pin_verify
+
 test_pins
 test_pins --progress
 
@@ -131,6 +218,8 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_export_import
+
 test_launch_ipfs_daemon --offline
 
 test_pins
@@ -144,6 +233,8 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_export_import
+
 test_kill_ipfs_daemon
 
 test_done
@@ -0,0 +1,75 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs repo backup and restore"
+
+. lib/test-lib.sh
+
+type unzip >/dev/null 2>&1 && test_set_prereq UNZIP
+
+test_init_ipfs
+
+test_expect_success "add and pin some content" '
+  echo "backed up" > afile &&
+  HASH=$(ipfs add -q afile) &&
+  echo "direct" > dfile &&
+  DHASH=$(ipfs add -q --pin=false dfile) &&
+  ipfs pin add -r=false "$DHASH" &&
+  ipfs key gen --type=ed25519 backupkey > keyid &&
+  PEERID=$(ipfs config Identity.PeerID)
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs repo backup --blocks' succeeds with the daemon running" '
+  ipfs repo backup --blocks backup.zip > backup_out
+'
+
+test_expect_success "'ipfs repo backup' output looks good" '
+  echo "Wrote backup.zip" > backup_expected &&
+  test_cmp backup_expected backup_out
+'
+
+test_expect_success UNZIP "the backup holds the private keys" '
+  unzip -p backup.zip config | grep PrivKey &&
+  unzip -l backup.zip | grep keystore/backupkey
+'
+
+test_expect_success UNZIP "the backups made through the API don't hold the private keys" '
+  curl -sf -X POST "http://$API_ADDR/api/v0/repo/backup?arg=api.zip" > api.zip &&
+  unzip -p api.zip config > api_config &&
+  test_must_fail grep PrivKey api_config &&
+  unzip -l api.zip > api_files &&
+  test_must_fail grep keystore/ api_files
+'
+
+test_expect_success UNZIP "the backups made through the API can't be restored" '
+  test_must_fail env IPFS_PATH="$(pwd)/fromapi" ipfs repo restore api.zip
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "'ipfs repo restore' fails on an existing repo" '
+  test_must_fail ipfs repo restore backup.zip
+'
+
+test_expect_success "'ipfs repo restore' succeeds on a new repo" '
+  IPFS_PATH="$(pwd)/restored" ipfs repo restore backup.zip > restore_out
+'
+
+test_expect_success "'ipfs repo restore' output looks good" '
+  grep "Restored peer identity: $PEERID" restore_out
+'
+
+test_expect_success "the restored repo has the identity, keys, pins and blocks" '
+  test "$(IPFS_PATH="$(pwd)/restored" ipfs config Identity.PeerID)" = "$PEERID" &&
+  IPFS_PATH="$(pwd)/restored" ipfs key list | grep backupkey &&
+  IPFS_PATH="$(pwd)/restored" ipfs pin ls --type=recursive | grep "$HASH" &&
+  IPFS_PATH="$(pwd)/restored" ipfs pin ls --type=direct | grep "$DHASH" &&
+  IPFS_PATH="$(pwd)/restored" ipfs cat "$HASH" > restored_afile &&
+  test_cmp afile restored_afile
+'
+
+test_done
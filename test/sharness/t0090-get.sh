@@ -112,6 +112,21 @@ test_get_cmd() {
     rm -r "$HASH2"
   '
 
+  test_expect_success "ipfs get --continue skips the files present (directory)" '
+    ipfs get "$HASH2" &&
+    ipfs get --continue "$HASH2" >actual &&
+    grep "Skipped 2 file(s)" actual &&
+    test_cmp dir/b/c "$HASH2"/b/c
+  '
+
+  test_expect_success "ipfs get --continue writes the files of the same size again (directory)" '
+    echo "Hello, Wor1ds!" >"$HASH2"/b/c &&
+    ipfs get --continue "$HASH2" >actual &&
+    grep "Skipped 1 file(s)" actual &&
+    test_cmp dir/b/c "$HASH2"/b/c &&
+    rm -r "$HASH2"
+  '
+
   test_expect_success "ipfs get -a -C succeeds (directory)" '
     ipfs get "$HASH2" -a -C >actual
   '
@@ -128,6 +143,34 @@ test_get_cmd() {
     rm -r "$HASH2"
   '
 
+  test_expect_success "ipfs get --archive-format=car succeeds (directory)" '
+    ipfs get "$HASH2" --archive-format=car >actual &&
+    printf "%s\n" "Saving archive to $HASH2.car" >expected &&
+    test_cmp expected actual &&
+    test -s "$HASH2".car &&
+    rm "$HASH2".car
+  '
+
+  test_expect_success "ipfs get -a --deterministic writes the same archive twice (directory)" '
+    ipfs get "$HASH2" -a --deterministic -o det1.tar &&
+    sleep 1 &&
+    ipfs get "$HASH2" -a --deterministic -o det2.tar &&
+    test_cmp det1.tar det2.tar &&
+    TZ=UTC tar -tvf det1.tar | grep "1970-01-01" &&
+    rm det1.tar det2.tar
+  '
+
+  test_expect_success "ipfs get --archive-format=car writes the same CAR twice (directory)" '
+    ipfs get "$HASH2" --archive-format=car -o det1.car &&
+    ipfs get "$HASH2" --archive-format=car -o det2.car &&
+    test_cmp det1.car det2.car &&
+    rm det1.car det2.car
+  '
+
+  test_expect_success "ipfs get fails with an unknown archive format" '
+    test_must_fail ipfs get "$HASH2" --archive-format=zip
+  '
+
   test_expect_success "ipfs get ../.. should fail" '
     echo "Error: invalid 'ipfs ref' path" >expected &&
     test_must_fail ipfs get ../.. 2>actual &&
@@ -173,4 +173,22 @@ EOF
   test_cmp refsr.txt expected.txt
 '
 
+test_expect_success "ipfs refs --format with depth and link names" '
+  cat <<EOF > expected.txt
+1 $refsroot 1.txt
+1 $refsroot B
+2 QmNkQvpiyAEtbeLviC7kqfifYoK1GXPcsSxTpP1yS3ykLa D
+1 $refsroot C
+2 QmSanP5DpxpqfDdS4yekHY1MqrVge47gtxQcp2e2yZ4UwS 2.txt
+EOF
+  ipfs refs -r --unique --max-depth=2 --format="<depth> <src> <linkname>" $refsroot > refsr.txt &&
+  test_cmp expected.txt refsr.txt
+'
+
+test_expect_success "ipfs refs --enc=json outputs the edges" '
+  ipfs refs -r --enc=json $refsroot > refs.json &&
+  grep "\"Ref\":\"QmSFxnK675wQ9Kc1uqWKyJUaNxvSc2BP5DbXCD3x93oq61\"" refs.json &&
+  grep "\"Src\":\"QmSanP5DpxpqfDdS4yekHY1MqrVge47gtxQcp2e2yZ4UwS\",\"Dst\":\"QmSFxnK675wQ9Kc1uqWKyJUaNxvSc2BP5DbXCD3x93oq61\",\"LinkName\":\"2.txt\",\"Depth\":4" refs.json
+'
+
 test_done
@@ -200,4 +200,41 @@ test_expect_success "GET compact blocks succeeds" '
 
 test_kill_ipfs_daemon
 
+# test the commands exposed by the gateway
+
+test_expect_success "set Gateway.ExposedAPICommands" '
+  ipfs config --json Gateway.ExposedAPICommands "[\"/cat\"]"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "an exposed command succeeds through the gateway" '
+  test_curl_gateway_api "cat?arg=$HASH2/test" &&
+  test_cmp dir/test actual
+'
+
+test_expect_success "the commands not exposed are forbidden" '
+  test_curl_resp_http_code "http://127.0.0.1:$port/api/v0/refs?arg=$HASH2" "HTTP/1.1 403 Forbidden"
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "expose no command" '
+  ipfs config --json Gateway.ExposedAPICommands "[]"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the gateway serves no command" '
+  test_must_fail test_curl_gateway_api "cat?arg=$HASH2/test"
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "exposing a command which isn't read-only fails" '
+  ipfs config --json Gateway.ExposedAPICommands "[\"/pin/add\"]" &&
+  test_must_fail ipfs daemon >daemon_out 2>daemon_err &&
+  grep "is not a read-only command" daemon_err
+'
+
 test_done
@@ -0,0 +1,88 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the denylist of the gateway"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add the test content" '
+  mkdir dir &&
+  echo "denied" > dir/denied &&
+  echo "allowed" > dir/allowed &&
+  DIR=$(ipfs add -r -Q dir) &&
+  DENIED=$(ipfs add -Q dir/denied) &&
+  FROMFILE=$(echo "from file" | ipfs add -Q)
+'
+
+test_expect_success "configure a denylist file" '
+  echo "# denied from the file" > "$IPFS_PATH/denylist.txt" &&
+  echo "$FROMFILE" >> "$IPFS_PATH/denylist.txt" &&
+  ipfs config --json Denylist.Files "[\"denylist.txt\"]"
+'
+
+test_launch_ipfs_daemon
+
+gwport=$GWAY_PORT
+
+test_expect_success "ipfs block deny add succeeds" '
+  ipfs block deny add "$DENIED" >actual &&
+  echo "denied $DENIED" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "ipfs block deny ls lists the entries" '
+  ipfs block deny ls >actual &&
+  grep "^$DENIED  *local$" actual &&
+  grep "^$FROMFILE  *$IPFS_PATH/denylist.txt$" actual
+'
+
+test_expect_success "the denied CID is not served" '
+  curl -s -o /dev/null -w "%{http_code}" "http://127.0.0.1:$gwport/ipfs/$DENIED" >actual &&
+  echo 451 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "the denied CID is not served under a directory" '
+  curl -s -o /dev/null -w "%{http_code}" "http://127.0.0.1:$gwport/ipfs/$DIR/denied" >actual &&
+  echo 451 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "the CID of the file is not served" '
+  curl -s -o /dev/null -w "%{http_code}" "http://127.0.0.1:$gwport/ipfs/$FROMFILE" >actual &&
+  echo 451 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "the other content is served" '
+  curl -sf "http://127.0.0.1:$gwport/ipfs/$DIR/allowed" >actual &&
+  echo "allowed" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "the entries of the file can't be removed" '
+  test_must_fail ipfs block deny rm "$FROMFILE"
+'
+
+test_expect_success "the reloaded file allows the CID again" '
+  echo > "$IPFS_PATH/denylist.txt" &&
+  ipfs block deny reload &&
+  curl -sf "http://127.0.0.1:$gwport/ipfs/$FROMFILE" >actual &&
+  echo "from file" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "ipfs block deny rm allows the CID again" '
+  ipfs block deny rm "$DENIED" &&
+  curl -sf "http://127.0.0.1:$gwport/ipfs/$DENIED" >actual &&
+  echo "denied" >expected &&
+  test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
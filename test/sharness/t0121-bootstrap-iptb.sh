@@ -78,6 +78,26 @@ test_expect_success "correct number of peers" '
   test `cat peers_out | wc -l` = 5
 '
 
+test_expect_success "bootstrap check reports the bootstrap node" '
+  ipfsi 0 bootstrap check >check_out &&
+  grep "^$bsn_peer_id connected" check_out &&
+  grep "1/1 bootstrap peers reachable" check_out
+'
+
+test_expect_success "bootstrap check reports unreachable peers" '
+  DEADADDR="/ip4/127.0.0.1/tcp/1/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ" &&
+  ipfsi 0 bootstrap add $DEADADDR &&
+  ipfsi 0 bootstrap check --dial-timeout=2s >check_out &&
+  grep "^QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ unreachable" check_out &&
+  grep "1/2 bootstrap peers reachable" check_out
+'
+
+test_expect_success "bootstrap peers are dead after repeated failures" '
+  ipfsi 0 bootstrap check --dial-timeout=2s &&
+  ipfsi 0 bootstrap check --dial-timeout=2s >check_out &&
+  grep "^QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ unreachable, dead after" check_out
+'
+
 test_kill_ipfs_daemon
 
 test_expect_success "bring down iptb nodes" '
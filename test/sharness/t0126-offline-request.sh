@@ -0,0 +1,84 @@
+#!/usr/bin/env bash
+
+test_description="Test the --offline option of single requests to an online node"
+
+. lib/test-lib.sh
+
+test_expect_success "init iptb" '
+  iptb init -n 2 --bootstrap=none --port=0
+'
+
+startup_cluster 2
+
+test_expect_success "add a file and a directory on node 1" '
+  random 500000 41 >remotefile &&
+  REMOTE_HASH=$(ipfsi 1 add -q remotefile) &&
+  mkdir remotedir &&
+  echo "in the dir" >remotedir/a &&
+  REMOTE_DIR=$(ipfsi 1 add -r -Q remotedir)
+'
+
+test_expect_success "add a file on node 0" '
+  echo "local content" >localfile &&
+  LOCAL_HASH=$(ipfsi 0 add -q localfile)
+'
+
+test_expect_success "local blocks can be read offline" '
+  ipfsi 0 cat --offline $LOCAL_HASH >actual &&
+  test_cmp localfile actual &&
+  ipfsi 0 block get --offline $LOCAL_HASH >/dev/null &&
+  ipfsi 0 dag get --offline $LOCAL_HASH >/dev/null &&
+  ipfsi 0 pin add --offline $LOCAL_HASH
+'
+
+test_expect_success "cat --offline fails on missing blocks" '
+  test_must_fail ipfsi 0 cat --offline $REMOTE_HASH 2>cat_err &&
+  test_should_contain "block not found locally" cat_err
+'
+
+test_expect_success "get --offline fails on missing blocks" '
+  test_must_fail ipfsi 0 get --offline -o got $REMOTE_DIR 2>get_err &&
+  test_should_contain "block not found locally" get_err
+'
+
+test_expect_success "dag get --offline fails on missing blocks" '
+  test_must_fail ipfsi 0 dag get --offline $REMOTE_HASH 2>dag_err &&
+  test_should_contain "block not found locally" dag_err
+'
+
+test_expect_success "block get --offline fails on missing blocks" '
+  test_must_fail ipfsi 0 block get --offline $REMOTE_HASH 2>block_err &&
+  test_should_contain "block not found locally" block_err
+'
+
+test_expect_success "pin add --offline fails on missing blocks" '
+  test_must_fail ipfsi 0 pin add --offline $REMOTE_HASH 2>pin_err &&
+  test_should_contain "block not found locally" pin_err &&
+  test_must_fail ipfsi 0 pin ls $REMOTE_HASH
+'
+
+test_expect_success "resolve and dag put honor --offline" '
+  test_must_fail ipfsi 0 resolve --offline -r /ipfs/$REMOTE_DIR/a 2>resolve_err &&
+  test_should_contain "block not found locally" resolve_err &&
+  echo "{\"a\":1}" | ipfsi 0 dag put --offline >dag_put_out
+'
+
+test_expect_success "commands using the network reject --offline" '
+  test_must_fail ipfsi 0 swarm peers --offline 2>swarm_err &&
+  test_should_contain "does not support --offline" swarm_err &&
+  test_must_fail ipfsi 0 files ls --offline / 2>files_err &&
+  test_should_contain "does not support --offline" files_err
+'
+
+test_expect_success "the blocks are still fetched without --offline" '
+  ipfsi 0 cat $REMOTE_HASH >actual &&
+  test_cmp remotefile actual &&
+  ipfsi 0 cat --offline $REMOTE_HASH >actual &&
+  test_cmp remotefile actual
+'
+
+test_expect_success "stop iptb" '
+  iptb stop
+'
+
+test_done
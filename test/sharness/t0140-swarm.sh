@@ -47,6 +47,51 @@ test_expect_success "cant trigger a dial backoff with swarm connect" '
   test_expect_code 1 grep "backoff" connect_out
 '
 
+test_expect_success "swarm connect --verbose reports each dial" '
+  test_expect_code 1 ipfs swarm connect --verbose $addr 2> connect_out &&
+  grep "/ip4/127.0.0.1/tcp/9898 (tcp): " connect_out
+'
+
+test_expect_success "swarm connect --clear-backoff=false respects the dial backoff" '
+  test_expect_code 1 ipfs swarm connect $addr 2> connect_out
+  test_expect_code 1 ipfs swarm connect --clear-backoff=false $addr 2>> connect_out
+  grep "backoff" connect_out
+'
+
+test_expect_success "swarm discovery shows the mDNS settings" '
+  ipfs swarm discovery >discovery_out &&
+  grep "mDNS: enabled, service tag \"_ipfs-discovery._udp\", interval 10s" discovery_out
+'
+
+test_expect_success "swarm rendezvous add advertises and stores the topic" '
+  ipfs swarm rendezvous add test-topic >rdv_out &&
+  grep "advertise \"test-topic\" success" rdv_out &&
+  ipfs swarm rendezvous ls >rdv_out &&
+  grep "^test-topic$" rdv_out &&
+  ipfs config Discovery.Rendezvous.Topics >rdv_out &&
+  grep "test-topic" rdv_out
+'
+
+test_expect_success "swarm rendezvous rm stops advertising the topic" '
+  ipfs swarm rendezvous rm test-topic >rdv_out &&
+  grep "remove \"test-topic\" success" rdv_out &&
+  ipfs swarm rendezvous ls >rdv_out &&
+  test_must_be_empty rdv_out
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "set a custom mDNS service tag" '
+  ipfs config Discovery.MDNS.ServiceTag _test-cluster._udp
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "swarm discovery uses the service tag" '
+  ipfs swarm discovery >discovery_out &&
+  grep "service tag \"_test-cluster._udp\"" discovery_out
+'
+
 test_kill_ipfs_daemon
 
 announceCfg='["/ip4/127.0.0.1/tcp/4001", "/ip4/1.2.3.4/tcp/1234"]'
@@ -123,8 +168,47 @@ test_expect_success "/p2p addresses work" '
   [ $(ipfsi 0 swarm peers | wc -l) -eq 1 ]
 '
 
+test_expect_success "swarm connect --verbose reports the address dialed" '
+  ipfsi 0 swarm disconnect "/ipfs/$(iptb get id 1)" &&
+  ipfsi 0 swarm connect --verbose --dial-timeout=5s "/ipfs/$(iptb get id 1)" >connect_out &&
+  grep "success" connect_out &&
+  grep "(tcp): success" connect_out
+'
+
+test_expect_success "diag net lists the connection" '
+  ipfsi 0 diag net >net_out &&
+  grep "1 peers, 1 connections" net_out &&
+  grep "$(iptb get id 1)" net_out &&
+  grep "Transports: tcp=1" net_out
+'
+
+test_expect_success "diag net groups the peers with a GeoIP database" '
+  printf "127.0.0.0\t127.255.255.255\t64512\tZZ\tTEST-AS\n" >geoip.tsv &&
+  ipfsi 0 diag net --geoip-db=geoip.tsv --enc=json >net_out.json &&
+  grep "\"ASN\":64512" net_out.json &&
+  grep "\"ASName\":\"TEST-AS\"" net_out.json
+'
+
+test_expect_success "swarm peerstore gc keeps the connected peer" '
+  ipfsi 0 swarm peerstore gc >gc_out &&
+  grep "1 peers left" gc_out
+'
+
+test_expect_success "swarm discover finds the node advertising a topic" '
+  ipfsi 0 swarm rendezvous add app-topic &&
+  # the topic is advertised in the background
+  go-sleep 1s &&
+  ipfsi 1 swarm discover -n 1 app-topic >discover_out &&
+  grep "$(iptb get id 0)" discover_out
+'
+
 test_expect_success "stopping cluster" '
   iptb stop
 '
 
+test_expect_success "the peerstore was persisted when the node stopped" '
+  ipfsi 0 swarm peerstore gc >gc_out &&
+  grep "removed 0 peers and 0 addresses, 1 peers left" gc_out
+'
+
 test_done
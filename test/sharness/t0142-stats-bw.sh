@@ -0,0 +1,56 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs stats bw command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "sample the bandwidth every second" '
+  ipfs config Swarm.BandwidthHistory.Interval 1s
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "stats bw accepts protocol patterns" '
+  ipfs stats bw -t "/ipfs/*" >bw_out &&
+  grep "TotalIn" bw_out
+'
+
+test_expect_success "stats bw history prints the samples" '
+  go-sleep 2s &&
+  ipfs stats bw history >history_out &&
+  grep "^total (since " history_out &&
+  grep "^Time  *Rate In" history_out &&
+  test $(wc -l <history_out) -gt 2
+'
+
+test_expect_success "stats bw history --since filters the samples" '
+  ipfs stats bw history --since=1ns >history_out &&
+  test $(wc -l <history_out) -eq 2
+'
+
+test_expect_success "stats bw history rejects peer and protocol together" '
+  test_expect_code 1 ipfs stats bw history -t "/ipfs/*" -p "$(ipfs config Identity.PeerID)" 2>history_err &&
+  grep "please only specify peer OR protocol" history_err
+'
+
+test_expect_success "save the start of the lifetime totals" '
+  ipfs stats bw history --enc=json | grep -o "\"Since\":\"[^\"]*\"" >since_before
+'
+
+test_kill_ipfs_daemon
+
+test_launch_ipfs_daemon
+
+test_expect_success "the lifetime totals survive restarts" '
+  ipfs stats bw history --enc=json | grep -o "\"Since\":\"[^\"]*\"" >since_after &&
+  test_cmp since_before since_after
+'
+
+test_kill_ipfs_daemon
+
+test_done
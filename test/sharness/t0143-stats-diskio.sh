@@ -0,0 +1,42 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs stats diskio command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "stats diskio fails offline" '
+  test_must_fail ipfs stats diskio 2>diskio_err &&
+  grep "this command must be run in online mode" diskio_err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "stats diskio counts the writes of the API" '
+  HASH=$(echo "disk io" | ipfs add -q) &&
+  ipfs stats diskio >diskio_out &&
+  grep "^Subsystem  *Reads" diskio_out &&
+  tail -n1 diskio_out | grep "^total "
+'
+
+test_expect_success "stats diskio counts the reads of the gateway" '
+  curl -sf "http://$GWAY_ADDR/ipfs/$HASH" >gw_out &&
+  ipfs stats diskio --enc=json >diskio_json &&
+  grep "\"gateway\":{\"ReadOps\":[1-9]" diskio_json
+'
+
+test_expect_success "stats diskio counts the garbage collection" '
+  ipfs pin rm "$HASH" &&
+  ipfs repo gc &&
+  ipfs stats diskio >diskio_out &&
+  grep "^gc " diskio_out &&
+  grep "^pinner " diskio_out
+'
+
+test_kill_ipfs_daemon
+
+test_done
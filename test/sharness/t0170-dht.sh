@@ -73,6 +73,18 @@ test_dht() {
     iptb get id 3 > expected &&
     test_cmp provs expected
   '
+
+  # ipfs routing findprovs <key>
+  test_expect_success 'routing findprovs' '
+    ipfsi 4 routing findprovs $HASH > provs &&
+    iptb get id 3 > expected &&
+    test_cmp provs expected
+  '
+
+  # ipfs routing get <key>
+  test_expect_success 'routing get' '
+    ipfsi 1 routing get "/ipns/$PEERID_2" | grep -aq "/ipfs/"
+  '
   
   
   # ipfs dht query <peerID>
@@ -121,6 +121,46 @@ test_expect_success 'stop peer 1' '
   iptb stop 1
 '
 
+# Test 'ondemand' strategy
+init_strategy 'ondemand'
+
+test_expect_success 'prepare test files' '
+  echo foo > f1 &&
+  echo bar > f2
+'
+
+test_expect_success 'add test objects' '
+  HASH_FOO=$(ipfsi 0 add -q f1) &&
+  HASH_BAR=$(ipfsi 0 add -q f2)
+'
+
+findprovs_empty '$HASH_FOO'
+findprovs_empty '$HASH_BAR'
+
+test_expect_success 'read test object' '
+  ipfsi 0 cat $HASH_FOO > cat_out &&
+  test_cmp f1 cat_out
+'
+
+test_expect_success 'read object provided on demand' '
+  for i in $(test_seq 1 10); do
+    ipfsi 1 dht findprovs -n 1 $HASH_FOO > findprovsOut &&
+    test -s findprovsOut && break
+    sleep 1
+  done &&
+  echo $PEERID_0 > expected &&
+  test_cmp expected findprovsOut
+'
+
+reprovide
+
+findprovs_expect '$HASH_FOO' '$PEERID_0'
+findprovs_empty '$HASH_BAR'
+
+test_expect_success 'stop peer 1' '
+  iptb stop 1
+'
+
 # Test reprovider working with ticking disabled
 test_expect_success 'init iptb' '
   iptb init -f -n $NUM_NODES --bootstrap=none --port=0
@@ -0,0 +1,58 @@
+#!/usr/bin/env bash
+
+test_description="Test DAG transfers of pinned and exported DAGs"
+
+. lib/test-lib.sh
+
+NUM_NODES=4
+
+test_expect_success 'init iptb' '
+  iptb init -f -n $NUM_NODES --bootstrap=none --port=0
+'
+
+test_expect_success 'enable DAG transfers' '
+  ipfsi 0 config --json DAGTransfer.Enabled true &&
+  ipfsi 1 config --json DAGTransfer.FetchDAGs true
+'
+
+startup_cluster ${NUM_NODES}
+
+test_expect_success 'add a directory on node 0' '
+  mkdir -p dir/sub &&
+  echo foo > dir/foo &&
+  echo bar > dir/sub/bar &&
+  random 300000 42 > dir/sub/big &&
+  HASH_DIR=$(ipfsi 0 add -r -Q dir)
+'
+
+test_expect_success 'pin the directory on node 1' '
+  ipfsi 1 pin add $HASH_DIR
+'
+
+test_expect_success 'node 1 has the whole directory' '
+  ipfsi 1 pin ls --type=recursive > pins &&
+  grep -q $HASH_DIR pins &&
+  ipfsi 1 cat $HASH_DIR/sub/bar > bar_out &&
+  test_cmp dir/sub/bar bar_out
+'
+
+test_expect_success 'node 1 exports a directory it does not have' '
+  mkdir dir2 &&
+  echo baz > dir2/baz &&
+  random 200000 43 > dir2/big &&
+  HASH_DIR2=$(ipfsi 0 add -r -Q dir2) &&
+  ipfsi 1 get -o dir2_out $HASH_DIR2 &&
+  test_cmp dir2/big dir2_out/big &&
+  ipfsi 1 get --archive-format=car -o dir2.car $HASH_DIR2 &&
+  ipfsi 1 archive export $HASH_DIR2 > dir2.tar
+'
+
+test_expect_success 'node 2 pins it without DAG transfers' '
+  ipfsi 2 pin add $HASH_DIR
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
@@ -292,11 +292,124 @@ test_expect_success 'C->S Close local listener' '
 
 check_test_ports
 
+test_expect_success "'ipfs p2p listen --allow-peer' fails with invalid peer IDs" '
+  test_must_fail ipfsi 0 p2p listen --allow-peer=foo /x/p2p-allow /ip4/127.0.0.1/tcp/10101
+'
+
+test_expect_success "'ipfs p2p listen --allow-peer' succeeds" '
+  ipfsi 0 p2p close -a &&
+  ipfsi 0 p2p listen --allow-peer=$PEERID_0 /x/p2p-allow /ip4/127.0.0.1/tcp/10101
+'
+
+test_expect_success "'ipfs p2p ls --detail' lists the allowed peers" '
+  echo "/x/p2p-allow /ipfs/$PEERID_0 /ip4/127.0.0.1/tcp/10101 $PEERID_0 ok" > expected &&
+  ipfsi 0 p2p ls --detail > actual &&
+  test_cmp expected actual
+'
+
+test_expect_success 'S->C Spawn sending server (allowed peers)' '
+  ma-pipe-unidir --listen --pidFile=listener.pid send /ip4/127.0.0.1/tcp/10101 < test0.bin &
+
+  test_wait_for_file 30 100ms listener.pid &&
+  kill -0 $(cat listener.pid)
+'
+
+test_expect_success 'S->C Setup client side (allowed peers)' '
+  ipfsi 1 p2p forward /x/p2p-allow /ip4/127.0.0.1/tcp/10102 /ipfs/${PEERID_0} 2>&1 > dialer-stdouterr.log &&
+  echo "/x/p2p-allow /ip4/127.0.0.1/tcp/10102 /ipfs/$PEERID_0 * ok" > expected &&
+  ipfsi 1 p2p ls --detail > actual &&
+  test_cmp expected actual
+'
+
+test_expect_success 'S->C Peers not allowed receive nothing' '
+  ma-pipe-unidir recv /ip4/127.0.0.1/tcp/10102 > client.out &&
+  test_must_be_empty client.out &&
+  kill -0 $(cat listener.pid)
+'
+
+test_expect_success 'S->C Allow the client peer' '
+  ipfsi 0 p2p close -p /x/p2p-allow &&
+  ipfsi 0 p2p listen --allow-peer=$PEERID_0,$PEERID_1 /x/p2p-allow /ip4/127.0.0.1/tcp/10101
+'
+
+test_server_to_client
+
+test_expect_success 'Close allowed peers listeners' '
+  ipfsi 0 p2p close -a &&
+  ipfsi 1 p2p close -a
+'
+
+check_test_ports
+
+test_expect_success "'ipfs p2p' registers UDP listeners" '
+  ipfsi 0 p2p listen /x/p2p-udp /ip4/127.0.0.1/udp/10101 &&
+  ipfsi 1 p2p forward /x/p2p-udp /ip4/127.0.0.1/udp/10102 /ipfs/$PEERID_0 &&
+  echo "/x/p2p-udp /ipfs/$PEERID_0 /ip4/127.0.0.1/udp/10101" > expected0 &&
+  echo "/x/p2p-udp /ip4/127.0.0.1/udp/10102 /ipfs/$PEERID_0" > expected1 &&
+  ipfsi 0 p2p ls > actual0 &&
+  ipfsi 1 p2p ls > actual1 &&
+  test_cmp expected0 actual0 &&
+  test_cmp expected1 actual1
+'
+
+test_expect_success "'ipfs p2p close' closes UDP listeners" '
+  ipfsi 0 p2p close -p /x/p2p-udp &&
+  ipfsi 1 p2p close -l /ip4/127.0.0.1/udp/10102 &&
+  ipfsi 1 p2p forward /x/p2p-udp /ip4/127.0.0.1/udp/10102 /ipfs/$PEERID_0 &&
+  ipfsi 1 p2p close -a
+'
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
 
 check_test_ports
 
+# Persistent forwards
+
+test_expect_success 'declare p2p forwards in the config' '
+  ipfsi 0 config --json P2P.Forwards "[
+    {\"Protocol\": \"/x/p2p-persist\", \"TargetAddress\": \"/ip4/127.0.0.1/tcp/10101\", \"AllowPeers\": [\"$PEERID_1\"]},
+    {\"Protocol\": \"/x/p2p-broken\", \"ListenAddress\": \"/ip4/127.0.0.1/tcp/10103\", \"TargetAddress\": \"/ip4/127.0.0.1/tcp/10101\"}
+  ]"
+'
+
+startup_cluster 2
+
+test_expect_success "'ipfs p2p ls' lists the listeners of the config" '
+  echo "/x/p2p-persist /ipfs/$PEERID_0 /ip4/127.0.0.1/tcp/10101" > expected &&
+  ipfsi 0 p2p ls > actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "'ipfs p2p ls --detail' reports the failed forwards of the config" '
+  ipfsi 0 p2p ls --detail > actual &&
+  grep "^/x/p2p-persist /ipfs/$PEERID_0 /ip4/127.0.0.1/tcp/10101 $PEERID_1 ok$" actual &&
+  grep "^/x/p2p-broken /ip4/127.0.0.1/tcp/10103 /ip4/127.0.0.1/tcp/10101 \* failed: " actual
+'
+
+spawn_sending_server
+
+test_expect_success 'S->C Setup client side (persistent listener)' '
+  ipfsi 1 p2p forward /x/p2p-persist /ip4/127.0.0.1/tcp/10102 /ipfs/${PEERID_0} 2>&1 > dialer-stdouterr.log
+'
+
+test_server_to_client
+
+test_expect_success "'ipfs p2p ls --detail' reports failing listeners" '
+  ipfsi 1 p2p close -a &&
+  ipfsi 1 p2p forward /x/p2p-persist /ip4/127.0.0.1/tcp/10102 /ipfs/${PEERID_0} &&
+  ma-pipe-unidir recv /ip4/127.0.0.1/tcp/10102 > client.out &&
+  test_must_be_empty client.out &&
+  ipfsi 0 p2p ls --detail > actual &&
+  grep "^/x/p2p-persist .* failing: " actual
+'
+
+test_expect_success 'stop iptb (persistent forwards)' '
+  iptb stop
+'
+
+check_test_ports
+
 test_done
 
@@ -0,0 +1,32 @@
+#!/usr/bin/env bash
+
+test_description="Test persistent pubsub topics"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "configure persistent topics" '
+  ipfs config --json Pubsub.PersistentTopics "[\"relay-a\", \"relay-b\"]"
+'
+
+test_launch_ipfs_daemon --enable-pubsub-experiment
+
+test_expect_success "persistent topics are subscribed" '
+  printf "relay-a\nrelay-b\n" > expected &&
+  ipfs pubsub ls | sort > actual &&
+  test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_launch_ipfs_daemon --enable-pubsub-experiment
+
+test_expect_success "persistent topics survive a restart" '
+  ipfs pubsub ls | sort > actual &&
+  test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
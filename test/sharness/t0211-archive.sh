@@ -0,0 +1,84 @@
+#!/usr/bin/env bash
+
+test_description="Test the archive commands"
+
+. lib/test-lib.sh
+
+type zip >/dev/null 2>&1 && type unzip >/dev/null 2>&1 && test_set_prereq ZIP
+
+test_init_ipfs
+
+test_expect_success "create some random files" '
+  mkdir foo &&
+  random 10000 > foo/a &&
+  random 1000000 > foo/b &&
+  mkdir foo/bar &&
+  random 5432 > foo/bar/baz &&
+  ln -s ../a foo/bar/link
+'
+
+test_expect_success "archive those random files" '
+  tar cf files.tar foo/ &&
+  tar czf files.tgz foo/
+'
+
+test_expect_success "'ipfs archive add' imports a tar archive" '
+  TAR_HASH=$(ipfs archive add files.tar) &&
+  ipfs pin ls --type=recursive | grep $TAR_HASH
+'
+
+test_expect_success "the entries keep their paths" '
+  ipfs cat $TAR_HASH/foo/bar/baz > baz_out &&
+  test_cmp foo/bar/baz baz_out
+'
+
+test_expect_success "'ipfs archive add' imports a gzip compressed tar archive" '
+  TGZ_HASH=$(ipfs archive add < files.tgz) &&
+  test "$TGZ_HASH" = "$TAR_HASH"
+'
+
+test_expect_success ZIP "'ipfs archive add' imports a zip archive" '
+  zip -qry files.zip foo/ &&
+  ZIP_HASH=$(ipfs archive add --pin=false files.zip) &&
+  test "$ZIP_HASH" = "$TAR_HASH"
+'
+
+test_expect_success "'ipfs archive add' rejects an unknown format" '
+  echo "not an archive" > notarchive &&
+  test_must_fail ipfs archive add notarchive &&
+  test_must_fail ipfs archive add --format=rar files.tar
+'
+
+test_expect_success "'ipfs archive export' writes a tar archive" '
+  mkdir output &&
+  ipfs archive export $TAR_HASH > output/out.tar &&
+  tar xf output/out.tar -C output/
+'
+
+test_expect_success "the exported files look right" '
+  test_cmp foo/a output/foo/a &&
+  test_cmp foo/b output/foo/b &&
+  test_cmp foo/bar/baz output/foo/bar/baz &&
+  [ -L output/foo/bar/link ]
+'
+
+test_expect_success "'ipfs archive export' is deterministic" '
+  ipfs archive export $TAR_HASH > output/again.tar &&
+  test_cmp output/out.tar output/again.tar
+'
+
+test_expect_success ZIP "'ipfs archive export --format=zip' writes a zip archive" '
+  mkdir zipout &&
+  ipfs archive export --format=zip $TAR_HASH > zipout/out.zip &&
+  (cd zipout && unzip -q out.zip) &&
+  test_cmp foo/b zipout/foo/b
+'
+
+test_expect_success "'ipfs archive export' of a file names the entry after the path" '
+  ipfs archive export $TAR_HASH/foo/a > file.tar &&
+  tar tf file.tar > file_list &&
+  echo "a" > file_exp &&
+  test_cmp file_exp file_list
+'
+
+test_done
@@ -726,4 +726,45 @@ test_sharding "(cidv1 root)" "--cid-version=1"
 
 test_kill_ipfs_daemon
 
+test_expect_success "set the chunker in config" '
+  ipfs config Import.UnixFSChunker size-1024 &&
+  random 10000 42 > chunked
+'
+
+test_expect_success "files write imports the created files as ipfs add" '
+  ipfs add -Q --only-hash chunked > chunked_expected &&
+  ipfs files write --create /chunked < chunked &&
+  ipfs files stat --hash /chunked > chunked_actual &&
+  test_cmp chunked_expected chunked_actual
+'
+
+test_expect_success "files write imports the truncated files as ipfs add" '
+  echo foo | ipfs files write --create /chunked-trunc &&
+  ipfs files write --truncate /chunked-trunc < chunked &&
+  ipfs files stat --hash /chunked-trunc > chunked_actual &&
+  test_cmp chunked_expected chunked_actual
+'
+
+test_expect_success "unset the chunker in config" '
+  ipfs config --json Import.UnixFSChunker null &&
+  ipfs files rm /chunked &&
+  ipfs files rm /chunked-trunc
+'
+
+test_expect_success "files write --chunker imports the file as ipfs add" '
+  ipfs add -Q --only-hash --chunker=buzhash chunked > chunked_expected &&
+  ipfs files write --create --chunker=buzhash /chunked < chunked &&
+  ipfs files stat --hash /chunked > chunked_actual &&
+  test_cmp chunked_expected chunked_actual
+'
+
+test_expect_success "files write --chunker refuses the partial writes" '
+  test_expect_code 1 ipfs files write --offset 10 --chunker=buzhash /chunked < chunked &&
+  test_expect_code 1 ipfs files write --chunker=size-1024 /chunked < chunked
+'
+
+test_expect_success "files write refuses an invalid chunker" '
+  test_expect_code 1 ipfs files write --create --chunker=rabin-1-2 /chunked-invalid < chunked
+'
+
 test_done
@@ -0,0 +1,84 @@
+#!/usr/bin/env bash
+
+test_description="test the snapshots of the unix files api"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create some files" '
+  ipfs files mkdir /photos &&
+  echo "cat picture" | ipfs files write --create /photos/cat &&
+  echo "dog picture" | ipfs files write --create /photos/dog &&
+  CAT_HASH=$(ipfs files stat --hash /photos/cat) &&
+  ROOT_HASH=$(ipfs files stat --hash /)
+'
+
+test_expect_success "'ipfs files snapshot create' succeeds" '
+  ipfs files snapshot create before-cleanup > create_out &&
+  grep "created snapshot before-cleanup of $ROOT_HASH" create_out
+'
+
+test_expect_success "snapshot names are unique" '
+  test_must_fail ipfs files snapshot create before-cleanup 2> err &&
+  grep "already exists" err
+'
+
+test_expect_success "'ipfs files snapshot create' rejects invalid names" '
+  test_must_fail ipfs files snapshot create "a/b"
+'
+
+test_expect_success "'ipfs files snapshot ls' lists the snapshot" '
+  ipfs files snapshot ls > ls_out &&
+  grep "^before-cleanup *$ROOT_HASH" ls_out
+'
+
+test_expect_success "the snapshot keeps the removed files from the gc" '
+  ipfs files rm -r /photos &&
+  ipfs repo gc &&
+  ipfs cat $CAT_HASH
+'
+
+test_expect_success "'ipfs files snapshot restore' brings the files back" '
+  ipfs files snapshot restore before-cleanup > restore_out &&
+  grep "restored snapshot before-cleanup" restore_out &&
+  ipfs files read /photos/cat > cat_out &&
+  echo "cat picture" > cat_exp &&
+  test_cmp cat_exp cat_out &&
+  test "$(ipfs files stat --hash /)" = "$ROOT_HASH"
+'
+
+test_expect_success "the restore saved the previous root" '
+  ipfs files snapshot ls > ls_out &&
+  grep "auto$" ls_out
+'
+
+test_expect_success "'ipfs files snapshot rm' removes the snapshots" '
+  ipfs files snapshot rm before-cleanup &&
+  ipfs files snapshot ls > ls_out &&
+  test_must_fail grep before-cleanup ls_out &&
+  test_must_fail ipfs files snapshot restore before-cleanup
+'
+
+test_expect_success "enable the automatic snapshots" '
+  ipfs config --json Files.AutoSnapshot true &&
+  ipfs config --json Files.MaxAutoSnapshots 2 &&
+  ipfs files snapshot rm $(ipfs files snapshot ls | cut -d" " -f1)
+'
+
+test_expect_success "'ipfs files rm' takes a snapshot" '
+  ipfs files rm /photos/dog &&
+  ipfs files snapshot ls > ls_out &&
+  test $(grep -c "auto$" ls_out) -eq 1
+'
+
+test_expect_success "only the last automatic snapshots are kept" '
+  echo "1" | ipfs files write --create /one &&
+  ipfs files rm /one &&
+  echo "2" | ipfs files write --create /two &&
+  ipfs files rm /two &&
+  ipfs files snapshot ls > ls_out &&
+  test $(grep -c "auto$" ls_out) -eq 2
+'
+
+test_done
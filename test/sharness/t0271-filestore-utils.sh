@@ -153,6 +153,43 @@ test_filestore_verify() {
   test_init_dataset
 }
 
+test_filestore_mv() {
+  # make sure the filestore is in a clean state
+  test_filestore_state
+
+  test_expect_success "move the dataset" '
+    mv somedir movedir &&
+    test_must_fail ipfs cat $FILE1_HASH
+  '
+
+  test_expect_success "'ipfs filestore mv' rewrites the references" '
+    ipfs filestore mv somedir movedir > mv_actual &&
+    sed "s/somedir/movedir/" verify_expect_key_order > mv_expect &&
+    LC_ALL=C sort mv_actual > mv_sorted &&
+    test_cmp mv_expect mv_sorted
+  '
+
+  test_expect_success "blocks can be read from the new location" '
+    ipfs cat $FILE3_HASH > file3.data &&
+    test_cmp movedir/file3 file3.data
+  '
+
+  test_expect_success "'ipfs filestore mv' keeps the references that do not verify" '
+    mv movedir somedir &&
+    dd if=/dev/zero of=somedir/file1 bs=1000 count=1 &&
+    test_must_fail ipfs filestore mv movedir somedir > mv_actual &&
+    grep changed mv_actual | grep -q movedir/file1 &&
+    grep ok mv_actual | grep -q somedir/file2
+  '
+
+  # reset the state for the next test
+  test_init_dataset
+
+  test_expect_success "move the remaining references back" '
+    ipfs filestore mv movedir somedir
+  '
+}
+
 test_filestore_dups() {
   # make sure the filestore is in a clean state
   test_filestore_state
@@ -165,6 +202,47 @@ test_filestore_dups() {
   '
 }
 
+test_filestore_clean() {
+  # make sure the filestore is in a clean state
+  test_filestore_state
+
+  test_expect_success "break some references" '
+    rm somedir/file2 &&
+    dd if=/dev/zero of=somedir/file3 bs=1024 count=1
+  '
+
+  test_expect_success "'ipfs filestore clean' fails on valid references" '
+    test_must_fail ipfs filestore clean ok
+  '
+
+  test_expect_success "'ipfs filestore clean no-file' removes the missing files" '
+    ipfs filestore clean no-file > clean_actual &&
+    grep no-file clean_actual | grep -q somedir/file2 &&
+    test_must_fail grep somedir/file3 clean_actual &&
+    grep -q "removed 1 references (10 kB)" clean_actual
+  '
+
+  test_expect_success "'ipfs filestore verify' does not list the removed references" '
+    ipfs filestore verify > verify_actual &&
+    test_must_fail grep somedir/file2 verify_actual &&
+    grep changed verify_actual | grep -q somedir/file3
+  '
+
+  test_expect_success "'ipfs filestore clean -q changed' removes the changed files" '
+    ipfs filestore clean -q changed > clean_actual &&
+    printf "removed 4 changed references\nremoved 4 references (1.0 MB)\n" > clean_expect &&
+    test_cmp clean_expect clean_actual
+  '
+
+  test_expect_success "only valid references are left" '
+    ipfs filestore verify > verify_actual &&
+    test_must_fail grep -v "^ok" verify_actual
+  '
+
+  # reset the state for the next test
+  test_init_dataset
+}
+
 #
 # No daemon
 #
@@ -175,8 +253,12 @@ test_filestore_adds
 
 test_filestore_verify
 
+test_filestore_mv
+
 test_filestore_dups
 
+test_filestore_clean
+
 #
 # With daemon
 #
@@ -191,8 +273,12 @@ test_filestore_adds
 
 test_filestore_verify
 
+test_filestore_mv
+
 test_filestore_dups
 
+test_filestore_clean
+
 test_kill_ipfs_daemon
 
 test_done
@@ -0,0 +1,317 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	version "github.com/ipfs/go-ipfs"
+
+	otlog "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go/log"
+)
+
+const (
+	// maxQueuedSpans bounds the spans waiting for export. Spans finished
+	// while the queue is full are dropped.
+	maxQueuedSpans = 2048
+	maxBatchSize   = 512
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+)
+
+// span kinds and status codes of OTLP.
+const (
+	kindInternal    = 1
+	kindServer      = 2
+	kindClient      = 3
+	kindProducer    = 4
+	kindConsumer    = 5
+	statusCodeError = 2
+)
+
+// exporter posts the finished spans to the collector in batches, encoded
+// as OTLP/HTTP JSON.
+type exporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	queue   chan *span
+	closing chan struct{}
+	done    chan struct{}
+
+	closeOnce sync.Once
+}
+
+func newExporter(endpoint, service string) *exporter {
+	e := &exporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: exportTimeout},
+		queue:    make(chan *span, maxQueuedSpans),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *exporter) enqueue(s *span) {
+	select {
+	case e.queue <- s:
+	default:
+		log.Debug("span queue full, dropping span ", s.name)
+	}
+}
+
+func (e *exporter) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*span, 0, maxBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			log.Warningf("exporting %d spans: %s", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) == maxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.closing:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+					if len(batch) == maxBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close exports the queued spans.
+func (e *exporter) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.closing)
+	})
+	<-e.done
+	return nil
+}
+
+func (e *exporter) export(spans []*span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// The types below are the JSON mapping of the OTLP trace protobufs.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *exporter) encode(spans []*span) *otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		out = append(out, encodeSpan(s))
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{attribute("service.name", e.service)},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "go-ipfs", Version: version.CurrentVersionNumber},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func encodeSpan(s *span) otlpSpan {
+	out := otlpSpan{
+		TraceID:           hex.EncodeToString(s.ctx.traceID[:]),
+		SpanID:            hex.EncodeToString(s.ctx.spanID[:]),
+		Name:              s.name,
+		Kind:              kindInternal,
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(s.end),
+	}
+	if s.parent != (spanID{}) {
+		out.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := s.tags[k]
+		switch k {
+		case "span.kind":
+			out.Kind = spanKind(fmt.Sprint(v))
+			continue
+		case "error":
+			if failed, _ := v.(bool); failed {
+				out.Status = &otlpStatus{Code: statusCodeError}
+			}
+		}
+		out.Attributes = append(out.Attributes, attribute(k, v))
+	}
+
+	for _, rec := range s.logs {
+		ev := otlpEvent{TimeUnixNano: unixNano(rec.Timestamp), Name: "log"}
+		for _, f := range rec.Fields {
+			if f.Key() == "event" {
+				ev.Name = fmt.Sprint(f.Value())
+				continue
+			}
+			ev.Attributes = append(ev.Attributes, fieldAttribute(f))
+		}
+		out.Events = append(out.Events, ev)
+	}
+	return out
+}
+
+func spanKind(k string) int {
+	switch k {
+	case "server":
+		return kindServer
+	case "client":
+		return kindClient
+	case "producer":
+		return kindProducer
+	case "consumer":
+		return kindConsumer
+	default:
+		return kindInternal
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func fieldAttribute(f otlog.Field) otlpKeyValue {
+	if err, ok := f.Value().(error); ok {
+		return attribute(f.Key(), err.Error())
+	}
+	return attribute(f.Key(), f.Value())
+}
+
+func attribute(k string, v interface{}) otlpKeyValue {
+	var av otlpAnyValue
+	switch v := v.(type) {
+	case string:
+		av.StringValue = &v
+	case bool:
+		av.BoolValue = &v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(v)
+		av.IntValue = &s
+	case float32:
+		f := float64(v)
+		av.DoubleValue = &f
+	case float64:
+		av.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		av.StringValue = &s
+	}
+	return otlpKeyValue{Key: k, Value: av}
+}
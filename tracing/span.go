@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
+	otlog "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go/log"
+)
+
+type traceID [16]byte
+
+type spanID [8]byte
+
+// spanContext is immutable, spans replace it to change the baggage.
+type spanContext struct {
+	traceID traceID
+	spanID  spanID
+	sampled bool
+	baggage map[string]string
+}
+
+// ForeachBaggageItem implements opentracing.SpanContext.
+func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+type span struct {
+	tracer *Tracer
+	parent spanID
+	start  time.Time
+
+	lk       sync.Mutex
+	ctx      spanContext
+	name     string
+	end      time.Time
+	tags     map[string]interface{}
+	logs     []opentracing.LogRecord
+	finished bool
+}
+
+var _ opentracing.Span = (*span)(nil)
+
+func (s *span) Finish() {
+	s.FinishWithOptions(opentracing.FinishOptions{})
+}
+
+func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
+	end := opts.FinishTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	s.lk.Lock()
+	if s.finished {
+		s.lk.Unlock()
+		return
+	}
+	s.finished = true
+	s.end = end
+	s.logs = append(s.logs, opts.LogRecords...)
+	for _, ld := range opts.BulkLogData {
+		s.logs = append(s.logs, ld.ToLogRecord())
+	}
+	s.lk.Unlock()
+
+	// the span isn't modified anymore past this point.
+	if s.ctx.sampled {
+		s.tracer.exporter.enqueue(s)
+	}
+	if next := s.tracer.next; next != nil {
+		ns := next.StartSpan(s.name, opentracing.StartTime(s.start), opentracing.Tags(s.tags))
+		ns.FinishWithOptions(opentracing.FinishOptions{FinishTime: s.end, LogRecords: s.logs})
+	}
+}
+
+func (s *span) Context() opentracing.SpanContext {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.ctx
+}
+
+func (s *span) SetOperationName(name string) opentracing.Span {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if !s.finished {
+		s.name = name
+	}
+	return s
+}
+
+func (s *span) SetTag(key string, value interface{}) opentracing.Span {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.finished {
+		return s
+	}
+	if s.tags == nil {
+		s.tags = make(map[string]interface{})
+	}
+	s.tags[key] = value
+	return s
+}
+
+func (s *span) LogFields(fields ...otlog.Field) {
+	s.log(opentracing.LogRecord{Timestamp: time.Now(), Fields: fields})
+}
+
+func (s *span) LogKV(kvs ...interface{}) {
+	fields, err := otlog.InterleavedKVToFields(kvs...)
+	if err != nil {
+		s.LogFields(otlog.Error(err), otlog.String("function", "LogKV"))
+		return
+	}
+	s.LogFields(fields...)
+}
+
+func (s *span) log(rec opentracing.LogRecord) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.finished {
+		return
+	}
+	s.logs = append(s.logs, rec)
+}
+
+func (s *span) SetBaggageItem(key, val string) opentracing.Span {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.finished {
+		return s
+	}
+	baggage := make(map[string]string, len(s.ctx.baggage)+1)
+	for k, v := range s.ctx.baggage {
+		baggage[k] = v
+	}
+	baggage[key] = val
+	s.ctx.baggage = baggage
+	return s
+}
+
+func (s *span) BaggageItem(key string) string {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.ctx.baggage[key]
+}
+
+func (s *span) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *span) LogEvent(event string) {
+	s.LogFields(otlog.String("event", event))
+}
+
+func (s *span) LogEventWithPayload(event string, payload interface{}) {
+	s.LogFields(otlog.String("event", event), otlog.Object("payload", payload))
+}
+
+func (s *span) Log(ld opentracing.LogData) {
+	if ld.Timestamp.IsZero() {
+		ld.Timestamp = time.Now()
+	}
+	s.log(ld.ToLogRecord())
+}
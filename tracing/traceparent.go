@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"strings"
+
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
+)
+
+// TraceparentHeader carries the trace context, as defined by
+// https://www.w3.org/TR/trace-context/.
+const TraceparentHeader = "traceparent"
+
+const flagSampled = 0x01
+
+// formatTraceparent encodes c as a version 00 traceparent.
+func formatTraceparent(c spanContext) string {
+	flags := "00"
+	if c.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(c.traceID[:]) + "-" + hex.EncodeToString(c.spanID[:]) + "-" + flags
+}
+
+// parseTraceparent decodes a traceparent. Versions newer than 00 are parsed
+// as 00 as long as they start the same, as the specification requires.
+func parseTraceparent(v string) (spanContext, error) {
+	var c spanContext
+
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(c.traceID[:], []byte(parts[1])); err != nil || c.traceID == (traceID{}) {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(c.spanID[:], []byte(parts[2])); err != nil || c.spanID == (spanID{}) {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return c, opentracing.ErrSpanContextCorrupted
+	}
+	c.sampled = flags[0]&flagSampled != 0
+	return c, nil
+}
@@ -0,0 +1,254 @@
+// Package tracing exports OpenTelemetry traces of the node to an OTLP/HTTP
+// collector.
+//
+// The components of go-ipfs and its dependencies are instrumented with
+// opentracing, directly or through the events of go-log, so the exporter is
+// an opentracing.Tracer: once it is the global tracer, the spans of the HTTP
+// API, the gateway, the CoreAPI, bitswap and the DHT are exported. Trace
+// context is propagated over HTTP with the W3C traceparent header.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
+)
+
+var log = logging.Logger("tracing")
+
+// Environment variables overriding the config, as defined by the
+// OpenTelemetry specification.
+const (
+	EnvEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	EnvServiceName    = "OTEL_SERVICE_NAME"
+	EnvSamplerArg     = "OTEL_TRACES_SAMPLER_ARG"
+)
+
+// DefaultServiceName is the service.name of the traces when none is
+// configured.
+const DefaultServiceName = "go-ipfs"
+
+// tracesPath is appended to base endpoints.
+const tracesPath = "/v1/traces"
+
+// Tracer is an opentracing.Tracer exporting the sampled spans to an OTLP
+// collector.
+type Tracer struct {
+	endpoint string
+	ratio    float64
+	exporter *exporter
+
+	// next receives a copy of every finished span, so that the tracer
+	// that was global before, such as the event log of go-log, keeps
+	// working.
+	next opentracing.Tracer
+}
+
+var _ opentracing.Tracer = (*Tracer)(nil)
+
+// New returns a tracer exporting to the endpoint configured in cfg or in the
+// environment, which takes precedence. It returns nil when no endpoint is
+// configured.
+func New(cfg *extconfig.Tracing) (*Tracer, error) {
+	endpoint := cfg.Endpoint
+	if env := os.Getenv(EnvEndpoint); env != "" {
+		endpoint = env
+	}
+	if endpoint != "" {
+		endpoint = strings.TrimSuffix(endpoint, "/") + tracesPath
+	}
+	if env := os.Getenv(EnvTracesEndpoint); env != "" {
+		endpoint = env
+	}
+	if endpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: expected an http(s) URL", endpoint)
+	}
+
+	service := cfg.ServiceName
+	if env := os.Getenv(EnvServiceName); env != "" {
+		service = env
+	}
+	if service == "" {
+		service = DefaultServiceName
+	}
+
+	ratio := 1.0
+	if cfg.SampleRatio != nil {
+		ratio = *cfg.SampleRatio
+	}
+	if env := os.Getenv(EnvSamplerArg); env != "" {
+		ratio, err = strconv.ParseFloat(env, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvSamplerArg, err)
+		}
+	}
+	if ratio < 0 || ratio > 1 || math.IsNaN(ratio) {
+		return nil, fmt.Errorf("invalid sample ratio %v: must be between 0 and 1", ratio)
+	}
+
+	t := &Tracer{
+		endpoint: endpoint,
+		ratio:    ratio,
+		exporter: newExporter(endpoint, service),
+	}
+	if prev := opentracing.GlobalTracer(); !isNoop(prev) {
+		t.next = prev
+	}
+	return t, nil
+}
+
+func isNoop(t opentracing.Tracer) bool {
+	_, ok := t.(opentracing.NoopTracer)
+	return ok
+}
+
+// Active returns whether the global tracer exports traces.
+func Active() bool {
+	_, ok := opentracing.GlobalTracer().(*Tracer)
+	return ok
+}
+
+// Endpoint returns the URL the spans are posted to.
+func (t *Tracer) Endpoint() string {
+	return t.endpoint
+}
+
+// Close exports the spans still queued and stops the exporter.
+func (t *Tracer) Close() error {
+	return t.exporter.Close()
+}
+
+// StartSpan implements opentracing.Tracer.
+func (t *Tracer) StartSpan(name string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var sso opentracing.StartSpanOptions
+	for _, o := range opts {
+		o.Apply(&sso)
+	}
+
+	s := &span{
+		tracer: t,
+		name:   name,
+		start:  sso.StartTime,
+	}
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	if len(sso.Tags) > 0 {
+		s.tags = make(map[string]interface{}, len(sso.Tags))
+		for k, v := range sso.Tags {
+			s.tags[k] = v
+		}
+	}
+
+	// the first parent of ours sets the trace, others are spans of other
+	// tracers or links we don't report.
+	for _, ref := range sso.References {
+		if ref.Type != opentracing.ChildOfRef && ref.Type != opentracing.FollowsFromRef {
+			continue
+		}
+		if pc, ok := ref.ReferencedContext.(spanContext); ok {
+			s.ctx = pc
+			s.parent = pc.spanID
+			break
+		}
+	}
+	if s.parent == (spanID{}) {
+		s.ctx = spanContext{traceID: newTraceID()}
+		s.ctx.sampled = t.sample(s.ctx.traceID)
+	}
+	s.ctx.spanID = newSpanID()
+	return s
+}
+
+// sample decides whether to export a new trace, deterministically from its
+// id like the TraceIdRatioBased sampler of OpenTelemetry.
+func (t *Tracer) sample(id traceID) bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	bound := uint64(t.ratio * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:])>>1 < bound
+}
+
+// Inject implements opentracing.Tracer. The TextMap and HTTPHeaders formats
+// are supported.
+func (t *Tracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	c, ok := sc.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return opentracing.ErrUnsupportedFormat
+	}
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	w.Set(TraceparentHeader, formatTraceparent(c))
+	return nil
+}
+
+// Extract implements opentracing.Tracer. The TextMap and HTTPHeaders
+// formats are supported.
+func (t *Tracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+
+	var header string
+	err := r.ForeachKey(func(k, v string) error {
+		if strings.EqualFold(k, TraceparentHeader) {
+			header = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if header == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	c, err := parseTraceparent(header)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newTraceID() (id traceID) {
+	for id == (traceID{}) {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(err)
+		}
+	}
+	return id
+}
+
+func newSpanID() (id spanID) {
+	for id == (spanID{}) {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(err)
+		}
+	}
+	return id
+}
@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	extconfig "github.com/ipfs/go-ipfs/repo/extconfig"
+
+	opentracing "gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
+)
+
+func TestTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	c, err := parseTraceparent(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.sampled {
+		t.Fatal("expected the context to be sampled")
+	}
+	if out := formatTraceparent(c); out != header {
+		t.Fatalf("expected %s, got %s", header, out)
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, err := parseTraceparent(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+
+	// newer versions may append fields
+	if _, err := parseTraceparent(header[2:] + "-extra"); err == nil {
+		t.Error("expected a truncated version to be rejected")
+	}
+	if _, err := parseTraceparent("01" + header[2:] + "-extra"); err != nil {
+		t.Errorf("expected a newer version to be accepted: %s", err)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	never := &Tracer{ratio: 0, exporter: &exporter{queue: make(chan *span, 1)}}
+	always := &Tracer{ratio: 1, exporter: &exporter{queue: make(chan *span, 1)}}
+
+	if never.StartSpan("a").Context().(spanContext).sampled {
+		t.Fatal("expected the trace not to be sampled")
+	}
+	if !always.StartSpan("a").Context().(spanContext).sampled {
+		t.Fatal("expected the trace to be sampled")
+	}
+
+	// children follow the decision of the caller
+	remote, err := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := never.StartSpan("b", opentracing.ChildOf(remote)).Context().(spanContext)
+	if !child.sampled || child.traceID != remote.traceID || child.spanID == remote.spanID {
+		t.Fatalf("unexpected child context %+v", child)
+	}
+}
+
+func TestExport(t *testing.T) {
+	reqs := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tracesPath {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		reqs <- req
+	}))
+	defer srv.Close()
+
+	tr, err := New(&extconfig.Tracing{Endpoint: srv.URL, ServiceName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carrier := opentracing.HTTPHeadersCarrier(http.Header{})
+	carrier.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	remote, err := tr.Extract(opentracing.HTTPHeaders, carrier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := tr.StartSpan("parent", opentracing.ChildOf(remote), opentracing.Tag{Key: "span.kind", Value: "server"})
+	child := tr.StartSpan("child", opentracing.ChildOf(parent.Context()))
+	child.SetTag("error", true)
+	child.LogKV("event", "retry", "attempt", 2)
+	child.Finish()
+	parent.Finish()
+
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-reqs
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if v := req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue; v == nil || *v != "test" {
+		t.Fatalf("unexpected service name %v", v)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if p.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || p.ParentSpanID != "00f067aa0ba902b7" || p.Kind != kindServer {
+		t.Fatalf("unexpected parent span %+v", p)
+	}
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID {
+		t.Fatalf("expected %+v to be a child of %+v", c, p)
+	}
+	if c.Status == nil || c.Status.Code != statusCodeError {
+		t.Fatalf("expected the child to have failed, got %+v", c.Status)
+	}
+	if len(c.Events) != 1 || c.Events[0].Name != "retry" || len(c.Events[0].Attributes) != 1 {
+		t.Fatalf("unexpected events %+v", c.Events)
+	}
+}
@@ -0,0 +1,67 @@
+package wss
+
+import (
+	"context"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// LetsEncryptURL is the ACME directory of the Let's Encrypt CA.
+const LetsEncryptURL = acme.LetsEncryptURL
+
+// certPrefix is where the certificates and the account key are stored.
+var certPrefix = ds.NewKey("/autotls")
+
+// newCertManager returns the manager obtaining a certificate for domain from
+// the ACME CA at ca, Let's Encrypt if empty, and renewing it before it
+// expires. Control of the domain is proven with the tls-alpn-01 challenge,
+// for which the CA connects to port 443 of the domain, so one of the /wss
+// listeners must be reachable there.
+//
+// Creating the manager accepts the terms of service of the CA; the caller
+// must have asked the user first.
+func newCertManager(d ds.Datastore, domain, email, ca string) *autocert.Manager {
+	if ca == "" {
+		ca = LetsEncryptURL
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      dsCache{d},
+		HostPolicy: autocert.HostWhitelist(domain),
+		Email:      email,
+		Client:     &acme.Client{DirectoryURL: ca},
+	}
+}
+
+// dsCache stores the certificates of autocert in the datastore.
+type dsCache struct {
+	dstore ds.Datastore
+}
+
+var _ autocert.Cache = dsCache{}
+
+func (c dsCache) Get(ctx context.Context, name string) ([]byte, error) {
+	b, err := c.dstore.Get(certPrefix.ChildString(name))
+	if err == ds.ErrNotFound {
+		return nil, autocert.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c dsCache) Put(ctx context.Context, name string, data []byte) error {
+	return c.dstore.Put(certPrefix.ChildString(name), data)
+}
+
+func (c dsCache) Delete(ctx context.Context, name string) error {
+	err := c.dstore.Delete(certPrefix.ChildString(name))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package wss
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+
+	ws "github.com/gorilla/websocket"
+)
+
+// closeTimeout bounds the wait for the close message to be written.
+var closeTimeout = 100 * time.Millisecond
+
+// conn is a WebSocket connection read and written as a stream of bytes.
+// Its addresses are those of the TCP connection underneath, with /wss.
+type conn struct {
+	*ws.Conn
+	reader io.Reader
+
+	laddr, raddr ma.Multiaddr
+	closeOnce    sync.Once
+}
+
+var _ manet.Conn = (*conn)(nil)
+
+// newConn wraps c, whose TLS connection is established.
+func newConn(c *ws.Conn) (*conn, error) {
+	laddr, err := wssAddr(c.UnderlyingConn().LocalAddr())
+	if err != nil {
+		return nil, err
+	}
+	raddr, err := wssAddr(c.UnderlyingConn().RemoteAddr())
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, laddr: laddr, raddr: raddr}, nil
+}
+
+// wssAddr returns the /wss multiaddr of a TCP address.
+func wssAddr(a net.Addr) (ma.Multiaddr, error) {
+	tcp, err := manet.FromNetAddr(a)
+	if err != nil {
+		return nil, err
+	}
+	return tcp.Encapsulate(wssComponent()), nil
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			t, r, err := c.Conn.NextReader()
+			if err != nil {
+				if ce, ok := err.(*ws.CloseError); ok && (ce.Code == ws.CloseNormalClosure || ce.Code == ws.CloseNoStatusReceived) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if t != ws.BinaryMessage {
+				continue
+			}
+			c.reader = r
+		}
+
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	if err := c.Conn.WriteMessage(ws.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close sends a close message and closes the connection. Only the first call
+// returns the error.
+func (c *conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.Conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, ""), time.Now().Add(closeTimeout))
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	return c.UnderlyingConn().SetDeadline(t)
+}
+
+func (c *conn) LocalMultiaddr() ma.Multiaddr  { return c.laddr }
+func (c *conn) RemoteMultiaddr() ma.Multiaddr { return c.raddr }
@@ -0,0 +1,213 @@
+package wss
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	peer "gx/ipfs/QmQsErDt8Qgw1XrsXf2BpEzDgGWtB1YLsTAARBup5b6B9W/go-libp2p-peer"
+	manet "gx/ipfs/QmV6FjemM1K8oXjrvuq3wuVWWoU2TLDPmNnKrxHzY3v6Ai/go-multiaddr-net"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+
+	ws "github.com/gorilla/websocket"
+	tpt "github.com/libp2p/go-libp2p-transport"
+	tptu "github.com/libp2p/go-libp2p-transport-upgrader"
+	"golang.org/x/crypto/acme"
+)
+
+var errListenerClosed = errors.New("wss listener closed")
+
+// Transport is the /wss libp2p transport. It dials and accepts WebSocket
+// connections over TLS, then upgrades them like the other transports.
+type Transport struct {
+	Upgrader *tptu.Upgrader
+
+	tlsConf *tls.Config
+	// rootCAs verify the certificates of the dialed peers, the system
+	// roots if nil.
+	rootCAs *x509.CertPool
+}
+
+var _ tpt.Transport = (*Transport)(nil)
+
+// CanDial returns whether a is a /wss address on an IP or a DNS name.
+func (t *Transport) CanDial(a ma.Multiaddr) bool {
+	ps := a.Protocols()
+	if len(ps) != 3 || ps[1].Code != ma.P_TCP || ps[2].Code != P_WSS {
+		return false
+	}
+	switch ps[0].Name {
+	case "ip4", "ip6", "dns4", "dns6":
+		return true
+	}
+	return false
+}
+
+func (t *Transport) Protocols() []int {
+	return []int{P_WSS}
+}
+
+func (t *Transport) Proxy() bool {
+	return false
+}
+
+// Dial opens a connection to p at raddr. The certificate of the peer must be
+// valid for the host of raddr.
+func (t *Transport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (tpt.Conn, error) {
+	c, err := t.dial(ctx, raddr)
+	if err != nil {
+		return nil, err
+	}
+	return t.Upgrader.UpgradeOutbound(ctx, t, c, p)
+}
+
+func (t *Transport) dial(ctx context.Context, raddr ma.Multiaddr) (*conn, error) {
+	ps := raddr.Protocols()
+	host, err := raddr.ValueForProtocol(ps[0].Code)
+	if err != nil {
+		return nil, err
+	}
+	port, err := raddr.ValueForProtocol(ma.P_TCP)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &ws.Dialer{
+		HandshakeTimeout: handshakeTimeout,
+		TLSClientConfig:  &tls.Config{ServerName: host, RootCAs: t.rootCAs},
+	}
+	wc, _, err := d.DialContext(ctx, "wss://"+net.JoinHostPort(host, port)+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newConn(wc)
+	if err != nil {
+		wc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Listen listens on laddr, a /wss address on an IP.
+func (t *Transport) Listen(laddr ma.Multiaddr) (tpt.Listener, error) {
+	l, err := t.listen(laddr)
+	if err != nil {
+		return nil, err
+	}
+	return t.Upgrader.UpgradeListener(t, l), nil
+}
+
+func (t *Transport) listen(laddr ma.Multiaddr) (*listener, error) {
+	tcp, err := manet.ToNetAddr(laddr.Decapsulate(wssComponent()))
+	if err != nil {
+		return nil, err
+	}
+	nl, err := net.Listen(tcp.Network(), tcp.String())
+	if err != nil {
+		return nil, err
+	}
+	maddr, err := wssAddr(nl.Addr())
+	if err != nil {
+		nl.Close()
+		return nil, err
+	}
+
+	l := &listener{
+		nl:       nl,
+		laddr:    maddr,
+		incoming: make(chan *conn),
+		closed:   make(chan struct{}),
+	}
+	l.server = &http.Server{
+		Handler: l,
+		// also bounds the TLS handshake, the deadline is cleared once
+		// the connection is upgraded
+		ReadTimeout: handshakeTimeout,
+		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){
+			// the ACME validation connections end with the handshake
+			acme.ALPNProto: func(_ *http.Server, c *tls.Conn, _ http.Handler) {
+				c.Close()
+			},
+		},
+	}
+	go l.server.Serve(tls.NewListener(nl, t.tlsConf))
+	return l, nil
+}
+
+var upgrader = ws.Upgrader{
+	HandshakeTimeout: handshakeTimeout,
+	// browsers dial from pages of any origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// listener accepts the connections upgraded to WebSocket by its HTTP server.
+type listener struct {
+	nl     net.Listener
+	laddr  ma.Multiaddr
+	server *http.Server
+
+	incoming  chan *conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var _ manet.Listener = (*listener)(nil)
+
+func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wc, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader replied with the error
+		return
+	}
+	wc.UnderlyingConn().SetDeadline(time.Time{})
+
+	c, err := newConn(wc)
+	if err != nil {
+		log.Debugf("wss connection from %s: %s", r.RemoteAddr, err)
+		wc.Close()
+		return
+	}
+
+	select {
+	case l.incoming <- c:
+	case <-l.closed:
+		c.Close()
+	}
+}
+
+func (l *listener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+// Close stops listening. The connections already accepted belong to the
+// swarm, which closes them.
+func (l *listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.server.Close()
+	})
+	return err
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.nl.Addr()
+}
+
+func (l *listener) NetListener() net.Listener {
+	return l.nl
+}
+
+func (l *listener) Multiaddr() ma.Multiaddr {
+	return l.laddr
+}
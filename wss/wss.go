@@ -0,0 +1,199 @@
+// Package wss implements the secure WebSocket (/wss) libp2p transport.
+//
+// The WebSocket transport shipped with libp2p only speaks plain /ws, so wss
+// provides a transport terminating TLS itself, before the WebSocket upgrade.
+// Connections keep the address of the remote peer, as with any other
+// transport. Certificates are either loaded from files or obtained from an
+// ACME CA (see newCertManager).
+//
+// TODO: gorilla/websocket, go-libp2p-transport, go-libp2p-transport-upgrader
+// and golang.org/x/crypto are still imported by their go paths. They must be
+// added with gx import, at the versions go-libp2p is built with, and imported
+// by their gx paths: until then Transport doesn't satisfy the transport
+// interface libp2p.Transport expects.
+package wss
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	logging "gx/ipfs/QmRREK2CAZ5Re2Bd9zZFG6FeYDppUWt5cMgsoUEp3ktgSr/go-log"
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+
+	tptu "github.com/libp2p/go-libp2p-transport-upgrader"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var log = logging.Logger("wss")
+
+// P_WSS is the multiaddr code of the wss protocol.
+const P_WSS = 478
+
+// P_WS is the multiaddr code of the ws protocol, registered by the libp2p
+// WebSocket transport.
+const P_WS = 477
+
+func init() {
+	if ma.ProtocolWithCode(P_WSS).Code == 0 {
+		err := ma.AddProtocol(ma.Protocol{
+			Code:  P_WSS,
+			Name:  "wss",
+			VCode: ma.CodeToVarint(P_WSS),
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// handshakeTimeout bounds the TLS handshake and WebSocket upgrade of
+// incoming connections.
+var handshakeTimeout = 30 * time.Second
+
+// IsWSS returns whether a is a secure WebSocket address.
+func IsWSS(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(P_WSS)
+	return err == nil
+}
+
+// IsWebSocket returns whether a is a plain or secure WebSocket address.
+func IsWebSocket(a ma.Multiaddr) bool {
+	if IsWSS(a) {
+		return true
+	}
+	_, err := a.ValueForProtocol(P_WS)
+	return err == nil
+}
+
+// SplitAddrs separates the /wss addresses from the others.
+func SplitAddrs(addrs []ma.Multiaddr) (wss, rest []ma.Multiaddr) {
+	for _, a := range addrs {
+		if IsWSS(a) {
+			wss = append(wss, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return wss, rest
+}
+
+// Config configures the certificates of the transport.
+type Config struct {
+	// Domain is the name announced in place of the listen IPs. Required
+	// with AutoTLS.
+	Domain string
+
+	// CertFile and KeyFile are PEM files with the certificate chain and
+	// key.
+	CertFile string
+	KeyFile  string
+
+	// AutoTLS obtains the certificate from the ACME CA at CA, or Let's
+	// Encrypt if empty, and stores it in Datastore. The terms of service of
+	// the CA must have been accepted with AcceptTOS.
+	AutoTLS   bool
+	AcceptTOS bool
+	Email     string
+	CA        string
+	Datastore ds.Datastore
+}
+
+// Service holds the certificate of the /wss addresses and builds the
+// transport serving them.
+type Service struct {
+	domain string
+	addrs  []ma.Multiaddr
+	static *tls.Certificate
+	certs  *autocert.Manager
+}
+
+// NewService prepares serving addrs, which must all be /wss addresses. The
+// node listens on them once the transport returned by Transport is added to
+// its host.
+func NewService(cfg Config, addrs []ma.Multiaddr) (*Service, error) {
+	s := &Service{
+		domain: cfg.Domain,
+		addrs:  addrs,
+	}
+
+	switch {
+	case cfg.AutoTLS:
+		if cfg.Domain == "" {
+			return nil, errors.New("AutoTLS requires a domain")
+		}
+		if !cfg.AcceptTOS {
+			return nil, errors.New("AutoTLS requires accepting the terms of service of the CA")
+		}
+		s.certs = newCertManager(cfg.Datastore, cfg.Domain, cfg.Email, cfg.CA)
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load WebSocket certificate: %s", err)
+		}
+		s.static = &cert
+	default:
+		return nil, errors.New("listening on /wss requires a certificate or AutoTLS")
+	}
+	return s, nil
+}
+
+// Transport constructs the /wss transport, to be passed to libp2p.Transport.
+func (s *Service) Transport(u *tptu.Upgrader) *Transport {
+	return &Transport{
+		Upgrader: u,
+		tlsConf: &tls.Config{
+			GetCertificate: s.getCertificate,
+			NextProtos:     []string{"http/1.1", acme.ALPNProto},
+		},
+	}
+}
+
+func (s *Service) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if s.certs != nil {
+		return s.certs.GetCertificate(hello)
+	}
+	return s.static, nil
+}
+
+// RewriteAddrs is an addresses factory announcing the /wss addresses under
+// the domain, when set, which is what browsers need to check the
+// certificate.
+func (s *Service) RewriteAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	if s.domain == "" {
+		return addrs
+	}
+
+	var out []ma.Multiaddr
+	seen := make(map[string]bool)
+	for _, a := range addrs {
+		if IsWSS(a) {
+			da, err := s.domainAddr(a)
+			if err != nil {
+				continue
+			}
+			a = da
+		}
+		if !seen[a.String()] {
+			seen[a.String()] = true
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// domainAddr returns the /dns4 address of the domain with the port of a.
+func (s *Service) domainAddr(a ma.Multiaddr) (ma.Multiaddr, error) {
+	port, err := a.ValueForProtocol(ma.P_TCP)
+	if err != nil {
+		return nil, err
+	}
+	return ma.NewMultiaddr(fmt.Sprintf("/dns4/%s/tcp/%s/wss", s.domain, port))
+}
+
+// wssComponent is a function rather than a variable as the wss protocol is
+// only registered in init.
+func wssComponent() ma.Multiaddr { return ma.StringCast("/wss") }
@@ -0,0 +1,227 @@
+package wss
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ds "gx/ipfs/QmSpg1CvpXQQow5ernt1gNBXaXV6yxyNqi7XoeerWfzB5w/go-datastore"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func writeTestCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, leaf
+}
+
+func TestTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wss-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, leaf := writeTestCert(t, dir)
+
+	s, err := NewService(Config{CertFile: certFile, KeyFile: keyFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt := s.Transport(nil)
+	tpt.rootCAs = x509.NewCertPool()
+	tpt.rootCAs.AddCert(leaf)
+
+	l, err := tpt.listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/wss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if !tpt.CanDial(l.Multiaddr()) {
+		t.Fatalf("expected to dial %s", l.Multiaddr())
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			close(accepted)
+			return
+		}
+		accepted <- c
+		io.Copy(c, c)
+		c.Close()
+	}()
+
+	c, err := tpt.dial(context.Background(), l.Multiaddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("expected hello, got %q", buf)
+	}
+
+	// the accepted connection has the address of the dialer
+	sc := (<-accepted).(*conn)
+	if !sc.RemoteMultiaddr().Equal(c.LocalMultiaddr()) {
+		t.Fatalf("expected remote address %s, got %s", c.LocalMultiaddr(), sc.RemoteMultiaddr())
+	}
+	if !IsWSS(sc.RemoteMultiaddr()) {
+		t.Fatalf("expected a /wss address, got %s", sc.RemoteMultiaddr())
+	}
+}
+
+func TestListenerClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wss-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, _ := writeTestCert(t, dir)
+
+	s, err := NewService(Config{CertFile: certFile, KeyFile: keyFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.Transport(nil).listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/wss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		done <- err
+	}()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != errListenerClosed {
+			t.Fatalf("expected %v, got %v", errListenerClosed, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept did not return after Close")
+	}
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Fatal("expected the listener to be closed")
+	}
+}
+
+func TestRewriteAddrs(t *testing.T) {
+	s := &Service{}
+	in := []ma.Multiaddr{
+		ma.StringCast("/ip4/1.2.3.4/tcp/4001"),
+		ma.StringCast("/ip4/1.2.3.4/tcp/443/wss"),
+		ma.StringCast("/ip4/5.6.7.8/tcp/443/wss"),
+	}
+	if out := s.RewriteAddrs(in); len(out) != len(in) {
+		t.Fatalf("expected the addresses to be kept without a domain, got %v", out)
+	}
+
+	s.domain = "example.com"
+	out := s.RewriteAddrs(in)
+	exp := []string{
+		"/ip4/1.2.3.4/tcp/4001",
+		"/dns4/example.com/tcp/443/wss",
+	}
+	if len(out) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, out)
+	}
+	for i, a := range out {
+		if a.String() != exp[i] {
+			t.Fatalf("expected %v, got %v", exp, out)
+		}
+	}
+}
+
+func TestAutoTLSRequiresTOS(t *testing.T) {
+	cfg := Config{AutoTLS: true, Domain: "example.com", Datastore: ds.NewMapDatastore()}
+	if _, err := NewService(cfg, nil); err == nil {
+		t.Fatal("expected AutoTLS to require accepting the terms of service")
+	}
+
+	cfg.AcceptTOS = true
+	if _, err := NewService(cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertCache(t *testing.T) {
+	ctx := context.Background()
+	c := dsCache{ds.NewMapDatastore()}
+
+	if _, err := c.Get(ctx, "example.com"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expected a cache miss, got %v", err)
+	}
+	if err := c.Put(ctx, "example.com", []byte("cert")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Get(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "cert" {
+		t.Fatalf("expected the stored certificate, got %q", b)
+	}
+	if err := c.Delete(ctx, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "example.com"); err != autocert.ErrCacheMiss {
+		t.Fatalf("expected a cache miss after delete, got %v", err)
+	}
+}